@@ -68,6 +68,9 @@ func (a *CouponRepositoryAdapter) GetByID(ctx context.Context, id uuid.UUID) (*i
 		Code:        c.Code,
 		Size:        string(c.Size),
 		Style:       string(c.Style),
+		ProductType: c.ProductType,
+		Panels:      c.Panels,
+		PanelGapMM:  c.PanelGapMM,
 		Status:      c.Status,
 		UserEmail:   c.UserEmail,
 		CompletedAt: c.CompletedAt,
@@ -86,6 +89,9 @@ func (a *CouponRepositoryAdapter) GetByCode(ctx context.Context, code string) (*
 		Code:        c.Code,
 		Size:        string(c.Size),
 		Style:       string(c.Style),
+		ProductType: c.ProductType,
+		Panels:      c.Panels,
+		PanelGapMM:  c.PanelGapMM,
 		Status:      c.Status,
 		UserEmail:   c.UserEmail,
 		CompletedAt: c.CompletedAt,
@@ -99,6 +105,9 @@ func (a *CouponRepositoryAdapter) Update(ctx context.Context, imgCoupon *image.C
 		Code:        imgCoupon.Code,
 		Size:        imgCoupon.Size,
 		Style:       imgCoupon.Style,
+		ProductType: imgCoupon.ProductType,
+		Panels:      imgCoupon.Panels,
+		PanelGapMM:  imgCoupon.PanelGapMM,
 		Status:      imgCoupon.Status,
 		StonesCount: imgCoupon.StonesCount,
 	}
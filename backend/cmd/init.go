@@ -33,18 +33,22 @@ import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	goredis "github.com/redis/go-redis/v9"
 
 	"github.com/skr1ms/mosaic/config"
 
 	// _ "github.com/skr1ms/mosaic/docs" // Swagger docs
 	"github.com/skr1ms/mosaic/internal/admin"
 	"github.com/skr1ms/mosaic/internal/auth"
+	"github.com/skr1ms/mosaic/internal/bulk"
 	"github.com/skr1ms/mosaic/internal/chat"
 	"github.com/skr1ms/mosaic/internal/coupon"
 	"github.com/skr1ms/mosaic/internal/image"
 	"github.com/skr1ms/mosaic/internal/partner"
 	"github.com/skr1ms/mosaic/internal/payment"
+	"github.com/skr1ms/mosaic/internal/preset"
 	"github.com/skr1ms/mosaic/internal/public"
 	"github.com/skr1ms/mosaic/internal/stats"
 	"github.com/skr1ms/mosaic/migrations"
@@ -54,6 +58,7 @@ import (
 	"github.com/skr1ms/mosaic/pkg/gitlab"
 	"github.com/skr1ms/mosaic/pkg/jwt"
 	"github.com/skr1ms/mosaic/pkg/middleware"
+	"github.com/skr1ms/mosaic/pkg/mockup"
 	"github.com/skr1ms/mosaic/pkg/mosaic"
 	"github.com/skr1ms/mosaic/pkg/palette"
 	"github.com/skr1ms/mosaic/pkg/queue"
@@ -64,8 +69,37 @@ import (
 	"github.com/skr1ms/mosaic/pkg/zip"
 )
 
-func InitializeApp() *fiber.App {
-	appLogger := middleware.NewLogger()
+// ServerOptions selects background jobs API server runs besides handling requests
+type ServerOptions struct {
+	Workers bool // Consume all queues in API process
+	Cron    bool // Run cron jobs and preview cleanup in API process
+}
+
+// core holds connections and clients shared by API server and worker
+type core struct {
+	cfg                   *config.Config
+	database              *db.Db
+	redisClient           *goredis.Client
+	s3Client              *s3.S3Client
+	stableDiffusionClient *stableDiffusion.StableDiffusionClient
+	queueManager          *queue.QueueManager
+}
+
+// workerServices are services queue workers and cron jobs depend on, API server uses them as well
+type workerServices struct {
+	couponRepo    *coupon.CouponRepository
+	partnerRepo   *partner.PartnerRepository
+	imageRepo     *image.ImageRepository
+	mailSender    *email.Mailer
+	emailAdapter  *queue.EmailServiceAdapter
+	imageService  *image.ImageService
+	imageAdapter  *queue.ImageServiceAdapter
+	statsService  *stats.StatsService
+	cronService   *stats.CronService
+	presetService *preset.PresetService
+}
+
+func initCore(appLogger *middleware.Logger) *core {
 	cfg, err := config.NewConfig()
 	if err != nil {
 		appLogger.GetZerologLogger().Fatal().
@@ -110,13 +144,153 @@ func InitializeApp() *fiber.App {
 		panic(fmt.Sprintf("Failed to create S3 client: %v", err))
 	}
 
-	ctx := context.Background()
-	if err := s3Client.StartPreviewCleanupJob(ctx); err != nil {
+	stableDiffusionClient, err := stableDiffusion.NewStableDiffusionClient(cfg.StableDiffusionConfig, appLogger)
+	if err != nil {
+		appLogger.GetZerologLogger().Fatal().
+			Err(err).
+			Msg("Failed to create Stable Diffusion client")
+		panic(fmt.Sprintf("Failed to create Stable Diffusion client: %v", err))
+	}
+
+	queueManager := queue.NewQueueManager(redisClient, appLogger)
+	queueManager.SetDedupWindow(cfg.QueueConfig.DedupWindow)
+	prometheus.MustRegister(queue.NewDepthCollector(queueManager))
+
+	return &core{
+		cfg:                   cfg,
+		database:              database,
+		redisClient:           redisClient,
+		s3Client:              s3Client,
+		stableDiffusionClient: stableDiffusionClient,
+		queueManager:          queueManager,
+	}
+}
+
+func initWorkerServices(c *core, appLogger *middleware.Logger) *workerServices {
+	cfg := c.cfg
+
+	couponRepo := coupon.NewCouponRepository(c.database.DB)
+	partnerRepo := partner.NewPartnerRepository(c.database.DB)
+	imageRepo := image.NewRepository(c.database.DB)
+
+	// Image tasks of partners take turns in queue by partner queue weight
+	c.queueManager.SetShareResolver(imageRepo)
+
+	// Prompt presets tuned by admins replace built-in ones in API and worker processes
+	presetService := preset.NewPresetService(&preset.PresetServiceDeps{
+		PresetRepository: preset.NewPresetRepository(c.database.DB),
+		AIClient:         c.stableDiffusionClient,
+	})
+	c.stableDiffusionClient.SetPresetStore(presetService)
+
+	mailSender := email.NewMailer(cfg, appLogger)
+	zipService := zip.NewZipService(appLogger)
+	paletteService := palette.NewPaletteService(cfg.MosaicGeneratorConfig.PalettePath, appLogger)
+
+	mosaicGenerator := mosaic.NewMosaicGenerator(
+		cfg.MosaicGeneratorConfig.ScriptPath,
+		cfg.MosaicGeneratorConfig.OutputDir,
+		cfg.MosaicGeneratorConfig.PythonCommand,
+		appLogger,
+	)
+
+	var mockupScenes []mockup.Scene
+	if cfg.MockupConfig.Enabled {
+		scenes, err := mockup.Select(cfg.MockupConfig.Scenes, cfg.MockupConfig.ScenesDir)
+		if err != nil {
+			appLogger.GetZerologLogger().Error().
+				Err(err).
+				Str("scenes_dir", cfg.MockupConfig.ScenesDir).
+				Msg("Failed to load mockup scenes, mockups will not be rendered")
+		}
+		mockupScenes = scenes
+	}
+
+	imageService := image.NewImageService(&image.ImageServiceDeps{
+		ImageRepository:       imageRepo,
+		CouponRepository:      NewCouponRepositoryAdapter(couponRepo),
+		S3Client:              c.s3Client,
+		StableDiffusionClient: c.stableDiffusionClient,
+		EmailService:          mailSender,
+		ZipService:            zipService,
+		MosaicGenerator:       mosaicGenerator,
+		PaletteService:        paletteService,
+		BeadBrand:             cfg.MosaicGeneratorConfig.BeadBrand,
+		WorkingDir:            "/tmp",
+		ThumbnailSizes:        cfg.ThumbnailConfig.Sizes,
+		ThumbnailFormat:       cfg.ThumbnailConfig.Format,
+		ThumbnailQuality:      cfg.ThumbnailConfig.Quality,
+		OptimizeQuality:       cfg.ThumbnailConfig.OptimizeQuality,
+		TaskCanceller:         c.queueManager.GetImageQueue(),
+		UpscaleQueue:          c.queueManager.GetImageQueue(),
+		UpscaleEnabled:        cfg.UpscaleConfig.Enabled,
+		MinPixelsPerStone:     cfg.UpscaleConfig.MinPixelsPerStone,
+		TargetPixelsPerStone:  cfg.UpscaleConfig.TargetPixelsPerStone,
+		MockupQueue:           c.queueManager.GetImageQueue(),
+		MockupEnabled:         len(mockupScenes) > 0,
+		MockupScenes:          mockupScenes,
+	})
+
+	statsService := stats.NewStatsService(&stats.StatsServiceDeps{
+		PartnerRepository: partnerRepo,
+		CouponRepository:  couponRepo,
+		RedisClient:       c.redisClient,
+		AIClient:          c.stableDiffusionClient,
+	})
+
+	return &workerServices{
+		couponRepo:    couponRepo,
+		partnerRepo:   partnerRepo,
+		imageRepo:     imageRepo,
+		mailSender:    mailSender,
+		emailAdapter:  queue.NewEmailServiceAdapter(mailSender),
+		imageService:  imageService,
+		imageAdapter:  queue.NewImageServiceAdapter(imageService),
+		statsService:  statsService,
+		cronService:   stats.NewCronService(statsService, couponRepo),
+		presetService: presetService,
+	}
+}
+
+// startCronJobs starts scheduled jobs, only one process of deployment should run them
+func startCronJobs(c *core, services *workerServices, appLogger *middleware.Logger) {
+	if err := c.s3Client.StartPreviewCleanupJob(context.Background()); err != nil {
 		appLogger.GetZerologLogger().Error().
 			Err(err).
 			Msg("Failed to start preview cleanup job")
 	}
 
+	if err := services.cronService.Start(); err != nil {
+		appLogger.GetZerologLogger().Error().
+			Err(err).
+			Msg("Failed to start cron jobs")
+	}
+}
+
+// startMetricsServer serves Prometheus metrics on separate port
+func startMetricsServer(port string) {
+	// Running the metrics server on a separate port - using a regular goroutine for long-running process
+	go func() {
+		metricsApp := fiber.New()
+		metricsApp.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
+
+		// Metrics server is running on port
+		metricsApp.Listen(":" + port)
+	}()
+}
+
+func InitializeApp(opts ServerOptions) *fiber.App {
+	appLogger := middleware.NewLogger()
+	c := initCore(appLogger)
+	services := initWorkerServices(c, appLogger)
+
+	cfg := c.cfg
+	database := c.database
+	redisClient := c.redisClient
+	s3Client := c.s3Client
+	stableDiffusionClient := c.stableDiffusionClient
+	queueManager := c.queueManager
+
 	gitlabClient := gitlab.NewClient(
 		cfg.GitLabConfig.BaseURL,
 		cfg.GitLabConfig.APIToken,
@@ -127,16 +301,17 @@ func InitializeApp() *fiber.App {
 
 	alfaBankClient := payment.NewAlfaBankClient(cfg)
 
-	stableDiffusionClient := stableDiffusion.NewStableDiffusionClient(cfg.StableDiffusionConfig, appLogger)
-
-	queueManager := queue.NewQueueManager(redisClient, appLogger)
-
+	// Bodies above bodyLimit are streamed instead of buffered, so bulk archives are spooled to disk
+	// while parsing multipart form. Other routes are held to bodyLimit by middleware.BodyLimit.
+	const bodyLimit = 50 * 1024 * 1024
 	app := fiber.New(fiber.Config{
-		ErrorHandler: appLogger.ErrorHandler(),
-		BodyLimit:    50 * 1024 * 1024,
-		ReadTimeout:  time.Second * 60,
-		WriteTimeout: time.Second * 60,
-		IdleTimeout:  time.Second * 60,
+		ErrorHandler:                 appLogger.ErrorHandler(),
+		BodyLimit:                    bodyLimit,
+		StreamRequestBody:            true,
+		DisablePreParseMultipartForm: true,
+		ReadTimeout:                  time.Second * 60,
+		WriteTimeout:                 time.Second * 60,
+		IdleTimeout:                  time.Second * 60,
 	})
 	app.Use(cors.New(cors.Config{
 		AllowOriginsFunc: func(origin string) bool {
@@ -175,6 +350,7 @@ func InitializeApp() *fiber.App {
 
 	app.Use(recover.New())
 	app.Use(appLogger.RequestIDMiddleware())
+	app.Use(middleware.BodyLimit(bodyLimit, bulk.IsUploadRequest))
 
 	app.Use(appLogger.SkipLoggingMiddleware("/api/ws/chat", "/health", "/metrics"))
 
@@ -229,20 +405,18 @@ func InitializeApp() *fiber.App {
 
 	// repository
 	adminRepo := admin.NewAdminRepository(database.DB)
-	couponRepo := coupon.NewCouponRepository(database.DB)
-	partnerRepo := partner.NewPartnerRepository(database.DB)
-	imageRepo := image.NewRepository(database.DB)
+	couponRepo := services.couponRepo
+	partnerRepo := services.partnerRepo
+	imageRepo := services.imageRepo
 	paymentRepo := payment.NewPaymentRepository(database.DB)
 	chatRepo := chat.NewRepository(database.DB)
 	publicRepo := public.NewPublicRepository(database.DB)
+	bulkRepo := bulk.NewBulkRepository(database.DB)
 
 	// service
-	mailSender := email.NewMailer(cfg, appLogger)
-	emailAdapter := queue.NewEmailServiceAdapter(mailSender)
+	mailSender := services.mailSender
 	recaptchService := recaptcha.NewVerifier(cfg.RecaptchaConfig.SecretKey, 0.5, appLogger)
 	jwtService := jwt.NewJWT(cfg.AuthConfig.AccessTokenSecret, cfg.AuthConfig.RefreshTokenSecret)
-	zipService := zip.NewZipService(appLogger)
-	paletteService := palette.NewPaletteService(cfg.MosaicGeneratorConfig.PalettePath, appLogger)
 
 	authService := auth.NewAuthService(&auth.AuthServiceDeps{
 		PartnerRepository: partnerRepo,
@@ -261,6 +435,7 @@ func InitializeApp() *fiber.App {
 		S3Client:          s3Client,
 		RedisClient:       redisClient,
 		GitLabClient:      gitlabClient,
+		QueueManager:      queueManager,
 	})
 
 	partnerService := partner.NewPartnerService(&partner.PartnerServiceDeps{
@@ -281,28 +456,7 @@ func InitializeApp() *fiber.App {
 		RedisClient:       redisClient,
 		S3Client:          s3Client,
 	})
-	couponAdapter := NewCouponRepositoryAdapter(couponRepo)
-
-	mosaicGenerator := mosaic.NewMosaicGenerator(
-		cfg.MosaicGeneratorConfig.ScriptPath,
-		cfg.MosaicGeneratorConfig.OutputDir,
-		cfg.MosaicGeneratorConfig.PythonCommand,
-		appLogger,
-	)
-
-	imageService := image.NewImageService(&image.ImageServiceDeps{
-		ImageRepository:       imageRepo,
-		CouponRepository:      couponAdapter,
-		S3Client:              s3Client,
-		StableDiffusionClient: stableDiffusionClient,
-		EmailService:          mailSender,
-		ZipService:            zipService,
-		MosaicGenerator:       mosaicGenerator,
-		PaletteService:        paletteService,
-		WorkingDir:            "/tmp",
-	})
-	imageAdapter := queue.NewImageServiceAdapter(imageService)
-
+	imageService := services.imageService
 
 	paymentService := payment.NewPaymentService(&payment.PaymentServiceDeps{
 		PaymentRepository:         paymentRepo,
@@ -325,25 +479,33 @@ func InitializeApp() *fiber.App {
 		EmailService:      mailSender,
 		S3Client:          s3Client,
 		AIClient:          stableDiffusionClient,
+		ImageTaskQueue:    queueManager.GetImageQueue(),
 		RecaptchaSiteKey:  cfg.RecaptchaConfig.SiteKey,
 	})
 
-	statsService := stats.NewStatsService(&stats.StatsServiceDeps{
-		PartnerRepository: partnerRepo,
-		CouponRepository:  couponRepo,
-		RedisClient:       redisClient,
+	bulkService := bulk.NewBulkService(&bulk.BulkServiceDeps{
+		BulkRepository:   bulkRepo,
+		CouponRepository: couponRepo,
+		ImageRepository:  imageRepo,
+		ImageService:     imageService,
+		ImageTaskQueue:   queueManager.GetImageQueue(),
+		S3Client:         s3Client,
 	})
 
+	statsService := services.statsService
+
 	chatService := chat.NewChatService(&chat.ChatServiceDeps{
 		ChatRepository: chatRepo,
 		S3Client:       s3Client,
 		Hub:            chat.NewHub(),
 	})
 
-	cronService := stats.NewCronService(statsService)
-	cronService.Start()
-
-	queueManager.StartAllWorkers(imageAdapter, emailAdapter)
+	if opts.Cron {
+		startCronJobs(c, services, appLogger)
+	}
+	if opts.Workers {
+		queueManager.StartAllWorkers(services.imageAdapter, services.emailAdapter)
+	}
 
 	// handlers
 	public.NewPublicHandler(api, &public.PublicHandlerDeps{
@@ -391,6 +553,19 @@ func InitializeApp() *fiber.App {
 		Logger:          appLogger,
 	})
 
+	bulk.NewBulkHandler(api, &bulk.BulkHandlerDeps{
+		BulkService:   bulkService,
+		JwtService:    jwtService,
+		Logger:        appLogger,
+		MaxUploadSize: max(bodyLimit, cfg.BulkConfig.MaxArchiveSize),
+	})
+
+	preset.NewPresetHandler(api, &preset.PresetHandlerDeps{
+		PresetService: services.presetService,
+		JwtService:    jwtService,
+		Logger:        appLogger,
+	})
+
 	stats.NewStatsHandler(api, &stats.StatsHandlerDeps{
 		StatsService: statsService,
 		JwtService:   jwtService,
@@ -405,14 +580,7 @@ func InitializeApp() *fiber.App {
 	// Prometheus metrics endpoint
 	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
 
-	// Running the metrics server on a separate port - using a regular goroutine for long-running process
-	go func() {
-		metricsApp := fiber.New()
-		metricsApp.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
-
-		// Metrics server is running on port
-		metricsApp.Listen(":" + cfg.MetricsConfig.Port)
-	}()
+	startMetricsServer(cfg.MetricsConfig.Port)
 
 	return app
 }
@@ -24,18 +24,50 @@ package main
 
 import (
 	"context"
+	"flag"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/skr1ms/mosaic/pkg/middleware"
+	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
 )
 
 var mainLogger = middleware.NewLogger()
 
+// Usage:
+//
+//	main [server] [-workers=true] [-cron=true]   API server, by default also consumes queues and runs cron jobs
+//	main worker [-queues=images] [-cron=false]    only queue workers, cron jobs when enabled
+//	main fake-sd [-addr=:7860] [-max-side=0]      deterministic fake AI server for development without GPU
 func main() {
-	app := InitializeApp()
+	command, args := "server", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "server":
+		runServer(args)
+	case "worker":
+		runWorker(args)
+	case "fake-sd":
+		runFakeStableDiffusion(args)
+	default:
+		mainLogger.GetZerologLogger().Fatal().Str("command", command).Msg("Unknown command, expected server, worker or fake-sd")
+	}
+}
+
+func runServer(args []string) {
+	flags := flag.NewFlagSet("server", flag.ExitOnError)
+	workers := flags.Bool("workers", true, "consume queues in API process")
+	cron := flags.Bool("cron", true, "run cron jobs in API process")
+	flags.Parse(args)
+
+	app := InitializeApp(ServerOptions{Workers: *workers, Cron: *cron})
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -67,3 +99,44 @@ func main() {
 
 	mainLogger.GetZerologLogger().Info().Msg("Application shutdown complete")
 }
+
+func runWorker(args []string) {
+	flags := flag.NewFlagSet("worker", flag.ExitOnError)
+	queues := flags.String("queues", "", "comma separated queues to consume, all queues when empty")
+	cron := flags.Bool("cron", false, "run cron jobs, enable in one process of deployment only")
+	flags.Parse(args)
+
+	var queueNames []string
+	for _, name := range strings.Split(*queues, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			queueNames = append(queueNames, name)
+		}
+	}
+
+	worker, err := InitializeWorker(WorkerOptions{Queues: queueNames, Cron: *cron})
+	if err != nil {
+		mainLogger.GetZerologLogger().Fatal().Err(err).Msg("Failed to start worker")
+	}
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	<-sigChan
+
+	mainLogger.GetZerologLogger().Info().Msg("Shutdown signal received")
+	worker.Stop()
+}
+
+func runFakeStableDiffusion(args []string) {
+	flags := flag.NewFlagSet("fake-sd", flag.ExitOnError)
+	addr := flags.String("addr", ":7860", "listen address")
+	maxSide := flags.Int("max-side", 0, "fail requests for larger images with out of memory error, 0 disables")
+	flags.Parse(args)
+
+	server := stableDiffusion.NewFakeServer()
+	server.MaxSide = *maxSide
+
+	mainLogger.GetZerologLogger().Info().Str("addr", *addr).Msg("Starting fake Stable Diffusion server")
+	if err := http.ListenAndServe(*addr, server); err != nil {
+		mainLogger.GetZerologLogger().Fatal().Err(err).Msg("Fake Stable Diffusion server stopped")
+	}
+}
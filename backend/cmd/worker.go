@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/skr1ms/mosaic/internal/stats"
+	"github.com/skr1ms/mosaic/pkg/middleware"
+	"github.com/skr1ms/mosaic/pkg/queue"
+)
+
+// WorkerOptions selects what standalone worker runs
+type WorkerOptions struct {
+	Queues []string // Queues to consume, all queues when empty
+	Cron   bool     // Run cron jobs and preview cleanup
+}
+
+// Worker runs queue workers and cron jobs without HTTP server, so heavy generation
+// does not compete with request handling and workers scale independently of API
+type Worker struct {
+	queueManager *queue.QueueManager
+	cronService  *stats.CronService
+	cronStarted  bool
+	logger       *middleware.Logger
+}
+
+// InitializeWorker connects to storage and starts consuming queues
+func InitializeWorker(opts WorkerOptions) (*Worker, error) {
+	appLogger := middleware.NewLogger()
+	c := initCore(appLogger)
+	services := initWorkerServices(c, appLogger)
+
+	if err := c.queueManager.StartWorkers(services.imageAdapter, services.emailAdapter, opts.Queues); err != nil {
+		return nil, err
+	}
+
+	if opts.Cron {
+		startCronJobs(c, services, appLogger)
+	}
+
+	startMetricsServer(c.cfg.MetricsConfig.Port)
+
+	appLogger.GetZerologLogger().Info().
+		Strs("queues", opts.Queues).
+		Bool("cron", opts.Cron).
+		Str("worker_id", c.queueManager.GetImageQueue().WorkerID()).
+		Msg("Worker started")
+
+	return &Worker{
+		queueManager: c.queueManager,
+		cronService:  services.cronService,
+		cronStarted:  opts.Cron,
+		logger:       appLogger,
+	}, nil
+}
+
+// Stop stops consuming queues and cron jobs, tasks in progress are redelivered after their lease expires
+func (w *Worker) Stop() {
+	if w.cronStarted {
+		w.cronService.Stop()
+	}
+	w.queueManager.StopAll()
+
+	w.logger.GetZerologLogger().Info().Msg("Worker stopped")
+}
@@ -29,3 +29,23 @@ func (c *Config) GetMosaicGeneratorConfig() MosaicGeneratorConfig {
 func (c *Config) GetGitLabConfig() GitLabConfig {
 	return c.GitLabConfig
 }
+
+func (c *Config) GetQueueConfig() QueueConfig {
+	return c.QueueConfig
+}
+
+func (c *Config) GetThumbnailConfig() ThumbnailConfig {
+	return c.ThumbnailConfig
+}
+
+func (c *Config) GetBulkConfig() BulkConfig {
+	return c.BulkConfig
+}
+
+func (c *Config) GetUpscaleConfig() UpscaleConfig {
+	return c.UpscaleConfig
+}
+
+func (c *Config) GetMockupConfig() MockupConfig {
+	return c.MockupConfig
+}
@@ -27,6 +27,11 @@ type Config struct {
 	DefaultAdminConfig    DefaultAdminConfig
 	DefaultPartnerConfig  DefaultPartnerConfig
 	GitLabConfig          GitLabConfig
+	QueueConfig           QueueConfig
+	ThumbnailConfig       ThumbnailConfig
+	BulkConfig            BulkConfig
+	UpscaleConfig         UpscaleConfig
+	MockupConfig          MockupConfig
 }
 
 type ServerConfig struct {
@@ -87,7 +92,12 @@ type S3MinioConfig struct {
 }
 
 type StableDiffusionConfig struct {
-	BaseURL string
+	BaseURL           string
+	Backend           string // API flavour of AI server: automatic1111 or comfyui
+	WorkflowPath      string // ComfyUI workflow in API format, built-in img2img workflow when empty
+	Checkpoint        string // ComfyUI checkpoint used by built-in workflow
+	Upscaler          string // Upscaler model of AI server, backend default when empty
+	SegmentationModel string // Background removal model of AI server, backend default when empty
 }
 
 type MosaicGeneratorConfig struct {
@@ -95,6 +105,7 @@ type MosaicGeneratorConfig struct {
 	PalettePath   string
 	OutputDir     string
 	PythonCommand string
+	BeadBrand     string
 }
 
 type MetricsConfig struct {
@@ -132,6 +143,33 @@ type GitLabConfig struct {
 	ProjectID    string
 }
 
+type QueueConfig struct {
+	DedupWindow time.Duration
+}
+
+type BulkConfig struct {
+	MaxArchiveSize int // Maximum size of uploaded archive of bulk job in bytes
+}
+
+type UpscaleConfig struct {
+	Enabled              bool
+	MinPixelsPerStone    float64 // Images with fewer source pixels per stone of coupon size are upscaled
+	TargetPixelsPerStone float64 // Pixels per stone upscaled image should have
+}
+
+type MockupConfig struct {
+	Enabled   bool
+	Scenes    []string // Names of scenes rendered for each schema, all available scenes when empty
+	ScenesDir string   // Directory with scenes.json and backgrounds of custom scenes
+}
+
+type ThumbnailConfig struct {
+	Sizes           []string
+	Format          string
+	Quality         int
+	OptimizeQuality int
+}
+
 func NewConfig() (*Config, error) {
 	envPath := filepath.Join("..", ".env")
 	err := godotenv.Load(envPath)
@@ -193,13 +231,19 @@ func NewConfig() (*Config, error) {
 			PublicURL:         os.Getenv("MINIO_PUBLIC_URL"),
 		},
 		StableDiffusionConfig: StableDiffusionConfig{
-			BaseURL: os.Getenv("STABLE_DIFFUSION_URL"),
+			BaseURL:           os.Getenv("STABLE_DIFFUSION_URL"),
+			Backend:           getStableDiffusionBackend(),
+			WorkflowPath:      os.Getenv("STABLE_DIFFUSION_WORKFLOW"),
+			Checkpoint:        getStableDiffusionCheckpoint(),
+			Upscaler:          os.Getenv("STABLE_DIFFUSION_UPSCALER"),
+			SegmentationModel: os.Getenv("STABLE_DIFFUSION_SEGMENTATION_MODEL"),
 		},
 		MosaicGeneratorConfig: MosaicGeneratorConfig{
 			ScriptPath:    "/app/scripts/mosaic_cli.py",
 			PalettePath:   "/app/scripts/",
 			OutputDir:     "/tmp/mosaic_output/",
 			PythonCommand: "python3",
+			BeadBrand:     getBeadBrand(),
 		},
 		DefaultAdminConfig: DefaultAdminConfig{
 			DefaultLogin:    "admin",
@@ -229,6 +273,28 @@ func NewConfig() (*Config, error) {
 			TriggerToken: os.Getenv("GITLAB_TRIGGER_TOKEN"),
 			ProjectID:    os.Getenv("GITLAB_PROJECT_ID"),
 		},
+		QueueConfig: QueueConfig{
+			DedupWindow: getQueueDedupWindow(),
+		},
+		ThumbnailConfig: ThumbnailConfig{
+			Sizes:           getThumbnailSizes(),
+			Format:          getThumbnailFormat(),
+			Quality:         getImageQuality("THUMBNAIL_QUALITY", 80),
+			OptimizeQuality: getImageQuality("IMAGE_OPTIMIZE_QUALITY", 85),
+		},
+		BulkConfig: BulkConfig{
+			MaxArchiveSize: getBulkMaxArchiveSize(),
+		},
+		UpscaleConfig: UpscaleConfig{
+			Enabled:              getUpscaleEnabled(),
+			MinPixelsPerStone:    getPixelsPerStone("UPSCALE_MIN_PIXELS_PER_STONE", 3),
+			TargetPixelsPerStone: getPixelsPerStone("UPSCALE_TARGET_PIXELS_PER_STONE", 6),
+		},
+		MockupConfig: MockupConfig{
+			Enabled:   getMockupEnabled(),
+			Scenes:    getMockupScenes(),
+			ScenesDir: os.Getenv("MOCKUP_SCENES_DIR"),
+		},
 	}
 
 	if err := validateConfig(config); err != nil {
@@ -265,6 +331,149 @@ func getSMTPSSL() bool {
 	return ssl
 }
 
+func getBeadBrand() string {
+	brand := os.Getenv("MOSAIC_BEAD_BRAND")
+	if brand == "" {
+		return "hama" // default bead brand
+	}
+	return brand
+}
+
+func getStableDiffusionBackend() string {
+	backend := strings.ToLower(os.Getenv("STABLE_DIFFUSION_BACKEND"))
+	switch backend {
+	case "":
+		return "automatic1111" // default AI backend
+	case "automatic1111", "comfyui":
+		return backend
+	default:
+		log.Printf("Warning: Invalid STABLE_DIFFUSION_BACKEND value '%s', using default automatic1111", backend)
+		return "automatic1111"
+	}
+}
+
+func getStableDiffusionCheckpoint() string {
+	checkpoint := os.Getenv("STABLE_DIFFUSION_CHECKPOINT")
+	if checkpoint == "" {
+		return "v1-5-pruned-emaonly.safetensors" // default ComfyUI checkpoint
+	}
+	return checkpoint
+}
+
+func getQueueDedupWindow() time.Duration {
+	windowStr := os.Getenv("QUEUE_DEDUP_WINDOW")
+	if windowStr == "" {
+		return 10 * time.Minute // default deduplication window
+	}
+	window, err := time.ParseDuration(windowStr)
+	if err != nil || window <= 0 {
+		log.Printf("Warning: Invalid QUEUE_DEDUP_WINDOW value '%s', using default 10m", windowStr)
+		return 10 * time.Minute
+	}
+	return window
+}
+
+func getBulkMaxArchiveSize() int {
+	sizeStr := os.Getenv("BULK_MAX_ARCHIVE_MB")
+	if sizeStr == "" {
+		return 1024 << 20 // default 1GB
+	}
+	size, err := strconv.Atoi(sizeStr)
+	if err != nil || size <= 0 {
+		log.Printf("Warning: Invalid BULK_MAX_ARCHIVE_MB value '%s', using default 1024", sizeStr)
+		return 1024 << 20
+	}
+	return size << 20
+}
+
+func getUpscaleEnabled() bool {
+	enabledStr := os.Getenv("UPSCALE_ENABLED")
+	if enabledStr == "" {
+		return true // upscaling enabled by default
+	}
+	enabled, err := strconv.ParseBool(enabledStr)
+	if err != nil {
+		log.Printf("Warning: Invalid UPSCALE_ENABLED value '%s', using default true", enabledStr)
+		return true
+	}
+	return enabled
+}
+
+func getMockupEnabled() bool {
+	enabledStr := os.Getenv("MOCKUP_ENABLED")
+	if enabledStr == "" {
+		return true // mockups enabled by default
+	}
+	enabled, err := strconv.ParseBool(enabledStr)
+	if err != nil {
+		log.Printf("Warning: Invalid MOCKUP_ENABLED value '%s', using default true", enabledStr)
+		return true
+	}
+	return enabled
+}
+
+func getMockupScenes() []string {
+	scenesStr := os.Getenv("MOCKUP_SCENES")
+	if scenesStr == "" {
+		return []string{"wall", "table"} // default built-in scenes
+	}
+	var scenes []string
+	for _, scene := range strings.Split(scenesStr, ",") {
+		if scene = strings.TrimSpace(scene); scene != "" {
+			scenes = append(scenes, scene)
+		}
+	}
+	return scenes
+}
+
+func getPixelsPerStone(name string, defaultValue float64) float64 {
+	valueStr := os.Getenv(name)
+	if valueStr == "" {
+		return defaultValue
+	}
+	value, err := strconv.ParseFloat(valueStr, 64)
+	if err != nil || value <= 0 {
+		log.Printf("Warning: Invalid %s value '%s', using default %g", name, valueStr, defaultValue)
+		return defaultValue
+	}
+	return value
+}
+
+func getThumbnailSizes() []string {
+	sizesStr := os.Getenv("THUMBNAIL_SIZES")
+	if sizesStr == "" {
+		return []string{"160", "320", "640"} // default thumbnail sizes
+	}
+	var sizes []string
+	for _, size := range strings.Split(sizesStr, ",") {
+		if size = strings.TrimSpace(size); size != "" {
+			sizes = append(sizes, size)
+		}
+	}
+	return sizes
+}
+
+func getThumbnailFormat() string {
+	format := strings.ToLower(os.Getenv("THUMBNAIL_FORMAT"))
+	if format != "webp" && format != "jpeg" {
+		return "webp" // default thumbnail format
+	}
+	return format
+}
+
+func getImageQuality(name string, defaultQuality int) int {
+	qualityStr := os.Getenv(name)
+	if qualityStr == "" {
+		return defaultQuality
+	}
+	quality, err := strconv.Atoi(qualityStr)
+	if err != nil || quality < 1 || quality > 100 {
+		log.Printf("Warning: Invalid %s value '%s', using default %d", name, qualityStr, defaultQuality)
+		return defaultQuality
+	}
+	return quality
+}
+
 func validateConfig(config *Config) error {
 	var missingVars []string
 
@@ -2,8 +2,10 @@ package admin
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,10 +15,11 @@ import (
 	"github.com/skr1ms/mosaic/internal/image"
 	"github.com/skr1ms/mosaic/internal/partner"
 
+	apperrors "github.com/skr1ms/mosaic/pkg/errors"
 	"github.com/skr1ms/mosaic/pkg/jwt"
 	"github.com/skr1ms/mosaic/pkg/marketplace"
 	"github.com/skr1ms/mosaic/pkg/middleware"
-	"github.com/skr1ms/mosaic/pkg/randomCouponCode"
+	"github.com/skr1ms/mosaic/pkg/queue"
 )
 
 type AdminHandlerDeps struct {
@@ -91,6 +94,12 @@ func NewAdminHandler(router fiber.Router, deps *AdminHandlerDeps) {
 	adminRoutes.Get("/coupons/export/partner/:id", handler.ExportPartnerCoupons)        // GET /api/admin/coupons/export/partner/:id
 	adminRoutes.Post("/coupons/batch-delete", handler.BatchDeleteCoupons)               // POST /api/admin/coupons/batch-delete
 	adminRoutes.Post("/coupons/batch/reset", handler.BatchResetCoupons)                 // POST /api/admin/coupons/batch/reset
+	adminRoutes.Post("/coupons/batch/extend-expiry", handler.ExtendCouponsExpiry)       // POST /api/admin/coupons/batch/extend-expiry
+	adminRoutes.Get("/coupons/batches", handler.GetCouponBatches)                       // GET /api/admin/coupons/batches
+	adminRoutes.Get("/coupons/batches/:id", handler.GetCouponBatch)                     // GET /api/admin/coupons/batches/:id
+	adminRoutes.Get("/coupons/batches/:id/export", handler.ExportCouponBatch)           // GET /api/admin/coupons/batches/:id/export
+	adminRoutes.Patch("/coupons/batches/:id/block", handler.BlockCouponBatch)           // PATCH /api/admin/coupons/batches/:id/block
+	adminRoutes.Patch("/coupons/batches/:id/unblock", handler.UnblockCouponBatch)       // PATCH /api/admin/coupons/batches/:id/unblock
 	adminRoutes.Get("/coupons/:id", handler.GetCoupon)                                  // GET /api/admin/coupons/:id
 	adminRoutes.Get("/coupons/:id/download-materials", handler.DownloadCouponMaterials) // GET /api/admin/coupons/:id/download-materials
 	adminRoutes.Patch("/coupons/:id/reset", handler.ResetCoupon)                        // PATCH /api/admin/coupons/:id/reset
@@ -107,6 +116,14 @@ func NewAdminHandler(router fiber.Router, deps *AdminHandlerDeps) {
 	adminRoutes.Delete("/images/:id", handler.DeleteImageTask)    // DELETE /api/admin/images/:id
 	adminRoutes.Post("/images/:id/retry", handler.RetryImageTask) // POST /api/admin/images/:id/retry
 
+	// Dead-letter queues
+	adminRoutes.Get("/queues/:queue/dead", handler.GetDeadTasks)                    // GET /api/admin/queues/:queue/dead
+	adminRoutes.Post("/queues/:queue/dead/replay", handler.ReplayDeadTasks)         // POST /api/admin/queues/:queue/dead/replay
+	adminRoutes.Post("/queues/:queue/dead/purge", handler.PurgeDeadTasks)           // POST /api/admin/queues/:queue/dead/purge
+	adminRoutes.Get("/queues/:queue/dead/:task_id", handler.GetDeadTask)            // GET /api/admin/queues/:queue/dead/:task_id
+	adminRoutes.Post("/queues/:queue/dead/:task_id/replay", handler.ReplayDeadTask) // POST /api/admin/queues/:queue/dead/:task_id/replay
+	adminRoutes.Delete("/queues/:queue/dead/:task_id", handler.DeleteDeadTask)      // DELETE /api/admin/queues/:queue/dead/:task_id
+
 	// Endpoints for working with partner articles
 	adminRoutes.Get("/partners/:id/articles/grid", handler.GetPartnerArticleGrid)       // GET /api/admin/partners/:id/articles/grid
 	adminRoutes.Put("/partners/:id/articles/sku", handler.UpdatePartnerArticleSKU)      // PUT /api/admin/partners/:id/articles/sku
@@ -1052,6 +1069,7 @@ func (handler *AdminHandler) GetCoupons(c *fiber.Ctx) error {
 // @Param created_to query string false "Creation date to (RFC3339)"
 // @Param used_from query string false "Usage date from (RFC3339)"
 // @Param used_to query string false "Usage date to (RFC3339)"
+// @Param expired query bool false "Only expired (true) or not expired (false) coupons"
 // @Success 200 {object} map[string]any "Coupons with pagination info"
 // @Failure 400 {object} map[string]any "Invalid request parameters"
 // @Failure 401 {object} map[string]any "Unauthorized"
@@ -1091,6 +1109,18 @@ func (handler *AdminHandler) GetCouponsPaginated(c *fiber.Ctx) error {
 		}
 	}
 
+	var expired *bool
+	if expiredStr := c.Query("expired"); expiredStr != "" {
+		value, err := strconv.ParseBool(expiredStr)
+		if err != nil {
+			handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Invalid expired filter")
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid expired filter",
+			})
+		}
+		expired = &value
+	}
+
 	var createdFromPtr, createdToPtr, usedFromPtr, usedToPtr *time.Time
 	if createdFromStr != "" {
 		if t, err := time.Parse(time.RFC3339, createdFromStr); err == nil {
@@ -1115,7 +1145,7 @@ func (handler *AdminHandler) GetCouponsPaginated(c *fiber.Ctx) error {
 
 	coupons, total, err := handler.deps.AdminService.GetCouponRepository().SearchWithPagination(
 		context.Background(), code, status, size, style, partnerID, page, limit,
-		createdFromPtr, createdToPtr, usedFromPtr, usedToPtr, sortBy, sortDir,
+		createdFromPtr, createdToPtr, usedFromPtr, usedToPtr, expired, sortBy, sortDir,
 	)
 	if err != nil {
 		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to get coupons")
@@ -1148,6 +1178,7 @@ func (handler *AdminHandler) GetCouponsPaginated(c *fiber.Ctx) error {
 			"zip_url":           coupon.ZipURL,
 			"schema_sent_email": coupon.SchemaSentEmail,
 			"schema_sent_at":    coupon.SchemaSentAt,
+			"is_expired":        coupon.IsExpired,
 			"created_at":        coupon.CreatedAt,
 		}
 	}
@@ -1202,76 +1233,76 @@ func (handler *AdminHandler) CreateCoupons(c *fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request"})
 	}
 
-	var partnerCode string = "0000"
-	effectivePartnerID := req.PartnerID
-	if req.PartnerID != uuid.Nil {
-		partner, err := handler.deps.AdminService.GetPartnerRepository().GetByID(context.Background(), req.PartnerID)
-		if err != nil {
-			handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Resource not found")
-			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
-				"error": "Resource not found",
-			})
-		}
-		partnerCode = partner.PartnerCode
-	} else {
-		own, err := handler.deps.AdminService.GetPartnerRepository().GetByPartnerCode(context.Background(), "0000")
-		if err != nil {
-			handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to get own partner")
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to get own partner",
-			})
-		}
-		effectivePartnerID = own.ID
+	if !coupon.IsSizeAvailableForProductType(req.ProductType, req.Size) {
+		handler.deps.Logger.FromContext(c).Error().Str("size", string(req.Size)).Str("product_type", string(req.ProductType)).Msg("Size is not available for product type")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Size is not available for product type"})
 	}
 
-	coupons := make([]*coupon.Coupon, 0, req.Count)
-	codes := make([]string, 0, req.Count)
+	if err := coupon.ValidatePanelLayout(req.Panels, req.PanelGapMM); err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Int("panels", req.Panels).Int("panel_gap_mm", req.PanelGapMM).Msg("Invalid panel layout")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	for i := 0; i < req.Count; i++ {
-		code, err := randomCouponCode.GenerateUniqueCouponCode(partnerCode, handler.deps.AdminService.GetCouponRepository())
-		if err != nil {
-			handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to generate coupon code")
-			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
-				"error": "Failed to generate coupon code",
-			})
-		}
+	if err := coupon.ValidateValidityWindow(req.ValidFrom, req.ExpiresAt, time.Now()); err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Invalid validity window")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
-		coupons = append(coupons, &coupon.Coupon{
-			Code:      code,
-			PartnerID: effectivePartnerID,
-			Size:      string(req.Size),
-			Style:     string(req.Style),
-			Status:    string(coupon.StatusNew),
-		})
-		codes = append(codes, code)
+	createdBy := ""
+	if claims, err := jwt.GetClaimsFromFiberContext(c); err == nil {
+		createdBy = claims.Login
 	}
 
-	if err := handler.deps.AdminService.GetCouponRepository().CreateBatch(context.Background(), coupons); err != nil {
+	batch, coupons, err := handler.deps.AdminService.CreateCoupons(req, createdBy)
+	if err != nil {
+		if strings.Contains(err.Error(), "partner not found") {
+			handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Resource not found")
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Resource not found",
+			})
+		}
 		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to create coupons")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to create coupons",
 		})
 	}
 
+	codes := make([]string, 0, len(coupons))
+	for _, created := range coupons {
+		codes = append(codes, created.Code)
+	}
+
 	handler.deps.Logger.FromContext(c).Info().Interface("context", map[string]any{
-		"count":      req.Count,
-		"partner_id": req.PartnerID,
-		"size":       req.Size,
-		"style":      req.Style,
+		"count":        req.Count,
+		"partner_id":   req.PartnerID,
+		"size":         req.Size,
+		"style":        req.Style,
+		"product_type": req.ProductType,
+		"panels":       req.Panels,
+		"panel_gap_mm": req.PanelGapMM,
+		"valid_from":   req.ValidFrom,
+		"expires_at":   req.ExpiresAt,
+		"batch_id":     batch.ID,
 	}).Msg("Coupons created")
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"message":     "Coupons created successfully",
-		"count":       req.Count,
-		"codes":       codes,
-		"partner_id":  req.PartnerID,
-		"size":        req.Size,
-		"style":       req.Style,
-		"codes_range": []string{codes[0], codes[len(codes)-1]},
+		"message":      "Coupons created successfully",
+		"count":        req.Count,
+		"codes":        codes,
+		"partner_id":   req.PartnerID,
+		"size":         req.Size,
+		"style":        req.Style,
+		"product_type": req.ProductType,
+		"panels":       req.Panels,
+		"panel_gap_mm": req.PanelGapMM,
+		"valid_from":   req.ValidFrom,
+		"expires_at":   req.ExpiresAt,
+		"batch_id":     batch.ID,
+		"codes_range":  []string{codes[0], codes[len(codes)-1]},
 	})
 }
 
 // @Summary Export partner coupons for admin
-// @Description Exports coupons of specific partner with status "new" in .txt or .csv format
+// @Description Exports not expired coupons of specific partner with status "new" in .txt or .csv format
 // @Tags admin-coupons
 // @Produce text/plain,text/csv
 // @Security BearerAuth
@@ -1307,10 +1338,12 @@ func (handler *AdminHandler) ExportPartnerCoupons(c *fiber.Ctx) error {
 		})
 	}
 
+	notExpired := false
 	options := coupon.ExportOptionsRequest{
 		Format:        coupon.ExportFormatAdmin,
 		PartnerID:     &partnerIDStr,
 		Status:        "new",
+		Expired:       &notExpired,
 		FileFormat:    format,
 		IncludeHeader: true,
 	}
@@ -1335,6 +1368,219 @@ func (handler *AdminHandler) ExportPartnerCoupons(c *fiber.Ctx) error {
 	return c.Send(content)
 }
 
+// @Summary Get coupon batches
+// @Description Returns print runs of coupons with number of activated coupons and activation rate
+// @Tags admin-coupons
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Number of items per page (default 20, max 100)"
+// @Param partner_id query string false "Partner ID"
+// @Success 200 {object} coupon.CouponBatchListResponse "Coupon batches with pagination info"
+// @Failure 400 {object} map[string]any "Invalid partner ID"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/coupons/batches [get]
+func (handler *AdminHandler) GetCouponBatches(c *fiber.Ctx) error {
+	var partnerID *uuid.UUID
+	if partnerIDStr := c.Query("partner_id"); partnerIDStr != "" {
+		id, err := uuid.Parse(partnerIDStr)
+		if err != nil {
+			handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Invalid partner ID")
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid partner ID",
+			})
+		}
+		partnerID = &id
+	}
+
+	response, err := handler.deps.AdminService.GetCouponBatches(partnerID, c.QueryInt("page", 1), c.QueryInt("limit", 20))
+	if err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to get coupon batches")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get coupon batches",
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// @Summary Get coupon batch
+// @Description Returns print run of coupons with number of activated coupons and activation rate
+// @Tags admin-coupons
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Batch ID"
+// @Success 200 {object} coupon.CouponBatchStats "Coupon batch"
+// @Failure 400 {object} map[string]any "Invalid ID"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 404 {object} map[string]any "Batch not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/coupons/batches/{id} [get]
+func (handler *AdminHandler) GetCouponBatch(c *fiber.Ctx) error {
+	batchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Invalid batch ID")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid batch ID",
+		})
+	}
+
+	batch, err := handler.deps.AdminService.GetCouponBatch(batchID)
+	if err != nil {
+		if err.Error() == "not found" {
+			handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Batch not found")
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Batch not found",
+			})
+		}
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to get coupon batch")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to get coupon batch",
+		})
+	}
+
+	return c.JSON(batch)
+}
+
+// @Summary Export coupon batch
+// @Description Exports all coupons of print run in .txt or .csv format
+// @Tags admin-coupons
+// @Produce text/plain,text/csv
+// @Security BearerAuth
+// @Param id path string true "Batch ID"
+// @Param format query string false "File format (txt or csv)" default(txt)
+// @Success 200 {string} string "Batch coupons file"
+// @Failure 400 {object} map[string]any "Invalid ID"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 404 {object} map[string]any "Batch not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/coupons/batches/{id}/export [get]
+func (handler *AdminHandler) ExportCouponBatch(c *fiber.Ctx) error {
+	batchIDStr := c.Params("id")
+	batchID, err := uuid.Parse(batchIDStr)
+	if err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Invalid batch ID")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid batch ID",
+		})
+	}
+
+	format := strings.ToLower(c.Query("format", "txt"))
+	if format != "txt" && format != "csv" {
+		format = "txt"
+	}
+
+	if _, err := handler.deps.AdminService.GetCouponBatch(batchID); err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Batch not found")
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error": "Batch not found",
+		})
+	}
+
+	options := coupon.ExportOptionsRequest{
+		Format:        coupon.ExportFormatAdmin,
+		BatchID:       &batchIDStr,
+		FileFormat:    format,
+		IncludeHeader: true,
+	}
+
+	content, filename, contentType, err := handler.deps.AdminService.ExportCouponsAdvanced(options)
+	if err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to export coupon batch")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to export coupon batch",
+		})
+	}
+
+	c.Set("Content-Type", contentType)
+	c.Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s", filename))
+	c.Set("Cache-Control", "no-cache")
+
+	handler.deps.Logger.FromContext(c).Info().Interface("context", map[string]any{
+		"batch_id": batchID,
+		"format":   format,
+		"filename": filename,
+	}).Msg("Coupon batch exported")
+	return c.Send(content)
+}
+
+// @Summary Block coupon batch
+// @Description Blocks all coupons of print run, blocked coupons cannot be activated
+// @Tags admin-coupons
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Batch ID"
+// @Success 200 {object} map[string]any "Batch blocked"
+// @Failure 400 {object} map[string]any "Invalid ID"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 404 {object} map[string]any "Batch not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/coupons/batches/{id}/block [patch]
+func (handler *AdminHandler) BlockCouponBatch(c *fiber.Ctx) error {
+	return handler.setCouponBatchBlocked(c, true)
+}
+
+// @Summary Unblock coupon batch
+// @Description Unblocks all coupons of print run
+// @Tags admin-coupons
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Batch ID"
+// @Success 200 {object} map[string]any "Batch unblocked"
+// @Failure 400 {object} map[string]any "Invalid ID"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 404 {object} map[string]any "Batch not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/coupons/batches/{id}/unblock [patch]
+func (handler *AdminHandler) UnblockCouponBatch(c *fiber.Ctx) error {
+	return handler.setCouponBatchBlocked(c, false)
+}
+
+func (handler *AdminHandler) setCouponBatchBlocked(c *fiber.Ctx, blocked bool) error {
+	batchID, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Invalid batch ID")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid batch ID",
+		})
+	}
+
+	updated, err := handler.deps.AdminService.SetCouponBatchBlocked(batchID, blocked)
+	if err != nil {
+		if err.Error() == "not found" {
+			handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Batch not found")
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Batch not found",
+			})
+		}
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to update coupon batch")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to update coupon batch",
+		})
+	}
+
+	message := "Batch unblocked successfully"
+	if blocked {
+		message = "Batch blocked successfully"
+	}
+
+	handler.deps.Logger.FromContext(c).Info().Interface("context", map[string]any{
+		"batch_id":        batchID,
+		"blocked":         blocked,
+		"coupons_updated": updated,
+	}).Msg("Coupon batch block status changed")
+	return c.JSON(fiber.Map{
+		"message":         message,
+		"coupons_updated": updated,
+	})
+}
+
 // @Summary Batch delete coupons for admin
 // @Description Deletes multiple coupons by their IDs in administrative panel
 // @Tags admin-coupons
@@ -1451,6 +1697,10 @@ func (handler *AdminHandler) GetCoupon(c *fiber.Ctx) error {
 		"zip_url":           coupon.ZipURL,
 		"schema_sent_email": coupon.SchemaSentEmail,
 		"schema_sent_at":    coupon.SchemaSentAt,
+		"batch_id":          coupon.BatchID,
+		"is_blocked":        coupon.IsBlocked,
+		"valid_from":        coupon.ValidFrom,
+		"expires_at":        coupon.ExpiresAt,
 		"created_at":        coupon.CreatedAt,
 	})
 }
@@ -1637,6 +1887,65 @@ func (handler *AdminHandler) BatchResetCoupons(c *fiber.Ctx) error {
 	return c.JSON(response)
 }
 
+// @Summary Extend coupons expiry (admin)
+// @Description Sets new expiry date or extends current expiry by number of days for multiple coupons
+// @Tags admin-coupons
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body coupon.ExtendExpiryRequest true "Coupon IDs and new expiry date or number of days"
+// @Success 200 {object} coupon.ExtendExpiryResponse "Extension result"
+// @Failure 400 {object} map[string]any "Invalid request"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/coupons/batch/extend-expiry [post]
+func (handler *AdminHandler) ExtendCouponsExpiry(c *fiber.Ctx) error {
+	var req coupon.ExtendExpiryRequest
+	if err := c.BodyParser(&req); err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Invalid request body")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request body",
+		})
+	}
+
+	if len(req.CouponIDs) == 0 {
+		handler.deps.Logger.FromContext(c).Error().Msg("Coupon ID required")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Coupon ID required"})
+	}
+
+	if len(req.CouponIDs) > 1000 {
+		handler.deps.Logger.FromContext(c).Error().Msg("Too many items")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Too many items"})
+	}
+
+	if (req.ExpiresAt == nil) == (req.ExtendDays == 0) {
+		handler.deps.Logger.FromContext(c).Error().Msg("Either expires_at or extend_days is required")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "Either expires_at or extend_days is required"})
+	}
+
+	if req.ExtendDays < 0 || (req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now())) {
+		handler.deps.Logger.FromContext(c).Error().Int("extend_days", req.ExtendDays).Msg("Invalid expiry")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{"error": "New expiry must be in the future"})
+	}
+
+	response, err := handler.deps.AdminService.ExtendCouponsExpiry(req)
+	if err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to extend coupons expiry")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to extend coupons expiry",
+		})
+	}
+
+	handler.deps.Logger.FromContext(c).Info().Interface("context", map[string]any{
+		"count":         len(req.CouponIDs),
+		"expires_at":    req.ExpiresAt,
+		"extend_days":   req.ExtendDays,
+		"success_count": response.SuccessCount,
+	}).Msg("Coupons expiry extended")
+	return c.JSON(response)
+}
+
 // @Summary Advanced export coupons (admin)
 // @Description Exports coupons in various formats (TXT, CSV, XLSX) with configurable options
 // @Tags admin-coupons
@@ -1669,6 +1978,10 @@ func (handler *AdminHandler) ExportCouponsAdvanced(c *fiber.Ctx) error {
 
 	content, filename, contentType, err := handler.deps.AdminService.ExportCouponsAdvanced(req)
 	if err != nil {
+		var problem *apperrors.ProblemDetail
+		if errors.As(err, &problem) {
+			return apperrors.SendError(c, problem)
+		}
 		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to export coupons")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to export coupons",
@@ -1883,6 +2196,8 @@ func (handler *AdminHandler) GetAllImages(c *fiber.Ctx) error {
 			"edited_image_s3_key":    t.EditedImageS3Key,
 			"preview_s3_key":         t.PreviewS3Key,
 			"processed_image_s3_key": t.ProcessedImageS3Key,
+			"optimized_image_s3_key": t.OptimizedImageS3Key,
+			"thumbnails":             t.Thumbnails,
 			"processing_params":      t.ProcessingParams,
 			"user_email":             t.UserEmail,
 			"status":                 t.Status,
@@ -2012,6 +2327,228 @@ func (handler *AdminHandler) RetryImageTask(c *fiber.Ctx) error {
 	return c.JSON(fiber.Map{"message": "Image task queued for retry"})
 }
 
+// @Summary List dead tasks
+// @Description Returns permanently failed tasks of queue with last error, attempt history and payload
+// @Tags admin-queues
+// @Produce json
+// @Security BearerAuth
+// @Param queue path string true "Queue name, e.g. images"
+// @Param type query string false "Task type"
+// @Param page query int false "Page number (default 1)"
+// @Param limit query int false "Number of items per page (default 20, max 100)"
+// @Success 200 {object} DeadTasksResponse "Dead tasks with pagination info"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 404 {object} map[string]any "Queue not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/queues/{queue}/dead [get]
+func (handler *AdminHandler) GetDeadTasks(c *fiber.Ctx) error {
+	queueName := c.Params("queue")
+	page := c.QueryInt("page", 1)
+	limit := c.QueryInt("limit", 20)
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	response, err := handler.deps.AdminService.ListDeadTasks(queueName, c.Query("type"), page, limit)
+	if err != nil {
+		return handler.deadTaskError(c, err, "Failed to get dead tasks")
+	}
+
+	return c.JSON(response)
+}
+
+// @Summary Get dead task
+// @Description Returns permanently failed task with last error, attempt history and payload
+// @Tags admin-queues
+// @Produce json
+// @Security BearerAuth
+// @Param queue path string true "Queue name, e.g. images"
+// @Param task_id path string true "Task ID"
+// @Success 200 {object} queue.DeadTask "Dead task"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 404 {object} map[string]any "Queue or task not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/queues/{queue}/dead/{task_id} [get]
+func (handler *AdminHandler) GetDeadTask(c *fiber.Ctx) error {
+	task, err := handler.deps.AdminService.GetDeadTask(c.Params("queue"), c.Params("task_id"))
+	if err != nil {
+		return handler.deadTaskError(c, err, "Failed to get dead task")
+	}
+
+	return c.JSON(task)
+}
+
+// @Summary Replay dead task
+// @Description Returns permanently failed task back to queue with fresh retry budget
+// @Tags admin-queues
+// @Produce json
+// @Security BearerAuth
+// @Param queue path string true "Queue name, e.g. images"
+// @Param task_id path string true "Task ID"
+// @Success 200 {object} map[string]any "Task replayed"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 404 {object} map[string]any "Queue or task not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/queues/{queue}/dead/{task_id}/replay [post]
+func (handler *AdminHandler) ReplayDeadTask(c *fiber.Ctx) error {
+	queueName := c.Params("queue")
+	taskID := c.Params("task_id")
+
+	replayed, err := handler.deps.AdminService.ReplayDeadTasks(queueName, DeadTasksActionRequest{TaskIDs: []string{taskID}})
+	if err != nil {
+		return handler.deadTaskError(c, err, "Failed to replay dead task")
+	}
+	if replayed == 0 {
+		return handler.deadTaskError(c, queue.ErrDeadTaskNotFound, "Failed to replay dead task")
+	}
+
+	handler.deps.Logger.FromContext(c).Info().Interface("context", map[string]any{"queue": queueName, "task_id": taskID}).Msg("Dead task replayed")
+	return c.JSON(fiber.Map{"message": "Dead task replayed", "replayed": replayed})
+}
+
+// @Summary Replay dead tasks
+// @Description Returns selected permanently failed tasks back to queue: by IDs, by task type or all of them
+// @Tags admin-queues
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param queue path string true "Queue name, e.g. images"
+// @Param request body DeadTasksActionRequest true "Selection of dead tasks"
+// @Success 200 {object} map[string]any "Number of replayed tasks"
+// @Failure 400 {object} map[string]any "Invalid request payload"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 404 {object} map[string]any "Queue not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/queues/{queue}/dead/replay [post]
+func (handler *AdminHandler) ReplayDeadTasks(c *fiber.Ctx) error {
+	queueName := c.Params("queue")
+
+	var payload DeadTasksActionRequest
+	if err := c.BodyParser(&payload); err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse request body",
+		})
+	}
+	if err := middleware.ValidateStruct(&payload); err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Invalid request payload")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request payload",
+		})
+	}
+
+	replayed, err := handler.deps.AdminService.ReplayDeadTasks(queueName, payload)
+	if err != nil {
+		return handler.deadTaskError(c, err, "Failed to replay dead tasks")
+	}
+
+	handler.deps.Logger.FromContext(c).Info().Interface("context", map[string]any{
+		"queue":    queueName,
+		"type":     payload.Type,
+		"replayed": replayed,
+	}).Msg("Dead tasks replayed")
+	return c.JSON(fiber.Map{"message": "Dead tasks replayed", "replayed": replayed})
+}
+
+// @Summary Delete dead task
+// @Description Permanently deletes failed task from dead-letter store
+// @Tags admin-queues
+// @Produce json
+// @Security BearerAuth
+// @Param queue path string true "Queue name, e.g. images"
+// @Param task_id path string true "Task ID"
+// @Success 200 {object} map[string]any "Task deleted"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 404 {object} map[string]any "Queue or task not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/queues/{queue}/dead/{task_id} [delete]
+func (handler *AdminHandler) DeleteDeadTask(c *fiber.Ctx) error {
+	queueName := c.Params("queue")
+	taskID := c.Params("task_id")
+
+	purged, err := handler.deps.AdminService.PurgeDeadTasks(queueName, DeadTasksActionRequest{TaskIDs: []string{taskID}})
+	if err != nil {
+		return handler.deadTaskError(c, err, "Failed to delete dead task")
+	}
+	if purged == 0 {
+		return handler.deadTaskError(c, queue.ErrDeadTaskNotFound, "Failed to delete dead task")
+	}
+
+	handler.deps.Logger.FromContext(c).Info().Interface("context", map[string]any{"queue": queueName, "task_id": taskID}).Msg("Dead task deleted")
+	return c.JSON(fiber.Map{"message": "Dead task deleted"})
+}
+
+// @Summary Purge dead tasks
+// @Description Permanently deletes selected failed tasks: by IDs, by task type or all of them
+// @Tags admin-queues
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param queue path string true "Queue name, e.g. images"
+// @Param request body DeadTasksActionRequest true "Selection of dead tasks"
+// @Success 200 {object} map[string]any "Number of deleted tasks"
+// @Failure 400 {object} map[string]any "Invalid request payload"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 403 {object} map[string]any "Forbidden"
+// @Failure 404 {object} map[string]any "Queue not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/queues/{queue}/dead/purge [post]
+func (handler *AdminHandler) PurgeDeadTasks(c *fiber.Ctx) error {
+	queueName := c.Params("queue")
+
+	var payload DeadTasksActionRequest
+	if err := c.BodyParser(&payload); err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to parse request body")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Failed to parse request body",
+		})
+	}
+	if err := middleware.ValidateStruct(&payload); err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Invalid request payload")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid request payload",
+		})
+	}
+
+	purged, err := handler.deps.AdminService.PurgeDeadTasks(queueName, payload)
+	if err != nil {
+		return handler.deadTaskError(c, err, "Failed to purge dead tasks")
+	}
+
+	handler.deps.Logger.FromContext(c).Info().Interface("context", map[string]any{
+		"queue":  queueName,
+		"type":   payload.Type,
+		"purged": purged,
+	}).Msg("Dead tasks purged")
+	return c.JSON(fiber.Map{"message": "Dead tasks purged", "purged": purged})
+}
+
+// deadTaskError maps dead-letter errors to response status
+func (handler *AdminHandler) deadTaskError(c *fiber.Ctx, err error, message string) error {
+	handler.deps.Logger.FromContext(c).Error().Err(err).Msg(message)
+
+	status := fiber.StatusInternalServerError
+	switch {
+	case errors.Is(err, queue.ErrQueueNotFound), errors.Is(err, queue.ErrDeadTaskNotFound):
+		status = fiber.StatusNotFound
+	case errors.Is(err, ErrEmptyDeadTasksSelection):
+		status = fiber.StatusBadRequest
+	}
+
+	return c.Status(status).JSON(fiber.Map{
+		"error": message,
+	})
+}
+
 // @Summary Force nginx update
 // @Description Forces generation and update of nginx configuration via CI/CD pipeline
 // @Tags admin-domains
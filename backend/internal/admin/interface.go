@@ -12,6 +12,7 @@ import (
 	"github.com/skr1ms/mosaic/internal/image"
 	"github.com/skr1ms/mosaic/internal/partner"
 	"github.com/skr1ms/mosaic/pkg/jwt"
+	"github.com/skr1ms/mosaic/pkg/queue"
 )
 
 type ConfigInterface interface {
@@ -78,6 +79,7 @@ type CouponRepositoryInterface interface {
 		partnerID *uuid.UUID,
 		page, limit int,
 		createdFrom, createdTo, usedFrom, usedTo *time.Time,
+		expired *bool,
 		sortBy, sortDir string,
 	) ([]*coupon.Coupon, int, error)
 	GetFiltered(ctx context.Context, filters map[string]any) ([]*coupon.Coupon, error)
@@ -118,6 +120,12 @@ type CouponRepositoryInterface interface {
 	ResetCoupon(ctx context.Context, id uuid.UUID) error
 	Reset(ctx context.Context, id uuid.UUID) error
 	BatchReset(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, []uuid.UUID, error)
+	FlagExpired(ctx context.Context, now time.Time) (int64, error)
+	ExtendExpiry(ctx context.Context, ids []uuid.UUID, expiresAt *time.Time, extendDays int) ([]uuid.UUID, error)
+	CreateWithBatch(ctx context.Context, batch *coupon.CouponBatch, coupons []*coupon.Coupon) error
+	GetBatches(ctx context.Context, partnerID *uuid.UUID, page, limit int) ([]*coupon.CouponBatchStats, int, error)
+	GetBatchByID(ctx context.Context, id uuid.UUID) (*coupon.CouponBatchStats, error)
+	SetBatchBlocked(ctx context.Context, id uuid.UUID, blocked bool) (int64, error)
 	GetPartnerCouponsWithFilter(ctx context.Context, partnerID uuid.UUID, filters map[string]any, page, limit int, sortBy, order string) ([]*coupon.Coupon, int, error)
 	GetPartnerCouponByCode(ctx context.Context, partnerID uuid.UUID, code string) (*coupon.Coupon, error)
 	GetPartnerCouponDetail(ctx context.Context, partnerID uuid.UUID, couponID uuid.UUID) (*coupon.Coupon, error)
@@ -149,6 +157,13 @@ type RedisClientInterface interface {
 	LLen(ctx context.Context, key string) *redis.IntCmd
 }
 
+type QueueManagerInterface interface {
+	ListDeadTasks(queueName, taskType string, offset, limit int) ([]*queue.DeadTask, int64, error)
+	GetDeadTask(queueName, taskID string) (*queue.DeadTask, error)
+	ReplayDeadTasks(queueName, taskType string, taskIDs []string) (int, error)
+	PurgeDeadTasks(queueName, taskType string, taskIDs []string) (int, error)
+}
+
 type AdminServiceInterface interface {
 	CreateAdmin(req CreateAdminRequest) (*Admin, error)
 	GetAdmins() ([]*Admin, error)
@@ -168,6 +183,16 @@ type AdminServiceInterface interface {
 	DeleteImageTask(imageID uuid.UUID) error
 	RetryImageTask(imageID uuid.UUID) error
 	BatchResetCoupons(couponIDs []string) (*coupon.BatchResetResponse, error)
+	CreateCoupons(req coupon.CreateCouponRequest, createdBy string) (*coupon.CouponBatch, []*coupon.Coupon, error)
+	ExtendCouponsExpiry(req coupon.ExtendExpiryRequest) (*coupon.ExtendExpiryResponse, error)
+	GetCouponBatches(partnerID *uuid.UUID, page, limit int) (*coupon.CouponBatchListResponse, error)
+	GetCouponBatch(id uuid.UUID) (*coupon.CouponBatchStats, error)
+	SetCouponBatchBlocked(id uuid.UUID, blocked bool) (int64, error)
+
+	ListDeadTasks(queueName, taskType string, page, limit int) (*DeadTasksResponse, error)
+	GetDeadTask(queueName, taskID string) (*queue.DeadTask, error)
+	ReplayDeadTasks(queueName string, req DeadTasksActionRequest) (int, error)
+	PurgeDeadTasks(queueName string, req DeadTasksActionRequest) (int, error)
 
 	ResetCoupon(id uuid.UUID) error
 	DeleteCoupon(id uuid.UUID) error
@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/pkg/queue"
 )
 
 type CreateAdminRequest struct {
@@ -82,3 +83,18 @@ type GenerateProductURLResponse struct {
 	Size        string `json:"size"`
 	Style       string `json:"style"`
 }
+
+// DeadTasksActionRequest selects dead tasks to replay or purge: explicit IDs, all tasks of type,
+// or all tasks of queue when All is set
+type DeadTasksActionRequest struct {
+	TaskIDs []string `json:"task_ids" validate:"omitempty,max=1000"`
+	Type    string   `json:"type"`
+	All     bool     `json:"all"`
+}
+
+type DeadTasksResponse struct {
+	Tasks []*queue.DeadTask `json:"tasks"`
+	Total int64             `json:"total"`
+	Page  int               `json:"page"`
+	Limit int               `json:"limit"`
+}
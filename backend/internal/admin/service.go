@@ -4,6 +4,7 @@ import (
 	"archive/zip"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -16,6 +17,7 @@ import (
 	"github.com/skr1ms/mosaic/internal/partner"
 	"github.com/skr1ms/mosaic/pkg/bcrypt"
 	"github.com/skr1ms/mosaic/pkg/gitlab"
+	"github.com/skr1ms/mosaic/pkg/queue"
 	"github.com/skr1ms/mosaic/pkg/randomCouponCode"
 	"github.com/skr1ms/mosaic/pkg/updatePartnerData"
 	validateData "github.com/skr1ms/mosaic/pkg/validateData"
@@ -29,6 +31,7 @@ type AdminServiceDeps struct {
 	S3Client          S3ClientInterface
 	RedisClient       RedisClientInterface
 	GitLabClient      *gitlab.Client
+	QueueManager      QueueManagerInterface
 }
 
 type AdminService struct {
@@ -359,6 +362,7 @@ func (s *AdminService) UpdatePartnerWithHistory(partnerID uuid.UUID, req partner
 		"address":          oldPartner.Address,
 		"logo_url":         oldPartner.LogoURL,
 		"allow_sales":      fmt.Sprintf("%t", oldPartner.AllowSales),
+		"queue_weight":     fmt.Sprintf("%d", oldPartner.QueueWeight),
 	}
 
 	updatePartnerData.UpdatePartnerData(oldPartner, &req)
@@ -381,6 +385,7 @@ func (s *AdminService) UpdatePartnerWithHistory(partnerID uuid.UUID, req partner
 		"address":          oldPartner.Address,
 		"logo_url":         oldPartner.LogoURL,
 		"allow_sales":      fmt.Sprintf("%t", oldPartner.AllowSales),
+		"queue_weight":     fmt.Sprintf("%d", oldPartner.QueueWeight),
 	}
 
 	for field, oldValue := range oldValues {
@@ -538,7 +543,7 @@ func (s *AdminService) GetCoupons(code, status, size, style string, partnerID *u
 
 // GetCouponsPaginated retrieves coupons with pagination and optional filtering
 func (s *AdminService) GetCouponsPaginated(code, status, size, style string, partnerID *uuid.UUID, page, limit int) ([]*coupon.Coupon, int64, error) {
-	coupons, total, err := s.deps.CouponRepository.SearchWithPagination(context.Background(), code, status, size, style, partnerID, page, limit, nil, nil, nil, nil, "", "")
+	coupons, total, err := s.deps.CouponRepository.SearchWithPagination(context.Background(), code, status, size, style, partnerID, page, limit, nil, nil, nil, nil, nil, "", "")
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to get coupons: %w", err)
 	}
@@ -546,20 +551,30 @@ func (s *AdminService) GetCouponsPaginated(code, status, size, style string, par
 }
 
 // CreateCoupons creates multiple coupons with unique codes for specified partner or own partner
-func (s *AdminService) CreateCoupons(req coupon.CreateCouponRequest) ([]*coupon.Coupon, error) {
+func (s *AdminService) CreateCoupons(req coupon.CreateCouponRequest, createdBy string) (*coupon.CouponBatch, []*coupon.Coupon, error) {
+	if !coupon.IsSizeAvailableForProductType(req.ProductType, req.Size) {
+		return nil, nil, fmt.Errorf("size %s is not available for product type %s", req.Size, req.ProductType)
+	}
+	if err := coupon.ValidatePanelLayout(req.Panels, req.PanelGapMM); err != nil {
+		return nil, nil, err
+	}
+	if err := coupon.ValidateValidityWindow(req.ValidFrom, req.ExpiresAt, time.Now()); err != nil {
+		return nil, nil, err
+	}
+
 	partnerCode := "0000"
 	effectivePartnerID := req.PartnerID
 
 	if req.PartnerID != uuid.Nil {
 		partner, err := s.deps.PartnerRepository.GetByID(context.Background(), req.PartnerID)
 		if err != nil {
-			return nil, fmt.Errorf("partner not found: %w", err)
+			return nil, nil, fmt.Errorf("partner not found: %w", err)
 		}
 		partnerCode = partner.PartnerCode
 	} else {
 		own, err := s.deps.PartnerRepository.GetByPartnerCode(context.Background(), "0000")
 		if err != nil {
-			return nil, fmt.Errorf("own partner (0000) not found: %w", err)
+			return nil, nil, fmt.Errorf("own partner (0000) not found: %w", err)
 		}
 		effectivePartnerID = own.ID
 	}
@@ -569,23 +584,41 @@ func (s *AdminService) CreateCoupons(req coupon.CreateCouponRequest) ([]*coupon.
 	for i := 0; i < req.Count; i++ {
 		code, err := randomCouponCode.GenerateUniqueCouponCode(partnerCode, s.deps.CouponRepository)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create coupons: %w", err)
+			return nil, nil, fmt.Errorf("failed to create coupons: %w", err)
 		}
 
 		coupons = append(coupons, &coupon.Coupon{
-			Code:      code,
-			PartnerID: effectivePartnerID,
-			Size:      string(req.Size),
-			Style:     string(req.Style),
-			Status:    string(coupon.StatusNew),
+			Code:        code,
+			PartnerID:   effectivePartnerID,
+			Size:        string(req.Size),
+			Style:       string(req.Style),
+			ProductType: string(req.ProductType),
+			Panels:      req.Panels,
+			PanelGapMM:  req.PanelGapMM,
+			Status:      string(coupon.StatusNew),
+			ValidFrom:   req.ValidFrom,
+			ExpiresAt:   req.ExpiresAt,
 		})
 	}
 
-	if err := s.deps.CouponRepository.CreateBatch(context.Background(), coupons); err != nil {
-		return nil, fmt.Errorf("failed to create coupons: %w", err)
+	batch := &coupon.CouponBatch{
+		PartnerID:   effectivePartnerID,
+		Size:        string(req.Size),
+		Style:       string(req.Style),
+		ProductType: string(req.ProductType),
+		Quantity:    req.Count,
+		CreatedBy:   createdBy,
+		Notes:       req.Notes,
+		PrintVendor: req.PrintVendor,
+		ValidFrom:   req.ValidFrom,
+		ExpiresAt:   req.ExpiresAt,
 	}
 
-	return coupons, nil
+	if err := s.deps.CouponRepository.CreateWithBatch(context.Background(), batch, coupons); err != nil {
+		return nil, nil, fmt.Errorf("failed to create coupons: %w", err)
+	}
+
+	return batch, coupons, nil
 }
 
 // ExportCoupons exports coupons data to CSV format with filtering options
@@ -884,6 +917,78 @@ func (s *AdminService) RetryImageTask(id uuid.UUID) error {
 	return nil
 }
 
+// ListDeadTasks returns page of permanently failed tasks of queue, optionally filtered by task type
+func (s *AdminService) ListDeadTasks(queueName, taskType string, page, limit int) (*DeadTasksResponse, error) {
+	if s.deps.QueueManager == nil {
+		return nil, fmt.Errorf("queue manager is not configured")
+	}
+
+	tasks, total, err := s.deps.QueueManager.ListDeadTasks(queueName, taskType, (page-1)*limit, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead tasks: %w", err)
+	}
+
+	return &DeadTasksResponse{
+		Tasks: tasks,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+	}, nil
+}
+
+// GetDeadTask returns permanently failed task with payload and attempt history
+func (s *AdminService) GetDeadTask(queueName, taskID string) (*queue.DeadTask, error) {
+	if s.deps.QueueManager == nil {
+		return nil, fmt.Errorf("queue manager is not configured")
+	}
+
+	task, err := s.deps.QueueManager.GetDeadTask(queueName, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get dead task: %w", err)
+	}
+	return task, nil
+}
+
+// ReplayDeadTasks returns selected dead tasks back to queue
+func (s *AdminService) ReplayDeadTasks(queueName string, req DeadTasksActionRequest) (int, error) {
+	if err := s.validateDeadTasksSelection(req); err != nil {
+		return 0, err
+	}
+
+	replayed, err := s.deps.QueueManager.ReplayDeadTasks(queueName, req.Type, req.TaskIDs)
+	if err != nil {
+		return replayed, fmt.Errorf("failed to replay dead tasks: %w", err)
+	}
+	return replayed, nil
+}
+
+// PurgeDeadTasks permanently deletes selected dead tasks
+func (s *AdminService) PurgeDeadTasks(queueName string, req DeadTasksActionRequest) (int, error) {
+	if err := s.validateDeadTasksSelection(req); err != nil {
+		return 0, err
+	}
+
+	purged, err := s.deps.QueueManager.PurgeDeadTasks(queueName, req.Type, req.TaskIDs)
+	if err != nil {
+		return purged, fmt.Errorf("failed to purge dead tasks: %w", err)
+	}
+	return purged, nil
+}
+
+// ErrEmptyDeadTasksSelection is returned when replay or purge request selects no dead tasks
+var ErrEmptyDeadTasksSelection = errors.New("task ids, type or all flag is required")
+
+// validateDeadTasksSelection protects from acting on whole queue by accident
+func (s *AdminService) validateDeadTasksSelection(req DeadTasksActionRequest) error {
+	if s.deps.QueueManager == nil {
+		return fmt.Errorf("queue manager is not configured")
+	}
+	if len(req.TaskIDs) == 0 && req.Type == "" && !req.All {
+		return ErrEmptyDeadTasksSelection
+	}
+	return nil
+}
+
 // BatchResetCoupons resets multiple coupons and cleans up associated S3 files
 func (s *AdminService) BatchResetCoupons(couponIDs []string) (*coupon.BatchResetResponse, error) {
 	if s.deps.S3Client != nil {
@@ -912,6 +1017,55 @@ func (s *AdminService) BatchResetCoupons(couponIDs []string) (*coupon.BatchReset
 	return response, nil
 }
 
+// ExtendCouponsExpiry sets or moves expiry of multiple coupons, expired flag is cleared for extended ones
+func (s *AdminService) ExtendCouponsExpiry(req coupon.ExtendExpiryRequest) (*coupon.ExtendExpiryResponse, error) {
+	couponService := coupon.NewCouponService(&coupon.CouponServiceDeps{
+		CouponRepository: s.deps.CouponRepository.(coupon.CouponRepositoryInterface),
+		RedisClient:      s.deps.RedisClient,
+		S3Client:         s.deps.S3Client,
+	})
+
+	response, err := couponService.ExtendCouponsExpiry(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extend coupons expiry: %w", err)
+	}
+
+	return response, nil
+}
+
+// GetCouponBatches returns page of coupon batches with activation rate of each
+func (s *AdminService) GetCouponBatches(partnerID *uuid.UUID, page, limit int) (*coupon.CouponBatchListResponse, error) {
+	couponService := coupon.NewCouponService(&coupon.CouponServiceDeps{
+		CouponRepository: s.deps.CouponRepository.(coupon.CouponRepositoryInterface),
+		RedisClient:      s.deps.RedisClient,
+		S3Client:         s.deps.S3Client,
+	})
+
+	return couponService.GetBatches(partnerID, page, limit)
+}
+
+// GetCouponBatch returns coupon batch with activation rate
+func (s *AdminService) GetCouponBatch(id uuid.UUID) (*coupon.CouponBatchStats, error) {
+	couponService := coupon.NewCouponService(&coupon.CouponServiceDeps{
+		CouponRepository: s.deps.CouponRepository.(coupon.CouponRepositoryInterface),
+		RedisClient:      s.deps.RedisClient,
+		S3Client:         s.deps.S3Client,
+	})
+
+	return couponService.GetBatch(id)
+}
+
+// SetCouponBatchBlocked blocks or unblocks all coupons of batch
+func (s *AdminService) SetCouponBatchBlocked(id uuid.UUID, blocked bool) (int64, error) {
+	couponService := coupon.NewCouponService(&coupon.CouponServiceDeps{
+		CouponRepository: s.deps.CouponRepository.(coupon.CouponRepositoryInterface),
+		RedisClient:      s.deps.RedisClient,
+		S3Client:         s.deps.S3Client,
+	})
+
+	return couponService.SetBatchBlocked(id, blocked)
+}
+
 // PreviewBatchDelete shows preview of coupons that will be deleted and cleans up S3 files
 func (s *AdminService) PreviewBatchDelete(couponIDs []string) (*coupon.BatchDeletePreviewResponse, error) {
 	if s.deps.S3Client != nil {
@@ -11,6 +11,7 @@ import (
 	"github.com/skr1ms/mosaic/internal/coupon"
 	"github.com/skr1ms/mosaic/internal/image"
 	"github.com/skr1ms/mosaic/internal/partner"
+	"github.com/skr1ms/mosaic/pkg/queue"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -298,8 +299,8 @@ func (m *MockCouponRepository) Search(ctx context.Context, code, status, size, s
 	return args.Get(0).([]*coupon.Coupon), args.Error(1)
 }
 
-func (m *MockCouponRepository) SearchWithPagination(ctx context.Context, code, status, size, style string, partnerID *uuid.UUID, page, limit int, createdFrom, createdTo, usedFrom, usedTo *time.Time, sortBy, sortDir string) ([]*coupon.Coupon, int, error) {
-	args := m.Called(ctx, code, status, size, style, partnerID, page, limit, createdFrom, createdTo, usedFrom, usedTo, sortBy, sortDir)
+func (m *MockCouponRepository) SearchWithPagination(ctx context.Context, code, status, size, style string, partnerID *uuid.UUID, page, limit int, createdFrom, createdTo, usedFrom, usedTo *time.Time, expired *bool, sortBy, sortDir string) ([]*coupon.Coupon, int, error) {
+	args := m.Called(ctx, code, status, size, style, partnerID, page, limit, createdFrom, createdTo, usedFrom, usedTo, expired, sortBy, sortDir)
 	if args.Get(0) == nil {
 		return nil, args.Int(1), args.Error(2)
 	}
@@ -560,6 +561,45 @@ func (m *MockCouponRepository) BatchReset(ctx context.Context, ids []uuid.UUID)
 	return args.Get(0).([]uuid.UUID), args.Get(1).([]uuid.UUID), args.Error(2)
 }
 
+func (m *MockCouponRepository) FlagExpired(ctx context.Context, now time.Time) (int64, error) {
+	args := m.Called(ctx, now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCouponRepository) ExtendExpiry(ctx context.Context, ids []uuid.UUID, expiresAt *time.Time, extendDays int) ([]uuid.UUID, error) {
+	args := m.Called(ctx, ids, expiresAt, extendDays)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockCouponRepository) CreateWithBatch(ctx context.Context, batch *coupon.CouponBatch, coupons []*coupon.Coupon) error {
+	args := m.Called(ctx, batch, coupons)
+	return args.Error(0)
+}
+
+func (m *MockCouponRepository) GetBatches(ctx context.Context, partnerID *uuid.UUID, page, limit int) ([]*coupon.CouponBatchStats, int, error) {
+	args := m.Called(ctx, partnerID, page, limit)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]*coupon.CouponBatchStats), args.Int(1), args.Error(2)
+}
+
+func (m *MockCouponRepository) GetBatchByID(ctx context.Context, id uuid.UUID) (*coupon.CouponBatchStats, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*coupon.CouponBatchStats), args.Error(1)
+}
+
+func (m *MockCouponRepository) SetBatchBlocked(ctx context.Context, id uuid.UUID, blocked bool) (int64, error) {
+	args := m.Called(ctx, id, blocked)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockCouponRepository) GetPartnerCouponsWithFilter(ctx context.Context, partnerID uuid.UUID, filters map[string]any, page, limit int, sortBy, order string) ([]*coupon.Coupon, int, error) {
 	args := m.Called(ctx, partnerID, filters, page, limit, sortBy, order)
 	if args.Get(0) == nil {
@@ -1335,6 +1375,49 @@ func TestAdminService_RetryImageTask(t *testing.T) {
 	}
 }
 
+func TestAdminService_CreateCoupons(t *testing.T) {
+	partnerID := uuid.New()
+	notes := "Spring promo"
+	vendor := "PrintHouse"
+
+	mockPartnerRepo := new(MockPartnerRepository)
+	mockCouponRepo := new(MockCouponRepository)
+
+	mockPartnerRepo.On("GetByID", mock.Anything, partnerID).Return(&partner.Partner{ID: partnerID, PartnerCode: "0042"}, nil)
+	mockCouponRepo.On("CodeExists", mock.Anything, mock.AnythingOfType("string")).Return(false, nil)
+	mockCouponRepo.On("CreateWithBatch", mock.Anything, mock.MatchedBy(func(batch *coupon.CouponBatch) bool {
+		return batch.PartnerID == partnerID && batch.Quantity == 3 && batch.CreatedBy == "admin" &&
+			batch.Notes == &notes && batch.PrintVendor == &vendor
+	}), mock.MatchedBy(func(coupons []*coupon.Coupon) bool {
+		return len(coupons) == 3
+	})).Return(nil)
+
+	service := NewAdminService(&AdminServiceDeps{
+		PartnerRepository: mockPartnerRepo,
+		CouponRepository:  mockCouponRepo,
+	})
+
+	batch, coupons, err := service.CreateCoupons(coupon.CreateCouponRequest{
+		Count:       3,
+		PartnerID:   partnerID,
+		Size:        coupon.Size40x50,
+		Style:       coupon.StyleGrayscale,
+		Notes:       &notes,
+		PrintVendor: &vendor,
+	}, "admin")
+
+	assert.NoError(t, err)
+	assert.NotNil(t, batch)
+	assert.Len(t, coupons, 3)
+	for _, c := range coupons {
+		assert.Equal(t, partnerID, c.PartnerID)
+		assert.Equal(t, "0042", c.Code[:4])
+	}
+
+	mockPartnerRepo.AssertExpectations(t)
+	mockCouponRepo.AssertExpectations(t)
+}
+
 func TestAdminService_BatchResetCoupons(t *testing.T) {
 	couponID1 := uuid.New()
 	couponID2 := uuid.New()
@@ -1391,3 +1474,105 @@ func TestAdminService_BatchResetCoupons(t *testing.T) {
 		})
 	}
 }
+
+type MockQueueManager struct {
+	mock.Mock
+}
+
+func (m *MockQueueManager) ListDeadTasks(queueName, taskType string, offset, limit int) ([]*queue.DeadTask, int64, error) {
+	args := m.Called(queueName, taskType, offset, limit)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*queue.DeadTask), args.Get(1).(int64), args.Error(2)
+}
+
+func (m *MockQueueManager) GetDeadTask(queueName, taskID string) (*queue.DeadTask, error) {
+	args := m.Called(queueName, taskID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*queue.DeadTask), args.Error(1)
+}
+
+func (m *MockQueueManager) ReplayDeadTasks(queueName, taskType string, taskIDs []string) (int, error) {
+	args := m.Called(queueName, taskType, taskIDs)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockQueueManager) PurgeDeadTasks(queueName, taskType string, taskIDs []string) (int, error) {
+	args := m.Called(queueName, taskType, taskIDs)
+	return args.Int(0), args.Error(1)
+}
+
+func TestAdminService_DeadTasks(t *testing.T) {
+	deadTask := &queue.DeadTask{
+		Task:      &queue.Task{ID: "task-1", Type: "generate_schema", Payload: map[string]any{"image_id": "img"}},
+		Queue:     "images",
+		LastError: "generation failed",
+		DiedAt:    time.Now(),
+	}
+
+	t.Run("list_with_pagination", func(t *testing.T) {
+		queueManager := new(MockQueueManager)
+		service := NewAdminService(&AdminServiceDeps{QueueManager: queueManager})
+		queueManager.On("ListDeadTasks", "images", "generate_schema", 20, 20).Return([]*queue.DeadTask{deadTask}, int64(21), nil)
+
+		response, err := service.ListDeadTasks("images", "generate_schema", 2, 20)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(21), response.Total)
+		assert.Equal(t, 2, response.Page)
+		assert.Len(t, response.Tasks, 1)
+		queueManager.AssertExpectations(t)
+	})
+
+	t.Run("get_not_found", func(t *testing.T) {
+		queueManager := new(MockQueueManager)
+		service := NewAdminService(&AdminServiceDeps{QueueManager: queueManager})
+		queueManager.On("GetDeadTask", "images", "missing").Return(nil, queue.ErrDeadTaskNotFound)
+
+		_, err := service.GetDeadTask("images", "missing")
+		assert.ErrorIs(t, err, queue.ErrDeadTaskNotFound)
+	})
+
+	t.Run("replay_by_ids", func(t *testing.T) {
+		queueManager := new(MockQueueManager)
+		service := NewAdminService(&AdminServiceDeps{QueueManager: queueManager})
+		queueManager.On("ReplayDeadTasks", "images", "", []string{"task-1", "task-2"}).Return(2, nil)
+
+		replayed, err := service.ReplayDeadTasks("images", DeadTasksActionRequest{TaskIDs: []string{"task-1", "task-2"}})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, replayed)
+		queueManager.AssertExpectations(t)
+	})
+
+	t.Run("purge_by_type", func(t *testing.T) {
+		queueManager := new(MockQueueManager)
+		service := NewAdminService(&AdminServiceDeps{QueueManager: queueManager})
+		queueManager.On("PurgeDeadTasks", "images", "send_schema", []string(nil)).Return(3, nil)
+
+		purged, err := service.PurgeDeadTasks("images", DeadTasksActionRequest{Type: "send_schema"})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, purged)
+	})
+
+	t.Run("empty_selection", func(t *testing.T) {
+		queueManager := new(MockQueueManager)
+		service := NewAdminService(&AdminServiceDeps{QueueManager: queueManager})
+
+		_, err := service.PurgeDeadTasks("images", DeadTasksActionRequest{})
+		assert.ErrorIs(t, err, ErrEmptyDeadTasksSelection)
+		_, err = service.ReplayDeadTasks("images", DeadTasksActionRequest{})
+		assert.ErrorIs(t, err, ErrEmptyDeadTasksSelection)
+		queueManager.AssertNotCalled(t, "PurgeDeadTasks")
+	})
+
+	t.Run("unknown_queue", func(t *testing.T) {
+		queueManager := new(MockQueueManager)
+		service := NewAdminService(&AdminServiceDeps{QueueManager: queueManager})
+		queueManager.On("ReplayDeadTasks", "videos", "", []string(nil)).Return(0, queue.ErrQueueNotFound)
+
+		_, err := service.ReplayDeadTasks("videos", DeadTasksActionRequest{All: true})
+		assert.ErrorIs(t, err, queue.ErrQueueNotFound)
+	})
+}
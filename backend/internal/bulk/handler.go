@@ -0,0 +1,268 @@
+package bulk
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/pkg/jwt"
+	"github.com/skr1ms/mosaic/pkg/middleware"
+)
+
+type BulkHandlerDeps struct {
+	BulkService   BulkServiceInterface
+	JwtService    JWTServiceInterface
+	Logger        *middleware.Logger
+	MaxUploadSize int // Body limit of job creation, archive is streamed to disk so it may exceed server body limit
+}
+
+type BulkHandler struct {
+	fiber.Router
+	deps *BulkHandlerDeps
+}
+
+func NewBulkHandler(router fiber.Router, deps *BulkHandlerDeps) {
+	handler := &BulkHandler{
+		Router: router,
+		deps:   deps,
+	}
+
+	jwtConcrete, ok := deps.JwtService.(*jwt.JWT)
+	if !ok {
+		panic("JwtService must be *jwt.JWT for middleware")
+	}
+
+	// ================================================================
+	// ADMIN BULK JOB ROUTES: /api/admin/bulk-jobs/*
+	// Access: admin and main_admin roles only, coupons of any partner
+	// ================================================================
+	adminJobs := router.Group("/admin/bulk-jobs")
+	adminJobs.Use(middleware.JWTMiddleware(jwtConcrete, deps.Logger), middleware.AdminOrMainAdmin())
+
+	adminJobs.Post("/", middleware.BodyLimit(deps.MaxUploadSize, nil), handler.CreateJob) // POST /api/admin/bulk-jobs
+	adminJobs.Get("/", handler.GetJobs)                                                   // GET /api/admin/bulk-jobs
+	adminJobs.Get("/:id", handler.GetJob)                                                 // GET /api/admin/bulk-jobs/:id
+	adminJobs.Get("/:id/result", handler.GetResult)                                       // GET /api/admin/bulk-jobs/:id/result
+
+	// ================================================================
+	// PARTNER BULK JOB ROUTES: /api/partner/bulk-jobs/*
+	// Access: authenticated partners only, own coupons and jobs
+	// ================================================================
+	partnerJobs := router.Group("/partner/bulk-jobs")
+	partnerJobs.Use(middleware.JWTMiddleware(jwtConcrete, deps.Logger), middleware.PartnerOnly())
+
+	partnerJobs.Post("/", middleware.BodyLimit(deps.MaxUploadSize, nil), handler.CreateJob) // POST /api/partner/bulk-jobs
+	partnerJobs.Get("/", handler.GetJobs)                                                   // GET /api/partner/bulk-jobs
+	partnerJobs.Get("/:id", handler.GetJob)                                                 // GET /api/partner/bulk-jobs/:id
+	partnerJobs.Get("/:id/result", handler.GetResult)                                       // GET /api/partner/bulk-jobs/:id/result
+}
+
+// IsUploadRequest reports whether request creates bulk job, such requests carry archive larger than
+// server body limit and are limited by MaxUploadSize on their routes
+func IsUploadRequest(c *fiber.Ctx) bool {
+	if c.Method() != fiber.MethodPost {
+		return false
+	}
+	path := strings.TrimSuffix(c.Path(), "/")
+	return path == "/api/admin/bulk-jobs" || path == "/api/partner/bulk-jobs"
+}
+
+// scope returns partner whose coupons and jobs are available to current user, nil for admins
+func scope(claims *jwt.Claims) *uuid.UUID {
+	if claims.Role == "partner" {
+		partnerID := claims.UserID
+		return &partnerID
+	}
+	return nil
+}
+
+func (h *BulkHandler) errorResponse(c *fiber.Ctx, status int, message string, err error) error {
+	response := fiber.Map{
+		"error":      message,
+		"request_id": c.Get("X-Request-ID"),
+	}
+	if err != nil && (os.Getenv("ENVIRONMENT") == "development" || os.Getenv("ENVIRONMENT") == "dev") {
+		response["details"] = err.Error()
+	}
+	return c.Status(status).JSON(response)
+}
+
+// @Summary Create bulk generation job
+// @Description Uploads ZIP archive of photos and CSV file mapping photos to coupon codes. Mapping file needs header with file and coupon_code columns and optional style column. Valid rows get their coupons activated and schemas generated in background, invalid rows are reported in job items.
+// @Tags bulk-jobs
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param archive formData file true "ZIP archive of JPG or PNG photos"
+// @Param mapping formData file true "CSV file mapping photos to coupon codes"
+// @Success 201 {object} BulkJobResponse "Job created"
+// @Failure 400 {object} map[string]any "Invalid archive or mapping file"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/bulk-jobs [post]
+// @Router /partner/bulk-jobs [post]
+func (h *BulkHandler) CreateJob(c *fiber.Ctx) error {
+	claims, err := jwt.GetClaimsFromFiberContext(c)
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusUnauthorized, "Failed to get JWT claims", err)
+	}
+
+	archive, err := c.FormFile("archive")
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "ZIP archive of photos is required", err)
+	}
+	mapping, err := c.FormFile("mapping")
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "CSV mapping file is required", err)
+	}
+
+	job, err := h.deps.BulkService.CreateJob(c.UserContext(), &CreateJobRequest{
+		Archive:   archive,
+		Mapping:   mapping,
+		PartnerID: scope(claims),
+		CreatedBy: claims.Login,
+	})
+	if err != nil {
+		if errors.Is(err, ErrInvalidUpload) {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":      err.Error(),
+				"request_id": c.Get("X-Request-ID"),
+			})
+		}
+		h.deps.Logger.FromContext(c).Error().
+			Err(err).
+			Str("handler", "CreateJob").
+			Str("created_by", claims.Login).
+			Msg("Failed to create bulk job")
+		return h.errorResponse(c, fiber.StatusInternalServerError, "Failed to create bulk job", err)
+	}
+
+	h.deps.Logger.FromContext(c).Info().
+		Str("handler", "CreateJob").
+		Str("job_id", job.ID.String()).
+		Str("created_by", claims.Login).
+		Int("total", job.Progress.Total).
+		Int("rejected", job.Progress.Rejected).
+		Msg("Bulk job created")
+
+	return c.Status(fiber.StatusCreated).JSON(job)
+}
+
+// @Summary List bulk generation jobs
+// @Description Returns bulk jobs with their progress, newest first. Partners see only their own jobs.
+// @Tags bulk-jobs
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]any "Jobs"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/bulk-jobs [get]
+// @Router /partner/bulk-jobs [get]
+func (h *BulkHandler) GetJobs(c *fiber.Ctx) error {
+	claims, err := jwt.GetClaimsFromFiberContext(c)
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusUnauthorized, "Failed to get JWT claims", err)
+	}
+
+	jobs, err := h.deps.BulkService.GetJobs(c.UserContext(), scope(claims))
+	if err != nil {
+		h.deps.Logger.FromContext(c).Error().
+			Err(err).
+			Str("handler", "GetJobs").
+			Msg("Failed to get bulk jobs")
+		return h.errorResponse(c, fiber.StatusInternalServerError, "Failed to get bulk jobs", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"jobs":  jobs,
+		"total": len(jobs),
+	})
+}
+
+// @Summary Get bulk generation job
+// @Description Returns job progress and status of every row of mapping file
+// @Tags bulk-jobs
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Success 200 {object} BulkJobResponse "Job"
+// @Failure 400 {object} map[string]any "Invalid job ID"
+// @Failure 404 {object} map[string]any "Job not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/bulk-jobs/{id} [get]
+// @Router /partner/bulk-jobs/{id} [get]
+func (h *BulkHandler) GetJob(c *fiber.Ctx) error {
+	claims, err := jwt.GetClaimsFromFiberContext(c)
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusUnauthorized, "Failed to get JWT claims", err)
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid job ID", err)
+	}
+
+	job, err := h.deps.BulkService.GetJob(c.UserContext(), id, scope(claims))
+	if err != nil {
+		if errors.Is(err, ErrJobNotFound) {
+			return h.errorResponse(c, fiber.StatusNotFound, "Bulk job not found", nil)
+		}
+		h.deps.Logger.FromContext(c).Error().
+			Err(err).
+			Str("handler", "GetJob").
+			Str("job_id", id.String()).
+			Msg("Failed to get bulk job")
+		return h.errorResponse(c, fiber.StatusInternalServerError, "Failed to get bulk job", err)
+	}
+
+	return c.JSON(job)
+}
+
+// @Summary Get bulk generation result
+// @Description Returns link to ZIP archive with schema archive of every completed coupon and report of all rows. Available when all rows are finished.
+// @Tags bulk-jobs
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Success 200 {object} map[string]any "Result archive URL"
+// @Failure 400 {object} map[string]any "Invalid job ID"
+// @Failure 404 {object} map[string]any "Job not found"
+// @Failure 409 {object} map[string]any "Job is not finished yet"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/bulk-jobs/{id}/result [get]
+// @Router /partner/bulk-jobs/{id}/result [get]
+func (h *BulkHandler) GetResult(c *fiber.Ctx) error {
+	claims, err := jwt.GetClaimsFromFiberContext(c)
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusUnauthorized, "Failed to get JWT claims", err)
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid job ID", err)
+	}
+
+	url, err := h.deps.BulkService.GetResultURL(c.UserContext(), id, scope(claims))
+	if err != nil {
+		switch {
+		case errors.Is(err, ErrJobNotFound):
+			return h.errorResponse(c, fiber.StatusNotFound, "Bulk job not found", nil)
+		case errors.Is(err, ErrJobNotFinished):
+			return h.errorResponse(c, fiber.StatusConflict, "Bulk job is not finished yet", nil)
+		}
+		h.deps.Logger.FromContext(c).Error().
+			Err(err).
+			Str("handler", "GetResult").
+			Str("job_id", id.String()).
+			Msg("Failed to get bulk job result")
+		return h.errorResponse(c, fiber.StatusInternalServerError, "Failed to get bulk job result", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"job_id":     id,
+		"url":        url,
+		"expires_in": int(resultURLExpiry.Seconds()),
+	})
+}
@@ -0,0 +1,55 @@
+package bulk
+
+import (
+	"context"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/internal/coupon"
+	"github.com/skr1ms/mosaic/internal/image"
+	"github.com/skr1ms/mosaic/pkg/jwt"
+)
+
+type BulkRepositoryInterface interface {
+	CreateJob(ctx context.Context, job *BulkJob, items []*BulkJobItem) error
+	GetJobByID(ctx context.Context, id uuid.UUID) (*BulkJob, error)
+	GetJobs(ctx context.Context, partnerID *uuid.UUID) ([]*BulkJob, error)
+	GetItemsWithImages(ctx context.Context, jobID uuid.UUID) ([]*BulkJobItemWithImage, error)
+	UpdateItem(ctx context.Context, item *BulkJobItem) error
+	UpdateResult(ctx context.Context, job *BulkJob) error
+}
+
+type CouponRepositoryInterface interface {
+	GetByCode(ctx context.Context, code string) (*coupon.Coupon, error)
+	Update(ctx context.Context, coupon *coupon.Coupon) error
+}
+
+type ImageRepositoryInterface interface {
+	GetByCouponID(ctx context.Context, couponID uuid.UUID) (*image.Image, error)
+}
+
+type ImageServiceInterface interface {
+	ImportImage(ctx context.Context, couponID uuid.UUID, fileName string, data []byte, userEmail string) (*image.Image, error)
+}
+
+type ImageTaskQueueInterface interface {
+	EnqueueBulkGeneration(imageID uuid.UUID, style string) (string, error)
+}
+
+type S3ClientInterface interface {
+	UploadFileWithKey(ctx context.Context, reader io.Reader, size int64, contentType string, objectKey string) (string, error)
+	DownloadFile(ctx context.Context, objectKey string) (io.ReadCloser, error)
+	GetFileURL(ctx context.Context, objectKey string, expiry time.Duration) (string, error)
+}
+
+type BulkServiceInterface interface {
+	CreateJob(ctx context.Context, req *CreateJobRequest) (*BulkJobResponse, error)
+	GetJob(ctx context.Context, id uuid.UUID, partnerID *uuid.UUID) (*BulkJobResponse, error)
+	GetJobs(ctx context.Context, partnerID *uuid.UUID) ([]*BulkJobResponse, error)
+	GetResultURL(ctx context.Context, id uuid.UUID, partnerID *uuid.UUID) (string, error)
+}
+
+type JWTServiceInterface interface {
+	ValidateAccessToken(tokenString string) (*jwt.Claims, error)
+}
@@ -0,0 +1,115 @@
+package bulk
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Item states reported in job progress, images of accepted items report their own processing status
+const (
+	ItemStatusRejected   = "rejected"
+	ItemStatusQueued     = "queued"
+	ItemStatusProcessing = "processing"
+	ItemStatusCompleted  = "completed"
+	ItemStatusFailed     = "failed"
+	ItemStatusCancelled  = "cancelled"
+)
+
+// BulkJob is generation of schemas for many coupons at once from uploaded archive of photos
+type BulkJob struct {
+	bun.BaseModel `bun:"table:bulk_jobs,alias:bj"`
+
+	ID            uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	PartnerID     *uuid.UUID `bun:"partner_id,type:uuid" json:"partner_id"` // Nil for jobs created by admin
+	CreatedBy     string     `bun:"created_by,notnull" json:"created_by"`
+	ArchiveName   string     `bun:"archive_name,notnull" json:"archive_name"`
+	TotalItems    int        `bun:"total_items,notnull,default:0" json:"total_items"`
+	ResultS3Key   *string    `bun:"result_s3_key" json:"-"` // Combined archive of schemas, built on first download
+	CreatedAt     time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	ResultBuiltAt *time.Time `bun:"result_built_at" json:"result_built_at"`
+}
+
+func (j *BulkJob) CreateIndex() string {
+	return `
+	CREATE INDEX IF NOT EXISTS idx_bulk_jobs_partner_id ON bulk_jobs(partner_id);
+	CREATE INDEX IF NOT EXISTS idx_bulk_jobs_created_at ON bulk_jobs(created_at);
+	CREATE INDEX IF NOT EXISTS idx_bulk_job_items_job_id ON bulk_job_items(job_id, line);
+	CREATE INDEX IF NOT EXISTS idx_bulk_job_items_image_id ON bulk_job_items(image_id);
+	`
+}
+
+// BulkJobItem is single row of mapping file
+type BulkJobItem struct {
+	bun.BaseModel `bun:"table:bulk_job_items,alias:bji"`
+
+	ID         uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	JobID      uuid.UUID  `bun:"job_id,type:uuid,notnull" json:"job_id"`
+	Line       int        `bun:"line,notnull" json:"line"` // Line number in mapping file
+	FileName   string     `bun:"file_name,notnull" json:"file_name"`
+	CouponCode string     `bun:"coupon_code,notnull" json:"coupon_code"`
+	Style      string     `bun:"style,nullzero" json:"style,omitempty"`
+	CouponID   *uuid.UUID `bun:"coupon_id,type:uuid" json:"coupon_id"`
+	ImageID    *uuid.UUID `bun:"image_id,type:uuid" json:"image_id"`
+	Error      *string    `bun:"error" json:"error,omitempty"` // Reason row was rejected or could not be queued
+	CreatedAt  time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// BulkJobItemWithImage is item with current state of its image
+type BulkJobItemWithImage struct {
+	*BulkJobItem
+	ImageStatus *string `bun:"image_status" json:"-"`
+	SchemaS3Key *string `bun:"schema_s3_key" json:"-"`
+}
+
+// Status maps image status to item status
+func (i *BulkJobItemWithImage) Status() string {
+	if i.Error != nil {
+		if i.ImageID == nil {
+			return ItemStatusRejected
+		}
+		return ItemStatusFailed
+	}
+	if i.ImageStatus == nil {
+		return ItemStatusFailed
+	}
+	switch *i.ImageStatus {
+	case "completed":
+		return ItemStatusCompleted
+	case "failed":
+		return ItemStatusFailed
+	case "cancelled":
+		return ItemStatusCancelled
+	case "processing", "processed":
+		return ItemStatusProcessing
+	default:
+		return ItemStatusQueued
+	}
+}
+
+// BulkJobProgress is number of job items in each state
+type BulkJobProgress struct {
+	Total      int  `json:"total"`
+	Rejected   int  `json:"rejected"`
+	Queued     int  `json:"queued"`
+	Processing int  `json:"processing"`
+	Completed  int  `json:"completed"`
+	Failed     int  `json:"failed"`
+	Cancelled  int  `json:"cancelled"`
+	Percent    int  `json:"percent"`  // Share of accepted items that reached final state
+	Finished   bool `json:"finished"` // No accepted item is queued or processing
+}
+
+// BulkJobItemResponse is item of job with its status
+type BulkJobItemResponse struct {
+	*BulkJobItem
+	Status string `json:"status"`
+}
+
+// BulkJobResponse is job with its progress and items
+type BulkJobResponse struct {
+	*BulkJob
+	Progress BulkJobProgress        `json:"progress"`
+	Items    []*BulkJobItemResponse `json:"items,omitempty"`
+}
@@ -0,0 +1,15 @@
+package bulk
+
+import (
+	"mime/multipart"
+
+	"github.com/google/uuid"
+)
+
+// CreateJobRequest is upload of bulk job, PartnerID restricts coupons to single partner
+type CreateJobRequest struct {
+	Archive   *multipart.FileHeader
+	Mapping   *multipart.FileHeader
+	PartnerID *uuid.UUID
+	CreatedBy string
+}
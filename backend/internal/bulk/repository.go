@@ -0,0 +1,105 @@
+package bulk
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type BulkRepository struct {
+	db *bun.DB
+}
+
+func NewBulkRepository(db *bun.DB) *BulkRepository {
+	return &BulkRepository{db: db}
+}
+
+// CreateJob saves job together with all its items
+func (r *BulkRepository) CreateJob(ctx context.Context, job *BulkJob, items []*BulkJobItem) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(job).Returning("*").Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create bulk job: %w", err)
+		}
+		if len(items) == 0 {
+			return nil
+		}
+		for _, item := range items {
+			item.JobID = job.ID
+		}
+		if _, err := tx.NewInsert().Model(&items).Returning("id").Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create bulk job items: %w", err)
+		}
+		return nil
+	})
+}
+
+// UpdateItem saves image created for item or reason it could not be imported
+func (r *BulkRepository) UpdateItem(ctx context.Context, item *BulkJobItem) error {
+	_, err := r.db.NewUpdate().
+		Model(item).
+		Column("image_id", "error").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update bulk job item: %w", err)
+	}
+	return nil
+}
+
+func (r *BulkRepository) GetJobByID(ctx context.Context, id uuid.UUID) (*BulkJob, error) {
+	job := new(BulkJob)
+	err := r.db.NewSelect().Model(job).Where("bj.id = ?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrJobNotFound
+		}
+		return nil, fmt.Errorf("failed to find bulk job: %w", err)
+	}
+	return job, nil
+}
+
+// GetJobs returns jobs newest first, jobs of all partners and admins when partnerID is nil
+func (r *BulkRepository) GetJobs(ctx context.Context, partnerID *uuid.UUID) ([]*BulkJob, error) {
+	var jobs []*BulkJob
+	query := r.db.NewSelect().Model(&jobs).Order("bj.created_at DESC")
+	if partnerID != nil {
+		query = query.Where("bj.partner_id = ?", *partnerID)
+	}
+	if err := query.Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to find bulk jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+// GetItemsWithImages returns items of job in mapping file order with current state of their images
+func (r *BulkRepository) GetItemsWithImages(ctx context.Context, jobID uuid.UUID) ([]*BulkJobItemWithImage, error) {
+	var items []*BulkJobItemWithImage
+	err := r.db.NewSelect().
+		Model((*BulkJobItem)(nil)).
+		ColumnExpr("bji.*, i.status AS image_status, i.schema_s3_key AS schema_s3_key").
+		Join("LEFT JOIN images AS i ON i.id = bji.image_id").
+		Where("bji.job_id = ?", jobID).
+		Order("bji.line ASC").
+		Scan(ctx, &items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find bulk job items: %w", err)
+	}
+	return items, nil
+}
+
+// UpdateResult stores key of combined archive of job schemas
+func (r *BulkRepository) UpdateResult(ctx context.Context, job *BulkJob) error {
+	_, err := r.db.NewUpdate().
+		Model(job).
+		Column("result_s3_key", "result_built_at").
+		WherePK().
+		Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update bulk job result: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,607 @@
+package bulk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/skr1ms/mosaic/internal/coupon"
+)
+
+var (
+	// ErrJobNotFound is returned when job does not exist or belongs to another partner
+	ErrJobNotFound = errors.New("bulk job not found")
+	// ErrJobNotFinished is returned when result is requested while items are still generated
+	ErrJobNotFinished = errors.New("bulk job is not finished")
+	// ErrInvalidUpload is returned when archive or mapping file cannot be read or has no valid rows
+	ErrInvalidUpload = errors.New("invalid bulk upload")
+)
+
+const (
+	maxItems        = 1000
+	maxPhotoSize    = 15 << 20
+	resultURLExpiry = 24 * time.Hour
+)
+
+var validStyles = map[string]bool{
+	"grayscale":  true,
+	"skin_tones": true,
+	"pop_art":    true,
+	"max_colors": true,
+}
+
+// Column names accepted in header of mapping file
+var (
+	fileColumns   = []string{"file", "file_name", "filename", "photo"}
+	couponColumns = []string{"coupon_code", "coupon", "code"}
+	styleColumns  = []string{"style"}
+)
+
+type BulkServiceDeps struct {
+	BulkRepository   BulkRepositoryInterface
+	CouponRepository CouponRepositoryInterface
+	ImageRepository  ImageRepositoryInterface
+	ImageService     ImageServiceInterface
+	ImageTaskQueue   ImageTaskQueueInterface
+	S3Client         S3ClientInterface
+}
+
+type BulkService struct {
+	deps *BulkServiceDeps
+}
+
+func NewBulkService(deps *BulkServiceDeps) *BulkService {
+	return &BulkService{
+		deps: deps,
+	}
+}
+
+// mappingRow is row of mapping file binding photo in archive to coupon
+type mappingRow struct {
+	line       int
+	fileName   string
+	couponCode string
+	style      string
+}
+
+// acceptedRow is validated row ready to be imported
+type acceptedRow struct {
+	item   *BulkJobItem
+	file   *zip.File
+	coupon *coupon.Coupon
+}
+
+// CreateJob validates mapping file against archive and coupons, saves job with all its rows, then creates
+// images of valid rows and queues their generation. Rows that fail validation are saved with reason and
+// do not stop the job, job is rejected only when none of rows is valid.
+func (s *BulkService) CreateJob(ctx context.Context, req *CreateJobRequest) (*BulkJobResponse, error) {
+	rows, err := readMapping(req.Mapping)
+	if err != nil {
+		return nil, err
+	}
+
+	archiveFile, err := req.Archive.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer archiveFile.Close()
+
+	archive, err := zip.NewReader(archiveFile, req.Archive.Size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: archive is not a valid ZIP file", ErrInvalidUpload)
+	}
+	files := indexArchive(archive)
+
+	items := make([]*BulkJobItem, 0, len(rows))
+	accepted := make([]*acceptedRow, 0, len(rows))
+	seenCodes := make(map[string]int, len(rows))
+	var firstErr error
+	for _, row := range rows {
+		item := &BulkJobItem{
+			Line:       row.line,
+			FileName:   row.fileName,
+			CouponCode: row.couponCode,
+			Style:      row.style,
+		}
+		items = append(items, item)
+
+		file, c, err := s.validateRow(ctx, row, files, seenCodes, req.PartnerID)
+		if err != nil {
+			reject(item, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("line %d: %w", row.line, err)
+			}
+			continue
+		}
+		item.CouponID = &c.ID
+		accepted = append(accepted, &acceptedRow{item: item, file: file, coupon: c})
+	}
+
+	if len(accepted) == 0 {
+		return nil, fmt.Errorf("%w: no valid rows in mapping file, %v", ErrInvalidUpload, firstErr)
+	}
+
+	// Job is saved before coupons are activated, so every imported row can be tracked in job
+	job := &BulkJob{
+		PartnerID:   req.PartnerID,
+		CreatedBy:   req.CreatedBy,
+		ArchiveName: req.Archive.Filename,
+		TotalItems:  len(items),
+	}
+	if err := s.deps.BulkRepository.CreateJob(ctx, job, items); err != nil {
+		return nil, err
+	}
+
+	// Import goes on when client disconnects, so rows are not left half imported
+	importCtx := context.WithoutCancel(ctx)
+	for _, row := range accepted {
+		if err := s.importRow(importCtx, row); err != nil {
+			log.Error().Err(err).Int("line", row.item.Line).Str("coupon_code", row.item.CouponCode).Msg("Failed to import bulk job row")
+			reject(row.item, err)
+		}
+		if err := s.deps.BulkRepository.UpdateItem(importCtx, row.item); err != nil {
+			log.Error().Err(err).Int("line", row.item.Line).Str("coupon_code", row.item.CouponCode).Msg("Failed to save bulk job row")
+		}
+	}
+
+	log.Info().
+		Str("job_id", job.ID.String()).
+		Str("created_by", job.CreatedBy).
+		Int("total", len(items)).
+		Int("accepted", len(accepted)).
+		Msg("Bulk job created")
+
+	itemsWithImages := make([]*BulkJobItemWithImage, 0, len(items))
+	for _, item := range items {
+		withImage := &BulkJobItemWithImage{BulkJobItem: item}
+		if item.ImageID != nil {
+			status := "queued"
+			withImage.ImageStatus = &status
+		}
+		itemsWithImages = append(itemsWithImages, withImage)
+	}
+
+	return buildResponse(job, itemsWithImages, true), nil
+}
+
+// validateRow checks that photo is in archive and coupon can be used for generation
+func (s *BulkService) validateRow(ctx context.Context, row mappingRow, files map[string]*zip.File, seenCodes map[string]int, partnerID *uuid.UUID) (*zip.File, *coupon.Coupon, error) {
+	if row.fileName == "" {
+		return nil, nil, fmt.Errorf("file name is empty")
+	}
+	if row.couponCode == "" {
+		return nil, nil, fmt.Errorf("coupon code is empty")
+	}
+	if row.style != "" && !validStyles[row.style] {
+		return nil, nil, fmt.Errorf("unknown style %q, supported: grayscale, skin_tones, pop_art, max_colors", row.style)
+	}
+
+	if line, ok := seenCodes[row.couponCode]; ok {
+		return nil, nil, fmt.Errorf("coupon %s is already used on line %d", row.couponCode, line)
+	}
+	seenCodes[row.couponCode] = row.line
+
+	file, ok := files[strings.ToLower(path.Base(row.fileName))]
+	if !ok {
+		return nil, nil, fmt.Errorf("file %s not found in archive", row.fileName)
+	}
+	if file == nil {
+		return nil, nil, fmt.Errorf("several files named %s in archive", row.fileName)
+	}
+	if file.UncompressedSize64 > maxPhotoSize {
+		return nil, nil, fmt.Errorf("file %s is too large, maximum size is 15MB", row.fileName)
+	}
+
+	c, err := s.deps.CouponRepository.GetByCode(ctx, row.couponCode)
+	if err != nil {
+		return nil, nil, fmt.Errorf("coupon %s not found", row.couponCode)
+	}
+	if partnerID != nil && c.PartnerID != *partnerID {
+		return nil, nil, fmt.Errorf("coupon %s not found", row.couponCode)
+	}
+	if c.IsBlocked {
+		return nil, nil, fmt.Errorf("coupon %s is blocked", row.couponCode)
+	}
+	if c.Status == "used" || c.Status == "completed" {
+		return nil, nil, fmt.Errorf("coupon %s is already used", row.couponCode)
+	}
+	// Bulk job activates coupon, so it gets the same validity window as activation by customer
+	if err := c.CheckValidity(time.Now()); err != nil {
+		return nil, nil, fmt.Errorf("coupon %s can not be used: %w", row.couponCode, err)
+	}
+	// Photo customer already uploaded for activated coupon is not replaced
+	if c.Status == "activated" {
+		existing, err := s.deps.ImageRepository.GetByCouponID(ctx, c.ID)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return nil, nil, fmt.Errorf("failed to check photo of coupon %s", row.couponCode)
+		}
+		if existing != nil {
+			return nil, nil, fmt.Errorf("coupon %s already has uploaded photo", row.couponCode)
+		}
+	}
+
+	return file, c, nil
+}
+
+// importRow activates coupon, creates its image from archive and queues generation
+func (s *BulkService) importRow(ctx context.Context, row *acceptedRow) error {
+	data, err := readArchiveFile(row.file)
+	if err != nil {
+		return err
+	}
+
+	c := row.coupon
+	if c.Status == "new" || row.item.Style != "" {
+		if c.Status == "new" {
+			now := time.Now()
+			c.Status = "activated"
+			c.ActivatedAt = &now
+		}
+		if row.item.Style != "" {
+			c.Style = row.item.Style
+		}
+		if err := s.deps.CouponRepository.Update(ctx, c); err != nil {
+			return fmt.Errorf("failed to activate coupon: %w", err)
+		}
+	}
+
+	userEmail := ""
+	if c.UserEmail != nil {
+		userEmail = *c.UserEmail
+	}
+	img, err := s.deps.ImageService.ImportImage(ctx, c.ID, row.item.FileName, data, userEmail)
+	if err != nil {
+		return err
+	}
+	row.item.ImageID = &img.ID
+
+	if _, err := s.deps.ImageTaskQueue.EnqueueBulkGeneration(img.ID, c.Style); err != nil {
+		return fmt.Errorf("failed to queue generation: %w", err)
+	}
+	return nil
+}
+
+// GetJob returns job with progress and items, partnerID restricts access to jobs of partner
+func (s *BulkService) GetJob(ctx context.Context, id uuid.UUID, partnerID *uuid.UUID) (*BulkJobResponse, error) {
+	job, items, err := s.getJobWithItems(ctx, id, partnerID)
+	if err != nil {
+		return nil, err
+	}
+	return buildResponse(job, items, true), nil
+}
+
+// GetJobs returns jobs with their progress, jobs of all partners and admins when partnerID is nil
+func (s *BulkService) GetJobs(ctx context.Context, partnerID *uuid.UUID) ([]*BulkJobResponse, error) {
+	jobs, err := s.deps.BulkRepository.GetJobs(ctx, partnerID)
+	if err != nil {
+		return nil, err
+	}
+
+	responses := make([]*BulkJobResponse, 0, len(jobs))
+	for _, job := range jobs {
+		items, err := s.deps.BulkRepository.GetItemsWithImages(ctx, job.ID)
+		if err != nil {
+			return nil, err
+		}
+		responses = append(responses, buildResponse(job, items, false))
+	}
+	return responses, nil
+}
+
+// GetResultURL returns link to combined archive of schemas of finished job. Archive holds schema
+// archive of every completed coupon and report with status of every row, it is built on first request.
+func (s *BulkService) GetResultURL(ctx context.Context, id uuid.UUID, partnerID *uuid.UUID) (string, error) {
+	job, items, err := s.getJobWithItems(ctx, id, partnerID)
+	if err != nil {
+		return "", err
+	}
+
+	if job.ResultS3Key == nil {
+		if !calculateProgress(items).Finished {
+			return "", ErrJobNotFinished
+		}
+		if err := s.buildResult(ctx, job, items); err != nil {
+			return "", err
+		}
+	}
+
+	url, err := s.deps.S3Client.GetFileURL(ctx, *job.ResultS3Key, resultURLExpiry)
+	if err != nil {
+		return "", fmt.Errorf("failed to get result URL: %w", err)
+	}
+	return url, nil
+}
+
+func (s *BulkService) getJobWithItems(ctx context.Context, id uuid.UUID, partnerID *uuid.UUID) (*BulkJob, []*BulkJobItemWithImage, error) {
+	job, err := s.deps.BulkRepository.GetJobByID(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	if partnerID != nil && (job.PartnerID == nil || *job.PartnerID != *partnerID) {
+		return nil, nil, ErrJobNotFound
+	}
+
+	items, err := s.deps.BulkRepository.GetItemsWithImages(ctx, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	return job, items, nil
+}
+
+// buildResult writes combined archive to temporary file and uploads it to S3
+func (s *BulkService) buildResult(ctx context.Context, job *BulkJob, items []*BulkJobItemWithImage) error {
+	tmp, err := os.CreateTemp("", "bulk-*.zip")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	writer := zip.NewWriter(tmp)
+	for _, item := range items {
+		if item.Status() != ItemStatusCompleted || item.SchemaS3Key == nil {
+			continue
+		}
+		if err := s.addSchema(ctx, writer, item); err != nil {
+			return err
+		}
+	}
+
+	report, err := writer.Create("report.csv")
+	if err != nil {
+		return fmt.Errorf("failed to add report: %w", err)
+	}
+	if err := writeReport(report, items); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("failed to finish archive: %w", err)
+	}
+
+	size, err := tmp.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to get archive size: %w", err)
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind archive: %w", err)
+	}
+
+	key := fmt.Sprintf("bulk/%s/schemas.zip", job.ID)
+	if _, err := s.deps.S3Client.UploadFileWithKey(ctx, tmp, size, "application/zip", key); err != nil {
+		return fmt.Errorf("failed to upload result archive: %w", err)
+	}
+
+	now := time.Now()
+	job.ResultS3Key = &key
+	job.ResultBuiltAt = &now
+	if err := s.deps.BulkRepository.UpdateResult(ctx, job); err != nil {
+		return err
+	}
+
+	log.Info().
+		Str("job_id", job.ID.String()).
+		Str("s3_key", key).
+		Int64("size", size).
+		Msg("Bulk job result archive built")
+
+	return nil
+}
+
+func (s *BulkService) addSchema(ctx context.Context, writer *zip.Writer, item *BulkJobItemWithImage) error {
+	schema, err := s.deps.S3Client.DownloadFile(ctx, *item.SchemaS3Key)
+	if err != nil {
+		return fmt.Errorf("failed to download schema of coupon %s: %w", item.CouponCode, err)
+	}
+	defer schema.Close()
+
+	entry, err := writer.CreateHeader(&zip.FileHeader{
+		Name:   item.CouponCode + ".zip",
+		Method: zip.Store, // Schema archives are already compressed
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add schema of coupon %s: %w", item.CouponCode, err)
+	}
+	if _, err := io.Copy(entry, schema); err != nil {
+		return fmt.Errorf("failed to copy schema of coupon %s: %w", item.CouponCode, err)
+	}
+	return nil
+}
+
+func writeReport(w io.Writer, items []*BulkJobItemWithImage) error {
+	report := csv.NewWriter(w)
+	if err := report.Write([]string{"line", "file", "coupon_code", "status", "error"}); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	for _, item := range items {
+		reason := ""
+		if item.Error != nil {
+			reason = *item.Error
+		}
+		record := []string{strconv.Itoa(item.Line), item.FileName, item.CouponCode, item.Status(), reason}
+		if err := report.Write(record); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	}
+	report.Flush()
+	return report.Error()
+}
+
+func reject(item *BulkJobItem, err error) {
+	reason := err.Error()
+	item.Error = &reason
+}
+
+// readMapping parses CSV mapping file with header row, both comma and semicolon separated files are accepted
+func readMapping(header *multipart.FileHeader) ([]mappingRow, error) {
+	file, err := header.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open mapping file: %w", err)
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mapping file: %w", err)
+	}
+	data = bytes.TrimPrefix(data, []byte("\xef\xbb\xbf"))
+
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	firstLine, _, _ := bytes.Cut(data, []byte("\n"))
+	if bytes.Count(firstLine, []byte(";")) > bytes.Count(firstLine, []byte(",")) {
+		reader.Comma = ';'
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("%w: mapping file is not a valid CSV: %v", ErrInvalidUpload, err)
+	}
+	if len(records) < 2 {
+		return nil, fmt.Errorf("%w: mapping file has no rows", ErrInvalidUpload)
+	}
+	if len(records)-1 > maxItems {
+		return nil, fmt.Errorf("%w: mapping file has more than %d rows", ErrInvalidUpload, maxItems)
+	}
+
+	fileCol := findColumn(records[0], fileColumns)
+	couponCol := findColumn(records[0], couponColumns)
+	styleCol := findColumn(records[0], styleColumns)
+	if fileCol < 0 || couponCol < 0 {
+		return nil, fmt.Errorf("%w: mapping file must have file and coupon_code columns", ErrInvalidUpload)
+	}
+
+	rows := make([]mappingRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		row := mappingRow{
+			line:       i + 2,
+			fileName:   field(record, fileCol),
+			couponCode: strings.ReplaceAll(field(record, couponCol), "-", ""),
+			style:      strings.ToLower(field(record, styleCol)),
+		}
+		if row.fileName == "" && row.couponCode == "" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func findColumn(header []string, names []string) int {
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(column))
+		for _, name := range names {
+			if column == name {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+func field(record []string, col int) string {
+	if col < 0 || col >= len(record) {
+		return ""
+	}
+	return strings.TrimSpace(record[col])
+}
+
+// indexArchive maps lowercased base names of photos to archive files, folders are ignored
+// so mapping may refer to photos by name only. Names found more than once map to nil.
+func indexArchive(archive *zip.Reader) map[string]*zip.File {
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, file := range archive.File {
+		if file.FileInfo().IsDir() || strings.HasPrefix(file.Name, "__MACOSX/") {
+			continue
+		}
+		name := strings.ToLower(path.Base(file.Name))
+		if strings.HasPrefix(name, ".") {
+			continue
+		}
+		if _, ok := files[name]; ok {
+			files[name] = nil
+			continue
+		}
+		files[name] = file
+	}
+	return files
+}
+
+func readArchiveFile(file *zip.File) ([]byte, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s in archive: %w", file.Name, err)
+	}
+	defer src.Close()
+
+	// Declared size may be forged, so reading is limited as well
+	data, err := io.ReadAll(io.LimitReader(src, maxPhotoSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s from archive: %w", file.Name, err)
+	}
+	if len(data) > maxPhotoSize {
+		return nil, fmt.Errorf("file %s is too large, maximum size is 15MB", file.Name)
+	}
+	return data, nil
+}
+
+func calculateProgress(items []*BulkJobItemWithImage) BulkJobProgress {
+	progress := BulkJobProgress{Total: len(items)}
+	for _, item := range items {
+		switch item.Status() {
+		case ItemStatusRejected:
+			progress.Rejected++
+		case ItemStatusQueued:
+			progress.Queued++
+		case ItemStatusProcessing:
+			progress.Processing++
+		case ItemStatusCompleted:
+			progress.Completed++
+		case ItemStatusFailed:
+			progress.Failed++
+		case ItemStatusCancelled:
+			progress.Cancelled++
+		}
+	}
+
+	accepted := progress.Total - progress.Rejected
+	done := progress.Completed + progress.Failed + progress.Cancelled
+	progress.Percent = 100
+	if accepted > 0 {
+		progress.Percent = done * 100 / accepted
+	}
+	progress.Finished = done == accepted
+	return progress
+}
+
+func buildResponse(job *BulkJob, items []*BulkJobItemWithImage, withItems bool) *BulkJobResponse {
+	response := &BulkJobResponse{
+		BulkJob:  job,
+		Progress: calculateProgress(items),
+	}
+	if !withItems {
+		return response
+	}
+
+	response.Items = make([]*BulkJobItemResponse, 0, len(items))
+	for _, item := range items {
+		response.Items = append(response.Items, &BulkJobItemResponse{
+			BulkJobItem: item.BulkJobItem,
+			Status:      item.Status(),
+		})
+	}
+	return response
+}
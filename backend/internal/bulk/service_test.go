@@ -0,0 +1,411 @@
+package bulk
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/internal/coupon"
+	"github.com/skr1ms/mosaic/internal/image"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockBulkRepository struct {
+	mock.Mock
+}
+
+func (m *MockBulkRepository) CreateJob(ctx context.Context, job *BulkJob, items []*BulkJobItem) error {
+	args := m.Called(ctx, job, items)
+	return args.Error(0)
+}
+
+func (m *MockBulkRepository) GetJobByID(ctx context.Context, id uuid.UUID) (*BulkJob, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*BulkJob), args.Error(1)
+}
+
+func (m *MockBulkRepository) GetJobs(ctx context.Context, partnerID *uuid.UUID) ([]*BulkJob, error) {
+	args := m.Called(ctx, partnerID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*BulkJob), args.Error(1)
+}
+
+func (m *MockBulkRepository) GetItemsWithImages(ctx context.Context, jobID uuid.UUID) ([]*BulkJobItemWithImage, error) {
+	args := m.Called(ctx, jobID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*BulkJobItemWithImage), args.Error(1)
+}
+
+func (m *MockBulkRepository) UpdateItem(ctx context.Context, item *BulkJobItem) error {
+	args := m.Called(ctx, item)
+	return args.Error(0)
+}
+
+func (m *MockBulkRepository) UpdateResult(ctx context.Context, job *BulkJob) error {
+	args := m.Called(ctx, job)
+	return args.Error(0)
+}
+
+type MockCouponRepository struct {
+	mock.Mock
+}
+
+func (m *MockCouponRepository) GetByCode(ctx context.Context, code string) (*coupon.Coupon, error) {
+	args := m.Called(ctx, code)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*coupon.Coupon), args.Error(1)
+}
+
+func (m *MockCouponRepository) Update(ctx context.Context, c *coupon.Coupon) error {
+	args := m.Called(ctx, c)
+	return args.Error(0)
+}
+
+type MockImageRepository struct {
+	mock.Mock
+}
+
+func (m *MockImageRepository) GetByCouponID(ctx context.Context, couponID uuid.UUID) (*image.Image, error) {
+	args := m.Called(ctx, couponID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*image.Image), args.Error(1)
+}
+
+type MockImageService struct {
+	mock.Mock
+}
+
+func (m *MockImageService) ImportImage(ctx context.Context, couponID uuid.UUID, fileName string, data []byte, userEmail string) (*image.Image, error) {
+	args := m.Called(ctx, couponID, fileName, data, userEmail)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*image.Image), args.Error(1)
+}
+
+type MockImageTaskQueue struct {
+	mock.Mock
+}
+
+func (m *MockImageTaskQueue) EnqueueBulkGeneration(imageID uuid.UUID, style string) (string, error) {
+	args := m.Called(imageID, style)
+	return args.String(0), args.Error(1)
+}
+
+type MockS3Client struct {
+	mock.Mock
+}
+
+func (m *MockS3Client) UploadFileWithKey(ctx context.Context, reader io.Reader, size int64, contentType string, objectKey string) (string, error) {
+	data, _ := io.ReadAll(reader)
+	args := m.Called(ctx, data, size, contentType, objectKey)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockS3Client) DownloadFile(ctx context.Context, objectKey string) (io.ReadCloser, error) {
+	args := m.Called(ctx, objectKey)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockS3Client) GetFileURL(ctx context.Context, objectKey string, expiry time.Duration) (string, error) {
+	args := m.Called(ctx, objectKey, expiry)
+	return args.String(0), args.Error(1)
+}
+
+// uploadRequest builds multipart upload of archive with given files and mapping file
+func uploadRequest(t *testing.T, files map[string]string, mapping string, partnerID *uuid.UUID) *CreateJobRequest {
+	var archive bytes.Buffer
+	zw := zip.NewWriter(&archive)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(content))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, zw.Close())
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("archive", "photos.zip")
+	assert.NoError(t, err)
+	_, err = part.Write(archive.Bytes())
+	assert.NoError(t, err)
+	part, err = writer.CreateFormFile("mapping", "mapping.csv")
+	assert.NoError(t, err)
+	_, err = part.Write([]byte(mapping))
+	assert.NoError(t, err)
+	assert.NoError(t, writer.Close())
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(10 << 20)
+	assert.NoError(t, err)
+	return &CreateJobRequest{
+		Archive:   form.File["archive"][0],
+		Mapping:   form.File["mapping"][0],
+		PartnerID: partnerID,
+		CreatedBy: "partner1",
+	}
+}
+
+func TestBulkService_CreateJob(t *testing.T) {
+	partnerID := uuid.New()
+
+	t.Run("valid_and_rejected_rows", func(t *testing.T) {
+		mockRepo := new(MockBulkRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		mockImageService := new(MockImageService)
+		mockQueue := new(MockImageTaskQueue)
+		service := NewBulkService(&BulkServiceDeps{
+			BulkRepository:   mockRepo,
+			CouponRepository: mockCouponRepo,
+			ImageService:     mockImageService,
+			ImageTaskQueue:   mockQueue,
+		})
+
+		fresh := &coupon.Coupon{ID: uuid.New(), Code: "111111111111", PartnerID: partnerID, Status: "new", Style: "grayscale"}
+		foreign := &coupon.Coupon{ID: uuid.New(), Code: "222222222222", PartnerID: uuid.New(), Status: "new"}
+		used := &coupon.Coupon{ID: uuid.New(), Code: "333333333333", PartnerID: partnerID, Status: "completed"}
+		imageID := uuid.New()
+
+		mockCouponRepo.On("GetByCode", mock.Anything, fresh.Code).Return(fresh, nil)
+		mockCouponRepo.On("GetByCode", mock.Anything, foreign.Code).Return(foreign, nil)
+		mockCouponRepo.On("GetByCode", mock.Anything, used.Code).Return(used, nil)
+		mockCouponRepo.On("GetByCode", mock.Anything, "444444444444").Return(nil, errors.New("coupon not found"))
+		jobSaved := false
+		mockRepo.On("CreateJob", mock.Anything, mock.AnythingOfType("*bulk.BulkJob"), mock.Anything).
+			Run(func(args mock.Arguments) { jobSaved = true }).
+			Return(nil)
+		mockCouponRepo.On("Update", mock.Anything, fresh).
+			Run(func(args mock.Arguments) { assert.True(t, jobSaved, "job is saved before coupon is activated") }).
+			Return(nil)
+		mockImageService.On("ImportImage", mock.Anything, fresh.ID, "ivanov.jpg", []byte("photo-1"), "").Return(&image.Image{ID: imageID}, nil)
+		mockQueue.On("EnqueueBulkGeneration", imageID, "pop_art").Return("task-1", nil)
+		mockRepo.On("UpdateItem", mock.Anything, mock.MatchedBy(func(item *BulkJobItem) bool {
+			return item.Line == 2 && item.ImageID != nil && *item.ImageID == imageID
+		})).Return(nil).Once()
+
+		mapping := "file;coupon_code;style\n" +
+			"ivanov.jpg;1111-1111-1111;pop_art\n" +
+			"petrov.jpg;2222-2222-2222;\n" +
+			"sidorov.jpg;3333-3333-3333;\n" +
+			"missing.jpg;4444-4444-4444;\n" +
+			"ivanov.jpg;1111-1111-1111;\n"
+		req := uploadRequest(t, map[string]string{
+			"photos/ivanov.jpg":  "photo-1",
+			"photos/petrov.jpg":  "photo-2",
+			"photos/sidorov.jpg": "photo-3",
+		}, mapping, &partnerID)
+
+		job, err := service.CreateJob(context.Background(), req)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, job.Progress.Total)
+		assert.Equal(t, 4, job.Progress.Rejected)
+		assert.Equal(t, 1, job.Progress.Queued)
+		assert.False(t, job.Progress.Finished)
+
+		assert.Equal(t, ItemStatusQueued, job.Items[0].Status)
+		assert.Equal(t, imageID, *job.Items[0].ImageID)
+		assert.Contains(t, *job.Items[1].Error, "not found")
+		assert.Contains(t, *job.Items[2].Error, "already used")
+		assert.Contains(t, *job.Items[3].Error, "not found in archive")
+		assert.Contains(t, *job.Items[4].Error, "already used on line 2")
+
+		assert.Equal(t, "activated", fresh.Status)
+		assert.Equal(t, "pop_art", fresh.Style)
+		assert.NotNil(t, fresh.ActivatedAt)
+		mockImageService.AssertExpectations(t)
+		mockQueue.AssertExpectations(t)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("activated_coupon_with_photo", func(t *testing.T) {
+		mockRepo := new(MockBulkRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		mockImageRepo := new(MockImageRepository)
+		mockImageService := new(MockImageService)
+		mockQueue := new(MockImageTaskQueue)
+		service := NewBulkService(&BulkServiceDeps{
+			BulkRepository:   mockRepo,
+			CouponRepository: mockCouponRepo,
+			ImageRepository:  mockImageRepo,
+			ImageService:     mockImageService,
+			ImageTaskQueue:   mockQueue,
+		})
+
+		withPhoto := &coupon.Coupon{ID: uuid.New(), Code: "111111111111", PartnerID: partnerID, Status: "activated", Style: "grayscale"}
+		withoutPhoto := &coupon.Coupon{ID: uuid.New(), Code: "222222222222", PartnerID: partnerID, Status: "activated", Style: "grayscale"}
+		imageID := uuid.New()
+
+		mockCouponRepo.On("GetByCode", mock.Anything, withPhoto.Code).Return(withPhoto, nil)
+		mockCouponRepo.On("GetByCode", mock.Anything, withoutPhoto.Code).Return(withoutPhoto, nil)
+		mockImageRepo.On("GetByCouponID", mock.Anything, withPhoto.ID).Return(&image.Image{ID: uuid.New(), CouponID: withPhoto.ID, Status: "uploaded"}, nil)
+		mockImageRepo.On("GetByCouponID", mock.Anything, withoutPhoto.ID).Return(nil, fmt.Errorf("failed to find coupon by ID: %w", sql.ErrNoRows))
+		mockRepo.On("CreateJob", mock.Anything, mock.AnythingOfType("*bulk.BulkJob"), mock.Anything).Return(nil)
+		mockImageService.On("ImportImage", mock.Anything, withoutPhoto.ID, "b.jpg", []byte("photo-2"), "").Return(&image.Image{ID: imageID}, nil)
+		mockQueue.On("EnqueueBulkGeneration", imageID, "grayscale").Return("task-1", nil)
+		mockRepo.On("UpdateItem", mock.Anything, mock.Anything).Return(nil)
+
+		req := uploadRequest(t, map[string]string{"a.jpg": "photo-1", "b.jpg": "photo-2"},
+			"file,coupon_code\na.jpg,111111111111\nb.jpg,222222222222\n", &partnerID)
+
+		job, err := service.CreateJob(context.Background(), req)
+		assert.NoError(t, err)
+		assert.Equal(t, ItemStatusRejected, job.Items[0].Status)
+		assert.Contains(t, *job.Items[0].Error, "already has uploaded photo")
+		assert.Equal(t, ItemStatusQueued, job.Items[1].Status)
+		mockImageService.AssertNotCalled(t, "ImportImage", mock.Anything, withPhoto.ID, mock.Anything, mock.Anything, mock.Anything)
+		mockImageService.AssertExpectations(t)
+	})
+
+	t.Run("coupon_outside_validity_window", func(t *testing.T) {
+		mockRepo := new(MockBulkRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		mockImageService := new(MockImageService)
+		service := NewBulkService(&BulkServiceDeps{
+			BulkRepository:   mockRepo,
+			CouponRepository: mockCouponRepo,
+			ImageService:     mockImageService,
+		})
+
+		past := time.Now().Add(-24 * time.Hour)
+		future := time.Now().Add(24 * time.Hour)
+		expired := &coupon.Coupon{ID: uuid.New(), Code: "111111111111", PartnerID: partnerID, Status: "new", ExpiresAt: &past}
+		notYetValid := &coupon.Coupon{ID: uuid.New(), Code: "222222222222", PartnerID: partnerID, Status: "new", ValidFrom: &future}
+
+		mockCouponRepo.On("GetByCode", mock.Anything, expired.Code).Return(expired, nil)
+		mockCouponRepo.On("GetByCode", mock.Anything, notYetValid.Code).Return(notYetValid, nil)
+
+		_, err := service.CreateJob(context.Background(),
+			uploadRequest(t, map[string]string{"a.jpg": "photo-1"}, "file,coupon_code\na.jpg,111111111111\n", &partnerID))
+		assert.ErrorIs(t, err, ErrInvalidUpload)
+		assert.ErrorContains(t, err, "expired")
+
+		_, err = service.CreateJob(context.Background(),
+			uploadRequest(t, map[string]string{"b.jpg": "photo-2"}, "file,coupon_code\nb.jpg,222222222222\n", &partnerID))
+		assert.ErrorIs(t, err, ErrInvalidUpload)
+		assert.ErrorContains(t, err, "starting from")
+		assert.Equal(t, "new", expired.Status)
+		assert.Equal(t, "new", notYetValid.Status)
+		mockCouponRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		mockImageService.AssertNotCalled(t, "ImportImage", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("no_valid_rows", func(t *testing.T) {
+		mockRepo := new(MockBulkRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		service := NewBulkService(&BulkServiceDeps{
+			BulkRepository:   mockRepo,
+			CouponRepository: mockCouponRepo,
+		})
+
+		req := uploadRequest(t, map[string]string{"a.jpg": "photo"}, "file,coupon_code\nb.jpg,111111111111\n", nil)
+
+		_, err := service.CreateJob(context.Background(), req)
+		assert.ErrorIs(t, err, ErrInvalidUpload)
+		assert.ErrorContains(t, err, "not found in archive")
+		mockRepo.AssertNotCalled(t, "CreateJob", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("missing_columns", func(t *testing.T) {
+		service := NewBulkService(&BulkServiceDeps{})
+
+		req := uploadRequest(t, map[string]string{"a.jpg": "photo"}, "name,code\na.jpg,111111111111\n", nil)
+
+		_, err := service.CreateJob(context.Background(), req)
+		assert.ErrorIs(t, err, ErrInvalidUpload)
+		assert.ErrorContains(t, err, "file and coupon_code columns")
+	})
+}
+
+func TestBulkService_GetResultURL(t *testing.T) {
+	jobID := uuid.New()
+	partnerID := uuid.New()
+	status := func(s string) *string { return &s }
+	schemaKey := "schemas/archive.zip"
+
+	t.Run("job_not_finished", func(t *testing.T) {
+		mockRepo := new(MockBulkRepository)
+		service := NewBulkService(&BulkServiceDeps{BulkRepository: mockRepo})
+
+		mockRepo.On("GetJobByID", mock.Anything, jobID).Return(&BulkJob{ID: jobID}, nil)
+		mockRepo.On("GetItemsWithImages", mock.Anything, jobID).Return([]*BulkJobItemWithImage{
+			{BulkJobItem: &BulkJobItem{ImageID: &jobID}, ImageStatus: status("processing")},
+		}, nil)
+
+		_, err := service.GetResultURL(context.Background(), jobID, nil)
+		assert.ErrorIs(t, err, ErrJobNotFinished)
+	})
+
+	t.Run("job_of_another_partner", func(t *testing.T) {
+		mockRepo := new(MockBulkRepository)
+		service := NewBulkService(&BulkServiceDeps{BulkRepository: mockRepo})
+
+		other := uuid.New()
+		mockRepo.On("GetJobByID", mock.Anything, jobID).Return(&BulkJob{ID: jobID, PartnerID: &other}, nil)
+
+		_, err := service.GetResultURL(context.Background(), jobID, &partnerID)
+		assert.ErrorIs(t, err, ErrJobNotFound)
+	})
+
+	t.Run("builds_archive", func(t *testing.T) {
+		mockRepo := new(MockBulkRepository)
+		mockS3 := new(MockS3Client)
+		service := NewBulkService(&BulkServiceDeps{BulkRepository: mockRepo, S3Client: mockS3})
+
+		imageID := uuid.New()
+		resultKey := "bulk/" + jobID.String() + "/schemas.zip"
+		mockRepo.On("GetJobByID", mock.Anything, jobID).Return(&BulkJob{ID: jobID, PartnerID: &partnerID}, nil)
+		mockRepo.On("GetItemsWithImages", mock.Anything, jobID).Return([]*BulkJobItemWithImage{
+			{BulkJobItem: &BulkJobItem{Line: 2, FileName: "a.jpg", CouponCode: "111111111111", ImageID: &imageID}, ImageStatus: status("completed"), SchemaS3Key: &schemaKey},
+			{BulkJobItem: &BulkJobItem{Line: 3, FileName: "b.jpg", CouponCode: "222222222222", Error: status("coupon 222222222222 not found")}},
+		}, nil)
+		mockS3.On("DownloadFile", mock.Anything, schemaKey).Return(io.NopCloser(strings.NewReader("schema")), nil)
+		mockS3.On("UploadFileWithKey", mock.Anything, mock.Anything, mock.Anything, "application/zip", resultKey).Return(resultKey, nil)
+		mockS3.On("GetFileURL", mock.Anything, resultKey, resultURLExpiry).Return("https://s3/result.zip", nil)
+		mockRepo.On("UpdateResult", mock.Anything, mock.MatchedBy(func(job *BulkJob) bool {
+			return job.ResultS3Key != nil && *job.ResultS3Key == resultKey
+		})).Return(nil)
+
+		url, err := service.GetResultURL(context.Background(), jobID, &partnerID)
+		assert.NoError(t, err)
+		assert.Equal(t, "https://s3/result.zip", url)
+
+		uploaded := mockS3.Calls[1].Arguments.Get(1).([]byte)
+		archive, err := zip.NewReader(bytes.NewReader(uploaded), int64(len(uploaded)))
+		assert.NoError(t, err)
+		assert.Len(t, archive.File, 2)
+		assert.Equal(t, "111111111111.zip", archive.File[0].Name)
+		assert.Equal(t, "report.csv", archive.File[1].Name)
+
+		report, err := archive.File[1].Open()
+		assert.NoError(t, err)
+		content, err := io.ReadAll(report)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "2,a.jpg,111111111111,completed,")
+		assert.Contains(t, string(content), "3,b.jpg,222222222222,rejected,coupon 222222222222 not found")
+		mockRepo.AssertExpectations(t)
+	})
+}
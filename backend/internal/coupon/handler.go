@@ -1,11 +1,13 @@
 package coupon
 
 import (
+	"errors"
 	"fmt"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/google/uuid"
+	apperrors "github.com/skr1ms/mosaic/pkg/errors"
 	"github.com/skr1ms/mosaic/pkg/middleware"
 )
 
@@ -239,6 +241,10 @@ func (handler *CouponHandler) ExportCouponsAdvanced(c *fiber.Ctx) error {
 
 	content, filename, contentType, err := handler.deps.CouponService.ExportCouponsAdvanced(req)
 	if err != nil {
+		var problem *apperrors.ProblemDetail
+		if errors.As(err, &problem) {
+			return apperrors.SendError(c, problem)
+		}
 		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to export coupons")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to export coupons",
@@ -378,7 +384,9 @@ func (handler *CouponHandler) GetCouponByCode(c *fiber.Ctx) error {
 // @Produce json
 // @Param code path string true "Coupon code"
 // @Success 200 {object} map[string]any "Coupon validation status"
+// @Failure 403 {object} map[string]any "Coupon is not yet valid"
 // @Failure 404 {object} map[string]any "Coupon not found"
+// @Failure 410 {object} map[string]any "Coupon expired"
 // @Failure 500 {object} map[string]any "Internal server error"
 // @Router /coupons/code/{code}/validate [post]
 func (handler *CouponHandler) ValidateCoupon(c *fiber.Ctx) error {
@@ -386,6 +394,10 @@ func (handler *CouponHandler) ValidateCoupon(c *fiber.Ctx) error {
 
 	validationResult, err := handler.deps.CouponService.ValidateCoupon(code)
 	if err != nil {
+		var problem *apperrors.ProblemDetail
+		if errors.As(err, &problem) {
+			return apperrors.SendError(c, problem)
+		}
 		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to validate coupon")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to validate coupon",
@@ -457,6 +469,8 @@ func (handler *CouponHandler) GetCouponByID(c *fiber.Ctx) error {
 // @Param request body ActivateCouponRequest true "Image links"
 // @Success 200 {object} map[string]any "Coupon activated"
 // @Failure 400 {object} map[string]any "Invalid coupon ID or request body"
+// @Failure 403 {object} map[string]any "Coupon is not yet valid"
+// @Failure 410 {object} map[string]any "Coupon expired"
 // @Failure 500 {object} map[string]any "Internal server error"
 // @Router /coupons/{id}/activate [put]
 func (handler *CouponHandler) ActivateCoupon(c *fiber.Ctx) error {
@@ -478,6 +492,10 @@ func (handler *CouponHandler) ActivateCoupon(c *fiber.Ctx) error {
 	}
 
 	if err := handler.deps.CouponService.ActivateCoupon(id, req); err != nil {
+		var problem *apperrors.ProblemDetail
+		if errors.As(err, &problem) {
+			return apperrors.SendError(c, problem)
+		}
 		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Failed to activate coupon")
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to activate coupon",
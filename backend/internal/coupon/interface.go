@@ -44,6 +44,7 @@ type CouponRepositoryInterface interface {
 		partnerID *uuid.UUID,
 		page, limit int,
 		createdFrom, createdTo, usedFrom, usedTo *time.Time,
+		expired *bool,
 		sortBy, sortDir string,
 	) ([]*Coupon, int, error)
 	GetFiltered(ctx context.Context, filters map[string]any) ([]*Coupon, error)
@@ -60,6 +61,12 @@ type CouponRepositoryInterface interface {
 	ResetCoupon(ctx context.Context, id uuid.UUID) error
 	Reset(ctx context.Context, id uuid.UUID) error
 	BatchReset(ctx context.Context, ids []uuid.UUID) ([]uuid.UUID, []uuid.UUID, error)
+	FlagExpired(ctx context.Context, now time.Time) (int64, error)
+	ExtendExpiry(ctx context.Context, ids []uuid.UUID, expiresAt *time.Time, extendDays int) ([]uuid.UUID, error)
+	CreateWithBatch(ctx context.Context, batch *CouponBatch, coupons []*Coupon) error
+	GetBatches(ctx context.Context, partnerID *uuid.UUID, page, limit int) ([]*CouponBatchStats, int, error)
+	GetBatchByID(ctx context.Context, id uuid.UUID) (*CouponBatchStats, error)
+	SetBatchBlocked(ctx context.Context, id uuid.UUID, blocked bool) (int64, error)
 
 	CountByPartnerID(ctx context.Context, partnerID uuid.UUID) (int, error)
 	CountActivatedByPartnerID(ctx context.Context, partnerID uuid.UUID) (int, error)
@@ -124,6 +131,10 @@ type CouponServiceInterface interface {
 	DownloadMaterials(id uuid.UUID) ([]byte, string, error)
 
 	BatchResetCoupons(couponIDs []string) (*BatchResetResponse, error)
+	ExtendCouponsExpiry(req ExtendExpiryRequest) (*ExtendExpiryResponse, error)
+	GetBatches(partnerID *uuid.UUID, page, limit int) (*CouponBatchListResponse, error)
+	GetBatch(id uuid.UUID) (*CouponBatchStats, error)
+	SetBatchBlocked(id uuid.UUID, blocked bool) (int64, error)
 	PreviewBatchDelete(couponIDs []string) (*BatchDeletePreviewResponse, error)
 	ExecuteBatchDelete(request BatchDeleteConfirmRequest) (*BatchDeleteResponse, error)
 }
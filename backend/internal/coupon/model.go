@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	apperrors "github.com/skr1ms/mosaic/pkg/errors"
 	"github.com/uptrace/bun"
 )
 
@@ -16,10 +17,19 @@ type Coupon struct {
 	Code          string     `bun:"code,unique,notnull" json:"code"`
 	Size          string     `bun:"size,type:coupon_size,notnull" json:"size"`
 	Style         string     `bun:"style,type:coupon_style,notnull" json:"style"`
+	ProductType   string     `bun:"product_type,type:coupon_product_type,nullzero,notnull,default:'diamond_mosaic'" json:"product_type"`
+	Panels        int        `bun:"panels,nullzero,notnull,default:1" json:"panels"`
+	PanelGapMM    int        `bun:"panel_gap_mm,notnull,default:0" json:"panel_gap_mm"`
 	Status        string     `bun:"status,type:coupon_status,default:'new'" json:"status"`
 	IsPurchased   bool       `bun:"is_purchased,default:false" json:"is_purchased"`
 	PurchaseEmail *string    `bun:"purchase_email" json:"purchase_email"`
 	PurchasedAt   *time.Time `bun:"purchased_at" json:"purchased_at"`
+	BatchID       *uuid.UUID `bun:"batch_id,type:uuid" json:"batch_id,omitempty"` // Print run coupon was created in
+
+	// Optional validity window, coupon cannot be activated or reopened outside of it
+	ValidFrom *time.Time `bun:"valid_from" json:"valid_from,omitempty"`
+	ExpiresAt *time.Time `bun:"expires_at" json:"expires_at,omitempty"`
+	IsExpired bool       `bun:"is_expired,notnull,default:false" json:"is_expired"` // Set by cron once expires_at has passed
 
 	UserEmail   *string    `bun:"user_email" json:"user_email"`
 	ActivatedAt *time.Time `bun:"activated_at" json:"activated_at"`
@@ -45,15 +55,67 @@ func (c *Coupon) CreateIndex() string {
 	CREATE INDEX IF NOT EXISTS idx_coupons_partner_status ON coupons(partner_id, status);
 	CREATE INDEX IF NOT EXISTS idx_coupons_status ON coupons(status);
 	CREATE INDEX IF NOT EXISTS idx_coupons_filters ON coupons(size, style, status);
+	CREATE INDEX IF NOT EXISTS idx_coupons_product_type ON coupons(product_type);
 	CREATE INDEX IF NOT EXISTS idx_coupons_purchased ON coupons(is_purchased);
 	CREATE INDEX IF NOT EXISTS idx_coupons_created_at ON coupons(created_at);
 	CREATE INDEX IF NOT EXISTS idx_coupons_purchased_at ON coupons(purchased_at);
 	CREATE INDEX IF NOT EXISTS idx_coupons_activated_at ON coupons(activated_at);
 	CREATE INDEX IF NOT EXISTS idx_coupons_used_at ON coupons(used_at);
 	CREATE INDEX IF NOT EXISTS idx_coupons_completed_at ON coupons(completed_at);
+	CREATE INDEX IF NOT EXISTS idx_coupons_expires_at ON coupons(expires_at) WHERE expires_at IS NOT NULL AND is_expired = FALSE;
+	CREATE INDEX IF NOT EXISTS idx_coupons_batch_id ON coupons(batch_id, status);
+	`
+}
+
+// CheckValidity returns problem detail error when coupon cannot be used at given time
+func (c *Coupon) CheckValidity(now time.Time) error {
+	if c.IsBlocked {
+		return apperrors.CouponBlockedError()
+	}
+	if c.ValidFrom != nil && now.Before(*c.ValidFrom) {
+		return apperrors.CouponNotYetValidError(*c.ValidFrom)
+	}
+	if c.ExpiresAt != nil && !now.Before(*c.ExpiresAt) {
+		return apperrors.CouponExpiredError(*c.ExpiresAt)
+	}
+	return nil
+}
+
+// CouponBatch is print run of coupons created together, so codes can be traced, exported and recalled as a lot
+type CouponBatch struct {
+	bun.BaseModel `bun:"table:coupon_batches,alias:cb"`
+
+	ID          uuid.UUID  `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	PartnerID   uuid.UUID  `bun:"partner_id,type:uuid,notnull" json:"partner_id"`
+	Size        string     `bun:"size,type:coupon_size,notnull" json:"size"`
+	Style       string     `bun:"style,type:coupon_style,notnull" json:"style"`
+	ProductType string     `bun:"product_type,type:coupon_product_type,nullzero,notnull,default:'diamond_mosaic'" json:"product_type"`
+	Quantity    int        `bun:"quantity,notnull" json:"quantity"`
+	CreatedBy   string     `bun:"created_by" json:"created_by"` // Login of admin who created batch
+	Notes       *string    `bun:"notes" json:"notes,omitempty"`
+	PrintVendor *string    `bun:"print_vendor" json:"print_vendor,omitempty"`
+	ValidFrom   *time.Time `bun:"valid_from" json:"valid_from,omitempty"`
+	ExpiresAt   *time.Time `bun:"expires_at" json:"expires_at,omitempty"`
+	IsBlocked   bool       `bun:"is_blocked,notnull,default:false" json:"is_blocked"`
+	CreatedAt   time.Time  `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+func (b *CouponBatch) CreateIndex() string {
+	return `
+	CREATE INDEX IF NOT EXISTS idx_coupon_batches_partner_id ON coupon_batches(partner_id);
+	CREATE INDEX IF NOT EXISTS idx_coupon_batches_created_at ON coupon_batches(created_at);
 	`
 }
 
+// CouponBatchStats is batch with counters of its coupons
+type CouponBatchStats struct {
+	CouponBatch `bun:",extend"`
+
+	CouponsCount   int     `bun:"coupons_count" json:"coupons_count"`
+	ActivatedCount int     `bun:"activated_count" json:"activated_count"`
+	ActivationRate float64 `bun:"-" json:"activation_rate"` // Percentage of activated coupons
+}
+
 type CouponFilterRequest struct {
 	PartnerID *uuid.UUID `json:"partner_id" query:"partner_id"`
 	Status    string     `json:"status" query:"status"`
@@ -65,6 +127,8 @@ type CouponFilterRequest struct {
 	ActivatedFrom *time.Time `json:"activated_from" query:"activated_from"`
 	ActivatedTo   *time.Time `json:"activated_to" query:"activated_to"`
 
+	Expired *bool `json:"expired" query:"expired"` // Filters by is_expired flag, nil returns all
+
 	Search string `json:"search" query:"search"`
 
 	SortBy string `json:"sort_by" query:"sort_by"`
@@ -1,6 +1,7 @@
 package coupon
 
 import (
+	"fmt"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,6 +10,7 @@ import (
 type CouponSize string
 type CouponStyle string
 type CouponStatus string
+type CouponProductType string
 
 const (
 	Size21x30 CouponSize = "21x30"
@@ -27,13 +29,87 @@ const (
 	StatusActivated CouponStatus = "activated"
 	StatusUsed      CouponStatus = "used"
 	StatusCompleted CouponStatus = "completed"
+
+	ProductTypeDiamondMosaic  CouponProductType = "diamond_mosaic"
+	ProductTypePaintByNumbers CouponProductType = "paint_by_numbers"
+	ProductTypeFuseBeads      CouponProductType = "fuse_beads"
+)
+
+// productTypeSizes lists canvas sizes produced for each product type
+var productTypeSizes = map[CouponProductType][]CouponSize{
+	ProductTypeDiamondMosaic:  {Size21x30, Size30x40, Size40x40, Size40x50, Size40x60, Size50x70},
+	ProductTypePaintByNumbers: {Size30x40, Size40x50, Size40x60, Size50x70},
+	ProductTypeFuseBeads:      {Size21x30, Size30x40, Size40x40, Size40x50},
+}
+
+// Multi-panel layout limits, size of coupon is size of a single panel
+const (
+	MaxPanels     = 4
+	MaxPanelGapMM = 200
 )
 
+// ValidatePanelLayout checks number of panels and gap between them, zero values mean single canvas
+func ValidatePanelLayout(panels, gapMM int) error {
+	if panels < 0 || panels > MaxPanels {
+		return fmt.Errorf("panels must be between 1 and %d", MaxPanels)
+	}
+	if gapMM < 0 || gapMM > MaxPanelGapMM {
+		return fmt.Errorf("panel gap must be between 0 and %d mm", MaxPanelGapMM)
+	}
+	if panels <= 1 && gapMM > 0 {
+		return fmt.Errorf("panel gap requires at least 2 panels")
+	}
+	return nil
+}
+
+// ValidateValidityWindow checks optional validity window of new coupons, expiry must be in the future and after start
+func ValidateValidityWindow(validFrom, expiresAt *time.Time, now time.Time) error {
+	if expiresAt == nil {
+		return nil
+	}
+	if !expiresAt.After(now) {
+		return fmt.Errorf("expires_at must be in the future")
+	}
+	if validFrom != nil && !expiresAt.After(*validFrom) {
+		return fmt.Errorf("expires_at must be after valid_from")
+	}
+	return nil
+}
+
+// SizesForProductType returns canvas sizes available for product type, empty type means diamond mosaic
+func SizesForProductType(productType CouponProductType) []CouponSize {
+	if productType == "" {
+		productType = ProductTypeDiamondMosaic
+	}
+	return productTypeSizes[productType]
+}
+
+// IsSizeAvailableForProductType checks that canvas size is produced for product type
+func IsSizeAvailableForProductType(productType CouponProductType, size CouponSize) bool {
+	for _, s := range SizesForProductType(productType) {
+		if s == size {
+			return true
+		}
+	}
+	return false
+}
+
 type CreateCouponRequest struct {
 	Count     int         `json:"count" validate:"required,min=1,max=1000"`
 	PartnerID uuid.UUID   `json:"partner_id" validate:"required"`
 	Size      CouponSize  `json:"size" validate:"required,oneof=21x30 30x40 40x40 40x50 40x60 50x70"`
 	Style     CouponStyle `json:"style" validate:"required,oneof=grayscale skin_tones pop_art max_colors"`
+
+	ProductType CouponProductType `json:"product_type,omitempty" validate:"omitempty,oneof=diamond_mosaic paint_by_numbers fuse_beads"`
+	Panels      int               `json:"panels,omitempty" validate:"omitempty,min=1,max=4"`
+	PanelGapMM  int               `json:"panel_gap_mm,omitempty" validate:"omitempty,min=0,max=200"`
+
+	ValidFrom *time.Time `json:"valid_from,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
+	// Recorded on batch created for this request
+	Notes       *string `json:"notes,omitempty" validate:"omitempty,max=1000"`
+	PrintVendor *string `json:"print_vendor,omitempty" validate:"omitempty,max=255"`
 }
 
 type UpdateCouponRequest struct {
@@ -49,16 +125,19 @@ type UpdateCouponRequest struct {
 }
 
 type CouponResponse struct {
-	ID            uuid.UUID    `json:"id"`
-	Code          string       `json:"code"`
-	PartnerID     uuid.UUID    `json:"partner_id"`
-	Size          CouponSize   `json:"size"`
-	Style         CouponStyle  `json:"style"`
-	Status        CouponStatus `json:"status"`
-	IsPurchased   bool         `json:"is_purchased"`
-	PurchaseEmail *string      `json:"purchase_email,omitempty"`
-	PurchasedAt   *time.Time   `json:"purchased_at,omitempty"`
-	UsedAt        *time.Time   `json:"used_at,omitempty"`
+	ID            uuid.UUID         `json:"id"`
+	Code          string            `json:"code"`
+	PartnerID     uuid.UUID         `json:"partner_id"`
+	Size          CouponSize        `json:"size"`
+	Style         CouponStyle       `json:"style"`
+	ProductType   CouponProductType `json:"product_type"`
+	Panels        int               `json:"panels"`
+	PanelGapMM    int               `json:"panel_gap_mm"`
+	Status        CouponStatus      `json:"status"`
+	IsPurchased   bool              `json:"is_purchased"`
+	PurchaseEmail *string           `json:"purchase_email,omitempty"`
+	PurchasedAt   *time.Time        `json:"purchased_at,omitempty"`
+	UsedAt        *time.Time        `json:"used_at,omitempty"`
 
 	ZipURL          *string    `json:"zip_url,omitempty"`
 	SchemaSentEmail *string    `json:"schema_sent_email,omitempty"`
@@ -77,6 +156,9 @@ type CouponValidationResponse struct {
 	Style   *string    `json:"style,omitempty"`
 	UsedAt  *time.Time `json:"used_at,omitempty"`
 
+	ValidFrom *time.Time `json:"valid_from,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+
 	PartnerID        *uuid.UUID `json:"partner_id,omitempty"`
 	PartnerCode      *string    `json:"partner_code,omitempty"`
 	PartnerDomain    *string    `json:"partner_domain,omitempty"`
@@ -106,6 +188,11 @@ type PaginatedCouponsResponse struct {
 	Pagination PaginationInfo `json:"pagination"`
 }
 
+type CouponBatchListResponse struct {
+	Batches    []*CouponBatchStats `json:"batches"`
+	Pagination PaginationInfo      `json:"pagination"`
+}
+
 type ActivateCouponRequest struct {
 	ZipURL            *string `json:"zip_url,omitempty" validate:"omitempty,url"`
 	PreviewImageURL   *string `json:"preview_image_url,omitempty" validate:"omitempty,url"`
@@ -136,6 +223,21 @@ type BatchResetResponse struct {
 	Errors       []string `json:"errors,omitempty"`
 }
 
+// ExtendExpiryRequest either sets exact expiry date or extends current one by number of days
+type ExtendExpiryRequest struct {
+	CouponIDs  []string   `json:"coupon_ids" validate:"required,min=1,max=1000"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	ExtendDays int        `json:"extend_days,omitempty" validate:"omitempty,min=1,max=3650"`
+}
+
+type ExtendExpiryResponse struct {
+	Success      []string `json:"success"`
+	Failed       []string `json:"failed"`
+	SuccessCount int      `json:"success_count"`
+	FailedCount  int      `json:"failed_count"`
+	Errors       []string `json:"errors,omitempty"`
+}
+
 type BatchDeleteConfirmRequest struct {
 	CouponIDs       []string `json:"coupon_ids" validate:"required,min=1,max=1000"`
 	ConfirmationKey string   `json:"confirmation_key" validate:"required"`
@@ -183,6 +285,7 @@ type ExportOptionsRequest struct {
 	Format       ExportFormatType `json:"format" validate:"required,oneof=codes basic full admin partner activity"`
 	PartnerID    *string          `json:"partner_id,omitempty"`
 	PartnerCodes []string         `json:"partner_codes,omitempty"`
+	BatchID      *string          `json:"batch_id,omitempty"`
 	Status       string           `json:"status,omitempty"`
 	Size         string           `json:"size,omitempty"`
 	Style        string           `json:"style,omitempty"`
@@ -191,6 +294,7 @@ type ExportOptionsRequest struct {
 	CreatedTo     *time.Time `json:"created_to,omitempty"`
 	ActivatedFrom *time.Time `json:"activated_from,omitempty"`
 	ActivatedTo   *time.Time `json:"activated_to,omitempty"`
+	Expired       *bool      `json:"expired,omitempty"` // Filters by is_expired flag, nil exports all
 
 	FileFormat    string `json:"file_format" validate:"oneof=txt csv xlsx"`
 	Delimiter     string `json:"delimiter,omitempty"`
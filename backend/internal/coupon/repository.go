@@ -86,7 +86,12 @@ func (r *CouponRepository) Update(ctx context.Context, coupon *Coupon) error {
 }
 
 func (r *CouponRepository) UpdateStatusByPartnerID(ctx context.Context, partnerID uuid.UUID, status bool) error {
-	_, err := r.db.NewUpdate().Model((*Coupon)(nil)).Set("is_blocked = ?", status).Where("partner_id = ?", partnerID).Exec(ctx)
+	query := r.db.NewUpdate().Model((*Coupon)(nil)).Set("is_blocked = ?", status).Where("partner_id = ?", partnerID)
+	if !status {
+		// Coupons of blocked batches stay blocked when partner is unblocked
+		query = query.Where("batch_id IS NULL OR batch_id NOT IN (SELECT id FROM coupon_batches WHERE is_blocked = TRUE)")
+	}
+	_, err := query.Exec(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to update coupon status: %w", err)
 	}
@@ -203,7 +208,7 @@ func (r *CouponRepository) Search(ctx context.Context, code, status, size, style
 	return coupons, nil
 }
 
-func (r *CouponRepository) SearchWithPagination(ctx context.Context, code, status, size, style string, partnerID *uuid.UUID, page, limit int, createdFrom, createdTo, usedFrom, usedTo *time.Time, sortBy, sortDir string) ([]*Coupon, int, error) {
+func (r *CouponRepository) SearchWithPagination(ctx context.Context, code, status, size, style string, partnerID *uuid.UUID, page, limit int, createdFrom, createdTo, usedFrom, usedTo *time.Time, expired *bool, sortBy, sortDir string) ([]*Coupon, int, error) {
 	query := r.db.NewSelect().Model((*Coupon)(nil))
 
 	if code != "" {
@@ -234,6 +239,9 @@ func (r *CouponRepository) SearchWithPagination(ctx context.Context, code, statu
 	if usedTo != nil {
 		query = query.Where("used_at <= ?", *usedTo)
 	}
+	if expired != nil {
+		query = query.Where("is_expired = ?", *expired)
+	}
 
 	total, err := query.Count(ctx)
 	if err != nil {
@@ -955,6 +963,10 @@ func (r *CouponRepository) GetCouponsWithAdvancedFilter(ctx context.Context, fil
 		query = query.Where("coupon.activated_at <= ?", *filter.ActivatedTo)
 	}
 
+	if filter.Expired != nil {
+		query = query.Where("coupon.is_expired = ?", *filter.Expired)
+	}
+
 	// Count total records for pagination
 	totalQuery := query.Clone()
 	total, err := totalQuery.Count(ctx)
@@ -1189,6 +1201,14 @@ func (r *CouponRepository) GetCouponsForExport(ctx context.Context, options Expo
 		query = query.Where("EXISTS (SELECT 1 FROM partners p3 WHERE p3.id = coupon.partner_id AND p3.partner_code IN (?))", bun.In(options.PartnerCodes))
 	}
 
+	if options.BatchID != nil {
+		batchID, err := uuid.Parse(strings.TrimSpace(*options.BatchID))
+		if err != nil {
+			return nil, fmt.Errorf("invalid batch_id: %w", err)
+		}
+		query = query.Where("coupon.batch_id = ?", batchID)
+	}
+
 	if options.Status != "" {
 		query = query.Where("coupon.status = ?", options.Status)
 	}
@@ -1218,6 +1238,10 @@ func (r *CouponRepository) GetCouponsForExport(ctx context.Context, options Expo
 		query = query.Where("coupon.activated_at <= ?", *options.ActivatedTo)
 	}
 
+	if options.Expired != nil {
+		query = query.Where("coupon.is_expired = ?", *options.Expired)
+	}
+
 	switch options.Format {
 	case ExportFormatType("codes"):
 		var codes []string
@@ -1747,3 +1771,135 @@ func (r *CouponRepository) GetPartnerUsageStatistics(ctx context.Context, partne
 
 	return stats, nil
 }
+
+// FlagExpired marks coupons whose expiry has passed by given time, returns number of flagged coupons
+func (r *CouponRepository) FlagExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.NewUpdate().Model((*Coupon)(nil)).
+		Set("is_expired = ?", true).
+		Where("is_expired = ?", false).
+		Where("expires_at IS NOT NULL AND expires_at <= ?", now).
+		Exec(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to flag expired coupons: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// ExtendExpiry sets expiry of coupons to expiresAt, or moves it by extendDays from later of current expiry and now
+// when expiresAt is nil. Coupons without expiry are not extended by days. Expired flag is cleared,
+// IDs of updated coupons are returned.
+func (r *CouponRepository) ExtendExpiry(ctx context.Context, ids []uuid.UUID, expiresAt *time.Time, extendDays int) ([]uuid.UUID, error) {
+	query := r.db.NewUpdate().Model((*Coupon)(nil)).
+		Set("is_expired = ?", false).
+		Where("id IN (?)", bun.In(ids))
+
+	if expiresAt != nil {
+		query = query.Set("expires_at = ?", *expiresAt)
+	} else {
+		query = query.
+			Set("expires_at = GREATEST(expires_at, ?) + make_interval(days => ?)", time.Now(), extendDays).
+			Where("expires_at IS NOT NULL")
+	}
+
+	var updated []uuid.UUID
+	if _, err := query.Returning("id").Exec(ctx, &updated); err != nil {
+		return nil, fmt.Errorf("failed to extend coupon expiry: %w", err)
+	}
+	return updated, nil
+}
+
+// CreateWithBatch creates batch record and its coupons in single transaction, coupons get reference to batch
+func (r *CouponRepository) CreateWithBatch(ctx context.Context, batch *CouponBatch, coupons []*Coupon) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(batch).Returning("id, created_at").Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create coupon batch: %w", err)
+		}
+
+		for _, c := range coupons {
+			c.BatchID = &batch.ID
+		}
+
+		if _, err := tx.NewInsert().Model(&coupons).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create coupon: %w", err)
+		}
+		return nil
+	})
+}
+
+// batchStatsQuery selects batches with number of all and activated coupons
+func (r *CouponRepository) batchStatsQuery(batches any) *bun.SelectQuery {
+	return r.db.NewSelect().Model(batches).
+		ColumnExpr("cb.*").
+		ColumnExpr("COUNT(c.id) AS coupons_count").
+		ColumnExpr("COUNT(c.id) FILTER (WHERE c.status IN ('activated', 'used', 'completed')) AS activated_count").
+		Join("LEFT JOIN coupons AS c ON c.batch_id = cb.id").
+		Group("cb.id")
+}
+
+// GetBatches returns page of batches with coupon counters, newest first
+func (r *CouponRepository) GetBatches(ctx context.Context, partnerID *uuid.UUID, page, limit int) ([]*CouponBatchStats, int, error) {
+	countQuery := r.db.NewSelect().Model((*CouponBatch)(nil))
+	if partnerID != nil {
+		countQuery = countQuery.Where("partner_id = ?", *partnerID)
+	}
+	total, err := countQuery.Count(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count coupon batches: %w", err)
+	}
+
+	var batches []*CouponBatchStats
+	query := r.batchStatsQuery(&batches)
+	if partnerID != nil {
+		query = query.Where("cb.partner_id = ?", *partnerID)
+	}
+	err = query.Order("cb.created_at DESC").Limit(limit).Offset((page - 1) * limit).Scan(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to find coupon batches: %w", err)
+	}
+	return batches, total, nil
+}
+
+// GetBatchByID returns batch with coupon counters
+func (r *CouponRepository) GetBatchByID(ctx context.Context, id uuid.UUID) (*CouponBatchStats, error) {
+	batch := new(CouponBatchStats)
+	err := r.batchStatsQuery(batch).Where("cb.id = ?", id).Scan(ctx)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("not found")
+		}
+		return nil, fmt.Errorf("failed to find coupon batch: %w", err)
+	}
+	return batch, nil
+}
+
+// SetBatchBlocked blocks or unblocks batch together with all its coupons, returns number of updated coupons
+func (r *CouponRepository) SetBatchBlocked(ctx context.Context, id uuid.UUID, blocked bool) (int64, error) {
+	var updated int64
+	err := r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		result, err := tx.NewUpdate().Model((*CouponBatch)(nil)).
+			Set("is_blocked = ?", blocked).
+			Where("id = ?", id).
+			Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update coupon batch: %w", err)
+		}
+		if rows, _ := result.RowsAffected(); rows == 0 {
+			return fmt.Errorf("not found")
+		}
+
+		query := tx.NewUpdate().Model((*Coupon)(nil)).
+			Set("is_blocked = ?", blocked).
+			Where("batch_id = ?", id)
+		if !blocked {
+			// Coupons of blocked partner stay blocked when batch is unblocked
+			query = query.Where("partner_id NOT IN (SELECT id FROM partners WHERE status = ?)", "blocked")
+		}
+		result, err = query.Exec(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to update coupons of batch: %w", err)
+		}
+		updated, _ = result.RowsAffected()
+		return nil
+	})
+	return updated, err
+}
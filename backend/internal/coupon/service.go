@@ -7,6 +7,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"sort"
 	"strings"
@@ -14,6 +15,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	apperrors "github.com/skr1ms/mosaic/pkg/errors"
 )
 
 type CouponServiceDeps struct {
@@ -68,6 +70,14 @@ func (s *CouponService) GetCouponByCode(code string) (*Coupon, error) {
 
 // ActivateCoupon activates coupon (changes status to 'completed')
 func (s *CouponService) ActivateCoupon(id uuid.UUID, req ActivateCouponRequest) error {
+	coupon, err := s.deps.CouponRepository.GetByID(context.Background(), id)
+	if err != nil {
+		return fmt.Errorf("failed to get coupon: %w", err)
+	}
+	if err := coupon.CheckValidity(time.Now()); err != nil {
+		return err
+	}
+
 	if err := s.deps.CouponRepository.ActivateCoupon(context.Background(), id, req); err != nil {
 		return fmt.Errorf("failed to activate coupon: %w", err)
 	}
@@ -117,6 +127,10 @@ func (s *CouponService) ValidateCoupon(code string) (*CouponValidationResponse,
 		}, nil
 	}
 
+	if err := coupon.CheckValidity(time.Now()); err != nil {
+		return nil, err
+	}
+
 	// Get partner information
 	partner, err := s.deps.PartnerRepository.GetByID(context.Background(), coupon.PartnerID)
 	if err != nil {
@@ -124,10 +138,12 @@ func (s *CouponService) ValidateCoupon(code string) (*CouponValidationResponse,
 		size := string(coupon.Size)
 		style := string(coupon.Style)
 		return &CouponValidationResponse{
-			Valid:   true,
-			Message: "Coupon is valid and ready to use",
-			Size:    &size,
-			Style:   &style,
+			Valid:     true,
+			Message:   "Coupon is valid and ready to use",
+			Size:      &size,
+			Style:     &style,
+			ValidFrom: coupon.ValidFrom,
+			ExpiresAt: coupon.ExpiresAt,
 		}, nil
 	}
 
@@ -135,10 +151,12 @@ func (s *CouponService) ValidateCoupon(code string) (*CouponValidationResponse,
 	style := string(coupon.Style)
 
 	response := &CouponValidationResponse{
-		Valid:   true,
-		Message: "Coupon is valid and ready to use",
-		Size:    &size,
-		Style:   &style,
+		Valid:     true,
+		Message:   "Coupon is valid and ready to use",
+		Size:      &size,
+		Style:     &style,
+		ValidFrom: coupon.ValidFrom,
+		ExpiresAt: coupon.ExpiresAt,
 
 		// Partner information for domain validation
 		PartnerID:        &partner.ID,
@@ -230,7 +248,7 @@ func (s *CouponService) SearchCouponsWithPagination(code, status, size, style st
 
 	coupons, total, err := s.deps.CouponRepository.SearchWithPagination(
 		context.Background(), code, status, size, style, partnerID, page, limit,
-		nil, nil, nil, nil, "", "",
+		nil, nil, nil, nil, nil, "", "",
 	)
 	if err != nil {
 		return nil, 0, fmt.Errorf("failed to fetch coupons: %w", err)
@@ -305,6 +323,147 @@ func (s *CouponService) BatchResetCoupons(couponIDs []string) (*BatchResetRespon
 	return response, nil
 }
 
+// ExtendCouponsExpiry sets new expiry date or moves current one by number of days for several coupons
+func (s *CouponService) ExtendCouponsExpiry(req ExtendExpiryRequest) (*ExtendExpiryResponse, error) {
+	response := &ExtendExpiryResponse{
+		Success: make([]string, 0),
+		Failed:  make([]string, 0),
+		Errors:  make([]string, 0),
+	}
+
+	if len(req.CouponIDs) == 0 {
+		return response, fmt.Errorf("no coupon IDs provided")
+	}
+
+	if len(req.CouponIDs) > 1000 {
+		return response, fmt.Errorf("too many coupon IDs (maximum 1000)")
+	}
+
+	if (req.ExpiresAt == nil) == (req.ExtendDays == 0) {
+		return response, fmt.Errorf("either expires_at or extend_days must be provided")
+	}
+
+	if req.ExpiresAt != nil && !req.ExpiresAt.After(time.Now()) {
+		return response, fmt.Errorf("expires_at must be in the future")
+	}
+
+	if req.ExtendDays < 0 {
+		return response, fmt.Errorf("extend_days must be positive")
+	}
+
+	var validIDs []uuid.UUID
+	for _, idStr := range req.CouponIDs {
+		id, err := uuid.Parse(idStr)
+		if err != nil {
+			response.Failed = append(response.Failed, idStr)
+			response.Errors = append(response.Errors, fmt.Sprintf("Invalid UUID format: %s", idStr))
+		} else {
+			validIDs = append(validIDs, id)
+		}
+	}
+
+	if len(validIDs) == 0 {
+		response.FailedCount = len(response.Failed)
+		return response, nil
+	}
+
+	updated, err := s.deps.CouponRepository.ExtendExpiry(context.Background(), validIDs, req.ExpiresAt, req.ExtendDays)
+	if err != nil {
+		for _, id := range validIDs {
+			response.Failed = append(response.Failed, id.String())
+		}
+		response.Errors = append(response.Errors, err.Error())
+	} else {
+		updatedSet := make(map[uuid.UUID]bool, len(updated))
+		for _, id := range updated {
+			updatedSet[id] = true
+		}
+		for _, id := range validIDs {
+			if updatedSet[id] {
+				response.Success = append(response.Success, id.String())
+				continue
+			}
+			response.Failed = append(response.Failed, id.String())
+			if req.ExpiresAt == nil {
+				response.Errors = append(response.Errors, fmt.Sprintf("Coupon %s not found or has no expiry to extend", id))
+			} else {
+				response.Errors = append(response.Errors, fmt.Sprintf("Coupon %s not found", id))
+			}
+		}
+	}
+
+	response.SuccessCount = len(response.Success)
+	response.FailedCount = len(response.Failed)
+
+	return response, nil
+}
+
+// GetBatches returns page of coupon batches with activation rate of each
+func (s *CouponService) GetBatches(partnerID *uuid.UUID, page, limit int) (*CouponBatchListResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+
+	batches, total, err := s.deps.CouponRepository.GetBatches(context.Background(), partnerID, page, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get coupon batches: %w", err)
+	}
+
+	for _, batch := range batches {
+		batch.ActivationRate = activationRate(batch.ActivatedCount, batch.CouponsCount)
+	}
+
+	totalPages := (int64(total) + int64(limit) - 1) / int64(limit)
+	return &CouponBatchListResponse{
+		Batches: batches,
+		Pagination: PaginationInfo{
+			CurrentPage: page,
+			PerPage:     limit,
+			Total:       int64(total),
+			TotalPages:  totalPages,
+			HasNext:     int64(page) < totalPages,
+			HasPrevious: page > 1,
+		},
+	}, nil
+}
+
+// GetBatch returns coupon batch with activation rate
+func (s *CouponService) GetBatch(id uuid.UUID) (*CouponBatchStats, error) {
+	batch, err := s.deps.CouponRepository.GetBatchByID(context.Background(), id)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return nil, fmt.Errorf("not found")
+		}
+		return nil, fmt.Errorf("failed to get coupon batch: %w", err)
+	}
+
+	batch.ActivationRate = activationRate(batch.ActivatedCount, batch.CouponsCount)
+	return batch, nil
+}
+
+// SetBatchBlocked blocks or unblocks all coupons of batch, returns number of affected coupons
+func (s *CouponService) SetBatchBlocked(id uuid.UUID, blocked bool) (int64, error) {
+	updated, err := s.deps.CouponRepository.SetBatchBlocked(context.Background(), id, blocked)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			return 0, fmt.Errorf("not found")
+		}
+		return 0, fmt.Errorf("failed to update coupon batch: %w", err)
+	}
+	return updated, nil
+}
+
+// activationRate returns percentage of activated coupons rounded to two decimals
+func activationRate(activated, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(activated)/float64(total)*10000) / 100
+}
+
 // PreviewBatchDelete returns preview for batch deletion
 func (s *CouponService) PreviewBatchDelete(couponIDs []string) (*BatchDeletePreviewResponse, error) {
 	if len(couponIDs) == 0 {
@@ -470,6 +629,12 @@ func (s *CouponService) ExecuteBatchDelete(request BatchDeleteConfirmRequest) (*
 
 // ExportCouponsAdvanced exports coupons with configurable formats
 func (s *CouponService) ExportCouponsAdvanced(options ExportOptionsRequest) ([]byte, string, string, error) {
+	if options.BatchID != nil {
+		if _, err := uuid.Parse(strings.TrimSpace(*options.BatchID)); err != nil {
+			return nil, "", "", apperrors.ValidationError("batch_id must be a valid UUID")
+		}
+	}
+
 	data, err := s.deps.CouponRepository.GetCouponsForExport(context.Background(), options)
 	if err != nil {
 		return nil, "", "", fmt.Errorf("failed to get export data: %w", err)
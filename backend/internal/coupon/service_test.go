@@ -8,6 +8,7 @@ import (
 
 	"github.com/google/uuid"
 	"github.com/redis/go-redis/v9"
+	apperrors "github.com/skr1ms/mosaic/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -107,8 +108,8 @@ func (m *MockCouponRepository) Search(ctx context.Context, code, status, size, s
 	return args.Get(0).([]*Coupon), args.Error(1)
 }
 
-func (m *MockCouponRepository) SearchWithPagination(ctx context.Context, code, status, size, style string, partnerID *uuid.UUID, page, limit int, createdFrom, createdTo, usedFrom, usedTo *time.Time, sortBy, sortDir string) ([]*Coupon, int, error) {
-	args := m.Called(ctx, code, status, size, style, partnerID, page, limit, createdFrom, createdTo, usedFrom, usedTo, sortBy, sortDir)
+func (m *MockCouponRepository) SearchWithPagination(ctx context.Context, code, status, size, style string, partnerID *uuid.UUID, page, limit int, createdFrom, createdTo, usedFrom, usedTo *time.Time, expired *bool, sortBy, sortDir string) ([]*Coupon, int, error) {
+	args := m.Called(ctx, code, status, size, style, partnerID, page, limit, createdFrom, createdTo, usedFrom, usedTo, expired, sortBy, sortDir)
 	if args.Get(0) == nil {
 		return nil, 0, args.Error(2)
 	}
@@ -190,6 +191,45 @@ func (m *MockCouponRepository) BatchReset(ctx context.Context, ids []uuid.UUID)
 	return args.Get(0).([]uuid.UUID), args.Get(1).([]uuid.UUID), args.Error(2)
 }
 
+func (m *MockCouponRepository) FlagExpired(ctx context.Context, now time.Time) (int64, error) {
+	args := m.Called(ctx, now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockCouponRepository) ExtendExpiry(ctx context.Context, ids []uuid.UUID, expiresAt *time.Time, extendDays int) ([]uuid.UUID, error) {
+	args := m.Called(ctx, ids, expiresAt, extendDays)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]uuid.UUID), args.Error(1)
+}
+
+func (m *MockCouponRepository) CreateWithBatch(ctx context.Context, batch *CouponBatch, coupons []*Coupon) error {
+	args := m.Called(ctx, batch, coupons)
+	return args.Error(0)
+}
+
+func (m *MockCouponRepository) GetBatches(ctx context.Context, partnerID *uuid.UUID, page, limit int) ([]*CouponBatchStats, int, error) {
+	args := m.Called(ctx, partnerID, page, limit)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]*CouponBatchStats), args.Int(1), args.Error(2)
+}
+
+func (m *MockCouponRepository) GetBatchByID(ctx context.Context, id uuid.UUID) (*CouponBatchStats, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*CouponBatchStats), args.Error(1)
+}
+
+func (m *MockCouponRepository) SetBatchBlocked(ctx context.Context, id uuid.UUID, blocked bool) (int64, error) {
+	args := m.Called(ctx, id, blocked)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 // Other methods implementation...
 func (m *MockCouponRepository) CountByPartnerID(ctx context.Context, partnerID uuid.UUID) (int, error) {
 	args := m.Called(ctx, partnerID)
@@ -489,6 +529,7 @@ func TestCouponService_ActivateCoupon(t *testing.T) {
 				ZipURL: stringPtr("http://example.com/materials.zip"),
 			},
 			mockSetup: func(repo *MockCouponRepository, redis *MockRedisClient) {
+				repo.On("GetByID", mock.Anything, mock.AnythingOfType("uuid.UUID")).Return(&Coupon{Status: "new"}, nil)
 				repo.On("ActivateCoupon", mock.Anything, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("coupon.ActivateCouponRequest")).Return(nil)
 			},
 			expectedError: false,
@@ -500,10 +541,23 @@ func TestCouponService_ActivateCoupon(t *testing.T) {
 				ZipURL: stringPtr("http://example.com/materials.zip"),
 			},
 			mockSetup: func(repo *MockCouponRepository, redis *MockRedisClient) {
+				repo.On("GetByID", mock.Anything, mock.AnythingOfType("uuid.UUID")).Return(&Coupon{Status: "new"}, nil)
 				repo.On("ActivateCoupon", mock.Anything, mock.AnythingOfType("uuid.UUID"), mock.AnythingOfType("coupon.ActivateCouponRequest")).Return(errors.New("database error"))
 			},
 			expectedError: true,
 		},
+		{
+			name:     "coupon_expired",
+			couponID: uuid.New(),
+			req: ActivateCouponRequest{
+				ZipURL: stringPtr("http://example.com/materials.zip"),
+			},
+			mockSetup: func(repo *MockCouponRepository, redis *MockRedisClient) {
+				expiresAt := time.Now().Add(-time.Hour)
+				repo.On("GetByID", mock.Anything, mock.AnythingOfType("uuid.UUID")).Return(&Coupon{Status: "new", ExpiresAt: &expiresAt}, nil)
+			},
+			expectedError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -922,6 +976,24 @@ func TestCouponService_ValidateCoupon(t *testing.T) {
 			expectedError: false,
 			expectedValid: true,
 		},
+		{
+			name: "expired_coupon",
+			code: "123456789013",
+			mockSetup: func(repo *MockCouponRepository, redis *MockRedisClient, partnerRepo *MockPartnerRepository) {
+				expiresAt := time.Now().Add(-24 * time.Hour)
+				repo.On("GetByCode", mock.Anything, "123456789013").Return(&Coupon{Code: "123456789013", ExpiresAt: &expiresAt}, nil)
+			},
+			expectedError: true,
+		},
+		{
+			name: "not_yet_valid_coupon",
+			code: "123456789014",
+			mockSetup: func(repo *MockCouponRepository, redis *MockRedisClient, partnerRepo *MockPartnerRepository) {
+				validFrom := time.Now().Add(24 * time.Hour)
+				repo.On("GetByCode", mock.Anything, "123456789014").Return(&Coupon{Code: "123456789014", ValidFrom: &validFrom}, nil)
+			},
+			expectedError: true,
+		},
 		{
 			name: "invalid_coupon_not_found",
 			code: "nonexistent",
@@ -993,7 +1065,7 @@ func TestCouponService_SearchCouponsWithPagination(t *testing.T) {
 				coupons := []*Coupon{
 					{ID: uuid.New(), Code: "123456789012", Size: "40x50", Style: "diamond", Status: "active"},
 				}
-				repo.On("SearchWithPagination", mock.Anything, "123", "active", "40x50", "diamond", (*uuid.UUID)(nil), 1, 10, (*time.Time)(nil), (*time.Time)(nil), (*time.Time)(nil), (*time.Time)(nil), "", "").Return(coupons, 1, nil)
+				repo.On("SearchWithPagination", mock.Anything, "123", "active", "40x50", "diamond", (*uuid.UUID)(nil), 1, 10, (*time.Time)(nil), (*time.Time)(nil), (*time.Time)(nil), (*time.Time)(nil), (*bool)(nil), "", "").Return(coupons, 1, nil)
 			},
 			expectedError: false,
 		},
@@ -1146,3 +1218,259 @@ func TestCouponService_BatchResetCoupons(t *testing.T) {
 		})
 	}
 }
+
+func TestCoupon_CheckValidity(t *testing.T) {
+	now := time.Now()
+	past := now.Add(-time.Hour)
+	future := now.Add(time.Hour)
+
+	tests := []struct {
+		name       string
+		coupon     Coupon
+		expectType string
+	}{
+		{name: "no_window", coupon: Coupon{}},
+		{name: "inside_window", coupon: Coupon{ValidFrom: &past, ExpiresAt: &future}},
+		{name: "not_yet_valid", coupon: Coupon{ValidFrom: &future}, expectType: string(apperrors.ErrorTypeCouponNotValid)},
+		{name: "expired", coupon: Coupon{ExpiresAt: &past}, expectType: string(apperrors.ErrorTypeCouponExpired)},
+		{name: "expires_exactly_now", coupon: Coupon{ExpiresAt: &now}, expectType: string(apperrors.ErrorTypeCouponExpired)},
+		{name: "blocked", coupon: Coupon{IsBlocked: true, ExpiresAt: &future}, expectType: string(apperrors.ErrorTypeCouponBlocked)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.coupon.CheckValidity(now)
+			if tt.expectType == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			var problem *apperrors.ProblemDetail
+			assert.True(t, errors.As(err, &problem))
+			assert.Equal(t, apperrors.GetBaseURI()+tt.expectType, problem.Type)
+		})
+	}
+}
+
+func TestCouponService_ExtendCouponsExpiry(t *testing.T) {
+	id1 := uuid.New()
+	id2 := uuid.New()
+	future := time.Now().Add(30 * 24 * time.Hour)
+	past := time.Now().Add(-time.Hour)
+
+	tests := []struct {
+		name          string
+		req           ExtendExpiryRequest
+		mockSetup     func(*MockCouponRepository)
+		expectedError bool
+		expectSuccess int
+		expectFailed  int
+	}{
+		{
+			name: "set_exact_expiry",
+			req:  ExtendExpiryRequest{CouponIDs: []string{id1.String(), id2.String()}, ExpiresAt: &future},
+			mockSetup: func(repo *MockCouponRepository) {
+				repo.On("ExtendExpiry", mock.Anything, []uuid.UUID{id1, id2}, &future, 0).Return([]uuid.UUID{id1, id2}, nil)
+			},
+			expectSuccess: 2,
+		},
+		{
+			name: "extend_by_days_skips_coupons_without_expiry",
+			req:  ExtendExpiryRequest{CouponIDs: []string{id1.String(), id2.String(), "invalid"}, ExtendDays: 30},
+			mockSetup: func(repo *MockCouponRepository) {
+				repo.On("ExtendExpiry", mock.Anything, []uuid.UUID{id1, id2}, (*time.Time)(nil), 30).Return([]uuid.UUID{id1}, nil)
+			},
+			expectSuccess: 1,
+			expectFailed:  2,
+		},
+		{
+			name:          "expiry_in_past",
+			req:           ExtendExpiryRequest{CouponIDs: []string{id1.String()}, ExpiresAt: &past},
+			expectedError: true,
+		},
+		{
+			name:          "both_expiry_and_days",
+			req:           ExtendExpiryRequest{CouponIDs: []string{id1.String()}, ExpiresAt: &future, ExtendDays: 10},
+			expectedError: true,
+		},
+		{
+			name:          "neither_expiry_nor_days",
+			req:           ExtendExpiryRequest{CouponIDs: []string{id1.String()}},
+			expectedError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := new(MockCouponRepository)
+			if tt.mockSetup != nil {
+				tt.mockSetup(mockRepo)
+			}
+
+			service := NewCouponService(&CouponServiceDeps{CouponRepository: mockRepo})
+
+			result, err := service.ExtendCouponsExpiry(tt.req)
+
+			if tt.expectedError {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectSuccess, result.SuccessCount)
+				assert.Equal(t, tt.expectFailed, result.FailedCount)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCouponService_GetBatches(t *testing.T) {
+	partnerID := uuid.New()
+	batches := []*CouponBatchStats{
+		{CouponBatch: CouponBatch{ID: uuid.New(), PartnerID: partnerID, Quantity: 8}, CouponsCount: 8, ActivatedCount: 3},
+		{CouponBatch: CouponBatch{ID: uuid.New(), PartnerID: partnerID, Quantity: 5}, CouponsCount: 0},
+	}
+
+	mockRepo := new(MockCouponRepository)
+	mockRepo.On("GetBatches", mock.Anything, &partnerID, 2, 20).Return(batches, 25, nil)
+
+	service := NewCouponService(&CouponServiceDeps{CouponRepository: mockRepo})
+
+	result, err := service.GetBatches(&partnerID, 2, 500)
+
+	assert.NoError(t, err)
+	assert.Equal(t, 37.5, result.Batches[0].ActivationRate)
+	assert.Equal(t, 0.0, result.Batches[1].ActivationRate)
+	assert.Equal(t, int64(2), result.Pagination.TotalPages)
+	assert.False(t, result.Pagination.HasNext)
+	assert.True(t, result.Pagination.HasPrevious)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestCouponService_SetBatchBlocked(t *testing.T) {
+	tests := []struct {
+		name          string
+		mockSetup     func(*MockCouponRepository, uuid.UUID)
+		expectedCount int64
+		expectedError string
+	}{
+		{
+			name: "successful_block",
+			mockSetup: func(repo *MockCouponRepository, id uuid.UUID) {
+				repo.On("SetBatchBlocked", mock.Anything, id, true).Return(int64(12), nil)
+			},
+			expectedCount: 12,
+		},
+		{
+			name: "batch_not_found",
+			mockSetup: func(repo *MockCouponRepository, id uuid.UUID) {
+				repo.On("SetBatchBlocked", mock.Anything, id, true).Return(int64(0), errors.New("not found"))
+			},
+			expectedError: "not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			batchID := uuid.New()
+			mockRepo := new(MockCouponRepository)
+			tt.mockSetup(mockRepo, batchID)
+
+			service := NewCouponService(&CouponServiceDeps{CouponRepository: mockRepo})
+
+			updated, err := service.SetBatchBlocked(batchID, true)
+
+			if tt.expectedError != "" {
+				assert.EqualError(t, err, tt.expectedError)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.expectedCount, updated)
+			}
+
+			mockRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestIsSizeAvailableForProductType(t *testing.T) {
+	tests := []struct {
+		name        string
+		productType CouponProductType
+		size        CouponSize
+		expected    bool
+	}{
+		{"diamond_small", ProductTypeDiamondMosaic, Size21x30, true},
+		{"diamond_large", ProductTypeDiamondMosaic, Size50x70, true},
+		{"empty_type_defaults_to_diamond", "", Size21x30, true},
+		{"paint_by_numbers_standard", ProductTypePaintByNumbers, Size40x50, true},
+		{"paint_by_numbers_too_small", ProductTypePaintByNumbers, Size21x30, false},
+		{"paint_by_numbers_square", ProductTypePaintByNumbers, Size40x40, false},
+		{"fuse_beads_small", ProductTypeFuseBeads, Size21x30, true},
+		{"fuse_beads_too_large", ProductTypeFuseBeads, Size50x70, false},
+		{"unknown_product_type", "cross_stitch", Size30x40, false},
+		{"unknown_size", ProductTypeDiamondMosaic, "10x10", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, IsSizeAvailableForProductType(tt.productType, tt.size))
+		})
+	}
+}
+
+func TestValidatePanelLayout(t *testing.T) {
+	tests := []struct {
+		name    string
+		panels  int
+		gapMM   int
+		wantErr bool
+	}{
+		{"single_canvas_default", 0, 0, false},
+		{"triptych_with_gap", 3, 40, false},
+		{"too_many_panels", 5, 0, true},
+		{"negative_gap", 2, -1, true},
+		{"gap_too_large", 2, MaxPanelGapMM + 1, true},
+		{"gap_without_panels", 1, 20, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidatePanelLayout(tt.panels, tt.gapMM)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestCouponService_ExportCouponsAdvanced_BatchID(t *testing.T) {
+	t.Run("invalid_batch_id", func(t *testing.T) {
+		repo := new(MockCouponRepository)
+		service := NewCouponService(&CouponServiceDeps{CouponRepository: repo})
+
+		batchID := "not-a-uuid"
+		_, _, _, err := service.ExportCouponsAdvanced(ExportOptionsRequest{Format: "codes", BatchID: &batchID, FileFormat: "txt"})
+
+		var problem *apperrors.ProblemDetail
+		assert.True(t, errors.As(err, &problem))
+		assert.Equal(t, 400, problem.Status)
+		repo.AssertNotCalled(t, "GetCouponsForExport", mock.Anything, mock.Anything)
+	})
+
+	t.Run("valid_batch_id", func(t *testing.T) {
+		repo := new(MockCouponRepository)
+		service := NewCouponService(&CouponServiceDeps{CouponRepository: repo})
+
+		batchID := uuid.New().String()
+		options := ExportOptionsRequest{Format: "codes", BatchID: &batchID, FileFormat: "txt"}
+		repo.On("GetCouponsForExport", mock.Anything, options).Return([]string{"123456789012"}, nil)
+
+		content, _, _, err := service.ExportCouponsAdvanced(options)
+		assert.NoError(t, err)
+		assert.Contains(t, string(content), "123456789012")
+		repo.AssertExpectations(t)
+	})
+}
@@ -2,6 +2,8 @@ package image
 
 import (
 	"context"
+	"errors"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
@@ -47,12 +49,15 @@ func NewImageProcessingHandler(app fiber.Router, deps *ImageHandlerDeps) *ImageH
 	// ================================================================
 	admin := handler.Group("/admin")
 	admin.Get("/queue", handler.GetQueue)                        // GET /api/admin/queue
+	admin.Get("/queue/partners", handler.GetQueueByPartner)      // GET /api/admin/queue/partners
 	admin.Get("/queue/:id", handler.GetTaskByID)                 // GET /api/admin/queue/:id
 	admin.Post("/queue", handler.AddToQueue)                     // POST /api/admin/queue
 	admin.Put("/queue/:id/start", handler.StartProcessing)       // PUT /api/admin/queue/:id/start
 	admin.Put("/queue/:id/complete", handler.CompleteProcessing) // PUT /api/admin/queue/:id/complete
 	admin.Put("/queue/:id/fail", handler.FailProcessing)         // PUT /api/admin/queue/:id/fail
 	admin.Put("/queue/:id/retry", handler.RetryTask)             // PUT /api/admin/queue/:id/retry
+	admin.Put("/queue/:id/cancel", handler.CancelImage)          // PUT /api/admin/queue/:id/cancel
+	admin.Put("/queue/:id/resume", handler.ResumeImage)          // PUT /api/admin/queue/:id/resume
 	admin.Delete("/queue/:id", handler.DeleteTask)               // DELETE /api/admin/queue/:id
 	admin.Get("/statistics", handler.GetStatistics)              // GET /api/admin/statistics
 	admin.Get("/next", handler.GetNextTask)                      // GET /api/admin/next
@@ -137,12 +142,12 @@ func (handler *ImageHandler) UploadImage(c *fiber.Ctx) error {
 }
 
 // @Summary Edit image
-// @Description Applies cropping, rotation and scaling to uploaded image
+// @Description Applies cropping, rotation, scaling and text overlays to uploaded image
 // @Tags public-images
 // @Accept json
 // @Produce json
 // @Param id path string true "Image ID (UUID format)"
-// @Param params body ImageEditParams true "Edit parameters including crop, rotation and scale settings"
+// @Param params body ImageEditParams true "Edit parameters including crop, rotation, scale settings and text overlays"
 // @Success 200 {object} types.ImageEditResponse "Image successfully edited"
 // @Failure 400 {object} map[string]string "Validation error - invalid image ID format or parse error"
 // @Failure 404 {object} map[string]string "Image not found"
@@ -243,6 +248,13 @@ func (handler *ImageHandler) ProcessImage(c *fiber.Ctx) error {
 		Brightness: processRequest.Brightness,
 		Saturation: processRequest.Saturation,
 	}
+	if processRequest.Background != nil {
+		processParams.Background = &BackgroundParams{
+			Mode:      processRequest.Background.Mode,
+			ColorCode: processRequest.Background.ColorCode,
+			Blur:      processRequest.Background.Blur,
+		}
+	}
 
 	// We start processing in the background with a separate context
 	handler.processImageAsync(imageID, &processParams)
@@ -333,7 +345,106 @@ func (handler *ImageHandler) GenerateSchema(c *fiber.Ctx) error {
 	})
 }
 
-// @Summary Get image processing status
+// @Summary Resume cancelled image
+// @Description Returns cancelled image to uploaded or edited status so it can be processed again
+// @Tags admin-image-processing
+// @Produce json
+// @Param id path string true "Image ID (UUID format)"
+// @Success 200 {object} map[string]any "Image resumed"
+// @Failure 400 {object} map[string]string "Validation error - invalid image ID format"
+// @Failure 404 {object} map[string]string "Image not found"
+// @Failure 409 {object} map[string]string "Image is not cancelled"
+// @Failure 500 {object} map[string]string "Internal server error - failed to resume image"
+// @Router /admin/queue/{id}/resume [put]
+func (handler *ImageHandler) ResumeImage(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Invalid ID format")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID format",
+		})
+	}
+
+	imageRecord, err := handler.deps.ImageService.ResumeImage(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrImageNotCancelled) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Image is not cancelled",
+			})
+		}
+		if strings.Contains(err.Error(), "image not found") {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Image not found",
+			})
+		}
+		handler.deps.Logger.FromContext(c).Error().Err(err).Interface("context", map[string]any{"image_id": id}).Msg("Error resuming cancelled image")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Error resuming cancelled image",
+		})
+	}
+
+	handler.deps.Logger.FromContext(c).Info().Interface("context", map[string]any{"image_id": id}).Msg("Cancelled image resumed")
+
+	return c.JSON(fiber.Map{
+		"message":  "Image resumed",
+		"image_id": imageRecord.ID,
+		"status":   imageRecord.Status,
+	})
+}
+
+// @Summary Cancel image processing
+// @Description Removes queued processing and schema generation tasks of image and stops running generation
+// @Tags admin-image-processing
+// @Produce json
+// @Param id path string true "Image ID (UUID format)"
+// @Success 200 {object} map[string]any "Image processing cancelled"
+// @Failure 400 {object} map[string]string "Validation error - invalid image ID format"
+// @Failure 404 {object} map[string]string "Image not found"
+// @Failure 409 {object} map[string]string "Image is already completed or cancelled"
+// @Failure 500 {object} map[string]string "Internal server error - failed to cancel processing"
+// @Router /admin/queue/{id}/cancel [put]
+func (handler *ImageHandler) CancelImage(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Invalid ID format")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid ID format",
+		})
+	}
+
+	imageRecord, err := handler.deps.ImageService.CancelImage(ctx, id)
+	if err != nil {
+		if errors.Is(err, ErrImageNotCancellable) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error": "Image is already completed or cancelled",
+			})
+		}
+		if strings.Contains(err.Error(), "image not found") {
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Image not found",
+			})
+		}
+		handler.deps.Logger.FromContext(c).Error().Err(err).Interface("context", map[string]any{"image_id": id}).Msg("Error cancelling image processing")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Error cancelling image processing",
+		})
+	}
+
+	handler.deps.Logger.FromContext(c).Info().Interface("context", map[string]any{"image_id": id}).Msg("Image processing cancelled")
+
+	return c.JSON(fiber.Map{
+		"message":  "Image processing cancelled",
+		"image_id": imageRecord.ID,
+		"status":   imageRecord.Status,
+	})
+}
+
 // @Description Returns current image processing status and file links
 // @Tags public-images
 // @Produce json
@@ -478,6 +589,32 @@ func (handler *ImageHandler) GetQueue(c *fiber.Ctx) error {
 	return c.JSON(tasks)
 }
 
+// @Summary Get queue depth by partner
+// @Description Returns number of queued and processing images of every partner and partner weight in fair scheduling of queue
+// @Tags admin-image-processing
+// @Produce json
+// @Success 200 {array} PartnerQueueDepth "Queue depth of partners"
+// @Failure 500 {object} map[string]string "Internal server error - failed to get queue depth"
+// @Router /admin/queue/partners [get]
+func (handler *ImageHandler) GetQueueByPartner(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	depths, err := handler.deps.ImageService.GetQueueDepthByPartner(ctx)
+	if err != nil {
+		handler.deps.Logger.FromContext(c).Error().Err(err).Msg("Error getting queue depth by partner")
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Error getting queue depth",
+		})
+	}
+
+	handler.deps.Logger.FromContext(c).Info().Interface("context", map[string]any{
+		"partners": len(depths),
+	}).Msg("Queue depth by partner retrieved")
+
+	return c.JSON(depths)
+}
+
 // @Summary Get task by ID
 // @Description Returns detailed information about image processing task
 // @Tags admin-image-processing
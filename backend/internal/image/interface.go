@@ -20,6 +20,9 @@ type Coupon struct {
 	Code        string     `json:"code"`
 	Size        string     `json:"size"`
 	Style       string     `json:"style"`
+	ProductType string     `json:"product_type"`
+	Panels      int        `json:"panels"`
+	PanelGapMM  int        `json:"panel_gap_mm"`
 	Status      string     `json:"status"`
 	UserEmail   *string    `json:"user_email"`
 	CompletedAt *time.Time `json:"completed_at"`
@@ -38,6 +41,8 @@ type ImageRepositoryInterface interface {
 	FailProcessing(ctx context.Context, id uuid.UUID, errorMessage string) error
 	RetryTask(ctx context.Context, id uuid.UUID) error
 	Update(ctx context.Context, task *Image) error
+	UpdateUnlessCancelled(ctx context.Context, task *Image) (bool, error)
+	UpdateColumns(ctx context.Context, task *Image, columns ...string) error
 	Delete(ctx context.Context, id uuid.UUID) error
 	GetAll(ctx context.Context) ([]*Image, error)
 	GetByStatus(ctx context.Context, status string) ([]*Image, error)
@@ -46,6 +51,24 @@ type ImageRepositoryInterface interface {
 	GetWithFilters(ctx context.Context, status, dateFrom, dateTo string) ([]*ImageWithPartner, error)
 	GetFailedTasksForRetry(ctx context.Context) ([]*Image, error)
 	GetStatistics(ctx context.Context) (map[string]int64, error)
+	GetQueueDepthByPartner(ctx context.Context) ([]*PartnerQueueDepth, error)
+}
+
+// TaskCancellerInterface removes queued tasks of image from task queue
+type TaskCancellerInterface interface {
+	CancelImageTasks(imageID uuid.UUID) (int, error)
+}
+
+// UpscaleQueueInterface schedules upscaling of processed image before schema generation. Schema generation
+// claims upscaling so image is never upscaled by task and schema generation at the same time.
+type UpscaleQueueInterface interface {
+	EnqueueImageUpscaling(imageID uuid.UUID) (string, error)
+	ClaimImageUpscaling(imageID uuid.UUID) (bool, error)
+}
+
+// MockupQueueInterface schedules rendering of mockups after schema generation
+type MockupQueueInterface interface {
+	EnqueueMockupRendering(imageID uuid.UUID) (string, error)
 }
 
 type CouponRepositoryInterface interface {
@@ -69,6 +92,8 @@ type StableDiffusionClientInterface interface {
 	EncodeImageToBase64(data []byte) string
 	DecodeBase64Image(base64Data string) ([]byte, error)
 	CheckHealth(ctx context.Context) error
+	Upscale(ctx context.Context, imageBase64 string, scale float64) (string, error)
+	SegmentSubject(ctx context.Context, imageBase64 string) (string, error)
 }
 
 type EmailServiceInterface interface {
@@ -81,15 +106,22 @@ type ZipServiceInterface interface {
 
 type MosaicGeneratorInterface interface {
 	Generate(ctx context.Context, req *mosaic.GenerationRequest) (*mosaic.GenerationResult, error)
+	GeneratePanels(ctx context.Context, req *mosaic.GenerationRequest, layout mosaic.PanelLayout) (*mosaic.PanelGenerationResult, error)
 }
 
 type ImageServiceInterface interface {
 	GetQueue(status string) ([]*ImageWithPartner, error)
 	GetQueueWithFilters(status, dateFrom, dateTo string) ([]*ImageWithPartner, error)
+	GetQueueDepthByPartner(ctx context.Context) ([]*PartnerQueueDepth, error)
+	CancelImage(ctx context.Context, imageID uuid.UUID) (*Image, error)
+	ResumeImage(ctx context.Context, imageID uuid.UUID) (*Image, error)
 	UploadImage(ctx context.Context, couponID uuid.UUID, file *multipart.FileHeader, userEmail string) (*Image, error)
+	UploadCollage(ctx context.Context, couponID uuid.UUID, templateName string, files []*multipart.FileHeader, userEmail string) (*Image, error)
+	ImportImage(ctx context.Context, couponID uuid.UUID, fileName string, data []byte, userEmail string) (*Image, error)
 	EditImage(ctx context.Context, imageID uuid.UUID, editParams ImageEditParams) error
 	ProcessImage(ctx context.Context, imageID uuid.UUID, processParams *ProcessingParams) error
 	GenerateSchema(ctx context.Context, imageID uuid.UUID, confirmed bool) error
+	GenerateWithStyle(ctx context.Context, imageID uuid.UUID, style string) error
 	GetImageStatus(ctx context.Context, imageID uuid.UUID) (*types.ImageStatusResponse, error)
 
 	GetCouponRepository() CouponRepositoryInterface
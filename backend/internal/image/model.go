@@ -18,6 +18,17 @@ type ProcessingParams struct {
 	Brightness float64        `json:"brightness,omitempty" validate:"omitempty,min=-100,max=100"`
 	Saturation float64        `json:"saturation,omitempty" validate:"omitempty,min=-100,max=100"`
 	Settings   map[string]any `json:"settings,omitempty"`
+	AISkipped  bool           `json:"ai_skipped,omitempty"` // AI was requested, but image was processed without it while Stable Diffusion was unavailable
+
+	Background        *BackgroundParams `json:"background,omitempty"`
+	BackgroundSkipped bool              `json:"background_skipped,omitempty"` // Background replacement was requested, but subject could not be isolated
+}
+
+// BackgroundParams replaces background behind subject of photo isolated by AI segmentation
+type BackgroundParams struct {
+	Mode      string  `json:"mode" validate:"required,oneof=color blur"`
+	ColorCode string  `json:"color_code,omitempty"`                             // Code of color from active palette for color mode, lightest color when empty
+	Blur      float64 `json:"blur,omitempty" validate:"omitempty,min=1,max=10"` // Blur radius in percent of longest side for blur mode
 }
 
 // Value implements driver.Valuer interface to convert ProcessingParams to database value
@@ -52,10 +63,17 @@ type Image struct {
 	ID                  uuid.UUID         `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
 	CouponID            uuid.UUID         `bun:"coupon_id,type:uuid,notnull" json:"coupon_id"`
 	OriginalImageS3Key  string            `bun:"original_image_s3_key,notnull" json:"original_image_s3_key"`
+	OptimizedImageS3Key *string           `bun:"optimized_image_s3_key" json:"optimized_image_s3_key,omitempty"`
 	EditedImageS3Key    *string           `bun:"edited_image_s3_key" json:"edited_image_s3_key"`
 	ProcessedImageS3Key *string           `bun:"processed_image_s3_key" json:"processed_image_s3_key"`
+	UpscaledImageS3Key  *string           `bun:"upscaled_image_s3_key" json:"upscaled_image_s3_key,omitempty"` // Upscaled copy of image schema is generated from
+	Upscale             *UpscaleInfo      `bun:"upscale,type:json" json:"upscale,omitempty"`                   // Analysis of image before schema generation and upscaling result
 	PreviewS3Key        *string           `bun:"preview_s3_key" json:"preview_s3_key"`
 	SchemaS3Key         *string           `bun:"schema_s3_key" json:"schema_s3_key"`
+	Thumbnails          map[string]string `bun:"thumbnails,type:json" json:"thumbnails,omitempty"` // Thumbnail S3 keys by longest side in pixels
+	Mockups             map[string]string `bun:"mockups,type:json" json:"mockups,omitempty"`       // Mockup render S3 keys by scene name
+	EditParams          *ImageEditParams  `bun:"edit_params,type:json" json:"edit_params"`
+	Collage             *CollageParams    `bun:"collage,type:json" json:"collage,omitempty"`
 	ProcessingParams    *ProcessingParams `bun:"processing_params,type:json" json:"processing_params"`
 	UserEmail           string            `bun:"user_email,notnull" json:"user_email"`
 	Status              string            `bun:"status,type:processing_status,default:'queued'" json:"status"`
@@ -75,6 +93,28 @@ type ImageWithPartner struct {
 	PartnerCode string    `bun:"partner_code" json:"partner_code"`
 }
 
+// GalleryImage is completed image of partner coupon with rendered mockups
+type GalleryImage struct {
+	ImageID     uuid.UUID         `bun:"image_id" json:"image_id"`
+	CouponID    uuid.UUID         `bun:"coupon_id" json:"coupon_id"`
+	CouponCode  string            `bun:"coupon_code" json:"coupon_code"`
+	Size        string            `bun:"size" json:"size"`
+	Style       string            `bun:"style" json:"style"`
+	ProductType string            `bun:"product_type" json:"product_type"`
+	Mockups     map[string]string `bun:"mockups,type:json" json:"mockups"`
+	CompletedAt *time.Time        `bun:"completed_at" json:"completed_at"`
+}
+
+// PartnerQueueDepth is number of images of partner waiting in queue and being processed
+type PartnerQueueDepth struct {
+	PartnerID   uuid.UUID `bun:"partner_id" json:"partner_id"`
+	PartnerCode string    `bun:"partner_code" json:"partner_code"`
+	BrandName   string    `bun:"brand_name" json:"brand_name"`
+	QueueWeight int       `bun:"queue_weight" json:"queue_weight"`
+	Queued      int       `bun:"queued" json:"queued"`
+	Processing  int       `bun:"processing" json:"processing"`
+}
+
 func (i *Image) CreateIndex() string {
 	return `
 	CREATE INDEX IF NOT EXISTS idx_images_coupon_id ON images(coupon_id);
@@ -96,4 +136,121 @@ type ImageEditParams struct {
 	CropHeight int     `json:"crop_height" validate:"min=1"`
 	Rotation   int     `json:"rotation" validate:"oneof=0 90 180 270"`
 	Scale      float64 `json:"scale" validate:"min=0.1,max=5.0"`
+
+	Texts []TextOverlay `json:"texts,omitempty" validate:"omitempty,max=5,dive"`
+}
+
+// TextOverlay is text rendered onto edited image before generation, e.g. names and date on wedding kits
+type TextOverlay struct {
+	Text      string  `json:"text" validate:"required,max=100"`
+	Font      string  `json:"font,omitempty" validate:"omitempty,oneof=regular bold italic mono"`
+	Position  string  `json:"position,omitempty" validate:"omitempty,oneof=top center bottom top_left top_right bottom_left bottom_right"`
+	Size      float64 `json:"size,omitempty" validate:"omitempty,min=2,max=25"` // Line height in percent of image height
+	ColorCode string  `json:"color_code,omitempty"`                             // Code of color from active palette, darkest color when empty
+}
+
+// Value implements driver.Valuer interface to convert ImageEditParams to database value
+func (p *ImageEditParams) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner interface to convert database value to ImageEditParams
+func (p *ImageEditParams) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("cannot scan non-bytes into ImageEditParams")
+	}
+
+	return json.Unmarshal(bytes, p)
+}
+
+// CollageParams describes image composed from several source photos
+type CollageParams struct {
+	Template        string   `json:"template"`
+	SourceImageKeys []string `json:"source_image_keys"`
+}
+
+// Value implements driver.Valuer interface to convert CollageParams to database value
+func (p *CollageParams) Value() (driver.Value, error) {
+	if p == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner interface to convert database value to CollageParams
+func (p *CollageParams) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("cannot scan non-bytes into CollageParams")
+	}
+
+	return json.Unmarshal(bytes, p)
+}
+
+// Methods of upscaling image before schema generation
+const (
+	UpscaleMethodNone    = "none"    // Image has enough pixels per stone
+	UpscaleMethodAI      = "ai"      // Upscaler model of AI server
+	UpscaleMethodLanczos = "lanczos" // Plain resize used when AI server is unavailable
+)
+
+// UpscaleInfo describes image before and after upscaling for coupon size
+type UpscaleInfo struct {
+	SourceS3Key          string    `json:"source_s3_key"` // Image that was analyzed, upscaling is repeated when it changes
+	Method               string    `json:"method"`
+	Scale                float64   `json:"scale,omitempty"`
+	BeforeWidth          int       `json:"before_width"`
+	BeforeHeight         int       `json:"before_height"`
+	AfterWidth           int       `json:"after_width"`
+	AfterHeight          int       `json:"after_height"`
+	PixelsPerStoneBefore float64   `json:"pixels_per_stone_before"`
+	PixelsPerStoneAfter  float64   `json:"pixels_per_stone_after"`
+	AIError              string    `json:"ai_error,omitempty"` // Why AI upscaler was not used
+	UpscaledAt           time.Time `json:"upscaled_at"`
+}
+
+// Value implements driver.Valuer interface to convert UpscaleInfo to database value
+func (u *UpscaleInfo) Value() (driver.Value, error) {
+	if u == nil {
+		return nil, nil
+	}
+	b, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner interface to convert database value to UpscaleInfo
+func (u *UpscaleInfo) Scan(value any) error {
+	if value == nil {
+		return nil
+	}
+
+	bytes, ok := value.([]byte)
+	if !ok {
+		return errors.New("cannot scan non-bytes into UpscaleInfo")
+	}
+
+	return json.Unmarshal(bytes, u)
 }
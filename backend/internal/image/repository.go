@@ -44,11 +44,26 @@ func (r *ImageRepository) GetByCouponID(ctx context.Context, couponID uuid.UUID)
 	return task, nil
 }
 
+// fairQueueOrder orders queued images by weighted fair queuing across partners. Within each
+// priority n-th queued image of partner gets virtual finish time n/weight, so partner with weight 2
+// gets two images processed for every image of partner with weight 1 and single partner with
+// hundreds of activations can not starve others. Ties are broken by creation time.
+const fairQueueOrder = `i.priority DESC,
+	(ROW_NUMBER() OVER (PARTITION BY coupons.partner_id, i.priority ORDER BY i.created_at ASC))::float
+		/ GREATEST(partners.queue_weight, 1) ASC,
+	i.created_at ASC`
+
+func (r *ImageRepository) queuedQuery(model any) *bun.SelectQuery {
+	return r.db.NewSelect().Model(model).
+		Join("JOIN coupons ON coupons.id = i.coupon_id").
+		Join("JOIN partners ON partners.id = coupons.partner_id").
+		Where("i.status = ?", "queued").
+		OrderExpr(fairQueueOrder)
+}
+
 func (r *ImageRepository) GetNextInQueue(ctx context.Context) (*Image, error) {
 	task := new(Image)
-	err := r.db.NewSelect().Model(task).
-		Where("status = ?", "queued").
-		OrderExpr("priority DESC, created_at ASC").
+	err := r.queuedQuery(task).
 		Limit(1).
 		Scan(ctx)
 	if err != nil {
@@ -62,10 +77,7 @@ func (r *ImageRepository) GetNextInQueue(ctx context.Context) (*Image, error) {
 
 func (r *ImageRepository) GetQueuedTasks(ctx context.Context) ([]*Image, error) {
 	var tasks []*Image
-	err := r.db.NewSelect().Model(&tasks).
-		Where("status = ?", "queued").
-		OrderExpr("priority DESC, created_at ASC").
-		Scan(ctx)
+	err := r.queuedQuery(&tasks).Scan(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to find queued tasks: %w", err)
 	}
@@ -137,8 +149,11 @@ func (r *ImageRepository) RetryTask(ctx context.Context, id uuid.UUID) error {
 	return nil
 }
 
+// Update saves image record. Columns written by background optimization tasks are skipped
+// so stale record cannot overwrite them, UpdateColumns is used for those.
 func (r *ImageRepository) Update(ctx context.Context, task *Image) error {
 	_, err := r.db.NewUpdate().Model(task).
+		ExcludeColumn("optimized_image_s3_key", "thumbnails").
 		WherePK().Exec(ctx)
 	if err != nil {
 		return fmt.Errorf("failed to update task: %w", err)
@@ -146,6 +161,36 @@ func (r *ImageRepository) Update(ctx context.Context, task *Image) error {
 	return nil
 }
 
+// UpdateUnlessCancelled saves image record like Update unless image was cancelled meanwhile,
+// possibly by another process. Reports whether record was saved.
+func (r *ImageRepository) UpdateUnlessCancelled(ctx context.Context, task *Image) (bool, error) {
+	result, err := r.db.NewUpdate().Model(task).
+		ExcludeColumn("optimized_image_s3_key", "thumbnails").
+		WherePK().
+		Where("status <> ?", "cancelled").
+		Exec(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to update task: %w", err)
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to get updated rows: %w", err)
+	}
+	return rows > 0, nil
+}
+
+// UpdateColumns saves only given columns of image record
+func (r *ImageRepository) UpdateColumns(ctx context.Context, task *Image, columns ...string) error {
+	task.UpdatedAt = time.Now()
+	_, err := r.db.NewUpdate().Model(task).
+		Column(append(columns, "updated_at")...).
+		WherePK().Exec(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to update task columns: %w", err)
+	}
+	return nil
+}
+
 func (r *ImageRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	_, err := r.db.NewDelete().Model((*Image)(nil)).Where("id = ?", id).Exec(ctx)
 	if err != nil {
@@ -276,3 +321,64 @@ func (r *ImageRepository) GetStatistics(ctx context.Context) (map[string]int64,
 
 	return stats, nil
 }
+
+// GetQueueDepthByPartner returns number of queued and processing images of every partner that has any
+func (r *ImageRepository) GetQueueDepthByPartner(ctx context.Context) ([]*PartnerQueueDepth, error) {
+	var depths []*PartnerQueueDepth
+	err := r.db.NewSelect().
+		Model((*Image)(nil)).
+		ColumnExpr("partners.id AS partner_id, partners.partner_code, partners.brand_name, partners.queue_weight").
+		ColumnExpr("COUNT(*) FILTER (WHERE i.status = 'queued') AS queued").
+		ColumnExpr("COUNT(*) FILTER (WHERE i.status = 'processing') AS processing").
+		Join("JOIN coupons ON coupons.id = i.coupon_id").
+		Join("JOIN partners ON partners.id = coupons.partner_id").
+		Where("i.status IN (?)", bun.In([]string{"queued", "processing"})).
+		GroupExpr("partners.id, partners.partner_code, partners.brand_name, partners.queue_weight").
+		OrderExpr("queued DESC, partners.partner_code ASC").
+		Scan(ctx, &depths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue depth by partner: %w", err)
+	}
+	return depths, nil
+}
+
+// GetQueueShare returns partner that owns image and weight of partner in task queue
+func (r *ImageRepository) GetQueueShare(ctx context.Context, imageID uuid.UUID) (uuid.UUID, int, error) {
+	var share struct {
+		PartnerID   uuid.UUID `bun:"partner_id"`
+		QueueWeight int       `bun:"queue_weight"`
+	}
+	err := r.db.NewSelect().
+		Model((*Image)(nil)).
+		ColumnExpr("partners.id AS partner_id, partners.queue_weight").
+		Join("JOIN coupons ON coupons.id = i.coupon_id").
+		Join("JOIN partners ON partners.id = coupons.partner_id").
+		Where("i.id = ?", imageID).
+		Limit(1).
+		Scan(ctx, &share)
+	if err != nil {
+		return uuid.Nil, 0, fmt.Errorf("failed to get queue share of image: %w", err)
+	}
+	return share.PartnerID, share.QueueWeight, nil
+}
+
+// GetGalleryByPartner returns completed images of partner coupons that have mockups, newest first
+func (r *ImageRepository) GetGalleryByPartner(ctx context.Context, partnerID uuid.UUID, limit, offset int) ([]*GalleryImage, int, error) {
+	var gallery []*GalleryImage
+	total, err := r.db.NewSelect().
+		Model((*Image)(nil)).
+		ColumnExpr("i.id AS image_id, i.coupon_id, i.mockups, i.completed_at").
+		ColumnExpr("coupons.code AS coupon_code, coupons.size, coupons.style, coupons.product_type").
+		Join("JOIN coupons ON coupons.id = i.coupon_id").
+		Where("coupons.partner_id = ?", partnerID).
+		Where("i.status = ?", "completed").
+		Where("i.mockups IS NOT NULL").
+		OrderExpr("i.completed_at DESC").
+		Limit(limit).
+		Offset(offset).
+		ScanAndCount(ctx, &gallery)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get gallery of partner: %w", err)
+	}
+	return gallery, total, nil
+}
@@ -5,25 +5,36 @@ import (
 	"context"
 	"encoding/base64"
 	"encoding/csv"
+	"errors"
 	"fmt"
 	"image"
+	"image/color"
 	"image/jpeg"
 	"image/png"
 	"io"
+	"math"
 	"mime/multipart"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/disintegration/imaging"
 	"github.com/google/uuid"
 	"github.com/rs/zerolog/log"
 	"github.com/skr1ms/mosaic/internal/types"
+	"github.com/skr1ms/mosaic/pkg/background"
+	"github.com/skr1ms/mosaic/pkg/collage"
+	"github.com/skr1ms/mosaic/pkg/mockup"
 	"github.com/skr1ms/mosaic/pkg/mosaic"
 	"github.com/skr1ms/mosaic/pkg/palette"
 	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
+	"github.com/skr1ms/mosaic/pkg/textOverlay"
+	"github.com/skr1ms/mosaic/pkg/thumbnail"
 	"github.com/skr1ms/mosaic/pkg/zip"
 )
 
@@ -36,16 +47,60 @@ type ImageServiceDeps struct {
 	ZipService            ZipServiceInterface
 	MosaicGenerator       MosaicGeneratorInterface
 	PaletteService        *palette.PaletteService
+	BeadBrand             string
 	WorkingDir            string
+	ThumbnailSizes        []string
+	ThumbnailFormat       string
+	ThumbnailQuality      int
+	OptimizeQuality       int
+	TaskCanceller         TaskCancellerInterface
+	UpscaleQueue          UpscaleQueueInterface
+	UpscaleEnabled        bool
+	MinPixelsPerStone     float64 // Images with fewer pixels per stone are upscaled before schema generation
+	TargetPixelsPerStone  float64
+	MockupQueue           MockupQueueInterface
+	MockupEnabled         bool
+	MockupScenes          []mockup.Scene
 }
 
+// optimizedMaxSide limits longest side of optimized original, larger photos give no benefit for generation
+const optimizedMaxSide = 4096
+
+// maxUploadSize limits size of single uploaded photo
+const maxUploadSize = 15 << 20
+
+// mockupSimulationSide is longest side of finished kit rendered for mockups, large enough for drills to have facets
+const mockupSimulationSide = 2400
+
+// maxUpscaleFactor limits enlargement of low-resolution image, larger factors invent detail that is not in photo
+const maxUpscaleFactor = 4
+
+// cancelPollInterval is how often running job checks whether its image was cancelled by another process
+const cancelPollInterval = 3 * time.Second
+
+// upscaleWaitInterval is how often schema generation checks whether upscaling task of its image has finished
+const upscaleWaitInterval = time.Second
+
+var (
+	// ErrImageCancelled is returned when processing or schema generation of image was cancelled
+	ErrImageCancelled = errors.New("image processing cancelled")
+	// ErrImageNotCancellable is returned when image has no processing left to cancel
+	ErrImageNotCancellable = errors.New("image cannot be cancelled in current status")
+	// ErrImageNotCancelled is returned when image being resumed was not cancelled
+	ErrImageNotCancelled = errors.New("image is not cancelled")
+)
+
 type ImageService struct {
 	deps *ImageServiceDeps
+
+	jobsMu sync.Mutex
+	jobs   map[uuid.UUID]context.CancelCauseFunc // Processing and schema generation running in this process
 }
 
 func NewImageService(deps *ImageServiceDeps) *ImageService {
 	s := &ImageService{
 		deps: deps,
+		jobs: make(map[uuid.UUID]context.CancelCauseFunc),
 	}
 
 	go func() {
@@ -96,6 +151,16 @@ func (s *ImageService) GetQueueWithFilters(status, dateFrom, dateTo string) ([]*
 	return tasks, nil
 }
 
+// GetQueueDepthByPartner returns number of queued and processing images per partner with partner queue weights
+func (s *ImageService) GetQueueDepthByPartner(ctx context.Context) ([]*PartnerQueueDepth, error) {
+	depths, err := s.deps.ImageRepository.GetQueueDepthByPartner(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch queue depth by partner: %w", err)
+	}
+
+	return depths, nil
+}
+
 // UploadImage uploads and saves image to S3
 func (s *ImageService) UploadImage(ctx context.Context, couponID uuid.UUID, file *multipart.FileHeader, userEmail string) (*Image, error) {
 	_, err := s.deps.CouponRepository.GetByID(ctx, couponID)
@@ -103,59 +168,79 @@ func (s *ImageService) UploadImage(ctx context.Context, couponID uuid.UUID, file
 		return nil, fmt.Errorf("coupon not found: %w", err)
 	}
 
-	existingImage, err := s.deps.ImageRepository.GetByCouponID(ctx, couponID)
-	if err == nil && existingImage != nil {
-		// Allow image reload if it's not being processed
-		if existingImage.Status == "processing" || existingImage.Status == "completed" {
-			return nil, fmt.Errorf("image already uploaded for this coupon and is being processed")
-		}
-		// Delete old image for reload
-		if err := s.deps.ImageRepository.Delete(ctx, existingImage.ID); err != nil {
-			log.Warn().Err(err).Str("image_id", existingImage.ID.String()).Msg("Failed to delete old image record")
-		}
-		s.cleanupLocalFiles(couponID)
+	if err := s.replaceExistingImage(ctx, couponID); err != nil {
+		return nil, err
 	}
 
-	if !isValidImageType(file) {
-		return nil, fmt.Errorf("invalid image type, supported: JPG, PNG")
+	if err := validateUploadedFile(file); err != nil {
+		return nil, err
 	}
 
-	if file.Size > 15<<20 {
-		return nil, fmt.Errorf("file too large, maximum size is 15MB")
+	uploadsDir := filepath.Join(s.deps.WorkingDir, "uploads", couponID.String())
+	localPath, err := saveUploadedFile(file, uploadsDir, fmt.Sprintf("%d", time.Now().Unix()))
+	if err != nil {
+		return nil, err
 	}
 
-	src, err := file.Open()
-	if err != nil {
-		return nil, fmt.Errorf("failed to open uploaded file: %w", err)
+	imageRecord := &Image{
+		CouponID:           couponID,
+		OriginalImageS3Key: "file://" + localPath,
+		UserEmail:          userEmail,
+		Status:             "uploaded",
+		Priority:           1,
 	}
-	defer src.Close()
 
-	uploadsDir := filepath.Join(s.deps.WorkingDir, "uploads", couponID.String())
-	if err := os.MkdirAll(uploadsDir, 0o755); err != nil {
-		return nil, fmt.Errorf("failed to create uploads dir: %w", err)
+	if err := s.deps.ImageRepository.Create(ctx, imageRecord); err != nil {
+		return nil, fmt.Errorf("failed to create image record: %w", err)
+	}
+
+	log.Info().
+		Str("image_id", imageRecord.ID.String()).
+		Str("coupon_id", couponID.String()).
+		Str("s3_key", imageRecord.OriginalImageS3Key).
+		Msg("Image uploaded successfully")
+
+	return imageRecord, nil
+}
+
+// ImportImage creates image of coupon from photo that was not uploaded through public flow,
+// e.g. taken from archive of bulk job. Imported images get lower priority than customer uploads.
+func (s *ImageService) ImportImage(ctx context.Context, couponID uuid.UUID, fileName string, data []byte, userEmail string) (*Image, error) {
+	if _, err := s.deps.CouponRepository.GetByID(ctx, couponID); err != nil {
+		return nil, fmt.Errorf("coupon not found: %w", err)
 	}
 
+	if len(data) > maxUploadSize {
+		return nil, fmt.Errorf("file too large, maximum size is 15MB")
+	}
 	ext := ".jpg"
-	if ct := file.Header.Get("Content-Type"); ct == "image/png" {
+	switch http.DetectContentType(data) {
+	case "image/jpeg":
+	case "image/png":
 		ext = ".png"
+	default:
+		return nil, fmt.Errorf("invalid image type, supported: JPG, PNG")
 	}
-	localPath := filepath.Join(uploadsDir, fmt.Sprintf("%d%s", time.Now().Unix(), ext))
-	dst, err := os.Create(localPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create local file: %w", err)
+
+	if err := s.replaceExistingImage(ctx, couponID); err != nil {
+		return nil, err
 	}
-	if _, err := io.Copy(dst, src); err != nil {
-		dst.Close()
+
+	uploadsDir := filepath.Join(s.deps.WorkingDir, "uploads", couponID.String())
+	if err := os.MkdirAll(uploadsDir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create uploads dir: %w", err)
+	}
+	localPath := filepath.Join(uploadsDir, fmt.Sprintf("%d%s", time.Now().Unix(), ext))
+	if err := os.WriteFile(localPath, data, 0o644); err != nil {
 		return nil, fmt.Errorf("failed to write local file: %w", err)
 	}
-	dst.Close()
 
 	imageRecord := &Image{
 		CouponID:           couponID,
 		OriginalImageS3Key: "file://" + localPath,
 		UserEmail:          userEmail,
 		Status:             "uploaded",
-		Priority:           1,
+		Priority:           0,
 	}
 
 	if err := s.deps.ImageRepository.Create(ctx, imageRecord); err != nil {
@@ -165,12 +250,164 @@ func (s *ImageService) UploadImage(ctx context.Context, couponID uuid.UUID, file
 	log.Info().
 		Str("image_id", imageRecord.ID.String()).
 		Str("coupon_id", couponID.String()).
+		Str("file_name", fileName).
 		Str("s3_key", imageRecord.OriginalImageS3Key).
-		Msg("Image uploaded successfully")
+		Msg("Image imported successfully")
+
+	return imageRecord, nil
+}
+
+// GetCollageTemplates returns collage templates available for coupon size
+func (s *ImageService) GetCollageTemplates(size string) []collage.Template {
+	width, height := parseCouponSize(size)
+	return collage.Templates(width, height)
+}
+
+// UploadCollage composes several photos into one image using collage template of coupon size.
+// Composed image is stored as original image, so it is edited and processed like a normal upload.
+func (s *ImageService) UploadCollage(ctx context.Context, couponID uuid.UUID, templateName string, files []*multipart.FileHeader, userEmail string) (*Image, error) {
+	coupon, err := s.deps.CouponRepository.GetByID(ctx, couponID)
+	if err != nil {
+		return nil, fmt.Errorf("coupon not found: %w", err)
+	}
+
+	if len(files) < collage.MinPhotos || len(files) > collage.MaxPhotos {
+		return nil, fmt.Errorf("collage requires from %d to %d photos, got %d", collage.MinPhotos, collage.MaxPhotos, len(files))
+	}
+
+	width, height := parseCouponSize(coupon.Size)
+	template, err := collage.GetTemplate(templateName, width, height)
+	if err != nil {
+		return nil, err
+	}
+	if template.Photos != len(files) {
+		return nil, fmt.Errorf("template %s requires %d photos, got %d", template.Name, template.Photos, len(files))
+	}
+
+	for _, file := range files {
+		if err := validateUploadedFile(file); err != nil {
+			return nil, fmt.Errorf("%s: %w", file.Filename, err)
+		}
+	}
+
+	if err := s.replaceExistingImage(ctx, couponID); err != nil {
+		return nil, err
+	}
+
+	uploadsDir := filepath.Join(s.deps.WorkingDir, "uploads", couponID.String())
+	prefix := time.Now().Unix()
+	sourceKeys := make([]string, 0, len(files))
+	photos := make([]image.Image, 0, len(files))
+	for i, file := range files {
+		localPath, err := saveUploadedFile(file, uploadsDir, fmt.Sprintf("%d_source_%d", prefix, i+1))
+		if err != nil {
+			return nil, err
+		}
+		photo, err := imaging.Open(localPath, imaging.AutoOrientation(true))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode photo %s: %w", file.Filename, err)
+		}
+		sourceKeys = append(sourceKeys, "file://"+localPath)
+		photos = append(photos, photo)
+	}
+
+	composed, err := collage.Compose(template, photos, width, height, collage.DefaultGutterPercent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to compose collage: %w", err)
+	}
+
+	composedPath := filepath.Join(uploadsDir, fmt.Sprintf("%d.jpg", prefix))
+	if err := imaging.Save(composed, composedPath, imaging.JPEGQuality(95)); err != nil {
+		return nil, fmt.Errorf("failed to save collage: %w", err)
+	}
+
+	imageRecord := &Image{
+		CouponID:           couponID,
+		OriginalImageS3Key: "file://" + composedPath,
+		Collage: &CollageParams{
+			Template:        template.Name,
+			SourceImageKeys: sourceKeys,
+		},
+		UserEmail: userEmail,
+		Status:    "uploaded",
+		Priority:  1,
+	}
+
+	if err := s.deps.ImageRepository.Create(ctx, imageRecord); err != nil {
+		return nil, fmt.Errorf("failed to create image record: %w", err)
+	}
+
+	log.Info().
+		Str("image_id", imageRecord.ID.String()).
+		Str("coupon_id", couponID.String()).
+		Str("template", template.Name).
+		Int("photos", len(files)).
+		Str("s3_key", imageRecord.OriginalImageS3Key).
+		Msg("Collage uploaded successfully")
 
 	return imageRecord, nil
 }
 
+// replaceExistingImage deletes image previously uploaded for coupon unless it is already being processed
+func (s *ImageService) replaceExistingImage(ctx context.Context, couponID uuid.UUID) error {
+	existingImage, err := s.deps.ImageRepository.GetByCouponID(ctx, couponID)
+	if err != nil || existingImage == nil {
+		return nil
+	}
+
+	// Allow image reload if it's not being processed
+	if existingImage.Status == "processing" || existingImage.Status == "completed" {
+		return fmt.Errorf("image already uploaded for this coupon and is being processed")
+	}
+	// Delete old image for reload
+	if err := s.deps.ImageRepository.Delete(ctx, existingImage.ID); err != nil {
+		log.Warn().Err(err).Str("image_id", existingImage.ID.String()).Msg("Failed to delete old image record")
+	}
+	s.cleanupLocalFiles(couponID)
+	return nil
+}
+
+func validateUploadedFile(file *multipart.FileHeader) error {
+	if !isValidImageType(file) {
+		return fmt.Errorf("invalid image type, supported: JPG, PNG")
+	}
+
+	if file.Size > maxUploadSize {
+		return fmt.Errorf("file too large, maximum size is 15MB")
+	}
+	return nil
+}
+
+// saveUploadedFile copies uploaded file into dir, extension is derived from content type
+func saveUploadedFile(file *multipart.FileHeader, dir, name string) (string, error) {
+	src, err := file.Open()
+	if err != nil {
+		return "", fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create uploads dir: %w", err)
+	}
+
+	ext := ".jpg"
+	if ct := file.Header.Get("Content-Type"); ct == "image/png" {
+		ext = ".png"
+	}
+	localPath := filepath.Join(dir, name+ext)
+	dst, err := os.Create(localPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to create local file: %w", err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return "", fmt.Errorf("failed to write local file: %w", err)
+	}
+	dst.Close()
+
+	return localPath, nil
+}
+
 // EditImage applies editing to image
 func (s *ImageService) EditImage(ctx context.Context, imageID uuid.UUID, editParams ImageEditParams) error {
 	imageRecord, err := s.deps.ImageRepository.GetByID(ctx, imageID)
@@ -182,7 +419,7 @@ func (s *ImageService) EditImage(ctx context.Context, imageID uuid.UUID, editPar
 		return fmt.Errorf("image cannot be edited in current status: %s", imageRecord.Status)
 	}
 
-	originalReader, err := s.openFromStorage(ctx, imageRecord.OriginalImageS3Key)
+	originalReader, err := s.openFromStorage(ctx, s.originalImageKey(imageRecord))
 	if err != nil {
 		return fmt.Errorf("failed to download original image: %w", err)
 	}
@@ -195,6 +432,17 @@ func (s *ImageService) EditImage(ctx context.Context, imageID uuid.UUID, editPar
 
 	editedImg := s.applyImageEditing(img, editParams)
 
+	if len(editParams.Texts) > 0 {
+		coupon, err := s.deps.CouponRepository.GetByID(ctx, imageRecord.CouponID)
+		if err != nil {
+			return fmt.Errorf("failed to get coupon: %w", err)
+		}
+		editedImg, err = s.ApplyTextOverlays(editedImg, editParams.Texts, coupon.ProductType, coupon.Style)
+		if err != nil {
+			return fmt.Errorf("failed to apply text overlays: %w", err)
+		}
+	}
+
 	var buf bytes.Buffer
 	switch format {
 	case "jpeg":
@@ -219,6 +467,7 @@ func (s *ImageService) EditImage(ctx context.Context, imageID uuid.UUID, editPar
 
 	fileEdited := "file://" + editedPath
 	imageRecord.EditedImageS3Key = &fileEdited
+	imageRecord.EditParams = &editParams
 	imageRecord.Status = "edited"
 	if err := s.deps.ImageRepository.Update(ctx, imageRecord); err != nil {
 		return fmt.Errorf("failed to update image record: %w", err)
@@ -227,6 +476,7 @@ func (s *ImageService) EditImage(ctx context.Context, imageID uuid.UUID, editPar
 	log.Info().
 		Str("image_id", imageID.String()).
 		Str("edited_s3_key", fileEdited).
+		Int("texts", len(editParams.Texts)).
 		Msg("Image edited successfully")
 
 	return nil
@@ -239,11 +489,17 @@ func (s *ImageService) ProcessImage(ctx context.Context, imageID uuid.UUID, proc
 		return fmt.Errorf("image not found: %w", err)
 	}
 
+	if imageRecord.Status == "cancelled" {
+		return ErrImageCancelled
+	}
 	if imageRecord.Status != "edited" && imageRecord.Status != "uploaded" {
 		return fmt.Errorf("image cannot be processed in current status: %s", imageRecord.Status)
 	}
 
-	sourceS3Key := imageRecord.OriginalImageS3Key
+	ctx, finish := s.startJob(ctx, imageID)
+	defer finish()
+
+	sourceS3Key := s.originalImageKey(imageRecord)
 	if imageRecord.EditedImageS3Key != nil {
 		sourceS3Key = *imageRecord.EditedImageS3Key
 	}
@@ -259,8 +515,12 @@ func (s *ImageService) ProcessImage(ctx context.Context, imageID uuid.UUID, proc
 	imageRecord.ProcessingParams = processParams
 	now := time.Now()
 	imageRecord.StartedAt = &now
-	if err := s.deps.ImageRepository.Update(ctx, imageRecord); err != nil {
-		return fmt.Errorf("failed to update status to processing: %w", err)
+	if err := s.saveJobResult(ctx, imageRecord); err != nil {
+		return err
+	}
+
+	if processParams.Background != nil {
+		sourceS3Key = s.replaceSourceBackground(ctx, imageRecord, coupon, sourceS3Key, processParams)
 	}
 
 	if !processParams.UseAI {
@@ -268,6 +528,9 @@ func (s *ImageService) ProcessImage(ctx context.Context, imageID uuid.UUID, proc
 	}
 
 	if err := s.deps.StableDiffusionClient.CheckHealth(ctx); err != nil {
+		if stableDiffusion.IsOutage(ctx, err) {
+			return s.processWithoutAI(ctx, imageRecord, sourceS3Key, processParams, err)
+		}
 		log.Warn().
 			Err(err).
 			Str("image_id", imageID.String()).
@@ -312,6 +575,9 @@ func (s *ImageService) ProcessImage(ctx context.Context, imageID uuid.UUID, proc
 		Msg("Starting Stable Diffusion processing")
 
 	processedBase64, err := s.deps.StableDiffusionClient.ProcessImage(ctx, sdRequest)
+	if err != nil && stableDiffusion.IsOutage(ctx, err) {
+		return s.processWithoutAI(ctx, imageRecord, sourceS3Key, processParams, err)
+	}
 	if err != nil {
 		return s.markProcessingFailed(ctx, imageRecord, fmt.Errorf("stable diffusion processing failed: %w", err))
 	}
@@ -340,12 +606,18 @@ func (s *ImageService) ProcessImage(ctx context.Context, imageID uuid.UUID, proc
 		return s.markProcessingFailed(ctx, imageRecord, fmt.Errorf("failed to create preview: %w", err))
 	}
 
+	if jobCancelled(ctx) {
+		os.Remove(processedPath)
+		return ErrImageCancelled
+	}
+
 	processedKey := "file://" + processedPath
 	imageRecord.ProcessedImageS3Key = &processedKey
 	imageRecord.PreviewS3Key = &previewS3Key
 	imageRecord.Status = "processed"
-	if err := s.deps.ImageRepository.Update(ctx, imageRecord); err != nil {
-		return fmt.Errorf("failed to update image record: %w", err)
+	if err := s.saveJobResult(ctx, imageRecord); err != nil {
+		os.Remove(processedPath)
+		return err
 	}
 
 	log.Info().
@@ -354,102 +626,728 @@ func (s *ImageService) ProcessImage(ctx context.Context, imageID uuid.UUID, proc
 		Str("preview_s3_key", previewS3Key).
 		Msg("Image processed successfully")
 
+	s.enqueueUpscaling(imageID)
+
 	return nil
 }
 
-// GenerateSchema creates final diamond art schema
-func (s *ImageService) GenerateSchema(ctx context.Context, imageID uuid.UUID, confirmed bool) error {
-	if !confirmed {
-		return fmt.Errorf("schema generation not confirmed")
+// ResetInterruptedProcessing returns image left in processing status by lost worker
+// to the status it was processed from, so redelivered processing task can run again
+func (s *ImageService) ResetInterruptedProcessing(ctx context.Context, imageID uuid.UUID) error {
+	imageRecord, err := s.deps.ImageRepository.GetByID(ctx, imageID)
+	if err != nil {
+		return fmt.Errorf("image not found: %w", err)
+	}
+
+	if imageRecord.Status != "processing" {
+		return nil
+	}
+
+	imageRecord.Status = "uploaded"
+	if imageRecord.EditedImageS3Key != nil {
+		imageRecord.Status = "edited"
+	}
+	imageRecord.StartedAt = nil
+	if err := s.deps.ImageRepository.Update(ctx, imageRecord); err != nil {
+		return fmt.Errorf("failed to reset interrupted processing: %w", err)
 	}
 
+	log.Warn().
+		Str("image_id", imageID.String()).
+		Str("status", imageRecord.Status).
+		Msg("Interrupted image processing reset")
+
+	return nil
+}
+
+// OptimizeImage re-encodes original image as JPEG with given quality and stores it in S3.
+// Optimized copy outlives local working files and replaces original when they are cleaned up.
+func (s *ImageService) OptimizeImage(ctx context.Context, imageID uuid.UUID, quality int) error {
 	imageRecord, err := s.deps.ImageRepository.GetByID(ctx, imageID)
 	if err != nil {
 		return fmt.Errorf("image not found: %w", err)
 	}
 
-	if imageRecord.Status != "processed" {
-		return fmt.Errorf("image must be processed before generating schema")
+	if quality <= 0 {
+		quality = s.deps.OptimizeQuality
 	}
 
-	sourceS3Key := imageRecord.OriginalImageS3Key
-	if imageRecord.ProcessedImageS3Key != nil {
-		sourceS3Key = *imageRecord.ProcessedImageS3Key
-	} else if imageRecord.EditedImageS3Key != nil {
-		sourceS3Key = *imageRecord.EditedImageS3Key
+	img, sourceSize, err := s.decodeFromStorage(ctx, imageRecord.OriginalImageS3Key)
+	if err != nil {
+		return fmt.Errorf("failed to read original image: %w", err)
 	}
 
-	schemaS3Key, err := s.createSchemaZipArchive(ctx, imageRecord, sourceS3Key)
+	encoded, err := thumbnail.EncodeJPEG(thumbnail.Fit(img, optimizedMaxSide), quality)
 	if err != nil {
-		return s.markProcessingFailed(ctx, imageRecord, fmt.Errorf("failed to create schema ZIP archive: %w", err))
+		return err
 	}
 
-	imageRecord.SchemaS3Key = &schemaS3Key
-	imageRecord.Status = "completed"
-	now := time.Now()
-	imageRecord.CompletedAt = &now
-	if err := s.deps.ImageRepository.Update(ctx, imageRecord); err != nil {
-		return fmt.Errorf("failed to update image record: %w", err)
+	key := fmt.Sprintf("optimized/%s/%s%s", imageRecord.CouponID, imageRecord.ID, encoded.Extension)
+	uploadedKey, err := s.deps.S3Client.UploadFileWithKey(ctx, bytes.NewReader(encoded.Data), int64(len(encoded.Data)), encoded.ContentType, key)
+	if err != nil {
+		return fmt.Errorf("failed to upload optimized image: %w", err)
 	}
 
-	coupon, err := s.deps.CouponRepository.GetByID(ctx, imageRecord.CouponID)
-	if err == nil && coupon != nil {
-		coupon.Status = "completed"
-		coupon.CompletedAt = &now
-		if err := s.deps.CouponRepository.Update(ctx, coupon); err != nil {
-			log.Error().Err(err).Str("coupon_id", imageRecord.CouponID.String()).Msg("Failed to update coupon status to completed after schema generation")
-		}
+	imageRecord.OptimizedImageS3Key = &uploadedKey
+	if err := s.deps.ImageRepository.UpdateColumns(ctx, imageRecord, "optimized_image_s3_key"); err != nil {
+		return fmt.Errorf("failed to save optimized image key: %w", err)
 	}
 
 	log.Info().
 		Str("image_id", imageID.String()).
-		Str("schema_s3_key", schemaS3Key).
-		Msg("Schema generated successfully")
-
-	s.cleanupLocalFilesAsync(imageRecord.CouponID)
-
-	s.sendSchemaEmailAsync(imageRecord, schemaS3Key)
+		Str("s3_key", uploadedKey).
+		Int("source_bytes", sourceSize).
+		Int("optimized_bytes", len(encoded.Data)).
+		Msg("Image optimized")
 
 	return nil
 }
 
-// GetImageStatus returns image processing status
-func (s *ImageService) GetImageStatus(ctx context.Context, imageID uuid.UUID) (*types.ImageStatusResponse, error) {
+// GenerateThumbnails creates thumbnails of original image with given longest sides in pixels
+// and stores them in S3, configured sizes are used when none given
+func (s *ImageService) GenerateThumbnails(ctx context.Context, imageID uuid.UUID, sizes []string) error {
 	imageRecord, err := s.deps.ImageRepository.GetByID(ctx, imageID)
 	if err != nil {
-		return nil, fmt.Errorf("image not found: %w", err)
+		return fmt.Errorf("image not found: %w", err)
 	}
 
-	response := &types.ImageStatusResponse{
-		ImageID:  imageRecord.ID,
-		Status:   imageRecord.Status,
-		Message:  s.getStatusMessage(imageRecord.Status),
-		Progress: s.calculateProgress(imageRecord.Status),
+	if len(sizes) == 0 {
+		sizes = s.deps.ThumbnailSizes
+	}
+	if len(sizes) == 0 {
+		sizes = thumbnail.DefaultSizes
+	}
+	pixelSizes, err := thumbnail.ParseSizes(sizes)
+	if err != nil {
+		return err
 	}
 
-	// Add error if exists
-	if imageRecord.ErrorMessage != nil {
-		response.ErrorMessage = imageRecord.ErrorMessage
+	img, _, err := s.decodeFromStorage(ctx, s.originalImageKey(imageRecord))
+	if err != nil {
+		return fmt.Errorf("failed to read original image: %w", err)
 	}
 
-	// Generate URLs/paths for file access
-	if strings.HasPrefix(imageRecord.OriginalImageS3Key, "file://") {
-		path := strings.TrimPrefix(imageRecord.OriginalImageS3Key, "file://")
-		if u, err := s.buildDataURLFromLocalPath(path); err == nil {
-			response.OriginalURL = u
-		}
-	} else if url, err := s.deps.S3Client.GetFileURL(ctx, imageRecord.OriginalImageS3Key, 24*time.Hour); err == nil {
-		response.OriginalURL = &url
+	encoder := thumbnail.NewEncoder(s.deps.ThumbnailFormat, s.deps.ThumbnailQuality)
+	thumbnails := make(map[string]string, len(imageRecord.Thumbnails)+len(pixelSizes))
+	for size, key := range imageRecord.Thumbnails {
+		thumbnails[size] = key
 	}
 
-	if imageRecord.EditedImageS3Key != nil {
-		if strings.HasPrefix(*imageRecord.EditedImageS3Key, "file://") {
-			path := strings.TrimPrefix(*imageRecord.EditedImageS3Key, "file://")
-			if u, err := s.buildDataURLFromLocalPath(path); err == nil {
-				response.EditedURL = u
-			}
-		} else if url, err := s.deps.S3Client.GetFileURL(ctx, *imageRecord.EditedImageS3Key, 24*time.Hour); err == nil {
-			response.EditedURL = &url
+	for _, size := range pixelSizes {
+		encoded, err := encoder.Encode(ctx, thumbnail.Fit(img, size))
+		if err != nil {
+			return fmt.Errorf("failed to encode %dpx thumbnail: %w", size, err)
+		}
+
+		key := fmt.Sprintf("thumbnails/%s/%s/%d%s", imageRecord.CouponID, imageRecord.ID, size, encoded.Extension)
+		uploadedKey, err := s.deps.S3Client.UploadFileWithKey(ctx, bytes.NewReader(encoded.Data), int64(len(encoded.Data)), encoded.ContentType, key)
+		if err != nil {
+			return fmt.Errorf("failed to upload %dpx thumbnail: %w", size, err)
+		}
+		thumbnails[strconv.Itoa(size)] = uploadedKey
+	}
+
+	imageRecord.Thumbnails = thumbnails
+	if err := s.deps.ImageRepository.UpdateColumns(ctx, imageRecord, "thumbnails"); err != nil {
+		return fmt.Errorf("failed to save thumbnail keys: %w", err)
+	}
+
+	log.Info().
+		Str("image_id", imageID.String()).
+		Strs("sizes", sizes).
+		Str("format", encoder.Format).
+		Msg("Thumbnails generated")
+
+	return nil
+}
+
+// UpscaleImage prepares processed image for schema generation. When image has too few pixels per stone
+// for coupon size it is upscaled by upscaler of AI server, or by plain Lanczos resize when AI server is unavailable.
+func (s *ImageService) UpscaleImage(ctx context.Context, imageID uuid.UUID) error {
+	imageRecord, err := s.deps.ImageRepository.GetByID(ctx, imageID)
+	if err != nil {
+		return fmt.Errorf("image not found: %w", err)
+	}
+
+	if imageRecord.Status == "cancelled" {
+		return ErrImageCancelled
+	}
+	if imageRecord.Status != "processed" {
+		log.Info().
+			Str("image_id", imageID.String()).
+			Str("status", imageRecord.Status).
+			Msg("Image is not waiting for schema generation, upscaling skipped")
+		return nil
+	}
+
+	return s.upscaleSource(ctx, imageRecord, s.schemaSourceKey(imageRecord))
+}
+
+// upscaleSource analyzes image schema is generated from and stores its upscaled copy when needed.
+// Nothing is done when image was already analyzed.
+func (s *ImageService) upscaleSource(ctx context.Context, imageRecord *Image, sourceS3Key string) error {
+	if imageRecord.Upscale != nil && imageRecord.Upscale.SourceS3Key == sourceS3Key {
+		return nil
+	}
+
+	coupon, err := s.deps.CouponRepository.GetByID(ctx, imageRecord.CouponID)
+	if err != nil {
+		return fmt.Errorf("failed to get coupon: %w", err)
+	}
+
+	reader, err := s.openFromStorage(ctx, sourceS3Key)
+	if err != nil {
+		return fmt.Errorf("failed to download source image: %w", err)
+	}
+	sourceData, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read source image: %w", err)
+	}
+	img, _, err := image.Decode(bytes.NewReader(sourceData))
+	if err != nil {
+		return fmt.Errorf("failed to decode source image: %w", err)
+	}
+
+	stonesX, stonesY := stoneGrid(coupon)
+	info := &UpscaleInfo{
+		SourceS3Key:          sourceS3Key,
+		Method:               UpscaleMethodNone,
+		BeforeWidth:          img.Bounds().Dx(),
+		BeforeHeight:         img.Bounds().Dy(),
+		PixelsPerStoneBefore: pixelsPerStone(img.Bounds().Dx(), img.Bounds().Dy(), stonesX, stonesY),
+		UpscaledAt:           time.Now(),
+	}
+	info.AfterWidth, info.AfterHeight = info.BeforeWidth, info.BeforeHeight
+	info.PixelsPerStoneAfter = info.PixelsPerStoneBefore
+
+	var upscaledKey *string
+	if info.PixelsPerStoneBefore < s.deps.MinPixelsPerStone {
+		info.Scale = min(math.Ceil(s.deps.TargetPixelsPerStone/info.PixelsPerStoneBefore), maxUpscaleFactor)
+
+		upscaled, err := s.upscaleWithAI(ctx, sourceData, info.Scale)
+		if err == nil {
+			info.Method = UpscaleMethodAI
+		} else {
+			log.Warn().
+				Err(err).
+				Str("image_id", imageRecord.ID.String()).
+				Msg("AI upscaling unavailable, upscaling image with Lanczos resize")
+			info.Method = UpscaleMethodLanczos
+			info.AIError = err.Error()
+			upscaled = imaging.Resize(img, int(math.Round(float64(info.BeforeWidth)*info.Scale)), int(math.Round(float64(info.BeforeHeight)*info.Scale)), imaging.Lanczos)
+		}
+
+		info.AfterWidth, info.AfterHeight = upscaled.Bounds().Dx(), upscaled.Bounds().Dy()
+		info.PixelsPerStoneAfter = pixelsPerStone(info.AfterWidth, info.AfterHeight, stonesX, stonesY)
+
+		encoded, err := thumbnail.EncodeJPEG(upscaled, s.deps.OptimizeQuality)
+		if err != nil {
+			return err
+		}
+
+		key := fmt.Sprintf("upscaled/%s/%s%s", imageRecord.CouponID, imageRecord.ID, encoded.Extension)
+		uploadedKey, err := s.deps.S3Client.UploadFileWithKey(ctx, bytes.NewReader(encoded.Data), int64(len(encoded.Data)), encoded.ContentType, key)
+		if err != nil {
+			return fmt.Errorf("failed to upload upscaled image: %w", err)
+		}
+		upscaledKey = &uploadedKey
+	}
+
+	imageRecord.UpscaledImageS3Key = upscaledKey
+	imageRecord.Upscale = info
+	if err := s.deps.ImageRepository.UpdateColumns(ctx, imageRecord, "upscaled_image_s3_key", "upscale"); err != nil {
+		return fmt.Errorf("failed to save upscaled image: %w", err)
+	}
+
+	log.Info().
+		Str("image_id", imageRecord.ID.String()).
+		Str("method", info.Method).
+		Float64("scale", info.Scale).
+		Float64("pixels_per_stone_before", info.PixelsPerStoneBefore).
+		Float64("pixels_per_stone_after", info.PixelsPerStoneAfter).
+		Msg("Image analyzed for schema generation")
+
+	return nil
+}
+
+// upscaleWithAI enlarges image by upscaler of AI server
+func (s *ImageService) upscaleWithAI(ctx context.Context, data []byte, scale float64) (image.Image, error) {
+	if s.deps.StableDiffusionClient == nil {
+		return nil, errors.New("AI client is not configured")
+	}
+
+	result, err := s.deps.StableDiffusionClient.Upscale(ctx, s.deps.StableDiffusionClient.EncodeImageToBase64(data), scale)
+	if err != nil {
+		return nil, err
+	}
+	resultData, err := s.deps.StableDiffusionClient.DecodeBase64Image(result)
+	if err != nil {
+		return nil, err
+	}
+	img, _, err := image.Decode(bytes.NewReader(resultData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode upscaled image: %w", err)
+	}
+	return img, nil
+}
+
+// claimUpscaling takes upscaling over from upscaling task of image before schema generation upscales it.
+// Task waiting in queue is removed, task already run by worker is waited for and its result is loaded.
+func (s *ImageService) claimUpscaling(ctx context.Context, imageRecord *Image) error {
+	if s.deps.UpscaleQueue == nil {
+		return nil
+	}
+
+	waited := false
+	for {
+		claimed, err := s.deps.UpscaleQueue.ClaimImageUpscaling(imageRecord.ID)
+		if err != nil {
+			log.Warn().
+				Err(err).
+				Str("image_id", imageRecord.ID.String()).
+				Msg("Failed to claim image upscaling, upscaling image in schema generation")
+			break
+		}
+		if claimed {
+			break
+		}
+
+		if !waited {
+			log.Info().
+				Str("image_id", imageRecord.ID.String()).
+				Msg("Image is being upscaled, schema generation waits for upscaling task")
+			waited = true
+		}
+		select {
+		case <-ctx.Done():
+			return context.Cause(ctx)
+		case <-time.After(upscaleWaitInterval):
+		}
+	}
+
+	if !waited {
+		return nil
+	}
+	upscaled, err := s.deps.ImageRepository.GetByID(ctx, imageRecord.ID)
+	if err != nil {
+		return fmt.Errorf("failed to reload upscaled image: %w", err)
+	}
+	imageRecord.UpscaledImageS3Key = upscaled.UpscaledImageS3Key
+	imageRecord.Upscale = upscaled.Upscale
+	return nil
+}
+
+// enqueueUpscaling schedules upscaling of processed image ahead of schema generation, which claims
+// upscaling from task when it is confirmed before task is finished
+func (s *ImageService) enqueueUpscaling(imageID uuid.UUID) {
+	if !s.deps.UpscaleEnabled || s.deps.UpscaleQueue == nil {
+		return
+	}
+	if _, err := s.deps.UpscaleQueue.EnqueueImageUpscaling(imageID); err != nil {
+		log.Error().Err(err).Str("image_id", imageID.String()).Msg("Failed to enqueue image upscaling")
+	}
+}
+
+// RenderMockups draws finished kit of completed image, puts it in frame onto configured scenes
+// and stores renders in S3
+func (s *ImageService) RenderMockups(ctx context.Context, imageID uuid.UUID) error {
+	imageRecord, err := s.deps.ImageRepository.GetByID(ctx, imageID)
+	if err != nil {
+		return fmt.Errorf("image not found: %w", err)
+	}
+
+	if imageRecord.Status != "completed" {
+		log.Info().
+			Str("image_id", imageID.String()).
+			Str("status", imageRecord.Status).
+			Msg("Image schema is not completed, mockup rendering skipped")
+		return nil
+	}
+	if len(s.deps.MockupScenes) == 0 {
+		return nil
+	}
+
+	coupon, err := s.deps.CouponRepository.GetByID(ctx, imageRecord.CouponID)
+	if err != nil {
+		return fmt.Errorf("failed to get coupon: %w", err)
+	}
+
+	sourceS3Key := s.schemaSourceKey(imageRecord)
+	if imageRecord.UpscaledImageS3Key != nil {
+		sourceS3Key = *imageRecord.UpscaledImageS3Key
+	}
+	img, _, err := s.decodeFromStorage(ctx, sourceS3Key)
+	if err != nil {
+		return fmt.Errorf("failed to read source image: %w", err)
+	}
+
+	artwork, err := s.simulateKit(img, coupon.ProductType, coupon.Style, coupon.Size, mockupSimulationSide)
+	if err != nil {
+		return err
+	}
+
+	mockups := make(map[string]string, len(s.deps.MockupScenes))
+	// Completed image can not be cancelled, so rendering is not registered as cancellable job
+	for _, scene := range s.deps.MockupScenes {
+		rendered, err := mockup.Render(artwork, scene)
+		if err != nil {
+			return fmt.Errorf("failed to render %s mockup: %w", scene.Name, err)
+		}
+		encoded, err := thumbnail.EncodeJPEG(rendered, s.deps.OptimizeQuality)
+		if err != nil {
+			return err
+		}
+
+		key := fmt.Sprintf("mockups/%s/%s/%s%s", imageRecord.CouponID, imageRecord.ID, scene.Name, encoded.Extension)
+		uploadedKey, err := s.deps.S3Client.UploadFileWithKey(ctx, bytes.NewReader(encoded.Data), int64(len(encoded.Data)), encoded.ContentType, key)
+		if err != nil {
+			return fmt.Errorf("failed to upload %s mockup: %w", scene.Name, err)
+		}
+		mockups[scene.Name] = uploadedKey
+	}
+
+	imageRecord.Mockups = mockups
+	if err := s.deps.ImageRepository.UpdateColumns(ctx, imageRecord, "mockups"); err != nil {
+		return fmt.Errorf("failed to save mockup keys: %w", err)
+	}
+
+	log.Info().
+		Str("image_id", imageID.String()).
+		Int("scenes", len(mockups)).
+		Msg("Mockups rendered")
+
+	return nil
+}
+
+// enqueueMockupRendering schedules rendering of mockups of completed image
+func (s *ImageService) enqueueMockupRendering(imageID uuid.UUID) {
+	if !s.deps.MockupEnabled || s.deps.MockupQueue == nil {
+		return
+	}
+	if _, err := s.deps.MockupQueue.EnqueueMockupRendering(imageID); err != nil {
+		log.Error().Err(err).Str("image_id", imageID.String()).Msg("Failed to enqueue mockup rendering")
+	}
+}
+
+// schemaSourceKey returns key of image schema is generated from
+func (s *ImageService) schemaSourceKey(imageRecord *Image) string {
+	if imageRecord.ProcessedImageS3Key != nil {
+		return *imageRecord.ProcessedImageS3Key
+	}
+	if imageRecord.EditedImageS3Key != nil {
+		return *imageRecord.EditedImageS3Key
+	}
+	return s.originalImageKey(imageRecord)
+}
+
+// originalImageKey returns key of original image, optimized copy is used once local original is cleaned up
+func (s *ImageService) originalImageKey(imageRecord *Image) string {
+	if imageRecord.OptimizedImageS3Key == nil || !strings.HasPrefix(imageRecord.OriginalImageS3Key, "file://") {
+		return imageRecord.OriginalImageS3Key
+	}
+	if _, err := os.Stat(strings.TrimPrefix(imageRecord.OriginalImageS3Key, "file://")); err != nil {
+		return *imageRecord.OptimizedImageS3Key
+	}
+	return imageRecord.OriginalImageS3Key
+}
+
+// decodeFromStorage reads and decodes image, returns it with size of encoded file
+func (s *ImageService) decodeFromStorage(ctx context.Context, key string) (image.Image, int, error) {
+	reader, err := s.openFromStorage(ctx, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to read image: %w", err)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+	return img, len(data), nil
+}
+
+// GenerateSchema creates final diamond art schema
+func (s *ImageService) GenerateSchema(ctx context.Context, imageID uuid.UUID, confirmed bool) error {
+	if !confirmed {
+		return fmt.Errorf("schema generation not confirmed")
+	}
+
+	imageRecord, err := s.deps.ImageRepository.GetByID(ctx, imageID)
+	if err != nil {
+		return fmt.Errorf("image not found: %w", err)
+	}
+
+	if imageRecord.Status == "cancelled" {
+		return ErrImageCancelled
+	}
+	if imageRecord.Status != "processed" {
+		return fmt.Errorf("image must be processed before generating schema")
+	}
+
+	ctx, finish := s.startJob(ctx, imageID)
+	defer finish()
+
+	sourceS3Key := s.schemaSourceKey(imageRecord)
+	if s.deps.UpscaleEnabled {
+		if err := s.claimUpscaling(ctx, imageRecord); err != nil {
+			if jobCancelled(ctx) {
+				return ErrImageCancelled
+			}
+			return err
+		}
+		if err := s.upscaleSource(ctx, imageRecord, sourceS3Key); err != nil {
+			log.Warn().
+				Err(err).
+				Str("image_id", imageID.String()).
+				Msg("Failed to upscale image, generating schema from image as is")
+		} else if imageRecord.UpscaledImageS3Key != nil {
+			sourceS3Key = *imageRecord.UpscaledImageS3Key
+		}
+	}
+
+	schemaS3Key, err := s.createSchemaZipArchive(ctx, imageRecord, sourceS3Key)
+	if err != nil {
+		return s.markProcessingFailed(ctx, imageRecord, fmt.Errorf("failed to create schema ZIP archive: %w", err))
+	}
+
+	if jobCancelled(ctx) {
+		return ErrImageCancelled
+	}
+
+	imageRecord.SchemaS3Key = &schemaS3Key
+	imageRecord.Status = "completed"
+	now := time.Now()
+	imageRecord.CompletedAt = &now
+	if err := s.saveJobResult(ctx, imageRecord); err != nil {
+		return err
+	}
+
+	coupon, err := s.deps.CouponRepository.GetByID(ctx, imageRecord.CouponID)
+	if err == nil && coupon != nil {
+		coupon.Status = "completed"
+		coupon.CompletedAt = &now
+		if err := s.deps.CouponRepository.Update(ctx, coupon); err != nil {
+			log.Error().Err(err).Str("coupon_id", imageRecord.CouponID.String()).Msg("Failed to update coupon status to completed after schema generation")
+		}
+	}
+
+	log.Info().
+		Str("image_id", imageID.String()).
+		Str("schema_s3_key", schemaS3Key).
+		Msg("Schema generated successfully")
+
+	s.cleanupLocalFilesAsync(imageRecord.CouponID)
+
+	s.sendSchemaEmailAsync(imageRecord, schemaS3Key)
+
+	s.enqueueMockupRendering(imageID)
+
+	return nil
+}
+
+// GenerateWithStyle processes image with given style without AI and generates its schema right away,
+// skipping preview confirmation. Processing is skipped when image was already processed by previous attempt.
+func (s *ImageService) GenerateWithStyle(ctx context.Context, imageID uuid.UUID, style string) error {
+	imageRecord, err := s.deps.ImageRepository.GetByID(ctx, imageID)
+	if err != nil {
+		return fmt.Errorf("image not found: %w", err)
+	}
+
+	if imageRecord.Status != "processed" {
+		if err := s.ProcessImage(ctx, imageID, &ProcessingParams{Style: style}); err != nil {
+			return err
+		}
+	}
+
+	return s.GenerateSchema(ctx, imageID, true)
+}
+
+// CancelImage cancels processing and schema generation of image. Tasks still waiting in queue are removed,
+// running generation is interrupted, generator subprocess is killed and its temporary files are removed.
+// Generation running in another process notices cancellation within cancelPollInterval.
+func (s *ImageService) CancelImage(ctx context.Context, imageID uuid.UUID) (*Image, error) {
+	imageRecord, err := s.deps.ImageRepository.GetByID(ctx, imageID)
+	if err != nil {
+		return nil, fmt.Errorf("image not found: %w", err)
+	}
+
+	if imageRecord.Status == "completed" || imageRecord.Status == "cancelled" {
+		return nil, ErrImageNotCancellable
+	}
+
+	previousStatus := imageRecord.Status
+	imageRecord.Status = "cancelled"
+	if err := s.deps.ImageRepository.UpdateColumns(ctx, imageRecord, "status"); err != nil {
+		return nil, fmt.Errorf("failed to update status to cancelled: %w", err)
+	}
+
+	running := s.cancelJob(imageID)
+
+	removed := 0
+	if s.deps.TaskCanceller != nil {
+		removed, err = s.deps.TaskCanceller.CancelImageTasks(imageID)
+		if err != nil {
+			log.Error().Err(err).Str("image_id", imageID.String()).Msg("Failed to remove queued tasks of cancelled image")
+		}
+	}
+
+	log.Info().
+		Str("image_id", imageID.String()).
+		Str("previous_status", previousStatus).
+		Bool("running", running).
+		Int("removed_tasks", removed).
+		Msg("Image processing cancelled")
+
+	return imageRecord, nil
+}
+
+// ResumeImage returns cancelled image to the status it is processed from, so it can be edited or
+// processed again. Results of cancelled processing and schema generation are kept until they are replaced.
+func (s *ImageService) ResumeImage(ctx context.Context, imageID uuid.UUID) (*Image, error) {
+	imageRecord, err := s.deps.ImageRepository.GetByID(ctx, imageID)
+	if err != nil {
+		return nil, fmt.Errorf("image not found: %w", err)
+	}
+
+	if imageRecord.Status != "cancelled" {
+		return nil, ErrImageNotCancelled
+	}
+
+	imageRecord.Status = "uploaded"
+	if imageRecord.EditedImageS3Key != nil {
+		imageRecord.Status = "edited"
+	}
+	imageRecord.StartedAt = nil
+	if err := s.deps.ImageRepository.UpdateColumns(ctx, imageRecord, "status", "started_at"); err != nil {
+		return nil, fmt.Errorf("failed to resume cancelled image: %w", err)
+	}
+
+	log.Info().
+		Str("image_id", imageID.String()).
+		Str("status", imageRecord.Status).
+		Msg("Cancelled image resumed")
+
+	return imageRecord, nil
+}
+
+// startJob registers processing of image running in this process so CancelImage can interrupt it,
+// and watches image status to notice cancellation made by another process. Returned function must be
+// called when job ends.
+func (s *ImageService) startJob(ctx context.Context, imageID uuid.UUID) (context.Context, func()) {
+	jobCtx, cancel := context.WithCancelCause(ctx)
+
+	s.jobsMu.Lock()
+	if s.jobs == nil {
+		s.jobs = make(map[uuid.UUID]context.CancelCauseFunc)
+	}
+	s.jobs[imageID] = cancel
+	s.jobsMu.Unlock()
+
+	go func() {
+		ticker := time.NewTicker(cancelPollInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-jobCtx.Done():
+				return
+			case <-ticker.C:
+				imageRecord, err := s.deps.ImageRepository.GetByID(jobCtx, imageID)
+				if err == nil && imageRecord.Status == "cancelled" {
+					cancel(ErrImageCancelled)
+					return
+				}
+			}
+		}
+	}()
+
+	return jobCtx, func() {
+		s.jobsMu.Lock()
+		delete(s.jobs, imageID)
+		s.jobsMu.Unlock()
+		cancel(nil)
+	}
+}
+
+// cancelJob interrupts processing of image running in this process, reports whether there was any
+func (s *ImageService) cancelJob(imageID uuid.UUID) bool {
+	s.jobsMu.Lock()
+	cancel, ok := s.jobs[imageID]
+	s.jobsMu.Unlock()
+
+	if ok {
+		cancel(ErrImageCancelled)
+	}
+	return ok
+}
+
+// saveJobResult saves status written by processing or schema generation. Image cancelled meanwhile,
+// also by another process before this one noticed it, keeps its status and ErrImageCancelled is returned.
+func (s *ImageService) saveJobResult(ctx context.Context, imageRecord *Image) error {
+	saved, err := s.deps.ImageRepository.UpdateUnlessCancelled(ctx, imageRecord)
+	if err != nil {
+		return fmt.Errorf("failed to update image record: %w", err)
+	}
+	if !saved {
+		log.Info().
+			Str("image_id", imageRecord.ID.String()).
+			Str("status", imageRecord.Status).
+			Msg("Image was cancelled, job result discarded")
+		imageRecord.Status = "cancelled"
+		return ErrImageCancelled
+	}
+	return nil
+}
+
+// jobCancelled reports whether job was interrupted because its image was cancelled
+func jobCancelled(ctx context.Context) bool {
+	return errors.Is(context.Cause(ctx), ErrImageCancelled)
+}
+
+// GetImageStatus returns image processing status
+func (s *ImageService) GetImageStatus(ctx context.Context, imageID uuid.UUID) (*types.ImageStatusResponse, error) {
+	imageRecord, err := s.deps.ImageRepository.GetByID(ctx, imageID)
+	if err != nil {
+		return nil, fmt.Errorf("image not found: %w", err)
+	}
+
+	response := &types.ImageStatusResponse{
+		ImageID:  imageRecord.ID,
+		Status:   imageRecord.Status,
+		Message:  s.getStatusMessage(imageRecord.Status),
+		Progress: s.calculateProgress(imageRecord.Status),
+	}
+
+	// Add error if exists
+	if imageRecord.ErrorMessage != nil {
+		response.ErrorMessage = imageRecord.ErrorMessage
+	}
+
+	// Generate URLs/paths for file access
+	originalKey := s.originalImageKey(imageRecord)
+	if strings.HasPrefix(originalKey, "file://") {
+		path := strings.TrimPrefix(originalKey, "file://")
+		if u, err := s.buildDataURLFromLocalPath(path); err == nil {
+			response.OriginalURL = u
+		}
+	} else if url, err := s.deps.S3Client.GetFileURL(ctx, originalKey, 24*time.Hour); err == nil {
+		response.OriginalURL = &url
+	}
+
+	if imageRecord.EditedImageS3Key != nil {
+		if strings.HasPrefix(*imageRecord.EditedImageS3Key, "file://") {
+			path := strings.TrimPrefix(*imageRecord.EditedImageS3Key, "file://")
+			if u, err := s.buildDataURLFromLocalPath(path); err == nil {
+				response.EditedURL = u
+			}
+		} else if url, err := s.deps.S3Client.GetFileURL(ctx, *imageRecord.EditedImageS3Key, 24*time.Hour); err == nil {
+			response.EditedURL = &url
 		}
 	}
 
@@ -464,51 +1362,326 @@ func (s *ImageService) GetImageStatus(ctx context.Context, imageID uuid.UUID) (*
 		}
 	}
 
-	if imageRecord.PreviewS3Key != nil {
-		if strings.HasPrefix(*imageRecord.PreviewS3Key, "file://") {
-			path := strings.TrimPrefix(*imageRecord.PreviewS3Key, "file://")
-			if u, err := s.buildDataURLFromLocalPath(path); err == nil {
-				response.PreviewURL = u
+	if imageRecord.PreviewS3Key != nil {
+		if strings.HasPrefix(*imageRecord.PreviewS3Key, "file://") {
+			path := strings.TrimPrefix(*imageRecord.PreviewS3Key, "file://")
+			if u, err := s.buildDataURLFromLocalPath(path); err == nil {
+				response.PreviewURL = u
+			}
+		} else if url, err := s.deps.S3Client.GetFileURL(ctx, *imageRecord.PreviewS3Key, 24*time.Hour); err == nil {
+			response.PreviewURL = &url
+		}
+	}
+
+	if imageRecord.SchemaS3Key != nil {
+		if url, err := s.deps.S3Client.GetFileURL(ctx, *imageRecord.SchemaS3Key, 24*time.Hour); err == nil {
+			response.ZipURL = &url
+		}
+	}
+
+	if len(imageRecord.Thumbnails) > 0 {
+		response.ThumbnailURLs = make(map[string]string, len(imageRecord.Thumbnails))
+		for size, key := range imageRecord.Thumbnails {
+			if url, err := s.deps.S3Client.GetFileURL(ctx, key, 24*time.Hour); err == nil {
+				response.ThumbnailURLs[size] = url
+			}
+		}
+	}
+
+	if len(imageRecord.Mockups) > 0 {
+		response.MockupURLs = make(map[string]string, len(imageRecord.Mockups))
+		for scene, key := range imageRecord.Mockups {
+			if url, err := s.deps.S3Client.GetFileURL(ctx, key, 24*time.Hour); err == nil {
+				response.MockupURLs[scene] = url
+			}
+		}
+	}
+
+	return response, nil
+}
+
+func (s *ImageService) applyImageEditing(img image.Image, params ImageEditParams) image.Image {
+	// Apply scaling
+	if params.Scale != 1.0 {
+		newWidth := int(float64(img.Bounds().Dx()) * params.Scale)
+		newHeight := int(float64(img.Bounds().Dy()) * params.Scale)
+		img = imaging.Resize(img, newWidth, newHeight, imaging.Lanczos)
+	}
+
+	// Apply rotation
+	switch params.Rotation {
+	case 90:
+		img = imaging.Rotate90(img)
+	case 180:
+		img = imaging.Rotate180(img)
+	case 270:
+		img = imaging.Rotate270(img)
+	}
+
+	// Apply cropping
+	if params.CropWidth > 0 && params.CropHeight > 0 {
+		cropRect := image.Rect(params.CropX, params.CropY, params.CropX+params.CropWidth, params.CropY+params.CropHeight)
+		img = imaging.Crop(img, cropRect)
+	}
+
+	return img
+}
+
+// ApplyTextOverlays renders texts onto image with colors taken from active palette of product and coupon style
+func (s *ImageService) ApplyTextOverlays(img image.Image, overlays []TextOverlay, productType, couponStyle string) (image.Image, error) {
+	if len(overlays) == 0 {
+		return img, nil
+	}
+
+	colors, err := s.textPaletteColors(productType, couponStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	texts := make([]textOverlay.Text, 0, len(overlays))
+	for _, overlay := range overlays {
+		textColor, err := resolveTextColor(overlay.ColorCode, colors)
+		if err != nil {
+			return nil, err
+		}
+		texts = append(texts, textOverlay.Text{
+			Text:        overlay.Text,
+			Font:        textOverlay.Font(overlay.Font),
+			Position:    textOverlay.Position(overlay.Position),
+			SizePercent: overlay.Size,
+			Color:       textColor,
+		})
+	}
+
+	return textOverlay.Draw(img, texts...)
+}
+
+// SimulateStyle renders fast preview of finished kit: image is quantized to active palette of product and coupon
+// style at stone grid of coupon size and drawn as stones, cells or beads
+func (s *ImageService) SimulateStyle(img image.Image, productType, couponStyle, size string) (image.Image, error) {
+	return s.simulateKit(img, productType, couponStyle, size, 0)
+}
+
+// simulateKit renders finished kit with given longest side, default side of preview is used when zero
+func (s *ImageService) simulateKit(img image.Image, productType, couponStyle, size string, side int) (image.Image, error) {
+	colors, err := s.kitPaletteColors(productType, couponStyle)
+	if err != nil {
+		return nil, err
+	}
+
+	rgba := make([]color.RGBA, 0, len(colors))
+	for _, c := range colors {
+		rgba = append(rgba, c.RGBA)
+	}
+
+	stonesX, stonesY := stoneGrid(&Coupon{Size: size, ProductType: productType})
+	simulated, err := mosaic.Simulate(img, mosaic.SimulationRequest{
+		ProductType: productType,
+		StonesX:     stonesX,
+		StonesY:     stonesY,
+		Colors:      rgba,
+		Side:        side,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to simulate %s preview: %w", couponStyle, err)
+	}
+	return simulated, nil
+}
+
+// kitPaletteColors returns colors kit of product is generated from. Diamond kits use drill palette
+// from xlsx file of generator script, other products use the same palettes as text overlays.
+func (s *ImageService) kitPaletteColors(productType, couponStyle string) ([]paletteColor, error) {
+	if productType != "" && productType != mosaic.ProductDiamondMosaic {
+		return s.textPaletteColors(productType, couponStyle)
+	}
+
+	drills, err := s.deps.PaletteService.GetDiamondPalette(s.mapCouponStyleToPaletteStyle(couponStyle))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get diamond palette: %w", err)
+	}
+	colors := make([]paletteColor, 0, len(drills))
+	for _, drill := range drills {
+		colors = append(colors, paletteColor{Code: drill.Code, RGBA: drill.RGBA()})
+	}
+	return colors, nil
+}
+
+// textPaletteColors returns colors of active palette by code. Diamond palettes are stored in xlsx files used by
+// generator script, so diamond kits use built-in paint set of the same style which approximates them
+func (s *ImageService) textPaletteColors(productType, couponStyle string) ([]paletteColor, error) {
+	paletteStyle := s.mapCouponStyleToPaletteStyle(couponStyle)
+
+	if productType == mosaic.ProductFuseBeads {
+		beads, err := s.deps.PaletteService.GetBeadPalette(palette.BeadBrand(s.deps.BeadBrand), paletteStyle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bead palette: %w", err)
+		}
+		colors := make([]paletteColor, 0, len(beads))
+		for _, bead := range beads {
+			colors = append(colors, paletteColor{Code: bead.Code, RGBA: bead.RGBA()})
+		}
+		return colors, nil
+	}
+
+	paints, err := s.deps.PaletteService.GetPaintPalette(paletteStyle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paint palette: %w", err)
+	}
+	colors := make([]paletteColor, 0, len(paints))
+	for _, paint := range paints {
+		colors = append(colors, paletteColor{Code: paint.Code, RGBA: paint.RGBA()})
+	}
+	return colors, nil
+}
+
+// paletteColor is palette color available for text overlays and backgrounds
+type paletteColor struct {
+	Code string
+	RGBA color.RGBA
+}
+
+// resolveTextColor finds palette color by code, empty code means darkest palette color
+func resolveTextColor(code string, colors []paletteColor) (color.RGBA, error) {
+	if len(colors) == 0 {
+		return color.RGBA{}, fmt.Errorf("active palette is empty")
+	}
+
+	if code == "" {
+		darkest := colors[0]
+		for _, c := range colors[1:] {
+			if luminance(c.RGBA) < luminance(darkest.RGBA) {
+				darkest = c
 			}
-		} else if url, err := s.deps.S3Client.GetFileURL(ctx, *imageRecord.PreviewS3Key, 24*time.Hour); err == nil {
-			response.PreviewURL = &url
 		}
+		return darkest.RGBA, nil
 	}
 
-	if imageRecord.SchemaS3Key != nil {
-		if url, err := s.deps.S3Client.GetFileURL(ctx, *imageRecord.SchemaS3Key, 24*time.Hour); err == nil {
-			response.ZipURL = &url
+	for _, c := range colors {
+		if strings.EqualFold(c.Code, code) {
+			return c.RGBA, nil
 		}
 	}
+	return color.RGBA{}, fmt.Errorf("color %s is not in active palette", code)
+}
 
-	return response, nil
+func luminance(c color.RGBA) float64 {
+	return 0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B)
 }
 
-func (s *ImageService) applyImageEditing(img image.Image, params ImageEditParams) image.Image {
-	// Apply scaling
-	if params.Scale != 1.0 {
-		newWidth := int(float64(img.Bounds().Dx()) * params.Scale)
-		newHeight := int(float64(img.Bounds().Dy()) * params.Scale)
-		img = imaging.Resize(img, newWidth, newHeight, imaging.Lanczos)
+// resolveBackgroundColor finds palette color by code, empty code means lightest palette color
+func resolveBackgroundColor(code string, colors []paletteColor) (color.RGBA, error) {
+	if code != "" || len(colors) == 0 {
+		return resolveTextColor(code, colors)
 	}
 
-	// Apply rotation
-	switch params.Rotation {
-	case 90:
-		img = imaging.Rotate90(img)
-	case 180:
-		img = imaging.Rotate180(img)
-	case 270:
-		img = imaging.Rotate270(img)
+	lightest := colors[0]
+	for _, c := range colors[1:] {
+		if luminance(c.RGBA) > luminance(lightest.RGBA) {
+			lightest = c
+		}
 	}
+	return lightest.RGBA, nil
+}
 
-	// Apply cropping
-	if params.CropWidth > 0 && params.CropHeight > 0 {
-		cropRect := image.Rect(params.CropX, params.CropY, params.CropX+params.CropWidth, params.CropY+params.CropHeight)
-		img = imaging.Crop(img, cropRect)
+// ReplaceBackground isolates subject of photo by AI segmentation and replaces background behind it
+// with color from active palette of product and coupon style or with blur
+func (s *ImageService) ReplaceBackground(ctx context.Context, img image.Image, params *BackgroundParams, productType, couponStyle string) (image.Image, error) {
+	if params == nil {
+		return img, nil
 	}
 
-	return img
+	opts := background.Options{Mode: background.Mode(params.Mode), BlurPercent: params.Blur}
+	if opts.Mode == background.ModeColor {
+		colors, err := s.textPaletteColors(productType, couponStyle)
+		if err != nil {
+			return nil, err
+		}
+		if opts.Color, err = resolveBackgroundColor(params.ColorCode, colors); err != nil {
+			return nil, err
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	maskBase64, err := s.deps.StableDiffusionClient.SegmentSubject(ctx, s.deps.StableDiffusionClient.EncodeImageToBase64(buf.Bytes()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to isolate subject: %w", err)
+	}
+	maskData, err := s.deps.StableDiffusionClient.DecodeBase64Image(maskBase64)
+	if err != nil {
+		return nil, err
+	}
+	mask, _, err := image.Decode(bytes.NewReader(maskData))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode subject mask: %w", err)
+	}
+
+	return background.Replace(img, mask, opts)
+}
+
+// replaceSourceBackground replaces background of image before processing and keeps result as processed image,
+// so schema is generated from it even when AI styling is skipped. Image is processed as is when subject
+// cannot be isolated.
+func (s *ImageService) replaceSourceBackground(ctx context.Context, imageRecord *Image, coupon *Coupon, sourceS3Key string, processParams *ProcessingParams) string {
+	img, _, err := s.decodeFromStorage(ctx, sourceS3Key)
+	if err == nil {
+		img, err = s.ReplaceBackground(ctx, img, processParams.Background, coupon.ProductType, coupon.Style)
+	}
+
+	var processedPath string
+	if err == nil {
+		processedPath, err = s.saveProcessedImage(imageRecord.CouponID, img)
+	}
+	if err != nil {
+		log.Warn().
+			Err(err).
+			Str("image_id", imageRecord.ID.String()).
+			Str("mode", processParams.Background.Mode).
+			Msg("Failed to replace background, processing image as is")
+		processParams.BackgroundSkipped = true
+		return sourceS3Key
+	}
+
+	processedKey := "file://" + processedPath
+	imageRecord.ProcessedImageS3Key = &processedKey
+
+	log.Info().
+		Str("image_id", imageRecord.ID.String()).
+		Str("mode", processParams.Background.Mode).
+		Str("processed_s3_key", processedKey).
+		Msg("Image background replaced")
+
+	return processedKey
+}
+
+// saveProcessedImage stores image with replaced background next to AI processing results
+func (s *ImageService) saveProcessedImage(couponID uuid.UUID, img image.Image) (string, error) {
+	processedDir := filepath.Join(s.deps.WorkingDir, "processed", couponID.String())
+	if err := os.MkdirAll(processedDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create processed dir: %w", err)
+	}
+
+	processedPath := filepath.Join(processedDir, fmt.Sprintf("%d_background.jpg", time.Now().Unix()))
+	if err := imaging.Save(img, processedPath, imaging.JPEGQuality(95)); err != nil {
+		return "", fmt.Errorf("failed to save processed image: %w", err)
+	}
+	return processedPath, nil
+}
+
+// processWithoutAI falls back to processing without AI while Stable Diffusion is unavailable,
+// so customer still gets schema. Skipped AI is recorded in processing params.
+func (s *ImageService) processWithoutAI(ctx context.Context, imageRecord *Image, sourceS3Key string, processParams *ProcessingParams, reason error) error {
+	log.Warn().
+		Err(reason).
+		Str("image_id", imageRecord.ID.String()).
+		Msg("Stable Diffusion is unavailable, processing image without AI")
+
+	processParams.AISkipped = true
+	imageRecord.ProcessingParams = processParams
+	return s.createPreviewWithoutAI(ctx, imageRecord, sourceS3Key)
 }
 
 func (s *ImageService) createPreviewWithoutAI(ctx context.Context, imageRecord *Image, sourceS3Key string) error {
@@ -528,12 +1701,18 @@ func (s *ImageService) createPreviewWithoutAI(ctx context.Context, imageRecord *
 		return s.markProcessingFailed(ctx, imageRecord, fmt.Errorf("failed to create preview: %w", err))
 	}
 
+	if jobCancelled(ctx) {
+		return ErrImageCancelled
+	}
+
 	imageRecord.PreviewS3Key = &previewS3Key
 	imageRecord.Status = "processed"
-	if err := s.deps.ImageRepository.Update(ctx, imageRecord); err != nil {
-		return fmt.Errorf("failed to update image record: %w", err)
+	if err := s.saveJobResult(ctx, imageRecord); err != nil {
+		return err
 	}
 
+	s.enqueueUpscaling(imageRecord.ID)
+
 	return nil
 }
 
@@ -593,10 +1772,11 @@ func (s *ImageService) createSchemaZipArchive(ctx context.Context, imageRecord *
 
 	for i := range mosaicFiles {
 		file := mosaicFiles[i]
-		if file.Name == "mosaic_preview.png" {
-			file.Name = "preview.png"
+		dir, name := path.Split(file.Name)
+		if name == "mosaic_preview.png" {
+			file.Name = dir + "preview.png"
 		}
-		if file.Name == "mosaic_legend.csv" {
+		if name == "mosaic_legend.csv" {
 			continue
 		}
 		files = append(files, file)
@@ -622,12 +1802,24 @@ func (s *ImageService) createSchemaZipArchive(ctx context.Context, imageRecord *
 }
 
 func (s *ImageService) markProcessingFailed(ctx context.Context, imageRecord *Image, err error) error {
+	// Cancelled image keeps its status, error is caused by interrupted generation
+	if jobCancelled(ctx) {
+		log.Info().
+			Err(err).
+			Str("image_id", imageRecord.ID.String()).
+			Msg("Image processing interrupted by cancellation")
+		return ErrImageCancelled
+	}
+
 	imageRecord.Status = "failed"
 	errorMsg := err.Error()
 	imageRecord.ErrorMessage = &errorMsg
 	imageRecord.RetryCount++
 
-	if updateErr := s.deps.ImageRepository.Update(ctx, imageRecord); updateErr != nil {
+	if updateErr := s.saveJobResult(ctx, imageRecord); updateErr != nil {
+		if errors.Is(updateErr, ErrImageCancelled) {
+			return ErrImageCancelled
+		}
 		log.Error().Err(updateErr).Msg("Failed to update image record with error status")
 	}
 
@@ -653,6 +1845,8 @@ func (s *ImageService) getStatusMessage(status string) string {
 		return "Схема алмазной мозаики создана"
 	case "failed":
 		return "Произошла ошибка при обработке"
+	case "cancelled":
+		return "Обработка отменена"
 	default:
 		return "Неизвестный статус"
 	}
@@ -670,7 +1864,7 @@ func (s *ImageService) calculateProgress(status string) int {
 		return 80
 	case "completed":
 		return 100
-	case "failed":
+	case "failed", "cancelled":
 		return 0
 	default:
 		return 0
@@ -705,6 +1899,21 @@ func parseCouponSize(size string) (width, height int) {
 	}
 }
 
+// stoneGrid returns number of stones, cells or beads across and down coupon
+func stoneGrid(coupon *Coupon) (int, int) {
+	width, height := parseCouponSize(coupon.Size)
+	stonesX, stonesY := width/4, height/4
+	if coupon.ProductType == mosaic.ProductFuseBeads {
+		return int(float64(stonesX) / palette.BeadPitchMM), int(float64(stonesY) / palette.BeadPitchMM)
+	}
+	return stonesX, stonesY
+}
+
+// pixelsPerStone returns pixels of image along side of single stone, image is cropped to coupon proportions
+func pixelsPerStone(width, height, stonesX, stonesY int) float64 {
+	return min(float64(width)/float64(stonesX), float64(height)/float64(stonesY))
+}
+
 // generateMosaicFiles generates mosaic files using Python script
 func (s *ImageService) generateMosaicFiles(ctx context.Context, sourceS3Key string, imageRecord *Image) ([]zip.FileData, string, error) {
 	coupon, err := s.deps.CouponRepository.GetByID(ctx, imageRecord.CouponID)
@@ -744,30 +1953,23 @@ func (s *ImageService) generateMosaicFiles(ctx context.Context, sourceS3Key stri
 	stonesY = stonesY / 4
 
 	paletteStyle := s.mapCouponStyleToPaletteStyle(coupon.Style)
-	palettePath, err := s.deps.PaletteService.GetPalettePath(paletteStyle)
+
+	var req *mosaic.GenerationRequest
+	switch coupon.ProductType {
+	case mosaic.ProductPaintByNumbers:
+		req, err = s.buildPaintByNumbersRequest(tempImageFile.Name(), stonesX, stonesY, paletteStyle)
+	case mosaic.ProductFuseBeads:
+		req, err = s.buildFuseBeadsRequest(tempImageFile.Name(), stonesX, stonesY, paletteStyle)
+	default:
+		req, err = s.buildDiamondMosaicRequest(tempImageFile.Name(), stonesX, stonesY, coupon.Style, paletteStyle)
+	}
 	if err != nil {
-		return nil, "", fmt.Errorf("failed to get palette path for style %s: %w", coupon.Style, err)
+		return nil, "", fmt.Errorf("failed to prepare generation request for style %s: %w", coupon.Style, err)
 	}
+	req.Size = coupon.Size
 
-	log.Info().
-		Str("coupon_style", coupon.Style).
-		Str("palette_style", string(paletteStyle)).
-		Str("palette_path", palettePath).
-		Msg("Using palette for mosaic generation")
-
-	req := &mosaic.GenerationRequest{
-		ImagePath:   tempImageFile.Name(),
-		StonesX:     stonesX,
-		StonesY:     stonesY,
-		StoneSizeMM: 2.52,
-		DPI:         150,
-		PreviewDPI:  120,
-		SchemeDPI:   150,
-		Mode:        "both",
-		Style:       s.mapCouponStyleToMosaicStyle(coupon.Style),
-		WithLegend:  true,
-		Threads:     4,
-		PalettePath: palettePath,
+	if coupon.Panels > 1 {
+		return s.generatePanelFiles(ctx, req, coupon, stonesX, stonesY)
 	}
 
 	result, err := s.deps.MosaicGenerator.Generate(ctx, req)
@@ -775,84 +1977,270 @@ func (s *ImageService) generateMosaicFiles(ctx context.Context, sourceS3Key stri
 		return nil, "", fmt.Errorf("failed to generate mosaic: %w", err)
 	}
 
+	files, err := s.collectResultFiles(result, "", coupon.ProductType)
+	if err != nil {
+		return nil, "", err
+	}
+	log.Info().
+		Str("image_id", imageRecord.ID.String()).
+		Str("preview_path", result.PreviewPath).
+		Str("scheme_path", result.SchemePath).
+		Msg("Added schema files to archive")
+
+	if result.LegendPath != "" {
+		legendFile, err := readLegendFile(result.LegendPath, coupon.ProductType)
+		if err != nil {
+			return nil, "", err
+		}
+		files = append(files, legendFile)
+
+		if coupon.ProductType != mosaic.ProductPaintByNumbers {
+			s.updateStonesCount(ctx, coupon, result.LegendPath)
+		}
+	}
+
+	log.Info().
+		Str("image_id", imageRecord.ID.String()).
+		Int("files_count", len(files)).
+		Msg("Mosaic files generated successfully")
+
+	return files, result.SchemaUUID, nil
+}
+
+// generatePanelFiles generates multi-panel set, every panel goes to its own folder and legend is combined for the set
+func (s *ImageService) generatePanelFiles(ctx context.Context, req *mosaic.GenerationRequest, coupon *Coupon, panelWidthMM, panelHeightMM int) ([]zip.FileData, string, error) {
+	layout := mosaic.PanelLayout{
+		Count:         coupon.Panels,
+		PanelWidthMM:  panelWidthMM,
+		PanelHeightMM: panelHeightMM,
+		GapMM:         coupon.PanelGapMM,
+	}
+
+	// Script reads diamond palette file itself, drill colors are loaded here to select colors shared by panels
+	if req.ProductType == mosaic.ProductDiamondMosaic {
+		drills, err := s.deps.PaletteService.GetDiamondPalette(s.mapCouponStyleToPaletteStyle(coupon.Style))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to get diamond palette: %w", err)
+		}
+		req.DiamondColors = drills
+	}
+
+	result, err := s.deps.MosaicGenerator.GeneratePanels(ctx, req, layout)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to generate panels: %w", err)
+	}
+	// Files are read into memory below, so panel directory is not needed afterwards
+	defer os.RemoveAll(result.WorkDir)
+
 	var files []zip.FileData
+	for i, panel := range result.Panels {
+		dir := fmt.Sprintf("panel_%d/", i+1)
+		panelFiles, err := s.collectResultFiles(panel, dir, coupon.ProductType)
+		if err != nil {
+			return nil, "", err
+		}
+		files = append(files, panelFiles...)
+	}
 
-	if result.PreviewPath != "" {
-		previewData, err := os.ReadFile(result.PreviewPath)
+	if result.CombinedLegendPath != "" {
+		legendFile, err := readLegendFile(result.CombinedLegendPath, coupon.ProductType)
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to read preview file: %w", err)
+			return nil, "", err
+		}
+		files = append(files, legendFile)
+
+		if coupon.ProductType != mosaic.ProductPaintByNumbers {
+			s.updateStonesCount(ctx, coupon, result.CombinedLegendPath)
 		}
-		files = append(files, zip.FileData{
-			Name:    "mosaic_preview.png",
-			Content: bytes.NewReader(previewData),
-			Size:    int64(len(previewData)),
-		})
-		log.Info().
-			Str("image_id", imageRecord.ID.String()).
-			Str("preview_path", result.PreviewPath).
-			Int("preview_size", len(previewData)).
-			Msg("Added preview file to archive")
 	}
 
-	if result.SchemePath != "" {
-		schemeData, err := os.ReadFile(result.SchemePath)
+	log.Info().
+		Str("coupon_id", coupon.ID.String()).
+		Int("panels", len(result.Panels)).
+		Int("files_count", len(files)).
+		Msg("Multi-panel mosaic files generated successfully")
+
+	return files, result.SchemaUUID, nil
+}
+
+// collectResultFiles reads preview, scheme, legend image and tiles of generation result, dir is prefix inside archive
+func (s *ImageService) collectResultFiles(result *mosaic.GenerationResult, dir, productType string) ([]zip.FileData, error) {
+	var files []zip.FileData
+
+	add := func(path, name, kind string) error {
+		data, err := os.ReadFile(path)
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to read scheme file: %w", err)
+			return fmt.Errorf("failed to read %s file: %w", kind, err)
 		}
 		files = append(files, zip.FileData{
-			Name:    "mosaic_scheme.png",
-			Content: bytes.NewReader(schemeData),
-			Size:    int64(len(schemeData)),
+			Name:    dir + name,
+			Content: bytes.NewReader(data),
+			Size:    int64(len(data)),
 		})
-		log.Info().
-			Str("image_id", imageRecord.ID.String()).
-			Str("scheme_path", result.SchemePath).
-			Int("scheme_size", len(schemeData)).
-			Msg("Added scheme file to archive")
+		return nil
+	}
+
+	if result.PreviewPath != "" {
+		if err := add(result.PreviewPath, "mosaic_preview.png", "preview"); err != nil {
+			return nil, err
+		}
+	}
+
+	if result.SchemePath != "" {
+		if err := add(result.SchemePath, "mosaic_scheme.png", "scheme"); err != nil {
+			return nil, err
+		}
 	} else {
-		log.Warn().
-			Str("image_id", imageRecord.ID.String()).
-			Msg("No scheme path in result")
+		log.Warn().Str("dir", dir).Msg("No scheme path in result")
 	}
 
-	if result.LegendPath != "" {
-		legendData, err := os.ReadFile(result.LegendPath)
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to read legend file: %w", err)
+	if result.LegendImagePath != "" {
+		if err := add(result.LegendImagePath, "legend.png", "legend image"); err != nil {
+			return nil, err
 		}
-		files = append(files, zip.FileData{
-			Name:    "mosaic_legend.csv",
-			Content: bytes.NewReader(legendData),
-			Size:    int64(len(legendData)),
-		})
+	}
+
+	// Each pegboard of bead kit is printed on a separate sheet
+	for _, tilePath := range result.TilePaths {
+		if err := add(tilePath, "tiles/"+filepath.Base(tilePath), "tile"); err != nil {
+			return nil, err
+		}
+	}
 
-		// Parse CSV to get total stones count
-		stonesCount, err := s.parseStonesCountFromCSV(result.LegendPath)
+	// Panel folders keep their own legend, set legend is added separately
+	if dir != "" && result.LegendPath != "" {
+		legendFile, err := readLegendFile(result.LegendPath, productType)
 		if err != nil {
-			log.Warn().Err(err).Msg("Failed to parse stones count from CSV")
-		} else {
-			// Update coupon with stones count
-			coupon.StonesCount = &stonesCount
-			if err := s.deps.CouponRepository.Update(ctx, coupon); err != nil {
-				log.Error().Err(err).
-					Str("coupon_id", coupon.ID.String()).
-					Int("stones_count", stonesCount).
-					Msg("Failed to update coupon with stones count")
-			} else {
-				log.Info().
-					Str("coupon_id", coupon.ID.String()).
-					Int("stones_count", stonesCount).
-					Msg("Updated coupon with stones count")
-			}
+			return nil, err
 		}
+		legendFile.Name = dir + legendFile.Name
+		files = append(files, legendFile)
+	}
+
+	return files, nil
+}
+
+// readLegendFile reads legend CSV, diamond legend is internal and used only for stones count
+func readLegendFile(path, productType string) (zip.FileData, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return zip.FileData{}, fmt.Errorf("failed to read legend file: %w", err)
+	}
+
+	name := "legend.csv"
+	if productType != mosaic.ProductPaintByNumbers && productType != mosaic.ProductFuseBeads {
+		name = "mosaic_legend.csv"
+	}
+
+	return zip.FileData{
+		Name:    name,
+		Content: bytes.NewReader(data),
+		Size:    int64(len(data)),
+	}, nil
+}
+
+// updateStonesCount stores total stones or beads count from legend CSV in coupon
+func (s *ImageService) updateStonesCount(ctx context.Context, coupon *Coupon, legendPath string) {
+	// Bead legend keeps count in the same column as diamond legend
+	stonesCount, err := s.parseStonesCountFromCSV(legendPath)
+	if err != nil {
+		log.Warn().Err(err).Msg("Failed to parse stones count from CSV")
+		return
+	}
+
+	coupon.StonesCount = &stonesCount
+	if err := s.deps.CouponRepository.Update(ctx, coupon); err != nil {
+		log.Error().Err(err).
+			Str("coupon_id", coupon.ID.String()).
+			Int("stones_count", stonesCount).
+			Msg("Failed to update coupon with stones count")
+	} else {
+		log.Info().
+			Str("coupon_id", coupon.ID.String()).
+			Int("stones_count", stonesCount).
+			Msg("Updated coupon with stones count")
+	}
+}
+
+// buildDiamondMosaicRequest prepares Python script request for diamond mosaic schema
+func (s *ImageService) buildDiamondMosaicRequest(imagePath string, stonesX, stonesY int, couponStyle string, paletteStyle palette.Style) (*mosaic.GenerationRequest, error) {
+	palettePath, err := s.deps.PaletteService.GetPalettePath(paletteStyle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get palette path: %w", err)
 	}
 
 	log.Info().
-		Str("image_id", imageRecord.ID.String()).
-		Int("files_count", len(files)).
-		Msg("Mosaic files generated successfully")
+		Str("coupon_style", couponStyle).
+		Str("palette_style", string(paletteStyle)).
+		Str("palette_path", palettePath).
+		Msg("Using palette for mosaic generation")
 
-	return files, result.SchemaUUID, nil
+	return &mosaic.GenerationRequest{
+		ImagePath:   imagePath,
+		StonesX:     stonesX,
+		StonesY:     stonesY,
+		StoneSizeMM: 2.52,
+		DPI:         150,
+		PreviewDPI:  120,
+		SchemeDPI:   150,
+		Mode:        "both",
+		Style:       s.mapCouponStyleToMosaicStyle(couponStyle),
+		WithLegend:  true,
+		Threads:     4,
+		PalettePath: palettePath,
+		ProductType: mosaic.ProductDiamondMosaic,
+	}, nil
+}
+
+// buildPaintByNumbersRequest prepares request for paint-by-numbers schema, one cell per millimeter of canvas
+func (s *ImageService) buildPaintByNumbersRequest(imagePath string, widthMM, heightMM int, paletteStyle palette.Style) (*mosaic.GenerationRequest, error) {
+	paintColors, err := s.deps.PaletteService.GetPaintPalette(paletteStyle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get paint palette: %w", err)
+	}
+
+	log.Info().
+		Str("palette_style", string(paletteStyle)).
+		Int("paint_colors", len(paintColors)).
+		Msg("Using paint palette for paint-by-numbers generation")
+
+	return &mosaic.GenerationRequest{
+		ImagePath:   imagePath,
+		StonesX:     widthMM,
+		StonesY:     heightMM,
+		StoneSizeMM: 1,
+		PreviewDPI:  120,
+		SchemeDPI:   150,
+		WithLegend:  true,
+		ProductType: mosaic.ProductPaintByNumbers,
+		PaintColors: paintColors,
+	}, nil
+}
+
+// buildFuseBeadsRequest prepares request for fuse bead kit, one bead per 5mm pegboard pitch
+func (s *ImageService) buildFuseBeadsRequest(imagePath string, widthMM, heightMM int, paletteStyle palette.Style) (*mosaic.GenerationRequest, error) {
+	beadColors, err := s.deps.PaletteService.GetBeadPalette(palette.BeadBrand(s.deps.BeadBrand), paletteStyle)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get bead palette: %w", err)
+	}
+
+	log.Info().
+		Str("bead_brand", s.deps.BeadBrand).
+		Str("palette_style", string(paletteStyle)).
+		Int("bead_colors", len(beadColors)).
+		Msg("Using bead palette for fuse beads generation")
+
+	return &mosaic.GenerationRequest{
+		ImagePath:   imagePath,
+		StonesX:     int(float64(widthMM) / palette.BeadPitchMM),
+		StonesY:     int(float64(heightMM) / palette.BeadPitchMM),
+		StoneSizeMM: palette.BeadPitchMM,
+		PreviewDPI:  120,
+		SchemeDPI:   150,
+		WithLegend:  true,
+		ProductType: mosaic.ProductFuseBeads,
+		BeadColors:  beadColors,
+	}, nil
 }
 
 // parseStonesCountFromCSV parses the legend CSV file and returns total stones count
@@ -1,17 +1,30 @@
 package image
 
 import (
+	archivezip "archive/zip"
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
+	"image/color"
 	"io"
 	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/disintegration/imaging"
 	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/config"
 	"github.com/skr1ms/mosaic/internal/coupon"
+	"github.com/skr1ms/mosaic/pkg/middleware"
+	"github.com/skr1ms/mosaic/pkg/mockup"
 	"github.com/skr1ms/mosaic/pkg/mosaic"
+	"github.com/skr1ms/mosaic/pkg/palette"
 	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
 	"github.com/skr1ms/mosaic/pkg/zip"
 	"github.com/stretchr/testify/assert"
@@ -93,6 +106,16 @@ func (m *MockImageRepository) Update(ctx context.Context, task *Image) error {
 	return args.Error(0)
 }
 
+func (m *MockImageRepository) UpdateUnlessCancelled(ctx context.Context, task *Image) (bool, error) {
+	args := m.Called(ctx, task)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockImageRepository) UpdateColumns(ctx context.Context, task *Image, columns ...string) error {
+	args := m.Called(ctx, task, columns)
+	return args.Error(0)
+}
+
 func (m *MockImageRepository) Delete(ctx context.Context, id uuid.UUID) error {
 	args := m.Called(ctx, id)
 	return args.Error(0)
@@ -130,6 +153,14 @@ func (m *MockImageRepository) GetStatistics(ctx context.Context) (map[string]int
 	return args.Get(0).(map[string]int64), args.Error(1)
 }
 
+func (m *MockImageRepository) GetQueueDepthByPartner(ctx context.Context) ([]*PartnerQueueDepth, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*PartnerQueueDepth), args.Error(1)
+}
+
 func (m *MockImageRepository) GetAllWithPartner(ctx context.Context) ([]*ImageWithPartner, error) {
 	args := m.Called(ctx)
 	if args.Get(0) == nil {
@@ -279,6 +310,14 @@ func (m *MockMosaicGenerator) Generate(ctx context.Context, req *mosaic.Generati
 	return args.Get(0).(*mosaic.GenerationResult), args.Error(1)
 }
 
+func (m *MockMosaicGenerator) GeneratePanels(ctx context.Context, req *mosaic.GenerationRequest, layout mosaic.PanelLayout) (*mosaic.PanelGenerationResult, error) {
+	args := m.Called(ctx, req, layout)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*mosaic.PanelGenerationResult), args.Error(1)
+}
+
 func createTestImage() *Image {
 	return &Image{
 		ID:                 uuid.New(),
@@ -312,6 +351,78 @@ func bytesToReadCloser(data []byte) io.ReadCloser {
 	return io.NopCloser(bytes.NewReader(data))
 }
 
+// writeDiamondPalettes writes xlsx palettes of all styles in layout of generator script palettes:
+// header row, DMC code, color name from shared strings and RGB channels
+func writeDiamondPalettes(t *testing.T, dir string) {
+	t.Helper()
+
+	drills := []palette.DiamondColor{
+		{Code: "310", Name: "Black", R: 0, G: 0, B: 0},
+		{Code: "317", Name: "Pewter Gray", R: 108, G: 108, B: 108},
+		{Code: "415", Name: "Pearl Gray", R: 211, G: 211, B: 214},
+		{Code: "B5200", Name: "Snow White", R: 255, G: 255, B: 255},
+		{Code: "666", Name: "Bright Red", R: 227, G: 29, B: 66},
+		{Code: "725", Name: "Topaz", R: 255, G: 200, B: 64},
+		{Code: "700", Name: "Bright Green", R: 7, G: 115, B: 27},
+		{Code: "820", Name: "Very Dark Royal Blue", R: 14, G: 54, B: 92},
+		{Code: "950", Name: "Light Desert Sand", R: 238, G: 211, B: 196},
+	}
+
+	var sheet, sharedStrings strings.Builder
+	sheet.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	sheet.WriteString(`<row r="1"><c r="A1" t="inlineStr"><is><t>DMC</t></is></c><c r="B1" t="inlineStr"><is><t>Name</t></is></c>` +
+		`<c r="C1" t="inlineStr"><is><t>R</t></is></c><c r="D1" t="inlineStr"><is><t>G</t></is></c><c r="E1" t="inlineStr"><is><t>B</t></is></c></row>`)
+	sharedStrings.WriteString(`<sst xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	for i, d := range drills {
+		r := i + 2
+		fmt.Fprintf(&sheet, `<row r="%d"><c r="A%d" t="inlineStr"><is><t>%s</t></is></c><c r="B%d" t="s"><v>%d</v></c>`+
+			`<c r="C%d"><v>%d</v></c><c r="D%d"><v>%d</v></c><c r="E%d"><v>%d</v></c></row>`,
+			r, r, d.Code, r, i, r, d.R, r, d.G, r, d.B)
+		fmt.Fprintf(&sharedStrings, `<si><t>%s</t></si>`, d.Name)
+	}
+	sheet.WriteString(`</sheetData></worksheet>`)
+	sharedStrings.WriteString(`</sst>`)
+
+	for _, name := range []string{"pallete_bw.xlsx", "pallete_fl.xlsx", "pallete_tl.xlsx", "pallete_max.xlsx"} {
+		var buf bytes.Buffer
+		archive := archivezip.NewWriter(&buf)
+		for path, content := range map[string]string{
+			"xl/worksheets/sheet1.xml": sheet.String(),
+			"xl/sharedStrings.xml":     sharedStrings.String(),
+		} {
+			w, err := archive.Create(path)
+			assert.NoError(t, err)
+			_, err = w.Write([]byte(content))
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, archive.Close())
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), buf.Bytes(), 0o644))
+	}
+}
+
+type MockTaskCanceller struct {
+	mock.Mock
+}
+
+func (m *MockTaskCanceller) CancelImageTasks(imageID uuid.UUID) (int, error) {
+	args := m.Called(imageID)
+	return args.Int(0), args.Error(1)
+}
+
+type MockUpscaleQueue struct {
+	mock.Mock
+}
+
+func (m *MockUpscaleQueue) EnqueueImageUpscaling(imageID uuid.UUID) (string, error) {
+	args := m.Called(imageID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockUpscaleQueue) ClaimImageUpscaling(imageID uuid.UUID) (bool, error) {
+	args := m.Called(imageID)
+	return args.Bool(0), args.Error(1)
+}
+
 type MockFileHeader struct {
 	multipart.FileHeader
 	content []byte
@@ -446,6 +557,36 @@ func TestImageService_GetQueue(t *testing.T) {
 	}
 }
 
+func TestImageService_GetQueueDepthByPartner(t *testing.T) {
+	t.Run("returns_depth_of_partners", func(t *testing.T) {
+		mockRepo := new(MockImageRepository)
+		depths := []*PartnerQueueDepth{
+			{PartnerID: uuid.New(), PartnerCode: "0001", QueueWeight: 1, Queued: 300, Processing: 2},
+			{PartnerID: uuid.New(), PartnerCode: "0002", QueueWeight: 3, Queued: 4, Processing: 1},
+		}
+		mockRepo.On("GetQueueDepthByPartner", mock.Anything).Return(depths, nil)
+
+		service := NewImageService(&ImageServiceDeps{ImageRepository: mockRepo})
+		result, err := service.GetQueueDepthByPartner(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, depths, result)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("repository_error", func(t *testing.T) {
+		mockRepo := new(MockImageRepository)
+		mockRepo.On("GetQueueDepthByPartner", mock.Anything).Return(nil, errors.New("database error"))
+
+		service := NewImageService(&ImageServiceDeps{ImageRepository: mockRepo})
+		result, err := service.GetQueueDepthByPartner(context.Background())
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
 func TestImageService_GetImageStatus(t *testing.T) {
 	imageID := uuid.New()
 
@@ -889,3 +1030,1136 @@ func TestImageService_MockInterfaces(t *testing.T) {
 		mockS3.AssertExpectations(t)
 	})
 }
+
+func TestImageService_BuildGenerationRequests(t *testing.T) {
+	paletteDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(paletteDir, "pallete_max.xlsx"), []byte("stub"), 0o644))
+
+	service := &ImageService{deps: &ImageServiceDeps{
+		PaletteService: palette.NewPaletteService(paletteDir, middleware.NewLogger()),
+	}}
+
+	t.Run("diamond_mosaic", func(t *testing.T) {
+		req, err := service.buildDiamondMosaicRequest("input.jpg", 300, 400, "max_colors", palette.StyleMaxColors)
+		assert.NoError(t, err)
+		assert.Equal(t, mosaic.ProductDiamondMosaic, req.ProductType)
+		assert.Equal(t, filepath.Join(paletteDir, "pallete_max.xlsx"), req.PalettePath)
+		assert.Equal(t, "glossy-dark", req.Style)
+		assert.Empty(t, req.PaintColors)
+	})
+
+	t.Run("diamond_mosaic_missing_palette", func(t *testing.T) {
+		_, err := service.buildDiamondMosaicRequest("input.jpg", 300, 400, "grayscale", palette.StyleGrayscale)
+		assert.Error(t, err)
+	})
+
+	t.Run("paint_by_numbers", func(t *testing.T) {
+		req, err := service.buildPaintByNumbersRequest("input.jpg", 400, 500, palette.StyleSkinTones)
+		assert.NoError(t, err)
+		assert.Equal(t, mosaic.ProductPaintByNumbers, req.ProductType)
+		assert.Equal(t, 400, req.StonesX)
+		assert.Equal(t, 500, req.StonesY)
+		assert.Equal(t, 1.0, req.StoneSizeMM)
+		assert.Empty(t, req.PalettePath)
+		assert.NotEmpty(t, req.PaintColors)
+		assert.True(t, req.WithLegend)
+	})
+
+	t.Run("fuse_beads", func(t *testing.T) {
+		req, err := service.buildFuseBeadsRequest("input.jpg", 210, 300, palette.StyleMaxColors)
+		assert.NoError(t, err)
+		assert.Equal(t, mosaic.ProductFuseBeads, req.ProductType)
+		assert.Equal(t, 42, req.StonesX)
+		assert.Equal(t, 60, req.StonesY)
+		assert.Equal(t, palette.BeadPitchMM, req.StoneSizeMM)
+		assert.Equal(t, "H01", req.BeadColors[0].Code)
+	})
+
+	t.Run("fuse_beads_unknown_brand", func(t *testing.T) {
+		brandService := &ImageService{deps: &ImageServiceDeps{
+			PaletteService: service.deps.PaletteService,
+			BeadBrand:      "artkal",
+		}}
+		_, err := brandService.buildFuseBeadsRequest("input.jpg", 210, 300, palette.StyleMaxColors)
+		assert.Error(t, err)
+	})
+}
+
+func TestImageService_GeneratePanelFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name string) string {
+		path := filepath.Join(dir, name)
+		assert.NoError(t, os.WriteFile(path, []byte(name), 0o644))
+		return path
+	}
+
+	panelResult := func(i int) *mosaic.GenerationResult {
+		return &mosaic.GenerationResult{
+			PreviewPath: writeFile(fmt.Sprintf("preview_%d.png", i)),
+			SchemePath:  writeFile(fmt.Sprintf("scheme_%d.png", i)),
+			LegendPath:  writeFile(fmt.Sprintf("legend_%d.csv", i)),
+		}
+	}
+
+	mockGenerator := new(MockMosaicGenerator)
+	service := &ImageService{deps: &ImageServiceDeps{MosaicGenerator: mockGenerator}}
+
+	coupon := &Coupon{ID: uuid.New(), ProductType: mosaic.ProductPaintByNumbers, Panels: 2, PanelGapMM: 30}
+	req := &mosaic.GenerationRequest{ProductType: mosaic.ProductPaintByNumbers}
+	layout := mosaic.PanelLayout{Count: 2, PanelWidthMM: 300, PanelHeightMM: 400, GapMM: 30}
+
+	mockGenerator.On("GeneratePanels", mock.Anything, req, layout).Return(&mosaic.PanelGenerationResult{
+		Panels:             []*mosaic.GenerationResult{panelResult(1), panelResult(2)},
+		CombinedLegendPath: writeFile("combined_legend.csv"),
+		SchemaUUID:         "schema-uuid",
+	}, nil)
+
+	files, schemaUUID, err := service.generatePanelFiles(context.Background(), req, coupon, 300, 400)
+	assert.NoError(t, err)
+	assert.Equal(t, "schema-uuid", schemaUUID)
+
+	names := make([]string, 0, len(files))
+	for _, file := range files {
+		names = append(names, file.Name)
+	}
+	assert.Equal(t, []string{
+		"panel_1/mosaic_preview.png",
+		"panel_1/mosaic_scheme.png",
+		"panel_1/legend.csv",
+		"panel_2/mosaic_preview.png",
+		"panel_2/mosaic_scheme.png",
+		"panel_2/legend.csv",
+		"legend.csv",
+	}, names)
+	mockGenerator.AssertExpectations(t)
+}
+
+func TestImageService_GeneratePanelFiles_DiamondPalette(t *testing.T) {
+	paletteDir := t.TempDir()
+	writeDiamondPalettes(t, paletteDir)
+
+	mockGenerator := new(MockMosaicGenerator)
+	service := &ImageService{deps: &ImageServiceDeps{
+		MosaicGenerator: mockGenerator,
+		PaletteService:  palette.NewPaletteService(paletteDir, middleware.NewLogger()),
+	}}
+
+	coupon := &Coupon{ID: uuid.New(), ProductType: mosaic.ProductDiamondMosaic, Style: "grayscale", Panels: 2, PanelGapMM: 30}
+	req := &mosaic.GenerationRequest{ProductType: mosaic.ProductDiamondMosaic}
+	layout := mosaic.PanelLayout{Count: 2, PanelWidthMM: 300, PanelHeightMM: 400, GapMM: 30}
+
+	mockGenerator.On("GeneratePanels", mock.Anything, mock.MatchedBy(func(req *mosaic.GenerationRequest) bool {
+		return len(req.DiamondColors) == 9 && req.DiamondColors[0].Code == "310"
+	}), layout).Return(&mosaic.PanelGenerationResult{
+		Panels:     []*mosaic.GenerationResult{{}, {}},
+		SchemaUUID: "schema-uuid",
+	}, nil)
+
+	_, _, err := service.generatePanelFiles(context.Background(), req, coupon, 300, 400)
+	assert.NoError(t, err)
+	mockGenerator.AssertExpectations(t)
+}
+
+func TestImageService_ApplyTextOverlays(t *testing.T) {
+	service := &ImageService{deps: &ImageServiceDeps{
+		PaletteService: palette.NewPaletteService(t.TempDir(), middleware.NewLogger()),
+	}}
+
+	source := imaging.New(200, 100, color.White)
+
+	t.Run("palette_color", func(t *testing.T) {
+		result, err := service.ApplyTextOverlays(source, []TextOverlay{
+			{Text: "Anna & Ivan", Font: "bold", Position: "center", Size: 20, ColorCode: "p04"},
+		}, mosaic.ProductPaintByNumbers, "pop_art")
+		assert.NoError(t, err)
+
+		vermilion := color.NRGBA{R: 236, G: 56, B: 32, A: 255}
+		found := false
+		bounds := result.Bounds()
+		for y := bounds.Min.Y; y < bounds.Max.Y && !found; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				if color.NRGBAModel.Convert(result.At(x, y)) == vermilion {
+					found = true
+					break
+				}
+			}
+		}
+		assert.True(t, found)
+		assert.Equal(t, color.NRGBA{R: 255, G: 255, B: 255, A: 255}, color.NRGBAModel.Convert(source.At(100, 50)))
+	})
+
+	t.Run("bead_palette", func(t *testing.T) {
+		_, err := service.ApplyTextOverlays(source, []TextOverlay{{Text: "12.08.2025", ColorCode: "H18"}}, mosaic.ProductFuseBeads, "max_colors")
+		assert.NoError(t, err)
+	})
+
+	t.Run("color_not_in_palette", func(t *testing.T) {
+		_, err := service.ApplyTextOverlays(source, []TextOverlay{{Text: "Anna", ColorCode: "H18"}}, mosaic.ProductPaintByNumbers, "grayscale")
+		assert.Error(t, err)
+	})
+
+	t.Run("default_darkest_color", func(t *testing.T) {
+		textColor, err := resolveTextColor("", []paletteColor{
+			{Code: "A", RGBA: color.RGBA{R: 200, G: 200, B: 200, A: 255}},
+			{Code: "B", RGBA: color.RGBA{R: 10, G: 20, B: 30, A: 255}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, color.RGBA{R: 10, G: 20, B: 30, A: 255}, textColor)
+	})
+}
+
+func TestImageService_UploadCollage(t *testing.T) {
+	photoHeaders := func(colors ...color.Color) []*multipart.FileHeader {
+		var body bytes.Buffer
+		writer := multipart.NewWriter(&body)
+		for i, c := range colors {
+			header := make(map[string][]string)
+			header["Content-Disposition"] = []string{fmt.Sprintf(`form-data; name="photos"; filename="photo_%d.png"`, i)}
+			header["Content-Type"] = []string{"image/png"}
+			part, err := writer.CreatePart(header)
+			assert.NoError(t, err)
+			assert.NoError(t, imaging.Encode(part, imaging.New(40, 30, c), imaging.PNG))
+		}
+		assert.NoError(t, writer.Close())
+
+		form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(10 << 20)
+		assert.NoError(t, err)
+		return form.File["photos"]
+	}
+
+	couponID := uuid.New()
+	red := color.NRGBA{R: 255, A: 255}
+	blue := color.NRGBA{B: 255, A: 255}
+
+	t.Run("composes_photos", func(t *testing.T) {
+		mockImageRepo := new(MockImageRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		service := &ImageService{deps: &ImageServiceDeps{
+			ImageRepository:  mockImageRepo,
+			CouponRepository: mockCouponRepo,
+			WorkingDir:       t.TempDir(),
+		}}
+
+		mockCouponRepo.On("GetByID", mock.Anything, couponID).Return(&Coupon{ID: couponID, Size: "30x40"}, nil)
+		mockImageRepo.On("GetByCouponID", mock.Anything, couponID).Return(nil, errors.New("not found"))
+		mockImageRepo.On("Create", mock.Anything, mock.AnythingOfType("*image.Image")).Return(nil)
+
+		record, err := service.UploadCollage(context.Background(), couponID, "two_vertical", photoHeaders(red, blue), "user@example.com")
+		assert.NoError(t, err)
+		assert.Equal(t, "uploaded", record.Status)
+		assert.Equal(t, "two_vertical", record.Collage.Template)
+		assert.Len(t, record.Collage.SourceImageKeys, 2)
+
+		composed, err := imaging.Open(record.OriginalImageS3Key[len("file://"):])
+		assert.NoError(t, err)
+		assert.Equal(t, 1200, composed.Bounds().Dx())
+		assert.Equal(t, 1600, composed.Bounds().Dy())
+
+		r, _, b, _ := composed.At(100, 800).RGBA()
+		assert.True(t, r > b)
+		r, _, b, _ = composed.At(1100, 800).RGBA()
+		assert.True(t, b > r)
+		mockImageRepo.AssertExpectations(t)
+	})
+
+	t.Run("template_not_available_for_size", func(t *testing.T) {
+		mockCouponRepo := new(MockCouponRepository)
+		service := &ImageService{deps: &ImageServiceDeps{CouponRepository: mockCouponRepo, WorkingDir: t.TempDir()}}
+		mockCouponRepo.On("GetByID", mock.Anything, couponID).Return(&Coupon{ID: couponID, Size: "40x40"}, nil)
+
+		_, err := service.UploadCollage(context.Background(), couponID, "three_rows", photoHeaders(red, blue, red), "")
+		assert.Error(t, err)
+	})
+
+	t.Run("photo_count_mismatch", func(t *testing.T) {
+		mockCouponRepo := new(MockCouponRepository)
+		service := &ImageService{deps: &ImageServiceDeps{CouponRepository: mockCouponRepo, WorkingDir: t.TempDir()}}
+		mockCouponRepo.On("GetByID", mock.Anything, couponID).Return(&Coupon{ID: couponID, Size: "30x40"}, nil)
+
+		_, err := service.UploadCollage(context.Background(), couponID, "four_grid", photoHeaders(red, blue, red), "")
+		assert.Error(t, err)
+	})
+
+	t.Run("templates_per_size", func(t *testing.T) {
+		service := &ImageService{deps: &ImageServiceDeps{}}
+		for _, size := range []string{"21x30", "30x40", "40x40", "40x50"} {
+			counts := make(map[int]bool)
+			for _, tmpl := range service.GetCollageTemplates(size) {
+				counts[tmpl.Photos] = true
+			}
+			for photos := 2; photos <= 6; photos++ {
+				assert.True(t, counts[photos], "size %s has no template for %d photos", size, photos)
+			}
+		}
+	})
+}
+
+func TestImageService_ImportImage(t *testing.T) {
+	couponID := uuid.New()
+
+	t.Run("png_photo", func(t *testing.T) {
+		mockImageRepo := new(MockImageRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		service := &ImageService{deps: &ImageServiceDeps{
+			ImageRepository:  mockImageRepo,
+			CouponRepository: mockCouponRepo,
+			WorkingDir:       t.TempDir(),
+		}}
+
+		var photo bytes.Buffer
+		assert.NoError(t, imaging.Encode(&photo, imaging.New(40, 30, color.White), imaging.PNG))
+
+		mockCouponRepo.On("GetByID", mock.Anything, couponID).Return(&Coupon{ID: couponID, Size: "30x40"}, nil)
+		mockImageRepo.On("GetByCouponID", mock.Anything, couponID).Return(nil, errors.New("not found"))
+		mockImageRepo.On("Create", mock.Anything, mock.AnythingOfType("*image.Image")).Return(nil)
+
+		record, err := service.ImportImage(context.Background(), couponID, "photo.png", photo.Bytes(), "")
+		assert.NoError(t, err)
+		assert.Equal(t, "uploaded", record.Status)
+		assert.Equal(t, 0, record.Priority)
+		assert.Equal(t, ".png", filepath.Ext(record.OriginalImageS3Key))
+
+		data, err := os.ReadFile(record.OriginalImageS3Key[len("file://"):])
+		assert.NoError(t, err)
+		assert.Equal(t, photo.Bytes(), data)
+		mockImageRepo.AssertExpectations(t)
+	})
+
+	t.Run("not_an_image", func(t *testing.T) {
+		mockImageRepo := new(MockImageRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		service := &ImageService{deps: &ImageServiceDeps{
+			ImageRepository:  mockImageRepo,
+			CouponRepository: mockCouponRepo,
+			WorkingDir:       t.TempDir(),
+		}}
+
+		mockCouponRepo.On("GetByID", mock.Anything, couponID).Return(&Coupon{ID: couponID, Size: "30x40"}, nil)
+
+		_, err := service.ImportImage(context.Background(), couponID, "notes.txt", []byte("plain text"), "")
+		assert.ErrorContains(t, err, "invalid image type")
+		mockImageRepo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func TestImageService_ResetInterruptedProcessing(t *testing.T) {
+	edited := "file:///tmp/edited.jpg"
+	startedAt := time.Now()
+
+	tests := []struct {
+		name           string
+		image          *Image
+		expectedStatus string
+		expectUpdate   bool
+	}{
+		{"edited_image", &Image{ID: uuid.New(), Status: "processing", EditedImageS3Key: &edited, StartedAt: &startedAt}, "edited", true},
+		{"uploaded_image", &Image{ID: uuid.New(), Status: "processing", StartedAt: &startedAt}, "uploaded", true},
+		{"not_processing", &Image{ID: uuid.New(), Status: "processed"}, "processed", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockImageRepo := new(MockImageRepository)
+			service := &ImageService{deps: &ImageServiceDeps{ImageRepository: mockImageRepo}}
+
+			mockImageRepo.On("GetByID", mock.Anything, tt.image.ID).Return(tt.image, nil)
+			if tt.expectUpdate {
+				mockImageRepo.On("Update", mock.Anything, tt.image).Return(nil)
+			}
+
+			err := service.ResetInterruptedProcessing(context.Background(), tt.image.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.expectedStatus, tt.image.Status)
+			if tt.expectUpdate {
+				assert.Nil(t, tt.image.StartedAt)
+			}
+			mockImageRepo.AssertExpectations(t)
+		})
+	}
+}
+
+func TestImageService_CancelImage(t *testing.T) {
+	t.Run("queued_tasks_removed", func(t *testing.T) {
+		imageRecord := &Image{ID: uuid.New(), Status: "processed"}
+		mockImageRepo := new(MockImageRepository)
+		mockCanceller := new(MockTaskCanceller)
+		service := &ImageService{deps: &ImageServiceDeps{ImageRepository: mockImageRepo, TaskCanceller: mockCanceller}}
+
+		mockImageRepo.On("GetByID", mock.Anything, imageRecord.ID).Return(imageRecord, nil)
+		mockImageRepo.On("UpdateColumns", mock.Anything, imageRecord, []string{"status"}).Return(nil)
+		mockCanceller.On("CancelImageTasks", imageRecord.ID).Return(1, nil)
+
+		result, err := service.CancelImage(context.Background(), imageRecord.ID)
+		assert.NoError(t, err)
+		assert.Equal(t, "cancelled", result.Status)
+		mockImageRepo.AssertExpectations(t)
+		mockCanceller.AssertExpectations(t)
+	})
+
+	t.Run("running_job_interrupted", func(t *testing.T) {
+		imageRecord := &Image{ID: uuid.New(), Status: "processing"}
+		mockImageRepo := new(MockImageRepository)
+		service := &ImageService{deps: &ImageServiceDeps{ImageRepository: mockImageRepo}}
+
+		mockImageRepo.On("GetByID", mock.Anything, imageRecord.ID).Return(imageRecord, nil)
+		mockImageRepo.On("UpdateColumns", mock.Anything, imageRecord, []string{"status"}).Return(nil)
+
+		jobCtx, finish := service.startJob(context.Background(), imageRecord.ID)
+		defer finish()
+
+		_, err := service.CancelImage(context.Background(), imageRecord.ID)
+		assert.NoError(t, err)
+
+		select {
+		case <-jobCtx.Done():
+		case <-time.After(time.Second):
+			t.Fatal("running job was not interrupted")
+		}
+		assert.True(t, jobCancelled(jobCtx))
+	})
+
+	t.Run("cancelled_by_another_process", func(t *testing.T) {
+		sourcePath := filepath.Join(t.TempDir(), "source.png")
+		assert.NoError(t, imaging.Save(imaging.New(64, 64, color.White), sourcePath))
+
+		couponID := uuid.New()
+		imageRecord := &Image{ID: uuid.New(), CouponID: couponID, Status: "uploaded", OriginalImageS3Key: "file://" + sourcePath}
+		mockImageRepo := new(MockImageRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		service := &ImageService{deps: &ImageServiceDeps{
+			ImageRepository:  mockImageRepo,
+			CouponRepository: mockCouponRepo,
+			WorkingDir:       t.TempDir(),
+		}}
+
+		mockImageRepo.On("GetByID", mock.Anything, imageRecord.ID).Return(imageRecord, nil)
+		mockCouponRepo.On("GetByID", mock.Anything, couponID).Return(&Coupon{ID: couponID, Size: "30x40"}, nil)
+		// Status "processing" is saved, then image is cancelled before result is written
+		mockImageRepo.On("UpdateUnlessCancelled", mock.Anything, imageRecord).Return(true, nil).Once()
+		mockImageRepo.On("UpdateUnlessCancelled", mock.Anything, imageRecord).Return(false, nil).Once()
+
+		err := service.ProcessImage(context.Background(), imageRecord.ID, &ProcessingParams{Style: "grayscale"})
+		assert.ErrorIs(t, err, ErrImageCancelled)
+		assert.Equal(t, "cancelled", imageRecord.Status)
+		mockImageRepo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+		mockImageRepo.AssertExpectations(t)
+	})
+
+	t.Run("cancelled_image_not_processed", func(t *testing.T) {
+		imageRecord := &Image{ID: uuid.New(), Status: "cancelled"}
+		mockImageRepo := new(MockImageRepository)
+		service := &ImageService{deps: &ImageServiceDeps{ImageRepository: mockImageRepo}}
+
+		mockImageRepo.On("GetByID", mock.Anything, imageRecord.ID).Return(imageRecord, nil)
+
+		err := service.ProcessImage(context.Background(), imageRecord.ID, &ProcessingParams{Style: "grayscale"})
+		assert.ErrorIs(t, err, ErrImageCancelled)
+
+		err = service.GenerateSchema(context.Background(), imageRecord.ID, true)
+		assert.ErrorIs(t, err, ErrImageCancelled)
+
+		_, err = service.CancelImage(context.Background(), imageRecord.ID)
+		assert.ErrorIs(t, err, ErrImageNotCancellable)
+	})
+
+	t.Run("completed_image", func(t *testing.T) {
+		imageRecord := &Image{ID: uuid.New(), Status: "completed"}
+		mockImageRepo := new(MockImageRepository)
+		service := &ImageService{deps: &ImageServiceDeps{ImageRepository: mockImageRepo}}
+
+		mockImageRepo.On("GetByID", mock.Anything, imageRecord.ID).Return(imageRecord, nil)
+
+		_, err := service.CancelImage(context.Background(), imageRecord.ID)
+		assert.ErrorIs(t, err, ErrImageNotCancellable)
+		mockImageRepo.AssertNotCalled(t, "UpdateColumns", mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestImageService_ResumeImage(t *testing.T) {
+	editedKey := "file:///tmp/edited/1.jpg"
+	startedAt := time.Now()
+
+	tests := []struct {
+		name           string
+		image          *Image
+		expectedStatus string
+		expectedErr    error
+	}{
+		{
+			name:           "cancelled_uploaded_image",
+			image:          &Image{ID: uuid.New(), Status: "cancelled", StartedAt: &startedAt},
+			expectedStatus: "uploaded",
+		},
+		{
+			name:           "cancelled_edited_image",
+			image:          &Image{ID: uuid.New(), Status: "cancelled", EditedImageS3Key: &editedKey, StartedAt: &startedAt},
+			expectedStatus: "edited",
+		},
+		{
+			name:           "image_not_cancelled",
+			image:          &Image{ID: uuid.New(), Status: "processing"},
+			expectedStatus: "processing",
+			expectedErr:    ErrImageNotCancelled,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockImageRepo := new(MockImageRepository)
+			service := &ImageService{deps: &ImageServiceDeps{ImageRepository: mockImageRepo}}
+
+			mockImageRepo.On("GetByID", mock.Anything, tt.image.ID).Return(tt.image, nil)
+			if tt.expectedErr == nil {
+				mockImageRepo.On("UpdateColumns", mock.Anything, tt.image, []string{"status", "started_at"}).Return(nil)
+			}
+
+			_, err := service.ResumeImage(context.Background(), tt.image.ID)
+			assert.ErrorIs(t, err, tt.expectedErr)
+			assert.Equal(t, tt.expectedStatus, tt.image.Status)
+			if tt.expectedErr == nil {
+				assert.Nil(t, tt.image.StartedAt)
+			}
+			mockImageRepo.AssertExpectations(t)
+		})
+	}
+
+	t.Run("resumed_image_processed_again", func(t *testing.T) {
+		sourcePath := filepath.Join(t.TempDir(), "source.png")
+		assert.NoError(t, imaging.Save(imaging.New(64, 64, color.White), sourcePath))
+
+		couponID := uuid.New()
+		imageRecord := &Image{ID: uuid.New(), CouponID: couponID, Status: "cancelled", OriginalImageS3Key: "file://" + sourcePath}
+		mockImageRepo := new(MockImageRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		service := &ImageService{deps: &ImageServiceDeps{
+			ImageRepository:  mockImageRepo,
+			CouponRepository: mockCouponRepo,
+			WorkingDir:       t.TempDir(),
+		}}
+
+		mockImageRepo.On("GetByID", mock.Anything, imageRecord.ID).Return(imageRecord, nil)
+		mockImageRepo.On("UpdateColumns", mock.Anything, imageRecord, []string{"status", "started_at"}).Return(nil)
+		mockCouponRepo.On("GetByID", mock.Anything, couponID).Return(&Coupon{ID: couponID, Size: "30x40"}, nil)
+		mockImageRepo.On("UpdateUnlessCancelled", mock.Anything, imageRecord).Return(true, nil)
+
+		err := service.ProcessImage(context.Background(), imageRecord.ID, &ProcessingParams{Style: "grayscale"})
+		assert.ErrorIs(t, err, ErrImageCancelled)
+
+		_, err = service.ResumeImage(context.Background(), imageRecord.ID)
+		assert.NoError(t, err)
+
+		err = service.ProcessImage(context.Background(), imageRecord.ID, &ProcessingParams{Style: "grayscale"})
+		assert.NoError(t, err)
+		assert.Equal(t, "processed", imageRecord.Status)
+	})
+}
+
+func TestImageService_OptimizeAndThumbnails(t *testing.T) {
+	couponID := uuid.New()
+	imageID := uuid.New()
+	originalPath := filepath.Join(t.TempDir(), "original.png")
+	assert.NoError(t, imaging.Save(imaging.New(800, 600, color.NRGBA{R: 200, A: 255}), originalPath))
+
+	newService := func(mockImageRepo *MockImageRepository, mockS3 *MockS3Client) *ImageService {
+		return &ImageService{deps: &ImageServiceDeps{
+			ImageRepository:  mockImageRepo,
+			S3Client:         mockS3,
+			ThumbnailSizes:   []string{"100", "300"},
+			ThumbnailFormat:  "jpeg",
+			ThumbnailQuality: 80,
+			OptimizeQuality:  85,
+		}}
+	}
+
+	t.Run("optimize_image", func(t *testing.T) {
+		mockImageRepo := new(MockImageRepository)
+		mockS3 := new(MockS3Client)
+		record := &Image{ID: imageID, CouponID: couponID, OriginalImageS3Key: "file://" + originalPath}
+		key := fmt.Sprintf("optimized/%s/%s.jpg", couponID, imageID)
+
+		mockImageRepo.On("GetByID", mock.Anything, imageID).Return(record, nil)
+		mockS3.On("UploadFileWithKey", mock.Anything, mock.Anything, mock.AnythingOfType("int64"), "image/jpeg", key).Return(key, nil)
+		mockImageRepo.On("UpdateColumns", mock.Anything, record, []string{"optimized_image_s3_key"}).Return(nil)
+
+		err := newService(mockImageRepo, mockS3).OptimizeImage(context.Background(), imageID, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, key, *record.OptimizedImageS3Key)
+		mockImageRepo.AssertExpectations(t)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("generate_thumbnails", func(t *testing.T) {
+		mockImageRepo := new(MockImageRepository)
+		mockS3 := new(MockS3Client)
+		record := &Image{ID: imageID, CouponID: couponID, OriginalImageS3Key: "file://" + originalPath}
+
+		mockImageRepo.On("GetByID", mock.Anything, imageID).Return(record, nil)
+		for _, size := range []string{"100", "300"} {
+			key := fmt.Sprintf("thumbnails/%s/%s/%s.jpg", couponID, imageID, size)
+			mockS3.On("UploadFileWithKey", mock.Anything, mock.Anything, mock.AnythingOfType("int64"), "image/jpeg", key).Return(key, nil)
+		}
+		mockImageRepo.On("UpdateColumns", mock.Anything, record, []string{"thumbnails"}).Return(nil)
+
+		err := newService(mockImageRepo, mockS3).GenerateThumbnails(context.Background(), imageID, nil)
+		assert.NoError(t, err)
+		assert.Len(t, record.Thumbnails, 2)
+		assert.Equal(t, fmt.Sprintf("thumbnails/%s/%s/300.jpg", couponID, imageID), record.Thumbnails["300"])
+		mockImageRepo.AssertExpectations(t)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("invalid_size", func(t *testing.T) {
+		mockImageRepo := new(MockImageRepository)
+		record := &Image{ID: imageID, CouponID: couponID, OriginalImageS3Key: "file://" + originalPath}
+		mockImageRepo.On("GetByID", mock.Anything, imageID).Return(record, nil)
+
+		err := newService(mockImageRepo, new(MockS3Client)).GenerateThumbnails(context.Background(), imageID, []string{"huge"})
+		assert.Error(t, err)
+		mockImageRepo.AssertNotCalled(t, "UpdateColumns", mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("optimized_copy_replaces_missing_original", func(t *testing.T) {
+		optimized := "optimized/key.jpg"
+		record := &Image{OriginalImageS3Key: "file:///nonexistent/original.jpg", OptimizedImageS3Key: &optimized}
+		assert.Equal(t, optimized, newService(nil, nil).originalImageKey(record))
+
+		record.OriginalImageS3Key = "file://" + originalPath
+		assert.Equal(t, record.OriginalImageS3Key, newService(nil, nil).originalImageKey(record))
+	})
+}
+
+func TestImageService_ProcessImageWithFakeAIBackend(t *testing.T) {
+	logger := middleware.NewLogger()
+	sourcePath := filepath.Join(t.TempDir(), "source.png")
+	assert.NoError(t, imaging.Save(imaging.New(300, 400, color.NRGBA{R: 200, G: 120, B: 40, A: 255}), sourcePath))
+
+	newBackend := func(t *testing.T, name, baseURL string) stableDiffusion.AIBackend {
+		backend, err := stableDiffusion.NewBackend(config.StableDiffusionConfig{BaseURL: baseURL, Backend: name}, http.DefaultClient, logger)
+		assert.NoError(t, err)
+		return backend
+	}
+
+	process := func(t *testing.T, backend stableDiffusion.AIBackend) (*Image, error) {
+		couponID := uuid.New()
+		record := &Image{ID: uuid.New(), CouponID: couponID, Status: "uploaded", OriginalImageS3Key: "file://" + sourcePath}
+		mockImageRepo := new(MockImageRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		mockImageRepo.On("GetByID", mock.Anything, record.ID).Return(record, nil)
+		mockImageRepo.On("UpdateUnlessCancelled", mock.Anything, record).Return(true, nil)
+		mockCouponRepo.On("GetByID", mock.Anything, couponID).Return(&Coupon{ID: couponID, Size: "30x40"}, nil)
+
+		service := &ImageService{deps: &ImageServiceDeps{
+			ImageRepository:       mockImageRepo,
+			CouponRepository:      mockCouponRepo,
+			StableDiffusionClient: stableDiffusion.NewStableDiffusionClientWithBackend(backend, logger),
+			WorkingDir:            t.TempDir(),
+		}}
+		err := service.ProcessImage(context.Background(), record.ID, &ProcessingParams{Style: "grayscale", UseAI: true})
+		return record, err
+	}
+
+	for _, name := range []string{stableDiffusion.BackendAutomatic1111, stableDiffusion.BackendComfyUI} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(stableDiffusion.NewFakeServer())
+			defer server.Close()
+
+			record, err := process(t, newBackend(t, name, server.URL))
+			assert.NoError(t, err)
+			assert.Equal(t, "processed", record.Status)
+			assert.NotNil(t, record.ProcessedImageS3Key)
+			assert.NotNil(t, record.PreviewS3Key)
+
+			processed, err := imaging.Open(strings.TrimPrefix(*record.ProcessedImageS3Key, "file://"))
+			assert.NoError(t, err)
+			assert.Equal(t, 576, processed.Bounds().Dx())
+			assert.Equal(t, 768, processed.Bounds().Dy())
+			r, g, b, _ := processed.At(10, 10).RGBA()
+			assert.True(t, r == g && g == b, "grayscale prompt gives gray image")
+		})
+	}
+
+	t.Run("out_of_memory_retried_with_smaller_size", func(t *testing.T) {
+		fake := stableDiffusion.NewFakeServer()
+		fake.MaxSide = 600
+		server := httptest.NewServer(fake)
+		defer server.Close()
+
+		record, err := process(t, newBackend(t, stableDiffusion.BackendAutomatic1111, server.URL))
+		assert.NoError(t, err)
+		assert.Equal(t, "processed", record.Status)
+		assert.Equal(t, 3, fake.Requests())
+	})
+
+	t.Run("server_unavailable_processed_without_ai", func(t *testing.T) {
+		server := httptest.NewServer(stableDiffusion.NewFakeServer())
+		server.Close()
+
+		record, err := process(t, newBackend(t, stableDiffusion.BackendComfyUI, server.URL))
+		assert.NoError(t, err)
+		assert.Equal(t, "processed", record.Status)
+		assert.Nil(t, record.ProcessedImageS3Key)
+		assert.True(t, record.ProcessingParams.AISkipped)
+	})
+
+	t.Run("server_error_processed_without_ai", func(t *testing.T) {
+		fake := stableDiffusion.NewFakeServer()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/sdapi/v1/img2img" {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			fake.ServeHTTP(w, r)
+		}))
+		defer server.Close()
+
+		record, err := process(t, newBackend(t, stableDiffusion.BackendAutomatic1111, server.URL))
+		assert.NoError(t, err)
+		assert.Equal(t, "processed", record.Status)
+		assert.NotNil(t, record.PreviewS3Key)
+		assert.True(t, record.ProcessingParams.AISkipped)
+	})
+
+	t.Run("server_rejects_request", func(t *testing.T) {
+		fake := stableDiffusion.NewFakeServer()
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/sdapi/v1/img2img" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			fake.ServeHTTP(w, r)
+		}))
+		defer server.Close()
+
+		record, err := process(t, newBackend(t, stableDiffusion.BackendAutomatic1111, server.URL))
+		assert.Error(t, err)
+		assert.Equal(t, "failed", record.Status)
+	})
+
+	t.Run("circuit_open_processed_without_ai", func(t *testing.T) {
+		server := httptest.NewServer(stableDiffusion.NewFakeServer())
+		server.Close()
+
+		cfg := stableDiffusion.DefaultBreakerConfig()
+		cfg.MinCalls = 1
+		breaker := stableDiffusion.NewBreakerBackend(newBackend(t, stableDiffusion.BackendAutomatic1111, server.URL), cfg, logger)
+
+		record, err := process(t, breaker)
+		assert.NoError(t, err)
+		assert.Equal(t, "processed", record.Status)
+		assert.NotNil(t, record.PreviewS3Key)
+		assert.Nil(t, record.ProcessedImageS3Key)
+		assert.True(t, record.ProcessingParams.AISkipped)
+		assert.Equal(t, stableDiffusion.BreakerOpen, breaker.Status().State)
+
+		_, err = breaker.Img2Img(context.Background(), stableDiffusion.GenerationParams{})
+		assert.ErrorIs(t, err, stableDiffusion.ErrCircuitOpen)
+	})
+}
+
+func TestImageService_UpscaleImage(t *testing.T) {
+	logger := middleware.NewLogger()
+	sourcePath := filepath.Join(t.TempDir(), "processed.png")
+	assert.NoError(t, imaging.Save(imaging.New(300, 400, color.NRGBA{R: 200, G: 120, B: 40, A: 255}), sourcePath))
+	sourceKey := "file://" + sourcePath
+
+	upscale := func(t *testing.T, backendName, baseURL string, coupon *Coupon, record *Image) (*MockS3Client, error) {
+		record.CouponID = coupon.ID
+		mockImageRepo := new(MockImageRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		mockS3 := new(MockS3Client)
+		mockImageRepo.On("GetByID", mock.Anything, record.ID).Return(record, nil)
+		mockImageRepo.On("UpdateColumns", mock.Anything, record, []string{"upscaled_image_s3_key", "upscale"}).Return(nil)
+		mockCouponRepo.On("GetByID", mock.Anything, coupon.ID).Return(coupon, nil)
+		mockS3.On("UploadFileWithKey", mock.Anything, mock.Anything, mock.Anything, "image/jpeg", fmt.Sprintf("upscaled/%s/%s.jpg", coupon.ID, record.ID)).
+			Return("upscaled-key", nil)
+
+		backend, err := stableDiffusion.NewBackend(config.StableDiffusionConfig{BaseURL: baseURL, Backend: backendName}, http.DefaultClient, logger)
+		assert.NoError(t, err)
+
+		service := &ImageService{deps: &ImageServiceDeps{
+			ImageRepository:       mockImageRepo,
+			CouponRepository:      mockCouponRepo,
+			S3Client:              mockS3,
+			StableDiffusionClient: stableDiffusion.NewStableDiffusionClientWithBackend(backend, logger),
+			UpscaleEnabled:        true,
+			MinPixelsPerStone:     3,
+			TargetPixelsPerStone:  6,
+		}}
+		return mockS3, service.UpscaleImage(context.Background(), record.ID)
+	}
+
+	newRecord := func() *Image {
+		return &Image{ID: uuid.New(), Status: "processed", ProcessedImageS3Key: &sourceKey}
+	}
+
+	for _, name := range []string{stableDiffusion.BackendAutomatic1111, stableDiffusion.BackendComfyUI} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(stableDiffusion.NewFakeServer())
+			defer server.Close()
+
+			record := newRecord()
+			mockS3, err := upscale(t, name, server.URL, &Coupon{ID: uuid.New(), Size: "30x40"}, record)
+			assert.NoError(t, err)
+			mockS3.AssertNumberOfCalls(t, "UploadFileWithKey", 1)
+			assert.Equal(t, "upscaled-key", *record.UpscaledImageS3Key)
+			assert.Equal(t, UpscaleMethodAI, record.Upscale.Method)
+			assert.Equal(t, sourceKey, record.Upscale.SourceS3Key)
+			assert.Equal(t, 4.0, record.Upscale.Scale)
+			assert.Equal(t, 1.0, record.Upscale.PixelsPerStoneBefore)
+			assert.Equal(t, 1200, record.Upscale.AfterWidth)
+			assert.Equal(t, 1600, record.Upscale.AfterHeight)
+			assert.Equal(t, 4.0, record.Upscale.PixelsPerStoneAfter)
+		})
+	}
+
+	t.Run("server_unavailable_lanczos_fallback", func(t *testing.T) {
+		server := httptest.NewServer(stableDiffusion.NewFakeServer())
+		server.Close()
+
+		record := newRecord()
+		_, err := upscale(t, stableDiffusion.BackendAutomatic1111, server.URL, &Coupon{ID: uuid.New(), Size: "30x40"}, record)
+		assert.NoError(t, err)
+		assert.Equal(t, UpscaleMethodLanczos, record.Upscale.Method)
+		assert.NotEmpty(t, record.Upscale.AIError)
+		assert.Equal(t, 1200, record.Upscale.AfterWidth)
+		assert.Equal(t, 1600, record.Upscale.AfterHeight)
+		assert.NotNil(t, record.UpscaledImageS3Key)
+	})
+
+	t.Run("enough_pixels_per_bead", func(t *testing.T) {
+		record := newRecord()
+		mockS3, err := upscale(t, stableDiffusion.BackendAutomatic1111, "http://127.0.0.1:0", &Coupon{ID: uuid.New(), Size: "30x40", ProductType: mosaic.ProductFuseBeads}, record)
+		assert.NoError(t, err)
+		mockS3.AssertNotCalled(t, "UploadFileWithKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		assert.Nil(t, record.UpscaledImageS3Key)
+		assert.Equal(t, UpscaleMethodNone, record.Upscale.Method)
+		assert.Equal(t, 5.0, record.Upscale.PixelsPerStoneBefore)
+	})
+
+	t.Run("already_analyzed", func(t *testing.T) {
+		record := newRecord()
+		record.Upscale = &UpscaleInfo{SourceS3Key: sourceKey, Method: UpscaleMethodNone}
+		mockS3, err := upscale(t, stableDiffusion.BackendAutomatic1111, "http://127.0.0.1:0", &Coupon{ID: uuid.New(), Size: "30x40"}, record)
+		assert.NoError(t, err)
+		mockS3.AssertNotCalled(t, "UploadFileWithKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		assert.Equal(t, UpscaleMethodNone, record.Upscale.Method)
+	})
+}
+
+func TestImageService_ClaimUpscaling(t *testing.T) {
+	t.Run("waiting_task_taken_over", func(t *testing.T) {
+		record := &Image{ID: uuid.New(), Status: "processed"}
+		mockImageRepo := new(MockImageRepository)
+		mockQueue := new(MockUpscaleQueue)
+		service := &ImageService{deps: &ImageServiceDeps{ImageRepository: mockImageRepo, UpscaleQueue: mockQueue}}
+
+		mockQueue.On("ClaimImageUpscaling", record.ID).Return(true, nil).Once()
+
+		assert.NoError(t, service.claimUpscaling(context.Background(), record))
+		mockQueue.AssertExpectations(t)
+		mockImageRepo.AssertNotCalled(t, "GetByID", mock.Anything, mock.Anything)
+	})
+
+	t.Run("running_task_waited_for", func(t *testing.T) {
+		record := &Image{ID: uuid.New(), Status: "processed"}
+		upscaledKey := "upscaled-key"
+		upscaled := &Image{ID: record.ID, Status: "processed", UpscaledImageS3Key: &upscaledKey, Upscale: &UpscaleInfo{Method: UpscaleMethodAI}}
+		mockImageRepo := new(MockImageRepository)
+		mockQueue := new(MockUpscaleQueue)
+		service := &ImageService{deps: &ImageServiceDeps{ImageRepository: mockImageRepo, UpscaleQueue: mockQueue}}
+
+		mockQueue.On("ClaimImageUpscaling", record.ID).Return(false, nil).Once()
+		mockQueue.On("ClaimImageUpscaling", record.ID).Return(true, nil).Once()
+		mockImageRepo.On("GetByID", mock.Anything, record.ID).Return(upscaled, nil)
+
+		assert.NoError(t, service.claimUpscaling(context.Background(), record))
+		assert.Equal(t, &upscaledKey, record.UpscaledImageS3Key, "result of upscaling task is used")
+		assert.Equal(t, UpscaleMethodAI, record.Upscale.Method)
+		mockQueue.AssertExpectations(t)
+	})
+
+	t.Run("cancelled_while_waiting", func(t *testing.T) {
+		record := &Image{ID: uuid.New(), Status: "processed"}
+		mockQueue := new(MockUpscaleQueue)
+		service := &ImageService{deps: &ImageServiceDeps{UpscaleQueue: mockQueue}}
+
+		mockQueue.On("ClaimImageUpscaling", record.ID).Return(false, nil)
+
+		ctx, cancel := context.WithCancelCause(context.Background())
+		cancel(ErrImageCancelled)
+		assert.ErrorIs(t, service.claimUpscaling(ctx, record), ErrImageCancelled)
+	})
+}
+
+func TestImageService_ReplaceBackground(t *testing.T) {
+	logger := middleware.NewLogger()
+	orange := color.NRGBA{R: 200, G: 120, B: 40, A: 255}
+	vermilion := color.NRGBA{R: 236, G: 56, B: 32, A: 255}
+	source := imaging.New(200, 200, orange)
+
+	newService := func(t *testing.T, name, baseURL string) *ImageService {
+		backend, err := stableDiffusion.NewBackend(config.StableDiffusionConfig{BaseURL: baseURL, Backend: name}, http.DefaultClient, logger)
+		assert.NoError(t, err)
+		return &ImageService{deps: &ImageServiceDeps{
+			StableDiffusionClient: stableDiffusion.NewStableDiffusionClientWithBackend(backend, logger),
+			PaletteService:        palette.NewPaletteService(t.TempDir(), logger),
+			WorkingDir:            t.TempDir(),
+		}}
+	}
+
+	for _, name := range []string{stableDiffusion.BackendAutomatic1111, stableDiffusion.BackendComfyUI} {
+		t.Run(name, func(t *testing.T) {
+			server := httptest.NewServer(stableDiffusion.NewFakeServer())
+			defer server.Close()
+
+			result, err := newService(t, name, server.URL).ReplaceBackground(context.Background(), source,
+				&BackgroundParams{Mode: "color", ColorCode: "p04"}, mosaic.ProductPaintByNumbers, "pop_art")
+			assert.NoError(t, err)
+			assert.Equal(t, orange, color.NRGBAModel.Convert(result.At(100, 100)), "subject is kept")
+			assert.Equal(t, vermilion, color.NRGBAModel.Convert(result.At(2, 2)), "background is palette color")
+		})
+	}
+
+	t.Run("blur", func(t *testing.T) {
+		server := httptest.NewServer(stableDiffusion.NewFakeServer())
+		defer server.Close()
+
+		striped := imaging.New(200, 200, color.White)
+		for y := 0; y < 200; y += 4 {
+			for x := 0; x < 200; x++ {
+				striped.Set(x, y, color.Black)
+			}
+		}
+
+		result, err := newService(t, stableDiffusion.BackendAutomatic1111, server.URL).ReplaceBackground(context.Background(), striped,
+			&BackgroundParams{Mode: "blur", Blur: 5}, mosaic.ProductPaintByNumbers, "max_colors")
+		assert.NoError(t, err)
+		assert.Equal(t, color.NRGBA{A: 255}, color.NRGBAModel.Convert(result.At(100, 100)), "subject is kept sharp")
+		r, _, _, _ := result.At(2, 0).RGBA()
+		assert.True(t, r > 0x2000 && r < 0xe000, "background is blurred")
+	})
+
+	t.Run("default_lightest_color", func(t *testing.T) {
+		backgroundColor, err := resolveBackgroundColor("", []paletteColor{
+			{Code: "A", RGBA: color.RGBA{R: 200, G: 200, B: 200, A: 255}},
+			{Code: "B", RGBA: color.RGBA{R: 10, G: 20, B: 30, A: 255}},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, color.RGBA{R: 200, G: 200, B: 200, A: 255}, backgroundColor)
+	})
+
+	t.Run("processing_skips_background_when_server_unavailable", func(t *testing.T) {
+		server := httptest.NewServer(stableDiffusion.NewFakeServer())
+		server.Close()
+
+		sourcePath := filepath.Join(t.TempDir(), "source.png")
+		assert.NoError(t, imaging.Save(source, sourcePath))
+
+		couponID := uuid.New()
+		record := &Image{ID: uuid.New(), CouponID: couponID, Status: "uploaded", OriginalImageS3Key: "file://" + sourcePath}
+		mockImageRepo := new(MockImageRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		mockImageRepo.On("GetByID", mock.Anything, record.ID).Return(record, nil)
+		mockImageRepo.On("UpdateUnlessCancelled", mock.Anything, record).Return(true, nil)
+		mockCouponRepo.On("GetByID", mock.Anything, couponID).Return(&Coupon{ID: couponID, Size: "30x40", Style: "max_colors"}, nil)
+
+		service := newService(t, stableDiffusion.BackendAutomatic1111, server.URL)
+		service.deps.ImageRepository = mockImageRepo
+		service.deps.CouponRepository = mockCouponRepo
+
+		err := service.ProcessImage(context.Background(), record.ID, &ProcessingParams{Style: "max_colors", Background: &BackgroundParams{Mode: "blur"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "processed", record.Status)
+		assert.True(t, record.ProcessingParams.BackgroundSkipped)
+		assert.Nil(t, record.ProcessedImageS3Key)
+	})
+
+	t.Run("processing_keeps_replaced_background", func(t *testing.T) {
+		server := httptest.NewServer(stableDiffusion.NewFakeServer())
+		defer server.Close()
+
+		sourcePath := filepath.Join(t.TempDir(), "source.png")
+		assert.NoError(t, imaging.Save(source, sourcePath))
+
+		couponID := uuid.New()
+		record := &Image{ID: uuid.New(), CouponID: couponID, Status: "uploaded", OriginalImageS3Key: "file://" + sourcePath}
+		mockImageRepo := new(MockImageRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		mockImageRepo.On("GetByID", mock.Anything, record.ID).Return(record, nil)
+		mockImageRepo.On("UpdateUnlessCancelled", mock.Anything, record).Return(true, nil)
+		mockCouponRepo.On("GetByID", mock.Anything, couponID).Return(&Coupon{ID: couponID, Size: "30x40", Style: "pop_art", ProductType: mosaic.ProductPaintByNumbers}, nil)
+
+		service := newService(t, stableDiffusion.BackendAutomatic1111, server.URL)
+		service.deps.ImageRepository = mockImageRepo
+		service.deps.CouponRepository = mockCouponRepo
+
+		err := service.ProcessImage(context.Background(), record.ID, &ProcessingParams{Style: "pop_art", Background: &BackgroundParams{Mode: "color", ColorCode: "p04"}})
+		assert.NoError(t, err)
+		assert.Equal(t, "processed", record.Status)
+		assert.False(t, record.ProcessingParams.BackgroundSkipped)
+		if assert.NotNil(t, record.ProcessedImageS3Key) {
+			processed, err := imaging.Open(strings.TrimPrefix(*record.ProcessedImageS3Key, "file://"))
+			assert.NoError(t, err)
+			r, g, b, _ := processed.At(2, 2).RGBA()
+			assert.InDelta(t, 236, r>>8, 6)
+			assert.InDelta(t, 56, g>>8, 6)
+			assert.InDelta(t, 32, b>>8, 6)
+		}
+	})
+}
+
+func TestImageService_SimulateStyle(t *testing.T) {
+	paletteDir := t.TempDir()
+	writeDiamondPalettes(t, paletteDir)
+	service := &ImageService{deps: &ImageServiceDeps{
+		PaletteService: palette.NewPaletteService(paletteDir, middleware.NewLogger()),
+	}}
+
+	source := imaging.New(420, 600, color.White)
+	for y := 0; y < 600; y++ {
+		for x := 0; x < 420; x++ {
+			source.Set(x, y, color.NRGBA{R: uint8(x * 255 / 420), G: uint8(y * 255 / 600), B: 120, A: 255})
+		}
+	}
+
+	paletteOf := func(t *testing.T, productType, style string) map[color.RGBA]bool {
+		colors, err := service.kitPaletteColors(productType, style)
+		assert.NoError(t, err)
+		set := make(map[color.RGBA]bool, len(colors))
+		for _, c := range colors {
+			set[c.RGBA] = true
+		}
+		return set
+	}
+	rgbaAt := func(img interface{ At(x, y int) color.Color }, x, y int) color.RGBA {
+		return color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+	}
+
+	t.Run("diamond_mosaic_drills", func(t *testing.T) {
+		result, err := service.SimulateStyle(source, "", "grayscale", "21x30")
+		assert.NoError(t, err)
+		// 210x300 drills, 4 pixels each
+		assert.Equal(t, 840, result.Bounds().Dx())
+		assert.Equal(t, 1200, result.Bounds().Dy())
+
+		colors := paletteOf(t, "", "grayscale")
+		assert.True(t, colors[color.RGBA{R: 108, G: 108, B: 108, A: 255}], "drill colors come from xlsx palette")
+		for y := 0; y < 300; y += 17 {
+			for x := 0; x < 210; x += 13 {
+				drill := rgbaAt(result, x*4+2, y*4+2)
+				assert.True(t, colors[drill], "drill %d,%d is palette color", x, y)
+				if gap := rgbaAt(result, x*4+3, y*4+1); drill.G > 0 {
+					assert.Less(t, gap.G, drill.G, "gap between drills is darker")
+				}
+			}
+		}
+	})
+
+	t.Run("paint_by_numbers_cells", func(t *testing.T) {
+		result, err := service.SimulateStyle(source, mosaic.ProductPaintByNumbers, "pop_art", "30x40")
+		assert.NoError(t, err)
+		assert.Equal(t, 900, result.Bounds().Dx())
+		assert.Equal(t, 1200, result.Bounds().Dy())
+
+		colors := paletteOf(t, mosaic.ProductPaintByNumbers, "pop_art")
+		used := map[color.RGBA]bool{}
+		for y := 0; y < 1200; y += 7 {
+			for x := 0; x < 900; x += 7 {
+				c := rgbaAt(result, x, y)
+				assert.True(t, colors[c])
+				used[c] = true
+			}
+		}
+		assert.Greater(t, len(used), 3, "gradient uses several paints")
+	})
+
+	t.Run("fuse_beads_rings", func(t *testing.T) {
+		result, err := service.SimulateStyle(source, mosaic.ProductFuseBeads, "max_colors", "30x40")
+		assert.NoError(t, err)
+		// 60x80 beads of 5mm pitch
+		assert.Equal(t, 900, result.Bounds().Dx())
+		assert.Equal(t, 1200, result.Bounds().Dy())
+
+		colors := paletteOf(t, mosaic.ProductFuseBeads, "max_colors")
+		assert.False(t, colors[rgbaAt(result, 0, 0)], "pegboard between beads")
+		assert.False(t, colors[rgbaAt(result, 7, 7)], "hole in bead")
+		assert.True(t, colors[rgbaAt(result, 2, 7)], "ring of bead")
+	})
+
+	t.Run("unknown_style", func(t *testing.T) {
+		_, err := service.SimulateStyle(source, mosaic.ProductPaintByNumbers, "sepia", "30x40")
+		assert.NoError(t, err, "unknown style falls back to max colors palette")
+	})
+}
+
+func TestImageService_RenderMockups(t *testing.T) {
+	sourcePath := filepath.Join(t.TempDir(), "processed.png")
+	assert.NoError(t, imaging.Save(imaging.New(420, 600, color.NRGBA{R: 200, G: 60, B: 40, A: 255}), sourcePath))
+	sourceKey := "file://" + sourcePath
+	scenes := mockup.BuiltinScenes()
+	paletteDir := t.TempDir()
+	writeDiamondPalettes(t, paletteDir)
+
+	newService := func(mockImageRepo *MockImageRepository, mockCouponRepo *MockCouponRepository, mockS3 *MockS3Client) *ImageService {
+		return &ImageService{deps: &ImageServiceDeps{
+			ImageRepository:  mockImageRepo,
+			CouponRepository: mockCouponRepo,
+			S3Client:         mockS3,
+			PaletteService:   palette.NewPaletteService(paletteDir, middleware.NewLogger()),
+			OptimizeQuality:  85,
+			MockupEnabled:    true,
+			MockupScenes:     scenes,
+		}}
+	}
+
+	t.Run("renders_all_scenes", func(t *testing.T) {
+		coupon := &Coupon{ID: uuid.New(), Size: "21x30", Style: "max_colors"}
+		record := &Image{ID: uuid.New(), CouponID: coupon.ID, Status: "completed", ProcessedImageS3Key: &sourceKey}
+		mockImageRepo := new(MockImageRepository)
+		mockCouponRepo := new(MockCouponRepository)
+		mockS3 := new(MockS3Client)
+
+		mockImageRepo.On("GetByID", mock.Anything, record.ID).Return(record, nil)
+		mockCouponRepo.On("GetByID", mock.Anything, coupon.ID).Return(coupon, nil)
+		for _, scene := range scenes {
+			key := fmt.Sprintf("mockups/%s/%s/%s.jpg", coupon.ID, record.ID, scene.Name)
+			mockS3.On("UploadFileWithKey", mock.Anything, mock.Anything, mock.AnythingOfType("int64"), "image/jpeg", key).
+				Run(func(args mock.Arguments) {
+					rendered, err := imaging.Decode(args.Get(1).(io.Reader))
+					assert.NoError(t, err)
+					assert.Equal(t, 1600, rendered.Bounds().Dx())
+					assert.Equal(t, 1200, rendered.Bounds().Dy())
+				}).
+				Return(key, nil)
+		}
+		mockImageRepo.On("UpdateColumns", mock.Anything, record, []string{"mockups"}).Return(nil)
+
+		err := newService(mockImageRepo, mockCouponRepo, mockS3).RenderMockups(context.Background(), record.ID)
+		assert.NoError(t, err)
+		assert.Len(t, record.Mockups, len(scenes))
+		assert.Equal(t, fmt.Sprintf("mockups/%s/%s/wall.jpg", coupon.ID, record.ID), record.Mockups["wall"])
+		mockImageRepo.AssertExpectations(t)
+		mockS3.AssertExpectations(t)
+	})
+
+	t.Run("schema_not_completed", func(t *testing.T) {
+		record := &Image{ID: uuid.New(), Status: "processed", ProcessedImageS3Key: &sourceKey}
+		mockImageRepo := new(MockImageRepository)
+		mockS3 := new(MockS3Client)
+		mockImageRepo.On("GetByID", mock.Anything, record.ID).Return(record, nil)
+
+		err := newService(mockImageRepo, new(MockCouponRepository), mockS3).RenderMockups(context.Background(), record.ID)
+		assert.NoError(t, err)
+		assert.Nil(t, record.Mockups)
+		mockS3.AssertNotCalled(t, "UploadFileWithKey", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("unknown_scene", func(t *testing.T) {
+		_, err := mockup.Select([]string{"wall", "garden"}, "")
+		assert.ErrorIs(t, err, mockup.ErrUnknownScene)
+
+		selected, err := mockup.Select([]string{"table"}, t.TempDir())
+		assert.NoError(t, err)
+		assert.Len(t, selected, 1)
+	})
+}
@@ -55,6 +55,7 @@ func NewPartnerHandler(router fiber.Router, deps *PartnerHandlerDeps) {
 	protected.Get("/coupons/:id/download-materials", handler.DownloadCouponMaterials) // GET /api/partner/coupons/:id/download-materials
 	protected.Get("/statistics", handler.GetMyStatistics)                             // GET /api/partner/statistics
 	protected.Get("/statistics/comparison", handler.GetComparisonStatistics)          // GET /api/partner/statistics/comparison
+	protected.Get("/gallery", handler.GetGallery)                                     // GET /api/partner/gallery
 }
 
 // @Summary Get partner dashboard data
@@ -487,6 +488,60 @@ func (handler *PartnerHandler) GetComparisonStatistics(c *fiber.Ctx) error {
 	return c.JSON(result)
 }
 
+// @Summary Get gallery of finished mosaics
+// @Description Returns completed partner's coupons with links to mockups of finished mosaic framed on wall and table scenes
+// @Tags partner-coupons
+// @Produce json
+// @Security BearerAuth
+// @Param page query integer false "Page number" default(1)
+// @Param limit query integer false "Number of items per page" default(24) minimum(1) maximum(100)
+// @Success 200 {object} GalleryResponse "Gallery of finished mosaics with pagination info"
+// @Failure 401 {object} map[string]any "Unauthorized: JWT token is missing or invalid"
+// @Failure 403 {object} map[string]any "Forbidden: User does not have partner role"
+// @Failure 500 {object} map[string]any "Internal server error when retrieving gallery"
+// @Router /partner/gallery [get]
+func (handler *PartnerHandler) GetGallery(c *fiber.Ctx) error {
+	claims, err := jwt.GetClaimsFromFiberContext(c)
+	if err != nil {
+		handler.deps.Logger.FromContext(c).Warn().
+			Err(err).
+			Str("handler", "GetGallery").
+			Msg("Failed to get JWT claims")
+
+		errorResponse := fiber.Map{
+			"error":      "Failed to get JWT claims",
+			"request_id": c.Get("X-Request-ID"),
+		}
+		return c.Status(fiber.StatusUnauthorized).JSON(errorResponse)
+	}
+
+	gallery, err := handler.deps.PartnerService.GetGallery(c.UserContext(), claims.UserID, c.QueryInt("page", 1), c.QueryInt("limit", 24))
+	if err != nil {
+		handler.deps.Logger.FromContext(c).Error().
+			Err(err).
+			Str("handler", "GetGallery").
+			Str("partner_id", claims.UserID.String()).
+			Msg("Failed to get gallery")
+
+		errorResponse := fiber.Map{
+			"error":      "Failed to get gallery",
+			"request_id": c.Get("X-Request-ID"),
+		}
+		if os.Getenv("ENVIRONMENT") == "development" || os.Getenv("ENVIRONMENT") == "dev" {
+			errorResponse["details"] = err.Error()
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
+	}
+
+	handler.deps.Logger.FromContext(c).Info().
+		Str("handler", "GetGallery").
+		Str("partner_id", claims.UserID.String()).
+		Int("total", gallery.Total).
+		Msg("Partner gallery retrieved successfully")
+
+	return c.JSON(gallery)
+}
+
 // @Summary Download coupon materials
 // @Description Downloads archive with materials of a redeemed partner's coupon (original, preview, scheme)
 // @Tags partner-coupons
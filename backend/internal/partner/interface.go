@@ -71,6 +71,7 @@ type PartnerServiceInterface interface {
 	ExportCoupons(partnerID uuid.UUID, status string, format string) ([]byte, string, string, error)
 	GetComparisonStatistics(ctx context.Context, partnerID uuid.UUID) (map[string]any, error)
 	DownloadCouponMaterials(id uuid.UUID) ([]byte, string, error)
+	GetGallery(ctx context.Context, partnerID uuid.UUID, page, limit int) (*GalleryResponse, error)
 
 	GetPartnerRepository() PartnerRepositoryInterface
 	GetCouponRepository() CouponRepositoryInterface
@@ -117,8 +118,10 @@ type BrandingHelperInterface interface {
 
 type ImageRepositoryInterface interface {
 	GetByCouponID(ctx context.Context, couponID uuid.UUID) (*image.Image, error)
+	GetGalleryByPartner(ctx context.Context, partnerID uuid.UUID, limit, offset int) ([]*image.GalleryImage, int, error)
 }
 
 type S3ClientInterface interface {
 	DownloadFile(ctx context.Context, key string) (io.ReadCloser, error)
+	GetFileURL(ctx context.Context, key string, expiry time.Duration) (string, error)
 }
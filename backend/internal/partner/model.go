@@ -37,6 +37,7 @@ type Partner struct {
 	AllowPurchases  bool      `bun:"allow_purchases,default:true" json:"allow_purchases"`
 	Status          string    `bun:"status,type:partner_status,default:'active'" json:"status"`
 	IsBlockedInChat bool      `bun:"is_blocked_in_chat,default:false" json:"is_blocked_in_chat"`
+	QueueWeight     int       `bun:"queue_weight,notnull,default:1" json:"queue_weight"` // Share of image queue relative to other partners
 	CreatedAt       time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
 	UpdatedAt       time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
 
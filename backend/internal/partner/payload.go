@@ -52,6 +52,7 @@ type UpdatePartnerRequest struct {
 	BrandColors     *[]string `json:"brand_colors" validate:"omitempty,max=3,dive,hex_color"`
 	AllowSales      *bool     `json:"allow_sales"`
 	Status          *string   `json:"status" validate:"omitempty,oneof=active inactive pending"`
+	QueueWeight     *int      `json:"queue_weight" validate:"omitempty,min=1,max=100"`
 }
 
 type ExportCouponRequest struct {
@@ -135,6 +136,26 @@ type PartnerCouponsResponse struct {
 	Pages   int                 `json:"pages"`
 }
 
+// GalleryItem is finished mosaic of partner coupon with links to its mockup renders
+type GalleryItem struct {
+	ImageID     uuid.UUID         `json:"image_id"`
+	CouponID    uuid.UUID         `json:"coupon_id"`
+	CouponCode  string            `json:"coupon_code"`
+	Size        string            `json:"size"`
+	Style       string            `json:"style"`
+	ProductType string            `json:"product_type"`
+	CompletedAt *time.Time        `json:"completed_at"`
+	MockupURLs  map[string]string `json:"mockup_urls"` // Mockup render URLs by scene name
+}
+
+type GalleryResponse struct {
+	Items []GalleryItem `json:"items"`
+	Total int           `json:"total"`
+	Page  int           `json:"page"`
+	Limit int           `json:"limit"`
+	Pages int           `json:"pages"`
+}
+
 type PartnerDashboardResponse struct {
 	Statistics     PartnerStatistics   `json:"statistics"`
 	RecentActivity []PartnerCouponInfo `json:"recent_activity"`
@@ -26,6 +26,11 @@ type PartnerServiceDeps struct {
 	Config            ConfigInterface
 }
 
+const (
+	defaultGalleryLimit = 24
+	maxGalleryLimit     = 100
+)
+
 type PartnerService struct {
 	deps *PartnerServiceDeps
 }
@@ -255,6 +260,49 @@ func (s *PartnerService) DownloadCouponMaterials(id uuid.UUID) ([]byte, string,
 	return data, filename, nil
 }
 
+// GetGallery returns finished mosaics of partner coupons with links to their mockups, newest first
+func (s *PartnerService) GetGallery(ctx context.Context, partnerID uuid.UUID, page, limit int) (*GalleryResponse, error) {
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > maxGalleryLimit {
+		limit = defaultGalleryLimit
+	}
+
+	images, total, err := s.deps.ImageRepository.GetGalleryByPartner(ctx, partnerID, limit, (page-1)*limit)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]GalleryItem, 0, len(images))
+	for _, img := range images {
+		urls := make(map[string]string, len(img.Mockups))
+		for scene, key := range img.Mockups {
+			if url, err := s.deps.S3Client.GetFileURL(ctx, key, 24*time.Hour); err == nil {
+				urls[scene] = url
+			}
+		}
+		items = append(items, GalleryItem{
+			ImageID:     img.ImageID,
+			CouponID:    img.CouponID,
+			CouponCode:  img.CouponCode,
+			Size:        img.Size,
+			Style:       img.Style,
+			ProductType: img.ProductType,
+			CompletedAt: img.CompletedAt,
+			MockupURLs:  urls,
+		})
+	}
+
+	return &GalleryResponse{
+		Items: items,
+		Total: total,
+		Page:  page,
+		Limit: limit,
+		Pages: (total + limit - 1) / limit,
+	}, nil
+}
+
 // InitializeArticleGrid creates empty article grid for partner
 func (s *PartnerService) InitializeArticleGrid(partnerID uuid.UUID) error {
 	return s.deps.PartnerRepository.InitializeArticleGrid(context.Background(), partnerID)
@@ -3,11 +3,13 @@ package partner
 import (
 	"context"
 	"errors"
+	"io"
 	"testing"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/skr1ms/mosaic/internal/coupon"
+	"github.com/skr1ms/mosaic/internal/image"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -338,6 +340,44 @@ func createTestPartner() *Partner {
 	}
 }
 
+// Mock image repository and S3 client
+type MockImageRepository struct {
+	mock.Mock
+}
+
+func (m *MockImageRepository) GetByCouponID(ctx context.Context, couponID uuid.UUID) (*image.Image, error) {
+	args := m.Called(ctx, couponID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*image.Image), args.Error(1)
+}
+
+func (m *MockImageRepository) GetGalleryByPartner(ctx context.Context, partnerID uuid.UUID, limit, offset int) ([]*image.GalleryImage, int, error) {
+	args := m.Called(ctx, partnerID, limit, offset)
+	if args.Get(0) == nil {
+		return nil, 0, args.Error(2)
+	}
+	return args.Get(0).([]*image.GalleryImage), args.Int(1), args.Error(2)
+}
+
+type MockS3Client struct {
+	mock.Mock
+}
+
+func (m *MockS3Client) DownloadFile(ctx context.Context, key string) (io.ReadCloser, error) {
+	args := m.Called(ctx, key)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockS3Client) GetFileURL(ctx context.Context, key string, expiry time.Duration) (string, error) {
+	args := m.Called(ctx, key, expiry)
+	return args.String(0), args.Error(1)
+}
+
 func createTestExportCoupon() *ExportCouponRequest {
 	return &ExportCouponRequest{
 		CouponCode:    "TEST-1234-5678",
@@ -870,3 +910,57 @@ func TestPartnerService_ArticleConstants(t *testing.T) {
 	assert.Len(t, AvailableStyles, 4)
 	assert.Len(t, Marketplaces, 2)
 }
+
+func TestPartnerService_GetGallery(t *testing.T) {
+	partnerID := uuid.New()
+	completedAt := time.Now()
+
+	t.Run("mockup_urls", func(t *testing.T) {
+		mockImageRepo := new(MockImageRepository)
+		mockS3 := new(MockS3Client)
+		service := NewPartnerService(&PartnerServiceDeps{ImageRepository: mockImageRepo, S3Client: mockS3})
+
+		gallery := []*image.GalleryImage{{
+			ImageID:     uuid.New(),
+			CouponID:    uuid.New(),
+			CouponCode:  "1234-5678-9012",
+			Size:        "30x40",
+			Style:       "pop_art",
+			Mockups:     map[string]string{"wall": "mockups/wall.jpg", "table": "mockups/table.jpg"},
+			CompletedAt: &completedAt,
+		}}
+		mockImageRepo.On("GetGalleryByPartner", mock.Anything, partnerID, 10, 10).Return(gallery, 25, nil)
+		mockS3.On("GetFileURL", mock.Anything, "mockups/wall.jpg", 24*time.Hour).Return("https://s3/wall.jpg", nil)
+		mockS3.On("GetFileURL", mock.Anything, "mockups/table.jpg", 24*time.Hour).Return("", errors.New("unavailable"))
+
+		result, err := service.GetGallery(context.Background(), partnerID, 2, 10)
+		assert.NoError(t, err)
+		assert.Equal(t, 25, result.Total)
+		assert.Equal(t, 3, result.Pages)
+		assert.Len(t, result.Items, 1)
+		assert.Equal(t, "1234-5678-9012", result.Items[0].CouponCode)
+		assert.Equal(t, map[string]string{"wall": "https://s3/wall.jpg"}, result.Items[0].MockupURLs)
+		mockImageRepo.AssertExpectations(t)
+	})
+
+	t.Run("default_paging", func(t *testing.T) {
+		mockImageRepo := new(MockImageRepository)
+		service := NewPartnerService(&PartnerServiceDeps{ImageRepository: mockImageRepo, S3Client: new(MockS3Client)})
+		mockImageRepo.On("GetGalleryByPartner", mock.Anything, partnerID, defaultGalleryLimit, 0).Return([]*image.GalleryImage{}, 0, nil)
+
+		result, err := service.GetGallery(context.Background(), partnerID, 0, 1000)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Page)
+		assert.Equal(t, defaultGalleryLimit, result.Limit)
+		assert.Empty(t, result.Items)
+	})
+
+	t.Run("repository_error", func(t *testing.T) {
+		mockImageRepo := new(MockImageRepository)
+		service := NewPartnerService(&PartnerServiceDeps{ImageRepository: mockImageRepo, S3Client: new(MockS3Client)})
+		mockImageRepo.On("GetGalleryByPartner", mock.Anything, partnerID, defaultGalleryLimit, 0).Return(nil, 0, errors.New("db down"))
+
+		_, err := service.GetGallery(context.Background(), partnerID, 1, 0)
+		assert.Error(t, err)
+	})
+}
@@ -35,6 +35,12 @@ const (
 	StyleMaxColors = "max_colors"
 )
 
+const (
+	ProductTypeDiamondMosaic  = "diamond_mosaic"
+	ProductTypePaintByNumbers = "paint_by_numbers"
+	ProductTypeFuseBeads      = "fuse_beads"
+)
+
 type Order struct {
 	bun.BaseModel `bun:"table:orders"`
 
@@ -46,6 +52,10 @@ type Order struct {
 	Size  string `bun:"size,notnull" json:"size"`
 	Style string `bun:"style,notnull" json:"style"`
 
+	ProductType string `bun:"product_type,notnull,default:'diamond_mosaic'" json:"product_type"`
+	Panels      int    `bun:"panels,nullzero,notnull,default:1" json:"panels"`
+	PanelGapMM  int    `bun:"panel_gap_mm,notnull,default:0" json:"panel_gap_mm"`
+
 	UserEmail string `bun:"user_email,notnull" json:"user_email"`
 
 	Amount   int64  `bun:"amount,notnull" json:"amount"`
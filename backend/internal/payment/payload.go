@@ -1,13 +1,16 @@
 package payment
 
 type PurchaseCouponRequest struct {
-	Size      string  `json:"size" validate:"required,oneof=21x30 30x40 40x40 40x50 40x60 50x70"`
-	Style     string  `json:"style" validate:"required,oneof=grayscale skin_tone pop_art max_colors"`
-	Email     string  `json:"email" validate:"required,email"`
-	ReturnURL string  `json:"return_url" validate:"required,url"`
-	FailURL   *string `json:"fail_url,omitempty" validate:"omitempty,url"`
-	Language  string  `json:"language,omitempty" validate:"omitempty,oneof=ru en es"`
-	Domain    *string `json:"domain,omitempty"`
+	Size        string  `json:"size" validate:"required,oneof=21x30 30x40 40x40 40x50 40x60 50x70"`
+	Style       string  `json:"style" validate:"required,oneof=grayscale skin_tone pop_art max_colors"`
+	ProductType string  `json:"product_type,omitempty" validate:"omitempty,oneof=diamond_mosaic paint_by_numbers fuse_beads"`
+	Panels      int     `json:"panels,omitempty" validate:"omitempty,min=1,max=4"`
+	PanelGapMM  int     `json:"panel_gap_mm,omitempty" validate:"omitempty,min=0,max=200"`
+	Email       string  `json:"email" validate:"required,email"`
+	ReturnURL   string  `json:"return_url" validate:"required,url"`
+	FailURL     *string `json:"fail_url,omitempty" validate:"omitempty,url"`
+	Language    string  `json:"language,omitempty" validate:"omitempty,oneof=ru en es"`
+	Domain      *string `json:"domain,omitempty"`
 }
 
 type PurchaseCouponResponse struct {
@@ -249,6 +249,26 @@ func (s *PaymentService) PurchaseCoupon(ctx context.Context, req *PurchaseCoupon
 		}, nil
 	}
 
+	productType := req.ProductType
+	if productType == "" {
+		productType = ProductTypeDiamondMosaic
+	}
+
+	if !coupon.IsSizeAvailableForProductType(coupon.CouponProductType(productType), coupon.CouponSize(req.Size)) {
+		return &PurchaseCouponResponse{
+			Success: false,
+			Message: "Size is not available for product type",
+		}, nil
+	}
+
+	if err := coupon.ValidatePanelLayout(req.Panels, req.PanelGapMM); err != nil {
+		return &PurchaseCouponResponse{
+			Success: false,
+			Message: "Invalid panel layout",
+		}, nil
+	}
+	panels := max(req.Panels, 1)
+
 	var partnerID *uuid.UUID
 	if req.Domain != nil && *req.Domain != "" {
 		partner, err := s.deps.PartnerRepository.GetByDomain(ctx, *req.Domain)
@@ -274,6 +294,9 @@ func (s *PaymentService) PurchaseCoupon(ctx context.Context, req *PurchaseCoupon
 		PartnerID:   partnerID,
 		Size:        req.Size,
 		Style:       style,
+		ProductType: productType,
+		Panels:      panels,
+		PanelGapMM:  req.PanelGapMM,
 		UserEmail:   req.Email,
 		Amount:      int64(FixedPriceRub * 100),
 		Currency:    "RUB",
@@ -500,6 +523,9 @@ func (s *PaymentService) createCouponForOrder(ctx context.Context, order *Order)
 		PartnerID:     partnerID,
 		Size:          order.Size,
 		Style:         order.Style,
+		ProductType:   order.ProductType,
+		Panels:        order.Panels,
+		PanelGapMM:    order.PanelGapMM,
 		Status:        "new",
 		IsPurchased:   true,
 		PurchaseEmail: &order.UserEmail,
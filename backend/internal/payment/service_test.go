@@ -1095,6 +1095,41 @@ func TestPaymentService_PurchaseCoupon_Comprehensive(t *testing.T) {
 			expectedError:   false,
 			expectedSuccess: true,
 		},
+		{
+			name: "multi_panel_purchase_charged_fixed_price",
+			request: &PurchaseCouponRequest{
+				Size:       "40x50",
+				Style:      "max_colors",
+				Email:      "test@example.com",
+				ReturnURL:  "https://example.com/return",
+				Panels:     3,
+				PanelGapMM: 20,
+			},
+			mockSetup: func(paymentRepo *MockPaymentRepository, partnerRepo *MockPartnerRepository, alfaClient *MockAlfaBankClient, config *MockConfig) {
+				paymentRepo.On("GetOrderByNumber", mock.Anything, mock.Anything).Return(nil, errors.New("not found"))
+				paymentRepo.On("CreateOrder", mock.Anything, mock.MatchedBy(func(order *Order) bool {
+					return order.Panels == 3 && order.Amount == int64(FixedPriceRub*100)
+				})).Return(nil)
+				paymentRepo.On("UpdateOrderStatus", mock.Anything, mock.Anything, OrderStatusPending, mock.Anything).Return(nil)
+				paymentRepo.On("UpdateOrderPaymentURL", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+
+				alfaClient.On("RegisterOrder", mock.Anything, mock.MatchedBy(func(req *AlfaBankRegisterRequest) bool {
+					return req.Amount == int64(FixedPriceRub*100)
+				})).Return(&AlfaBankRegisterResponse{
+					OrderId: "ALFA_ORDER_789",
+					FormUrl: "https://pay.alfabank.ru/payment/form3",
+				}, nil)
+
+				config.On("GetAlfaBankConfig").Return(AlphaBankConfig{
+					WebhookURL: "https://test.com/webhook",
+				})
+				config.On("GetServerConfig").Return(ServerConfig{
+					FrontendURL: "https://test.com",
+				}).Maybe()
+			},
+			expectedError:   false,
+			expectedSuccess: true,
+		},
 		{
 			name: "alfa_bank_api_error",
 			request: &PurchaseCouponRequest{
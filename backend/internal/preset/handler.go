@@ -0,0 +1,368 @@
+package preset
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/pkg/jwt"
+	"github.com/skr1ms/mosaic/pkg/middleware"
+)
+
+// maxSampleSize limits size of sample image uploaded for dry run
+const maxSampleSize = 15 << 20
+
+type PresetHandlerDeps struct {
+	PresetService PresetServiceInterface
+	JwtService    JWTServiceInterface
+	Logger        *middleware.Logger
+}
+
+type PresetHandler struct {
+	fiber.Router
+	deps *PresetHandlerDeps
+}
+
+func NewPresetHandler(router fiber.Router, deps *PresetHandlerDeps) {
+	handler := &PresetHandler{
+		Router: router,
+		deps:   deps,
+	}
+
+	jwtConcrete, ok := deps.JwtService.(*jwt.JWT)
+	if !ok {
+		panic("JwtService must be *jwt.JWT for middleware")
+	}
+
+	// ================================================================
+	// ADMIN PROMPT PRESET ROUTES: /api/admin/prompt-presets/*
+	// Access: admin and main_admin roles only
+	// ================================================================
+	presets := router.Group("/admin/prompt-presets")
+	presets.Use(middleware.JWTMiddleware(jwtConcrete, deps.Logger), middleware.AdminOrMainAdmin())
+
+	presets.Get("/", handler.GetPresets)                                   // GET /api/admin/prompt-presets
+	presets.Post("/", handler.CreatePreset)                                // POST /api/admin/prompt-presets
+	presets.Get("/defaults", handler.GetDefaultPresets)                    // GET /api/admin/prompt-presets/defaults
+	presets.Get("/:id", handler.GetPreset)                                 // GET /api/admin/prompt-presets/:id
+	presets.Put("/:id", handler.UpdatePreset)                              // PUT /api/admin/prompt-presets/:id
+	presets.Delete("/:id", handler.DeletePreset)                           // DELETE /api/admin/prompt-presets/:id
+	presets.Get("/:id/versions", handler.GetVersions)                      // GET /api/admin/prompt-presets/:id/versions
+	presets.Post("/:id/versions/:version/restore", handler.RestoreVersion) // POST /api/admin/prompt-presets/:id/versions/:version/restore
+	presets.Post("/:id/dry-run", handler.DryRun)                           // POST /api/admin/prompt-presets/:id/dry-run
+}
+
+func (h *PresetHandler) errorResponse(c *fiber.Ctx, status int, message string, err error) error {
+	response := fiber.Map{
+		"error":      message,
+		"request_id": c.Get("X-Request-ID"),
+	}
+	if err != nil && (os.Getenv("ENVIRONMENT") == "development" || os.Getenv("ENVIRONMENT") == "dev") {
+		response["details"] = err.Error()
+	}
+	return c.Status(status).JSON(response)
+}
+
+// serviceError maps errors of preset service to responses, unknown errors are logged
+func (h *PresetHandler) serviceError(c *fiber.Ctx, handlerName, message string, err error) error {
+	switch {
+	case errors.Is(err, ErrPresetNotFound):
+		return h.errorResponse(c, fiber.StatusNotFound, "Prompt preset not found", nil)
+	case errors.Is(err, ErrVersionNotFound):
+		return h.errorResponse(c, fiber.StatusNotFound, "Prompt preset version not found", nil)
+	case errors.Is(err, ErrPresetExists):
+		return h.errorResponse(c, fiber.StatusConflict, "Prompt preset for this style and lighting already exists", nil)
+	case errors.Is(err, ErrInvalidImage):
+		return h.errorResponse(c, fiber.StatusBadRequest, "Sample image must be JPG or PNG", err)
+	}
+
+	h.deps.Logger.FromContext(c).Error().
+		Err(err).
+		Str("handler", handlerName).
+		Str("preset_id", c.Params("id")).
+		Msg(message)
+	return h.errorResponse(c, fiber.StatusInternalServerError, message, err)
+}
+
+// @Summary List prompt presets
+// @Description Returns Stable Diffusion prompt presets set by admins, styles without preset use built-in one
+// @Tags prompt-presets
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]any "Presets"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/prompt-presets [get]
+func (h *PresetHandler) GetPresets(c *fiber.Ctx) error {
+	presets, err := h.deps.PresetService.GetPresets(c.UserContext())
+	if err != nil {
+		return h.serviceError(c, "GetPresets", "Failed to get prompt presets", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"presets": presets,
+		"total":   len(presets),
+	})
+}
+
+// @Summary Get built-in prompt presets
+// @Description Returns built-in preset of every style used while admins have not set their own
+// @Tags prompt-presets
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} DefaultPresetsResponse "Built-in presets"
+// @Failure 401 {object} map[string]any "Unauthorized"
+// @Router /admin/prompt-presets/defaults [get]
+func (h *PresetHandler) GetDefaultPresets(c *fiber.Ctx) error {
+	return c.JSON(h.deps.PresetService.GetDefaultPresets())
+}
+
+// @Summary Create prompt preset
+// @Description Creates preset for processing style and lighting. Preset without lighting is used with any lighting that has no preset of its own.
+// @Tags prompt-presets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreatePresetRequest true "Preset"
+// @Success 201 {object} PromptPreset "Preset created"
+// @Failure 400 {object} map[string]any "Invalid request"
+// @Failure 409 {object} map[string]any "Preset for style and lighting already exists"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/prompt-presets [post]
+func (h *PresetHandler) CreatePreset(c *fiber.Ctx) error {
+	claims, err := jwt.GetClaimsFromFiberContext(c)
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusUnauthorized, "Failed to get JWT claims", err)
+	}
+
+	var req CreatePresetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Failed to parse request body", err)
+	}
+	if err := middleware.ValidateStruct(&req); err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid request payload", err)
+	}
+
+	preset, err := h.deps.PresetService.CreatePreset(c.UserContext(), &req, claims.Login)
+	if err != nil {
+		return h.serviceError(c, "CreatePreset", "Failed to create prompt preset", err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(preset)
+}
+
+// @Summary Get prompt preset
+// @Tags prompt-presets
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Preset ID"
+// @Success 200 {object} PromptPreset "Preset"
+// @Failure 400 {object} map[string]any "Invalid preset ID"
+// @Failure 404 {object} map[string]any "Preset not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/prompt-presets/{id} [get]
+func (h *PresetHandler) GetPreset(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid preset ID", err)
+	}
+
+	preset, err := h.deps.PresetService.GetPreset(c.UserContext(), id)
+	if err != nil {
+		return h.serviceError(c, "GetPreset", "Failed to get prompt preset", err)
+	}
+
+	return c.JSON(preset)
+}
+
+// @Summary Update prompt preset
+// @Description Replaces generation parameters of preset, previous parameters stay available as earlier version
+// @Tags prompt-presets
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Preset ID"
+// @Param request body UpdatePresetRequest true "Generation parameters"
+// @Success 200 {object} PromptPreset "Preset with new version"
+// @Failure 400 {object} map[string]any "Invalid request"
+// @Failure 404 {object} map[string]any "Preset not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/prompt-presets/{id} [put]
+func (h *PresetHandler) UpdatePreset(c *fiber.Ctx) error {
+	claims, err := jwt.GetClaimsFromFiberContext(c)
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusUnauthorized, "Failed to get JWT claims", err)
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid preset ID", err)
+	}
+
+	var req UpdatePresetRequest
+	if err := c.BodyParser(&req); err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Failed to parse request body", err)
+	}
+	if err := middleware.ValidateStruct(&req); err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid request payload", err)
+	}
+
+	preset, err := h.deps.PresetService.UpdatePreset(c.UserContext(), id, &req, claims.Login)
+	if err != nil {
+		return h.serviceError(c, "UpdatePreset", "Failed to update prompt preset", err)
+	}
+
+	return c.JSON(preset)
+}
+
+// @Summary Delete prompt preset
+// @Description Deletes preset with all its versions, its style and lighting return to built-in preset
+// @Tags prompt-presets
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Preset ID"
+// @Success 200 {object} map[string]any "Preset deleted"
+// @Failure 400 {object} map[string]any "Invalid preset ID"
+// @Failure 404 {object} map[string]any "Preset not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/prompt-presets/{id} [delete]
+func (h *PresetHandler) DeletePreset(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid preset ID", err)
+	}
+
+	if err := h.deps.PresetService.DeletePreset(c.UserContext(), id); err != nil {
+		return h.serviceError(c, "DeletePreset", "Failed to delete prompt preset", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Prompt preset deleted",
+		"id":      id,
+	})
+}
+
+// @Summary List prompt preset versions
+// @Tags prompt-presets
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Preset ID"
+// @Success 200 {object} map[string]any "Versions, newest first"
+// @Failure 400 {object} map[string]any "Invalid preset ID"
+// @Failure 404 {object} map[string]any "Preset not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/prompt-presets/{id}/versions [get]
+func (h *PresetHandler) GetVersions(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid preset ID", err)
+	}
+
+	versions, err := h.deps.PresetService.GetVersions(c.UserContext(), id)
+	if err != nil {
+		return h.serviceError(c, "GetVersions", "Failed to get prompt preset versions", err)
+	}
+
+	return c.JSON(fiber.Map{
+		"versions": versions,
+		"total":    len(versions),
+	})
+}
+
+// @Summary Restore prompt preset version
+// @Description Makes parameters of earlier version current, they are saved as new version
+// @Tags prompt-presets
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Preset ID"
+// @Param version path int true "Version to restore"
+// @Success 200 {object} PromptPreset "Preset with new version"
+// @Failure 400 {object} map[string]any "Invalid preset ID or version"
+// @Failure 404 {object} map[string]any "Preset or version not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /admin/prompt-presets/{id}/versions/{version}/restore [post]
+func (h *PresetHandler) RestoreVersion(c *fiber.Ctx) error {
+	claims, err := jwt.GetClaimsFromFiberContext(c)
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusUnauthorized, "Failed to get JWT claims", err)
+	}
+
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid preset ID", err)
+	}
+	version, err := strconv.Atoi(c.Params("version"))
+	if err != nil || version < 1 {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid preset version", err)
+	}
+
+	preset, err := h.deps.PresetService.RestoreVersion(c.UserContext(), id, version, claims.Login)
+	if err != nil {
+		return h.serviceError(c, "RestoreVersion", "Failed to restore prompt preset version", err)
+	}
+
+	return c.JSON(preset)
+}
+
+// @Summary Dry run prompt preset
+// @Description Generates image from uploaded sample with preset, nothing is saved. Inactive presets and earlier versions can be tested too.
+// @Tags prompt-presets
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Preset ID"
+// @Param image formData file true "Sample JPG or PNG image"
+// @Param version formData int false "Version to test, current when omitted"
+// @Param lighting formData string false "Lighting added to preset used with any lighting (sun, moon, venus)"
+// @Success 200 {object} DryRunResponse "Generated image"
+// @Failure 400 {object} map[string]any "Invalid request"
+// @Failure 404 {object} map[string]any "Preset or version not found"
+// @Failure 500 {object} map[string]any "Generation failed"
+// @Router /admin/prompt-presets/{id}/dry-run [post]
+func (h *PresetHandler) DryRun(c *fiber.Ctx) error {
+	id, err := uuid.Parse(c.Params("id"))
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid preset ID", err)
+	}
+
+	req := &DryRunRequest{
+		PresetID: id,
+		Lighting: c.FormValue("lighting"),
+	}
+	switch req.Lighting {
+	case "", "sun", "moon", "venus":
+	default:
+		return h.errorResponse(c, fiber.StatusBadRequest, "Invalid lighting", nil)
+	}
+	if value := c.FormValue("version"); value != "" {
+		if req.Version, err = strconv.Atoi(value); err != nil || req.Version < 1 {
+			return h.errorResponse(c, fiber.StatusBadRequest, "Invalid preset version", err)
+		}
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Sample image is required", err)
+	}
+	if file.Size > maxSampleSize {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Sample image is too large", nil)
+	}
+	src, err := file.Open()
+	if err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Failed to read sample image", err)
+	}
+	defer src.Close()
+	if req.ImageData, err = io.ReadAll(src); err != nil {
+		return h.errorResponse(c, fiber.StatusBadRequest, "Failed to read sample image", err)
+	}
+
+	result, err := h.deps.PresetService.DryRun(c.UserContext(), req)
+	if err != nil {
+		return h.serviceError(c, "DryRun", "Failed to run prompt preset", err)
+	}
+
+	return c.JSON(result)
+}
@@ -0,0 +1,42 @@
+package preset
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/pkg/jwt"
+	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
+)
+
+type PresetRepositoryInterface interface {
+	Create(ctx context.Context, preset *PromptPreset) error
+	Update(ctx context.Context, preset *PromptPreset) error
+	Delete(ctx context.Context, id uuid.UUID) error
+	GetByID(ctx context.Context, id uuid.UUID) (*PromptPreset, error)
+	GetByStyle(ctx context.Context, style, lighting string) (*PromptPreset, error)
+	FindActive(ctx context.Context, style, lighting string) (*PromptPreset, error)
+	GetAll(ctx context.Context) ([]*PromptPreset, error)
+	GetVersions(ctx context.Context, presetID uuid.UUID) ([]*PromptPresetVersion, error)
+	GetVersion(ctx context.Context, presetID uuid.UUID, version int) (*PromptPresetVersion, error)
+}
+
+type AIClientInterface interface {
+	ProcessImageWithPreset(ctx context.Context, req stableDiffusion.ProcessImageRequest, preset stableDiffusion.PromptPreset) (string, error)
+	EncodeImageToBase64(imageData []byte) string
+}
+
+type PresetServiceInterface interface {
+	CreatePreset(ctx context.Context, req *CreatePresetRequest, createdBy string) (*PromptPreset, error)
+	UpdatePreset(ctx context.Context, id uuid.UUID, req *UpdatePresetRequest, updatedBy string) (*PromptPreset, error)
+	DeletePreset(ctx context.Context, id uuid.UUID) error
+	GetPreset(ctx context.Context, id uuid.UUID) (*PromptPreset, error)
+	GetPresets(ctx context.Context) ([]*PromptPreset, error)
+	GetDefaultPresets() *DefaultPresetsResponse
+	GetVersions(ctx context.Context, id uuid.UUID) ([]*PromptPresetVersion, error)
+	RestoreVersion(ctx context.Context, id uuid.UUID, version int, updatedBy string) (*PromptPreset, error)
+	DryRun(ctx context.Context, req *DryRunRequest) (*DryRunResponse, error)
+}
+
+type JWTServiceInterface interface {
+	ValidateAccessToken(tokenString string) (*jwt.Claims, error)
+}
@@ -0,0 +1,85 @@
+package preset
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
+	"github.com/uptrace/bun"
+)
+
+// PromptPreset is generation parameters of Stable Diffusion for processing style and lighting tuned by admins.
+// Every change increases version and keeps previous parameters in history.
+type PromptPreset struct {
+	bun.BaseModel `bun:"table:prompt_presets,alias:pp"`
+
+	ID                uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	Style             string    `bun:"style,notnull" json:"style"`
+	Lighting          string    `bun:"lighting,notnull" json:"lighting"` // Empty for preset used with any lighting
+	Prompt            string    `bun:"prompt,notnull" json:"prompt"`
+	NegativePrompt    string    `bun:"negative_prompt,notnull" json:"negative_prompt"`
+	Steps             int       `bun:"steps,notnull" json:"steps"`
+	CfgScale          float64   `bun:"cfg_scale,notnull" json:"cfg_scale"`
+	DenoisingStrength float64   `bun:"denoising_strength,notnull" json:"denoising_strength"`
+	Sampler           string    `bun:"sampler,notnull" json:"sampler"`
+	IsActive          bool      `bun:"is_active,notnull,default:true" json:"is_active"` // Inactive preset is ignored and built-in one is used
+	Version           int       `bun:"version,notnull,default:1" json:"version"`
+	UpdatedBy         string    `bun:"updated_by,notnull" json:"updated_by"`
+	CreatedAt         time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+	UpdatedAt         time.Time `bun:"updated_at,nullzero,notnull,default:current_timestamp" json:"updated_at"`
+}
+
+func (p *PromptPreset) CreateIndex() string {
+	return `
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_prompt_presets_style_lighting ON prompt_presets(style, lighting);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_prompt_preset_versions_preset_id ON prompt_preset_versions(preset_id, version);
+	`
+}
+
+// ToGenerationPreset converts preset to parameters used by Stable Diffusion client
+func (p *PromptPreset) ToGenerationPreset() *stableDiffusion.PromptPreset {
+	return &stableDiffusion.PromptPreset{
+		Style:             stableDiffusion.ProcessingStyle(p.Style),
+		Lighting:          stableDiffusion.LightingType(p.Lighting),
+		Prompt:            p.Prompt,
+		NegativePrompt:    p.NegativePrompt,
+		Steps:             p.Steps,
+		CfgScale:          p.CfgScale,
+		DenoisingStrength: p.DenoisingStrength,
+		Sampler:           p.Sampler,
+	}
+}
+
+// PromptPresetVersion is snapshot of preset parameters saved with every version
+type PromptPresetVersion struct {
+	bun.BaseModel `bun:"table:prompt_preset_versions,alias:ppv"`
+
+	ID                uuid.UUID `bun:"id,pk,type:uuid,default:gen_random_uuid()" json:"id"`
+	PresetID          uuid.UUID `bun:"preset_id,type:uuid,notnull" json:"preset_id"`
+	Version           int       `bun:"version,notnull" json:"version"`
+	Prompt            string    `bun:"prompt,notnull" json:"prompt"`
+	NegativePrompt    string    `bun:"negative_prompt,notnull" json:"negative_prompt"`
+	Steps             int       `bun:"steps,notnull" json:"steps"`
+	CfgScale          float64   `bun:"cfg_scale,notnull" json:"cfg_scale"`
+	DenoisingStrength float64   `bun:"denoising_strength,notnull" json:"denoising_strength"`
+	Sampler           string    `bun:"sampler,notnull" json:"sampler"`
+	IsActive          bool      `bun:"is_active,notnull" json:"is_active"`
+	CreatedBy         string    `bun:"created_by,notnull" json:"created_by"`
+	CreatedAt         time.Time `bun:"created_at,nullzero,notnull,default:current_timestamp" json:"created_at"`
+}
+
+// newVersion takes snapshot of current parameters of preset
+func newVersion(p *PromptPreset) *PromptPresetVersion {
+	return &PromptPresetVersion{
+		PresetID:          p.ID,
+		Version:           p.Version,
+		Prompt:            p.Prompt,
+		NegativePrompt:    p.NegativePrompt,
+		Steps:             p.Steps,
+		CfgScale:          p.CfgScale,
+		DenoisingStrength: p.DenoisingStrength,
+		Sampler:           p.Sampler,
+		IsActive:          p.IsActive,
+		CreatedBy:         p.UpdatedBy,
+	}
+}
@@ -0,0 +1,50 @@
+package preset
+
+import (
+	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
+)
+
+// CreatePresetRequest creates preset for style and lighting, empty lighting makes preset apply to any lighting
+type CreatePresetRequest struct {
+	Style    string `json:"style" validate:"required,oneof=grayscale skin_tones pop_art max_colors"`
+	Lighting string `json:"lighting" validate:"omitempty,oneof=sun moon venus"`
+	PresetParams
+}
+
+// PresetParams are generation parameters of preset
+type PresetParams struct {
+	Prompt            string  `json:"prompt" validate:"required,max=2000"`
+	NegativePrompt    string  `json:"negative_prompt" validate:"max=4000"`
+	Steps             int     `json:"steps" validate:"required,min=1,max=150"`
+	CfgScale          float64 `json:"cfg_scale" validate:"required,min=1,max=30"`
+	DenoisingStrength float64 `json:"denoising_strength" validate:"required,gt=0,max=1"`
+	Sampler           string  `json:"sampler" validate:"max=64"` // Empty for default sampler
+	IsActive          *bool   `json:"is_active"`                 // Active when omitted
+}
+
+// UpdatePresetRequest replaces generation parameters of preset and creates its new version
+type UpdatePresetRequest struct {
+	PresetParams
+}
+
+// DefaultPresetsResponse is built-in presets used for styles without preset set by admins
+type DefaultPresetsResponse struct {
+	Presets []stableDiffusion.PromptPreset `json:"presets"`
+}
+
+// DryRunRequest runs preset on sample image without saving result, Version selects one of previous versions
+type DryRunRequest struct {
+	PresetID  uuid.UUID
+	Version   int // Current version when zero
+	ImageData []byte
+	Lighting  string // Lighting of image for preset used with any lighting
+}
+
+// DryRunResponse is image generated by preset
+type DryRunResponse struct {
+	PresetID   uuid.UUID `json:"preset_id"`
+	Version    int       `json:"version"`
+	DurationMs int64     `json:"duration_ms"`
+	Image      string    `json:"image"` // Generated image as data URL
+}
@@ -0,0 +1,142 @@
+package preset
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+type PresetRepository struct {
+	db *bun.DB
+}
+
+func NewPresetRepository(db *bun.DB) *PresetRepository {
+	return &PresetRepository{db: db}
+}
+
+// Create saves preset together with its first version
+func (r *PresetRepository) Create(ctx context.Context, preset *PromptPreset) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewInsert().Model(preset).Returning("*").Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create prompt preset: %w", err)
+		}
+		if _, err := tx.NewInsert().Model(newVersion(preset)).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create prompt preset version: %w", err)
+		}
+		return nil
+	})
+}
+
+// Update saves changed preset and snapshot of its new version
+func (r *PresetRepository) Update(ctx context.Context, preset *PromptPreset) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewUpdate().Model(preset).WherePK().Exec(ctx); err != nil {
+			return fmt.Errorf("failed to update prompt preset: %w", err)
+		}
+		if _, err := tx.NewInsert().Model(newVersion(preset)).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to create prompt preset version: %w", err)
+		}
+		return nil
+	})
+}
+
+// Delete removes preset with all its versions
+func (r *PresetRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		if _, err := tx.NewDelete().Model((*PromptPresetVersion)(nil)).Where("preset_id = ?", id).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to delete prompt preset versions: %w", err)
+		}
+		if _, err := tx.NewDelete().Model((*PromptPreset)(nil)).Where("id = ?", id).Exec(ctx); err != nil {
+			return fmt.Errorf("failed to delete prompt preset: %w", err)
+		}
+		return nil
+	})
+}
+
+func (r *PresetRepository) GetByID(ctx context.Context, id uuid.UUID) (*PromptPreset, error) {
+	preset := new(PromptPreset)
+	err := r.db.NewSelect().Model(preset).Where("pp.id = ?", id).Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPresetNotFound
+		}
+		return nil, fmt.Errorf("failed to find prompt preset: %w", err)
+	}
+	return preset, nil
+}
+
+// GetByStyle returns preset of exactly given style and lighting, active or not
+func (r *PresetRepository) GetByStyle(ctx context.Context, style, lighting string) (*PromptPreset, error) {
+	preset := new(PromptPreset)
+	err := r.db.NewSelect().Model(preset).
+		Where("pp.style = ?", style).
+		Where("pp.lighting = ?", lighting).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPresetNotFound
+		}
+		return nil, fmt.Errorf("failed to find prompt preset: %w", err)
+	}
+	return preset, nil
+}
+
+// FindActive returns active preset for style, preset of given lighting is preferred to one used with any lighting
+func (r *PresetRepository) FindActive(ctx context.Context, style, lighting string) (*PromptPreset, error) {
+	preset := new(PromptPreset)
+	err := r.db.NewSelect().Model(preset).
+		Where("pp.style = ?", style).
+		Where("pp.lighting IN (?, '')", lighting).
+		Where("pp.is_active = TRUE").
+		OrderExpr("pp.lighting DESC").
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPresetNotFound
+		}
+		return nil, fmt.Errorf("failed to find prompt preset: %w", err)
+	}
+	return preset, nil
+}
+
+// GetAll returns presets ordered by style and lighting
+func (r *PresetRepository) GetAll(ctx context.Context) ([]*PromptPreset, error) {
+	var presets []*PromptPreset
+	if err := r.db.NewSelect().Model(&presets).Order("pp.style ASC", "pp.lighting ASC").Scan(ctx); err != nil {
+		return nil, fmt.Errorf("failed to find prompt presets: %w", err)
+	}
+	return presets, nil
+}
+
+// GetVersions returns versions of preset, newest first
+func (r *PresetRepository) GetVersions(ctx context.Context, presetID uuid.UUID) ([]*PromptPresetVersion, error) {
+	var versions []*PromptPresetVersion
+	err := r.db.NewSelect().Model(&versions).
+		Where("ppv.preset_id = ?", presetID).
+		Order("ppv.version DESC").
+		Scan(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find prompt preset versions: %w", err)
+	}
+	return versions, nil
+}
+
+func (r *PresetRepository) GetVersion(ctx context.Context, presetID uuid.UUID, version int) (*PromptPresetVersion, error) {
+	presetVersion := new(PromptPresetVersion)
+	err := r.db.NewSelect().Model(presetVersion).
+		Where("ppv.preset_id = ?", presetID).
+		Where("ppv.version = ?", version).
+		Scan(ctx)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrVersionNotFound
+		}
+		return nil, fmt.Errorf("failed to find prompt preset version: %w", err)
+	}
+	return presetVersion, nil
+}
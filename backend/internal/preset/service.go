@@ -0,0 +1,236 @@
+package preset
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/jpeg"
+	_ "image/png"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
+)
+
+var (
+	// ErrPresetNotFound is returned when preset does not exist
+	ErrPresetNotFound = errors.New("prompt preset not found")
+	// ErrPresetExists is returned when preset for style and lighting is already created
+	ErrPresetExists = errors.New("prompt preset for style and lighting already exists")
+	// ErrVersionNotFound is returned when preset has no such version
+	ErrVersionNotFound = errors.New("prompt preset version not found")
+	// ErrInvalidImage is returned when sample image for dry run cannot be decoded
+	ErrInvalidImage = errors.New("invalid sample image")
+)
+
+type PresetServiceDeps struct {
+	PresetRepository PresetRepositoryInterface
+	AIClient         AIClientInterface
+}
+
+type PresetService struct {
+	deps *PresetServiceDeps
+}
+
+func NewPresetService(deps *PresetServiceDeps) *PresetService {
+	return &PresetService{
+		deps: deps,
+	}
+}
+
+var _ stableDiffusion.PresetStore = (*PresetService)(nil)
+
+// FindPreset returns active preset for style and lighting to Stable Diffusion client, nil when built-in preset should be used
+func (s *PresetService) FindPreset(ctx context.Context, style stableDiffusion.ProcessingStyle, lighting stableDiffusion.LightingType) (*stableDiffusion.PromptPreset, error) {
+	preset, err := s.deps.PresetRepository.FindActive(ctx, string(style), string(lighting))
+	if err != nil {
+		if errors.Is(err, ErrPresetNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return preset.ToGenerationPreset(), nil
+}
+
+// CreatePreset creates first version of preset for style and lighting
+func (s *PresetService) CreatePreset(ctx context.Context, req *CreatePresetRequest, createdBy string) (*PromptPreset, error) {
+	existing, err := s.deps.PresetRepository.GetByStyle(ctx, req.Style, req.Lighting)
+	if err != nil && !errors.Is(err, ErrPresetNotFound) {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, ErrPresetExists
+	}
+
+	preset := &PromptPreset{
+		Style:     req.Style,
+		Lighting:  req.Lighting,
+		Version:   1,
+		UpdatedBy: createdBy,
+	}
+	applyParams(preset, &req.PresetParams)
+
+	if err := s.deps.PresetRepository.Create(ctx, preset); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("preset_id", preset.ID.String()).
+		Str("style", preset.Style).
+		Str("lighting", preset.Lighting).
+		Str("created_by", createdBy).
+		Msg("Prompt preset created")
+
+	return preset, nil
+}
+
+// UpdatePreset replaces parameters of preset and saves them as its next version
+func (s *PresetService) UpdatePreset(ctx context.Context, id uuid.UUID, req *UpdatePresetRequest, updatedBy string) (*PromptPreset, error) {
+	preset, err := s.deps.PresetRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyParams(preset, &req.PresetParams)
+	return s.saveVersion(ctx, preset, updatedBy)
+}
+
+// RestoreVersion makes parameters of previous version current, restored parameters become new version
+func (s *PresetService) RestoreVersion(ctx context.Context, id uuid.UUID, version int, updatedBy string) (*PromptPreset, error) {
+	preset, err := s.deps.PresetRepository.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	previous, err := s.deps.PresetRepository.GetVersion(ctx, id, version)
+	if err != nil {
+		return nil, err
+	}
+
+	preset.Prompt = previous.Prompt
+	preset.NegativePrompt = previous.NegativePrompt
+	preset.Steps = previous.Steps
+	preset.CfgScale = previous.CfgScale
+	preset.DenoisingStrength = previous.DenoisingStrength
+	preset.Sampler = previous.Sampler
+	preset.IsActive = previous.IsActive
+	return s.saveVersion(ctx, preset, updatedBy)
+}
+
+func (s *PresetService) saveVersion(ctx context.Context, preset *PromptPreset, updatedBy string) (*PromptPreset, error) {
+	preset.Version++
+	preset.UpdatedBy = updatedBy
+	preset.UpdatedAt = time.Now()
+
+	if err := s.deps.PresetRepository.Update(ctx, preset); err != nil {
+		return nil, err
+	}
+
+	log.Info().
+		Str("preset_id", preset.ID.String()).
+		Int("version", preset.Version).
+		Str("updated_by", updatedBy).
+		Msg("Prompt preset updated")
+
+	return preset, nil
+}
+
+// DeletePreset removes preset, its style returns to built-in preset
+func (s *PresetService) DeletePreset(ctx context.Context, id uuid.UUID) error {
+	if _, err := s.deps.PresetRepository.GetByID(ctx, id); err != nil {
+		return err
+	}
+	return s.deps.PresetRepository.Delete(ctx, id)
+}
+
+func (s *PresetService) GetPreset(ctx context.Context, id uuid.UUID) (*PromptPreset, error) {
+	return s.deps.PresetRepository.GetByID(ctx, id)
+}
+
+func (s *PresetService) GetPresets(ctx context.Context) ([]*PromptPreset, error) {
+	return s.deps.PresetRepository.GetAll(ctx)
+}
+
+// GetDefaultPresets returns built-in presets of every style, they are starting point for presets of art team
+func (s *PresetService) GetDefaultPresets() *DefaultPresetsResponse {
+	styles := stableDiffusion.Styles()
+	presets := make([]stableDiffusion.PromptPreset, 0, len(styles))
+	for _, style := range styles {
+		presets = append(presets, stableDiffusion.DefaultPreset(style, ""))
+	}
+	return &DefaultPresetsResponse{Presets: presets}
+}
+
+func (s *PresetService) GetVersions(ctx context.Context, id uuid.UUID) ([]*PromptPresetVersion, error) {
+	if _, err := s.deps.PresetRepository.GetByID(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.deps.PresetRepository.GetVersions(ctx, id)
+}
+
+// DryRun generates image from sample with preset version without saving anything, inactive presets can be tested too
+func (s *PresetService) DryRun(ctx context.Context, req *DryRunRequest) (*DryRunResponse, error) {
+	preset, err := s.deps.PresetRepository.GetByID(ctx, req.PresetID)
+	if err != nil {
+		return nil, err
+	}
+
+	version := preset.Version
+	params := preset.ToGenerationPreset()
+	if req.Version != 0 && req.Version != preset.Version {
+		previous, err := s.deps.PresetRepository.GetVersion(ctx, preset.ID, req.Version)
+		if err != nil {
+			return nil, err
+		}
+		version = previous.Version
+		params.Prompt = previous.Prompt
+		params.NegativePrompt = previous.NegativePrompt
+		params.Steps = previous.Steps
+		params.CfgScale = previous.CfgScale
+		params.DenoisingStrength = previous.DenoisingStrength
+		params.Sampler = previous.Sampler
+	}
+
+	sample, _, err := image.DecodeConfig(bytes.NewReader(req.ImageData))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidImage, err)
+	}
+
+	lighting := req.Lighting
+	if preset.Lighting != "" {
+		lighting = preset.Lighting
+	}
+
+	started := time.Now()
+	result, err := s.deps.AIClient.ProcessImageWithPreset(ctx, stableDiffusion.ProcessImageRequest{
+		ImageBase64: s.deps.AIClient.EncodeImageToBase64(req.ImageData),
+		Style:       stableDiffusion.ProcessingStyle(preset.Style),
+		UseAI:       true,
+		Lighting:    stableDiffusion.LightingType(lighting),
+		Width:       sample.Width,
+		Height:      sample.Height,
+	}, *params)
+	if err != nil {
+		return nil, fmt.Errorf("failed to run prompt preset: %w", err)
+	}
+
+	return &DryRunResponse{
+		PresetID:   preset.ID,
+		Version:    version,
+		DurationMs: time.Since(started).Milliseconds(),
+		Image:      "data:image/png;base64," + result,
+	}, nil
+}
+
+func applyParams(preset *PromptPreset, params *PresetParams) {
+	preset.Prompt = params.Prompt
+	preset.NegativePrompt = params.NegativePrompt
+	preset.Steps = params.Steps
+	preset.CfgScale = params.CfgScale
+	preset.DenoisingStrength = params.DenoisingStrength
+	preset.Sampler = params.Sampler
+	preset.IsActive = params.IsActive == nil || *params.IsActive
+}
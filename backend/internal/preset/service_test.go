@@ -0,0 +1,292 @@
+package preset
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"image"
+	"image/color"
+	"image/png"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/disintegration/imaging"
+	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/pkg/middleware"
+	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockPresetRepository struct {
+	mock.Mock
+}
+
+func (m *MockPresetRepository) Create(ctx context.Context, preset *PromptPreset) error {
+	args := m.Called(ctx, preset)
+	return args.Error(0)
+}
+
+func (m *MockPresetRepository) Update(ctx context.Context, preset *PromptPreset) error {
+	args := m.Called(ctx, preset)
+	return args.Error(0)
+}
+
+func (m *MockPresetRepository) Delete(ctx context.Context, id uuid.UUID) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *MockPresetRepository) GetByID(ctx context.Context, id uuid.UUID) (*PromptPreset, error) {
+	args := m.Called(ctx, id)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*PromptPreset), args.Error(1)
+}
+
+func (m *MockPresetRepository) GetByStyle(ctx context.Context, style, lighting string) (*PromptPreset, error) {
+	args := m.Called(ctx, style, lighting)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*PromptPreset), args.Error(1)
+}
+
+func (m *MockPresetRepository) FindActive(ctx context.Context, style, lighting string) (*PromptPreset, error) {
+	args := m.Called(ctx, style, lighting)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*PromptPreset), args.Error(1)
+}
+
+func (m *MockPresetRepository) GetAll(ctx context.Context) ([]*PromptPreset, error) {
+	args := m.Called(ctx)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*PromptPreset), args.Error(1)
+}
+
+func (m *MockPresetRepository) GetVersions(ctx context.Context, presetID uuid.UUID) ([]*PromptPresetVersion, error) {
+	args := m.Called(ctx, presetID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*PromptPresetVersion), args.Error(1)
+}
+
+func (m *MockPresetRepository) GetVersion(ctx context.Context, presetID uuid.UUID, version int) (*PromptPresetVersion, error) {
+	args := m.Called(ctx, presetID, version)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*PromptPresetVersion), args.Error(1)
+}
+
+func createTestPreset() *PromptPreset {
+	return &PromptPreset{
+		ID:                uuid.New(),
+		Style:             "pop_art",
+		Lighting:          "",
+		Prompt:            "grayscale, charcoal drawing",
+		NegativePrompt:    "color",
+		Steps:             30,
+		CfgScale:          6,
+		DenoisingStrength: 0.45,
+		Sampler:           "Euler a",
+		IsActive:          true,
+		Version:           2,
+		UpdatedBy:         "art",
+	}
+}
+
+func createTestImage(t *testing.T) []byte {
+	var buf bytes.Buffer
+	assert.NoError(t, png.Encode(&buf, imaging.New(300, 400, color.NRGBA{R: 200, G: 120, B: 40, A: 255})))
+	return buf.Bytes()
+}
+
+func decodeResult(t *testing.T, dataURL string) image.Image {
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(dataURL, "data:image/png;base64,"))
+	assert.NoError(t, err)
+	img, err := png.Decode(bytes.NewReader(data))
+	assert.NoError(t, err)
+	return img
+}
+
+func isGray(img image.Image) bool {
+	r, g, b, _ := img.At(10, 10).RGBA()
+	return r == g && g == b
+}
+
+func TestPresetService_CreatePreset(t *testing.T) {
+	params := PresetParams{Prompt: "oil painting", Steps: 30, CfgScale: 7, DenoisingStrength: 0.5}
+
+	t.Run("created_with_first_version", func(t *testing.T) {
+		repo := new(MockPresetRepository)
+		repo.On("GetByStyle", mock.Anything, "pop_art", "sun").Return(nil, ErrPresetNotFound)
+		repo.On("Create", mock.Anything, mock.AnythingOfType("*preset.PromptPreset")).Return(nil)
+
+		service := NewPresetService(&PresetServiceDeps{PresetRepository: repo})
+		preset, err := service.CreatePreset(context.Background(), &CreatePresetRequest{Style: "pop_art", Lighting: "sun", PresetParams: params}, "art")
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, preset.Version)
+		assert.True(t, preset.IsActive)
+		assert.Equal(t, "art", preset.UpdatedBy)
+		assert.Equal(t, "oil painting", preset.Prompt)
+		repo.AssertExpectations(t)
+	})
+
+	t.Run("duplicate_style_and_lighting", func(t *testing.T) {
+		repo := new(MockPresetRepository)
+		repo.On("GetByStyle", mock.Anything, "pop_art", "").Return(createTestPreset(), nil)
+
+		service := NewPresetService(&PresetServiceDeps{PresetRepository: repo})
+		_, err := service.CreatePreset(context.Background(), &CreatePresetRequest{Style: "pop_art", PresetParams: params}, "art")
+
+		assert.ErrorIs(t, err, ErrPresetExists)
+		repo.AssertNotCalled(t, "Create", mock.Anything, mock.Anything)
+	})
+}
+
+func TestPresetService_UpdateAndRestore(t *testing.T) {
+	existing := createTestPreset()
+	repo := new(MockPresetRepository)
+	repo.On("GetByID", mock.Anything, existing.ID).Return(existing, nil)
+	repo.On("Update", mock.Anything, existing).Return(nil)
+	repo.On("GetVersion", mock.Anything, existing.ID, 1).Return(&PromptPresetVersion{
+		PresetID: existing.ID, Version: 1, Prompt: "first prompt", Steps: 20, CfgScale: 7.5, DenoisingStrength: 0.6, IsActive: true,
+	}, nil)
+	repo.On("GetVersion", mock.Anything, existing.ID, 9).Return(nil, ErrVersionNotFound)
+
+	service := NewPresetService(&PresetServiceDeps{PresetRepository: repo})
+
+	inactive := false
+	updated, err := service.UpdatePreset(context.Background(), existing.ID, &UpdatePresetRequest{PresetParams: PresetParams{
+		Prompt: "watercolor", Steps: 40, CfgScale: 8, DenoisingStrength: 0.7, IsActive: &inactive,
+	}}, "lead")
+	assert.NoError(t, err)
+	assert.Equal(t, 3, updated.Version)
+	assert.Equal(t, "watercolor", updated.Prompt)
+	assert.False(t, updated.IsActive)
+	assert.Equal(t, "lead", updated.UpdatedBy)
+
+	restored, err := service.RestoreVersion(context.Background(), existing.ID, 1, "art")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, restored.Version)
+	assert.Equal(t, "first prompt", restored.Prompt)
+	assert.Equal(t, 20, restored.Steps)
+	assert.True(t, restored.IsActive)
+
+	_, err = service.RestoreVersion(context.Background(), existing.ID, 9, "art")
+	assert.ErrorIs(t, err, ErrVersionNotFound)
+	repo.AssertNumberOfCalls(t, "Update", 2)
+}
+
+func TestPresetService_FindPreset(t *testing.T) {
+	t.Run("active_preset", func(t *testing.T) {
+		repo := new(MockPresetRepository)
+		repo.On("FindActive", mock.Anything, "pop_art", "moon").Return(createTestPreset(), nil)
+
+		service := NewPresetService(&PresetServiceDeps{PresetRepository: repo})
+		preset, err := service.FindPreset(context.Background(), stableDiffusion.StylePopArt, stableDiffusion.LightingMoon)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "grayscale, charcoal drawing", preset.Prompt)
+		assert.Equal(t, "Euler a", preset.Sampler)
+	})
+
+	t.Run("no_preset_uses_built_in", func(t *testing.T) {
+		repo := new(MockPresetRepository)
+		repo.On("FindActive", mock.Anything, "grayscale", "").Return(nil, ErrPresetNotFound)
+
+		service := NewPresetService(&PresetServiceDeps{PresetRepository: repo})
+		preset, err := service.FindPreset(context.Background(), stableDiffusion.StyleGrayscale, "")
+
+		assert.NoError(t, err)
+		assert.Nil(t, preset)
+	})
+
+	t.Run("repository_error", func(t *testing.T) {
+		repo := new(MockPresetRepository)
+		repo.On("FindActive", mock.Anything, "grayscale", "").Return(nil, errors.New("database error"))
+
+		service := NewPresetService(&PresetServiceDeps{PresetRepository: repo})
+		_, err := service.FindPreset(context.Background(), stableDiffusion.StyleGrayscale, "")
+
+		assert.Error(t, err)
+	})
+}
+
+func TestPresetService_PresetsUsedByAIClient(t *testing.T) {
+	logger := middleware.NewLogger()
+	server := httptest.NewServer(stableDiffusion.NewFakeServer())
+	defer server.Close()
+
+	backend := stableDiffusion.NewAutomatic1111Backend(server.URL, server.Client(), logger)
+	client := stableDiffusion.NewStableDiffusionClientWithBackend(backend, logger)
+
+	repo := new(MockPresetRepository)
+	repo.On("FindActive", mock.Anything, "pop_art", "").Return(createTestPreset(), nil)
+	repo.On("FindActive", mock.Anything, "max_colors", "").Return(nil, ErrPresetNotFound)
+	client.SetPresetStore(NewPresetService(&PresetServiceDeps{PresetRepository: repo}))
+
+	process := func(style stableDiffusion.ProcessingStyle) image.Image {
+		result, err := client.ProcessImage(context.Background(), stableDiffusion.ProcessImageRequest{
+			ImageBase64: client.EncodeImageToBase64(createTestImage(t)),
+			Style:       style,
+			UseAI:       true,
+			Width:       300,
+			Height:      400,
+		})
+		assert.NoError(t, err)
+		return decodeResult(t, result)
+	}
+
+	assert.True(t, isGray(process(stableDiffusion.StylePopArt)), "grayscale prompt of preset replaces built-in pop art prompt")
+	assert.False(t, isGray(process(stableDiffusion.StyleMaxColors)), "built-in prompt is used without preset")
+}
+
+func TestPresetService_DryRun(t *testing.T) {
+	logger := middleware.NewLogger()
+	server := httptest.NewServer(stableDiffusion.NewFakeServer())
+	defer server.Close()
+
+	backend := stableDiffusion.NewAutomatic1111Backend(server.URL, server.Client(), logger)
+	client := stableDiffusion.NewStableDiffusionClientWithBackend(backend, logger)
+
+	existing := createTestPreset()
+	repo := new(MockPresetRepository)
+	repo.On("GetByID", mock.Anything, existing.ID).Return(existing, nil)
+	repo.On("GetVersion", mock.Anything, existing.ID, 1).Return(&PromptPresetVersion{
+		PresetID: existing.ID, Version: 1, Prompt: "pop art style", Steps: 20, CfgScale: 7.5, DenoisingStrength: 0.6,
+	}, nil)
+
+	service := NewPresetService(&PresetServiceDeps{PresetRepository: repo, AIClient: client})
+
+	t.Run("current_version", func(t *testing.T) {
+		result, err := service.DryRun(context.Background(), &DryRunRequest{PresetID: existing.ID, ImageData: createTestImage(t)})
+		assert.NoError(t, err)
+		assert.Equal(t, 2, result.Version)
+		assert.True(t, isGray(decodeResult(t, result.Image)))
+	})
+
+	t.Run("previous_version", func(t *testing.T) {
+		result, err := service.DryRun(context.Background(), &DryRunRequest{PresetID: existing.ID, Version: 1, ImageData: createTestImage(t)})
+		assert.NoError(t, err)
+		assert.Equal(t, 1, result.Version)
+		assert.False(t, isGray(decodeResult(t, result.Image)))
+	})
+
+	t.Run("invalid_image", func(t *testing.T) {
+		_, err := service.DryRun(context.Background(), &DryRunRequest{PresetID: existing.ID, ImageData: []byte("not an image")})
+		assert.ErrorIs(t, err, ErrInvalidImage)
+	})
+
+	repo.AssertNotCalled(t, "Update", mock.Anything, mock.Anything)
+}
@@ -2,6 +2,8 @@ package public
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"os"
 	"strings"
 	"time"
@@ -10,8 +12,10 @@ import (
 	"github.com/google/uuid"
 	"github.com/skr1ms/mosaic/internal/image"
 	"github.com/skr1ms/mosaic/internal/types"
+	apperrors "github.com/skr1ms/mosaic/pkg/errors"
 	"github.com/skr1ms/mosaic/pkg/marketplace"
 	"github.com/skr1ms/mosaic/pkg/middleware"
+	"github.com/skr1ms/mosaic/pkg/mosaic"
 )
 
 type PublicHandlerDeps struct {
@@ -42,15 +46,19 @@ func NewPublicHandler(router fiber.Router, deps *PublicHandlerDeps) *PublicHandl
 	router.Post("/coupons/:code/activate", handler.ActivateCoupon)                        // POST /api/coupons/:code/activate
 	router.Post("/coupons/purchase", handler.PurchaseCoupon)                              // POST /api/coupons/purchase
 	router.Post("/images/upload", handler.UploadImage)                                    // POST /api/images/upload
+	router.Post("/images/collage", handler.UploadCollage)                                 // POST /api/images/collage
 	router.Post("/images/:id/edit", handler.EditImage)                                    // POST /api/images/:id/edit
 	router.Post("/images/:id/process", handler.ProcessImage)                              // POST /api/images/:id/process
 	router.Post("/images/:id/generate-schema", handler.GenerateSchema)                    // POST /api/images/:id/generate-schema
 	router.Post("/images/:id/send-email", handler.SendSchemaToEmail)                      // POST /api/images/:id/send-email
 	router.Get("/images/:id/preview", handler.GetImagePreview)                            // GET /api/images/:id/preview
 	router.Get("/images/:id/status", handler.GetProcessingStatus)                         // GET /api/images/:id/status
+	router.Post("/images/:id/cancel", handler.CancelImage)                                // POST /api/images/:id/cancel
+	router.Post("/images/:id/resume", handler.ResumeImage)                                // POST /api/images/:id/resume
 	router.Get("/images/:id/download", handler.DownloadSchema)                            // GET /api/images/:id/download
 	router.Get("/sizes", handler.GetAvailableSizes)                                       // GET /api/sizes
 	router.Get("/styles", handler.GetAvailableStyles)                                     // GET /api/styles
+	router.Get("/collage-templates", handler.GetCollageTemplates)                         // GET /api/collage-templates
 	router.Get("/config/recaptcha", handler.GetRecaptchaSiteKey)                          // GET /api/config/recaptcha
 	router.Post("/coupons/:code/reactivate", handler.ReactivateCoupon)                    // POST /api/coupons/:code/reactivate
 	router.Post("/images/:id/search-page", handler.SearchSchemaPage)                      // POST /api/images/:id/search-page
@@ -63,6 +71,7 @@ func NewPublicHandler(router fiber.Router, deps *PublicHandlerDeps) *PublicHandl
 	public.Post("/generate-style-variants", handler.GenerateStyleVariants) // POST /api/preview/generate-style-variants
 	public.Post("/generate-ai", handler.GenerateAIPreview)                 // POST /api/preview/generate-ai
 	public.Post("/generate-all", handler.GenerateAllPreviews)              // POST /api/preview/generate-all
+	public.Post("/recommend-style", handler.RecommendStyle)                // POST /api/preview/recommend-style
 	public.Get("/:id", handler.GetPreview)                                 // GET /api/preview/:id
 	public.Delete("/:id", handler.DeletePreview)                           // DELETE /api/preview/:id
 	public.Post("/cleanup-all", handler.CleanupAllPreviews)                // POST /api/preview/cleanup-all (EMERGENCY)
@@ -281,8 +290,10 @@ func (h *PublicHandler) GetCouponByCode(c *fiber.Ctx) error {
 // @Param code path string true "Coupon code"
 // @Success 200 {object} map[string]any "Coupon activated successfully"
 // @Failure 400 {object} map[string]any "Invalid request format"
+// @Failure 403 {object} map[string]any "Coupon is not yet valid"
 // @Failure 404 {object} map[string]any "Coupon not found"
 // @Failure 409 {object} map[string]any "Coupon already used"
+// @Failure 410 {object} map[string]any "Coupon expired"
 // @Failure 500 {object} map[string]any "Internal server error during coupon activation"
 // @Router /api/coupons/{code}/activate [post]
 func (h *PublicHandler) ActivateCoupon(c *fiber.Ctx) error {
@@ -296,6 +307,11 @@ func (h *PublicHandler) ActivateCoupon(c *fiber.Ctx) error {
 			Str("coupon_code", code).
 			Msg("Failed to activate coupon")
 
+		var problem *apperrors.ProblemDetail
+		if errors.As(err, &problem) {
+			return apperrors.SendError(c, problem)
+		}
+
 		var errorMsg string
 		var statusCode int
 
@@ -448,6 +464,117 @@ func (h *PublicHandler) UploadImage(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(result)
 }
 
+// @Summary Upload collage
+// @Description Uploads 2-6 photos and composes them into one image using collage template of coupon size
+// @Tags images
+// @Accept multipart/form-data
+// @Produce json
+// @Param coupon_id formData string false "Coupon ID"
+// @Param coupon_code formData string false "Coupon code (12 digits)"
+// @Param template formData string true "Collage template name"
+// @Param photos formData file true "Photos in order of template slots (JPG, PNG)"
+// @Success 201 {object} map[string]any "Collage uploaded successfully"
+// @Failure 400 {object} map[string]any "Bad request: missing coupon, template or photos"
+// @Failure 404 {object} map[string]any "Coupon not found"
+// @Failure 500 {object} map[string]any "Internal server error during collage upload"
+// @Router /api/images/collage [post]
+func (h *PublicHandler) UploadCollage(c *fiber.Ctx) error {
+	couponID := c.FormValue("coupon_id")
+	couponCode := c.FormValue("coupon_code")
+	templateName := c.FormValue("template")
+
+	if templateName == "" {
+		h.deps.Logger.FromContext(c).Warn().
+			Str("handler", "UploadCollage").
+			Msg("Collage template is required")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":      "Collage template is required",
+			"request_id": c.Get("X-Request-ID"),
+		})
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil || len(form.File["photos"]) == 0 {
+		h.deps.Logger.FromContext(c).Warn().
+			Err(err).
+			Str("handler", "UploadCollage").
+			Msg("Photos are required")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":      "Photos are required",
+			"request_id": c.Get("X-Request-ID"),
+		})
+	}
+	files := form.File["photos"]
+
+	if couponID == "" && couponCode != "" {
+		cleanCode := strings.TrimSpace(strings.ReplaceAll(couponCode, "-", ""))
+		if len(cleanCode) != 12 {
+			h.deps.Logger.FromContext(c).Warn().
+				Str("handler", "UploadCollage").
+				Str("coupon_code", couponCode).
+				Msg("Coupon code must be 12 digits")
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":      "Coupon code must be 12 digits",
+				"request_id": c.Get("X-Request-ID"),
+			})
+		}
+
+		coupon, err := h.deps.PublicService.GetCouponRepository().GetByCode(context.Background(), cleanCode)
+		if err != nil {
+			h.deps.Logger.FromContext(c).Error().
+				Err(err).
+				Str("handler", "UploadCollage").
+				Str("coupon_code", cleanCode).
+				Msg("Coupon not found")
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":      "Coupon not found",
+				"request_id": c.Get("X-Request-ID"),
+			})
+		}
+		couponID = coupon.ID.String()
+	}
+
+	if couponID == "" {
+		h.deps.Logger.FromContext(c).Warn().
+			Str("handler", "UploadCollage").
+			Msg("Coupon is required for collage")
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":      "Coupon is required for collage",
+			"request_id": c.Get("X-Request-ID"),
+		})
+	}
+
+	result, err := h.deps.PublicService.UploadCollage(couponID, templateName, files)
+	if err != nil {
+		h.deps.Logger.FromContext(c).Error().
+			Err(err).
+			Str("handler", "UploadCollage").
+			Str("coupon_id", couponID).
+			Str("template", templateName).
+			Int("photos", len(files)).
+			Msg("Failed to upload collage")
+
+		errorResponse := fiber.Map{
+			"error":      "Failed to upload collage",
+			"request_id": c.Get("X-Request-ID"),
+		}
+		if os.Getenv("ENVIRONMENT") == "development" || os.Getenv("ENVIRONMENT") == "dev" {
+			errorResponse["details"] = err.Error()
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(errorResponse)
+	}
+
+	h.deps.Logger.FromContext(c).Info().
+		Str("handler", "UploadCollage").
+		Str("coupon_id", couponID).
+		Interface("image_id", result["image_id"]).
+		Str("template", templateName).
+		Int("photos", len(files)).
+		Msg("Collage uploaded successfully")
+
+	return c.Status(fiber.StatusCreated).JSON(result)
+}
+
 // @Summary Edit image
 // @Description Applies cropping, rotation and scaling to the image
 // @Tags images
@@ -889,6 +1016,122 @@ func (h *PublicHandler) GetProcessingStatus(c *fiber.Ctx) error {
 	return c.JSON(result)
 }
 
+// @Summary Cancel image processing
+// @Description Cancels queued or running processing and schema generation of the image
+// @Tags images
+// @Produce json
+// @Param id path string true "Image ID"
+// @Success 200 {object} map[string]any "Processing cancelled"
+// @Failure 400 {object} map[string]any "Invalid image ID format"
+// @Failure 404 {object} map[string]any "Image not found"
+// @Failure 409 {object} map[string]any "Image is already completed or cancelled"
+// @Failure 500 {object} map[string]any "Internal server error when cancelling processing"
+// @Router /api/images/{id}/cancel [post]
+func (h *PublicHandler) CancelImage(c *fiber.Ctx) error {
+	imageID := c.Params("id")
+
+	result, err := h.deps.PublicService.CancelImage(imageID)
+	if err != nil {
+		h.deps.Logger.FromContext(c).Error().
+			Err(err).
+			Str("handler", "CancelImage").
+			Str("image_id", imageID).
+			Msg("Failed to cancel image processing")
+
+		var errorMsg string
+		var statusCode int
+
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid image id"):
+			errorMsg = "Invalid image ID format"
+			statusCode = fiber.StatusBadRequest
+		case strings.HasPrefix(err.Error(), "image not found"):
+			errorMsg = "Image not found"
+			statusCode = fiber.StatusNotFound
+		case errors.Is(err, image.ErrImageNotCancellable):
+			errorMsg = "Image is already completed or cancelled"
+			statusCode = fiber.StatusConflict
+		default:
+			errorMsg = "Failed to cancel image processing"
+			statusCode = fiber.StatusInternalServerError
+		}
+
+		errorResponse := fiber.Map{
+			"error":      errorMsg,
+			"request_id": c.Get("X-REQUEST-ID"),
+		}
+		if os.Getenv("ENVIRONMENT") == "development" || os.Getenv("ENVIRONMENT") == "dev" {
+			errorResponse["details"] = err.Error()
+		}
+		return c.Status(statusCode).JSON(errorResponse)
+	}
+
+	h.deps.Logger.FromContext(c).Info().
+		Str("handler", "CancelImage").
+		Str("image_id", imageID).
+		Msg("Image processing cancelled")
+
+	return c.JSON(result)
+}
+
+// @Summary Resume cancelled image
+// @Description Returns cancelled image to editing and processing, processing is started again by client
+// @Tags images
+// @Produce json
+// @Param id path string true "Image ID"
+// @Success 200 {object} map[string]any "Image resumed"
+// @Failure 400 {object} map[string]any "Invalid image ID format"
+// @Failure 404 {object} map[string]any "Image not found"
+// @Failure 409 {object} map[string]any "Image is not cancelled"
+// @Failure 500 {object} map[string]any "Internal server error when resuming image"
+// @Router /api/images/{id}/resume [post]
+func (h *PublicHandler) ResumeImage(c *fiber.Ctx) error {
+	imageID := c.Params("id")
+
+	result, err := h.deps.PublicService.ResumeImage(imageID)
+	if err != nil {
+		h.deps.Logger.FromContext(c).Error().
+			Err(err).
+			Str("handler", "ResumeImage").
+			Str("image_id", imageID).
+			Msg("Failed to resume cancelled image")
+
+		var errorMsg string
+		var statusCode int
+
+		switch {
+		case strings.HasPrefix(err.Error(), "invalid image id"):
+			errorMsg = "Invalid image ID format"
+			statusCode = fiber.StatusBadRequest
+		case strings.HasPrefix(err.Error(), "image not found"):
+			errorMsg = "Image not found"
+			statusCode = fiber.StatusNotFound
+		case errors.Is(err, image.ErrImageNotCancelled):
+			errorMsg = "Image is not cancelled"
+			statusCode = fiber.StatusConflict
+		default:
+			errorMsg = "Failed to resume cancelled image"
+			statusCode = fiber.StatusInternalServerError
+		}
+
+		errorResponse := fiber.Map{
+			"error":      errorMsg,
+			"request_id": c.Get("X-REQUEST-ID"),
+		}
+		if os.Getenv("ENVIRONMENT") == "development" || os.Getenv("ENVIRONMENT") == "dev" {
+			errorResponse["details"] = err.Error()
+		}
+		return c.Status(statusCode).JSON(errorResponse)
+	}
+
+	h.deps.Logger.FromContext(c).Info().
+		Str("handler", "ResumeImage").
+		Str("image_id", imageID).
+		Msg("Cancelled image resumed")
+
+	return c.JSON(result)
+}
+
 // @Summary Purchase coupon
 // @Description Purchases a new coupon with card payment
 // @Tags coupons
@@ -1002,6 +1245,26 @@ func (h *PublicHandler) GetAvailableSizes(c *fiber.Ctx) error {
 	return c.JSON(sizes)
 }
 
+// @Summary Get collage templates
+// @Description Returns collage templates available for canvas size, slots are fractions of canvas sides
+// @Tags public
+// @Produce json
+// @Param size query string false "Canvas size, e.g. 30x40"
+// @Success 200 {array} collage.Template "Available collage templates"
+// @Router /api/collage-templates [get]
+func (h *PublicHandler) GetCollageTemplates(c *fiber.Ctx) error {
+	size := c.Query("size", "30x40")
+	templates := h.deps.PublicService.GetCollageTemplates(size)
+
+	h.deps.Logger.FromContext(c).Info().
+		Str("handler", "GetCollageTemplates").
+		Str("size", size).
+		Int("count", len(templates)).
+		Msg("Collage templates retrieved successfully")
+
+	return c.JSON(templates)
+}
+
 // @Summary Get available styles
 // @Description Returns list of available processing styles
 // @Tags public
@@ -1161,11 +1424,36 @@ func (h *PublicHandler) GeneratePreview(c *fiber.Ctx) error {
 	lighting := c.FormValue("lighting", "sun")
 	contrastLevel := c.FormValue("contrast_level", "normal")
 
+	// Optional text overlays as JSON array, same format as in image edit request
+	var texts []image.TextOverlay
+	if textsValue := c.FormValue("texts"); textsValue != "" {
+		if err := json.Unmarshal([]byte(textsValue), &texts); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid texts format",
+			})
+		}
+	}
+
+	// Optional background replacement as JSON object, same format as in image processing request
+	var bg *image.BackgroundParams
+	if backgroundValue := c.FormValue("background"); backgroundValue != "" {
+		if err := json.Unmarshal([]byte(backgroundValue), &bg); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid background format",
+			})
+		}
+		if err := middleware.ValidateStruct(bg); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error": "Invalid background format",
+			})
+		}
+	}
+
 	// Generate preview ID
 	previewID := uuid.New().String()
 
 	// Process image
-	previewData, err := h.deps.PublicService.GeneratePreview(ctx, file, size, style, lighting, contrastLevel)
+	previewData, err := h.deps.PublicService.GeneratePreview(ctx, file, size, style, lighting, contrastLevel, texts, bg)
 	if err != nil {
 		h.deps.Logger.FromContext(c).Error().
 			Err(err).
@@ -1229,7 +1517,7 @@ func (h *PublicHandler) GenerateVariants(c *fiber.Ctx) error {
 
 	for _, variant := range variants {
 		// For variants, use style as lighting and keep grayscale as base style
-		previewData, err := h.deps.PublicService.GeneratePreview(ctx, file, size, "grayscale", variant.Style, variant.Contrast)
+		previewData, err := h.deps.PublicService.GeneratePreview(ctx, file, size, "grayscale", variant.Style, variant.Contrast, nil, nil)
 		if err != nil {
 			h.deps.Logger.FromContext(c).
 				Error().
@@ -1254,7 +1542,7 @@ func (h *PublicHandler) GenerateVariants(c *fiber.Ctx) error {
 	})
 }
 
-// GenerateStyleVariants generates previews for all 4 main styles
+// GenerateStyleVariants generates previews for all 4 main styles, each quantized to its palette and rendered as stones
 func (h *PublicHandler) GenerateStyleVariants(c *fiber.Ctx) error {
 	ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
 	defer cancel()
@@ -1277,6 +1565,14 @@ func (h *PublicHandler) GenerateStyleVariants(c *fiber.Ctx) error {
 
 	file := files[0]
 	size := c.FormValue("size", "30x40")
+	productType := c.FormValue("product_type", mosaic.ProductDiamondMosaic)
+	switch productType {
+	case mosaic.ProductDiamondMosaic, mosaic.ProductPaintByNumbers, mosaic.ProductFuseBeads:
+	default:
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Invalid product type",
+		})
+	}
 
 	// Define the 4 main styles
 	styles := []struct {
@@ -1303,7 +1599,7 @@ func (h *PublicHandler) GenerateStyleVariants(c *fiber.Ctx) error {
 	for _, style := range styles {
 		style := style // Capture loop variable
 		go func() {
-			previewData, err := h.deps.PublicService.GenerateStylePreview(ctx, file, size, style.Key)
+			previewData, err := h.deps.PublicService.GenerateStylePreview(ctx, file, size, style.Key, productType)
 			resultChan <- previewResult{
 				Style: style.Key,
 				Label: style.Label,
@@ -1347,9 +1643,10 @@ func (h *PublicHandler) GenerateStyleVariants(c *fiber.Ctx) error {
 	}
 
 	return c.JSON(fiber.Map{
-		"previews": previews,
-		"total":    len(previews),
-		"size":     size,
+		"previews":     previews,
+		"total":        len(previews),
+		"size":         size,
+		"product_type": productType,
 	})
 }
 
@@ -1472,9 +1769,9 @@ func (h *PublicHandler) DeletePreview(c *fiber.Ctx) error {
 	})
 }
 
-// GenerateAllPreviews generates all 8 base previews + optional 1 AI preview
+// GenerateAllPreviews generates ranked style previews, all 8 base previews + optional 1 AI preview
 // @Summary Generate all preview variants
-// @Description Generates 8 base previews (4 styles × 2 contrasts) and optionally 1 AI preview
+// @Description Generates 4 coupon style previews ordered by recommendation, 8 base previews (4 lightings × 2 contrasts) and optionally 1 AI preview
 // @Tags preview
 // @Accept multipart/form-data
 // @Produce json
@@ -1562,6 +1859,63 @@ func (h *PublicHandler) GenerateAllPreviews(c *fiber.Ctx) error {
 	return c.JSON(result)
 }
 
+// RecommendStyle ranks coupon styles for photo
+// @Summary Recommend coupon style
+// @Description Analyzes colorfulness, skin tones, contrast and dominant hues of photo and ranks grayscale, skin_tones, pop_art and max_colors styles
+// @Tags preview
+// @Accept multipart/form-data
+// @Produce json
+// @Param image_id formData string false "Image ID of uploaded photo"
+// @Param image formData file false "Image file (required if image_id not given)"
+// @Success 200 {object} StyleRecommendationResponse "Styles ranked from best"
+// @Failure 400 {object} map[string]any "Invalid request"
+// @Failure 404 {object} map[string]any "Image not found"
+// @Failure 500 {object} map[string]any "Internal server error"
+// @Router /api/preview/recommend-style [post]
+func (h *PublicHandler) RecommendStyle(c *fiber.Ctx) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	if imageID := c.FormValue("image_id"); imageID != "" {
+		result, err := h.deps.PublicService.RecommendStyle(ctx, imageID)
+		if err != nil {
+			h.deps.Logger.FromContext(c).Error().
+				Err(err).
+				Str("handler", "RecommendStyle").
+				Str("image_id", imageID).
+				Msg("Failed to recommend style")
+
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error": "Image not found",
+			})
+		}
+		return c.JSON(result)
+	}
+
+	file, err := c.FormFile("image")
+	if err != nil || file == nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error": "Image file or image_id is required",
+		})
+	}
+
+	result, err := h.deps.PublicService.RecommendStyleFromFile(ctx, file)
+	if err != nil {
+		h.deps.Logger.FromContext(c).Error().
+			Err(err).
+			Str("handler", "RecommendStyle").
+			Str("filename", file.Filename).
+			Msg("Failed to recommend style")
+
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Failed to analyze image",
+			"details": err.Error(),
+		})
+	}
+
+	return c.JSON(result)
+}
+
 // ReactivateCoupon handles re-access to an already activated coupon
 // @Summary Reactivate coupon
 // @Description Provides access to already activated coupon data and schema
@@ -1572,7 +1926,9 @@ func (h *PublicHandler) GenerateAllPreviews(c *fiber.Ctx) error {
 // @Param request body ReactivateCouponRequest true "Reactivation request"
 // @Success 200 {object} ReactivateCouponResponse "Coupon data retrieved"
 // @Failure 400 {object} map[string]any "Invalid request"
+// @Failure 403 {object} map[string]any "Coupon is not yet valid"
 // @Failure 404 {object} map[string]any "Coupon not found"
+// @Failure 410 {object} map[string]any "Coupon expired"
 // @Failure 500 {object} map[string]any "Internal server error"
 // @Router /api/coupons/{code}/reactivate [post]
 func (h *PublicHandler) ReactivateCoupon(c *fiber.Ctx) error {
@@ -1596,6 +1952,11 @@ func (h *PublicHandler) ReactivateCoupon(c *fiber.Ctx) error {
 			Str("code", req.Code).
 			Msg("Failed to reactivate coupon")
 
+		var problem *apperrors.ProblemDetail
+		if errors.As(err, &problem) {
+			return apperrors.SendError(c, problem)
+		}
+
 		var statusCode int
 		if strings.Contains(err.Error(), "not found") {
 			statusCode = fiber.StatusNotFound
@@ -15,6 +15,7 @@ import (
 	"github.com/skr1ms/mosaic/internal/partner"
 	"github.com/skr1ms/mosaic/internal/payment"
 	"github.com/skr1ms/mosaic/internal/types"
+	"github.com/skr1ms/mosaic/pkg/collage"
 	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
 )
 
@@ -62,10 +63,17 @@ type PublicRepositoryInterface interface {
 
 type ImageServiceInterface interface {
 	UploadImage(ctx context.Context, couponID uuid.UUID, file *multipart.FileHeader, userEmail string) (*internalImage.Image, error)
+	UploadCollage(ctx context.Context, couponID uuid.UUID, templateName string, files []*multipart.FileHeader, userEmail string) (*internalImage.Image, error)
+	GetCollageTemplates(size string) []collage.Template
 	EditImage(ctx context.Context, imageID uuid.UUID, params internalImage.ImageEditParams) error
 	ProcessImage(ctx context.Context, imageID uuid.UUID, params *internalImage.ProcessingParams) error
 	GenerateSchema(ctx context.Context, imageID uuid.UUID, confirmed bool) error
+	CancelImage(ctx context.Context, imageID uuid.UUID) (*internalImage.Image, error)
+	ResumeImage(ctx context.Context, imageID uuid.UUID) (*internalImage.Image, error)
 	GetImageStatus(ctx context.Context, imageID uuid.UUID) (*types.ImageStatusResponse, error)
+	ApplyTextOverlays(img image.Image, overlays []internalImage.TextOverlay, productType, couponStyle string) (image.Image, error)
+	ReplaceBackground(ctx context.Context, img image.Image, params *internalImage.BackgroundParams, productType, couponStyle string) (image.Image, error)
+	SimulateStyle(img image.Image, productType, couponStyle, size string) (image.Image, error)
 }
 
 type PaymentServiceInterface interface {
@@ -91,18 +99,24 @@ type PublicServiceInterface interface {
 	PurchaseCoupon(req PurchaseCouponRequest) (map[string]any, error)
 
 	UploadImage(couponID string, file *multipart.FileHeader) (map[string]any, error)
+	UploadCollage(couponID, templateName string, files []*multipart.FileHeader) (map[string]any, error)
+	GetCollageTemplates(size string) []collage.Template
 	EditImage(imageID string, req types.EditImageRequest) (map[string]any, error)
 	ProcessImage(imageID string, req types.ProcessImageRequest) (map[string]any, error)
 	GetImagePreview(imageID string) (map[string]any, error)
 	GetProcessingStatus(imageID string) (map[string]any, error)
+	CancelImage(imageID string) (map[string]any, error)
+	ResumeImage(imageID string) (map[string]any, error)
 	GetImageForDownload(imageID string) (*internalImage.Image, error)
 	SendSchemaToEmail(imageID string, req SendEmailRequest) (map[string]any, error)
 
-	GeneratePreview(ctx context.Context, file *multipart.FileHeader, size, style, lighting, contrast string) (*PreviewData, error)
-	GenerateStylePreview(ctx context.Context, file *multipart.FileHeader, size, style string) (*PreviewData, error)
+	GeneratePreview(ctx context.Context, file *multipart.FileHeader, size, style, lighting, contrast string, texts []internalImage.TextOverlay, bg *internalImage.BackgroundParams) (*PreviewData, error)
+	GenerateStylePreview(ctx context.Context, file *multipart.FileHeader, size, style, productType string) (*PreviewData, error)
 	GenerateAIPreview(ctx context.Context, file *multipart.FileHeader, prompt string) (*PreviewData, error)
 	GenerateAllPreviews(ctx context.Context, imageID string, size string, useAI bool) (*GenerateAllPreviewsResponse, error)
 	GenerateAllPreviewsFromFile(ctx context.Context, file *multipart.FileHeader, size string, useAI bool) (*GenerateAllPreviewsResponse, error)
+	RecommendStyle(ctx context.Context, imageID string) (*StyleRecommendationResponse, error)
+	RecommendStyleFromFile(ctx context.Context, file *multipart.FileHeader) (*StyleRecommendationResponse, error)
 	SearchSchemaPage(ctx context.Context, imageID string, pageNumber int) (*SearchSchemaPageResponse, error)
 	ReactivateCoupon(ctx context.Context, code string) (*ReactivateCouponResponse, error)
 	GetEmailService() EmailServiceInterface
@@ -235,6 +249,11 @@ type S3ClientInterface interface {
 	Decode(reader io.Reader) (image.Image, string, error)
 }
 
+type ImageTaskQueueInterface interface {
+	EnqueueImageOptimization(imageID uuid.UUID, quality int) (string, error)
+	EnqueueThumbnailGeneration(imageID uuid.UUID, sizes []string) (string, error)
+}
+
 type RedisClientInterface interface {
 	Get(ctx context.Context, key string) *redis.StringCmd
 	Set(ctx context.Context, key string, value any, expiration time.Duration) *redis.StatusCmd
@@ -2,6 +2,7 @@ package public
 
 import (
 	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/pkg/styleRecommendation"
 )
 
 type SendEmailRequest struct {
@@ -11,6 +12,9 @@ type SendEmailRequest struct {
 type PurchaseCouponRequest struct {
 	Size         string  `json:"size" validate:"required,oneof=21x30 30x40 40x40 40x50 40x60 50x70"`
 	Style        string  `json:"style" validate:"required,oneof=grayscale skin_tones pop_art max_colors"`
+	ProductType  string  `json:"product_type,omitempty" validate:"omitempty,oneof=diamond_mosaic paint_by_numbers fuse_beads"`
+	Panels       int     `json:"panels,omitempty" validate:"omitempty,min=1,max=4"`
+	PanelGapMM   int     `json:"panel_gap_mm,omitempty" validate:"omitempty,min=0,max=200"`
 	Email        string  `json:"email" validate:"required,email"`
 	PaymentToken string  `json:"payment_token" validate:"required"`
 	Amount       float64 `json:"amount" validate:"required"`
@@ -89,18 +93,30 @@ type GenerateAllPreviewsRequest struct {
 }
 
 type GenerateAllPreviewsResponse struct {
-	Previews []PreviewInfo `json:"previews"`
-	Total    int           `json:"total"`
-	ImageID  string        `json:"image_id"`
+	Previews        []PreviewInfo               `json:"previews"`
+	Total           int                         `json:"total"`
+	ImageID         string                      `json:"image_id"`
+	Recommendations []styleRecommendation.Score `json:"recommendations"`
 }
 
 type PreviewInfo struct {
-	ID       string `json:"id"`
-	URL      string `json:"url"`
-	Style    string `json:"style"`
-	Contrast string `json:"contrast"`
-	Label    string `json:"label"`
-	IsAI     bool   `json:"is_ai"`
+	ID          string  `json:"id"`
+	URL         string  `json:"url"`
+	Style       string  `json:"style"`
+	Contrast    string  `json:"contrast"`
+	Label       string  `json:"label"`
+	IsAI        bool    `json:"is_ai"`
+	Rank        int     `json:"rank,omitempty"`        // Rank of coupon style recommendation, zero for lighting variants
+	Score       float64 `json:"score,omitempty"`       // Score of coupon style recommendation
+	Recommended bool    `json:"recommended,omitempty"` // Best coupon style for image
+}
+
+// StyleRecommendationResponse ranks coupon styles for image from best
+type StyleRecommendationResponse struct {
+	ImageID         string                       `json:"image_id,omitempty"`
+	Recommended     string                       `json:"recommended"`
+	Recommendations []styleRecommendation.Score  `json:"recommendations"`
+	Analysis        styleRecommendation.Analysis `json:"analysis"`
 }
 
 type SearchSchemaPageRequest struct {
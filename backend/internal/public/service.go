@@ -13,16 +13,21 @@ import (
 	"image/png"
 	"io"
 	"mime/multipart"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/disintegration/imaging"
 	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
 	internalImage "github.com/skr1ms/mosaic/internal/image"
 	"github.com/skr1ms/mosaic/internal/payment"
 	"github.com/skr1ms/mosaic/internal/types"
+	"github.com/skr1ms/mosaic/pkg/collage"
 	"github.com/skr1ms/mosaic/pkg/marketplace"
+	"github.com/skr1ms/mosaic/pkg/mosaic"
 	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
+	"github.com/skr1ms/mosaic/pkg/styleRecommendation"
 )
 
 type PublicServiceDeps struct {
@@ -37,6 +42,7 @@ type PublicServiceDeps struct {
 	S3Client          S3ClientInterface
 	AIClient          AIClientInterface
 	RedisClient       RedisClientInterface
+	ImageTaskQueue    ImageTaskQueueInterface
 	RecaptchaSiteKey  string
 }
 
@@ -119,15 +125,23 @@ func (s *PublicService) GetCouponByCode(code string) (map[string]any, error) {
 		return nil, fmt.Errorf("coupon not found: %w", err)
 	}
 
+	// Coupon outside of its validity window is still returned, so frontend can show dates
+	valid := coupon.CheckValidity(time.Now()) == nil
+
 	partner, err := s.deps.PartnerRepository.GetByID(context.Background(), coupon.PartnerID)
 	if err != nil {
 		return map[string]any{
-			"id":     coupon.ID,
-			"code":   coupon.Code,
-			"size":   coupon.Size,
-			"style":  coupon.Style,
-			"status": coupon.Status,
-			"valid":  true,
+			"id":           coupon.ID,
+			"code":         coupon.Code,
+			"size":         coupon.Size,
+			"style":        coupon.Style,
+			"product_type": coupon.ProductType,
+			"panels":       coupon.Panels,
+			"panel_gap_mm": coupon.PanelGapMM,
+			"status":       coupon.Status,
+			"valid":        valid,
+			"valid_from":   coupon.ValidFrom,
+			"expires_at":   coupon.ExpiresAt,
 		}, nil
 	}
 
@@ -136,8 +150,13 @@ func (s *PublicService) GetCouponByCode(code string) (map[string]any, error) {
 		"code":           coupon.Code,
 		"size":           coupon.Size,
 		"style":          coupon.Style,
+		"product_type":   coupon.ProductType,
+		"panels":         coupon.Panels,
+		"panel_gap_mm":   coupon.PanelGapMM,
 		"status":         coupon.Status,
-		"valid":          true,
+		"valid":          valid,
+		"valid_from":     coupon.ValidFrom,
+		"expires_at":     coupon.ExpiresAt,
 		"partner_id":     partner.ID,
 		"partner_code":   partner.PartnerCode,
 		"partner_domain": partner.Domain,
@@ -153,8 +172,12 @@ func (s *PublicService) ActivateCoupon(code string) (map[string]any, error) {
 		return nil, fmt.Errorf("coupon not found: %w", err)
 	}
 
-	coupon.Status = "activated"
 	now := time.Now()
+	if err := coupon.CheckValidity(now); err != nil {
+		return nil, err
+	}
+
+	coupon.Status = "activated"
 	coupon.ActivatedAt = &now
 
 	if err := s.deps.CouponRepository.Update(context.Background(), coupon); err != nil {
@@ -200,6 +223,7 @@ func (s *PublicService) UploadImage(couponID string, file *multipart.FileHeader)
 	if err != nil {
 		return nil, fmt.Errorf("failed to upload image: %w", err)
 	}
+	s.enqueueImageOptimization(imageRecord.ID)
 
 	return map[string]any{
 		"message":      "Изображение успешно загружено",
@@ -207,10 +231,54 @@ func (s *PublicService) UploadImage(couponID string, file *multipart.FileHeader)
 		"next_step":    "edit_image",
 		"coupon_size":  coupon.Size,
 		"coupon_style": coupon.Style,
+		"product_type": coupon.ProductType,
+		"panels":       coupon.Panels,
 		"is_preview":   false,
 	}, nil
 }
 
+// UploadCollage composes several photos into one image for coupon (uses ImageService)
+func (s *PublicService) UploadCollage(couponID, templateName string, files []*multipart.FileHeader) (map[string]any, error) {
+	couponUUID, err := uuid.Parse(couponID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid coupon id: %w", err)
+	}
+
+	coupon, err := s.deps.CouponRepository.GetByID(context.Background(), couponUUID)
+	if err != nil {
+		return nil, fmt.Errorf("coupon not found: %w", err)
+	}
+
+	userEmail := ""
+	if coupon.UserEmail != nil {
+		userEmail = *coupon.UserEmail
+	}
+
+	imageRecord, err := s.deps.ImageService.UploadCollage(context.Background(), couponUUID, templateName, files, userEmail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to upload collage: %w", err)
+	}
+	s.enqueueImageOptimization(imageRecord.ID)
+
+	return map[string]any{
+		"message":      "Коллаж успешно загружен",
+		"image_id":     imageRecord.ID,
+		"next_step":    "edit_image",
+		"coupon_size":  coupon.Size,
+		"coupon_style": coupon.Style,
+		"product_type": coupon.ProductType,
+		"panels":       coupon.Panels,
+		"template":     templateName,
+		"photos":       len(files),
+		"is_preview":   false,
+	}, nil
+}
+
+// GetCollageTemplates returns collage templates available for canvas size
+func (s *PublicService) GetCollageTemplates(size string) []collage.Template {
+	return s.deps.ImageService.GetCollageTemplates(size)
+}
+
 // EditImage applies editing to image
 func (s *PublicService) EditImage(imageID string, req types.EditImageRequest) (map[string]any, error) {
 	imageUUID, err := uuid.Parse(imageID)
@@ -226,6 +294,15 @@ func (s *PublicService) EditImage(imageID string, req types.EditImageRequest) (m
 		Rotation:   req.Rotation,
 		Scale:      req.Scale,
 	}
+	for _, text := range req.Texts {
+		editParams.Texts = append(editParams.Texts, internalImage.TextOverlay{
+			Text:      text.Text,
+			Font:      text.Font,
+			Position:  text.Position,
+			Size:      text.Size,
+			ColorCode: text.ColorCode,
+		})
+	}
 
 	if err := s.deps.ImageService.EditImage(context.Background(), imageUUID, editParams); err != nil {
 		return nil, fmt.Errorf("failed to edit image: %w", err)
@@ -264,6 +341,13 @@ func (s *PublicService) ProcessImage(imageID string, req types.ProcessImageReque
 		Saturation: req.Saturation,
 		Settings:   make(map[string]any),
 	}
+	if req.Background != nil {
+		processParams.Background = &internalImage.BackgroundParams{
+			Mode:      req.Background.Mode,
+			ColorCode: req.Background.ColorCode,
+			Blur:      req.Background.Blur,
+		}
+	}
 
 	s.processImageAsync(imageUUID, processParams)
 
@@ -311,6 +395,8 @@ func (s *PublicService) GetImagePreview(imageID string) (map[string]any, error)
 		"status":       task.Status,
 		"preview_url":  status.PreviewURL,
 		"original_url": status.OriginalURL,
+		"edited_url":   status.EditedURL,
+		"edit_params":  task.EditParams,
 	}, nil
 }
 
@@ -348,6 +434,44 @@ func (s *PublicService) GetProcessingStatus(imageID string) (map[string]any, err
 	}, nil
 }
 
+// CancelImage cancels processing and schema generation of customer's image
+func (s *PublicService) CancelImage(imageID string) (map[string]any, error) {
+	imageUUID, err := uuid.Parse(imageID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image id: %w", err)
+	}
+
+	task, err := s.deps.ImageService.CancelImage(context.Background(), imageUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"id":      task.ID,
+		"status":  task.Status,
+		"message": "Обработка отменена",
+	}, nil
+}
+
+// ResumeImage returns customer's cancelled image to editing and processing
+func (s *PublicService) ResumeImage(imageID string) (map[string]any, error) {
+	imageUUID, err := uuid.Parse(imageID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image id: %w", err)
+	}
+
+	task, err := s.deps.ImageService.ResumeImage(context.Background(), imageUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]any{
+		"id":      task.ID,
+		"status":  task.Status,
+		"message": "Обработку можно запустить снова",
+	}, nil
+}
+
 // GetImageForDownload returns task for download
 func (s *PublicService) GetImageForDownload(imageID string) (*internalImage.Image, error) {
 	imageUUID, err := uuid.Parse(imageID)
@@ -435,11 +559,14 @@ func (s *PublicService) PurchaseCoupon(req PurchaseCouponRequest) (map[string]an
 	serverConfig := s.deps.Config.GetServerConfig()
 
 	paymentReq := &payment.PurchaseCouponRequest{
-		Size:      req.Size,
-		Style:     req.Style,
-		Email:     req.Email,
-		ReturnURL: serverConfig.PaymentSuccessURL,
-		Language:  "ru",
+		Size:        req.Size,
+		Style:       req.Style,
+		ProductType: req.ProductType,
+		Panels:      req.Panels,
+		PanelGapMM:  req.PanelGapMM,
+		Email:       req.Email,
+		ReturnURL:   serverConfig.PaymentSuccessURL,
+		Language:    "ru",
 	}
 
 	response, err := s.deps.PaymentService.PurchaseCoupon(context.Background(), paymentReq)
@@ -630,6 +757,10 @@ func (s *PublicService) ReactivateCoupon(ctx context.Context, code string) (*Rea
 		return nil, fmt.Errorf("coupon not activated yet")
 	}
 
+	if err := coupon.CheckValidity(time.Now()); err != nil {
+		return nil, err
+	}
+
 	// Get associated image
 	img, err := s.deps.ImageRepository.GetByCouponID(ctx, coupon.ID)
 	if err != nil {
@@ -688,17 +819,31 @@ func isNumeric(s string) bool {
 	return true
 }
 
+// enqueueImageOptimization schedules optimized copy and thumbnails of uploaded image,
+// upload succeeds even if they cannot be scheduled
+func (s *PublicService) enqueueImageOptimization(imageID uuid.UUID) {
+	if s.deps.ImageTaskQueue == nil {
+		return
+	}
+	if _, err := s.deps.ImageTaskQueue.EnqueueImageOptimization(imageID, 0); err != nil {
+		log.Error().Err(err).Str("image_id", imageID.String()).Msg("Failed to enqueue image optimization")
+	}
+	if _, err := s.deps.ImageTaskQueue.EnqueueThumbnailGeneration(imageID, nil); err != nil {
+		log.Error().Err(err).Str("image_id", imageID.String()).Msg("Failed to enqueue thumbnail generation")
+	}
+}
+
 // processImageAsync runs image processing asynchronously
 func (s *PublicService) processImageAsync(imageUUID uuid.UUID, processParams *internalImage.ProcessingParams) {
 	go func() {
 		if err := s.deps.ImageService.ProcessImage(context.Background(), imageUUID, processParams); err != nil {
-			fmt.Printf("Failed to process image: %v, imageUUID: %s\n", err, imageUUID.String())
+			log.Error().Err(err).Str("image_id", imageUUID.String()).Msg("Failed to process image")
 		}
 	}()
 }
 
-// GeneratePreview generates a single preview with style, lighting and contrast
-func (s *PublicService) GeneratePreview(ctx context.Context, file *multipart.FileHeader, size, style, lighting, contrast string) (*PreviewData, error) {
+// GeneratePreview generates a single preview with style, lighting, contrast, optional text overlays and background replacement
+func (s *PublicService) GeneratePreview(ctx context.Context, file *multipart.FileHeader, size, style, lighting, contrast string, texts []internalImage.TextOverlay, bg *internalImage.BackgroundParams) (*PreviewData, error) {
 	src, err := file.Open()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
@@ -713,6 +858,18 @@ func (s *PublicService) GeneratePreview(ctx context.Context, file *multipart.Fil
 
 	fileHash := fmt.Sprintf("%x", sha256.Sum256(fileContent))
 	cacheKey := fmt.Sprintf("preview:%s:%s:%s:%s:%s", fileHash[:16], size, style, lighting, contrast)
+	textsHash := ""
+	if len(texts) > 0 {
+		textsJSON, _ := json.Marshal(texts)
+		textsHash = fmt.Sprintf("%x", sha256.Sum256(textsJSON))[:16]
+		cacheKey += ":" + textsHash
+	}
+	backgroundHash := ""
+	if bg != nil {
+		backgroundJSON, _ := json.Marshal(bg)
+		backgroundHash = fmt.Sprintf("%x", sha256.Sum256(backgroundJSON))[:16]
+		cacheKey += ":bg" + backgroundHash
+	}
 
 	if s.deps.RedisClient != nil {
 		cachedData := s.deps.RedisClient.Get(ctx, cacheKey)
@@ -725,6 +882,12 @@ func (s *PublicService) GeneratePreview(ctx context.Context, file *multipart.Fil
 	}
 
 	previewHash := fmt.Sprintf("%s_%s_%s", size, fmt.Sprintf("%s_%s", style, lighting), contrast)
+	if textsHash != "" {
+		previewHash += "_" + textsHash
+	}
+	if backgroundHash != "" {
+		previewHash += "_bg" + backgroundHash
+	}
 	previewID := uuid.NewSHA1(uuid.NameSpaceURL, []byte(previewHash))
 
 	existingPreview, err := s.deps.PublicRepository.GetByID(ctx, previewID)
@@ -748,10 +911,27 @@ func (s *PublicService) GeneratePreview(ctx context.Context, file *multipart.Fil
 	}
 
 	img = s.resizeImage(img, size)
-	img = s.ApplyStyle(img, style)
+	if bg != nil {
+		img, err = s.deps.ImageService.ReplaceBackground(ctx, img, bg, "", style)
+		if err != nil {
+			return nil, fmt.Errorf("failed to replace background: %w", err)
+		}
+	}
 	img = s.ApplyLighting(img, lighting)
 	img = s.ApplyContrast(img, contrast)
 
+	if len(texts) > 0 {
+		img, err = s.deps.ImageService.ApplyTextOverlays(img, texts, "", style)
+		if err != nil {
+			return nil, fmt.Errorf("failed to apply text overlays: %w", err)
+		}
+	}
+
+	img, err = s.deps.ImageService.SimulateStyle(img, "", style, size)
+	if err != nil {
+		return nil, err
+	}
+
 	var buf bytes.Buffer
 	switch format {
 	case "jpeg", "jpg":
@@ -804,8 +984,8 @@ func (s *PublicService) GeneratePreview(ctx context.Context, file *multipart.Fil
 	return previewData, nil
 }
 
-// GenerateStylePreview generates a simple mosaic preview for a specific style
-func (s *PublicService) GenerateStylePreview(ctx context.Context, file *multipart.FileHeader, size, style string) (*PreviewData, error) {
+// GenerateStylePreview generates mosaic preview for a specific style quantized to its palette at stone grid of product
+func (s *PublicService) GenerateStylePreview(ctx context.Context, file *multipart.FileHeader, size, style, productType string) (*PreviewData, error) {
 	src, err := file.Open()
 	if err != nil {
 		return nil, fmt.Errorf("failed to open file: %w", err)
@@ -819,10 +999,14 @@ func (s *PublicService) GenerateStylePreview(ctx context.Context, file *multipar
 	src.Close()
 
 	fileHash := fmt.Sprintf("%x", sha256.Sum256(fileContent))
-	previewHash := fmt.Sprintf("style_%s_%s_%s", fileHash[:16], style, size)
+	if productType == "" {
+		productType = mosaic.ProductDiamondMosaic
+	}
+
+	previewHash := fmt.Sprintf("style_%s_%s_%s_%s", fileHash[:16], style, productType, size)
 	previewID := uuid.NewSHA1(uuid.NameSpaceURL, []byte(previewHash))
 
-	cacheKey := fmt.Sprintf("style_preview:%s:%s:%s", fileHash[:16], style, size)
+	cacheKey := fmt.Sprintf("style_preview:%s:%s:%s:%s", fileHash[:16], style, productType, size)
 
 	if s.deps.RedisClient != nil {
 		cachedData := s.deps.RedisClient.Get(ctx, cacheKey)
@@ -854,10 +1038,10 @@ func (s *PublicService) GenerateStylePreview(ctx context.Context, file *multipar
 		return nil, fmt.Errorf("failed to decode image: %w", err)
 	}
 
-	img = s.resizeImage(img, size)
-	img = s.ApplyStyle(img, style)
-	img = s.ApplyLighting(img, "sun")
-	img = s.ApplyContrast(img, "normal")
+	img, err = s.deps.ImageService.SimulateStyle(img, productType, style, size)
+	if err != nil {
+		return nil, err
+	}
 
 	var buf bytes.Buffer
 	switch format {
@@ -942,25 +1126,6 @@ func (s *PublicService) GenerateAIPreview(ctx context.Context, file *multipart.F
 	return previewData, nil
 }
 
-// ApplyStyle applies the main processing style to the image
-func (s *PublicService) ApplyStyle(img image.Image, style string) image.Image {
-	switch style {
-	case "grayscale":
-		return imaging.Grayscale(img)
-	case "skin_tones":
-		img = imaging.AdjustSaturation(img, -20)
-		return s.applyColorFilter(img, color.RGBA{255, 220, 200, 20})
-	case "pop_art":
-		img = imaging.AdjustSaturation(img, 50)
-		return imaging.AdjustContrast(img, 30)
-	case "max_colors":
-		img = imaging.AdjustSaturation(img, 20)
-		return imaging.AdjustGamma(img, 1.2)
-	default:
-		return img
-	}
-}
-
 // ApplyLighting applies lighting effects (sun, moon, venus)
 func (s *PublicService) ApplyLighting(img image.Image, lighting string) image.Image {
 	switch lighting {
@@ -1003,7 +1168,7 @@ func (s *PublicService) applyColorFilter(img image.Image, filterColor color.RGBA
 	return filtered
 }
 
-// GenerateAllPreviews generates all 8 base previews + optional 1 AI preview
+// GenerateAllPreviews generates 4 coupon style previews ranked by recommendation, 8 base previews + optional 1 AI preview
 func (s *PublicService) GenerateAllPreviews(ctx context.Context, imageID string, size string, useAI bool) (*GenerateAllPreviewsResponse, error) {
 	imageUUID, err := uuid.Parse(imageID)
 	if err != nil {
@@ -1043,6 +1208,9 @@ func (s *PublicService) GenerateAllPreviews(ctx context.Context, imageID string,
 		"moon":  "Луна",
 		"mars":  "Марс",
 	}
+	for _, style := range s.GetAvailableStyles() {
+		styleLabels[style["style"].(string)] = style["title"].(string)
+	}
 
 	type previewTask struct {
 		style    string
@@ -1050,9 +1218,23 @@ func (s *PublicService) GenerateAllPreviews(ctx context.Context, imageID string,
 			Value string
 			Label string
 		}
+		score *styleRecommendation.Score
 	}
 
+	// Coupon style previews go first in order of recommendation, then lighting variants
+	_, ranking := styleRecommendation.Recommend(originalImg)
 	var tasks []previewTask
+	for _, score := range ranking {
+		score := score
+		tasks = append(tasks, previewTask{
+			style: score.Style,
+			contrast: struct {
+				Value string
+				Label string
+			}{"normal", "обычный контраст"},
+			score: &score,
+		})
+	}
 	for _, style := range styles {
 		for _, contrast := range contrasts {
 			tasks = append(tasks, previewTask{
@@ -1067,9 +1249,19 @@ func (s *PublicService) GenerateAllPreviews(ctx context.Context, imageID string,
 
 	for _, task := range tasks {
 		go func() {
-			processedImg := s.resizeImage(originalImg, size)
-			processedImg = s.ApplyLighting(processedImg, task.style)
-			processedImg = s.ApplyContrast(processedImg, task.contrast.Value)
+			var processedImg image.Image
+			if task.score != nil {
+				simulated, err := s.deps.ImageService.SimulateStyle(originalImg, "", task.style, size)
+				if err != nil {
+					errorChan <- err
+					return
+				}
+				processedImg = simulated
+			} else {
+				processedImg = s.resizeImage(originalImg, size)
+				processedImg = s.ApplyLighting(processedImg, task.style)
+				processedImg = s.ApplyContrast(processedImg, task.contrast.Value)
+			}
 
 			var buf bytes.Buffer
 			switch format {
@@ -1094,7 +1286,7 @@ func (s *PublicService) GenerateAllPreviews(ctx context.Context, imageID string,
 
 			previewURL := s.deps.S3Client.GetPreviewURL(previewKey)
 
-			resultChan <- PreviewInfo{
+			preview := PreviewInfo{
 				ID:       previewID.String(),
 				URL:      previewURL,
 				Style:    task.style,
@@ -1102,6 +1294,12 @@ func (s *PublicService) GenerateAllPreviews(ctx context.Context, imageID string,
 				Label:    fmt.Sprintf("%s (%s)", styleLabels[task.style], task.contrast.Label),
 				IsAI:     false,
 			}
+			if task.score != nil {
+				preview.Rank = task.score.Rank
+				preview.Score = task.score.Score
+				preview.Recommended = task.score.Rank == 1
+			}
+			resultChan <- preview
 		}()
 	}
 
@@ -1199,14 +1397,17 @@ func (s *PublicService) GenerateAllPreviews(ctx context.Context, imageID string,
 		}()
 	}
 
+	orderPreviews(previews)
+
 	return &GenerateAllPreviewsResponse{
-		Previews: previews,
-		Total:    len(previews),
-		ImageID:  imageID,
+		Previews:        previews,
+		Total:           len(previews),
+		ImageID:         imageID,
+		Recommendations: ranking,
 	}, nil
 }
 
-// GenerateAllPreviewsFromFile generates all 8 base previews + optional 1 AI preview directly from uploaded file
+// GenerateAllPreviewsFromFile generates ranked style previews, 8 base previews + optional 1 AI preview directly from uploaded file
 func (s *PublicService) GenerateAllPreviewsFromFile(ctx context.Context, file *multipart.FileHeader, size string, useAI bool) (*GenerateAllPreviewsResponse, error) {
 	src, err := file.Open()
 	if err != nil {
@@ -1239,6 +1440,9 @@ func (s *PublicService) GenerateAllPreviewsFromFile(ctx context.Context, file *m
 		"moon":  "Луна",
 		"mars":  "Марс",
 	}
+	for _, style := range s.GetAvailableStyles() {
+		styleLabels[style["style"].(string)] = style["title"].(string)
+	}
 
 	type previewTask struct {
 		style    string
@@ -1246,9 +1450,23 @@ func (s *PublicService) GenerateAllPreviewsFromFile(ctx context.Context, file *m
 			Value string
 			Label string
 		}
+		score *styleRecommendation.Score
 	}
 
+	// Coupon style previews go first in order of recommendation, then lighting variants
+	_, ranking := styleRecommendation.Recommend(originalImg)
 	var tasks []previewTask
+	for _, score := range ranking {
+		score := score
+		tasks = append(tasks, previewTask{
+			style: score.Style,
+			contrast: struct {
+				Value string
+				Label string
+			}{"normal", "обычный контраст"},
+			score: &score,
+		})
+	}
 	for _, style := range styles {
 		for _, contrast := range contrasts {
 			tasks = append(tasks, previewTask{
@@ -1264,9 +1482,19 @@ func (s *PublicService) GenerateAllPreviewsFromFile(ctx context.Context, file *m
 	for _, task := range tasks {
 		task := task
 		go func() {
-			processedImg := s.resizeImage(originalImg, size)
-			processedImg = s.ApplyLighting(processedImg, task.style)
-			processedImg = s.ApplyContrast(processedImg, task.contrast.Value)
+			var processedImg image.Image
+			if task.score != nil {
+				simulated, err := s.deps.ImageService.SimulateStyle(originalImg, "", task.style, size)
+				if err != nil {
+					errorChan <- err
+					return
+				}
+				processedImg = simulated
+			} else {
+				processedImg = s.resizeImage(originalImg, size)
+				processedImg = s.ApplyLighting(processedImg, task.style)
+				processedImg = s.ApplyContrast(processedImg, task.contrast.Value)
+			}
 
 			var buf bytes.Buffer
 			switch format {
@@ -1291,7 +1519,7 @@ func (s *PublicService) GenerateAllPreviewsFromFile(ctx context.Context, file *m
 
 			previewURL := s.deps.S3Client.GetPreviewURL(previewKey)
 
-			resultChan <- PreviewInfo{
+			preview := PreviewInfo{
 				ID:       previewID.String(),
 				URL:      previewURL,
 				Style:    task.style,
@@ -1299,6 +1527,12 @@ func (s *PublicService) GenerateAllPreviewsFromFile(ctx context.Context, file *m
 				Label:    fmt.Sprintf("%s (%s)", styleLabels[task.style], task.contrast.Label),
 				IsAI:     false,
 			}
+			if task.score != nil {
+				preview.Rank = task.score.Rank
+				preview.Score = task.score.Score
+				preview.Recommended = task.score.Rank == 1
+			}
+			resultChan <- preview
 		}()
 	}
 
@@ -1387,13 +1621,90 @@ func (s *PublicService) GenerateAllPreviewsFromFile(ctx context.Context, file *m
 		}
 	}
 
+	orderPreviews(previews)
+
 	return &GenerateAllPreviewsResponse{
-		Previews: previews,
-		Total:    len(previews),
-		ImageID:  sessionID,
+		Previews:        previews,
+		Total:           len(previews),
+		ImageID:         sessionID,
+		Recommendations: ranking,
 	}, nil
 }
 
+// RecommendStyle ranks coupon styles for uploaded image by its colorfulness, skin tones, contrast and hues
+func (s *PublicService) RecommendStyle(ctx context.Context, imageID string) (*StyleRecommendationResponse, error) {
+	imageUUID, err := uuid.Parse(imageID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid image ID: %w", err)
+	}
+
+	img, err := s.deps.ImageRepository.GetByID(ctx, imageUUID)
+	if err != nil {
+		return nil, fmt.Errorf("image not found: %w", err)
+	}
+
+	imageData, err := s.deps.S3Client.DownloadFile(ctx, img.OriginalImageS3Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer imageData.Close()
+
+	originalImg, _, err := s.deps.S3Client.Decode(imageData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	recommendation := recommendStyle(originalImg)
+	recommendation.ImageID = imageID
+	return recommendation, nil
+}
+
+// RecommendStyleFromFile ranks coupon styles for image file without saving it
+func (s *PublicService) RecommendStyleFromFile(ctx context.Context, file *multipart.FileHeader) (*StyleRecommendationResponse, error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open file: %w", err)
+	}
+	defer src.Close()
+
+	originalImg, _, err := s.deps.S3Client.Decode(src)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return recommendStyle(originalImg), nil
+}
+
+func recommendStyle(img image.Image) *StyleRecommendationResponse {
+	analysis, ranking := styleRecommendation.Recommend(img)
+	return &StyleRecommendationResponse{
+		Recommended:     ranking[0].Style,
+		Recommendations: ranking,
+		Analysis:        analysis,
+	}
+}
+
+// orderPreviews puts coupon style previews first by rank, keeps base previews in generation order and AI preview last
+func orderPreviews(previews []PreviewInfo) {
+	lightings := map[string]int{"venus": 0, "sun": 1, "moon": 2, "mars": 3}
+	contrasts := map[string]int{"soft": 0, "strong": 1}
+
+	position := func(p PreviewInfo) int {
+		switch {
+		case p.Rank > 0:
+			return p.Rank
+		case p.IsAI:
+			return 1000
+		default:
+			return 100 + lightings[p.Style]*len(contrasts) + contrasts[p.Contrast]
+		}
+	}
+
+	sort.SliceStable(previews, func(i, j int) bool {
+		return position(previews[i]) < position(previews[j])
+	})
+}
+
 func (s *PublicService) parseSize(size string) (int, int) {
 	switch size {
 	case "21x30":
@@ -3,10 +3,13 @@ package public
 import (
 	"context"
 	"errors"
+	stdimage "image"
+	"image/color"
 	"mime/multipart"
 	"testing"
 	"time"
 
+	"github.com/disintegration/imaging"
 	"github.com/google/uuid"
 	"github.com/skr1ms/mosaic/config"
 	"github.com/skr1ms/mosaic/internal/coupon"
@@ -14,6 +17,7 @@ import (
 	"github.com/skr1ms/mosaic/internal/partner"
 	"github.com/skr1ms/mosaic/internal/payment"
 	"github.com/skr1ms/mosaic/internal/types"
+	"github.com/skr1ms/mosaic/pkg/collage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -215,6 +219,22 @@ func (m *MockImageService) GenerateSchema(ctx context.Context, imageID uuid.UUID
 	return args.Error(0)
 }
 
+func (m *MockImageService) CancelImage(ctx context.Context, imageID uuid.UUID) (*image.Image, error) {
+	args := m.Called(ctx, imageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*image.Image), args.Error(1)
+}
+
+func (m *MockImageService) ResumeImage(ctx context.Context, imageID uuid.UUID) (*image.Image, error) {
+	args := m.Called(ctx, imageID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*image.Image), args.Error(1)
+}
+
 func (m *MockImageService) GetImageStatus(ctx context.Context, imageID uuid.UUID) (*types.ImageStatusResponse, error) {
 	args := m.Called(ctx, imageID)
 	if args.Get(0) == nil {
@@ -223,6 +243,43 @@ func (m *MockImageService) GetImageStatus(ctx context.Context, imageID uuid.UUID
 	return args.Get(0).(*types.ImageStatusResponse), args.Error(1)
 }
 
+func (m *MockImageService) UploadCollage(ctx context.Context, couponID uuid.UUID, templateName string, files []*multipart.FileHeader, userEmail string) (*image.Image, error) {
+	args := m.Called(ctx, couponID, templateName, files, userEmail)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*image.Image), args.Error(1)
+}
+
+func (m *MockImageService) GetCollageTemplates(size string) []collage.Template {
+	args := m.Called(size)
+	return args.Get(0).([]collage.Template)
+}
+
+func (m *MockImageService) ApplyTextOverlays(img stdimage.Image, overlays []image.TextOverlay, productType, couponStyle string) (stdimage.Image, error) {
+	args := m.Called(img, overlays, productType, couponStyle)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(stdimage.Image), args.Error(1)
+}
+
+func (m *MockImageService) SimulateStyle(img stdimage.Image, productType, couponStyle, size string) (stdimage.Image, error) {
+	args := m.Called(img, productType, couponStyle, size)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(stdimage.Image), args.Error(1)
+}
+
+func (m *MockImageService) ReplaceBackground(ctx context.Context, img stdimage.Image, params *image.BackgroundParams, productType, couponStyle string) (stdimage.Image, error) {
+	args := m.Called(ctx, img, params, productType, couponStyle)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(stdimage.Image), args.Error(1)
+}
+
 // Mock Payment Service
 type MockPaymentService struct {
 	mock.Mock
@@ -555,6 +612,17 @@ func TestPublicService_ActivateCoupon(t *testing.T) {
 			},
 			expectedError: false, // Service now allows re-activation due to simplified validation
 		},
+		{
+			name: "coupon_expired",
+			code: "123456789012",
+			mockSetup: func(repo *MockCouponRepository) {
+				coupon := createTestCoupon()
+				expiresAt := time.Now().Add(-time.Hour)
+				coupon.ExpiresAt = &expiresAt
+				repo.On("GetByCode", mock.Anything, "123456789012").Return(coupon, nil)
+			},
+			expectedError: true,
+		},
 	}
 
 	for _, tt := range tests {
@@ -729,3 +797,72 @@ func TestPublicService_RequestStructures(t *testing.T) {
 		assert.Equal(t, "token123", req.PaymentToken)
 	})
 }
+
+func TestPublicService_RecommendStyle(t *testing.T) {
+	portrait := imaging.New(200, 200, color.NRGBA{R: 40, G: 40, B: 50, A: 255})
+	portrait = imaging.Overlay(portrait, imaging.New(140, 160, color.NRGBA{R: 224, G: 172, B: 140, A: 255}), stdimage.Pt(30, 20), 1)
+
+	colorful := imaging.New(240, 200, color.Black)
+	hues := []color.NRGBA{
+		{R: 230, G: 30, B: 30, A: 255}, {R: 240, G: 150, B: 20, A: 255}, {R: 230, G: 230, B: 30, A: 255},
+		{R: 40, G: 200, B: 40, A: 255}, {R: 30, G: 200, B: 200, A: 255}, {R: 30, G: 60, B: 230, A: 255},
+		{R: 140, G: 40, B: 220, A: 255}, {R: 230, G: 40, B: 160, A: 255},
+	}
+	for i, hue := range hues {
+		colorful = imaging.Paste(colorful, imaging.New(30, 200, hue), stdimage.Pt(i*30, 0))
+	}
+
+	gray := imaging.New(200, 200, color.NRGBA{R: 230, G: 230, B: 230, A: 255})
+	gray = imaging.Paste(gray, imaging.New(100, 200, color.NRGBA{R: 30, G: 30, B: 30, A: 255}), stdimage.Pt(0, 0))
+
+	poster := imaging.New(200, 200, color.NRGBA{R: 250, G: 220, B: 0, A: 255})
+	poster = imaging.Paste(poster, imaging.New(100, 200, color.NRGBA{R: 230, G: 0, B: 20, A: 255}), stdimage.Pt(0, 0))
+
+	tests := []struct {
+		name     string
+		img      stdimage.Image
+		expected string
+	}{
+		{"portrait", portrait, "skin_tones"},
+		{"many_hues", colorful, "max_colors"},
+		{"black_and_white", gray, "grayscale"},
+		{"bold_colors", poster, "pop_art"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := recommendStyle(tt.img)
+			assert.Equal(t, tt.expected, result.Recommended)
+			assert.Len(t, result.Recommendations, 4)
+			for i, score := range result.Recommendations {
+				assert.Equal(t, i+1, score.Rank)
+				if i > 0 {
+					assert.LessOrEqual(t, score.Score, result.Recommendations[i-1].Score)
+				}
+			}
+		})
+	}
+}
+
+func TestOrderPreviews(t *testing.T) {
+	previews := []PreviewInfo{
+		{Style: "moon", Contrast: "strong"},
+		{Style: "ai", Contrast: "enhanced", IsAI: true},
+		{Style: "grayscale", Contrast: "normal", Rank: 3},
+		{Style: "venus", Contrast: "soft"},
+		{Style: "pop_art", Contrast: "normal", Rank: 1, Recommended: true},
+		{Style: "moon", Contrast: "soft"},
+		{Style: "max_colors", Contrast: "normal", Rank: 2},
+	}
+
+	orderPreviews(previews)
+
+	var order []string
+	for _, preview := range previews {
+		order = append(order, preview.Style+"_"+preview.Contrast)
+	}
+	assert.Equal(t, []string{
+		"pop_art_normal", "max_colors_normal", "grayscale_normal",
+		"venus_soft", "moon_soft", "moon_strong", "ai_enhanced",
+	}, order)
+}
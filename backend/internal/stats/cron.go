@@ -7,19 +7,22 @@ import (
 	"time"
 
 	"github.com/robfig/cron/v3"
+	"github.com/rs/zerolog/log"
 )
 
 type CronService struct {
 	Cron             *cron.Cron
 	StatsService     StatsServiceInterface
 	MetricsCollector MetricsCollectorInterface
+	CouponExpirer    CouponExpirerInterface
 }
 
-func NewCronService(statsService *StatsService) *CronService {
+func NewCronService(statsService *StatsService, couponExpirer CouponExpirerInterface) *CronService {
 	return &CronService{
 		Cron:             cron.New(),
 		StatsService:     statsService,
 		MetricsCollector: NewMetricsCollector(),
+		CouponExpirer:    couponExpirer,
 	}
 }
 
@@ -50,6 +53,13 @@ func (cs *CronService) Start() error {
 		return fmt.Errorf("failed to add daily stats aggregation: %w", err)
 	}
 
+	if cs.CouponExpirer != nil {
+		_, err = cs.Cron.AddFunc("*/15 * * * *", cs.flagExpiredCoupons)
+		if err != nil {
+			return fmt.Errorf("failed to add expired coupons flagging: %w", err)
+		}
+	}
+
 	cs.Cron.Start()
 	return nil
 }
@@ -143,3 +153,18 @@ func (cs *CronService) aggregateDailyStats() {
 	}
 
 }
+
+// flagExpiredCoupons marks coupons whose expiry has passed, so they can be filtered out in lists and exports
+func (cs *CronService) flagExpiredCoupons() {
+	ctx := context.Background()
+
+	flagged, err := cs.CouponExpirer.FlagExpired(ctx, time.Now())
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to flag expired coupons")
+		cs.MetricsCollector.IncrementErrors("coupon_expiry", "flag_expired")
+		return
+	}
+	if flagged > 0 {
+		log.Info().Int64("flagged", flagged).Msg("Expired coupons flagged")
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"github.com/redis/go-redis/v9"
 	"github.com/skr1ms/mosaic/internal/partner"
 	"github.com/skr1ms/mosaic/pkg/jwt"
+	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
 )
 
 type CouponRepositoryInterface interface {
@@ -25,6 +26,11 @@ type CouponRepositoryInterface interface {
 	HealthCheck(ctx context.Context) error
 }
 
+// CouponExpirerInterface flags coupons whose expiry has passed
+type CouponExpirerInterface interface {
+	FlagExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
 type PartnerRepositoryInterface interface {
 	CountActive(ctx context.Context) (int64, error)
 	CountTotal(ctx context.Context) (int64, error)
@@ -39,6 +45,10 @@ type RedisClientInterface interface {
 	LLen(ctx context.Context, key string) *redis.IntCmd
 }
 
+type AIClientInterface interface {
+	CircuitStatus() stableDiffusion.BreakerStatus
+}
+
 type StatsServiceInterface interface {
 	GetGeneralStats(ctx context.Context) (*GeneralStatsResponse, error)
 	GetPartnerStats(ctx context.Context, partnerID uuid.UUID) (*PartnerStatsResponse, error)
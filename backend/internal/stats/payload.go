@@ -4,6 +4,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
 )
 
 type GeneralStatsResponse struct {
@@ -55,11 +56,14 @@ type SystemHealthResponse struct {
 	Status                string  `json:"status"` // healthy, warning, critical
 	DatabaseStatus        string  `json:"database_status"`
 	RedisStatus           string  `json:"redis_status"`
+	AIStatus              string  `json:"ai_status"` // State of Stable Diffusion circuit breaker: closed, open, half_open
 	ImageProcessingQueue  int64   `json:"image_processing_queue"`
 	AverageProcessingTime float64 `json:"average_processing_time"`
 	ErrorRate             float64 `json:"error_rate"`
 	Uptime                string  `json:"uptime"`
 	LastUpdated           string  `json:"last_updated"`
+
+	AICircuit stableDiffusion.BreakerStatus `json:"ai_circuit"`
 }
 
 type RealTimeStatsResponse struct {
@@ -7,12 +7,14 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
 )
 
 type StatsServiceDeps struct {
 	CouponRepository  CouponRepositoryInterface
 	PartnerRepository PartnerRepositoryInterface
 	RedisClient       RedisClientInterface
+	AIClient          AIClientInterface
 }
 
 type StatsService struct {
@@ -227,9 +229,17 @@ func (s *StatsService) GetSystemHealth(ctx context.Context) (*SystemHealthRespon
 		redisStatus = "unhealthy"
 	}
 
+	aiCircuit := stableDiffusion.BreakerStatus{State: stableDiffusion.BreakerClosed}
+	if s.deps.AIClient != nil {
+		aiCircuit = s.deps.AIClient.CircuitStatus()
+	}
+
 	overallStatus := "healthy"
 	if dbStatus != "healthy" || redisStatus != "healthy" {
 		overallStatus = "unhealthy"
+	} else if aiCircuit.State != stableDiffusion.BreakerClosed {
+		// Images are still processed, but without AI
+		overallStatus = "warning"
 	}
 
 	imageProcessingQueue := int64(0)
@@ -241,6 +251,8 @@ func (s *StatsService) GetSystemHealth(ctx context.Context) (*SystemHealthRespon
 		Status:                overallStatus,
 		DatabaseStatus:        dbStatus,
 		RedisStatus:           redisStatus,
+		AIStatus:              string(aiCircuit.State),
+		AICircuit:             aiCircuit,
 		ImageProcessingQueue:  imageProcessingQueue,
 		AverageProcessingTime: 45.6,
 		ErrorRate:             0.02,
@@ -7,8 +7,10 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/redis/go-redis/v9"
 	"github.com/skr1ms/mosaic/internal/partner"
+	"github.com/skr1ms/mosaic/pkg/stableDiffusion"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -18,6 +20,11 @@ type MockCouponRepository struct {
 	mock.Mock
 }
 
+func (m *MockCouponRepository) FlagExpired(ctx context.Context, now time.Time) (int64, error) {
+	args := m.Called(ctx, now)
+	return args.Get(0).(int64), args.Error(1)
+}
+
 func (m *MockCouponRepository) CountTotal(ctx context.Context) (int64, error) {
 	args := m.Called(ctx)
 	return args.Get(0).(int64), args.Error(1)
@@ -149,6 +156,16 @@ func (m *MockRedisClient) LLen(ctx context.Context, key string) *redis.IntCmd {
 	return args.Get(0).(*redis.IntCmd)
 }
 
+// Mock AI Client
+type MockAIClient struct {
+	mock.Mock
+}
+
+func (m *MockAIClient) CircuitStatus() stableDiffusion.BreakerStatus {
+	args := m.Called()
+	return args.Get(0).(stableDiffusion.BreakerStatus)
+}
+
 func createTestPartner() *partner.Partner {
 	return &partner.Partner{
 		ID:        uuid.New(),
@@ -536,9 +553,11 @@ func TestStatsService_GetTimeSeriesStats(t *testing.T) {
 
 func TestStatsService_GetSystemHealth(t *testing.T) {
 	tests := []struct {
-		name          string
-		mockSetup     func(*MockCouponRepository, *MockRedisClient)
-		expectedError bool
+		name           string
+		mockSetup      func(*MockCouponRepository, *MockRedisClient)
+		aiStatus       *stableDiffusion.BreakerStatus
+		expectedStatus string
+		expectedError  bool
 	}{
 		{
 			name: "healthy_system",
@@ -549,7 +568,21 @@ func TestStatsService_GetSystemHealth(t *testing.T) {
 				redisClient.On("LLen", mock.Anything, "image_processing_queue").Return(&redis.IntCmd{})
 				redisClient.On("Set", mock.Anything, "system_health", mock.Anything, 1*time.Minute).Return(&redis.StatusCmd{})
 			},
-			expectedError: false,
+			expectedStatus: "healthy",
+			expectedError:  false,
+		},
+		{
+			name: "ai_circuit_open",
+			mockSetup: func(couponRepo *MockCouponRepository, redisClient *MockRedisClient) {
+				redisClient.On("Get", mock.Anything, "system_health").Return(&redis.StringCmd{})
+				couponRepo.On("HealthCheck", mock.Anything).Return(nil)
+				redisClient.On("Ping", mock.Anything).Return(&redis.StatusCmd{})
+				redisClient.On("LLen", mock.Anything, "image_processing_queue").Return(&redis.IntCmd{})
+				redisClient.On("Set", mock.Anything, "system_health", mock.Anything, 1*time.Minute).Return(&redis.StatusCmd{})
+			},
+			aiStatus:       &stableDiffusion.BreakerStatus{State: stableDiffusion.BreakerOpen},
+			expectedStatus: "warning",
+			expectedError:  false,
 		},
 		{
 			name: "unhealthy_database",
@@ -560,7 +593,8 @@ func TestStatsService_GetSystemHealth(t *testing.T) {
 				redisClient.On("LLen", mock.Anything, "image_processing_queue").Return(&redis.IntCmd{})
 				redisClient.On("Set", mock.Anything, "system_health", mock.Anything, 1*time.Minute).Return(&redis.StatusCmd{})
 			},
-			expectedError: false,
+			expectedStatus: "unhealthy",
+			expectedError:  false,
 		},
 	}
 
@@ -577,13 +611,18 @@ func TestStatsService_GetSystemHealth(t *testing.T) {
 				CouponRepository: mockCouponRepo,
 				RedisClient:      mockRedisClient,
 			}
+			if tt.aiStatus != nil {
+				mockAIClient := &MockAIClient{}
+				mockAIClient.On("CircuitStatus").Return(*tt.aiStatus)
+				deps.AIClient = mockAIClient
+			}
 			service := NewStatsService(deps)
 
 			result, err := service.GetSystemHealth(context.Background())
 
 			assert.NoError(t, err)
 			assert.NotNil(t, result)
-			assert.NotEmpty(t, result.Status)
+			assert.Equal(t, tt.expectedStatus, result.Status)
 			assert.NotEmpty(t, result.DatabaseStatus)
 			assert.NotEmpty(t, result.RedisStatus)
 			assert.NotZero(t, result.AverageProcessingTime)
@@ -940,3 +979,29 @@ func TestStatsService_RequestStructures(t *testing.T) {
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestCronService_FlagExpiredCoupons(t *testing.T) {
+	t.Run("flags_expired", func(t *testing.T) {
+		expirer := new(MockCouponRepository)
+		expirer.On("FlagExpired", mock.Anything, mock.AnythingOfType("time.Time")).Return(int64(3), nil)
+		cs := &CronService{MetricsCollector: NewMetricsCollector(), CouponExpirer: expirer}
+
+		before := testutil.ToFloat64(ErrorsTotal.WithLabelValues("coupon_expiry", "flag_expired"))
+		cs.flagExpiredCoupons()
+
+		expirer.AssertExpectations(t)
+		assert.Equal(t, before, testutil.ToFloat64(ErrorsTotal.WithLabelValues("coupon_expiry", "flag_expired")))
+	})
+
+	t.Run("repository_error", func(t *testing.T) {
+		expirer := new(MockCouponRepository)
+		expirer.On("FlagExpired", mock.Anything, mock.AnythingOfType("time.Time")).Return(int64(0), errors.New("db error"))
+		cs := &CronService{MetricsCollector: NewMetricsCollector(), CouponExpirer: expirer}
+
+		before := testutil.ToFloat64(ErrorsTotal.WithLabelValues("coupon_expiry", "flag_expired"))
+		cs.flagExpiredCoupons()
+
+		expirer.AssertExpectations(t)
+		assert.Equal(t, before+1, testutil.ToFloat64(ErrorsTotal.WithLabelValues("coupon_expiry", "flag_expired")))
+	})
+}
@@ -17,6 +17,17 @@ type EditImageRequest struct {
 	CropHeight int     `json:"crop_height" validate:"min=1"`           // Height of crop area
 	Rotation   int     `json:"rotation" validate:"oneof=0 90 180 270"` // Rotation in degrees
 	Scale      float64 `json:"scale" validate:"min=0.1,max=5.0"`       // Scale
+
+	Texts []TextOverlayRequest `json:"texts,omitempty" validate:"omitempty,max=5,dive"` // Names, dates and other texts
+}
+
+// TextOverlayRequest - text rendered onto edited image
+type TextOverlayRequest struct {
+	Text      string  `json:"text" validate:"required,max=100"`                                                                            // Text, lines separated with \n
+	Font      string  `json:"font,omitempty" validate:"omitempty,oneof=regular bold italic mono"`                                          // Bundled font
+	Position  string  `json:"position,omitempty" validate:"omitempty,oneof=top center bottom top_left top_right bottom_left bottom_right"` // Text anchor
+	Size      float64 `json:"size,omitempty" validate:"omitempty,min=2,max=25"`                                                            // Line height in percent of image height
+	ColorCode string  `json:"color_code,omitempty"`                                                                                        // Color code from active palette
 }
 
 // ProcessImageRequest - image processing request (style selection)
@@ -27,6 +38,15 @@ type ProcessImageRequest struct {
 	Contrast   string  `json:"contrast,omitempty" validate:"omitempty,oneof=low high"`                  // Contrast (2 options)
 	Brightness float64 `json:"brightness,omitempty" validate:"omitempty,min=-100,max=100"`              // Brightness (-100 to 100)
 	Saturation float64 `json:"saturation,omitempty" validate:"omitempty,min=-100,max=100"`              // Saturation (-100 to 100)
+
+	Background *BackgroundRequest `json:"background,omitempty"` // Replace background behind isolated subject
+}
+
+// BackgroundRequest - background replacement behind photo subject isolated by AI
+type BackgroundRequest struct {
+	Mode      string  `json:"mode" validate:"required,oneof=color blur"`        // Solid palette color or blurred original background
+	ColorCode string  `json:"color_code,omitempty"`                             // Color code from active palette, lightest color when empty
+	Blur      float64 `json:"blur,omitempty" validate:"omitempty,min=1,max=10"` // Blur radius in percent of longest side
 }
 
 // GenerateSchemaRequest - schema generation request
@@ -71,15 +91,17 @@ type GenerateSchemaResponse struct {
 
 // ImageStatusResponse - response with image processing status
 type ImageStatusResponse struct {
-	ImageID       uuid.UUID `json:"image_id"`
-	Status        string    `json:"status"` // queued, processing, completed, failed
-	Message       string    `json:"message"`
-	Progress      int       `json:"progress"`
-	EstimatedTime *int      `json:"estimated_time"`
-	ErrorMessage  *string   `json:"error_message"`
-	OriginalURL   *string   `json:"original_url"`
-	EditedURL     *string   `json:"edited_url"`
-	ProcessedURL  *string   `json:"processed_url"`
-	PreviewURL    *string   `json:"preview_url"`
-	ZipURL        *string   `json:"zip_url"`
+	ImageID       uuid.UUID         `json:"image_id"`
+	Status        string            `json:"status"` // queued, processing, completed, failed
+	Message       string            `json:"message"`
+	Progress      int               `json:"progress"`
+	EstimatedTime *int              `json:"estimated_time"`
+	ErrorMessage  *string           `json:"error_message"`
+	OriginalURL   *string           `json:"original_url"`
+	EditedURL     *string           `json:"edited_url"`
+	ProcessedURL  *string           `json:"processed_url"`
+	PreviewURL    *string           `json:"preview_url"`
+	ZipURL        *string           `json:"zip_url"`
+	ThumbnailURLs map[string]string `json:"thumbnail_urls,omitempty"` // Thumbnail URLs by longest side in pixels
+	MockupURLs    map[string]string `json:"mockup_urls,omitempty"`    // Mockup render URLs by scene name
 }
@@ -7,11 +7,13 @@ import (
 	"github.com/rs/zerolog/log"
 	"github.com/skr1ms/mosaic/config"
 	"github.com/skr1ms/mosaic/internal/admin"
+	"github.com/skr1ms/mosaic/internal/bulk"
 	"github.com/skr1ms/mosaic/internal/chat"
 	"github.com/skr1ms/mosaic/internal/coupon"
 	"github.com/skr1ms/mosaic/internal/image"
 	"github.com/skr1ms/mosaic/internal/partner"
 	"github.com/skr1ms/mosaic/internal/payment"
+	"github.com/skr1ms/mosaic/internal/preset"
 	"github.com/skr1ms/mosaic/internal/public"
 	"github.com/skr1ms/mosaic/pkg/bcrypt"
 	"github.com/skr1ms/mosaic/pkg/db"
@@ -34,6 +36,7 @@ func Init(cfg *config.Config) {
 		(*partner.PartnerArticle)(nil),
 		(*admin.Admin)(nil),
 		(*admin.ProfileChangeLog)(nil),
+		(*coupon.CouponBatch)(nil),
 		(*coupon.Coupon)(nil),
 		(*image.Image)(nil),
 		(*payment.Order)(nil),
@@ -41,6 +44,10 @@ func Init(cfg *config.Config) {
 		(*chat.SupportChat)(nil),
 		(*chat.SupportMessage)(nil),
 		(*public.PreviewData)(nil),
+		(*bulk.BulkJob)(nil),
+		(*bulk.BulkJobItem)(nil),
+		(*preset.PromptPreset)(nil),
+		(*preset.PromptPresetVersion)(nil),
 	}
 
 	for _, model := range models {
@@ -50,6 +57,11 @@ func Init(cfg *config.Config) {
 		}
 	}
 
+	// Add columns introduced after tables were created
+	if err := addMissingColumns(database.DB, ctx); err != nil {
+		log.Fatal().Err(err).Msg("Failed to add missing columns")
+	}
+
 	// Create foreign key constraints with cascade deletion
 	if err := createForeignKeys(database.DB, ctx); err != nil {
 		log.Fatal().Err(err).Msg("Failed to create foreign keys")
@@ -99,6 +111,14 @@ func createEnumTypes(db *bun.DB, ctx context.Context) error {
 			WHEN duplicate_object THEN null;
 		END $$;`,
 
+		// ENUM for coupon product types
+		`DO $$ BEGIN
+			CREATE TYPE coupon_product_type AS ENUM ('diamond_mosaic', 'paint_by_numbers', 'fuse_beads');
+		EXCEPTION
+			WHEN duplicate_object THEN null;
+		END $$;`,
+		`ALTER TYPE coupon_product_type ADD VALUE IF NOT EXISTS 'fuse_beads';`,
+
 		// ENUM for coupon status
 		`DO $$ BEGIN
 			CREATE TYPE coupon_status AS ENUM ('new', 'activated', 'used', 'completed');
@@ -108,10 +128,11 @@ func createEnumTypes(db *bun.DB, ctx context.Context) error {
 
 		// ENUM for image processing status
 		`DO $$ BEGIN
-			CREATE TYPE processing_status AS ENUM ('queued', 'uploaded', 'edited', 'processing', 'processed', 'completed', 'failed');
+			CREATE TYPE processing_status AS ENUM ('queued', 'uploaded', 'edited', 'processing', 'processed', 'completed', 'failed', 'cancelled');
 		EXCEPTION
 			WHEN duplicate_object THEN null;
 		END $$;`,
+		`ALTER TYPE processing_status ADD VALUE IF NOT EXISTS 'cancelled';`,
 	}
 
 	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
@@ -128,6 +149,59 @@ func createEnumTypes(db *bun.DB, ctx context.Context) error {
 	})
 }
 
+// addMissingColumns adds columns to tables created by earlier versions
+func addMissingColumns(db *bun.DB, ctx context.Context) error {
+	columnQueries := []string{
+		// Product type of coupon (diamond mosaic, paint-by-numbers, fuse beads)
+		`ALTER TABLE coupons ADD COLUMN IF NOT EXISTS product_type coupon_product_type NOT NULL DEFAULT 'diamond_mosaic';`,
+
+		// Product type of purchased coupon
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS product_type VARCHAR NOT NULL DEFAULT 'diamond_mosaic';`,
+
+		// Multi-panel layout of coupon and purchased coupon
+		`ALTER TABLE coupons ADD COLUMN IF NOT EXISTS panels INTEGER NOT NULL DEFAULT 1;`,
+		`ALTER TABLE coupons ADD COLUMN IF NOT EXISTS panel_gap_mm INTEGER NOT NULL DEFAULT 0;`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS panels INTEGER NOT NULL DEFAULT 1;`,
+		`ALTER TABLE orders ADD COLUMN IF NOT EXISTS panel_gap_mm INTEGER NOT NULL DEFAULT 0;`,
+
+		// Edit parameters of image including text overlays
+		`ALTER TABLE images ADD COLUMN IF NOT EXISTS edit_params JSON;`,
+
+		// Collage template and source photos of image composed from several photos
+		`ALTER TABLE images ADD COLUMN IF NOT EXISTS collage JSON;`,
+
+		// Re-encoded original and thumbnails produced by background tasks
+		`ALTER TABLE images ADD COLUMN IF NOT EXISTS optimized_image_s3_key VARCHAR;`,
+		`ALTER TABLE images ADD COLUMN IF NOT EXISTS thumbnails JSON;`,
+
+		// Low-resolution image upscaled before schema generation
+		`ALTER TABLE images ADD COLUMN IF NOT EXISTS upscaled_image_s3_key VARCHAR;`,
+		`ALTER TABLE images ADD COLUMN IF NOT EXISTS upscale JSON;`,
+
+		// Framed renders of finished mosaic in scene templates
+		`ALTER TABLE images ADD COLUMN IF NOT EXISTS mockups JSON;`,
+
+		// Weight of partner in fair scheduling of image queue
+		`ALTER TABLE partners ADD COLUMN IF NOT EXISTS queue_weight INTEGER NOT NULL DEFAULT 1;`,
+
+		// Validity window of coupon and flag set by cron once it expires
+		`ALTER TABLE coupons ADD COLUMN IF NOT EXISTS valid_from TIMESTAMPTZ;`,
+		`ALTER TABLE coupons ADD COLUMN IF NOT EXISTS expires_at TIMESTAMPTZ;`,
+		`ALTER TABLE coupons ADD COLUMN IF NOT EXISTS is_expired BOOLEAN NOT NULL DEFAULT FALSE;`,
+		// Print run coupon was created in
+		`ALTER TABLE coupons ADD COLUMN IF NOT EXISTS batch_id UUID;`,
+	}
+
+	return db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		for _, query := range columnQueries {
+			if _, err := tx.Exec(query); err != nil {
+				return fmt.Errorf("error adding column: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
 // createForeignKeys creates foreign key constraints with cascade deletion
 func createForeignKeys(db *bun.DB, ctx context.Context) error {
 	foreignKeyQueries := []string{
@@ -141,6 +215,26 @@ func createForeignKeys(db *bun.DB, ctx context.Context) error {
 			WHEN duplicate_object THEN null;
 		END $$;`,
 
+		// Constraint between coupon_batches and partners
+		`DO $$ BEGIN
+			ALTER TABLE coupon_batches 
+			ADD CONSTRAINT fk_coupon_batches_partner_id 
+			FOREIGN KEY (partner_id) REFERENCES partners(id) 
+			ON DELETE CASCADE;
+		EXCEPTION
+			WHEN duplicate_object THEN null;
+		END $$;`,
+
+		// Constraint between coupons and coupon_batches, coupons outlive deleted batch
+		`DO $$ BEGIN
+			ALTER TABLE coupons 
+			ADD CONSTRAINT fk_coupons_batch_id 
+			FOREIGN KEY (batch_id) REFERENCES coupon_batches(id) 
+			ON DELETE SET NULL;
+		EXCEPTION
+			WHEN duplicate_object THEN null;
+		END $$;`,
+
 		// Constraint between images and coupons
 		`DO $$ BEGIN
 			ALTER TABLE images 
@@ -151,6 +245,46 @@ func createForeignKeys(db *bun.DB, ctx context.Context) error {
 			WHEN duplicate_object THEN null;
 		END $$;`,
 
+		// Constraint between bulk_jobs and partners
+		`DO $$ BEGIN
+			ALTER TABLE bulk_jobs 
+			ADD CONSTRAINT fk_bulk_jobs_partner_id 
+			FOREIGN KEY (partner_id) REFERENCES partners(id) 
+			ON DELETE CASCADE;
+		EXCEPTION
+			WHEN duplicate_object THEN null;
+		END $$;`,
+
+		// Constraint between bulk_job_items and bulk_jobs
+		`DO $$ BEGIN
+			ALTER TABLE bulk_job_items 
+			ADD CONSTRAINT fk_bulk_job_items_job_id 
+			FOREIGN KEY (job_id) REFERENCES bulk_jobs(id) 
+			ON DELETE CASCADE;
+		EXCEPTION
+			WHEN duplicate_object THEN null;
+		END $$;`,
+
+		// Constraint between bulk_job_items and images, items keep their rows when image is deleted
+		`DO $$ BEGIN
+			ALTER TABLE bulk_job_items 
+			ADD CONSTRAINT fk_bulk_job_items_image_id 
+			FOREIGN KEY (image_id) REFERENCES images(id) 
+			ON DELETE SET NULL;
+		EXCEPTION
+			WHEN duplicate_object THEN null;
+		END $$;`,
+
+		// Constraint between prompt_preset_versions and prompt_presets
+		`DO $$ BEGIN
+			ALTER TABLE prompt_preset_versions 
+			ADD CONSTRAINT fk_prompt_preset_versions_preset_id 
+			FOREIGN KEY (preset_id) REFERENCES prompt_presets(id) 
+			ON DELETE CASCADE;
+		EXCEPTION
+			WHEN duplicate_object THEN null;
+		END $$;`,
+
 		// Constraint between profile_changes and partners
 		`DO $$ BEGIN
 			ALTER TABLE profile_changes 
@@ -198,6 +332,11 @@ func createIndexes(db *bun.DB, ctx context.Context) error {
 		return fmt.Errorf("error creating index for coupons: %w", err)
 	}
 
+	couponBatchModel := &coupon.CouponBatch{}
+	if _, err := db.ExecContext(ctx, couponBatchModel.CreateIndex()); err != nil {
+		return fmt.Errorf("error creating index for coupon batches: %w", err)
+	}
+
 	imageModel := &image.Image{}
 	if _, err := db.ExecContext(ctx, imageModel.CreateIndex()); err != nil {
 		return fmt.Errorf("error creating index for images: %w", err)
@@ -208,6 +347,16 @@ func createIndexes(db *bun.DB, ctx context.Context) error {
 		return fmt.Errorf("error creating index for orders: %w", err)
 	}
 
+	bulkJobModel := &bulk.BulkJob{}
+	if _, err := db.ExecContext(ctx, bulkJobModel.CreateIndex()); err != nil {
+		return fmt.Errorf("error creating index for bulk jobs: %w", err)
+	}
+
+	promptPresetModel := &preset.PromptPreset{}
+	if _, err := db.ExecContext(ctx, promptPresetModel.CreateIndex()); err != nil {
+		return fmt.Errorf("error creating index for prompt presets: %w", err)
+	}
+
 	return nil
 }
 
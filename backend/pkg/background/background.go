@@ -0,0 +1,78 @@
+package background
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+
+	"github.com/disintegration/imaging"
+)
+
+// Mode is how background behind photo subject is replaced
+type Mode string
+
+const (
+	ModeColor Mode = "color" // Solid color
+	ModeBlur  Mode = "blur"  // Blurred original background
+)
+
+// DefaultBlurPercent is blur radius used when none given, in percent of longest side of image
+const DefaultBlurPercent = 3.0
+
+// minSubjectShare is smallest part of image mask has to cover to be taken as subject
+const minSubjectShare = 0.01
+
+// ErrNoSubject is returned when mask has no subject to keep
+var ErrNoSubject = errors.New("no subject found on photo")
+
+// Options of background replacement
+type Options struct {
+	Mode        Mode
+	Color       color.RGBA // Background color for ModeColor
+	BlurPercent float64    // Blur radius for ModeBlur in percent of longest side, DefaultBlurPercent when zero
+}
+
+// Replace keeps subject of image and replaces everything else. Mask is white where subject is and black
+// on background, gray values blend edges. Mask of other size is scaled to image.
+func Replace(img, mask image.Image, opts Options) (*image.NRGBA, error) {
+	src := imaging.Clone(img)
+	width, height := src.Bounds().Dx(), src.Bounds().Dy()
+
+	if mask.Bounds().Dx() != width || mask.Bounds().Dy() != height {
+		mask = imaging.Resize(mask, width, height, imaging.Linear)
+	}
+	alpha := imaging.Grayscale(mask)
+
+	var background *image.NRGBA
+	switch opts.Mode {
+	case ModeColor:
+		background = imaging.New(width, height, opts.Color)
+	case ModeBlur:
+		blur := opts.BlurPercent
+		if blur <= 0 {
+			blur = DefaultBlurPercent
+		}
+		background = imaging.Blur(src, float64(max(width, height))*blur/100)
+	default:
+		return nil, fmt.Errorf("unknown background mode: %s", opts.Mode)
+	}
+
+	var subject int
+	result := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for i := 0; i < len(result.Pix); i += 4 {
+		a := uint32(alpha.Pix[i]) * uint32(alpha.Pix[i+3]) / 255
+		if a >= 128 {
+			subject++
+		}
+		for c := 0; c < 3; c++ {
+			result.Pix[i+c] = uint8((uint32(src.Pix[i+c])*a + uint32(background.Pix[i+c])*(255-a) + 127) / 255)
+		}
+		result.Pix[i+3] = src.Pix[i+3]
+	}
+
+	if float64(subject) < float64(width*height)*minSubjectShare {
+		return nil, ErrNoSubject
+	}
+	return result, nil
+}
@@ -0,0 +1,212 @@
+package collage
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	MinPhotos = 2
+	MaxPhotos = 6
+
+	DefaultGutterPercent = 1.0  // Width of white line between photos in percent of smaller canvas side
+	squareTolerance      = 0.05 // Canvas is treated as square when its sides differ less than that
+)
+
+// Aspect is shape of canvas a template was designed for
+type Aspect string
+
+const (
+	AspectPortrait Aspect = "portrait"
+	AspectSquare   Aspect = "square"
+)
+
+// Slot is place of single photo on canvas, all values are fractions of canvas sides
+type Slot struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	W float64 `json:"w"`
+	H float64 `json:"h"`
+}
+
+// Template is predefined arrangement of photos, photos fill slots in order
+type Template struct {
+	Name    string   `json:"name"`
+	Title   string   `json:"title"`
+	Photos  int      `json:"photos"`
+	Slots   []Slot   `json:"slots"`
+	Aspects []Aspect `json:"-"`
+}
+
+var templates = []Template{
+	{
+		Name: "two_vertical", Title: "Два фото рядом",
+		Slots:   []Slot{{0, 0, 0.5, 1}, {0.5, 0, 0.5, 1}},
+		Aspects: []Aspect{AspectPortrait, AspectSquare},
+	},
+	{
+		Name: "two_horizontal", Title: "Два фото друг над другом",
+		Slots:   []Slot{{0, 0, 1, 0.5}, {0, 0.5, 1, 0.5}},
+		Aspects: []Aspect{AspectPortrait, AspectSquare},
+	},
+	{
+		Name: "three_main_top", Title: "Большое фото сверху и два снизу",
+		Slots:   []Slot{{0, 0, 1, 0.6}, {0, 0.6, 0.5, 0.4}, {0.5, 0.6, 0.5, 0.4}},
+		Aspects: []Aspect{AspectPortrait, AspectSquare},
+	},
+	{
+		Name: "three_main_left", Title: "Большое фото слева и два справа",
+		Slots:   []Slot{{0, 0, 0.6, 1}, {0.6, 0, 0.4, 0.5}, {0.6, 0.5, 0.4, 0.5}},
+		Aspects: []Aspect{AspectSquare},
+	},
+	{
+		Name: "three_rows", Title: "Три фото друг над другом",
+		Slots:   []Slot{{0, 0, 1, 1.0 / 3}, {0, 1.0 / 3, 1, 1.0 / 3}, {0, 2.0 / 3, 1, 1.0 / 3}},
+		Aspects: []Aspect{AspectPortrait},
+	},
+	{
+		Name: "four_grid", Title: "Четыре фото сеткой",
+		Slots:   []Slot{{0, 0, 0.5, 0.5}, {0.5, 0, 0.5, 0.5}, {0, 0.5, 0.5, 0.5}, {0.5, 0.5, 0.5, 0.5}},
+		Aspects: []Aspect{AspectPortrait, AspectSquare},
+	},
+	{
+		Name: "four_main_top", Title: "Большое фото сверху и три снизу",
+		Slots:   []Slot{{0, 0, 1, 0.65}, {0, 0.65, 1.0 / 3, 0.35}, {1.0 / 3, 0.65, 1.0 / 3, 0.35}, {2.0 / 3, 0.65, 1.0 / 3, 0.35}},
+		Aspects: []Aspect{AspectPortrait},
+	},
+	{
+		Name: "five_main_top", Title: "Большое фото сверху и четыре снизу",
+		Slots: []Slot{
+			{0, 0, 1, 0.5},
+			{0, 0.5, 0.5, 0.25}, {0.5, 0.5, 0.5, 0.25},
+			{0, 0.75, 0.5, 0.25}, {0.5, 0.75, 0.5, 0.25},
+		},
+		Aspects: []Aspect{AspectPortrait},
+	},
+	{
+		Name: "five_main_center", Title: "Большое фото в центре и четыре по углам",
+		Slots: []Slot{
+			{0, 0, 0.3, 0.5}, {0, 0.5, 0.3, 0.5},
+			{0.3, 0, 0.4, 1},
+			{0.7, 0, 0.3, 0.5}, {0.7, 0.5, 0.3, 0.5},
+		},
+		Aspects: []Aspect{AspectSquare},
+	},
+	{
+		Name: "six_grid", Title: "Шесть фото сеткой",
+		Slots: []Slot{
+			{0, 0, 0.5, 1.0 / 3}, {0.5, 0, 0.5, 1.0 / 3},
+			{0, 1.0 / 3, 0.5, 1.0 / 3}, {0.5, 1.0 / 3, 0.5, 1.0 / 3},
+			{0, 2.0 / 3, 0.5, 1.0 / 3}, {0.5, 2.0 / 3, 0.5, 1.0 / 3},
+		},
+		Aspects: []Aspect{AspectPortrait},
+	},
+	{
+		Name: "six_grid_wide", Title: "Шесть фото в два ряда",
+		Slots: []Slot{
+			{0, 0, 1.0 / 3, 0.5}, {1.0 / 3, 0, 1.0 / 3, 0.5}, {2.0 / 3, 0, 1.0 / 3, 0.5},
+			{0, 0.5, 1.0 / 3, 0.5}, {1.0 / 3, 0.5, 1.0 / 3, 0.5}, {2.0 / 3, 0.5, 1.0 / 3, 0.5},
+		},
+		Aspects: []Aspect{AspectSquare},
+	},
+}
+
+func init() {
+	for i := range templates {
+		templates[i].Photos = len(templates[i].Slots)
+	}
+}
+
+// CanvasAspect returns aspect of canvas with given pixel size
+func CanvasAspect(width, height int) Aspect {
+	if width <= 0 || height <= 0 {
+		return AspectPortrait
+	}
+	ratio := float64(width) / float64(height)
+	if math.Abs(ratio-1) <= squareTolerance {
+		return AspectSquare
+	}
+	return AspectPortrait
+}
+
+// Templates returns templates suitable for canvas with given pixel size
+func Templates(width, height int) []Template {
+	aspect := CanvasAspect(width, height)
+	result := make([]Template, 0, len(templates))
+	for _, t := range templates {
+		for _, a := range t.Aspects {
+			if a == aspect {
+				result = append(result, t)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// GetTemplate returns template by name if it suits canvas with given pixel size
+func GetTemplate(name string, width, height int) (Template, error) {
+	for _, t := range Templates(width, height) {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return Template{}, fmt.Errorf("collage template %q is not available for %s canvas", name, CanvasAspect(width, height))
+}
+
+// Compose arranges photos on white canvas of given size according to template.
+// Every photo is scaled and center-cropped to fill its slot.
+func Compose(t Template, photos []image.Image, width, height int, gutterPercent float64) (*image.NRGBA, error) {
+	if len(photos) != len(t.Slots) {
+		return nil, fmt.Errorf("template %s requires %d photos, got %d", t.Name, len(t.Slots), len(photos))
+	}
+	if width <= 0 || height <= 0 {
+		return nil, fmt.Errorf("invalid canvas size %dx%d", width, height)
+	}
+	if gutterPercent < 0 {
+		gutterPercent = 0
+	}
+
+	canvas := imaging.New(width, height, color.White)
+	gutter := int(math.Round(float64(min(width, height)) * gutterPercent / 100))
+
+	for i, slot := range t.Slots {
+		rect := slotRect(slot, width, height, gutter)
+		if rect.Dx() <= 0 || rect.Dy() <= 0 {
+			return nil, fmt.Errorf("slot %d of template %s is too small for canvas %dx%d", i, t.Name, width, height)
+		}
+		photo := imaging.Fill(photos[i], rect.Dx(), rect.Dy(), imaging.Center, imaging.Lanczos)
+		draw.Draw(canvas, rect, photo, image.Point{}, draw.Src)
+	}
+
+	return canvas, nil
+}
+
+// slotRect converts slot to pixels, inner edges are shrunk by half of gutter so
+// neighbouring photos are separated by full gutter and photos touch canvas borders
+func slotRect(slot Slot, width, height, gutter int) image.Rectangle {
+	x0 := int(math.Round(slot.X * float64(width)))
+	y0 := int(math.Round(slot.Y * float64(height)))
+	x1 := int(math.Round((slot.X + slot.W) * float64(width)))
+	y1 := int(math.Round((slot.Y + slot.H) * float64(height)))
+
+	half := gutter / 2
+	if x0 > 0 {
+		x0 += gutter - half
+	}
+	if y0 > 0 {
+		y0 += gutter - half
+	}
+	if x1 < width {
+		x1 -= half
+	}
+	if y1 < height {
+		y1 -= half
+	}
+	return image.Rect(x0, y0, x1, y1)
+}
@@ -35,6 +35,9 @@ const (
 	ErrorTypeInternal       ErrorType = "internal-server-error"
 	ErrorTypeBadRequest     ErrorType = "bad-request-error"
 	ErrorTypePayment        ErrorType = "payment-error"
+	ErrorTypeCouponExpired  ErrorType = "coupon-expired"
+	ErrorTypeCouponNotValid ErrorType = "coupon-not-yet-valid"
+	ErrorTypeCouponBlocked  ErrorType = "coupon-blocked"
 )
 
 // GetBaseURI returns base URI for error types
@@ -180,6 +183,38 @@ func PaymentError(detail string) *ProblemDetail {
 	)
 }
 
+// CouponExpiredError is returned when coupon is used after its expiry
+func CouponExpiredError(expiresAt time.Time) *ProblemDetail {
+	problem := NewProblemDetail(
+		ErrorTypeCouponExpired,
+		"Coupon Expired",
+		"Coupon expired on "+expiresAt.Format("02.01.2006")+" and can no longer be used",
+		http.StatusGone,
+	)
+	return problem.WithExtension("expires_at", expiresAt.UTC().Format(time.RFC3339))
+}
+
+// CouponNotYetValidError is returned when coupon is used before start of its validity window
+func CouponNotYetValidError(validFrom time.Time) *ProblemDetail {
+	problem := NewProblemDetail(
+		ErrorTypeCouponNotValid,
+		"Coupon Not Yet Valid",
+		"Coupon can be used starting from "+validFrom.Format("02.01.2006"),
+		http.StatusForbidden,
+	)
+	return problem.WithExtension("valid_from", validFrom.UTC().Format(time.RFC3339))
+}
+
+// CouponBlockedError is returned when coupon or its whole batch was blocked by administrator
+func CouponBlockedError() *ProblemDetail {
+	return NewProblemDetail(
+		ErrorTypeCouponBlocked,
+		"Coupon Blocked",
+		"Coupon is blocked and can no longer be used",
+		http.StatusForbidden,
+	)
+}
+
 // ValidationErrorWithFields creates validation error with field details
 func ValidationErrorWithFields(fields []ValidationFieldError) *ProblemDetail {
 	problem := ValidationError("One or more validation errors occurred")
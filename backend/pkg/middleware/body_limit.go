@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"io"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// BodyLimit rejects requests whose body is larger than limit. Server streams bodies above its own
+// BodyLimit instead of buffering them in memory, this middleware keeps the limit for routes that read
+// whole body. Routes accepting large uploads are skipped by global BodyLimit and set their own.
+func BodyLimit(limit int, skip func(c *fiber.Ctx) bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if skip != nil && skip(c) {
+			return c.Next()
+		}
+
+		req := c.Request()
+		length := req.Header.ContentLength()
+		if length > limit {
+			// Rest of streamed body is not read, connection can not be reused
+			c.Context().SetConnectionClose()
+			return fiber.ErrRequestEntityTooLarge
+		}
+
+		// Chunked body has no length in advance, it is read here up to limit
+		if length == -1 && req.IsBodyStream() {
+			body, err := io.ReadAll(io.LimitReader(req.BodyStream(), int64(limit)+1))
+			if err != nil {
+				return fiber.ErrBadRequest
+			}
+			if len(body) > limit {
+				c.Context().SetConnectionClose()
+				return fiber.ErrRequestEntityTooLarge
+			}
+			req.SetBody(body)
+		}
+
+		return c.Next()
+	}
+}
@@ -136,7 +136,7 @@ func (l *Logger) CombinedMiddleware() fiber.Handler {
 			Str("ip", c.IP()).
 			Str("user_agent", c.Get("User-Agent")).
 			Str("referer", c.Get("Referer")).
-			Int("content_length", len(c.Body())).
+			Int("content_length", c.Request().Header.ContentLength()).
 			Msg("Request started")
 
 		err := c.Next()
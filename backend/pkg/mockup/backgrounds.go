@@ -0,0 +1,82 @@
+package mockup
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+// drawWall draws plain painted wall with baseboard and wooden floor at the bottom
+func drawWall(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	floorTop := int(float64(height) * 0.88)
+	baseboardTop := int(float64(height) * 0.85)
+
+	for y := 0; y < height; y++ {
+		t := float64(y) / float64(height)
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			switch {
+			case y >= floorTop:
+				c = wood(x, y-floorTop, height-floorTop, 8)
+			case y >= baseboardTop:
+				c = gradient(color.NRGBA{R: 246, G: 244, B: 240, A: 255}, color.NRGBA{R: 220, G: 216, B: 210, A: 255},
+					float64(y-baseboardTop)/float64(floorTop-baseboardTop))
+			default:
+				c = gradient(color.NRGBA{R: 238, G: 232, B: 222, A: 255}, color.NRGBA{R: 221, G: 212, B: 199, A: 255}, t)
+			}
+			img.SetNRGBA(x, y, vignette(c, x, y, width, height))
+		}
+	}
+	return img
+}
+
+// drawTable draws wooden tabletop going to horizon in front of wall
+func drawTable(width, height int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, width, height))
+	horizon := int(float64(height) * 0.45)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var c color.NRGBA
+			if y < horizon {
+				c = gradient(color.NRGBA{R: 226, G: 222, B: 214, A: 255}, color.NRGBA{R: 204, G: 198, B: 188, A: 255},
+					float64(y)/float64(horizon))
+			} else {
+				// Boards narrow towards horizon, so grain is compressed by depth
+				depth := float64(y-horizon) / float64(height-horizon)
+				c = wood(int(float64(x-width/2)/(0.35+depth)), int(math.Sqrt(depth)*float64(height)), height, 5)
+				c = shadeNRGBA(c, int(-30*(1-depth)))
+			}
+			img.SetNRGBA(x, y, vignette(c, x, y, width, height))
+		}
+	}
+	return img
+}
+
+// wood draws boards running across with slightly wavy grain
+func wood(x, y, height, boards int) color.NRGBA {
+	board := max(1, height/boards)
+	index := y / board
+	grain := math.Sin(float64(y)*0.9+math.Sin(float64(x)*0.01+float64(index))*3) * 8
+	tone := float64((index*37)%5) * 6
+	c := color.NRGBA{R: 150, G: 108, B: 72, A: 255}
+	c = shadeNRGBA(c, int(grain+tone))
+	if y%board == 0 {
+		c = shadeNRGBA(c, -40)
+	}
+	return c
+}
+
+func gradient(from, to color.NRGBA, t float64) color.NRGBA {
+	mix := func(a, b uint8) uint8 {
+		return uint8(math.Round(float64(a)*(1-t) + float64(b)*t))
+	}
+	return color.NRGBA{R: mix(from.R, to.R), G: mix(from.G, to.G), B: mix(from.B, to.B), A: 255}
+}
+
+func vignette(c color.NRGBA, x, y, width, height int) color.NRGBA {
+	dx := float64(x)/float64(width) - 0.5
+	dy := float64(y)/float64(height) - 0.5
+	return shadeNRGBA(c, -int(60*(dx*dx+dy*dy)))
+}
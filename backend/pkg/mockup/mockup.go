@@ -0,0 +1,411 @@
+package mockup
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// ScenesFile is manifest of custom scenes in scenes directory
+	ScenesFile = "scenes.json"
+
+	DefaultFramePercent = 4.0 // Width of frame in percent of longer side of artwork
+	builtinWidth        = 1600
+	builtinHeight       = 1200
+	shadowOpacity       = 0.45
+)
+
+var defaultFrameColor = color.NRGBA{R: 46, G: 34, B: 26, A: 255}
+
+// ErrUnknownScene is returned when selected scene is neither built-in nor listed in scenes directory
+var ErrUnknownScene = errors.New("unknown mockup scene")
+
+// Point is position on scene background in fractions of its sides
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// Scene is template background with place where framed artwork is put
+type Scene struct {
+	Name         string   `json:"name"`
+	Title        string   `json:"title"`
+	Background   string   `json:"background"`              // Image file relative to scenes directory
+	Corners      [4]Point `json:"corners"`                 // Top-left, top-right, bottom-right and bottom-left corners of area artwork is fitted into
+	Aspect       float64  `json:"aspect,omitempty"`        // Real width to height ratio of area, taken from corners when zero
+	FrameColor   string   `json:"frame_color,omitempty"`   // Frame color in #RRGGBB notation, dark wood when empty
+	FramePercent float64  `json:"frame_percent,omitempty"` // Frame width in percent of longer side of artwork, DefaultFramePercent when zero
+	Shadow       Point    `json:"shadow"`                  // Offset of shadow under frame in fractions of background sides, no shadow when zero
+
+	dir  string
+	draw func(width, height int) *image.NRGBA
+}
+
+// BuiltinScenes returns scenes with backgrounds drawn in code, they need no template files
+func BuiltinScenes() []Scene {
+	return []Scene{
+		{
+			Name:    "wall",
+			Title:   "Картина на стене",
+			Corners: [4]Point{{0.31, 0.10}, {0.69, 0.13}, {0.69, 0.77}, {0.31, 0.80}},
+			Aspect:  0.78,
+			Shadow:  Point{0.008, 0.012},
+			draw:    drawWall,
+		},
+		{
+			Name:    "table",
+			Title:   "Картина на столе",
+			Corners: [4]Point{{0.30, 0.50}, {0.70, 0.50}, {0.84, 0.94}, {0.16, 0.94}},
+			Aspect:  0.8,
+			Shadow:  Point{0.004, 0.006},
+			draw:    drawTable,
+		},
+	}
+}
+
+// LoadScenes reads custom scenes from ScenesFile in dir, missing manifest means no custom scenes
+func LoadScenes(dir string) ([]Scene, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ScenesFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read mockup scenes: %w", err)
+	}
+
+	var scenes []Scene
+	if err := json.Unmarshal(data, &scenes); err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", ScenesFile, err)
+	}
+
+	for i := range scenes {
+		scene := &scenes[i]
+		if scene.Name == "" || scene.Background == "" {
+			return nil, fmt.Errorf("mockup scene %d must have name and background", i+1)
+		}
+		if _, err := parseHexColor(scene.FrameColor); err != nil {
+			return nil, fmt.Errorf("mockup scene %s: %w", scene.Name, err)
+		}
+		if _, err := os.Stat(filepath.Join(dir, scene.Background)); err != nil {
+			return nil, fmt.Errorf("mockup scene %s: background not found: %w", scene.Name, err)
+		}
+		scene.dir = dir
+	}
+	return scenes, nil
+}
+
+// Select returns scenes by names in given order, all scenes when names are empty.
+// Custom scenes from dir replace built-in scenes of the same name.
+func Select(names []string, dir string) ([]Scene, error) {
+	available := BuiltinScenes()
+	if dir != "" {
+		custom, err := LoadScenes(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, scene := range custom {
+			replaced := false
+			for i := range available {
+				if available[i].Name == scene.Name {
+					available[i], replaced = scene, true
+				}
+			}
+			if !replaced {
+				available = append(available, scene)
+			}
+		}
+	}
+
+	if len(names) == 0 {
+		return available, nil
+	}
+
+	selected := make([]Scene, 0, len(names))
+	for _, name := range names {
+		found := false
+		for _, scene := range available {
+			if scene.Name == name {
+				selected, found = append(selected, scene), true
+				break
+			}
+		}
+		if !found {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownScene, name)
+		}
+	}
+	return selected, nil
+}
+
+// Render frames artwork and places it onto scene background with perspective of scene area
+func Render(artwork image.Image, scene Scene) (*image.NRGBA, error) {
+	background, err := scene.background()
+	if err != nil {
+		return nil, err
+	}
+	frameColor, err := parseHexColor(scene.FrameColor)
+	if err != nil {
+		return nil, err
+	}
+
+	width, height := background.Bounds().Dx(), background.Bounds().Dy()
+	var quad [4]Point
+	for i, corner := range scene.Corners {
+		quad[i] = Point{corner.X * float64(width), corner.Y * float64(height)}
+	}
+	area := squareToQuad(quad)
+
+	framed := addFrame(artwork, frameColor, scene.FramePercent)
+
+	// Artwork is fitted into area keeping its proportions, in coordinates of area from 0 to 1
+	aspect := scene.Aspect
+	if aspect <= 0 {
+		aspect = quadAspect(quad)
+	}
+	fw, fh := float64(framed.Bounds().Dx()), float64(framed.Bounds().Dy())
+	pw, ph := 1.0, 1.0
+	if ratio := (fw / fh) / aspect; ratio < 1 {
+		pw = ratio
+	} else {
+		ph = 1 / ratio
+	}
+	px, py := (1-pw)/2, (1-ph)/2
+	placed := [4]Point{
+		area.apply(px, py), area.apply(px+pw, py),
+		area.apply(px+pw, py+ph), area.apply(px, py+ph),
+	}
+	toArtwork, ok := squareToQuad(placed).inverse()
+	if !ok {
+		return nil, fmt.Errorf("mockup scene %s has degenerate corners", scene.Name)
+	}
+
+	// Artwork is reduced close to its size on scene first, so warping does not alias drills into noise
+	longest := 0.0
+	for i := range placed {
+		next := placed[(i+1)%4]
+		longest = max(longest, math.Hypot(next.X-placed[i].X, next.Y-placed[i].Y))
+	}
+	if target := int(math.Ceil(longest)); target > 0 && target < max(framed.Bounds().Dx(), framed.Bounds().Dy()) {
+		framed = imaging.Fit(framed, target, target, imaging.Lanczos)
+	}
+
+	bounds := quadBounds(placed, width, height)
+	result := imaging.Clone(background)
+
+	if scene.Shadow != (Point{}) {
+		drawShadow(result, toArtwork, bounds, int(scene.Shadow.X*float64(width)), int(scene.Shadow.Y*float64(height)))
+	}
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			u, v := toArtwork.applyPoint(float64(x)+0.5, float64(y)+0.5)
+			if u < 0 || v < 0 || u > 1 || v > 1 {
+				continue
+			}
+			result.SetNRGBA(x, y, bilinear(framed, u, v))
+		}
+	}
+
+	return result, nil
+}
+
+func (s Scene) background() (*image.NRGBA, error) {
+	if s.draw != nil {
+		return s.draw(builtinWidth, builtinHeight), nil
+	}
+	img, err := imaging.Open(filepath.Join(s.dir, s.Background), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open background of mockup scene %s: %w", s.Name, err)
+	}
+	return imaging.Clone(img), nil
+}
+
+// addFrame surrounds artwork with frame that has lit outer and shaded inner edge
+func addFrame(artwork image.Image, frameColor color.NRGBA, percent float64) *image.NRGBA {
+	if percent <= 0 {
+		percent = DefaultFramePercent
+	}
+	width, height := artwork.Bounds().Dx(), artwork.Bounds().Dy()
+	border := max(2, int(float64(max(width, height))*percent/100))
+	bevel := max(1, border/6)
+
+	framed := imaging.New(width+2*border, height+2*border, frameColor)
+	fw, fh := framed.Bounds().Dx(), framed.Bounds().Dy()
+	light, dark := shadeNRGBA(frameColor, 40), shadeNRGBA(frameColor, -30)
+	for i := 0; i < bevel; i++ {
+		for x := i; x < fw-i; x++ {
+			framed.SetNRGBA(x, i, light)
+			framed.SetNRGBA(x, fh-1-i, dark)
+		}
+		for y := i; y < fh-i; y++ {
+			framed.SetNRGBA(i, y, light)
+			framed.SetNRGBA(fw-1-i, y, dark)
+		}
+	}
+	inner := shadeNRGBA(frameColor, -50)
+	for i := 1; i <= bevel; i++ {
+		for x := border - i; x < border+width+i; x++ {
+			framed.SetNRGBA(x, border-i, inner)
+			framed.SetNRGBA(x, border+height+i-1, inner)
+		}
+		for y := border - i; y < border+height+i; y++ {
+			framed.SetNRGBA(border-i, y, inner)
+			framed.SetNRGBA(border+width+i-1, y, inner)
+		}
+	}
+
+	return imaging.Paste(framed, artwork, image.Pt(border, border))
+}
+
+// drawShadow darkens background under frame moved by offset, shadow edges are blurred
+func drawShadow(dst *image.NRGBA, toArtwork homography, bounds image.Rectangle, dx, dy int) {
+	blur := max(2, (bounds.Dx()+bounds.Dy())/120)
+	area := bounds.Inset(-3 * blur).Add(image.Pt(dx, dy)).Intersect(dst.Bounds())
+	if area.Empty() {
+		return
+	}
+
+	mask := image.NewGray(image.Rect(0, 0, area.Dx(), area.Dy()))
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			u, v := toArtwork.applyPoint(float64(x-dx)+0.5, float64(y-dy)+0.5)
+			if u >= 0 && v >= 0 && u <= 1 && v <= 1 {
+				mask.SetGray(x-area.Min.X, y-area.Min.Y, color.Gray{Y: 255})
+			}
+		}
+	}
+	soft := imaging.Blur(mask, float64(blur))
+
+	for y := area.Min.Y; y < area.Max.Y; y++ {
+		for x := area.Min.X; x < area.Max.X; x++ {
+			alpha := float64(soft.NRGBAAt(x-area.Min.X, y-area.Min.Y).R) / 255 * shadowOpacity
+			if alpha == 0 {
+				continue
+			}
+			c := dst.NRGBAAt(x, y)
+			dst.SetNRGBA(x, y, color.NRGBA{
+				R: uint8(float64(c.R) * (1 - alpha)),
+				G: uint8(float64(c.G) * (1 - alpha)),
+				B: uint8(float64(c.B) * (1 - alpha)),
+				A: c.A,
+			})
+		}
+	}
+}
+
+func bilinear(img *image.NRGBA, u, v float64) color.NRGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	fx := min(max(u*float64(w)-0.5, 0), float64(w-1))
+	fy := min(max(v*float64(h)-0.5, 0), float64(h-1))
+	x0, y0 := int(fx), int(fy)
+	x1, y1 := min(x0+1, w-1), min(y0+1, h-1)
+	tx, ty := fx-float64(x0), fy-float64(y0)
+
+	c00, c10 := img.NRGBAAt(x0, y0), img.NRGBAAt(x1, y0)
+	c01, c11 := img.NRGBAAt(x0, y1), img.NRGBAAt(x1, y1)
+	mix := func(a, b, c, d uint8) uint8 {
+		top := float64(a)*(1-tx) + float64(b)*tx
+		bottom := float64(c)*(1-tx) + float64(d)*tx
+		return uint8(math.Round(top*(1-ty) + bottom*ty))
+	}
+	return color.NRGBA{
+		R: mix(c00.R, c10.R, c01.R, c11.R),
+		G: mix(c00.G, c10.G, c01.G, c11.G),
+		B: mix(c00.B, c10.B, c01.B, c11.B),
+		A: 255,
+	}
+}
+
+// homography maps unit square onto quadrilateral
+type homography struct {
+	a, b, c, d, e, f, g, h, i float64
+}
+
+// squareToQuad finds projective mapping of corners (0,0), (1,0), (1,1), (0,1) onto quad
+func squareToQuad(q [4]Point) homography {
+	dx1, dx2, dx3 := q[1].X-q[2].X, q[3].X-q[2].X, q[0].X-q[1].X+q[2].X-q[3].X
+	dy1, dy2, dy3 := q[1].Y-q[2].Y, q[3].Y-q[2].Y, q[0].Y-q[1].Y+q[2].Y-q[3].Y
+
+	var g, h float64
+	if det := dx1*dy2 - dx2*dy1; det != 0 && (dx3 != 0 || dy3 != 0) {
+		g = (dx3*dy2 - dx2*dy3) / det
+		h = (dx1*dy3 - dx3*dy1) / det
+	}
+
+	return homography{
+		a: q[1].X - q[0].X + g*q[1].X, b: q[3].X - q[0].X + h*q[3].X, c: q[0].X,
+		d: q[1].Y - q[0].Y + g*q[1].Y, e: q[3].Y - q[0].Y + h*q[3].Y, f: q[0].Y,
+		g: g, h: h, i: 1,
+	}
+}
+
+func (m homography) apply(u, v float64) Point {
+	x, y := m.applyPoint(u, v)
+	return Point{x, y}
+}
+
+func (m homography) applyPoint(u, v float64) (float64, float64) {
+	w := m.g*u + m.h*v + m.i
+	return (m.a*u + m.b*v + m.c) / w, (m.d*u + m.e*v + m.f) / w
+}
+
+func (m homography) inverse() (homography, bool) {
+	det := m.a*(m.e*m.i-m.f*m.h) - m.b*(m.d*m.i-m.f*m.g) + m.c*(m.d*m.h-m.e*m.g)
+	if math.Abs(det) < 1e-12 {
+		return homography{}, false
+	}
+	return homography{
+		a: (m.e*m.i - m.f*m.h) / det, b: (m.c*m.h - m.b*m.i) / det, c: (m.b*m.f - m.c*m.e) / det,
+		d: (m.f*m.g - m.d*m.i) / det, e: (m.a*m.i - m.c*m.g) / det, f: (m.c*m.d - m.a*m.f) / det,
+		g: (m.d*m.h - m.e*m.g) / det, h: (m.b*m.g - m.a*m.h) / det, i: (m.a*m.e - m.b*m.d) / det,
+	}, true
+}
+
+// quadAspect estimates width to height ratio of quad from average lengths of its opposite sides
+func quadAspect(q [4]Point) float64 {
+	side := func(a, b Point) float64 { return math.Hypot(b.X-a.X, b.Y-a.Y) }
+	width := (side(q[0], q[1]) + side(q[3], q[2])) / 2
+	height := (side(q[0], q[3]) + side(q[1], q[2])) / 2
+	if height == 0 {
+		return 1
+	}
+	return width / height
+}
+
+func quadBounds(q [4]Point, width, height int) image.Rectangle {
+	minX, minY := q[0].X, q[0].Y
+	maxX, maxY := q[0].X, q[0].Y
+	for _, p := range q[1:] {
+		minX, minY = min(minX, p.X), min(minY, p.Y)
+		maxX, maxY = max(maxX, p.X), max(maxY, p.Y)
+	}
+	return image.Rect(int(math.Floor(minX)), int(math.Floor(minY)), int(math.Ceil(maxX)), int(math.Ceil(maxY))).
+		Intersect(image.Rect(0, 0, width, height))
+}
+
+func parseHexColor(hex string) (color.NRGBA, error) {
+	if hex == "" {
+		return defaultFrameColor, nil
+	}
+	value, err := strconv.ParseUint(strings.TrimPrefix(hex, "#"), 16, 32)
+	if err != nil || len(strings.TrimPrefix(hex, "#")) != 6 {
+		return color.NRGBA{}, fmt.Errorf("invalid frame color %q, expected #RRGGBB", hex)
+	}
+	return color.NRGBA{R: uint8(value >> 16), G: uint8(value >> 8), B: uint8(value), A: 255}, nil
+}
+
+func shadeNRGBA(c color.NRGBA, delta int) color.NRGBA {
+	channel := func(v uint8) uint8 {
+		return uint8(min(255, max(0, int(v)+delta)))
+	}
+	return color.NRGBA{R: channel(c.R), G: channel(c.G), B: channel(c.B), A: c.A}
+}
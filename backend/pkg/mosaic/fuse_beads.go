@@ -0,0 +1,302 @@
+package mosaic
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	"github.com/skr1ms/mosaic/pkg/palette"
+)
+
+const (
+	defaultBeadMaxColors = 30
+	defaultPegboardSize  = 29   // Standard large square pegboard, 29x29 pegs
+	beadsPerBag          = 1000 // Beads in a standard refill bag
+	beadTileCellPx       = 28   // Cell size on printed tile sheets, fits three-character color code
+	beadTileHeaderPx     = 40
+)
+
+var (
+	beadBackgroundColor = color.RGBA{R: 235, G: 235, B: 235, A: 255}
+	beadGridColor       = color.RGBA{R: 200, G: 200, B: 200, A: 255}
+	beadTileBorderColor = color.RGBA{R: 20, G: 20, B: 20, A: 255}
+)
+
+// beadLegendEntry describes bead usage for the legend
+type beadLegendEntry struct {
+	Color palette.BeadColor
+	Count int
+	Bags  int
+}
+
+// generateFuseBeads converts image to pixel-art bead layout split into pegboard tiles and writes
+// preview, overview scheme with tile indices, per-tile sheets and bead legend into outputDir
+func (mg *MosaicGenerator) generateFuseBeads(ctx context.Context, req *GenerationRequest, outputDir string) error {
+	if len(req.BeadColors) == 0 {
+		return fmt.Errorf("bead palette is empty")
+	}
+	if req.StonesX <= 0 || req.StonesY <= 0 {
+		return fmt.Errorf("invalid bead grid: %dx%d", req.StonesX, req.StonesY)
+	}
+
+	src, err := imaging.Open(req.ImagePath, imaging.AutoOrientation(true))
+	if err != nil {
+		return fmt.Errorf("failed to open source image: %w", err)
+	}
+
+	width, height := req.StonesX, req.StonesY
+	canvas := imaging.Fill(src, width, height, imaging.Center, imaging.Box)
+
+	maxColors := req.MaxColors
+	if maxColors <= 0 {
+		maxColors = defaultBeadMaxColors
+	}
+	tileSize := req.TileSize
+	if tileSize <= 0 {
+		tileSize = defaultPegboardSize
+	}
+
+	candidates := make([]color.RGBA, len(req.BeadColors))
+	for i, bc := range req.BeadColors {
+		candidates[i] = bc.RGBA()
+	}
+	selected := paletteColors(canvas, candidates, maxColors, req.FixedPalette)
+	colors := make([]palette.BeadColor, len(selected))
+	rgba := make([]color.RGBA, len(selected))
+	for i, idx := range selected {
+		colors[i] = req.BeadColors[idx]
+		rgba[i] = candidates[idx]
+	}
+	grid := quantizeToColors(canvas, rgba)
+	if err := checkCancelled(ctx); err != nil {
+		return err
+	}
+
+	tilesX := (width + tileSize - 1) / tileSize
+	tilesY := (height + tileSize - 1) / tileSize
+
+	mg.logger.GetZerologLogger().Info().
+		Int("beads_x", width).
+		Int("beads_y", height).
+		Int("colors", len(colors)).
+		Int("tiles_x", tilesX).
+		Int("tiles_y", tilesY).
+		Msg("Fuse bead layout completed")
+
+	pitchMM := req.StoneSizeMM
+	if pitchMM <= 0 {
+		pitchMM = palette.BeadPitchMM
+	}
+
+	previewScale := pixelsPerCell(req.PreviewDPI, pitchMM, 8)
+	if err := savePNG(filepath.Join(outputDir, "beads_preview.png"), renderBeadPreview(grid, rgba, width, height, previewScale)); err != nil {
+		return err
+	}
+
+	if err := checkCancelled(ctx); err != nil {
+		return err
+	}
+
+	schemeScale := pixelsPerCell(req.SchemeDPI, pitchMM, 12)
+	if err := savePNG(filepath.Join(outputDir, "beads_scheme.png"), renderBeadOverview(grid, rgba, width, height, tileSize, schemeScale)); err != nil {
+		return err
+	}
+
+	for ty := 0; ty < tilesY; ty++ {
+		for tx := 0; tx < tilesX; tx++ {
+			if err := checkCancelled(ctx); err != nil {
+				return err
+			}
+			tile := renderBeadTile(grid, colors, width, height, tileSize, tx, ty)
+			name := fmt.Sprintf("tile_%02d_%02d.png", ty+1, tx+1)
+			if err := savePNG(filepath.Join(outputDir, name), tile); err != nil {
+				return err
+			}
+		}
+	}
+
+	if req.WithLegend {
+		legend := buildBeadLegend(grid, colors)
+		if err := writeBeadLegendCSV(filepath.Join(outputDir, "beads_legend.csv"), legend); err != nil {
+			return err
+		}
+		if err := savePNG(filepath.Join(outputDir, "beads_legend.png"), renderBeadLegend(legend)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// tileLabel returns human-readable pegboard index, row first
+func tileLabel(tx, ty int) string {
+	return fmt.Sprintf("%d-%d", ty+1, tx+1)
+}
+
+func renderBeadPreview(grid []int, colors []color.RGBA, width, height, scale int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width*scale, height*scale))
+	draw.Draw(img, img.Bounds(), image.NewUniform(beadBackgroundColor), image.Point{}, draw.Src)
+
+	radius := float64(scale) / 2
+	hole := radius * 0.35
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := colors[grid[y*width+x]]
+			for py := 0; py < scale; py++ {
+				for px := 0; px < scale; px++ {
+					d := math.Hypot(float64(px)+0.5-radius, float64(py)+0.5-radius)
+					if d <= radius && d > hole {
+						img.SetRGBA(x*scale+px, y*scale+py, c)
+					}
+				}
+			}
+		}
+	}
+	return img
+}
+
+func renderBeadOverview(grid []int, colors []color.RGBA, width, height, tileSize, scale int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width*scale, height*scale))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			rect := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale)
+			draw.Draw(img, rect, image.NewUniform(colors[grid[y*width+x]]), image.Point{}, draw.Src)
+			for p := 0; p < scale; p++ {
+				img.SetRGBA(x*scale+p, y*scale, beadGridColor)
+				img.SetRGBA(x*scale, y*scale+p, beadGridColor)
+			}
+		}
+	}
+
+	for ty := 0; ty*tileSize < height; ty++ {
+		for tx := 0; tx*tileSize < width; tx++ {
+			x0, y0 := tx*tileSize*scale, ty*tileSize*scale
+			x1 := min((tx+1)*tileSize, width) * scale
+			y1 := min((ty+1)*tileSize, height) * scale
+			for _, r := range []image.Rectangle{
+				image.Rect(x0, y0, x1, y0+2),
+				image.Rect(x0, y1-2, x1, y1),
+				image.Rect(x0, y0, x0+2, y1),
+				image.Rect(x1-2, y0, x1, y1),
+			} {
+				draw.Draw(img, r, image.NewUniform(beadTileBorderColor), image.Point{}, draw.Src)
+			}
+
+			// Narrow edge tiles get smaller label so it does not overflow into the margin
+			label := tileLabel(tx, ty)
+			labelScale := 2
+			labelW, labelH := measureText(label, labelScale)
+			if x0+labelW+12 > x1 {
+				labelScale = 1
+				labelW, labelH = measureText(label, labelScale)
+			}
+			box := image.Rect(x0+4, y0+4, x0+labelW+12, y0+labelH+8)
+			draw.Draw(img, box, image.White, image.Point{}, draw.Src)
+			drawText(img, label, x0+8, y0+6, beadTileBorderColor, labelScale)
+		}
+	}
+
+	return img
+}
+
+// renderBeadTile renders printable sheet of a single pegboard with color code in every peg
+func renderBeadTile(grid []int, colors []palette.BeadColor, width, height, tileSize, tx, ty int) *image.RGBA {
+	startX, startY := tx*tileSize, ty*tileSize
+	endX, endY := min(startX+tileSize, width), min(startY+tileSize, height)
+	cols, rows := endX-startX, endY-startY
+
+	img := image.NewRGBA(image.Rect(0, 0, cols*beadTileCellPx+1, rows*beadTileCellPx+beadTileHeaderPx+1))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	header := fmt.Sprintf("Pegboard %s  rows %d-%d  columns %d-%d", tileLabel(tx, ty), startY+1, endY, startX+1, endX)
+	drawText(img, header, 4, 8, color.Black, 2)
+
+	for y := startY; y < endY; y++ {
+		for x := startX; x < endX; x++ {
+			bead := colors[grid[y*width+x]]
+			cx := (x - startX) * beadTileCellPx
+			cy := (y-startY)*beadTileCellPx + beadTileHeaderPx
+			draw.Draw(img, image.Rect(cx, cy, cx+beadTileCellPx, cy+beadTileCellPx), image.NewUniform(beadGridColor), image.Point{}, draw.Src)
+			draw.Draw(img, image.Rect(cx+1, cy+1, cx+beadTileCellPx, cy+beadTileCellPx), image.NewUniform(bead.RGBA()), image.Point{}, draw.Src)
+
+			textW, textH := measureText(bead.Code, 1)
+			drawText(img, bead.Code, cx+(beadTileCellPx-textW)/2+1, cy+(beadTileCellPx-textH)/2+1, contrastTextColor(bead.RGBA()), 1)
+		}
+	}
+
+	return img
+}
+
+// buildBeadLegend counts beads per color ordered by usage
+func buildBeadLegend(grid []int, colors []palette.BeadColor) []beadLegendEntry {
+	counts := make([]int, len(colors))
+	for _, colorIdx := range grid {
+		counts[colorIdx]++
+	}
+
+	legend := make([]beadLegendEntry, 0, len(colors))
+	for i, c := range colors {
+		if counts[i] == 0 {
+			continue
+		}
+		legend = append(legend, beadLegendEntry{
+			Color: c,
+			Count: counts[i],
+			Bags:  (counts[i] + beadsPerBag - 1) / beadsPerBag,
+		})
+	}
+	sort.SliceStable(legend, func(a, b int) bool { return legend[a].Count > legend[b].Count })
+
+	return legend
+}
+
+func renderBeadLegend(legend []beadLegendEntry) *image.RGBA {
+	rows := make([]legendRow, 0, len(legend))
+	for _, entry := range legend {
+		rows = append(rows, legendRow{
+			Color: entry.Color.RGBA(),
+			Text:  fmt.Sprintf("%-5s %-18s %7d %5d", entry.Color.Code, entry.Color.Name, entry.Count, entry.Bags),
+		})
+	}
+	return renderLegendTable("Code  Color                Beads  Bags", rows)
+}
+
+// writeBeadLegendCSV writes legend with bead count in third column, same as diamond legend
+func writeBeadLegendCSV(path string, legend []beadLegendEntry) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create legend file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Comma = ';'
+
+	if err := writer.Write([]string{"Code", "Name", "Count", "Hex", "Bags"}); err != nil {
+		return fmt.Errorf("failed to write legend header: %w", err)
+	}
+	for _, entry := range legend {
+		record := []string{
+			entry.Color.Code,
+			entry.Color.Name,
+			strconv.Itoa(entry.Count),
+			entry.Color.Hex(),
+			strconv.Itoa(entry.Bags),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write legend row: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
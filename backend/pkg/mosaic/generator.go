@@ -9,11 +9,21 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/skr1ms/mosaic/pkg/middleware"
+	"github.com/skr1ms/mosaic/pkg/palette"
+)
+
+// Product types supported by the generator
+const (
+	ProductDiamondMosaic  = "diamond_mosaic"
+	ProductPaintByNumbers = "paint_by_numbers"
+	ProductFuseBeads      = "fuse_beads"
 )
 
 type MosaicGenerator struct {
@@ -36,14 +46,31 @@ type GenerationRequest struct {
 	WithLegend  bool
 	Threads     int
 	PalettePath string
+	Size        string // Coupon size label, used only in metrics
+
+	// Product type, empty value means diamond mosaic
+	ProductType string
+	// Paint-by-numbers options
+	PaintColors   []palette.PaintColor
+	MaxColors     int
+	MinRegionSize int
+	// Fuse bead options, StonesX/StonesY are measured in beads
+	BeadColors []palette.BeadColor
+	TileSize   int
+	// Diamond options, drill colors of palette file. Script is limited to them only when palette is fixed
+	DiamondColors []palette.DiamondColor
+	// FixedPalette disables color selection, PaintColors/BeadColors/DiamondColors are used as is in given order
+	FixedPalette bool
 }
 
 type GenerationResult struct {
-	PreviewPath string
-	SchemePath  string
-	LegendPath  string
-	ZipPath     string
-	SchemaUUID  string
+	PreviewPath     string
+	SchemePath      string
+	LegendPath      string
+	LegendImagePath string
+	TilePaths       []string
+	ZipPath         string
+	SchemaUUID      string
 }
 
 func NewMosaicGenerator(scriptPath, outputDir, pythonCommand string, logger *middleware.Logger) *MosaicGenerator {
@@ -55,8 +82,28 @@ func NewMosaicGenerator(scriptPath, outputDir, pythonCommand string, logger *mid
 	}
 }
 
-func (mg *MosaicGenerator) Generate(ctx context.Context, req *GenerationRequest) (*GenerationResult, error) {
+// checkCancelled returns error when generation was cancelled, Go generators check it between stages
+func checkCancelled(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("mosaic generation cancelled: %w", context.Cause(ctx))
+	}
+	return nil
+}
+
+// Generate creates mosaic files in temporary directory. When ctx is cancelled generation stops,
+// Python subprocess is killed and temporary directory is removed.
+func (mg *MosaicGenerator) Generate(ctx context.Context, req *GenerationRequest) (result *GenerationResult, err error) {
+	if err := checkCancelled(ctx); err != nil {
+		return nil, err
+	}
+
+	started := time.Now()
+	defer func() {
+		observeGeneration(ctx, req, started, err)
+	}()
+
 	mg.logger.GetZerologLogger().Info().
+		Str("product_type", req.ProductType).
 		Str("mode", req.Mode).
 		Str("style", req.Style).
 		Int("stones_x", req.StonesX).
@@ -76,48 +123,36 @@ func (mg *MosaicGenerator) Generate(ctx context.Context, req *GenerationRequest)
 		mg.logger.GetZerologLogger().Error().Err(err).Str("output_dir", mg.OutputDir).Msg("Failed to create output directory")
 		return nil, fmt.Errorf("failed to create output directory: %w", err)
 	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(outputDir)
+		}
+	}()
 
 	schemaUUID := uuid.New().String()
 
-	if _, err := os.Stat(mg.ScriptPath); os.IsNotExist(err) {
-		mg.logger.GetZerologLogger().Error().Str("script_path", mg.ScriptPath).Msg("Python script not found")
-		return nil, fmt.Errorf("python script not found at path: %s", mg.ScriptPath)
-	}
-
-	if req.PalettePath != "" {
-		if _, err := os.Stat(req.PalettePath); os.IsNotExist(err) {
-			mg.logger.GetZerologLogger().Error().Str("palette_path", req.PalettePath).Msg("Palette file not found")
-			return nil, fmt.Errorf("palette file not found at path: %s", req.PalettePath)
+	switch req.ProductType {
+	case ProductPaintByNumbers:
+		if err := mg.generatePaintByNumbers(ctx, req, outputDir); err != nil {
+			mg.logger.GetZerologLogger().Error().Err(err).Msg("Paint-by-numbers generation failed")
+			return nil, fmt.Errorf("paint-by-numbers generation failed: %w", err)
+		}
+	case ProductFuseBeads:
+		if err := mg.generateFuseBeads(ctx, req, outputDir); err != nil {
+			mg.logger.GetZerologLogger().Error().Err(err).Msg("Fuse beads generation failed")
+			return nil, fmt.Errorf("fuse beads generation failed: %w", err)
+		}
+	default:
+		if err := mg.runPythonScript(ctx, req, outputDir); err != nil {
+			return nil, err
 		}
 	}
 
-	args := mg.buildPythonArgs(req)
-
-	cmd := exec.CommandContext(ctx, mg.PythonCommand, args...)
-	cmd.Dir = outputDir
-
-	var stdoutBuf, stderrBuf bytes.Buffer
-	cmd.Stdout = &stdoutBuf
-	cmd.Stderr = &stderrBuf
-
-	done := make(chan error, 1)
-	go func() {
-		done <- cmd.Run()
-	}()
-
-	select {
-	case err := <-done:
-		if err != nil {
-			mg.logger.GetZerologLogger().Error().Err(err).Str("stderr", stderrBuf.String()).Msg("Python script execution failed")
-			return nil, fmt.Errorf("python script execution failed: %w: %s", err, stderrBuf.String())
-		}
-	case <-time.After(10 * time.Minute):
-		cmd.Process.Kill()
-		mg.logger.GetZerologLogger().Error().Msg("Mosaic generation timed out")
-		return nil, fmt.Errorf("mosaic generation timed out")
+	if err := checkCancelled(ctx); err != nil {
+		return nil, err
 	}
 
-	result := &GenerationResult{
+	result = &GenerationResult{
 		SchemaUUID: schemaUUID,
 	}
 
@@ -136,11 +171,22 @@ func (mg *MosaicGenerator) Generate(ctx context.Context, req *GenerationRequest)
 		result.LegendPath = matches[0]
 	}
 
+	legendImagePattern := filepath.Join(outputDir, "*legend*.png")
+	if matches, err := filepath.Glob(legendImagePattern); err == nil && len(matches) > 0 {
+		result.LegendImagePath = matches[0]
+	}
+
+	tilePattern := filepath.Join(outputDir, "tile_*.png")
+	if matches, err := filepath.Glob(tilePattern); err == nil && len(matches) > 0 {
+		sort.Strings(matches)
+		result.TilePaths = matches
+	}
+
 	zipPath, err := mg.createZipArchive(result, outputDir, schemaUUID)
 	if err != nil {
 		mg.logger.GetZerologLogger().Error().
 			Err(err).
-			Str("stderr", stderrBuf.String()).
+			Str("output_dir", outputDir).
 			Msg("Failed to create ZIP archive")
 		return nil, fmt.Errorf("failed to create zip archive: %w", err)
 	} else {
@@ -150,6 +196,53 @@ func (mg *MosaicGenerator) Generate(ctx context.Context, req *GenerationRequest)
 	return result, nil
 }
 
+// runPythonScript generates diamond mosaic files in outputDir using Python script
+func (mg *MosaicGenerator) runPythonScript(ctx context.Context, req *GenerationRequest, outputDir string) error {
+	if _, err := os.Stat(mg.ScriptPath); os.IsNotExist(err) {
+		mg.logger.GetZerologLogger().Error().Str("script_path", mg.ScriptPath).Msg("Python script not found")
+		return fmt.Errorf("python script not found at path: %s", mg.ScriptPath)
+	}
+
+	if req.PalettePath != "" {
+		if _, err := os.Stat(req.PalettePath); os.IsNotExist(err) {
+			mg.logger.GetZerologLogger().Error().Str("palette_path", req.PalettePath).Msg("Palette file not found")
+			return fmt.Errorf("palette file not found at path: %s", req.PalettePath)
+		}
+	}
+
+	args := mg.buildPythonArgs(req)
+
+	cmd := exec.CommandContext(ctx, mg.PythonCommand, args...)
+	cmd.Dir = outputDir
+
+	var stdoutBuf, stderrBuf bytes.Buffer
+	cmd.Stdout = &stdoutBuf
+	cmd.Stderr = &stderrBuf
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Run()
+	}()
+
+	select {
+	case err := <-done:
+		if ctx.Err() != nil {
+			mg.logger.GetZerologLogger().Info().Msg("Mosaic generation cancelled, Python script killed")
+			return fmt.Errorf("mosaic generation cancelled: %w", context.Cause(ctx))
+		}
+		if err != nil {
+			mg.logger.GetZerologLogger().Error().Err(err).Str("stderr", stderrBuf.String()).Msg("Python script execution failed")
+			return fmt.Errorf("python script execution failed: %w: %s", err, stderrBuf.String())
+		}
+	case <-time.After(10 * time.Minute):
+		cmd.Process.Kill()
+		mg.logger.GetZerologLogger().Error().Msg("Mosaic generation timed out")
+		return fmt.Errorf("mosaic generation timed out")
+	}
+
+	return nil
+}
+
 func (mg *MosaicGenerator) buildPythonArgs(req *GenerationRequest) []string {
 	args := []string{mg.ScriptPath}
 
@@ -202,6 +295,15 @@ func (mg *MosaicGenerator) buildPythonArgs(req *GenerationRequest) []string {
 		args = append(args, "--threads", strconv.Itoa(req.Threads))
 	}
 
+	// Script uses only listed palette codes and numbers them in given order
+	if req.FixedPalette && len(req.DiamondColors) > 0 {
+		codes := make([]string, len(req.DiamondColors))
+		for i, c := range req.DiamondColors {
+			codes[i] = c.Code
+		}
+		args = append(args, "--colors", strings.Join(codes, ","))
+	}
+
 	return args
 }
 
@@ -222,6 +324,10 @@ func (mg *MosaicGenerator) createZipArchive(result *GenerationResult, outputDir
 		"preview.png": result.PreviewPath,
 		"scheme.png":  result.SchemePath,
 		"legend.csv":  result.LegendPath,
+		"legend.png":  result.LegendImagePath,
+	}
+	for _, tilePath := range result.TilePaths {
+		filesToZip["tiles/"+filepath.Base(tilePath)] = tilePath
 	}
 
 	for archiveName, filePath := range filesToZip {
@@ -267,8 +373,10 @@ func (mg *MosaicGenerator) Cleanup(result *GenerationResult) error {
 		result.PreviewPath,
 		result.SchemePath,
 		result.LegendPath,
+		result.LegendImagePath,
 		result.ZipPath,
 	}
+	filesToClean = append(filesToClean, result.TilePaths...)
 
 	for _, filePath := range filesToClean {
 		if filePath != "" {
@@ -0,0 +1,45 @@
+package mosaic
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// GenerationDuration is time of schema generation, for diamond mosaic it is mostly Python subprocess run time
+var GenerationDuration = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "mosaic_generator_duration_seconds",
+		Help:    "Duration of mosaic schema generation",
+		Buckets: []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300, 600},
+	},
+	[]string{"product_type", "size", "style", "result"},
+)
+
+// observeGeneration records duration of finished generation
+func observeGeneration(ctx context.Context, req *GenerationRequest, started time.Time, err error) {
+	productType := req.ProductType
+	if productType == "" {
+		productType = ProductDiamondMosaic
+	}
+	size := req.Size
+	if size == "" {
+		size = "unknown"
+	}
+	style := req.Style
+	if style == "" {
+		style = "none"
+	}
+
+	result := "success"
+	switch {
+	case err != nil && ctx.Err() != nil:
+		result = "cancelled"
+	case err != nil:
+		result = "failed"
+	}
+
+	GenerationDuration.WithLabelValues(productType, size, style, result).Observe(time.Since(started).Seconds())
+}
@@ -0,0 +1,495 @@
+package mosaic
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	"github.com/skr1ms/mosaic/pkg/palette"
+)
+
+const (
+	defaultPaintMaxColors     = 24
+	defaultPaintMinRegionSize = 30    // Regions smaller than this number of cells are merged into neighbours
+	paintCoverageMLPerCM2     = 0.012 // Acrylic volume for two coats per square centimeter
+	paintReserveFactor        = 1.3   // Reserve for brush losses and drying
+	paintSmoothingPasses      = 2
+	paintMergePasses          = 8
+)
+
+// standardPotSizesML are paint pot volumes available for kits
+var standardPotSizesML = []float64{3, 5, 10, 20, 50}
+
+var (
+	paintOutlineColor = color.RGBA{R: 150, G: 150, B: 150, A: 255}
+	paintNumberColor  = color.RGBA{R: 90, G: 90, B: 90, A: 255}
+)
+
+// paintRegion is a connected area of cells painted with a single color
+type paintRegion struct {
+	colorIdx int
+	size     int
+	labelX   int
+	labelY   int
+	labelD   int
+}
+
+// paintLegendEntry describes paint usage for the legend
+type paintLegendEntry struct {
+	Number   int
+	Color    palette.PaintColor
+	Cells    int
+	Regions  int
+	AreaCM2  float64
+	VolumeML float64
+	PotML    float64
+}
+
+// generatePaintByNumbers segments image into regions of limited paint palette and writes
+// preview, numbered outline scheme and paint legend into outputDir
+func (mg *MosaicGenerator) generatePaintByNumbers(ctx context.Context, req *GenerationRequest, outputDir string) error {
+	if len(req.PaintColors) == 0 {
+		return fmt.Errorf("paint palette is empty")
+	}
+	if req.StonesX <= 0 || req.StonesY <= 0 {
+		return fmt.Errorf("invalid canvas grid: %dx%d", req.StonesX, req.StonesY)
+	}
+
+	src, err := imaging.Open(req.ImagePath, imaging.AutoOrientation(true))
+	if err != nil {
+		return fmt.Errorf("failed to open source image: %w", err)
+	}
+
+	width, height := req.StonesX, req.StonesY
+	canvas := imaging.Fill(src, width, height, imaging.Center, imaging.Lanczos)
+	canvas = imaging.Blur(canvas, 1.0)
+
+	maxColors := req.MaxColors
+	if maxColors <= 0 {
+		maxColors = defaultPaintMaxColors
+	}
+	minRegionSize := req.MinRegionSize
+	if minRegionSize <= 0 {
+		minRegionSize = defaultPaintMinRegionSize
+	}
+
+	candidates := make([]color.RGBA, len(req.PaintColors))
+	for i, pc := range req.PaintColors {
+		candidates[i] = pc.RGBA()
+	}
+	selected := paletteColors(canvas, candidates, maxColors, req.FixedPalette)
+	colors := make([]palette.PaintColor, len(selected))
+	rgba := make([]color.RGBA, len(selected))
+	for i, idx := range selected {
+		colors[i] = req.PaintColors[idx]
+		rgba[i] = candidates[idx]
+	}
+	grid := quantizeToColors(canvas, rgba)
+	for i := 0; i < paintSmoothingPasses; i++ {
+		smoothGrid(grid, width, height, len(colors))
+	}
+	if err := checkCancelled(ctx); err != nil {
+		return err
+	}
+
+	mergeSmallRegions(grid, width, height, minRegionSize)
+	if err := checkCancelled(ctx); err != nil {
+		return err
+	}
+
+	labels, regions := labelRegions(grid, width, height)
+	placeRegionLabels(grid, labels, regions, width, height)
+
+	cellMM := req.StoneSizeMM
+	if cellMM <= 0 {
+		cellMM = 1
+	}
+	legend, numbers := buildPaintLegend(grid, regions, colors, cellMM, req.FixedPalette)
+
+	mg.logger.GetZerologLogger().Info().
+		Int("width", width).
+		Int("height", height).
+		Int("colors", len(legend)).
+		Int("regions", len(regions)).
+		Msg("Paint-by-numbers segmentation completed")
+
+	if err := checkCancelled(ctx); err != nil {
+		return err
+	}
+
+	previewScale := pixelsPerCell(req.PreviewDPI, cellMM, 2)
+	if err := savePNG(filepath.Join(outputDir, "paint_preview.png"), renderPaintPreview(grid, colors, width, height, previewScale)); err != nil {
+		return err
+	}
+	if err := checkCancelled(ctx); err != nil {
+		return err
+	}
+
+	schemeScale := pixelsPerCell(req.SchemeDPI, cellMM, 4)
+	if err := savePNG(filepath.Join(outputDir, "paint_scheme.png"), renderPaintScheme(grid, regions, numbers, width, height, schemeScale)); err != nil {
+		return err
+	}
+
+	if req.WithLegend {
+		if err := writePaintLegendCSV(filepath.Join(outputDir, "paint_legend.csv"), legend); err != nil {
+			return err
+		}
+		if err := savePNG(filepath.Join(outputDir, "paint_legend.png"), renderPaintLegend(legend)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// smoothGrid applies 3x3 majority filter to remove single-cell noise
+func smoothGrid(grid []int, width, height, colorsCount int) {
+	source := make([]int, len(grid))
+	copy(source, grid)
+	counts := make([]int, colorsCount)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			for i := range counts {
+				counts[i] = 0
+			}
+			for dy := -1; dy <= 1; dy++ {
+				for dx := -1; dx <= 1; dx++ {
+					nx, ny := x+dx, y+dy
+					if nx < 0 || ny < 0 || nx >= width || ny >= height {
+						continue
+					}
+					counts[source[ny*width+nx]]++
+				}
+			}
+			current := source[y*width+x]
+			best := current
+			for i, count := range counts {
+				if count > counts[best] {
+					best = i
+				}
+			}
+			grid[y*width+x] = best
+		}
+	}
+}
+
+// labelRegions finds 4-connected regions of equal colors
+func labelRegions(grid []int, width, height int) ([]int, []paintRegion) {
+	labels := make([]int, len(grid))
+	for i := range labels {
+		labels[i] = -1
+	}
+
+	var regions []paintRegion
+	queue := make([]int, 0, 1024)
+	for start := range grid {
+		if labels[start] != -1 {
+			continue
+		}
+		regionID := len(regions)
+		region := paintRegion{colorIdx: grid[start]}
+		labels[start] = regionID
+		queue = append(queue[:0], start)
+
+		for len(queue) > 0 {
+			cell := queue[len(queue)-1]
+			queue = queue[:len(queue)-1]
+			region.size++
+
+			x, y := cell%width, cell/width
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[1] < 0 || n[0] >= width || n[1] >= height {
+					continue
+				}
+				idx := n[1]*width + n[0]
+				if labels[idx] == -1 && grid[idx] == region.colorIdx {
+					labels[idx] = regionID
+					queue = append(queue, idx)
+				}
+			}
+		}
+		regions = append(regions, region)
+	}
+
+	return labels, regions
+}
+
+// mergeSmallRegions repaints regions smaller than minSize with the color of their dominant neighbour
+func mergeSmallRegions(grid []int, width, height, minSize int) {
+	for pass := 0; pass < paintMergePasses; pass++ {
+		labels, regions := labelRegions(grid, width, height)
+
+		borders := make([]map[int]int, len(regions))
+		for cell, regionID := range labels {
+			if regions[regionID].size >= minSize {
+				continue
+			}
+			x, y := cell%width, cell/width
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[1] < 0 || n[0] >= width || n[1] >= height {
+					continue
+				}
+				neighbour := grid[n[1]*width+n[0]]
+				if neighbour == regions[regionID].colorIdx {
+					continue
+				}
+				if borders[regionID] == nil {
+					borders[regionID] = make(map[int]int)
+				}
+				borders[regionID][neighbour]++
+			}
+		}
+
+		replacement := make([]int, len(regions))
+		changed := false
+		for regionID, region := range regions {
+			replacement[regionID] = region.colorIdx
+			if region.size >= minSize || len(borders[regionID]) == 0 {
+				continue
+			}
+			best, bestCount := region.colorIdx, -1
+			for colorIdx, count := range borders[regionID] {
+				if count > bestCount || (count == bestCount && colorIdx < best) {
+					best, bestCount = colorIdx, count
+				}
+			}
+			replacement[regionID] = best
+			changed = true
+		}
+
+		if !changed {
+			return
+		}
+		for cell, regionID := range labels {
+			grid[cell] = replacement[regionID]
+		}
+	}
+}
+
+// placeRegionLabels finds for every region the cell farthest from its border to place number there
+func placeRegionLabels(grid, labels []int, regions []paintRegion, width, height int) {
+	dist := make([]int, len(grid))
+	inf := width + height
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			idx := y*width + x
+			onBorder := x == 0 || y == 0 || x == width-1 || y == height-1 ||
+				grid[idx-1] != grid[idx] || grid[idx+1] != grid[idx] ||
+				grid[idx-width] != grid[idx] || grid[idx+width] != grid[idx]
+			if onBorder {
+				dist[idx] = 0
+			} else {
+				dist[idx] = inf
+			}
+		}
+	}
+
+	// Two-pass chessboard distance transform
+	for y := 1; y < height; y++ {
+		for x := 1; x < width; x++ {
+			idx := y*width + x
+			dist[idx] = min(dist[idx], dist[idx-1]+1, dist[idx-width]+1, dist[idx-width-1]+1)
+			if x < width-1 {
+				dist[idx] = min(dist[idx], dist[idx-width+1]+1)
+			}
+		}
+	}
+	for y := height - 2; y >= 0; y-- {
+		for x := width - 2; x >= 0; x-- {
+			idx := y*width + x
+			dist[idx] = min(dist[idx], dist[idx+1]+1, dist[idx+width]+1, dist[idx+width+1]+1)
+			if x > 0 {
+				dist[idx] = min(dist[idx], dist[idx+width-1]+1)
+			}
+		}
+	}
+
+	for i := range regions {
+		regions[i].labelD = -1
+	}
+	for cell, regionID := range labels {
+		if dist[cell] > regions[regionID].labelD {
+			regions[regionID].labelD = dist[cell]
+			regions[regionID].labelX = cell % width
+			regions[regionID].labelY = cell / width
+		}
+	}
+}
+
+// buildPaintLegend computes paint usage and assigns legend numbers ordered by used area
+func buildPaintLegend(grid []int, regions []paintRegion, colors []palette.PaintColor, cellMM float64, fixedNumbers bool) ([]paintLegendEntry, map[int]int) {
+	cells := make([]int, len(colors))
+	for _, colorIdx := range grid {
+		cells[colorIdx]++
+	}
+	regionCounts := make([]int, len(colors))
+	for _, region := range regions {
+		regionCounts[region.colorIdx]++
+	}
+
+	order := make([]int, 0, len(colors))
+	for i := range colors {
+		if cells[i] > 0 {
+			order = append(order, i)
+		}
+	}
+	if !fixedNumbers {
+		sort.SliceStable(order, func(a, b int) bool { return cells[order[a]] > cells[order[b]] })
+	}
+
+	cellAreaCM2 := (cellMM / 10) * (cellMM / 10)
+	numbers := make(map[int]int, len(order))
+	legend := make([]paintLegendEntry, 0, len(order))
+	for i, colorIdx := range order {
+		area := float64(cells[colorIdx]) * cellAreaCM2
+		volume := math.Ceil(area*paintCoverageMLPerCM2*paintReserveFactor*10) / 10
+		number := i + 1
+		if fixedNumbers {
+			number = colorIdx + 1
+		}
+		numbers[colorIdx] = number
+		legend = append(legend, paintLegendEntry{
+			Number:   number,
+			Color:    colors[colorIdx],
+			Cells:    cells[colorIdx],
+			Regions:  regionCounts[colorIdx],
+			AreaCM2:  math.Round(area*10) / 10,
+			VolumeML: volume,
+			PotML:    potSizeFor(volume),
+		})
+	}
+
+	return legend, numbers
+}
+
+// potSizeFor returns smallest standard pot holding given volume
+func potSizeFor(volumeML float64) float64 {
+	for _, size := range standardPotSizesML {
+		if volumeML <= size {
+			return size
+		}
+	}
+	return standardPotSizesML[len(standardPotSizesML)-1]
+}
+
+func renderPaintPreview(grid []int, colors []palette.PaintColor, width, height, scale int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width*scale, height*scale))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			rect := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale)
+			draw.Draw(img, rect, image.NewUniform(colors[grid[y*width+x]].RGBA()), image.Point{}, draw.Src)
+		}
+	}
+	return img
+}
+
+func renderPaintScheme(grid []int, regions []paintRegion, numbers map[int]int, width, height, scale int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width*scale, height*scale))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			idx := y*width + x
+			if x < width-1 && grid[idx] != grid[idx+1] {
+				for py := y * scale; py < (y+1)*scale; py++ {
+					img.SetRGBA((x+1)*scale-1, py, paintOutlineColor)
+				}
+			}
+			if y < height-1 && grid[idx] != grid[idx+width] {
+				for px := x * scale; px < (x+1)*scale; px++ {
+					img.SetRGBA(px, (y+1)*scale-1, paintOutlineColor)
+				}
+			}
+		}
+	}
+
+	for _, region := range regions {
+		text := strconv.Itoa(numbers[region.colorIdx])
+		textW, textH := measureText(text, 1)
+		cx := region.labelX*scale + scale/2
+		cy := region.labelY*scale + scale/2
+		drawText(img, text, cx-textW/2, cy-textH/2, paintNumberColor, 1)
+	}
+
+	return img
+}
+
+func renderPaintLegend(legend []paintLegendEntry) *image.RGBA {
+	rows := make([]legendRow, 0, len(legend))
+	for _, entry := range legend {
+		rows = append(rows, legendRow{
+			Color: entry.Color.RGBA(),
+			Text:  fmt.Sprintf("%-3d %-5s %-18s %9.1f %9.1f %7.0f", entry.Number, entry.Color.Code, entry.Color.Name, entry.AreaCM2, entry.VolumeML, entry.PotML),
+		})
+	}
+	return renderLegendTable("No  Code  Color              Area,cm2  Paint,ml  Pot,ml", rows)
+}
+
+func writePaintLegendCSV(path string, legend []paintLegendEntry) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create legend file: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Comma = ';'
+
+	if err := writer.Write([]string{"Number", "Code", "Name", "Hex", "Regions", "Area_cm2", "Volume_ml", "Pot_ml"}); err != nil {
+		return fmt.Errorf("failed to write legend header: %w", err)
+	}
+	for _, entry := range legend {
+		record := []string{
+			strconv.Itoa(entry.Number),
+			entry.Color.Code,
+			entry.Color.Name,
+			entry.Color.Hex(),
+			strconv.Itoa(entry.Regions),
+			strconv.FormatFloat(entry.AreaCM2, 'f', 1, 64),
+			strconv.FormatFloat(entry.VolumeML, 'f', 1, 64),
+			strconv.FormatFloat(entry.PotML, 'f', 0, 64),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write legend row: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// pixelsPerCell converts DPI and cell size to integer pixel scale
+func pixelsPerCell(dpi int, cellMM float64, fallback int) int {
+	if dpi <= 0 {
+		return fallback
+	}
+	scale := int(math.Round(float64(dpi) / 25.4 * cellMM))
+	if scale < 2 {
+		return 2
+	}
+	return scale
+}
+
+func savePNG(path string, img image.Image) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", filepath.Base(path), err)
+	}
+	defer file.Close()
+
+	if err := png.Encode(file, img); err != nil {
+		return fmt.Errorf("failed to encode %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
@@ -0,0 +1,348 @@
+package mosaic
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/disintegration/imaging"
+	"github.com/skr1ms/mosaic/pkg/palette"
+)
+
+// PanelLayout describes split of one image into several equal canvases hung side by side
+type PanelLayout struct {
+	Count         int // Number of canvases, 2-4
+	PanelWidthMM  int // Width of a single canvas
+	PanelHeightMM int // Height of a single canvas
+	GapMM         int // Wall gap between neighbouring canvases, image part behind the gap is dropped
+}
+
+// PanelGenerationResult contains per-panel schemas and legend combined for the whole set
+type PanelGenerationResult struct {
+	Panels             []*GenerationResult
+	CombinedLegendPath string
+	SchemaUUID         string
+	WorkDir            string // Holds all panel files, caller removes it once files are read
+}
+
+// legendMergeSpec describes how legend CSV of product type is combined across panels
+type legendMergeSpec struct {
+	keyColumn    int   // Column identifying color
+	numberColumn int   // Column with shared color number used for ordering, -1 orders by first sum column
+	sumColumns   []int // Columns summed across panels
+	recompute    func(record []string)
+}
+
+var legendMergeSpecs = map[string]legendMergeSpec{
+	ProductDiamondMosaic: {keyColumn: 0, numberColumn: -1, sumColumns: []int{2}},
+	ProductPaintByNumbers: {keyColumn: 1, numberColumn: 0, sumColumns: []int{4, 5, 6}, recompute: func(record []string) {
+		if len(record) < 8 {
+			return
+		}
+		volume, _ := strconv.ParseFloat(record[6], 64)
+		record[7] = strconv.FormatFloat(potSizeFor(volume), 'f', 0, 64)
+	}},
+	ProductFuseBeads: {keyColumn: 0, numberColumn: -1, sumColumns: []int{2}, recompute: func(record []string) {
+		if len(record) < 5 {
+			return
+		}
+		count, _ := strconv.Atoi(record[2])
+		record[4] = strconv.Itoa((count + beadsPerBag - 1) / beadsPerBag)
+	}},
+}
+
+// GeneratePanels splits source image into panels and generates schema for each of them.
+// All panels share one palette selected on the whole image
+func (mg *MosaicGenerator) GeneratePanels(ctx context.Context, req *GenerationRequest, layout PanelLayout) (result *PanelGenerationResult, err error) {
+	if layout.Count < 2 {
+		return nil, fmt.Errorf("panel layout requires at least 2 panels, got %d", layout.Count)
+	}
+	if layout.PanelWidthMM <= 0 || layout.PanelHeightMM <= 0 || layout.GapMM < 0 {
+		return nil, fmt.Errorf("invalid panel geometry: %dx%d mm, gap %d mm", layout.PanelWidthMM, layout.PanelHeightMM, layout.GapMM)
+	}
+
+	mg.logger.GetZerologLogger().Info().
+		Str("product_type", req.ProductType).
+		Int("panels", layout.Count).
+		Int("panel_width_mm", layout.PanelWidthMM).
+		Int("panel_height_mm", layout.PanelHeightMM).
+		Int("gap_mm", layout.GapMM).
+		Msg("Starting multi-panel generation")
+
+	if mg.OutputDir != "" {
+		if err := os.MkdirAll(mg.OutputDir, 0755); err != nil {
+			return nil, fmt.Errorf("failed to ensure output base directory: %w", err)
+		}
+	}
+	workDir, err := os.MkdirTemp(mg.OutputDir, "panels_*")
+	if err != nil {
+		mg.logger.GetZerologLogger().Error().Err(err).Str("output_dir", mg.OutputDir).Msg("Failed to create panels directory")
+		return nil, fmt.Errorf("failed to create panels directory: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			os.RemoveAll(workDir)
+		}
+	}()
+
+	// Panel outputs are created inside work directory, so the whole set is removed at once
+	panelGenerator := *mg
+	panelGenerator.OutputDir = workDir
+
+	src, err := imaging.Open(req.ImagePath, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open source image: %w", err)
+	}
+	composite, panelImages := splitPanels(src, layout)
+
+	if err := checkCancelled(ctx); err != nil {
+		return nil, err
+	}
+
+	shared := *req
+	if err := mg.selectSharedPalette(&shared, composite, layout); err != nil {
+		return nil, err
+	}
+
+	result = &PanelGenerationResult{WorkDir: workDir}
+	var legendPaths []string
+	for i, panelImage := range panelImages {
+		if err := checkCancelled(ctx); err != nil {
+			return nil, err
+		}
+
+		panelPath := filepath.Join(workDir, fmt.Sprintf("panel_%d.png", i+1))
+		if err := imaging.Save(panelImage, panelPath); err != nil {
+			return nil, fmt.Errorf("failed to save panel %d: %w", i+1, err)
+		}
+
+		panelReq := shared
+		panelReq.ImagePath = panelPath
+		panelResult, err := panelGenerator.Generate(ctx, &panelReq)
+		if err != nil {
+			mg.logger.GetZerologLogger().Error().Err(err).Int("panel", i+1).Msg("Panel generation failed")
+			return nil, fmt.Errorf("failed to generate panel %d: %w", i+1, err)
+		}
+
+		result.Panels = append(result.Panels, panelResult)
+		if panelResult.LegendPath != "" {
+			legendPaths = append(legendPaths, panelResult.LegendPath)
+		}
+	}
+	result.SchemaUUID = result.Panels[0].SchemaUUID
+
+	if len(legendPaths) > 0 {
+		spec, ok := legendMergeSpecs[req.ProductType]
+		if !ok {
+			spec = legendMergeSpecs[ProductDiamondMosaic]
+		}
+		combinedPath := filepath.Join(workDir, "combined_legend.csv")
+		if err := mergeLegendCSV(legendPaths, combinedPath, spec); err != nil {
+			mg.logger.GetZerologLogger().Error().Err(err).Msg("Failed to combine panel legends")
+			return nil, fmt.Errorf("failed to combine panel legends: %w", err)
+		}
+		result.CombinedLegendPath = combinedPath
+	}
+
+	mg.logger.GetZerologLogger().Info().
+		Int("panels", len(result.Panels)).
+		Str("combined_legend", result.CombinedLegendPath).
+		Msg("Multi-panel generation completed")
+
+	return result, nil
+}
+
+// splitPanels crops image to aspect ratio of the whole set including gaps and cuts it into panels
+func splitPanels(src image.Image, layout PanelLayout) (*image.NRGBA, []*image.NRGBA) {
+	totalWidthMM := layout.Count*layout.PanelWidthMM + (layout.Count-1)*layout.GapMM
+
+	// Keep source resolution: pixels per millimeter are limited by the tighter side
+	bounds := src.Bounds()
+	pxPerMM := math.Min(float64(bounds.Dx())/float64(totalWidthMM), float64(bounds.Dy())/float64(layout.PanelHeightMM))
+
+	panelWidth := max(int(math.Round(float64(layout.PanelWidthMM)*pxPerMM)), 1)
+	height := max(int(math.Round(float64(layout.PanelHeightMM)*pxPerMM)), 1)
+	gap := int(math.Round(float64(layout.GapMM) * pxPerMM))
+	width := layout.Count*panelWidth + (layout.Count-1)*gap
+
+	composite := imaging.Fill(src, width, height, imaging.Center, imaging.Lanczos)
+
+	panels := make([]*image.NRGBA, 0, layout.Count)
+	for i := 0; i < layout.Count; i++ {
+		x := i * (panelWidth + gap)
+		panels = append(panels, imaging.Crop(composite, image.Rect(x, 0, x+panelWidth, height)))
+	}
+	return composite, panels
+}
+
+// selectSharedPalette narrows palette to colors selected on the whole set, so that every panel uses
+// the same colors with the same numbers. Diamond colors are passed to script as fixed list of codes.
+func (mg *MosaicGenerator) selectSharedPalette(req *GenerationRequest, composite image.Image, layout PanelLayout) error {
+	if req.StonesX <= 0 || req.StonesY <= 0 {
+		return fmt.Errorf("invalid panel grid: %dx%d", req.StonesX, req.StonesY)
+	}
+
+	// Sample composite at the same cell density as panels
+	gridWidth := req.StonesX*layout.Count + req.StonesX*layout.GapMM*(layout.Count-1)/layout.PanelWidthMM
+	sample := imaging.Resize(composite, gridWidth, req.StonesY, imaging.Box)
+
+	switch req.ProductType {
+	case ProductPaintByNumbers:
+		if len(req.PaintColors) == 0 {
+			return fmt.Errorf("paint palette is empty")
+		}
+		maxColors := req.MaxColors
+		if maxColors <= 0 {
+			maxColors = defaultPaintMaxColors
+		}
+		candidates := make([]color.RGBA, len(req.PaintColors))
+		for i, pc := range req.PaintColors {
+			candidates[i] = pc.RGBA()
+		}
+		selected := selectColors(imaging.Blur(sample, 1.0), candidates, maxColors)
+		colors := make([]palette.PaintColor, len(selected))
+		for i, idx := range selected {
+			colors[i] = req.PaintColors[idx]
+		}
+		req.PaintColors = colors
+	case ProductFuseBeads:
+		if len(req.BeadColors) == 0 {
+			return fmt.Errorf("bead palette is empty")
+		}
+		maxColors := req.MaxColors
+		if maxColors <= 0 {
+			maxColors = defaultBeadMaxColors
+		}
+		candidates := make([]color.RGBA, len(req.BeadColors))
+		for i, bc := range req.BeadColors {
+			candidates[i] = bc.RGBA()
+		}
+		selected := selectColors(sample, candidates, maxColors)
+		colors := make([]palette.BeadColor, len(selected))
+		for i, idx := range selected {
+			colors[i] = req.BeadColors[idx]
+		}
+		req.BeadColors = colors
+	default:
+		if len(req.DiamondColors) == 0 {
+			return fmt.Errorf("diamond palette is empty")
+		}
+		maxColors := req.MaxColors
+		if maxColors <= 0 {
+			maxColors = len(req.DiamondColors)
+		}
+		candidates := make([]color.RGBA, len(req.DiamondColors))
+		for i, dc := range req.DiamondColors {
+			candidates[i] = dc.RGBA()
+		}
+		selected := selectColors(sample, candidates, maxColors)
+		colors := make([]palette.DiamondColor, len(selected))
+		for i, idx := range selected {
+			colors[i] = req.DiamondColors[idx]
+		}
+		req.DiamondColors = colors
+	}
+	req.FixedPalette = true
+
+	return nil
+}
+
+// mergeLegendCSV combines semicolon separated panel legends into one legend of the whole set
+func mergeLegendCSV(paths []string, outputPath string, spec legendMergeSpec) error {
+	var header []string
+	rows := make(map[string][]string)
+	sums := make(map[string][]float64)
+	var keys []string
+
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open legend %s: %w", path, err)
+		}
+		reader := csv.NewReader(file)
+		reader.Comma = ';'
+		reader.FieldsPerRecord = -1
+		records, err := reader.ReadAll()
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read legend %s: %w", path, err)
+		}
+
+		for i, record := range records {
+			if i == 0 {
+				if header == nil {
+					header = record
+				}
+				continue
+			}
+			if len(record) <= spec.keyColumn {
+				continue
+			}
+
+			key := record[spec.keyColumn]
+			if _, ok := rows[key]; !ok {
+				rows[key] = append([]string(nil), record...)
+				sums[key] = make([]float64, len(spec.sumColumns))
+				keys = append(keys, key)
+			}
+			for j, column := range spec.sumColumns {
+				if column >= len(record) {
+					continue
+				}
+				if value, err := strconv.ParseFloat(record[column], 64); err == nil {
+					sums[key][j] += value
+				}
+			}
+		}
+	}
+
+	for _, key := range keys {
+		record := rows[key]
+		for j, column := range spec.sumColumns {
+			if column < len(record) {
+				record[column] = strconv.FormatFloat(math.Round(sums[key][j]*10)/10, 'f', -1, 64)
+			}
+		}
+		if spec.recompute != nil {
+			spec.recompute(record)
+		}
+	}
+
+	sort.SliceStable(keys, func(a, b int) bool {
+		if spec.numberColumn >= 0 {
+			na, _ := strconv.Atoi(rows[keys[a]][spec.numberColumn])
+			nb, _ := strconv.Atoi(rows[keys[b]][spec.numberColumn])
+			return na < nb
+		}
+		return sums[keys[a]][0] > sums[keys[b]][0]
+	})
+
+	file, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create combined legend: %w", err)
+	}
+	defer file.Close()
+
+	writer := csv.NewWriter(file)
+	writer.Comma = ';'
+	if header != nil {
+		if err := writer.Write(header); err != nil {
+			return fmt.Errorf("failed to write legend header: %w", err)
+		}
+	}
+	for _, key := range keys {
+		if err := writer.Write(rows[key]); err != nil {
+			return fmt.Errorf("failed to write legend row: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,77 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+)
+
+// selectColors returns indexes of up to maxColors candidates most used by the image, most used first
+func selectColors(img image.Image, candidates []color.RGBA, maxColors int) []int {
+	usage := make([]int, len(candidates))
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			usage[nearestColor(img.At(x, y), candidates)]++
+		}
+	}
+
+	order := make([]int, len(candidates))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(a, b int) bool { return usage[order[a]] > usage[order[b]] })
+
+	selected := make([]int, 0, maxColors)
+	for _, idx := range order {
+		if len(selected) == maxColors || usage[idx] == 0 {
+			break
+		}
+		selected = append(selected, idx)
+	}
+	return selected
+}
+
+// paletteColors returns indexes of candidates used for the scheme. Fixed palette is used as is in given order,
+// so panels of one multi-panel set get identical color numbering
+func paletteColors(img image.Image, candidates []color.RGBA, maxColors int, fixed bool) []int {
+	if !fixed {
+		return selectColors(img, candidates, maxColors)
+	}
+	indexes := make([]int, len(candidates))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	return indexes
+}
+
+// quantizeToColors maps every pixel to index of nearest color
+func quantizeToColors(img image.Image, colors []color.RGBA) []int {
+	bounds := img.Bounds()
+	grid := make([]int, bounds.Dx()*bounds.Dy())
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			grid[y*bounds.Dx()+x] = nearestColor(img.At(bounds.Min.X+x, bounds.Min.Y+y), colors)
+		}
+	}
+	return grid
+}
+
+func nearestColor(c color.Color, colors []color.RGBA) int {
+	r, g, b, _ := c.RGBA()
+	best, bestDist := 0, math.MaxFloat64
+	for i, pc := range colors {
+		if d := colorDistance(float64(r>>8), float64(g>>8), float64(b>>8), float64(pc.R), float64(pc.G), float64(pc.B)); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// colorDistance is weighted RGB distance approximating human perception
+func colorDistance(r1, g1, b1, r2, g2, b2 float64) float64 {
+	rMean := (r1 + r2) / 2
+	dr, dg, db := r1-r2, g1-g2, b1-b2
+	return (2+rMean/256)*dr*dr + 4*dg*dg + (2+(255-rMean)/256)*db*db
+}
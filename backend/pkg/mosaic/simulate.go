@@ -0,0 +1,137 @@
+package mosaic
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// DefaultSimulationSide is longest side of simulated preview in pixels. Large grids are rendered
+	// with at least minSimulationCellPx per stone, so their previews may be bigger
+	DefaultSimulationSide = 1200
+	minSimulationCellPx   = 2
+	drillGapColorShift    = -60 // Shade of gaps between square drills
+	drillFacetColorShift  = 45  // Shade of lit facet of square drill
+)
+
+// SimulationRequest describes fast preview of finished kit
+type SimulationRequest struct {
+	ProductType string       // Empty value means diamond mosaic
+	StonesX     int          // Stones, cells or beads across
+	StonesY     int          // Stones, cells or beads down
+	Colors      []color.RGBA // Palette of product and style
+	MaxColors   int          // Colors picked from palette, defaults of product are used when zero
+	Side        int          // Longest side of preview, DefaultSimulationSide when zero
+}
+
+// Simulate quantizes image to palette at stone grid of kit and renders stones the way finished kit
+// looks: square drills for diamond mosaic, flat cells for paint-by-numbers and fused rings for beads.
+// It skips region merging and legends of full generation, so it takes milliseconds.
+func Simulate(img image.Image, req SimulationRequest) (*image.RGBA, error) {
+	if len(req.Colors) == 0 {
+		return nil, fmt.Errorf("palette is empty")
+	}
+	if req.StonesX <= 0 || req.StonesY <= 0 {
+		return nil, fmt.Errorf("invalid stone grid: %dx%d", req.StonesX, req.StonesY)
+	}
+
+	width, height := req.StonesX, req.StonesY
+	side := req.Side
+	if side <= 0 {
+		side = DefaultSimulationSide
+	}
+	scale := max(minSimulationCellPx, side/max(width, height))
+
+	maxColors := req.MaxColors
+	var canvas *image.NRGBA
+	switch req.ProductType {
+	case ProductPaintByNumbers:
+		canvas = imaging.Blur(imaging.Fill(img, width, height, imaging.Center, imaging.Lanczos), 1.0)
+		if maxColors <= 0 {
+			maxColors = defaultPaintMaxColors
+		}
+	case ProductFuseBeads:
+		canvas = imaging.Fill(img, width, height, imaging.Center, imaging.Box)
+		if maxColors <= 0 {
+			maxColors = defaultBeadMaxColors
+		}
+	default:
+		canvas = imaging.Fill(img, width, height, imaging.Center, imaging.Lanczos)
+		if maxColors <= 0 {
+			maxColors = len(req.Colors)
+		}
+	}
+
+	selected := selectColors(canvas, req.Colors, maxColors)
+	colors := make([]color.RGBA, len(selected))
+	for i, idx := range selected {
+		colors[i] = req.Colors[idx]
+	}
+	grid := quantizeToColors(canvas, colors)
+
+	switch req.ProductType {
+	case ProductPaintByNumbers:
+		for i := 0; i < paintSmoothingPasses; i++ {
+			smoothGrid(grid, width, height, len(colors))
+		}
+		return renderFlatCells(grid, colors, width, height, scale), nil
+	case ProductFuseBeads:
+		return renderBeadPreview(grid, colors, width, height, scale), nil
+	default:
+		return renderDrills(grid, colors, width, height, scale), nil
+	}
+}
+
+func renderFlatCells(grid []int, colors []color.RGBA, width, height, scale int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width*scale, height*scale))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			rect := image.Rect(x*scale, y*scale, (x+1)*scale, (y+1)*scale)
+			draw.Draw(img, rect, image.NewUniform(colors[grid[y*width+x]]), image.Point{}, draw.Src)
+		}
+	}
+	return img
+}
+
+// renderDrills draws square drills with darker gap on bottom and right edges and lit top-left facet.
+// Cells smaller than 4 pixels have no room for facets and are drawn flat with gaps only.
+func renderDrills(grid []int, colors []color.RGBA, width, height, scale int) *image.RGBA {
+	img := renderFlatCells(grid, colors, width, height, scale)
+	if scale < 3 {
+		return img
+	}
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			c := colors[grid[y*width+x]]
+			gap := shade(c, drillGapColorShift)
+			x0, y0 := x*scale, y*scale
+			for p := 0; p < scale; p++ {
+				img.SetRGBA(x0+scale-1, y0+p, gap)
+				img.SetRGBA(x0+p, y0+scale-1, gap)
+			}
+			if scale < 4 {
+				continue
+			}
+			facet := shade(c, drillFacetColorShift)
+			for p := 1; p < scale/2; p++ {
+				for q := 1; q < scale/2-p+1; q++ {
+					img.SetRGBA(x0+p, y0+q, facet)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// shade makes color lighter for positive delta and darker for negative one
+func shade(c color.RGBA, delta int) color.RGBA {
+	channel := func(v uint8) uint8 {
+		return uint8(min(255, max(0, int(v)+delta)))
+	}
+	return color.RGBA{R: channel(c.R), G: channel(c.G), B: channel(c.B), A: 255}
+}
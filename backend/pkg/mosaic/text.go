@@ -0,0 +1,103 @@
+package mosaic
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// labelFace is bitmap font used for numbers and legends on generated schemes
+var labelFace = basicfont.Face7x13
+
+// measureText returns size of text in pixels for given scale
+func measureText(text string, scale int) (int, int) {
+	if scale < 1 {
+		scale = 1
+	}
+	width := font.MeasureString(labelFace, text).Ceil()
+	height := labelFace.Metrics().Height.Ceil()
+	return width * scale, height * scale
+}
+
+// drawText draws text with top-left corner at (x, y), scaling bitmap glyphs by integer factor
+func drawText(dst draw.Image, text string, x, y int, col color.Color, scale int) {
+	if text == "" {
+		return
+	}
+	if scale < 1 {
+		scale = 1
+	}
+
+	width, height := measureText(text, 1)
+	mask := image.NewAlpha(image.Rect(0, 0, width, height))
+	drawer := &font.Drawer{
+		Dst:  mask,
+		Src:  image.Opaque,
+		Face: labelFace,
+		Dot:  fixed.P(0, labelFace.Metrics().Ascent.Ceil()),
+	}
+	drawer.DrawString(text)
+
+	scaled := mask
+	if scale > 1 {
+		scaled = image.NewAlpha(image.Rect(0, 0, width*scale, height*scale))
+		for sy := 0; sy < height*scale; sy++ {
+			for sx := 0; sx < width*scale; sx++ {
+				scaled.SetAlpha(sx, sy, mask.AlphaAt(sx/scale, sy/scale))
+			}
+		}
+	}
+
+	rect := image.Rect(x, y, x+width*scale, y+height*scale)
+	draw.DrawMask(dst, rect, image.NewUniform(col), image.Point{}, scaled, image.Point{}, draw.Over)
+}
+
+// legendRow is a single line of legend table with color swatch
+type legendRow struct {
+	Color color.RGBA
+	Text  string
+}
+
+// renderLegendTable draws legend table with header and swatch rows
+func renderLegendTable(header string, rows []legendRow) *image.RGBA {
+	const (
+		rowHeight = 40
+		padding   = 20
+		swatch    = 28
+		textScale = 2
+	)
+
+	width, _ := measureText(header, textScale)
+	for _, row := range rows {
+		if w, _ := measureText(row.Text, textScale); w > width {
+			width = w
+		}
+	}
+	width += padding*2 + swatch + 10
+	height := padding*2 + rowHeight*(len(rows)+1)
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(img, img.Bounds(), image.White, image.Point{}, draw.Src)
+
+	drawText(img, header, padding+swatch+10, padding, color.Black, textScale)
+	for i, row := range rows {
+		y := padding + rowHeight*(i+1)
+		draw.Draw(img, image.Rect(padding, y, padding+swatch, y+swatch), image.NewUniform(row.Color), image.Point{}, draw.Src)
+		drawText(img, row.Text, padding+swatch+10, y, color.Black, textScale)
+	}
+
+	return img
+}
+
+// contrastTextColor returns black or white depending on background luminance
+func contrastTextColor(background color.RGBA) color.Color {
+	luminance := 0.299*float64(background.R) + 0.587*float64(background.G) + 0.114*float64(background.B)
+	if luminance > 140 {
+		return color.Black
+	}
+	return color.White
+}
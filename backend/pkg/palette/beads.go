@@ -0,0 +1,160 @@
+package palette
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// BeadBrand represents fuse bead manufacturer
+type BeadBrand string
+
+const (
+	BeadBrandHama   BeadBrand = "hama"   // Hama Midi beads
+	BeadBrandPerler BeadBrand = "perler" // Perler beads
+)
+
+// Fuse beads of both brands are placed on pegboards with 5mm pitch
+const BeadPitchMM = 5.0
+
+// BeadColor represents single fuse bead color of a brand
+type BeadColor struct {
+	Code string `json:"code"` // Brand color code printed on the bag
+	Name string `json:"name"` // Brand color name
+	R    uint8  `json:"r"`
+	G    uint8  `json:"g"`
+	B    uint8  `json:"b"`
+}
+
+// RGBA returns bead color as color.RGBA
+func (c BeadColor) RGBA() color.RGBA {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: 255}
+}
+
+// Hex returns bead color in #RRGGBB notation
+func (c BeadColor) Hex() string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// beadPalettes contains solid colors of supported bead brands with their catalogue codes
+var beadPalettes = map[BeadBrand][]BeadColor{
+	BeadBrandHama: {
+		{Code: "H01", Name: "White", R: 241, G: 241, B: 241},
+		{Code: "H02", Name: "Cream", R: 240, G: 232, B: 185},
+		{Code: "H03", Name: "Yellow", R: 240, G: 185, B: 1},
+		{Code: "H04", Name: "Orange", R: 230, G: 79, B: 39},
+		{Code: "H05", Name: "Red", R: 182, G: 49, B: 54},
+		{Code: "H06", Name: "Pink", R: 225, G: 136, B: 159},
+		{Code: "H07", Name: "Purple", R: 105, G: 74, B: 130},
+		{Code: "H08", Name: "Blue", R: 44, G: 70, B: 144},
+		{Code: "H09", Name: "Light Blue", R: 48, G: 92, B: 176},
+		{Code: "H10", Name: "Green", R: 37, G: 104, B: 71},
+		{Code: "H11", Name: "Light Green", R: 73, G: 174, B: 137},
+		{Code: "H12", Name: "Brown", R: 83, G: 65, B: 55},
+		{Code: "H17", Name: "Grey", R: 131, G: 136, B: 138},
+		{Code: "H18", Name: "Black", R: 46, G: 47, B: 49},
+		{Code: "H20", Name: "Reddish Brown", R: 127, G: 51, B: 42},
+		{Code: "H21", Name: "Light Brown", R: 165, G: 105, B: 63},
+		{Code: "H22", Name: "Dark Red", R: 160, G: 50, B: 54},
+		{Code: "H26", Name: "Flesh", R: 222, G: 158, B: 144},
+		{Code: "H27", Name: "Beige", R: 222, G: 180, B: 139},
+		{Code: "H28", Name: "Dark Green", R: 54, G: 63, B: 56},
+		{Code: "H29", Name: "Claret", R: 185, G: 57, B: 94},
+		{Code: "H30", Name: "Burgundy", R: 105, G: 46, B: 59},
+		{Code: "H31", Name: "Turquoise", R: 104, G: 138, B: 187},
+		{Code: "H43", Name: "Pastel Yellow", R: 240, G: 234, B: 55},
+		{Code: "H44", Name: "Pastel Red", R: 238, G: 105, B: 114},
+		{Code: "H45", Name: "Pastel Purple", R: 136, G: 118, B: 173},
+		{Code: "H46", Name: "Pastel Blue", R: 108, G: 136, B: 191},
+		{Code: "H47", Name: "Pastel Green", R: 146, G: 216, B: 127},
+		{Code: "H48", Name: "Pastel Pink", R: 224, G: 140, B: 198},
+		{Code: "H49", Name: "Azure", R: 76, G: 176, B: 209},
+		{Code: "H60", Name: "Teddybear Brown", R: 184, G: 123, B: 39},
+		{Code: "H70", Name: "Light Grey", R: 192, G: 195, B: 191},
+		{Code: "H71", Name: "Dark Grey", R: 73, G: 77, B: 80},
+		{Code: "H75", Name: "Tan", R: 124, G: 94, B: 69},
+		{Code: "H76", Name: "Nougat", R: 147, G: 111, B: 85},
+		{Code: "H77", Name: "Beige Light", R: 230, G: 213, B: 187},
+		{Code: "H78", Name: "Peach", R: 244, G: 190, B: 160},
+	},
+	BeadBrandPerler: {
+		{Code: "P01", Name: "White", R: 241, G: 241, B: 241},
+		{Code: "P02", Name: "Cream", R: 224, G: 222, B: 169},
+		{Code: "P03", Name: "Yellow", R: 236, G: 216, B: 0},
+		{Code: "P04", Name: "Orange", R: 237, G: 97, B: 32},
+		{Code: "P05", Name: "Red", R: 191, G: 46, B: 64},
+		{Code: "P06", Name: "Bubblegum", R: 221, G: 102, B: 154},
+		{Code: "P07", Name: "Purple", R: 96, G: 64, B: 137},
+		{Code: "P08", Name: "Dark Blue", R: 43, G: 63, B: 135},
+		{Code: "P09", Name: "Light Blue", R: 51, G: 112, B: 192},
+		{Code: "P10", Name: "Dark Green", R: 28, G: 117, B: 62},
+		{Code: "P11", Name: "Light Green", R: 86, G: 186, B: 159},
+		{Code: "P12", Name: "Brown", R: 81, G: 57, B: 49},
+		{Code: "P17", Name: "Grey", R: 138, G: 141, B: 145},
+		{Code: "P18", Name: "Black", R: 46, G: 47, B: 50},
+		{Code: "P20", Name: "Rust", R: 140, G: 55, B: 44},
+		{Code: "P21", Name: "Light Brown", R: 129, G: 93, B: 52},
+		{Code: "P33", Name: "Peach", R: 238, G: 186, B: 178},
+		{Code: "P35", Name: "Tan", R: 207, G: 168, B: 137},
+		{Code: "P38", Name: "Magenta", R: 242, G: 47, B: 123},
+		{Code: "P52", Name: "Pastel Blue", R: 88, G: 149, B: 209},
+		{Code: "P53", Name: "Pastel Green", R: 118, G: 200, B: 130},
+		{Code: "P54", Name: "Pastel Lavender", R: 138, G: 114, B: 193},
+		{Code: "P56", Name: "Pastel Yellow", R: 254, G: 247, B: 113},
+		{Code: "P57", Name: "Cheddar", R: 241, G: 170, B: 12},
+		{Code: "P58", Name: "Toothpaste", R: 147, G: 200, B: 212},
+		{Code: "P59", Name: "Hot Coral", R: 255, G: 59, B: 67},
+		{Code: "P60", Name: "Plum", R: 162, G: 75, B: 156},
+		{Code: "P61", Name: "Kiwi Lime", R: 108, G: 190, B: 19},
+		{Code: "P62", Name: "Turquoise", R: 38, G: 151, B: 173},
+		{Code: "P63", Name: "Blush", R: 255, G: 127, B: 133},
+		{Code: "P70", Name: "Periwinkle", R: 100, G: 114, B: 190},
+		{Code: "P79", Name: "Light Pink", R: 246, G: 179, B: 221},
+		{Code: "P83", Name: "Pink", R: 228, G: 72, B: 146},
+		{Code: "P90", Name: "Butterscotch", R: 211, G: 128, B: 69},
+		{Code: "P92", Name: "Dark Grey", R: 78, G: 81, B: 85},
+		{Code: "P96", Name: "Cranapple", R: 128, G: 31, B: 50},
+	},
+}
+
+// grayscaleBeadChroma is maximum channel spread for bead color to be used in grayscale kits
+const grayscaleBeadChroma = 24
+
+// GetBeadPalette returns bead colors of brand suitable for specified style, empty brand means Hama
+func (ps *PaletteService) GetBeadPalette(brand BeadBrand, style Style) ([]BeadColor, error) {
+	if brand == "" {
+		brand = BeadBrandHama
+	}
+
+	colors, ok := beadPalettes[brand]
+	if !ok {
+		ps.logger.GetZerologLogger().Error().Str("brand", string(brand)).Msg("Unknown bead brand requested")
+		return nil, fmt.Errorf("unknown bead brand: %s", brand)
+	}
+
+	if err := ps.ValidateStyle(string(style)); err != nil {
+		return nil, err
+	}
+
+	result := make([]BeadColor, 0, len(colors))
+	for _, c := range colors {
+		if style == StyleGrayscale && chroma(c.R, c.G, c.B) > grayscaleBeadChroma {
+			continue
+		}
+		result = append(result, c)
+	}
+
+	ps.logger.GetZerologLogger().Info().Str("brand", string(brand)).Str("style", string(style)).Int("colors", len(result)).Msg("Bead palette resolved successfully")
+	return result, nil
+}
+
+// ValidateBeadBrand validates bead brand correctness
+func (ps *PaletteService) ValidateBeadBrand(brand string) error {
+	if _, ok := beadPalettes[BeadBrand(brand)]; !ok {
+		return fmt.Errorf("invalid bead brand: %s. Available brands: hama, perler", brand)
+	}
+	return nil
+}
+
+func chroma(r, g, b uint8) uint8 {
+	return max(r, g, b) - min(r, g, b)
+}
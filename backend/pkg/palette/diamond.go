@@ -0,0 +1,378 @@
+package palette
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DiamondColor represents a single drill color of diamond mosaic palette
+type DiamondColor struct {
+	Code string `json:"code"` // Code printed on the drill bag, DMC number in most palettes
+	Name string `json:"name"` // Human-readable color name, empty when palette has none
+	R    uint8  `json:"r"`
+	G    uint8  `json:"g"`
+	B    uint8  `json:"b"`
+}
+
+// RGBA returns drill color as color.RGBA
+func (c DiamondColor) RGBA() color.RGBA {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: 255}
+}
+
+// Hex returns drill color in #RRGGBB notation
+func (c DiamondColor) Hex() string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// diamondPaletteCache keeps parsed xlsx palettes until their file changes
+type diamondPaletteCache struct {
+	mu      sync.Mutex
+	entries map[string]diamondPaletteEntry
+}
+
+type diamondPaletteEntry struct {
+	modTime time.Time
+	colors  []DiamondColor
+}
+
+var diamondPalettes = &diamondPaletteCache{entries: make(map[string]diamondPaletteEntry)}
+
+// GetDiamondPalette returns drill colors of diamond mosaic palette of specified style.
+// Colors are read from the same xlsx file generator script uses, so previews match generated schemas.
+func (ps *PaletteService) GetDiamondPalette(style Style) ([]DiamondColor, error) {
+	palettePath, err := ps.GetPalettePath(style)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(palettePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat palette file: %w", err)
+	}
+
+	diamondPalettes.mu.Lock()
+	defer diamondPalettes.mu.Unlock()
+
+	entry, ok := diamondPalettes.entries[palettePath]
+	if !ok || !entry.modTime.Equal(info.ModTime()) {
+		colors, err := readDiamondPalette(palettePath)
+		if err != nil {
+			ps.logger.GetZerologLogger().Error().Err(err).Str("path", palettePath).Str("style", string(style)).Msg("Failed to read diamond palette")
+			return nil, fmt.Errorf("failed to read diamond palette %s: %w", style, err)
+		}
+		entry = diamondPaletteEntry{modTime: info.ModTime(), colors: colors}
+		diamondPalettes.entries[palettePath] = entry
+	}
+
+	result := make([]DiamondColor, len(entry.colors))
+	copy(result, entry.colors)
+
+	ps.logger.GetZerologLogger().Info().Str("style", string(style)).Int("colors", len(result)).Msg("Diamond palette resolved successfully")
+	return result, nil
+}
+
+// readDiamondPalette reads drill colors from first worksheet of xlsx palette. Columns are found by header
+// (code, name, R/G/B or hex), palette without header is read as code in first column followed by R, G, B or hex.
+func readDiamondPalette(palettePath string) ([]DiamondColor, error) {
+	archive, err := zip.OpenReader(palettePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open xlsx: %w", err)
+	}
+	defer archive.Close()
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	var sharedStrings []string
+	if f, ok := files["xl/sharedStrings.xml"]; ok {
+		if sharedStrings, err = readSharedStrings(f); err != nil {
+			return nil, err
+		}
+	}
+
+	sheetPath, err := firstSheetPath(files)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := readSheetRows(files[sheetPath], sharedStrings)
+	if err != nil {
+		return nil, err
+	}
+
+	colors := parseDiamondRows(rows)
+	if len(colors) == 0 {
+		return nil, fmt.Errorf("no colors found in %s", path.Base(palettePath))
+	}
+	return colors, nil
+}
+
+// firstSheetPath resolves file of first worksheet listed in workbook
+func firstSheetPath(files map[string]*zip.File) (string, error) {
+	var workbook struct {
+		Sheets []struct {
+			ID string `xml:"http://schemas.openxmlformats.org/officeDocument/2006/relationships id,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	var rels struct {
+		Relationships []struct {
+			ID     string `xml:"Id,attr"`
+			Target string `xml:"Target,attr"`
+		} `xml:"Relationship"`
+	}
+
+	if f, ok := files["xl/workbook.xml"]; ok && decodeXML(f, &workbook) == nil && len(workbook.Sheets) > 0 {
+		if f, ok := files["xl/_rels/workbook.xml.rels"]; ok && decodeXML(f, &rels) == nil {
+			for _, rel := range rels.Relationships {
+				if rel.ID != workbook.Sheets[0].ID {
+					continue
+				}
+				target := strings.TrimPrefix(rel.Target, "/")
+				if !strings.HasPrefix(target, "xl/") {
+					target = path.Join("xl", target)
+				}
+				if _, ok := files[target]; ok {
+					return target, nil
+				}
+			}
+		}
+	}
+
+	if _, ok := files["xl/worksheets/sheet1.xml"]; ok {
+		return "xl/worksheets/sheet1.xml", nil
+	}
+	return "", fmt.Errorf("xlsx has no worksheets")
+}
+
+func readSharedStrings(f *zip.File) ([]string, error) {
+	var sst struct {
+		Items []struct {
+			Text string `xml:"t"`
+			Runs []struct {
+				Text string `xml:"t"`
+			} `xml:"r"`
+		} `xml:"si"`
+	}
+	if err := decodeXML(f, &sst); err != nil {
+		return nil, fmt.Errorf("failed to read shared strings: %w", err)
+	}
+
+	result := make([]string, len(sst.Items))
+	for i, item := range sst.Items {
+		text := item.Text
+		for _, run := range item.Runs {
+			text += run.Text
+		}
+		result[i] = text
+	}
+	return result, nil
+}
+
+// readSheetRows returns cell values of worksheet rows, cells are placed by their column reference
+func readSheetRows(f *zip.File, sharedStrings []string) ([][]string, error) {
+	var sheet struct {
+		Rows []struct {
+			Cells []struct {
+				Ref    string `xml:"r,attr"`
+				Type   string `xml:"t,attr"`
+				Value  string `xml:"v"`
+				Inline string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := decodeXML(f, &sheet); err != nil {
+		return nil, fmt.Errorf("failed to read worksheet: %w", err)
+	}
+
+	rows := make([][]string, 0, len(sheet.Rows))
+	for _, row := range sheet.Rows {
+		var values []string
+		for i, cell := range row.Cells {
+			column := columnIndex(cell.Ref)
+			if column < 0 {
+				column = i
+			}
+			for len(values) <= column {
+				values = append(values, "")
+			}
+
+			value := cell.Value
+			switch cell.Type {
+			case "s":
+				if idx, err := strconv.Atoi(value); err == nil && idx >= 0 && idx < len(sharedStrings) {
+					value = sharedStrings[idx]
+				}
+			case "inlineStr":
+				value = cell.Inline
+			}
+			values[column] = strings.TrimSpace(value)
+		}
+		rows = append(rows, values)
+	}
+	return rows, nil
+}
+
+// columnIndex converts cell reference like "C12" to zero-based column index
+func columnIndex(ref string) int {
+	index := 0
+	letters := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		index = index*26 + int(r-'A'+1)
+		letters++
+	}
+	if letters == 0 {
+		return -1
+	}
+	return index - 1
+}
+
+func decodeXML(f *zip.File, v any) error {
+	rc, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	return xml.NewDecoder(rc).Decode(v)
+}
+
+// diamondColumns are positions of palette columns, -1 when column is absent
+type diamondColumns struct {
+	code, name, r, g, b, hex int
+}
+
+var hexColorPattern = regexp.MustCompile(`^#?([0-9A-Fa-f]{6})$`)
+
+// parseDiamondRows finds palette columns by header row and reads colors from rows below it
+func parseDiamondRows(rows [][]string) []DiamondColor {
+	columns := diamondColumns{code: 0, name: -1, r: -1, g: -1, b: -1, hex: -1}
+	start := 0
+	for i, row := range rows {
+		if header, ok := diamondHeader(row); ok {
+			columns = header
+			start = i + 1
+			break
+		}
+	}
+
+	var colors []DiamondColor
+	seen := make(map[string]bool)
+	for _, row := range rows[start:] {
+		c, ok := parseDiamondRow(row, columns)
+		if !ok || seen[c.Code] {
+			continue
+		}
+		seen[c.Code] = true
+		colors = append(colors, c)
+	}
+	return colors
+}
+
+// diamondHeader recognizes header row by code column together with RGB or hex columns
+func diamondHeader(row []string) (diamondColumns, bool) {
+	columns := diamondColumns{code: -1, name: -1, r: -1, g: -1, b: -1, hex: -1}
+	for i, cell := range row {
+		switch strings.ToLower(strings.TrimSpace(cell)) {
+		case "code", "код", "dmc", "номер", "артикул":
+			if columns.code < 0 {
+				columns.code = i
+			}
+		case "name", "название", "наименование", "цвет":
+			if columns.name < 0 {
+				columns.name = i
+			}
+		case "r", "red":
+			columns.r = i
+		case "g", "green":
+			columns.g = i
+		case "b", "blue":
+			columns.b = i
+		case "hex", "rgb", "html":
+			columns.hex = i
+		}
+	}
+
+	hasRGB := columns.r >= 0 && columns.g >= 0 && columns.b >= 0
+	return columns, columns.code >= 0 && (hasRGB || columns.hex >= 0)
+}
+
+func parseDiamondRow(row []string, columns diamondColumns) (DiamondColor, bool) {
+	cell := func(i int) string {
+		if i < 0 || i >= len(row) {
+			return ""
+		}
+		return row[i]
+	}
+
+	c := DiamondColor{Code: strings.TrimSuffix(cell(columns.code), ".0"), Name: cell(columns.name)}
+	if c.Code == "" {
+		return c, false
+	}
+
+	if columns.r >= 0 && columns.g >= 0 && columns.b >= 0 {
+		var ok bool
+		if c.R, ok = channel(cell(columns.r)); !ok {
+			return c, false
+		}
+		if c.G, ok = channel(cell(columns.g)); !ok {
+			return c, false
+		}
+		if c.B, ok = channel(cell(columns.b)); !ok {
+			return c, false
+		}
+		return c, true
+	}
+	if columns.hex >= 0 {
+		return c, parseHex(cell(columns.hex), &c)
+	}
+
+	// No header: color is first hex cell or first three channel values after code
+	for i := columns.code + 1; i < len(row); i++ {
+		if parseHex(row[i], &c) {
+			return c, true
+		}
+		if i+2 < len(row) {
+			r, okR := channel(row[i])
+			g, okG := channel(row[i+1])
+			b, okB := channel(row[i+2])
+			if okR && okG && okB {
+				c.R, c.G, c.B = r, g, b
+				return c, true
+			}
+		}
+		if c.Name == "" {
+			c.Name = row[i]
+		}
+	}
+	return c, false
+}
+
+func channel(value string) (uint8, bool) {
+	f, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || f < 0 || f > 255 {
+		return 0, false
+	}
+	return uint8(f + 0.5), true
+}
+
+func parseHex(value string, c *DiamondColor) bool {
+	match := hexColorPattern.FindStringSubmatch(strings.TrimSpace(value))
+	if match == nil {
+		return false
+	}
+	v, _ := strconv.ParseUint(match[1], 16, 32)
+	c.R, c.G, c.B = uint8(v>>16), uint8(v>>8), uint8(v)
+	return true
+}
@@ -0,0 +1,137 @@
+package palette
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// PaintColor represents a single acrylic paint pot used in paint-by-numbers kits
+type PaintColor struct {
+	Code string `json:"code"` // Code printed on the paint pot
+	Name string `json:"name"` // Human-readable color name
+	R    uint8  `json:"r"`
+	G    uint8  `json:"g"`
+	B    uint8  `json:"b"`
+}
+
+// RGBA returns paint color as color.RGBA
+func (c PaintColor) RGBA() color.RGBA {
+	return color.RGBA{R: c.R, G: c.G, B: c.B, A: 255}
+}
+
+// Hex returns paint color in #RRGGBB notation
+func (c PaintColor) Hex() string {
+	return fmt.Sprintf("#%02X%02X%02X", c.R, c.G, c.B)
+}
+
+// paintPalettes contains built-in paint sets for paint-by-numbers kits.
+// Unlike diamond palettes they are not loaded from xlsx files because paint sets are mixed in-house.
+var paintPalettes = map[Style][]PaintColor{
+	StyleGrayscale: {
+		{Code: "G01", Name: "Titanium White", R: 250, G: 250, B: 248},
+		{Code: "G02", Name: "Pearl Grey", R: 228, G: 228, B: 226},
+		{Code: "G03", Name: "Silver Grey", R: 205, G: 205, B: 203},
+		{Code: "G04", Name: "Light Grey", R: 182, G: 182, B: 180},
+		{Code: "G05", Name: "Cool Grey", R: 158, G: 158, B: 157},
+		{Code: "G06", Name: "Neutral Grey", R: 134, G: 134, B: 133},
+		{Code: "G07", Name: "Medium Grey", R: 111, G: 111, B: 110},
+		{Code: "G08", Name: "Slate Grey", R: 89, G: 89, B: 89},
+		{Code: "G09", Name: "Dark Grey", R: 68, G: 68, B: 68},
+		{Code: "G10", Name: "Graphite", R: 48, G: 48, B: 48},
+		{Code: "G11", Name: "Charcoal", R: 31, G: 31, B: 31},
+		{Code: "G12", Name: "Mars Black", R: 14, G: 14, B: 14},
+	},
+	StyleSkinTones: {
+		{Code: "S01", Name: "Titanium White", R: 250, G: 250, B: 248},
+		{Code: "S02", Name: "Ivory", R: 247, G: 234, B: 214},
+		{Code: "S03", Name: "Porcelain", R: 243, G: 218, B: 197},
+		{Code: "S04", Name: "Light Peach", R: 238, G: 200, B: 174},
+		{Code: "S05", Name: "Peach", R: 229, G: 181, B: 152},
+		{Code: "S06", Name: "Warm Beige", R: 217, G: 170, B: 133},
+		{Code: "S07", Name: "Rose Beige", R: 214, G: 157, B: 140},
+		{Code: "S08", Name: "Sand", R: 198, G: 151, B: 112},
+		{Code: "S09", Name: "Caramel", R: 176, G: 123, B: 84},
+		{Code: "S10", Name: "Sienna", R: 153, G: 98, B: 64},
+		{Code: "S11", Name: "Cocoa", R: 121, G: 78, B: 55},
+		{Code: "S12", Name: "Raw Umber", R: 94, G: 62, B: 45},
+		{Code: "S13", Name: "Burnt Umber", R: 66, G: 43, B: 33},
+		{Code: "S14", Name: "Blush Pink", R: 224, G: 150, B: 150},
+		{Code: "S15", Name: "Lip Red", R: 178, G: 76, B: 76},
+		{Code: "S16", Name: "Dusty Rose", R: 173, G: 112, B: 112},
+		{Code: "S17", Name: "Olive Shadow", R: 120, G: 112, B: 84},
+		{Code: "S18", Name: "Cool Shadow", R: 108, G: 104, B: 118},
+		{Code: "S19", Name: "Iris Blue", R: 92, G: 124, B: 158},
+		{Code: "S20", Name: "Mars Black", R: 14, G: 14, B: 14},
+	},
+	StylePopArt: {
+		{Code: "P01", Name: "Titanium White", R: 250, G: 250, B: 248},
+		{Code: "P02", Name: "Lemon Yellow", R: 255, G: 232, B: 0},
+		{Code: "P03", Name: "Sun Orange", R: 255, G: 140, B: 0},
+		{Code: "P04", Name: "Vermilion", R: 236, G: 56, B: 32},
+		{Code: "P05", Name: "Magenta", R: 222, G: 0, B: 126},
+		{Code: "P06", Name: "Hot Pink", R: 255, G: 105, B: 180},
+		{Code: "P07", Name: "Violet", R: 128, G: 40, B: 168},
+		{Code: "P08", Name: "Ultramarine", R: 32, G: 64, B: 200},
+		{Code: "P09", Name: "Cyan", R: 0, G: 174, B: 239},
+		{Code: "P10", Name: "Turquoise", R: 0, G: 190, B: 170},
+		{Code: "P11", Name: "Grass Green", R: 60, G: 180, B: 60},
+		{Code: "P12", Name: "Lime", R: 180, G: 230, B: 40},
+		{Code: "P13", Name: "Skin Light", R: 250, G: 210, B: 180},
+		{Code: "P14", Name: "Medium Grey", R: 128, G: 128, B: 128},
+		{Code: "P15", Name: "Dark Blue", R: 20, G: 30, B: 90},
+		{Code: "P16", Name: "Mars Black", R: 14, G: 14, B: 14},
+	},
+	StyleMaxColors: {
+		{Code: "M01", Name: "Titanium White", R: 250, G: 250, B: 248},
+		{Code: "M02", Name: "Ivory", R: 247, G: 234, B: 214},
+		{Code: "M03", Name: "Light Grey", R: 190, G: 190, B: 188},
+		{Code: "M04", Name: "Medium Grey", R: 128, G: 128, B: 128},
+		{Code: "M05", Name: "Dark Grey", R: 70, G: 70, B: 70},
+		{Code: "M06", Name: "Mars Black", R: 14, G: 14, B: 14},
+		{Code: "M07", Name: "Lemon Yellow", R: 255, G: 232, B: 0},
+		{Code: "M08", Name: "Cadmium Yellow", R: 255, G: 200, B: 30},
+		{Code: "M09", Name: "Yellow Ochre", R: 204, G: 153, B: 51},
+		{Code: "M10", Name: "Sun Orange", R: 255, G: 140, B: 0},
+		{Code: "M11", Name: "Vermilion", R: 236, G: 56, B: 32},
+		{Code: "M12", Name: "Crimson", R: 178, G: 24, B: 44},
+		{Code: "M13", Name: "Burgundy", R: 110, G: 20, B: 40},
+		{Code: "M14", Name: "Light Pink", R: 248, G: 190, B: 200},
+		{Code: "M15", Name: "Magenta", R: 222, G: 0, B: 126},
+		{Code: "M16", Name: "Lavender", R: 186, G: 160, B: 210},
+		{Code: "M17", Name: "Violet", R: 128, G: 40, B: 168},
+		{Code: "M18", Name: "Dioxazine Purple", R: 72, G: 30, B: 100},
+		{Code: "M19", Name: "Sky Blue", R: 150, G: 200, B: 240},
+		{Code: "M20", Name: "Cerulean", R: 40, G: 140, B: 210},
+		{Code: "M21", Name: "Ultramarine", R: 32, G: 64, B: 200},
+		{Code: "M22", Name: "Prussian Blue", R: 20, G: 40, B: 80},
+		{Code: "M23", Name: "Turquoise", R: 0, G: 190, B: 170},
+		{Code: "M24", Name: "Mint", R: 170, G: 225, B: 195},
+		{Code: "M25", Name: "Lime", R: 180, G: 230, B: 40},
+		{Code: "M26", Name: "Grass Green", R: 60, G: 160, B: 60},
+		{Code: "M27", Name: "Sap Green", R: 80, G: 110, B: 40},
+		{Code: "M28", Name: "Viridian", R: 20, G: 100, B: 80},
+		{Code: "M29", Name: "Olive", R: 120, G: 120, B: 60},
+		{Code: "M30", Name: "Peach", R: 229, G: 181, B: 152},
+		{Code: "M31", Name: "Warm Beige", R: 217, G: 170, B: 133},
+		{Code: "M32", Name: "Caramel", R: 176, G: 123, B: 84},
+		{Code: "M33", Name: "Burnt Sienna", R: 160, G: 82, B: 45},
+		{Code: "M34", Name: "Raw Umber", R: 94, G: 62, B: 45},
+		{Code: "M35", Name: "Burnt Umber", R: 66, G: 43, B: 33},
+		{Code: "M36", Name: "Payne's Grey", R: 50, G: 60, B: 72},
+	},
+}
+
+// GetPaintPalette returns built-in paint set for paint-by-numbers kits of specified style
+func (ps *PaletteService) GetPaintPalette(style Style) ([]PaintColor, error) {
+	colors, ok := paintPalettes[style]
+	if !ok {
+		ps.logger.GetZerologLogger().Error().Str("style", string(style)).Msg("Unknown paint palette style requested")
+		return nil, fmt.Errorf("unknown paint palette style: %s", style)
+	}
+
+	result := make([]PaintColor, len(colors))
+	copy(result, colors)
+
+	ps.logger.GetZerologLogger().Info().Str("style", string(style)).Int("colors", len(result)).Msg("Paint palette resolved successfully")
+	return result, nil
+}
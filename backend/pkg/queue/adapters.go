@@ -2,6 +2,8 @@ package queue
 
 import (
 	"context"
+	"errors"
+	"fmt"
 
 	"github.com/google/uuid"
 	"github.com/skr1ms/mosaic/internal/image"
@@ -44,8 +46,11 @@ func (a *ImageServiceAdapter) ProcessImageWithStyle(ctx context.Context, imageID
 	if settings, ok := parameters["settings"].(map[string]any); ok {
 		processParams.Settings = settings
 	}
+	if bg, ok := parameters["background"].(map[string]any); ok {
+		processParams.Background = backgroundParams(bg)
+	}
 
-	return a.imageService.ProcessImage(ctx, imageID, &processParams)
+	return taskError(a.imageService.ProcessImage(ctx, imageID, &processParams))
 }
 
 // ProcessImageWithAI processes image using AI (Stable Diffusion)
@@ -70,26 +75,65 @@ func (a *ImageServiceAdapter) ProcessImageWithAI(ctx context.Context, imageID uu
 	if settings, ok := parameters["settings"].(map[string]any); ok {
 		processParams.Settings = settings
 	}
+	if bg, ok := parameters["background"].(map[string]any); ok {
+		processParams.Background = backgroundParams(bg)
+	}
 
 	// Log processing image with AI
 
-	return a.imageService.ProcessImage(ctx, imageID, &processParams)
+	return taskError(a.imageService.ProcessImage(ctx, imageID, &processParams))
+}
+
+// backgroundParams reads background replacement from task parameters
+func backgroundParams(parameters map[string]any) *image.BackgroundParams {
+	params := &image.BackgroundParams{}
+	params.Mode, _ = parameters["mode"].(string)
+	params.ColorCode, _ = parameters["color_code"].(string)
+	params.Blur, _ = parameters["blur"].(float64)
+	return params
 }
 
 // GenerateSchema generates mosaic schema
 func (a *ImageServiceAdapter) GenerateSchema(ctx context.Context, imageID uuid.UUID, confirmed bool) error {
-	return a.imageService.GenerateSchema(ctx, imageID, confirmed)
+	return taskError(a.imageService.GenerateSchema(ctx, imageID, confirmed))
 }
 
-// OptimizeImage optimizes image (stub method, can be implemented later)
+// GenerateWithStyle processes image and generates its schema without preview confirmation
+func (a *ImageServiceAdapter) GenerateWithStyle(ctx context.Context, imageID uuid.UUID, style string) error {
+	return taskError(a.imageService.GenerateWithStyle(ctx, imageID, style))
+}
+
+// taskError turns cancellation of image into ErrTaskCancelled, so queue does not retry cancelled work
+func taskError(err error) error {
+	if errors.Is(err, image.ErrImageCancelled) {
+		return fmt.Errorf("%w: %v", ErrTaskCancelled, err)
+	}
+	return err
+}
+
+// ResetInterruptedProcessing prepares image for redelivered processing task
+func (a *ImageServiceAdapter) ResetInterruptedProcessing(ctx context.Context, imageID uuid.UUID) error {
+	return a.imageService.ResetInterruptedProcessing(ctx, imageID)
+}
+
+// OptimizeImage re-encodes original image and stores it in S3
 func (a *ImageServiceAdapter) OptimizeImage(ctx context.Context, imageID uuid.UUID, quality int) error {
-	return nil
+	return a.imageService.OptimizeImage(ctx, imageID, quality)
 }
 
-// GenerateThumbnails generates image thumbnails (stub method, can be implemented later)
+// GenerateThumbnails generates image thumbnails and stores them in S3
 func (a *ImageServiceAdapter) GenerateThumbnails(ctx context.Context, imageID uuid.UUID, sizes []string) error {
-	// For now return nil, can add implementation later
-	return nil
+	return a.imageService.GenerateThumbnails(ctx, imageID, sizes)
+}
+
+// UpscaleImage upscales low-resolution processed image before schema generation
+func (a *ImageServiceAdapter) UpscaleImage(ctx context.Context, imageID uuid.UUID) error {
+	return taskError(a.imageService.UpscaleImage(ctx, imageID))
+}
+
+// RenderMockups renders framed mockups of completed image and stores them in S3
+func (a *ImageServiceAdapter) RenderMockups(ctx context.Context, imageID uuid.UUID) error {
+	return a.imageService.RenderMockups(ctx, imageID)
 }
 
 // EmailServiceAdapter adapter for compatibility with queue.EmailService
@@ -0,0 +1,74 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrTaskCancelled is returned by task handlers when work of task was cancelled, such task is
+// acknowledged without retries and is not moved to dead-letter store
+var ErrTaskCancelled = errors.New("task cancelled")
+
+// cancelScript removes waiting task with given ID from pending or delayed set and frees its deduplication key.
+// Task data is found in task index, so cancel does not depend on queue length.
+// KEYS[7] - delayed set, KEYS[8] - deduplication key, ARGV[1] - task ID
+var cancelScript = redis.NewScript(fairLua + `
+local data = redis.call('HGET', KEYS[4], ARGV[1])
+if not data then
+	return 0
+end
+redis.call('HDEL', KEYS[4], ARGV[1])
+
+local group = taskGroup(decode(data))
+if redis.call('ZREM', groupKey(group), data) == 1 then
+	redis.call('RPOP', KEYS[2])
+	refresh(group)
+elseif redis.call('ZREM', KEYS[7], data) == 0 then
+	return 0
+end
+redis.call('DEL', KEYS[8])
+return 1
+`)
+
+// CancelByDedupKey removes task holding deduplication key from queue while it waits for worker.
+// Returns false when there is no such task or it is already being processed.
+func (q *TaskQueue) CancelByDedupKey(key string) (bool, error) {
+	dedupKey := q.getDedupKey(key)
+
+	taskID, err := q.redisClient.Get(q.ctx, dedupKey).Result()
+	if err == redis.Nil {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get task by deduplication key: %w", err)
+	}
+
+	keys := append(q.pushKeys(), q.getDelayedKey(), dedupKey)
+	removed, err := cancelScript.Run(q.ctx, q.redisClient, keys, taskID).Int()
+	if err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("task_id", taskID).Str("dedup_key", key).Msg("Failed to cancel task")
+		return false, fmt.Errorf("failed to cancel task: %w", err)
+	}
+	if removed == 0 {
+		return false, nil
+	}
+
+	q.logger.GetZerologLogger().Info().
+		Str("task_id", taskID).
+		Str("queue", q.name).
+		Str("dedup_key", key).
+		Msg("Task cancelled")
+
+	return true, nil
+}
+
+// DedupKeyHeld reports whether deduplication key is held by task that is still waiting or being processed
+func (q *TaskQueue) DedupKeyHeld(key string) (bool, error) {
+	held, err := q.redisClient.Exists(q.ctx, q.getDedupKey(key)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check deduplication key: %w", err)
+	}
+	return held > 0, nil
+}
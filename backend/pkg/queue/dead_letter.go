@@ -0,0 +1,323 @@
+package queue
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrDeadTaskNotFound is returned when dead-letter store has no task with given ID
+var ErrDeadTaskNotFound = errors.New("dead task not found")
+
+// TaskAttempt is single failed attempt of task
+type TaskAttempt struct {
+	Attempt  int       `json:"attempt"`
+	Error    string    `json:"error"`
+	WorkerID string    `json:"worker_id,omitempty"`
+	FailedAt time.Time `json:"failed_at"`
+}
+
+// DeadTask is task that exhausted its retries, kept with payload and attempt history until replayed or purged
+type DeadTask struct {
+	Task      *Task     `json:"task"`
+	Queue     string    `json:"queue"`
+	LastError string    `json:"last_error"`
+	DiedAt    time.Time `json:"died_at"`
+}
+
+// replayScript moves dead task back to pending set unless it was replayed or purged meanwhile. Deduplication
+// key of task is reserved again, replay is refused while key is held by another task.
+// KEYS[7] - dead tasks hash, KEYS[8] - dead index, KEYS[9] - dead index of task type, KEYS[10] - deduplication key,
+// ARGV[1] - task data, ARGV[2] - priority weight, ARGV[3] - task ID, ARGV[4] - "1" when task has deduplication key,
+// ARGV[5] - deduplication window in milliseconds
+var replayScript = redis.NewScript(pushLua + `
+if redis.call('HEXISTS', KEYS[7], ARGV[3]) == 0 then
+	return 0
+end
+if ARGV[4] == '1' then
+	local holder = redis.call('GET', KEYS[10])
+	if holder and holder ~= ARGV[3] then
+		return -1
+	end
+	redis.call('SET', KEYS[10], ARGV[3], 'PX', ARGV[5])
+end
+redis.call('HDEL', KEYS[7], ARGV[3])
+redis.call('ZREM', KEYS[8], ARGV[3])
+redis.call('ZREM', KEYS[9], ARGV[3])
+push(ARGV[1], ARGV[2], false)
+return 1
+`)
+
+// buryScript moves task of expired worker from its in-flight list to dead-letter store unless task was
+// requeued or acknowledged meanwhile.
+// KEYS[1] - in-flight list, KEYS[2] - dead tasks hash, KEYS[3] - dead index, KEYS[4] - dead index of task type,
+// ARGV[1] - stored task data, ARGV[2] - dead task data, ARGV[3] - task ID, ARGV[4] - death time
+var buryScript = redis.NewScript(`
+if redis.call('LREM', KEYS[1], 1, ARGV[1]) == 0 then
+	return 0
+end
+redis.call('HSET', KEYS[2], ARGV[3], ARGV[2])
+redis.call('ZADD', KEYS[3], ARGV[4], ARGV[3])
+redis.call('ZADD', KEYS[4], ARGV[4], ARGV[3])
+return 1
+`)
+
+// moveToDeadLetter stores permanently failed task and acknowledges it
+func (q *TaskQueue) moveToDeadLetter(task *Task) error {
+	deadData, diedAt, err := q.deadTaskData(task)
+	if err != nil {
+		return err
+	}
+
+	score := float64(diedAt.Unix())
+	pipe := q.redisClient.TxPipeline()
+	pipe.HSet(q.ctx, q.getDeadKey(), task.ID, deadData)
+	pipe.ZAdd(q.ctx, q.getDeadIndexKey(""), redis.Z{Score: score, Member: task.ID})
+	pipe.ZAdd(q.ctx, q.getDeadIndexKey(task.Type), redis.Z{Score: score, Member: task.ID})
+	if _, err := pipe.Exec(q.ctx); err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("task_id", task.ID).Str("task_type", task.Type).Msg("Failed to move task to dead-letter store")
+		return fmt.Errorf("failed to move task to dead-letter store: %w", err)
+	}
+
+	q.release(task)
+	q.releaseDedupKey(task)
+	return nil
+}
+
+// buryExpired moves task left in in-flight list of expired worker to dead-letter store,
+// returns false when task is no longer there
+func (q *TaskQueue) buryExpired(task *Task, inflightKey, taskData string) (bool, error) {
+	deadData, diedAt, err := q.deadTaskData(task)
+	if err != nil {
+		return false, err
+	}
+
+	keys := []string{inflightKey, q.getDeadKey(), q.getDeadIndexKey(""), q.getDeadIndexKey(task.Type)}
+	moved, err := buryScript.Run(q.ctx, q.redisClient, keys, taskData, deadData, task.ID, diedAt.Unix()).Int()
+	if err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("task_id", task.ID).Str("task_type", task.Type).Msg("Failed to move task to dead-letter store")
+		return false, fmt.Errorf("failed to move task to dead-letter store: %w", err)
+	}
+	if moved == 0 {
+		return false, nil
+	}
+
+	q.releaseDedupKey(task)
+	return true, nil
+}
+
+func (q *TaskQueue) deadTaskData(task *Task) ([]byte, time.Time, error) {
+	diedAt := time.Now()
+	if task.ProcessedAt != nil {
+		diedAt = *task.ProcessedAt
+	}
+
+	deadData, err := json.Marshal(&DeadTask{
+		Task:      task,
+		Queue:     q.name,
+		LastError: task.Error,
+		DiedAt:    diedAt,
+	})
+	if err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("task_id", task.ID).Str("task_type", task.Type).Msg("Failed to marshal dead task")
+		return nil, diedAt, fmt.Errorf("failed to marshal dead task: %w", err)
+	}
+
+	return deadData, diedAt, nil
+}
+
+// ListDeadTasks returns dead tasks starting from most recent, optionally only of given type
+func (q *TaskQueue) ListDeadTasks(taskType string, offset, limit int) ([]*DeadTask, int64, error) {
+	indexKey := q.getDeadIndexKey(taskType)
+
+	total, err := q.redisClient.ZCard(q.ctx, indexKey).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to count dead tasks: %w", err)
+	}
+
+	ids, err := q.redisClient.ZRevRange(q.ctx, indexKey, int64(offset), int64(offset+limit-1)).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list dead tasks: %w", err)
+	}
+	if len(ids) == 0 {
+		return []*DeadTask{}, total, nil
+	}
+
+	values, err := q.redisClient.HMGet(q.ctx, q.getDeadKey(), ids...).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get dead tasks: %w", err)
+	}
+
+	tasks := make([]*DeadTask, 0, len(values))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		var deadTask DeadTask
+		if err := json.Unmarshal([]byte(data), &deadTask); err != nil {
+			q.logger.GetZerologLogger().Error().Err(err).Str("task_id", ids[i]).Msg("Failed to unmarshal dead task")
+			continue
+		}
+		tasks = append(tasks, &deadTask)
+	}
+
+	return tasks, total, nil
+}
+
+// GetDeadTask returns dead task by ID
+func (q *TaskQueue) GetDeadTask(id string) (*DeadTask, error) {
+	data, err := q.redisClient.HGet(q.ctx, q.getDeadKey(), id).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil, ErrDeadTaskNotFound
+		}
+		return nil, fmt.Errorf("failed to get dead task: %w", err)
+	}
+
+	var deadTask DeadTask
+	if err := json.Unmarshal([]byte(data), &deadTask); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal dead task: %w", err)
+	}
+
+	return &deadTask, nil
+}
+
+// ReplayDeadTasks returns dead tasks to queue with fresh retry budget, attempt history is kept.
+// When ids are empty all dead tasks of given type are replayed, empty type means all types.
+// Task whose deduplication key is held by another task is left in dead-letter store.
+func (q *TaskQueue) ReplayDeadTasks(taskType string, ids []string) (int, error) {
+	ids, err := q.resolveDeadTaskIDs(taskType, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	replayed := 0
+	for _, id := range ids {
+		deadTask, err := q.GetDeadTask(id)
+		if err != nil {
+			if errors.Is(err, ErrDeadTaskNotFound) {
+				continue
+			}
+			return replayed, err
+		}
+
+		task := deadTask.Task
+		task.Retries = 0
+		task.Redelivered = 0
+		task.Error = ""
+		task.ScheduledAt = nil
+		task.ProcessedAt = nil
+
+		taskData, err := json.Marshal(task)
+		if err != nil {
+			return replayed, fmt.Errorf("failed to marshal replayed task: %w", err)
+		}
+
+		hasDedupKey := "0"
+		if task.DedupKey != "" {
+			hasDedupKey = "1"
+		}
+
+		keys := append(q.pushKeys(), q.getDeadKey(), q.getDeadIndexKey(""), q.getDeadIndexKey(task.Type), q.getDedupKey(task.DedupKey))
+		moved, err := replayScript.Run(q.ctx, q.redisClient, keys, taskData, priorityWeight(task.Priority), task.ID,
+			hasDedupKey, q.dedupWindow.Milliseconds()).Int()
+		if err != nil {
+			q.logger.GetZerologLogger().Error().Err(err).Str("task_id", task.ID).Msg("Failed to replay dead task")
+			return replayed, fmt.Errorf("failed to replay dead task: %w", err)
+		}
+		switch moved {
+		case -1:
+			// The same work was enqueued again after task died, replay would run it twice
+			q.logger.GetZerologLogger().Warn().
+				Str("task_id", task.ID).
+				Str("task_type", task.Type).
+				Str("dedup_key", task.DedupKey).
+				Msg("Dead task not replayed, deduplication key is held by another task")
+		case 1:
+			replayed++
+			q.logger.GetZerologLogger().Info().
+				Str("task_id", task.ID).
+				Str("task_type", task.Type).
+				Str("queue", q.name).
+				Int("attempts", len(task.Attempts)).
+				Msg("Dead task replayed")
+		}
+	}
+
+	return replayed, nil
+}
+
+// PurgeDeadTasks deletes dead tasks. When ids are empty all dead tasks of given type are deleted,
+// empty type means all types.
+func (q *TaskQueue) PurgeDeadTasks(taskType string, ids []string) (int, error) {
+	ids, err := q.resolveDeadTaskIDs(taskType, ids)
+	if err != nil {
+		return 0, err
+	}
+
+	return q.purgeDeadTasks(ids)
+}
+
+// PurgeDeadTasksBefore deletes dead tasks that failed before given time
+func (q *TaskQueue) PurgeDeadTasksBefore(before time.Time) (int, error) {
+	ids, err := q.redisClient.ZRangeByScore(q.ctx, q.getDeadIndexKey(""), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprintf("%d", before.Unix()),
+	}).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get old dead tasks: %w", err)
+	}
+
+	return q.purgeDeadTasks(ids)
+}
+
+// CountDeadTasks returns number of tasks in dead-letter store
+func (q *TaskQueue) CountDeadTasks() (int64, error) {
+	return q.redisClient.HLen(q.ctx, q.getDeadKey()).Result()
+}
+
+func (q *TaskQueue) purgeDeadTasks(ids []string) (int, error) {
+	purged := 0
+	for _, id := range ids {
+		deadTask, err := q.GetDeadTask(id)
+		if err != nil {
+			if errors.Is(err, ErrDeadTaskNotFound) {
+				// Drop dangling index entry
+				q.redisClient.ZRem(q.ctx, q.getDeadIndexKey(""), id)
+				continue
+			}
+			return purged, err
+		}
+
+		pipe := q.redisClient.TxPipeline()
+		deleted := pipe.HDel(q.ctx, q.getDeadKey(), id)
+		pipe.ZRem(q.ctx, q.getDeadIndexKey(""), id)
+		pipe.ZRem(q.ctx, q.getDeadIndexKey(deadTask.Task.Type), id)
+		if _, err := pipe.Exec(q.ctx); err != nil {
+			return purged, fmt.Errorf("failed to purge dead task: %w", err)
+		}
+		purged += int(deleted.Val())
+	}
+
+	if purged > 0 {
+		q.logger.GetZerologLogger().Info().Str("queue", q.name).Int("purged", purged).Msg("Dead tasks purged")
+	}
+	return purged, nil
+}
+
+func (q *TaskQueue) resolveDeadTaskIDs(taskType string, ids []string) ([]string, error) {
+	if len(ids) > 0 {
+		return ids, nil
+	}
+
+	ids, err := q.redisClient.ZRange(q.ctx, q.getDeadIndexKey(taskType), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list dead tasks: %w", err)
+	}
+	return ids, nil
+}
@@ -12,6 +12,13 @@ import (
 // ImageTaskQueue specialized queue for image processing
 type ImageTaskQueue struct {
 	*TaskQueue
+	shares ShareResolver
+}
+
+// ShareResolver finds partner that owns image and weight of partner in queue, so that tasks of
+// partner with hundreds of activations do not starve images of other partners
+type ShareResolver interface {
+	GetQueueShare(ctx context.Context, imageID uuid.UUID) (uuid.UUID, int, error)
 }
 
 // NewImageTaskQueue creates queue for image processing tasks
@@ -21,6 +28,30 @@ func NewImageTaskQueue(redis *redis.Client, logger *middleware.Logger) *ImageTas
 	}
 }
 
+// SetShareResolver makes tasks of images share workers fairly between partners, must be called before tasks are enqueued
+func (q *ImageTaskQueue) SetShareResolver(resolver ShareResolver) {
+	q.shares = resolver
+}
+
+// share returns fair share group of partner that owns image, tasks of images whose partner
+// can not be found share default group
+func (q *ImageTaskQueue) share(imageID uuid.UUID) TaskOption {
+	if q.shares == nil {
+		return func(*Task) {}
+	}
+
+	partnerID, weight, err := q.shares.GetQueueShare(q.ctx, imageID)
+	if err != nil {
+		q.logger.GetZerologLogger().Warn().Err(err).Str("image_id", imageID.String()).Msg("Failed to get queue share of image")
+		return func(*Task) {}
+	}
+	return WithGroup(partnerID.String(), weight)
+}
+
+// Deduplication keys are scoped by image, so repeated requests for the same image within
+// deduplication window return ID of task that is already queued. Task still waiting for worker
+// takes parameters of the latest request, e.g. style chosen again by customer.
+
 // Task types for image processing
 const (
 	TaskTypeImageProcessing     = "image_processing"
@@ -28,61 +59,131 @@ const (
 	TaskTypeEmailSending        = "email_sending"
 	TaskTypeImageOptimization   = "image_optimization"
 	TaskTypeThumbnailGeneration = "thumbnail_generation"
+	TaskTypeImageUpscaling      = "image_upscaling"
+	TaskTypeMockupRendering     = "mockup_rendering"
 	TaskTypeAIProcessing        = "ai_processing" // AI processing via Stable Diffusion
 	TaskTypeAIPriority          = "ai_priority"   // Priority AI processing
+	TaskTypeBulkGeneration      = "bulk_generation"
 )
 
 // EnqueueImageProcessing adds image processing task
-func (q *ImageTaskQueue) EnqueueImageProcessing(imageID uuid.UUID, style string, parameters map[string]any) error {
+func (q *ImageTaskQueue) EnqueueImageProcessing(imageID uuid.UUID, style string, parameters map[string]any) (string, error) {
 	payload := map[string]any{
 		"image_id":   imageID.String(),
 		"style":      style,
 		"parameters": parameters,
 	}
 
-	return q.Enqueue(TaskTypeImageProcessing, payload, WithPriority(5), WithMaxRetries(3))
+	return q.Enqueue(TaskTypeImageProcessing, payload, WithPriority(5), WithMaxRetries(3),
+		WithDedupKey(fmt.Sprintf("process:%s", imageID), 0), q.share(imageID))
 }
 
 // EnqueueSchemaGeneration adds schema generation task
-func (q *ImageTaskQueue) EnqueueSchemaGeneration(imageID uuid.UUID, couponID uuid.UUID, confirmed bool) error {
+func (q *ImageTaskQueue) EnqueueSchemaGeneration(imageID uuid.UUID, couponID uuid.UUID, confirmed bool) (string, error) {
 	payload := map[string]any{
 		"image_id":  imageID.String(),
 		"coupon_id": couponID.String(),
 		"confirmed": confirmed,
 	}
 
-	return q.Enqueue(TaskTypeSchemaGeneration, payload, WithPriority(8), WithMaxRetries(2))
+	return q.Enqueue(TaskTypeSchemaGeneration, payload, WithPriority(8), WithMaxRetries(2),
+		WithDedupKey(fmt.Sprintf("schema:%s", imageID), 0), q.share(imageID))
 }
 
 // EnqueueEmailSending adds email sending task
-func (q *ImageTaskQueue) EnqueueEmailSending(email string, schemaURL string, couponCode string) error {
+func (q *ImageTaskQueue) EnqueueEmailSending(email string, schemaURL string, couponCode string) (string, error) {
 	payload := map[string]any{
 		"email":       email,
 		"schema_url":  schemaURL,
 		"coupon_code": couponCode,
 	}
 
-	return q.Enqueue(TaskTypeEmailSending, payload, WithPriority(3), WithMaxRetries(5))
+	return q.Enqueue(TaskTypeEmailSending, payload, WithPriority(3), WithMaxRetries(5),
+		WithDedupKey(fmt.Sprintf("email:%s:%s", couponCode, email), 0))
 }
 
 // EnqueueImageOptimization adds image optimization task
-func (q *ImageTaskQueue) EnqueueImageOptimization(imageID uuid.UUID, quality int) error {
+func (q *ImageTaskQueue) EnqueueImageOptimization(imageID uuid.UUID, quality int) (string, error) {
 	payload := map[string]any{
 		"image_id": imageID.String(),
 		"quality":  quality,
 	}
 
-	return q.Enqueue(TaskTypeImageOptimization, payload, WithPriority(2))
+	return q.Enqueue(TaskTypeImageOptimization, payload, WithPriority(2),
+		WithDedupKey(fmt.Sprintf("optimize:%s", imageID), 0), q.share(imageID))
 }
 
 // EnqueueThumbnailGeneration adds thumbnail generation task
-func (q *ImageTaskQueue) EnqueueThumbnailGeneration(imageID uuid.UUID, sizes []string) error {
+func (q *ImageTaskQueue) EnqueueThumbnailGeneration(imageID uuid.UUID, sizes []string) (string, error) {
 	payload := map[string]any{
 		"image_id": imageID.String(),
 		"sizes":    sizes,
 	}
 
-	return q.Enqueue(TaskTypeThumbnailGeneration, payload, WithPriority(1))
+	return q.Enqueue(TaskTypeThumbnailGeneration, payload, WithPriority(1),
+		WithDedupKey(fmt.Sprintf("thumbnails:%s", imageID), 0), q.share(imageID))
+}
+
+// EnqueueImageUpscaling adds task upscaling processed image before its schema is generated
+func (q *ImageTaskQueue) EnqueueImageUpscaling(imageID uuid.UUID) (string, error) {
+	payload := map[string]any{
+		"image_id": imageID.String(),
+	}
+
+	return q.Enqueue(TaskTypeImageUpscaling, payload, WithPriority(7), WithMaxRetries(2),
+		WithDedupKey(fmt.Sprintf("upscale:%s", imageID), 0), q.share(imageID))
+}
+
+// ClaimImageUpscaling takes upscaling of image over from its upscaling task, so schema generation does not
+// upscale image at the same time. Task still waiting in queue is removed, false is returned while task is run by worker.
+func (q *ImageTaskQueue) ClaimImageUpscaling(imageID uuid.UUID) (bool, error) {
+	key := fmt.Sprintf("upscale:%s", imageID)
+	if _, err := q.CancelByDedupKey(key); err != nil {
+		return false, err
+	}
+	running, err := q.DedupKeyHeld(key)
+	if err != nil {
+		return false, err
+	}
+	return !running, nil
+}
+
+// EnqueueMockupRendering adds task rendering mockups of finished mosaic after its schema is generated
+func (q *ImageTaskQueue) EnqueueMockupRendering(imageID uuid.UUID) (string, error) {
+	payload := map[string]any{
+		"image_id": imageID.String(),
+	}
+
+	return q.Enqueue(TaskTypeMockupRendering, payload, WithPriority(1), WithMaxRetries(2),
+		WithDedupKey(fmt.Sprintf("mockup:%s", imageID), 0), q.share(imageID))
+}
+
+// EnqueueBulkGeneration adds task processing image of bulk job and generating its schema,
+// bulk tasks have lowest priority so they do not delay customers using public flow
+func (q *ImageTaskQueue) EnqueueBulkGeneration(imageID uuid.UUID, style string) (string, error) {
+	payload := map[string]any{
+		"image_id": imageID.String(),
+		"style":    style,
+	}
+
+	return q.Enqueue(TaskTypeBulkGeneration, payload, WithPriority(MinPriority), WithMaxRetries(2),
+		WithDedupKey(fmt.Sprintf("bulk:%s", imageID), 0), q.share(imageID))
+}
+
+// CancelImageTasks removes processing, AI processing, upscaling, schema and bulk generation tasks of image
+// that still wait in queue, returns number of removed tasks
+func (q *ImageTaskQueue) CancelImageTasks(imageID uuid.UUID) (int, error) {
+	cancelled := 0
+	for _, prefix := range []string{"process", "ai", "upscale", "schema", "bulk"} {
+		removed, err := q.CancelByDedupKey(fmt.Sprintf("%s:%s", prefix, imageID))
+		if err != nil {
+			return cancelled, err
+		}
+		if removed {
+			cancelled++
+		}
+	}
+	return cancelled, nil
 }
 
 // EnqueueAIProcessing adds AI image processing task via Stable Diffusion
@@ -93,7 +194,7 @@ func (q *ImageTaskQueue) EnqueueAIProcessing(
 	useAI bool,
 	parameters map[string]any,
 	priority int,
-) error {
+) (string, error) {
 	payload := map[string]any{
 		"image_id":   imageID.String(),
 		"user_email": userEmail,
@@ -111,7 +212,8 @@ func (q *ImageTaskQueue) EnqueueAIProcessing(
 		calculatedPriority = 6
 	}
 
-	return q.Enqueue(TaskTypeAIProcessing, payload, WithPriority(calculatedPriority), WithMaxRetries(3))
+	return q.Enqueue(TaskTypeAIProcessing, payload, WithPriority(calculatedPriority), WithMaxRetries(3),
+		WithDedupKey(fmt.Sprintf("ai:%s", imageID), 0), q.share(imageID))
 }
 
 // EnqueuePriorityAIProcessing adds priority AI processing task
@@ -121,7 +223,7 @@ func (q *ImageTaskQueue) EnqueuePriorityAIProcessing(
 	style string,
 	useAI bool,
 	parameters map[string]any,
-) error {
+) (string, error) {
 	payload := map[string]any{
 		"image_id":   imageID.String(),
 		"user_email": userEmail,
@@ -131,29 +233,39 @@ func (q *ImageTaskQueue) EnqueuePriorityAIProcessing(
 		"priority":   10, // Maximum priority
 	}
 
-	return q.Enqueue(TaskTypeAIPriority, payload, WithPriority(10), WithMaxRetries(3))
+	return q.Enqueue(TaskTypeAIPriority, payload, WithPriority(10), WithMaxRetries(3),
+		WithDedupKey(fmt.Sprintf("ai:%s", imageID), 0), q.share(imageID))
 }
 
-// GetImageTaskHandlers returns map of handlers for image processing tasks
+// GetImageTaskHandlers returns map of handlers for image processing tasks, keyed by task types used on enqueue
 func GetImageTaskHandlers(imageService *ImageServiceAdapter, emailService *EmailServiceAdapter, logger *middleware.Logger) map[string]TaskHandler {
 	return map[string]TaskHandler{
-		"process_image": func(ctx context.Context, task *Task) error {
+		TaskTypeImageProcessing: func(ctx context.Context, task *Task) error {
 			return handleProcessImage(ctx, task, imageService, logger)
 		},
-		"generate_schema": func(ctx context.Context, task *Task) error {
+		TaskTypeSchemaGeneration: func(ctx context.Context, task *Task) error {
 			return handleGenerateSchema(ctx, task, imageService, logger)
 		},
-		"send_schema": func(ctx context.Context, task *Task) error { return handleSendSchema(ctx, task, emailService) },
-		"optimize_image": func(ctx context.Context, task *Task) error {
+		TaskTypeEmailSending: func(ctx context.Context, task *Task) error { return handleSendSchema(ctx, task, emailService) },
+		TaskTypeImageOptimization: func(ctx context.Context, task *Task) error {
 			return handleOptimizeImage(ctx, task, imageService, logger)
 		},
-		"generate_thumbnails": func(ctx context.Context, task *Task) error {
+		TaskTypeThumbnailGeneration: func(ctx context.Context, task *Task) error {
 			return handleGenerateThumbnails(ctx, task, imageService, logger)
 		},
-		"ai_processing": func(ctx context.Context, task *Task) error {
+		TaskTypeImageUpscaling: func(ctx context.Context, task *Task) error {
+			return handleUpscaleImage(ctx, task, imageService, logger)
+		},
+		TaskTypeMockupRendering: func(ctx context.Context, task *Task) error {
+			return handleRenderMockups(ctx, task, imageService, logger)
+		},
+		TaskTypeAIProcessing: func(ctx context.Context, task *Task) error {
 			return handleAIProcessing(ctx, task, imageService, logger)
 		},
-		"ai_priority": func(ctx context.Context, task *Task) error { return handleAIPriority(ctx, task, imageService, logger) },
+		TaskTypeAIPriority: func(ctx context.Context, task *Task) error { return handleAIPriority(ctx, task, imageService, logger) },
+		TaskTypeBulkGeneration: func(ctx context.Context, task *Task) error {
+			return handleBulkGeneration(ctx, task, imageService, logger)
+		},
 	}
 }
 
@@ -164,7 +276,11 @@ type ImageService interface {
 	GenerateSchema(ctx context.Context, imageID uuid.UUID, confirmed bool) error
 	OptimizeImage(ctx context.Context, imageID uuid.UUID, quality int) error
 	GenerateThumbnails(ctx context.Context, imageID uuid.UUID, sizes []string) error
+	UpscaleImage(ctx context.Context, imageID uuid.UUID) error
+	RenderMockups(ctx context.Context, imageID uuid.UUID) error
 	ProcessImageWithAI(ctx context.Context, imageID uuid.UUID, style string, useAI bool, parameters map[string]any) error
+	ResetInterruptedProcessing(ctx context.Context, imageID uuid.UUID) error
+	GenerateWithStyle(ctx context.Context, imageID uuid.UUID, style string) error
 }
 
 type EmailService interface {
@@ -190,6 +306,10 @@ func handleProcessImage(ctx context.Context, task *Task, imageService *ImageServ
 	style, _ := payload["style"].(string)
 	parameters, _ := payload["parameters"].(map[string]any)
 
+	if err := resetRedeliveredProcessing(ctx, task, imageID, imageService, logger); err != nil {
+		return err
+	}
+
 	return imageService.ProcessImageWithStyle(ctx, imageID, style, parameters)
 }
 
@@ -236,7 +356,11 @@ func handleOptimizeImage(ctx context.Context, task *Task, imageService *ImageSer
 		return err
 	}
 
-	quality, _ := payload["quality"].(int)
+	// Numbers come back from JSON as float64
+	quality := 0
+	if q, ok := payload["quality"].(float64); ok {
+		quality = int(q)
+	}
 
 	return imageService.OptimizeImage(ctx, imageID, quality)
 }
@@ -255,11 +379,52 @@ func handleGenerateThumbnails(ctx context.Context, task *Task, imageService *Ima
 		return err
 	}
 
-	sizes, _ := payload["sizes"].([]string)
+	var sizes []string
+	if values, ok := payload["sizes"].([]any); ok {
+		for _, v := range values {
+			if size, ok := v.(string); ok {
+				sizes = append(sizes, size)
+			}
+		}
+	}
 
 	return imageService.GenerateThumbnails(ctx, imageID, sizes)
 }
 
+func handleUpscaleImage(ctx context.Context, task *Task, imageService *ImageServiceAdapter, logger *middleware.Logger) error {
+	payload := task.Payload
+
+	imageIDStr, ok := payload["image_id"].(string)
+	if !ok {
+		logger.GetZerologLogger().Error().Interface("payload", payload).Str("task_type", task.Type).Msg("Invalid image_id in upscale image task")
+		return fmt.Errorf("invalid image_id")
+	}
+
+	imageID, err := uuid.Parse(imageIDStr)
+	if err != nil {
+		return err
+	}
+
+	return imageService.UpscaleImage(ctx, imageID)
+}
+
+func handleRenderMockups(ctx context.Context, task *Task, imageService *ImageServiceAdapter, logger *middleware.Logger) error {
+	payload := task.Payload
+
+	imageIDStr, ok := payload["image_id"].(string)
+	if !ok {
+		logger.GetZerologLogger().Error().Interface("payload", payload).Str("task_type", task.Type).Msg("Invalid image_id in render mockups task")
+		return fmt.Errorf("invalid image_id")
+	}
+
+	imageID, err := uuid.Parse(imageIDStr)
+	if err != nil {
+		return err
+	}
+
+	return imageService.RenderMockups(ctx, imageID)
+}
+
 func handleAIProcessing(ctx context.Context, task *Task, imageService *ImageServiceAdapter, logger *middleware.Logger) error {
 	payload := task.Payload
 
@@ -277,9 +442,51 @@ func handleAIProcessing(ctx context.Context, task *Task, imageService *ImageServ
 	style, _ := payload["style"].(string)
 	parameters, _ := payload["parameters"].(map[string]any)
 
+	if err := resetRedeliveredProcessing(ctx, task, imageID, imageService, logger); err != nil {
+		return err
+	}
+
 	return imageService.ProcessImageWithStyle(ctx, imageID, style, parameters)
 }
 
+func handleBulkGeneration(ctx context.Context, task *Task, imageService *ImageServiceAdapter, logger *middleware.Logger) error {
+	payload := task.Payload
+
+	imageIDStr, ok := payload["image_id"].(string)
+	if !ok {
+		logger.GetZerologLogger().Error().Interface("payload", payload).Str("task_type", task.Type).Msg("Invalid image_id in bulk generation task")
+		return fmt.Errorf("invalid image_id")
+	}
+
+	imageID, err := uuid.Parse(imageIDStr)
+	if err != nil {
+		return err
+	}
+
+	style, _ := payload["style"].(string)
+
+	if err := resetRedeliveredProcessing(ctx, task, imageID, imageService, logger); err != nil {
+		return err
+	}
+
+	return imageService.GenerateWithStyle(ctx, imageID, style)
+}
+
+// resetRedeliveredProcessing resets image status left by worker that lost task lease
+func resetRedeliveredProcessing(ctx context.Context, task *Task, imageID uuid.UUID, imageService *ImageServiceAdapter, logger *middleware.Logger) error {
+	if task.Redelivered == 0 {
+		return nil
+	}
+
+	logger.GetZerologLogger().Warn().
+		Str("task_id", task.ID).
+		Str("image_id", imageID.String()).
+		Int("redelivered", task.Redelivered).
+		Msg("Processing redelivered task")
+
+	return imageService.ResetInterruptedProcessing(ctx, imageID)
+}
+
 // handleAIPriority processes priority AI processing task
 func handleAIPriority(ctx context.Context, task *Task, imageService *ImageServiceAdapter, logger *middleware.Logger) error {
 	return handleAIProcessing(ctx, task, imageService, logger)
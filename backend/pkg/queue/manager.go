@@ -2,6 +2,7 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -10,28 +11,36 @@ import (
 	"github.com/skr1ms/mosaic/pkg/middleware"
 )
 
+// DeadTaskRetention is time permanently failed tasks are kept in dead-letter store
+const DeadTaskRetention = 30 * 24 * time.Hour
+
+// ErrQueueNotFound is returned for unknown queue name
+var ErrQueueNotFound = errors.New("queue not found")
+
 type QueueManager struct {
-	redis      *redis.Client
-	queues     map[string]*TaskQueue
-	imageQueue *ImageTaskQueue
-	aiQueue    *ImageTaskQueue // Special queue for AI tasks
-	mu         sync.RWMutex
-	ctx        context.Context
-	cancel     context.CancelFunc
-	logger     *middleware.Logger
+	redis       *redis.Client
+	queues      map[string]*TaskQueue
+	imageQueue  *ImageTaskQueue
+	aiQueue     *ImageTaskQueue // Additional worker for AI tasks, reads the same "images" queue as imageQueue
+	mu          sync.RWMutex
+	dedupWindow time.Duration
+	ctx         context.Context
+	cancel      context.CancelFunc
+	logger      *middleware.Logger
 }
 
 func NewQueueManager(redis *redis.Client, logger *middleware.Logger) *QueueManager {
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return &QueueManager{
-		redis:      redis,
-		queues:     make(map[string]*TaskQueue),
-		imageQueue: NewImageTaskQueue(redis, logger),
-		aiQueue:    NewImageTaskQueue(redis, logger),
-		ctx:        ctx,
-		cancel:     cancel,
-		logger:     logger,
+		redis:       redis,
+		queues:      make(map[string]*TaskQueue),
+		imageQueue:  NewImageTaskQueue(redis, logger),
+		aiQueue:     NewImageTaskQueue(redis, logger),
+		dedupWindow: DefaultDedupWindow,
+		ctx:         ctx,
+		cancel:      cancel,
+		logger:      logger,
 	}
 }
 
@@ -55,12 +64,36 @@ func (qm *QueueManager) CreateQueue(name string) *TaskQueue {
 	}
 
 	queue := NewTaskQueue(name, qm.redis, qm.logger)
+	queue.SetDedupWindow(qm.dedupWindow)
 	qm.queues[name] = queue
 
 	qm.logger.GetZerologLogger().Info().Str("queue_name", name).Msg("Queue created")
 	return queue
 }
 
+// SetDedupWindow sets deduplication window of all queues, including queues created later
+func (qm *QueueManager) SetDedupWindow(window time.Duration) {
+	if window <= 0 {
+		return
+	}
+
+	qm.mu.Lock()
+	defer qm.mu.Unlock()
+
+	qm.dedupWindow = window
+	qm.imageQueue.SetDedupWindow(window)
+	qm.aiQueue.SetDedupWindow(window)
+	for _, queue := range qm.queues {
+		queue.SetDedupWindow(window)
+	}
+}
+
+// SetShareResolver makes image tasks share workers fairly between partners
+func (qm *QueueManager) SetShareResolver(resolver ShareResolver) {
+	qm.imageQueue.SetShareResolver(resolver)
+	qm.aiQueue.SetShareResolver(resolver)
+}
+
 // GetQueue returns queue by name
 func (qm *QueueManager) GetQueue(name string) *TaskQueue {
 	qm.mu.RLock()
@@ -71,19 +104,41 @@ func (qm *QueueManager) GetQueue(name string) *TaskQueue {
 
 // StartAllWorkers starts workers for all queues
 func (qm *QueueManager) StartAllWorkers(imageAdapter *ImageServiceAdapter, emailAdapter *EmailServiceAdapter) {
-	imageHandlers := GetImageTaskHandlers(imageAdapter, emailAdapter, qm.logger)
-	qm.imageQueue.StartWorker(imageHandlers)
+	if err := qm.StartWorkers(imageAdapter, emailAdapter, nil); err != nil {
+		qm.logger.GetZerologLogger().Error().Err(err).Msg("Failed to start queue workers")
+	}
+}
 
-	qm.aiQueue.StartWorker(imageHandlers)
+// StartWorkers starts workers only for given queues, empty list means all queues.
+// Unknown queue names are rejected before any worker is started.
+func (qm *QueueManager) StartWorkers(imageAdapter *ImageServiceAdapter, emailAdapter *EmailServiceAdapter, names []string) error {
+	if len(names) == 0 {
+		names = qm.QueueNames()
+	}
 
-	qm.mu.RLock()
-	for name, queue := range qm.queues {
+	queues := make(map[string]*TaskQueue, len(names))
+	for _, name := range names {
+		queue, err := qm.findQueue(name)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, name)
+		}
+		queues[name] = queue
+	}
+
+	for name := range queues {
 		qm.logger.GetZerologLogger().Info().Str("queue_name", name).Msg("Starting queue worker")
-		queue.StartWorker(map[string]TaskHandler{})
+		if name == qm.imageQueue.name {
+			imageHandlers := GetImageTaskHandlers(imageAdapter, emailAdapter, qm.logger)
+			qm.imageQueue.StartWorker(imageHandlers)
+
+			qm.aiQueue.StartWorker(imageHandlers)
+			continue
+		}
+		queues[name].StartWorker(map[string]TaskHandler{})
 	}
-	qm.mu.RUnlock()
 
-	qm.logger.GetZerologLogger().Info().Msg("All queue workers started")
+	qm.logger.GetZerologLogger().Info().Strs("queues", names).Msg("Queue workers started")
+	return nil
 }
 
 // StopAll stops all queues
@@ -109,21 +164,10 @@ func (qm *QueueManager) StopAll() {
 func (qm *QueueManager) GetStats() map[string]QueueStats {
 	stats := make(map[string]QueueStats)
 
-	// Statistics for image queue
-	imageStats := qm.getQueueStats("images")
-	stats["images"] = imageStats
-
-	// Statistics for AI queue
-	aiStats := qm.getQueueStats("ai_images")
-	stats["ai_images"] = aiStats
-
-	// Statistics for other queues
-	qm.mu.RLock()
-	for name := range qm.queues {
-		queueStats := qm.getQueueStats(name)
-		stats[name] = queueStats
+	// AI queue shares keys of image queue, so it is counted once under image queue name
+	for _, name := range qm.QueueNames() {
+		stats[name] = qm.getQueueStats(name)
 	}
-	qm.mu.RUnlock()
 
 	return stats
 }
@@ -135,6 +179,10 @@ type QueueStats struct {
 	DelayedTasks   int64  `json:"delayed_tasks"`
 	CompletedTasks int64  `json:"completed_tasks"`
 	FailedTasks    int64  `json:"failed_tasks"`
+	InFlightTasks  int64  `json:"in_flight_tasks"` // Tasks taken by workers and not acknowledged yet
+	ActiveWorkers  int64  `json:"active_workers"`  // Workers with valid lease
+	ExpiredLeases  int64  `json:"expired_leases"`  // Workers whose lease expired, their tasks are waiting to be requeued
+	OrphanedTasks  int64  `json:"orphaned_tasks"`  // In-flight tasks of workers with expired lease
 }
 
 // getQueueStats gets statistics for specific queue
@@ -145,13 +193,10 @@ func (qm *QueueManager) getQueueStats(queueName string) QueueStats {
 		Name: queueName,
 	}
 
-	// Count tasks in queue (by all priorities)
-	for priority := 0; priority <= 10; priority++ {
-		queueKey := fmt.Sprintf("queue:%s:priority:%d", queueName, priority)
-		count, err := qm.redis.LLen(ctx, queueKey).Result()
-		if err == nil {
-			stats.PendingTasks += count
-		}
+	// Count tasks in queue across pending sets of all groups
+	pendingCount, err := countPending(ctx, qm.redis, queueName)
+	if err == nil {
+		stats.PendingTasks = pendingCount
 	}
 
 	// Count delayed tasks
@@ -168,13 +213,35 @@ func (qm *QueueManager) getQueueStats(queueName string) QueueStats {
 		stats.CompletedTasks = completedCount
 	}
 
-	// Count failed tasks
-	failedKey := fmt.Sprintf("queue:%s:failed", queueName)
-	failedCount, err := qm.redis.ZCard(ctx, failedKey).Result()
+	// Count permanently failed tasks kept in dead-letter store
+	deadKey := fmt.Sprintf("queue:%s:dead", queueName)
+	failedCount, err := qm.redis.HLen(ctx, deadKey).Result()
 	if err == nil {
 		stats.FailedTasks = failedCount
 	}
 
+	// Count in-flight tasks per worker lease
+	workersKey := fmt.Sprintf("queue:%s:workers", queueName)
+	workers, err := qm.redis.ZRangeWithScores(ctx, workersKey, 0, -1).Result()
+	if err == nil {
+		now := float64(time.Now().Unix())
+		for _, worker := range workers {
+			inflightKey := fmt.Sprintf("queue:%s:inflight:%v", queueName, worker.Member)
+			inflightCount, err := qm.redis.LLen(ctx, inflightKey).Result()
+			if err != nil {
+				continue
+			}
+
+			stats.InFlightTasks += inflightCount
+			if worker.Score > now {
+				stats.ActiveWorkers++
+			} else {
+				stats.ExpiredLeases++
+				stats.OrphanedTasks += inflightCount
+			}
+		}
+	}
+
 	return stats
 }
 
@@ -182,16 +249,7 @@ func (qm *QueueManager) getQueueStats(queueName string) QueueStats {
 func (qm *QueueManager) CleanupOldTasks() error {
 	ctx := context.Background()
 
-	// Get all queue names
-	queueNames := []string{"images", "ai_images"}
-
-	qm.mu.RLock()
-	for name := range qm.queues {
-		queueNames = append(queueNames, name)
-	}
-	qm.mu.RUnlock()
-
-	for _, queueName := range queueNames {
+	for _, queueName := range qm.QueueNames() {
 		// Remove completed tasks older than 24 hours
 		completedKey := fmt.Sprintf("queue:%s:completed", queueName)
 		yesterday := time.Now().Add(-24 * time.Hour).Unix()
@@ -202,18 +260,82 @@ func (qm *QueueManager) CleanupOldTasks() error {
 		} else if removedCompleted > 0 {
 			qm.logger.GetZerologLogger().Info().Str("queue", queueName).Int64("removed", removedCompleted).Msg("Cleaned up completed tasks")
 		}
+	}
 
-		// Remove failed tasks older than 7 days
-		failedKey := fmt.Sprintf("queue:%s:failed", queueName)
-		weekAgo := time.Now().Add(-7 * 24 * time.Hour).Unix()
-
-		removedFailed, err := qm.redis.ZRemRangeByScore(ctx, failedKey, "-inf", fmt.Sprintf("%d", weekAgo)).Result()
+	// Remove dead tasks nobody replayed within retention period
+	for _, queueName := range qm.QueueNames() {
+		queue, err := qm.findQueue(queueName)
 		if err != nil {
-			qm.logger.GetZerologLogger().Error().Err(err).Str("queue", queueName).Msg("Failed to cleanup failed tasks")
-		} else if removedFailed > 0 {
-			qm.logger.GetZerologLogger().Info().Str("queue", queueName).Int64("removed", removedFailed).Msg("Cleaned up failed tasks")
+			continue
+		}
+		if _, err := queue.PurgeDeadTasksBefore(time.Now().Add(-DeadTaskRetention)); err != nil {
+			qm.logger.GetZerologLogger().Error().Err(err).Str("queue", queueName).Msg("Failed to cleanup dead tasks")
 		}
 	}
 
 	return nil
 }
+
+// QueueNames returns names of queues served by manager
+func (qm *QueueManager) QueueNames() []string {
+	names := []string{qm.imageQueue.name}
+
+	qm.mu.RLock()
+	for name := range qm.queues {
+		names = append(names, name)
+	}
+	qm.mu.RUnlock()
+
+	return names
+}
+
+// findQueue returns queue by name, image and AI queues share the same Redis keys
+func (qm *QueueManager) findQueue(name string) (*TaskQueue, error) {
+	if name == qm.imageQueue.name {
+		return qm.imageQueue.TaskQueue, nil
+	}
+
+	qm.mu.RLock()
+	defer qm.mu.RUnlock()
+
+	if queue, exists := qm.queues[name]; exists {
+		return queue, nil
+	}
+	return nil, ErrQueueNotFound
+}
+
+// ListDeadTasks returns dead tasks of queue, optionally filtered by task type
+func (qm *QueueManager) ListDeadTasks(queueName, taskType string, offset, limit int) ([]*DeadTask, int64, error) {
+	queue, err := qm.findQueue(queueName)
+	if err != nil {
+		return nil, 0, err
+	}
+	return queue.ListDeadTasks(taskType, offset, limit)
+}
+
+// GetDeadTask returns dead task of queue by ID
+func (qm *QueueManager) GetDeadTask(queueName, taskID string) (*DeadTask, error) {
+	queue, err := qm.findQueue(queueName)
+	if err != nil {
+		return nil, err
+	}
+	return queue.GetDeadTask(taskID)
+}
+
+// ReplayDeadTasks returns dead tasks of queue back for processing
+func (qm *QueueManager) ReplayDeadTasks(queueName, taskType string, taskIDs []string) (int, error) {
+	queue, err := qm.findQueue(queueName)
+	if err != nil {
+		return 0, err
+	}
+	return queue.ReplayDeadTasks(taskType, taskIDs)
+}
+
+// PurgeDeadTasks deletes dead tasks of queue
+func (qm *QueueManager) PurgeDeadTasks(queueName, taskType string, taskIDs []string) (int, error) {
+	queue, err := qm.findQueue(queueName)
+	if err != nil {
+		return 0, err
+	}
+	return queue.PurgeDeadTasks(taskType, taskIDs)
+}
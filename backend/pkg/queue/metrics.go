@@ -0,0 +1,204 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/redis/go-redis/v9"
+)
+
+// Task run results reported in run time histogram
+const (
+	runResultCompleted = "completed"
+	runResultFailed    = "failed"
+	runResultCancelled = "cancelled"
+)
+
+// Prometheus metrics of task processing, labelled by queue and task type
+var (
+	TasksEnqueuedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mosaic_queue_tasks_enqueued_total",
+			Help: "Total number of tasks enqueued, duplicates skipped by deduplication are not counted",
+		},
+		[]string{"queue", "task_type"},
+	)
+
+	TasksDequeuedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mosaic_queue_tasks_dequeued_total",
+			Help: "Total number of tasks taken by workers",
+		},
+		[]string{"queue", "task_type"},
+	)
+
+	TasksCompletedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mosaic_queue_tasks_completed_total",
+			Help: "Total number of tasks completed successfully",
+		},
+		[]string{"queue", "task_type"},
+	)
+
+	TasksRetriedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mosaic_queue_tasks_retried_total",
+			Help: "Total number of failed task attempts scheduled for retry",
+		},
+		[]string{"queue", "task_type"},
+	)
+
+	TasksFailedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mosaic_queue_tasks_failed_total",
+			Help: "Total number of tasks failed permanently and moved to dead-letter store",
+		},
+		[]string{"queue", "task_type"},
+	)
+
+	TaskWaitDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "mosaic_queue_task_wait_seconds",
+			Help:    "Time task spent in queue from enqueue or scheduled time until worker took it",
+			Buckets: []float64{0.1, 0.5, 1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+		},
+		[]string{"queue", "task_type"},
+	)
+
+	TaskRunDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "mosaic_queue_task_run_seconds",
+			Help:    "Time worker spent processing task",
+			Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300, 600},
+		},
+		[]string{"queue", "task_type", "result"},
+	)
+)
+
+// observeWait records time task waited in queue before worker took it
+func (q *TaskQueue) observeWait(task *Task) {
+	TasksDequeuedTotal.WithLabelValues(q.name, task.Type).Inc()
+
+	since := task.CreatedAt
+	if task.ScheduledAt != nil && task.ScheduledAt.After(since) {
+		since = *task.ScheduledAt
+	}
+	if since.IsZero() {
+		return
+	}
+	TaskWaitDuration.WithLabelValues(q.name, task.Type).Observe(max(0, time.Since(since).Seconds()))
+}
+
+// DepthCollector reports current number of tasks in every queue of manager on each scrape
+type DepthCollector struct {
+	manager *QueueManager
+
+	pending  *prometheus.Desc
+	delayed  *prometheus.Desc
+	inFlight *prometheus.Desc
+	dead     *prometheus.Desc
+	workers  *prometheus.Desc
+}
+
+var _ prometheus.Collector = (*DepthCollector)(nil)
+
+// NewDepthCollector creates collector of queue depth, it has to be registered once per process
+func NewDepthCollector(manager *QueueManager) *DepthCollector {
+	return &DepthCollector{
+		manager: manager,
+		pending: prometheus.NewDesc(
+			"mosaic_queue_pending_tasks",
+			"Number of tasks waiting for worker",
+			[]string{"queue", "priority"}, nil,
+		),
+		delayed: prometheus.NewDesc(
+			"mosaic_queue_delayed_tasks",
+			"Number of tasks scheduled for later, including retries",
+			[]string{"queue"}, nil,
+		),
+		inFlight: prometheus.NewDesc(
+			"mosaic_queue_in_flight_tasks",
+			"Number of tasks taken by workers and not acknowledged yet",
+			[]string{"queue"}, nil,
+		),
+		dead: prometheus.NewDesc(
+			"mosaic_queue_dead_tasks",
+			"Number of tasks kept in dead-letter store",
+			[]string{"queue"}, nil,
+		),
+		workers: prometheus.NewDesc(
+			"mosaic_queue_active_workers",
+			"Number of workers with valid lease",
+			[]string{"queue"}, nil,
+		),
+	}
+}
+
+func (c *DepthCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.pending
+	ch <- c.delayed
+	ch <- c.inFlight
+	ch <- c.dead
+	ch <- c.workers
+}
+
+func (c *DepthCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	for _, name := range c.manager.QueueNames() {
+		pending, err := c.manager.pendingByPriority(ctx, name)
+		if err != nil {
+			c.manager.logger.GetZerologLogger().Error().Err(err).Str("queue", name).Msg("Failed to collect queue depth")
+			continue
+		}
+		for priority := MinPriority; priority <= MaxPriority; priority++ {
+			ch <- prometheus.MustNewConstMetric(c.pending, prometheus.GaugeValue, float64(pending[priority]), name, strconv.Itoa(priority))
+		}
+
+		stats := c.manager.getQueueStats(name)
+		ch <- prometheus.MustNewConstMetric(c.delayed, prometheus.GaugeValue, float64(stats.DelayedTasks), name)
+		ch <- prometheus.MustNewConstMetric(c.inFlight, prometheus.GaugeValue, float64(stats.InFlightTasks), name)
+		ch <- prometheus.MustNewConstMetric(c.dead, prometheus.GaugeValue, float64(stats.FailedTasks), name)
+		ch <- prometheus.MustNewConstMetric(c.workers, prometheus.GaugeValue, float64(stats.ActiveWorkers), name)
+	}
+}
+
+// pendingByPriority counts pending tasks of queue per priority, each priority owns its own score range
+// in pending set of every group
+func (qm *QueueManager) pendingByPriority(ctx context.Context, queueName string) (map[int]int64, error) {
+	keys, err := pendingKeys(ctx, qm.redis, queueName)
+	if err != nil {
+		return nil, err
+	}
+
+	pipe := qm.redis.Pipeline()
+	counts := make(map[int][]*redis.IntCmd, MaxPriority-MinPriority+1)
+	for priority := MinPriority; priority <= MaxPriority; priority++ {
+		weight := int64(priorityWeight(priority))
+		for _, pendingKey := range keys {
+			counts[priority] = append(counts[priority], pipe.ZCount(ctx, pendingKey,
+				strconv.FormatInt(weight*1e12, 10),
+				"("+strconv.FormatInt((weight+1)*1e12, 10),
+			))
+		}
+	}
+	if len(keys) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to count pending tasks: %w", err)
+		}
+	}
+
+	result := make(map[int]int64, len(counts))
+	for priority := MinPriority; priority <= MaxPriority; priority++ {
+		result[priority] = 0
+		for _, cmd := range counts[priority] {
+			result[priority] += cmd.Val()
+		}
+	}
+	return result, nil
+}
@@ -3,7 +3,9 @@ package queue
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/google/uuid"
@@ -11,12 +13,200 @@ import (
 	"github.com/skr1ms/mosaic/pkg/middleware"
 )
 
+const (
+	// DefaultLeaseTimeout is time after which in-flight tasks of worker that stopped sending heartbeats are returned to queue
+	DefaultLeaseTimeout = 2 * time.Minute
+
+	// DefaultDedupWindow is time during which task with the same deduplication key is not enqueued again
+	DefaultDedupWindow = 10 * time.Minute
+
+	// MaxRedeliveries is how many times task is returned to queue after its worker died, task that
+	// kills every worker taking it is moved to dead-letter store instead
+	MaxRedeliveries = 3
+
+	// FairStride is pass added to group for each claimed task of weight 1, heavier groups advance slower
+	FairStride = 1000
+
+	MinPriority = 0
+	MaxPriority = 10
+)
+
+// fairLua keeps pending tasks in separate set per group (partner), so group with hundreds of tasks
+// can not hold workers while tasks of other groups wait. Tasks without group share default set.
+// Within group set score orders tasks by priority first and by enqueue sequence within priority.
+// Groups set orders groups by priority of their first task and then by pass of group: each claim
+// advances pass of group by FairStride divided by weight of group, so group with weight 2 gets two
+// tasks claimed for every task of group with weight 1 (stride scheduling).
+// KEYS[1] - default pending set and prefix of group pending sets, KEYS[2] - notifications list,
+// KEYS[3] - sequence counter, KEYS[4] - task index, KEYS[5] - groups set, KEYS[6] - passes and weights of groups
+const fairLua = `
+local function decode(data)
+	local ok, task = pcall(cjson.decode, data)
+	if ok and type(task) == 'table' then
+		return task
+	end
+	return {}
+end
+
+local function taskGroup(task)
+	if type(task.group) == 'string' then
+		return task.group
+	end
+	return ''
+end
+
+local function groupKey(group)
+	if group == '' then
+		return KEYS[1]
+	end
+	return KEYS[1] .. ':' .. group
+end
+
+local function groupPass(group)
+	return tonumber(redis.call('HGET', KEYS[6], 'pass:' .. group) or 0)
+end
+
+-- refresh places group in groups set by priority of its first task, group without tasks leaves the set
+local function refresh(group)
+	local head = redis.call('ZRANGE', groupKey(group), 0, 0)
+	if #head == 0 then
+		redis.call('ZREM', KEYS[5], group)
+		return
+	end
+	-- priority weight as in priorityWeight, priorities are MinPriority 0 .. MaxPriority 10
+	local priority = tonumber(decode(head[1]).priority) or 0
+	local weight = 10 - math.max(0, math.min(10, priority))
+	redis.call('ZADD', KEYS[5], weight * 1e12 + groupPass(group), group)
+end
+`
+
+// pushLua adds task to pending set of its group, tasks pushed to front get zero sequence and are taken
+// before other tasks of their priority in group. Group that had no waiting tasks joins at current virtual
+// time, so it does not get credit for time it was idle.
+// Every push leaves wake-up token for workers blocked in Dequeue, so there is exactly one token per pending task.
+// Index maps task ID to its stored data, so waiting task can be removed without scanning the queue.
+const pushLua = fairLua + `
+local function push(data, weight, front)
+	local task = decode(data)
+	local group = taskGroup(task)
+
+	if not redis.call('ZSCORE', KEYS[5], group) then
+		local vtime = tonumber(redis.call('HGET', KEYS[6], 'vtime') or 0)
+		if groupPass(group) < vtime then
+			redis.call('HSET', KEYS[6], 'pass:' .. group, vtime)
+		end
+	end
+	if type(task.weight) == 'number' and task.weight > 0 then
+		redis.call('HSET', KEYS[6], 'weight:' .. group, task.weight)
+	end
+
+	local seq = 0
+	if not front then
+		seq = redis.call('INCR', KEYS[3])
+	end
+	redis.call('ZADD', groupKey(group), tonumber(weight) * 1e12 + seq, data)
+	redis.call('LPUSH', KEYS[2], 1)
+	if type(task.id) == 'string' then
+		redis.call('HSET', KEYS[4], task.id, data)
+	end
+	refresh(group)
+end
+`
+
+// enqueueScript adds task to pending set. ARGV[1] - task data, ARGV[2] - priority weight
+var enqueueScript = redis.NewScript(pushLua + `
+push(ARGV[1], ARGV[2], false)
+return 1
+`)
+
+// promoteScript moves due delayed task to pending set unless another worker did it already.
+// KEYS[7] - delayed set, ARGV[1] - task data, ARGV[2] - priority weight
+var promoteScript = redis.NewScript(pushLua + `
+if redis.call('ZREM', KEYS[7], ARGV[1]) == 1 then
+	push(ARGV[1], ARGV[2], false)
+	return 1
+end
+return 0
+`)
+
+// requeueScript returns in-flight task to front of its priority in its group unless it was acknowledged meanwhile.
+// KEYS[7] - in-flight list, ARGV[1] - stored task data, ARGV[2] - priority weight, ARGV[3] - updated task data
+var requeueScript = redis.NewScript(pushLua + `
+if redis.call('LREM', KEYS[7], 1, ARGV[1]) == 1 then
+	push(ARGV[3], ARGV[2], true)
+	return 1
+end
+return 0
+`)
+
+// migrateScript moves one task from list of previous per-priority queue layout to pending set.
+// KEYS[7] - legacy priority list, ARGV[1] - priority weight
+var migrateScript = redis.NewScript(pushLua + `
+local data = redis.call('RPOP', KEYS[7])
+if data then
+	push(data, ARGV[1], false)
+	return 1
+end
+return 0
+`)
+
+// claimScript atomically moves task with highest priority from group whose turn it is to in-flight list
+// of worker and takes its wake-up token, unless worker already took one with BLPOP.
+// KEYS[7] - in-flight list, ARGV[1] - "1" when token of claimed task is already taken, ARGV[2] - FairStride
+var claimScript = redis.NewScript(fairLua + `
+local group, items
+repeat
+	local groups = redis.call('ZRANGE', KEYS[5], 0, 0)
+	if #groups == 0 then
+		return false
+	end
+	group = groups[1]
+	items = redis.call('ZPOPMIN', groupKey(group))
+	if #items == 0 then
+		redis.call('ZREM', KEYS[5], group)
+	end
+until #items > 0
+
+local pass = groupPass(group)
+local weight = tonumber(redis.call('HGET', KEYS[6], 'weight:' .. group) or 1)
+redis.call('HSET', KEYS[6], 'vtime', pass)
+redis.call('HSET', KEYS[6], 'pass:' .. group, pass + math.ceil(tonumber(ARGV[2]) / weight))
+refresh(group)
+
+if ARGV[1] ~= '1' then
+	redis.call('RPOP', KEYS[2])
+end
+redis.call('LPUSH', KEYS[7], items[1])
+local task = decode(items[1])
+if type(task.id) == 'string' then
+	redis.call('HDEL', KEYS[4], task.id)
+end
+return items[1]
+`)
+
+// activateScript puts default group into groups set, for tasks stored in pending set before groups existed
+var activateScript = redis.NewScript(fairLua + `
+refresh('')
+return 1
+`)
+
+// delayScript adds task to delayed set and to task index.
+// KEYS[1] - delayed set, KEYS[2] - task index, ARGV[1] - task data, ARGV[2] - due time, ARGV[3] - task ID
+var delayScript = redis.NewScript(`
+redis.call('ZADD', KEYS[1], ARGV[2], ARGV[1])
+redis.call('HSET', KEYS[2], ARGV[3], ARGV[1])
+return 1
+`)
+
 type TaskQueue struct {
-	name              string
-	redisClient       *redis.Client
-	ctx               context.Context
-	cancel            context.CancelFunc
-	logger            *middleware.Logger
+	name         string
+	redisClient  *redis.Client
+	ctx          context.Context
+	cancel       context.CancelFunc
+	logger       *middleware.Logger
+	workerID     string
+	leaseTimeout time.Duration
+	dedupWindow  time.Duration
 }
 
 // Task represents task in queue
@@ -31,6 +221,15 @@ type Task struct {
 	ScheduledAt *time.Time     `json:"scheduled_at,omitempty"`
 	ProcessedAt *time.Time     `json:"processed_at,omitempty"`
 	Error       string         `json:"error,omitempty"`
+	Redelivered int            `json:"redelivered,omitempty"` // Times task was returned to queue after worker lease expired
+	Attempts    []TaskAttempt  `json:"attempts,omitempty"`    // History of failed attempts
+	DedupKey    string         `json:"dedup_key,omitempty"`   // Key that prevents enqueueing the same work twice
+	Group       string         `json:"group,omitempty"`       // Group that shares workers fairly with other groups, e.g. partner
+	Weight      int            `json:"weight,omitempty"`      // Share of workers of group relative to other groups
+
+	dedupWindow time.Duration // Deduplication window of this task, queue default when zero
+
+	raw string // Task data as stored in in-flight list, used to acknowledge task
 }
 
 // TaskHandler function for task processing
@@ -40,18 +239,44 @@ func NewTaskQueue(name string, redisClient *redis.Client, logger *middleware.Log
 	ctx, cancel := context.WithCancel(context.Background())
 
 	queue := &TaskQueue{
-		name:        name,
-		redisClient: redisClient,
-		ctx:         ctx,
-		cancel:      cancel,
-		logger:      logger,
+		name:         name,
+		redisClient:  redisClient,
+		ctx:          ctx,
+		cancel:       cancel,
+		logger:       logger,
+		workerID:     newWorkerID(),
+		leaseTimeout: DefaultLeaseTimeout,
+		dedupWindow:  DefaultDedupWindow,
 	}
 
 	return queue
 }
 
-// Enqueue adds task to queue
-func (q *TaskQueue) Enqueue(taskType string, payload map[string]any, opts ...TaskOption) error {
+// SetLeaseTimeout changes lease timeout of worker, must be called before StartWorker
+func (q *TaskQueue) SetLeaseTimeout(timeout time.Duration) {
+	if timeout > 0 {
+		q.leaseTimeout = timeout
+	}
+}
+
+// WorkerID returns identifier of this queue worker
+func (q *TaskQueue) WorkerID() string {
+	return q.workerID
+}
+
+func newWorkerID() string {
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "worker"
+	}
+	return fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), uuid.New().String()[:8])
+}
+
+// Enqueue adds task to queue and returns its ID. When deduplication key of task was already used
+// within deduplication window, nothing is enqueued and ID of the existing task is returned. Existing
+// task that still waits for worker gets payload of the new task, so repeated request with other
+// parameters is not lost.
+func (q *TaskQueue) Enqueue(taskType string, payload map[string]any, opts ...TaskOption) (string, error) {
 	task := &Task{
 		ID:         uuid.New().String(),
 		Type:       taskType,
@@ -66,8 +291,38 @@ func (q *TaskQueue) Enqueue(taskType string, payload map[string]any, opts ...Tas
 		opt(task)
 	}
 
-	queueKey := q.getQueueKey(task.Priority)
+	task.Priority = clampPriority(task.Priority)
+
+	if task.DedupKey != "" {
+		existingID, err := q.reserveDedupKey(task)
+		if err != nil {
+			return "", err
+		}
+		if existingID != "" {
+			if err := q.replaceWaitingPayload(existingID, task); err != nil {
+				return "", err
+			}
+			q.logger.GetZerologLogger().Info().
+				Str("task_id", existingID).
+				Str("task_type", task.Type).
+				Str("queue", q.name).
+				Str("dedup_key", task.DedupKey).
+				Msg("Duplicate task skipped")
+			return existingID, nil
+		}
+	}
 
+	if err := q.enqueueTask(task); err != nil {
+		q.releaseDedupKey(task)
+		return "", err
+	}
+	TasksEnqueuedTotal.WithLabelValues(q.name, task.Type).Inc()
+
+	return task.ID, nil
+}
+
+// enqueueTask adds task to pending set or to delayed set when it is scheduled for later
+func (q *TaskQueue) enqueueTask(task *Task) error {
 	if task.ScheduledAt != nil && task.ScheduledAt.After(time.Now()) {
 		return q.enqueueDelayed(task)
 	}
@@ -78,7 +333,7 @@ func (q *TaskQueue) Enqueue(taskType string, payload map[string]any, opts ...Tas
 		return fmt.Errorf("failed to marshal task: %w", err)
 	}
 
-	err = q.redisClient.LPush(q.ctx, queueKey, taskData).Err()
+	err = enqueueScript.Run(q.ctx, q.redisClient, q.pushKeys(), taskData, priorityWeight(task.Priority)).Err()
 	if err != nil {
 		q.logger.GetZerologLogger().Error().Err(err).Str("task_id", task.ID).Str("task_type", task.Type).Str("queue", q.name).Msg("Failed to enqueue task")
 		return fmt.Errorf("failed to enqueue task: %w", err)
@@ -94,6 +349,133 @@ func (q *TaskQueue) Enqueue(taskType string, payload map[string]any, opts ...Tas
 	return nil
 }
 
+// reserveDedupKey binds deduplication key to task for deduplication window,
+// returns ID of task that holds the key already or empty string when key was free
+func (q *TaskQueue) reserveDedupKey(task *Task) (string, error) {
+	window := task.dedupWindow
+	if window <= 0 {
+		window = q.dedupWindow
+	}
+
+	existingID, err := q.redisClient.SetArgs(q.ctx, q.getDedupKey(task.DedupKey), task.ID, redis.SetArgs{
+		Mode: "NX",
+		Get:  true,
+		TTL:  window,
+	}).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	if err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("dedup_key", task.DedupKey).Str("queue", q.name).Msg("Failed to reserve deduplication key")
+		return "", fmt.Errorf("failed to reserve deduplication key: %w", err)
+	}
+
+	return existingID, nil
+}
+
+// replacePayloadScript swaps data of waiting task in pending or delayed set keeping its place in queue,
+// nothing is changed when task was claimed or changed meanwhile.
+// KEYS[7] - delayed set, ARGV[1] - stored task data, ARGV[2] - updated task data, ARGV[3] - task ID
+var replacePayloadScript = redis.NewScript(fairLua + `
+if redis.call('HGET', KEYS[4], ARGV[3]) ~= ARGV[1] then
+	return 0
+end
+
+local key = groupKey(taskGroup(decode(ARGV[1])))
+local score = redis.call('ZSCORE', key, ARGV[1])
+if not score then
+	key = KEYS[7]
+	score = redis.call('ZSCORE', key, ARGV[1])
+	if not score then
+		return 0
+	end
+end
+redis.call('ZREM', key, ARGV[1])
+redis.call('ZADD', key, score, ARGV[2])
+redis.call('HSET', KEYS[4], ARGV[3], ARGV[2])
+return 1
+`)
+
+// replaceWaitingPayload gives payload of duplicate task to task with given ID while that task waits for worker.
+// Payload of task that is already being processed can not change.
+func (q *TaskQueue) replaceWaitingPayload(taskID string, duplicate *Task) error {
+	stored, err := q.redisClient.HGet(q.ctx, q.getIndexKey(), taskID).Result()
+	if err == redis.Nil {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get waiting task: %w", err)
+	}
+
+	var task Task
+	if err := json.Unmarshal([]byte(stored), &task); err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("task_id", taskID).Msg("Failed to unmarshal waiting task")
+		return nil
+	}
+
+	current, err := json.Marshal(task.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+	requested, err := json.Marshal(duplicate.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task payload: %w", err)
+	}
+	if string(current) == string(requested) {
+		return nil
+	}
+
+	task.Payload = duplicate.Payload
+	updated, err := json.Marshal(&task)
+	if err != nil {
+		return fmt.Errorf("failed to marshal task: %w", err)
+	}
+
+	keys := append(q.pushKeys(), q.getDelayedKey())
+	replaced, err := replacePayloadScript.Run(q.ctx, q.redisClient, keys, stored, updated, taskID).Int()
+	if err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("task_id", taskID).Str("dedup_key", duplicate.DedupKey).Msg("Failed to replace payload of waiting task")
+		return fmt.Errorf("failed to replace payload of waiting task: %w", err)
+	}
+	if replaced == 1 {
+		q.logger.GetZerologLogger().Info().
+			Str("task_id", taskID).
+			Str("task_type", task.Type).
+			Str("queue", q.name).
+			Str("dedup_key", duplicate.DedupKey).
+			Msg("Payload of waiting task replaced by duplicate")
+	}
+
+	return nil
+}
+
+// releaseDedupScript deletes deduplication key only while it still holds given task.
+// KEYS[1] - deduplication key, ARGV[1] - task ID
+var releaseDedupScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// releaseDedupKey frees deduplication key of task that was not enqueued or is finished,
+// key reserved meanwhile by another task is kept
+func (q *TaskQueue) releaseDedupKey(task *Task) {
+	if task.DedupKey == "" {
+		return
+	}
+	if err := releaseDedupScript.Run(q.ctx, q.redisClient, []string{q.getDedupKey(task.DedupKey)}, task.ID).Err(); err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("dedup_key", task.DedupKey).Msg("Failed to release deduplication key")
+	}
+}
+
+// SetDedupWindow changes default time during which tasks with the same deduplication key are not enqueued again
+func (q *TaskQueue) SetDedupWindow(window time.Duration) {
+	if window > 0 {
+		q.dedupWindow = window
+	}
+}
+
 // enqueueDelayed adds delayed task
 func (q *TaskQueue) enqueueDelayed(task *Task) error {
 	taskData, err := json.Marshal(task)
@@ -103,13 +485,9 @@ func (q *TaskQueue) enqueueDelayed(task *Task) error {
 	}
 
 	delayedKey := q.getDelayedKey()
-	score := float64(task.ScheduledAt.Unix())
-
-	err = q.redisClient.ZAdd(q.ctx, delayedKey, redis.Z{
-		Score:  score,
-		Member: taskData,
-	}).Err()
+	keys := []string{delayedKey, q.getIndexKey()}
 
+	err = delayScript.Run(q.ctx, q.redisClient, keys, taskData, task.ScheduledAt.Unix(), task.ID).Err()
 	if err != nil {
 		q.logger.GetZerologLogger().Error().Err(err).Str("task_id", task.ID).Str("task_type", task.Type).Str("delayed_key", delayedKey).Msg("Failed to enqueue delayed task")
 		return fmt.Errorf("failed to enqueue delayed task: %w", err)
@@ -124,33 +502,194 @@ func (q *TaskQueue) enqueueDelayed(task *Task) error {
 	return nil
 }
 
+// Dequeue atomically moves task with highest priority into in-flight list of this worker. Task stays
+// there until it is acknowledged by MarkCompleted or MarkFailed, or until worker lease expires.
+// When queue is empty it waits for new task at most timeout.
 func (q *TaskQueue) Dequeue(timeout time.Duration) (*Task, error) {
-	for priority := 10; priority >= 0; priority-- {
-		queueKey := q.getQueueKey(priority)
+	if err := q.Heartbeat(); err != nil {
+		return nil, err
+	}
+
+	task, err := q.claim(false)
+	if err != nil || task != nil {
+		return task, err
+	}
+
+	// Single blocking wait for any enqueue, regardless of task priority
+	if err := q.redisClient.BLPop(q.ctx, timeout, q.getNotifyKey()).Err(); err != nil {
+		if err == redis.Nil {
+			return nil, nil
+		}
+		q.logger.GetZerologLogger().Error().Err(err).Str("queue", q.name).Msg("Failed to wait for task")
+		return nil, fmt.Errorf("failed to wait for task: %w", err)
+	}
+
+	// Token taken by BLPOP belongs to task claimed next. When another worker was faster and
+	// pending set is empty, token is stale and is dropped.
+	return q.claim(true)
+}
 
-		result, err := q.redisClient.BRPop(q.ctx, timeout, queueKey).Result()
+// claim takes task with highest priority without blocking, returns nil when queue is empty.
+// tokenTaken tells that wake-up token of this task was already taken from notifications list.
+func (q *TaskQueue) claim(tokenTaken bool) (*Task, error) {
+	inflightKey := q.getInflightKey(q.workerID)
+
+	for {
+		taken := "0"
+		if tokenTaken {
+			taken = "1"
+		}
+		taskData, err := claimScript.Run(q.ctx, q.redisClient, append(q.pushKeys(), inflightKey), taken, FairStride).Text()
+		tokenTaken = false
 		if err != nil {
 			if err == redis.Nil {
-				continue
+				return nil, nil
 			}
-			q.logger.GetZerologLogger().Error().Err(err).Str("queue", q.name).Int("priority", priority).Msg("Failed to dequeue task")
+			q.logger.GetZerologLogger().Error().Err(err).Str("queue", q.name).Msg("Failed to dequeue task")
 			return nil, fmt.Errorf("failed to dequeue task: %w", err)
 		}
 
-		if len(result) != 2 {
-			continue
-		}
-
 		var task Task
-		if err := json.Unmarshal([]byte(result[1]), &task); err != nil {
+		if err := json.Unmarshal([]byte(taskData), &task); err != nil {
 			q.logger.GetZerologLogger().Error().Err(err).Msg("Failed to unmarshal task")
+			q.redisClient.LRem(q.ctx, inflightKey, 1, taskData)
 			continue
 		}
+		task.raw = taskData
+		q.observeWait(&task)
 
 		return &task, nil
 	}
+}
+
+// Heartbeat extends lease of this worker
+func (q *TaskQueue) Heartbeat() error {
+	deadline := time.Now().Add(q.leaseTimeout)
+
+	err := q.redisClient.ZAdd(q.ctx, q.getWorkersKey(), redis.Z{
+		Score:  float64(deadline.Unix()),
+		Member: q.workerID,
+	}).Err()
+	if err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("queue", q.name).Str("worker_id", q.workerID).Msg("Failed to extend worker lease")
+		return fmt.Errorf("failed to extend worker lease: %w", err)
+	}
+
+	return nil
+}
+
+// release removes acknowledged task from in-flight list of this worker
+func (q *TaskQueue) release(task *Task) {
+	if task.raw == "" {
+		return
+	}
+
+	if err := q.redisClient.LRem(q.ctx, q.getInflightKey(q.workerID), 1, task.raw).Err(); err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("task_id", task.ID).Str("worker_id", q.workerID).Msg("Failed to remove task from in-flight list")
+	}
+	task.raw = ""
+}
+
+// forgetWorkerScript removes expired worker without in-flight tasks unless it has renewed its lease
+var forgetWorkerScript = redis.NewScript(`
+local score = redis.call('ZSCORE', KEYS[1], ARGV[1])
+if score and tonumber(score) <= tonumber(ARGV[2]) and redis.call('LLEN', KEYS[2]) == 0 then
+	redis.call('ZREM', KEYS[1], ARGV[1])
+	return 1
+end
+return 0
+`)
+
+// RequeueExpiredLeases returns in-flight tasks of workers whose lease expired back to queue,
+// tasks redelivered more than MaxRedeliveries times are moved to dead-letter store
+func (q *TaskQueue) RequeueExpiredLeases() (int, error) {
+	workersKey := q.getWorkersKey()
+	now := time.Now().Unix()
+
+	expiredWorkers, err := q.redisClient.ZRangeByScore(q.ctx, workersKey, &redis.ZRangeBy{
+		Min: "-inf",
+		Max: fmt.Sprintf("%d", now),
+	}).Result()
+	if err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("queue", q.name).Msg("Failed to get expired workers")
+		return 0, fmt.Errorf("failed to get expired workers: %w", err)
+	}
+
+	requeued := 0
+	for _, workerID := range expiredWorkers {
+		inflightKey := q.getInflightKey(workerID)
+
+		tasks, err := q.redisClient.LRange(q.ctx, inflightKey, 0, -1).Result()
+		if err != nil {
+			q.logger.GetZerologLogger().Error().Err(err).Str("worker_id", workerID).Msg("Failed to get in-flight tasks")
+			continue
+		}
+
+		for _, taskData := range tasks {
+			var task Task
+			if err := json.Unmarshal([]byte(taskData), &task); err != nil {
+				q.logger.GetZerologLogger().Error().Err(err).Str("worker_id", workerID).Msg("Failed to unmarshal in-flight task")
+				q.redisClient.LRem(q.ctx, inflightKey, 1, taskData)
+				continue
+			}
+
+			task.Redelivered++
+			if task.Redelivered > MaxRedeliveries {
+				now := time.Now()
+				task.ProcessedAt = &now
+				task.Error = fmt.Sprintf("worker lease expired %d times", task.Redelivered)
+				task.Attempts = append(task.Attempts, TaskAttempt{
+					Attempt:  task.Retries + 1,
+					Error:    task.Error,
+					WorkerID: workerID,
+					FailedAt: now,
+				})
+
+				buried, err := q.buryExpired(&task, inflightKey, taskData)
+				if err != nil {
+					continue
+				}
+				if buried {
+					TasksFailedTotal.WithLabelValues(q.name, task.Type).Inc()
+					q.logger.GetZerologLogger().Error().
+						Str("task_id", task.ID).
+						Str("task_type", task.Type).
+						Str("worker_id", workerID).
+						Int("redelivered", task.Redelivered).
+						Msg("Task moved to dead-letter store after repeated worker loss")
+				}
+				continue
+			}
+
+			newData, err := json.Marshal(&task)
+			if err != nil {
+				q.logger.GetZerologLogger().Error().Err(err).Str("task_id", task.ID).Msg("Failed to marshal requeued task")
+				continue
+			}
+
+			keys := append(q.pushKeys(), inflightKey)
+			moved, err := requeueScript.Run(q.ctx, q.redisClient, keys, taskData, priorityWeight(task.Priority), newData).Int()
+			if err != nil {
+				q.logger.GetZerologLogger().Error().Err(err).Str("task_id", task.ID).Msg("Failed to requeue task with expired lease")
+				continue
+			}
+			if moved == 1 {
+				requeued++
+				q.logger.GetZerologLogger().Warn().
+					Str("task_id", task.ID).
+					Str("task_type", task.Type).
+					Str("worker_id", workerID).
+					Int("redelivered", task.Redelivered).
+					Msg("Task requeued after worker lease expired")
+			}
+		}
+
+		if err := forgetWorkerScript.Run(q.ctx, q.redisClient, []string{workersKey, inflightKey}, workerID, now).Err(); err != nil {
+			q.logger.GetZerologLogger().Error().Err(err).Str("worker_id", workerID).Msg("Failed to remove expired worker")
+		}
+	}
 
-	return nil, nil
+	return requeued, nil
 }
 
 func (q *TaskQueue) ProcessDelayedTasks() error {
@@ -176,16 +715,15 @@ func (q *TaskQueue) ProcessDelayedTasks() error {
 			continue
 		}
 
-		queueKey := q.getQueueKey(task.Priority)
-
-		pipe := q.redisClient.Pipeline()
-		pipe.ZRem(q.ctx, delayedKey, taskData)
-		pipe.LPush(q.ctx, queueKey, taskData)
-
-		if _, err := pipe.Exec(q.ctx); err != nil {
+		keys := append(q.pushKeys(), delayedKey)
+		moved, err := promoteScript.Run(q.ctx, q.redisClient, keys, taskData, priorityWeight(task.Priority)).Int()
+		if err != nil {
 			q.logger.GetZerologLogger().Error().Err(err).Str("task_id", task.ID).Msg("Failed to move delayed task")
 			continue
 		}
+		if moved == 0 {
+			continue
+		}
 
 		q.logger.GetZerologLogger().Info().
 			Str("task_id", task.ID).
@@ -222,6 +760,10 @@ func (q *TaskQueue) MarkCompleted(task *Task) error {
 	// Set TTL on key
 	q.redisClient.Expire(q.ctx, completedKey, 24*time.Hour)
 
+	q.release(task)
+	q.releaseDedupKey(task)
+	TasksCompletedTotal.WithLabelValues(q.name, task.Type).Inc()
+
 	q.logger.GetZerologLogger().Info().
 		Str("task_id", task.ID).
 		Str("task_type", task.Type).
@@ -236,6 +778,12 @@ func (q *TaskQueue) MarkFailed(task *Task, err error) error {
 	task.Error = err.Error()
 	now := time.Now()
 	task.ProcessedAt = &now
+	task.Attempts = append(task.Attempts, TaskAttempt{
+		Attempt:  task.Retries,
+		Error:    task.Error,
+		WorkerID: q.workerID,
+		FailedAt: now,
+	})
 
 	// If there are more attempts, return to queue with delay
 	if task.Retries < task.MaxRetries {
@@ -244,29 +792,19 @@ func (q *TaskQueue) MarkFailed(task *Task, err error) error {
 		task.ScheduledAt = &scheduledAt
 		task.ProcessedAt = nil
 
-		return q.enqueueDelayed(task)
-	}
-
-	// Otherwise add to failed set
-	failedKey := q.getFailedKey()
-	taskData, marshalErr := json.Marshal(task)
-	if marshalErr != nil {
-		q.logger.GetZerologLogger().Error().Err(marshalErr).Str("task_id", task.ID).Str("task_type", task.Type).Msg("Failed to marshal failed task")
-		return fmt.Errorf("failed to marshal failed task: %w", marshalErr)
+		if err := q.enqueueDelayed(task); err != nil {
+			return err
+		}
+		q.release(task)
+		TasksRetriedTotal.WithLabelValues(q.name, task.Type).Inc()
+		return nil
 	}
 
-	redisErr := q.redisClient.ZAdd(q.ctx, failedKey, redis.Z{
-		Score:  float64(now.Unix()),
-		Member: taskData,
-	}).Err()
-
-	if redisErr != nil {
-		q.logger.GetZerologLogger().Error().Err(redisErr).Str("task_id", task.ID).Str("task_type", task.Type).Msg("Failed to mark task as failed")
-		return fmt.Errorf("failed to mark task as failed: %w", redisErr)
+	// Otherwise keep task in dead-letter store for inspection and replay
+	if err := q.moveToDeadLetter(task); err != nil {
+		return err
 	}
-
-	// Set TTL on key
-	q.redisClient.Expire(q.ctx, failedKey, 7*24*time.Hour) // 7 days
+	TasksFailedTotal.WithLabelValues(q.name, task.Type).Inc()
 
 	q.logger.GetZerologLogger().Error().
 		Err(err).
@@ -278,7 +816,41 @@ func (q *TaskQueue) MarkFailed(task *Task, err error) error {
 	return nil
 }
 
+// MigrateLegacyQueues moves tasks left in per-priority lists of previous queue layout to pending set
+// and makes tasks left in pending set before fair share groups existed visible to workers
+func (q *TaskQueue) MigrateLegacyQueues() (int, error) {
+	if err := activateScript.Run(q.ctx, q.redisClient, q.pushKeys()).Err(); err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("queue", q.name).Msg("Failed to activate default queue group")
+		return 0, fmt.Errorf("failed to activate default queue group: %w", err)
+	}
+
+	migrated := 0
+	for priority := MaxPriority; priority >= MinPriority; priority-- {
+		keys := append(q.pushKeys(), q.getLegacyQueueKey(priority))
+		for {
+			moved, err := migrateScript.Run(q.ctx, q.redisClient, keys, priorityWeight(priority)).Int()
+			if err != nil {
+				q.logger.GetZerologLogger().Error().Err(err).Str("queue", q.name).Int("priority", priority).Msg("Failed to migrate legacy queue")
+				return migrated, fmt.Errorf("failed to migrate legacy queue: %w", err)
+			}
+			if moved == 0 {
+				break
+			}
+			migrated++
+		}
+	}
+
+	if migrated > 0 {
+		q.logger.GetZerologLogger().Info().Str("queue", q.name).Int("migrated", migrated).Msg("Legacy queue tasks migrated")
+	}
+	return migrated, nil
+}
+
 func (q *TaskQueue) StartWorker(handlers map[string]TaskHandler) {
+	if _, err := q.MigrateLegacyQueues(); err != nil {
+		q.logger.GetZerologLogger().Error().Err(err).Str("queue", q.name).Msg("Failed to migrate legacy queues")
+	}
+
 	go func() {
 		ticker := time.NewTicker(30 * time.Second)
 		defer ticker.Stop()
@@ -289,6 +861,24 @@ func (q *TaskQueue) StartWorker(handlers map[string]TaskHandler) {
 				if err := q.ProcessDelayedTasks(); err != nil {
 					q.logger.GetZerologLogger().Error().Err(err).Msg("Failed to process delayed tasks")
 				}
+				if _, err := q.RequeueExpiredLeases(); err != nil {
+					q.logger.GetZerologLogger().Error().Err(err).Msg("Failed to requeue tasks with expired leases")
+				}
+			case <-q.ctx.Done():
+				return
+			}
+		}
+	}()
+
+	// Heartbeats keep lease of this worker while long tasks are processed
+	go func() {
+		ticker := time.NewTicker(q.leaseTimeout / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				q.Heartbeat()
 			case <-q.ctx.Done():
 				return
 			}
@@ -328,7 +918,30 @@ func (q *TaskQueue) StartWorker(handlers map[string]TaskHandler) {
 					Str("task_type", task.Type).
 					Msg("Processing task")
 
-				if err := handler(q.ctx, task); err != nil {
+				started := time.Now()
+				err = handler(q.ctx, task)
+				result := runResultCompleted
+				switch {
+				case errors.Is(err, ErrTaskCancelled):
+					result = runResultCancelled
+				case err != nil:
+					result = runResultFailed
+				}
+				TaskRunDuration.WithLabelValues(q.name, task.Type, result).Observe(time.Since(started).Seconds())
+
+				switch {
+				case err == nil:
+					q.MarkCompleted(task)
+				case errors.Is(err, ErrTaskCancelled):
+					q.logger.GetZerologLogger().Info().
+						Err(err).
+						Str("task_id", task.ID).
+						Str("task_type", task.Type).
+						Msg("Task processing cancelled")
+
+					q.release(task)
+					q.releaseDedupKey(task)
+				default:
 					q.logger.GetZerologLogger().Error().
 						Err(err).
 						Str("task_id", task.ID).
@@ -336,8 +949,6 @@ func (q *TaskQueue) StartWorker(handlers map[string]TaskHandler) {
 						Msg("Task processing failed")
 
 					q.MarkFailed(task, err)
-				} else {
-					q.MarkCompleted(task)
 				}
 			}
 		}
@@ -351,10 +962,100 @@ func (q *TaskQueue) Close() error {
 	return nil
 }
 
-func (q *TaskQueue) getQueueKey(priority int) string {
+func (q *TaskQueue) getDedupKey(key string) string {
+	return fmt.Sprintf("queue:%s:dedup:%s", q.name, key)
+}
+
+func (q *TaskQueue) getPendingKey() string {
+	return fmt.Sprintf("queue:%s:pending", q.name)
+}
+
+func (q *TaskQueue) getNotifyKey() string {
+	return fmt.Sprintf("queue:%s:notify", q.name)
+}
+
+func (q *TaskQueue) getSequenceKey() string {
+	return fmt.Sprintf("queue:%s:seq", q.name)
+}
+
+// getLegacyQueueKey returns list of previous layout, when each priority had its own list
+func (q *TaskQueue) getLegacyQueueKey(priority int) string {
 	return fmt.Sprintf("queue:%s:priority:%d", q.name, priority)
 }
 
+// getIndexKey returns hash that maps ID of waiting task to its data in pending or delayed set
+func (q *TaskQueue) getIndexKey() string {
+	return fmt.Sprintf("queue:%s:index", q.name)
+}
+
+// getGroupsKey returns set of groups that have pending tasks
+func (q *TaskQueue) getGroupsKey() string {
+	return fmt.Sprintf("queue:%s:groups", q.name)
+}
+
+// getFairKey returns hash with passes and weights of groups and virtual time of queue
+func (q *TaskQueue) getFairKey() string {
+	return fmt.Sprintf("queue:%s:fair", q.name)
+}
+
+// pushKeys returns keys expected by fairLua
+func (q *TaskQueue) pushKeys() []string {
+	return []string{q.getPendingKey(), q.getNotifyKey(), q.getSequenceKey(), q.getIndexKey(), q.getGroupsKey(), q.getFairKey()}
+}
+
+// pendingKeys returns pending sets of all groups that have waiting tasks
+func pendingKeys(ctx context.Context, client *redis.Client, queueName string) ([]string, error) {
+	pendingKey := fmt.Sprintf("queue:%s:pending", queueName)
+	groups, err := client.ZRange(ctx, fmt.Sprintf("queue:%s:groups", queueName), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get queue groups: %w", err)
+	}
+
+	keys := make([]string, 0, len(groups))
+	for _, group := range groups {
+		if group == "" {
+			keys = append(keys, pendingKey)
+		} else {
+			keys = append(keys, pendingKey+":"+group)
+		}
+	}
+	return keys, nil
+}
+
+// countPending returns number of waiting tasks of queue across all groups
+func countPending(ctx context.Context, client *redis.Client, queueName string) (int64, error) {
+	keys, err := pendingKeys(ctx, client, queueName)
+	if err != nil {
+		return 0, err
+	}
+
+	pipe := client.Pipeline()
+	counts := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		counts[i] = pipe.ZCard(ctx, key)
+	}
+	if len(keys) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return 0, fmt.Errorf("failed to count pending tasks: %w", err)
+		}
+	}
+
+	var total int64
+	for _, count := range counts {
+		total += count.Val()
+	}
+	return total, nil
+}
+
+// priorityWeight converts priority to score component, higher priority gives lower score
+func priorityWeight(priority int) int {
+	return MaxPriority - clampPriority(priority)
+}
+
+func clampPriority(priority int) int {
+	return max(MinPriority, min(MaxPriority, priority))
+}
+
 func (q *TaskQueue) getDelayedKey() string {
 	return fmt.Sprintf("queue:%s:delayed", q.name)
 }
@@ -363,8 +1064,23 @@ func (q *TaskQueue) getCompletedKey() string {
 	return fmt.Sprintf("queue:%s:completed", q.name)
 }
 
-func (q *TaskQueue) getFailedKey() string {
-	return fmt.Sprintf("queue:%s:failed", q.name)
+func (q *TaskQueue) getDeadKey() string {
+	return fmt.Sprintf("queue:%s:dead", q.name)
+}
+
+func (q *TaskQueue) getDeadIndexKey(taskType string) string {
+	if taskType == "" {
+		return fmt.Sprintf("queue:%s:dead:index", q.name)
+	}
+	return fmt.Sprintf("queue:%s:dead:type:%s", q.name, taskType)
+}
+
+func (q *TaskQueue) getWorkersKey() string {
+	return fmt.Sprintf("queue:%s:workers", q.name)
+}
+
+func (q *TaskQueue) getInflightKey(workerID string) string {
+	return fmt.Sprintf("queue:%s:inflight:%s", q.name, workerID)
 }
 
 type TaskOption func(*Task)
@@ -388,6 +1104,24 @@ func WithDelay(delay time.Duration) TaskOption {
 	}
 }
 
+// WithDedupKey makes duplicate Enqueue with the same key return existing task instead of creating
+// new one, window limits how long key is kept, zero means default window of queue
+func WithDedupKey(key string, window time.Duration) TaskOption {
+	return func(t *Task) {
+		t.DedupKey = key
+		t.dedupWindow = window
+	}
+}
+
+// WithGroup puts task into fair share group, waiting groups take turns in claiming workers in
+// proportion to their weights, so one group can not starve others. Weight below 1 counts as 1.
+func WithGroup(group string, weight int) TaskOption {
+	return func(t *Task) {
+		t.Group = group
+		t.Weight = max(1, weight)
+	}
+}
+
 // WithScheduledTime sets exact execution time
 func WithScheduledTime(scheduledAt time.Time) TaskOption {
 	return func(t *Task) {
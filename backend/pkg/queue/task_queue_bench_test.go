@@ -0,0 +1,169 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/skr1ms/mosaic/pkg/middleware"
+)
+
+// Benchmarks need running Redis, e.g.
+// QUEUE_BENCH_REDIS_ADDR=localhost:6379 go test -run ^$ -bench Dequeue ./pkg/queue/
+const benchTimeout = 20 * time.Millisecond
+
+func benchRedis(b *testing.B) *redis.Client {
+	addr := os.Getenv("QUEUE_BENCH_REDIS_ADDR")
+	if addr == "" {
+		b.Skip("QUEUE_BENCH_REDIS_ADDR is not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		b.Skipf("redis is not available: %v", err)
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	return client
+}
+
+func benchQueue(b *testing.B, client *redis.Client) *TaskQueue {
+	q := NewTaskQueue("bench-"+uuid.New().String()[:8], client, middleware.NewLogger())
+	b.Cleanup(func() {
+		keys, _ := client.Keys(context.Background(), fmt.Sprintf("queue:%s:*", q.name)).Result()
+		if len(keys) > 0 {
+			client.Del(context.Background(), keys...)
+		}
+		q.Close()
+	})
+	return q
+}
+
+// legacyEnqueue and legacyDequeue reproduce previous layout with list per priority
+// and separate blocking pop for each of them
+func legacyEnqueue(q *TaskQueue, priority int) error {
+	data, err := json.Marshal(&Task{ID: uuid.New().String(), Type: "bench", Priority: priority, CreatedAt: time.Now()})
+	if err != nil {
+		return err
+	}
+	return q.redisClient.LPush(q.ctx, q.getLegacyQueueKey(priority), data).Err()
+}
+
+func legacyDequeue(q *TaskQueue, timeout time.Duration) (*Task, error) {
+	for priority := MaxPriority; priority >= MinPriority; priority-- {
+		result, err := q.redisClient.BRPop(q.ctx, timeout, q.getLegacyQueueKey(priority)).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var task Task
+		if err := json.Unmarshal([]byte(result[1]), &task); err != nil {
+			return nil, err
+		}
+		return &task, nil
+	}
+	return nil, nil
+}
+
+// BenchmarkDequeueLowPriority measures time to get single low priority task,
+// legacy layout waits for timeout on every empty higher priority list
+func BenchmarkDequeueLowPriority(b *testing.B) {
+	client := benchRedis(b)
+
+	b.Run("legacy_priority_lists", func(b *testing.B) {
+		q := benchQueue(b, client)
+		for i := 0; i < b.N; i++ {
+			if err := legacyEnqueue(q, MinPriority); err != nil {
+				b.Fatal(err)
+			}
+			task, err := legacyDequeue(q, benchTimeout)
+			if err != nil || task == nil {
+				b.Fatalf("task not dequeued: %v", err)
+			}
+		}
+	})
+
+	b.Run("sorted_set", func(b *testing.B) {
+		q := benchQueue(b, client)
+		for i := 0; i < b.N; i++ {
+			if _, err := q.Enqueue("bench", nil, WithPriority(MinPriority)); err != nil {
+				b.Fatal(err)
+			}
+			task, err := q.Dequeue(benchTimeout)
+			if err != nil || task == nil {
+				b.Fatalf("task not dequeued: %v", err)
+			}
+			q.release(task)
+		}
+	})
+}
+
+// BenchmarkDequeueIdle measures how long idle worker blocks in single Dequeue call
+func BenchmarkDequeueIdle(b *testing.B) {
+	client := benchRedis(b)
+
+	b.Run("legacy_priority_lists", func(b *testing.B) {
+		q := benchQueue(b, client)
+		for i := 0; i < b.N; i++ {
+			if _, err := legacyDequeue(q, benchTimeout); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("sorted_set", func(b *testing.B) {
+		q := benchQueue(b, client)
+		for i := 0; i < b.N; i++ {
+			if _, err := q.Dequeue(benchTimeout); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkDequeueMixedPriorities measures throughput when tasks of all priorities are waiting
+func BenchmarkDequeueMixedPriorities(b *testing.B) {
+	client := benchRedis(b)
+
+	b.Run("legacy_priority_lists", func(b *testing.B) {
+		q := benchQueue(b, client)
+		for i := 0; i < b.N; i++ {
+			if err := legacyEnqueue(q, i%(MaxPriority+1)); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := legacyDequeue(q, benchTimeout); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("sorted_set", func(b *testing.B) {
+		q := benchQueue(b, client)
+		for i := 0; i < b.N; i++ {
+			if _, err := q.Enqueue("bench", nil, WithPriority(i%(MaxPriority+1))); err != nil {
+				b.Fatal(err)
+			}
+		}
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			task, err := q.Dequeue(benchTimeout)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if task != nil {
+				q.release(task)
+			}
+		}
+	})
+}
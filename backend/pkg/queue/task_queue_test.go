@@ -0,0 +1,781 @@
+package queue
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/rs/zerolog"
+	"github.com/skr1ms/mosaic/pkg/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// Tests need running Redis, e.g.
+// QUEUE_TEST_REDIS_ADDR=localhost:6379 go test ./pkg/queue/
+func testRedis(t *testing.T) *redis.Client {
+	addr := os.Getenv("QUEUE_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("QUEUE_TEST_REDIS_ADDR is not set")
+	}
+
+	client := redis.NewClient(&redis.Options{Addr: addr})
+	if err := client.Ping(context.Background()).Err(); err != nil {
+		t.Skipf("redis is not available: %v", err)
+	}
+	zerolog.SetGlobalLevel(zerolog.Disabled)
+	return client
+}
+
+// testQueue returns queue with unique name, its keys are removed after test
+func testQueue(t *testing.T, client *redis.Client) *TaskQueue {
+	q := NewTaskQueue("test-"+uuid.New().String()[:8], client, middleware.NewLogger())
+	t.Cleanup(func() {
+		keys, _ := client.Keys(context.Background(), fmt.Sprintf("queue:%s:*", q.name)).Result()
+		if len(keys) > 0 {
+			client.Del(context.Background(), keys...)
+		}
+		q.Close()
+	})
+	return q
+}
+
+// testWorker returns another worker of the same queue
+func testWorker(t *testing.T, q *TaskQueue) *TaskQueue {
+	worker := NewTaskQueue(q.name, q.redisClient, q.logger)
+	t.Cleanup(func() { worker.Close() })
+	return worker
+}
+
+func assertTokensMatchPending(t *testing.T, q *TaskQueue) {
+	t.Helper()
+
+	pending, err := countPending(q.ctx, q.redisClient, q.name)
+	require.NoError(t, err)
+	tokens, err := q.redisClient.LLen(q.ctx, q.getNotifyKey()).Result()
+	require.NoError(t, err)
+	assert.Equal(t, pending, tokens, "wake-up tokens must match pending tasks")
+}
+
+func TestTaskQueue_Dequeue_TokensMatchPending(t *testing.T) {
+	client := testRedis(t)
+
+	t.Run("claim without waiting", func(t *testing.T) {
+		q := testQueue(t, client)
+		for i := 0; i < 3; i++ {
+			_, err := q.Enqueue("test", nil)
+			require.NoError(t, err)
+		}
+
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		assertTokensMatchPending(t, q)
+	})
+
+	t.Run("claim after blocking wait", func(t *testing.T) {
+		q := testQueue(t, client)
+
+		done := make(chan *Task, 1)
+		go func() {
+			task, _ := q.Dequeue(2 * time.Second)
+			done <- task
+		}()
+
+		time.Sleep(200 * time.Millisecond)
+		_, err := q.Enqueue("test", nil)
+		require.NoError(t, err)
+		_, err = q.Enqueue("test", nil)
+		require.NoError(t, err)
+
+		task := <-done
+		require.NotNil(t, task)
+		assertTokensMatchPending(t, q)
+
+		// Remaining task is claimed at once and no token is left behind
+		task, err = testWorker(t, q).Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		assertTokensMatchPending(t, q)
+	})
+
+	t.Run("idle worker does not return without task", func(t *testing.T) {
+		q := testQueue(t, client)
+
+		started := time.Now()
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		assert.Nil(t, task)
+		assert.GreaterOrEqual(t, time.Since(started), 900*time.Millisecond)
+	})
+}
+
+func TestTaskQueue_Heartbeat(t *testing.T) {
+	client := testRedis(t)
+	q := testQueue(t, client)
+	q.SetLeaseTimeout(time.Minute)
+
+	require.NoError(t, q.Heartbeat())
+
+	score, err := client.ZScore(q.ctx, q.getWorkersKey(), q.WorkerID()).Result()
+	require.NoError(t, err)
+	assert.InDelta(t, float64(time.Now().Add(time.Minute).Unix()), score, 2)
+
+	// Heartbeat keeps lease alive, so tasks of this worker are not requeued
+	_, err = q.Enqueue("test", nil)
+	require.NoError(t, err)
+	task, err := q.Dequeue(time.Second)
+	require.NoError(t, err)
+	require.NotNil(t, task)
+
+	requeued, err := testWorker(t, q).RequeueExpiredLeases()
+	require.NoError(t, err)
+	assert.Equal(t, 0, requeued)
+
+	inflight, err := client.LLen(q.ctx, q.getInflightKey(q.WorkerID())).Result()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), inflight)
+}
+
+func TestTaskQueue_RequeueExpiredLeases(t *testing.T) {
+	client := testRedis(t)
+
+	t.Run("in-flight task of expired worker is requeued", func(t *testing.T) {
+		q := testQueue(t, client)
+		id, err := q.Enqueue("test", map[string]any{"key": "value"})
+		require.NoError(t, err)
+
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+
+		// Lease of crashed worker expires
+		require.NoError(t, client.ZAdd(q.ctx, q.getWorkersKey(), redis.Z{
+			Score:  float64(time.Now().Add(-time.Second).Unix()),
+			Member: q.WorkerID(),
+		}).Err())
+
+		other := testWorker(t, q)
+		requeued, err := other.RequeueExpiredLeases()
+		require.NoError(t, err)
+		assert.Equal(t, 1, requeued)
+		assertTokensMatchPending(t, q)
+
+		inflight, err := client.LLen(q.ctx, q.getInflightKey(q.WorkerID())).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), inflight)
+
+		_, err = client.ZScore(q.ctx, q.getWorkersKey(), q.WorkerID()).Result()
+		assert.Equal(t, redis.Nil, err, "expired worker without tasks is forgotten")
+
+		redelivered, err := other.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, redelivered)
+		assert.Equal(t, id, redelivered.ID)
+		assert.Equal(t, 1, redelivered.Redelivered)
+		assert.Equal(t, "value", redelivered.Payload["key"])
+	})
+
+	t.Run("acknowledged task is not requeued", func(t *testing.T) {
+		q := testQueue(t, client)
+		_, err := q.Enqueue("test", nil)
+		require.NoError(t, err)
+
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		require.NoError(t, q.MarkCompleted(task))
+
+		require.NoError(t, client.ZAdd(q.ctx, q.getWorkersKey(), redis.Z{
+			Score:  float64(time.Now().Add(-time.Second).Unix()),
+			Member: q.WorkerID(),
+		}).Err())
+
+		requeued, err := testWorker(t, q).RequeueExpiredLeases()
+		require.NoError(t, err)
+		assert.Equal(t, 0, requeued)
+
+		pending, err := client.ZCard(q.ctx, q.getPendingKey()).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), pending)
+	})
+
+	t.Run("task that keeps killing workers is moved to dead-letter store", func(t *testing.T) {
+		q := testQueue(t, client)
+		id, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+
+		other := testWorker(t, q)
+		for i := 0; i <= MaxRedeliveries; i++ {
+			task, err := q.Dequeue(time.Second)
+			require.NoError(t, err)
+			require.NotNil(t, task)
+			assert.Equal(t, id, task.ID)
+
+			require.NoError(t, client.ZAdd(q.ctx, q.getWorkersKey(), redis.Z{
+				Score:  float64(time.Now().Add(-time.Second).Unix()),
+				Member: q.WorkerID(),
+			}).Err())
+			_, err = other.RequeueExpiredLeases()
+			require.NoError(t, err)
+		}
+
+		pending, err := client.ZCard(q.ctx, q.getPendingKey()).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), pending)
+		assertTokensMatchPending(t, q)
+
+		inflight, err := client.LLen(q.ctx, q.getInflightKey(q.WorkerID())).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), inflight)
+
+		dead, err := q.GetDeadTask(id)
+		require.NoError(t, err)
+		assert.Equal(t, MaxRedeliveries+1, dead.Task.Redelivered)
+		assert.NotEmpty(t, dead.LastError)
+
+		_, err = client.Get(q.ctx, q.getDedupKey("process:1")).Result()
+		assert.Equal(t, redis.Nil, err, "dead task frees its deduplication key")
+	})
+
+	t.Run("broken in-flight data is dropped", func(t *testing.T) {
+		q := testQueue(t, client)
+		require.NoError(t, client.LPush(q.ctx, q.getInflightKey(q.WorkerID()), "not json").Err())
+		require.NoError(t, client.ZAdd(q.ctx, q.getWorkersKey(), redis.Z{
+			Score:  float64(time.Now().Add(-time.Second).Unix()),
+			Member: q.WorkerID(),
+		}).Err())
+
+		requeued, err := testWorker(t, q).RequeueExpiredLeases()
+		require.NoError(t, err)
+		assert.Equal(t, 0, requeued)
+
+		inflight, err := client.LLen(q.ctx, q.getInflightKey(q.WorkerID())).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), inflight)
+	})
+}
+
+func TestTaskQueue_Enqueue_Dedup(t *testing.T) {
+	client := testRedis(t)
+
+	t.Run("duplicate of waiting task is skipped", func(t *testing.T) {
+		q := testQueue(t, client)
+		first, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		second, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+
+		assert.Equal(t, first, second)
+		pending, err := client.ZCard(q.ctx, q.getPendingKey()).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), pending)
+	})
+
+	t.Run("duplicate of running task is skipped", func(t *testing.T) {
+		q := testQueue(t, client)
+		first, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+
+		second, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		assert.Equal(t, first, second)
+	})
+
+	t.Run("duplicate replaces payload of waiting task", func(t *testing.T) {
+		q := testQueue(t, client)
+		first, err := q.Enqueue("test", map[string]any{"style": "grayscale"}, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		other, err := q.Enqueue("test", nil)
+		require.NoError(t, err)
+		second, err := q.Enqueue("test", map[string]any{"style": "pop_art"}, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		assert.Equal(t, first, second)
+		assertTokensMatchPending(t, q)
+
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		assert.Equal(t, first, task.ID, "task keeps its place in queue")
+		assert.Equal(t, "pop_art", task.Payload["style"])
+
+		task, err = q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		assert.Equal(t, other, task.ID)
+	})
+
+	t.Run("duplicate replaces payload of delayed task", func(t *testing.T) {
+		q := testQueue(t, client)
+		first, err := q.Enqueue("test", map[string]any{"style": "grayscale"}, WithDedupKey("process:1", 0), WithDelay(time.Hour))
+		require.NoError(t, err)
+		_, err = q.Enqueue("test", map[string]any{"style": "pop_art"}, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+
+		delayed, err := client.ZRange(q.ctx, q.getDelayedKey(), 0, -1).Result()
+		require.NoError(t, err)
+		require.Len(t, delayed, 1)
+		assert.Contains(t, delayed[0], "pop_art")
+
+		cancelled, err := q.CancelByDedupKey("process:1")
+		require.NoError(t, err)
+		assert.True(t, cancelled, "task %s stays cancellable by its key", first)
+	})
+
+	t.Run("duplicate does not change running task", func(t *testing.T) {
+		q := testQueue(t, client)
+		_, err := q.Enqueue("test", map[string]any{"style": "grayscale"}, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+
+		_, err = q.Enqueue("test", map[string]any{"style": "pop_art"}, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+
+		pending, err := client.ZCard(q.ctx, q.getPendingKey()).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), pending)
+		assert.Equal(t, "grayscale", task.Payload["style"])
+	})
+
+	t.Run("completed task frees key", func(t *testing.T) {
+		q := testQueue(t, client)
+		first, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		require.NoError(t, q.MarkCompleted(task))
+
+		second, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("task retried later keeps key", func(t *testing.T) {
+		q := testQueue(t, client)
+		first, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0), WithMaxRetries(2))
+		require.NoError(t, err)
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		require.NoError(t, q.MarkFailed(task, fmt.Errorf("failed")))
+
+		second, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		assert.Equal(t, first, second)
+	})
+
+	t.Run("dead task frees key", func(t *testing.T) {
+		q := testQueue(t, client)
+		first, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0), WithMaxRetries(1))
+		require.NoError(t, err)
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		require.NoError(t, q.MarkFailed(task, fmt.Errorf("failed")))
+
+		second, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("cancelled task frees key", func(t *testing.T) {
+		q := testQueue(t, client)
+		first, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+
+		cancelled, err := q.CancelByDedupKey("process:1")
+		require.NoError(t, err)
+		assert.True(t, cancelled)
+		assertTokensMatchPending(t, q)
+
+		second, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("finished task keeps key reserved by newer task", func(t *testing.T) {
+		q := testQueue(t, client)
+		_, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+
+		// Key expired while task was running and was taken by another task
+		require.NoError(t, client.Del(q.ctx, q.getDedupKey("process:1")).Err())
+		newer, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+
+		require.NoError(t, q.MarkCompleted(task))
+
+		holder, err := client.Get(q.ctx, q.getDedupKey("process:1")).Result()
+		require.NoError(t, err)
+		assert.Equal(t, newer, holder)
+	})
+}
+
+func TestTaskQueue_ReplayDeadTasks(t *testing.T) {
+	client := testRedis(t)
+
+	// killTask enqueues task and lets it exhaust its only attempt
+	killTask := func(t *testing.T, q *TaskQueue, opts ...TaskOption) string {
+		t.Helper()
+		id, err := q.Enqueue("test", map[string]any{"key": "value"}, append(opts, WithMaxRetries(1))...)
+		require.NoError(t, err)
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		require.NoError(t, q.MarkFailed(task, fmt.Errorf("failed")))
+		return id
+	}
+
+	t.Run("dead task returns to queue with fresh retry budget", func(t *testing.T) {
+		q := testQueue(t, client)
+		id := killTask(t, q)
+
+		replayed, err := q.ReplayDeadTasks("", nil)
+		require.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+		assertTokensMatchPending(t, q)
+
+		count, err := q.CountDeadTasks()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), count)
+
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		assert.Equal(t, id, task.ID)
+		assert.Equal(t, 0, task.Retries)
+		assert.Len(t, task.Attempts, 1, "attempt history is kept")
+		assert.Equal(t, "value", task.Payload["key"])
+	})
+
+	t.Run("replayed task reserves its deduplication key", func(t *testing.T) {
+		q := testQueue(t, client)
+		id := killTask(t, q, WithDedupKey("process:1", 0))
+
+		replayed, err := q.ReplayDeadTasks("test", []string{id})
+		require.NoError(t, err)
+		assert.Equal(t, 1, replayed)
+
+		// Fresh enqueue of the same work joins replayed task
+		duplicate, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		assert.Equal(t, id, duplicate)
+
+		// and replayed task can be cancelled by its key
+		cancelled, err := q.CancelByDedupKey("process:1")
+		require.NoError(t, err)
+		assert.True(t, cancelled)
+		assertTokensMatchPending(t, q)
+	})
+
+	t.Run("replay is refused while key is held by another task", func(t *testing.T) {
+		q := testQueue(t, client)
+		id := killTask(t, q, WithDedupKey("process:1", 0))
+		newer, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		require.NotEqual(t, id, newer)
+
+		replayed, err := q.ReplayDeadTasks("", nil)
+		require.NoError(t, err)
+		assert.Equal(t, 0, replayed)
+
+		_, err = q.GetDeadTask(id)
+		assert.NoError(t, err, "refused task stays in dead-letter store")
+
+		pending, err := client.ZCard(q.ctx, q.getPendingKey()).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), pending)
+
+		holder, err := client.Get(q.ctx, q.getDedupKey("process:1")).Result()
+		require.NoError(t, err)
+		assert.Equal(t, newer, holder)
+	})
+
+	t.Run("purged task is not replayed", func(t *testing.T) {
+		q := testQueue(t, client)
+		id := killTask(t, q)
+
+		purged, err := q.PurgeDeadTasks("", []string{id})
+		require.NoError(t, err)
+		assert.Equal(t, 1, purged)
+
+		replayed, err := q.ReplayDeadTasks("", []string{id})
+		require.NoError(t, err)
+		assert.Equal(t, 0, replayed)
+	})
+}
+
+func TestTaskQueue_CancelByDedupKey(t *testing.T) {
+	client := testRedis(t)
+
+	t.Run("waiting task is removed among many others", func(t *testing.T) {
+		q := testQueue(t, client)
+		for i := 0; i < 100; i++ {
+			_, err := q.Enqueue("test", nil, WithDedupKey(fmt.Sprintf("bulk:%d", i), 0))
+			require.NoError(t, err)
+		}
+
+		cancelled, err := q.CancelByDedupKey("bulk:42")
+		require.NoError(t, err)
+		assert.True(t, cancelled)
+		assertTokensMatchPending(t, q)
+
+		pending, err := client.ZCard(q.ctx, q.getPendingKey()).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(99), pending)
+		indexed, err := client.HLen(q.ctx, q.getIndexKey()).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(99), indexed)
+	})
+
+	t.Run("delayed task is removed", func(t *testing.T) {
+		q := testQueue(t, client)
+		_, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0), WithDelay(time.Hour))
+		require.NoError(t, err)
+
+		cancelled, err := q.CancelByDedupKey("process:1")
+		require.NoError(t, err)
+		assert.True(t, cancelled)
+
+		delayed, err := client.ZCard(q.ctx, q.getDelayedKey()).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), delayed)
+	})
+
+	t.Run("running task is not cancelled", func(t *testing.T) {
+		q := testQueue(t, client)
+		_, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+
+		indexed, err := client.HLen(q.ctx, q.getIndexKey()).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), indexed, "claimed task leaves index")
+
+		cancelled, err := q.CancelByDedupKey("process:1")
+		require.NoError(t, err)
+		assert.False(t, cancelled)
+
+		inflight, err := client.LLen(q.ctx, q.getInflightKey(q.WorkerID())).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), inflight)
+	})
+
+	t.Run("retried task is found by its new data", func(t *testing.T) {
+		q := testQueue(t, client)
+		_, err := q.Enqueue("test", nil, WithDedupKey("process:1", 0), WithMaxRetries(2))
+		require.NoError(t, err)
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		require.NoError(t, q.MarkFailed(task, fmt.Errorf("failed")))
+
+		cancelled, err := q.CancelByDedupKey("process:1")
+		require.NoError(t, err)
+		assert.True(t, cancelled)
+	})
+}
+
+// claimGroups dequeues n tasks and returns their groups in claim order
+func claimGroups(t *testing.T, q *TaskQueue, n int) []string {
+	t.Helper()
+
+	groups := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+		require.NoError(t, q.MarkCompleted(task))
+		groups = append(groups, task.Group)
+	}
+	return groups
+}
+
+func countGroup(groups []string, group string) int {
+	count := 0
+	for _, g := range groups {
+		if g == group {
+			count++
+		}
+	}
+	return count
+}
+
+func TestTaskQueue_FairShare(t *testing.T) {
+	client := testRedis(t)
+
+	t.Run("groups with equal weight take turns", func(t *testing.T) {
+		q := testQueue(t, client)
+		for i := 0; i < 100; i++ {
+			_, err := q.Enqueue("test", nil, WithGroup("busy", 1))
+			require.NoError(t, err)
+		}
+		for i := 0; i < 3; i++ {
+			_, err := q.Enqueue("test", nil, WithGroup("small", 1))
+			require.NoError(t, err)
+		}
+
+		// Tasks of small group enqueued after hundred others are not stuck behind them
+		groups := claimGroups(t, q, 6)
+		assert.Equal(t, 3, countGroup(groups, "small"), "claim order %v", groups)
+		for i := 1; i < len(groups); i++ {
+			assert.NotEqual(t, groups[i-1], groups[i], "groups interleave, claim order %v", groups)
+		}
+		assertTokensMatchPending(t, q)
+	})
+
+	t.Run("claims follow group weights", func(t *testing.T) {
+		q := testQueue(t, client)
+		for i := 0; i < 30; i++ {
+			_, err := q.Enqueue("test", nil, WithGroup("heavy", 2))
+			require.NoError(t, err)
+			_, err = q.Enqueue("test", nil, WithGroup("light", 1))
+			require.NoError(t, err)
+		}
+
+		groups := claimGroups(t, q, 12)
+		assert.Equal(t, 8, countGroup(groups, "heavy"), "claim order %v", groups)
+		assert.Equal(t, 4, countGroup(groups, "light"), "claim order %v", groups)
+	})
+
+	t.Run("priority wins over turn of group", func(t *testing.T) {
+		q := testQueue(t, client)
+		for i := 0; i < 5; i++ {
+			_, err := q.Enqueue("test", nil, WithGroup("busy", 1), WithPriority(8))
+			require.NoError(t, err)
+		}
+		_, err := q.Enqueue("test", nil, WithGroup("small", 1), WithPriority(1))
+		require.NoError(t, err)
+
+		groups := claimGroups(t, q, 6)
+		assert.Equal(t, []string{"busy", "busy", "busy", "busy", "busy", "small"}, groups)
+	})
+
+	t.Run("idle group does not save up turns", func(t *testing.T) {
+		q := testQueue(t, client)
+		for i := 0; i < 10; i++ {
+			_, err := q.Enqueue("test", nil, WithGroup("busy", 1))
+			require.NoError(t, err)
+		}
+		claimGroups(t, q, 6)
+
+		for i := 0; i < 10; i++ {
+			_, err := q.Enqueue("test", nil, WithGroup("late", 1))
+			require.NoError(t, err)
+		}
+
+		groups := claimGroups(t, q, 8)
+		assert.Equal(t, 4, countGroup(groups, "busy"), "claim order %v", groups)
+		assert.Equal(t, 4, countGroup(groups, "late"), "claim order %v", groups)
+	})
+
+	t.Run("cancelled task leaves its group", func(t *testing.T) {
+		q := testQueue(t, client)
+		_, err := q.Enqueue("test", nil, WithGroup("single", 1), WithDedupKey("process:1", 0))
+		require.NoError(t, err)
+
+		cancelled, err := q.CancelByDedupKey("process:1")
+		require.NoError(t, err)
+		assert.True(t, cancelled)
+
+		groups, err := client.ZCard(q.ctx, q.getGroupsKey()).Result()
+		require.NoError(t, err)
+		assert.Equal(t, int64(0), groups)
+		assertTokensMatchPending(t, q)
+	})
+}
+
+// testShares maps images to partners for fair scheduling tests
+type testShares map[uuid.UUID]uuid.UUID
+
+func (s testShares) GetQueueShare(ctx context.Context, imageID uuid.UUID) (uuid.UUID, int, error) {
+	partnerID, ok := s[imageID]
+	if !ok {
+		return uuid.Nil, 0, fmt.Errorf("image not found")
+	}
+	return partnerID, 1, nil
+}
+
+func TestImageTaskQueue_BulkTasksInterleaveAcrossPartners(t *testing.T) {
+	client := testRedis(t)
+	q := &ImageTaskQueue{TaskQueue: testQueue(t, client)}
+
+	bulkPartner, customerPartner := uuid.New(), uuid.New()
+	shares := testShares{}
+	q.SetShareResolver(shares)
+
+	// Partner uploads bulk job of 200 images, then customer of another partner activates coupon
+	for i := 0; i < 200; i++ {
+		imageID := uuid.New()
+		shares[imageID] = bulkPartner
+		_, err := q.EnqueueBulkGeneration(imageID, "max_colors")
+		require.NoError(t, err)
+	}
+	customerImage := uuid.New()
+	shares[customerImage] = customerPartner
+	_, err := q.EnqueueBulkGeneration(customerImage, "max_colors")
+	require.NoError(t, err)
+
+	groups := claimGroups(t, q.TaskQueue, 2)
+	assert.Contains(t, groups, customerPartner.String(), "customer image is claimed within first turns, claim order %v", groups)
+}
+
+func TestImageTaskQueue_ClaimImageUpscaling(t *testing.T) {
+	client := testRedis(t)
+
+	t.Run("waiting task is taken over", func(t *testing.T) {
+		q := &ImageTaskQueue{TaskQueue: testQueue(t, client)}
+		imageID := uuid.New()
+		_, err := q.EnqueueImageUpscaling(imageID)
+		require.NoError(t, err)
+
+		claimed, err := q.ClaimImageUpscaling(imageID)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+
+		task, err := q.Dequeue(100 * time.Millisecond)
+		require.NoError(t, err)
+		assert.Nil(t, task, "claimed task is not run by worker")
+	})
+
+	t.Run("running task is waited for", func(t *testing.T) {
+		q := &ImageTaskQueue{TaskQueue: testQueue(t, client)}
+		imageID := uuid.New()
+		_, err := q.EnqueueImageUpscaling(imageID)
+		require.NoError(t, err)
+		task, err := q.Dequeue(time.Second)
+		require.NoError(t, err)
+		require.NotNil(t, task)
+
+		claimed, err := q.ClaimImageUpscaling(imageID)
+		require.NoError(t, err)
+		assert.False(t, claimed)
+
+		require.NoError(t, q.MarkCompleted(task))
+		claimed, err = q.ClaimImageUpscaling(imageID)
+		require.NoError(t, err)
+		assert.True(t, claimed)
+	})
+
+	t.Run("no task", func(t *testing.T) {
+		q := &ImageTaskQueue{TaskQueue: testQueue(t, client)}
+		claimed, err := q.ClaimImageUpscaling(uuid.New())
+		require.NoError(t, err)
+		assert.True(t, claimed)
+	})
+}
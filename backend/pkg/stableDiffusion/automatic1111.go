@@ -0,0 +1,444 @@
+package stableDiffusion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/skr1ms/mosaic/pkg/middleware"
+)
+
+// defaultAutomatic1111Upscaler is upscaler of extras API used when none configured
+const defaultAutomatic1111Upscaler = "R-ESRGAN 4x+"
+
+// defaultSegmentationModel is rembg model used when none configured, it handles people and pets
+const defaultSegmentationModel = "isnet-general-use"
+
+// Automatic1111Backend works with img2img API of AUTOMATIC1111 Stable Diffusion web UI
+type Automatic1111Backend struct {
+	baseURL           string
+	upscaler          string
+	segmentationModel string
+	httpClient        *http.Client
+	logger            *middleware.Logger
+}
+
+// NewAutomatic1111Backend creates backend for web UI at baseURL, URL of img2img endpoint itself is accepted too
+func NewAutomatic1111Backend(baseURL string, httpClient *http.Client, logger *middleware.Logger) *Automatic1111Backend {
+	return &Automatic1111Backend{
+		baseURL:    baseURL,
+		httpClient: httpClient,
+		logger:     logger,
+	}
+}
+
+func (b *Automatic1111Backend) Name() string {
+	return BackendAutomatic1111
+}
+
+// Img2Img converts params to img2img API request
+func (b *Automatic1111Backend) Img2Img(ctx context.Context, params GenerationParams) (string, error) {
+	if params.Sampler == "" {
+		params.Sampler = DefaultSampler
+	}
+	return b.makeImg2ImgRequest(ctx, Img2ImgRequest{
+		InitImages:                        []string{params.ImageBase64},
+		Prompt:                            params.Prompt,
+		NegativePrompt:                    params.NegativePrompt,
+		Steps:                             params.Steps,
+		CfgScale:                          params.CfgScale,
+		DenoisingStrength:                 params.DenoisingStrength,
+		Width:                             params.Width,
+		Height:                            params.Height,
+		SamplerName:                       params.Sampler,
+		BatchSize:                         1,
+		NIter:                             1,
+		Seed:                              params.Seed,
+		RestoreFaces:                      true,
+		SendImages:                        true,
+		SaveImages:                        false,
+		ResizeMode:                        1,
+		Tiling:                            false,
+		OverrideSettings:                  map[string]any{},
+		OverrideSettingsRestoreAfterwards: true,
+	})
+}
+
+// img2imgURL returns img2img endpoint, configured URL may point to it directly or to web UI root
+func (b *Automatic1111Backend) img2imgURL() string {
+	if strings.HasSuffix(strings.TrimRight(b.baseURL, "/"), "/img2img") {
+		return b.baseURL
+	}
+	return b.apiURL("img2img")
+}
+
+// apiURL returns URL of API endpoint
+func (b *Automatic1111Backend) apiURL(endpoint string) string {
+	return b.rootURL() + "/sdapi/v1/" + endpoint
+}
+
+// rootURL returns URL of web UI root, extensions serve their API there
+func (b *Automatic1111Backend) rootURL() string {
+	root := strings.TrimRight(b.baseURL, "/")
+	if idx := strings.Index(root, "/sdapi/"); idx >= 0 {
+		root = root[:idx]
+	}
+	return root
+}
+
+// Img2ImgRequest structure for img2img API request
+type Img2ImgRequest struct {
+	InitImages                        []string       `json:"init_images"`                          // Base images in base64
+	ResizeMode                        int            `json:"resize_mode"`                          // Resize mode (0-3)
+	DenoisingStrength                 float64        `json:"denoising_strength"`                   // Denoising strength (0.0-1.0)
+	ImageCfgScale                     float64        `json:"image_cfg_scale"`                      // Image configuration scale
+	Mask                              string         `json:"mask,omitempty"`                       // Mask in base64 (optional)
+	MaskBlur                          int            `json:"mask_blur"`                            // Mask blur
+	InpaintingFill                    int            `json:"inpainting_fill"`                      // Inpainting fill
+	InpaintFullRes                    bool           `json:"inpaint_full_res"`                     // Inpainting in full resolution
+	InpaintFullResPadding             int            `json:"inpaint_full_res_padding"`             // Padding for full resolution
+	InpaintingMaskInvert              int            `json:"inpainting_mask_invert"`               // Mask inversion
+	InitialNoiseMultiplier            float64        `json:"initial_noise_multiplier"`             // Initial noise multiplier
+	Prompt                            string         `json:"prompt"`                               // Text prompt
+	Styles                            []string       `json:"styles,omitempty"`                     // Styles
+	Seed                              int64          `json:"seed"`                                 // Generation seed
+	Subseed                           int64          `json:"subseed"`                              // Subseed
+	SubseedStrength                   float64        `json:"subseed_strength"`                     // Subseed strength
+	SeedResizeFromH                   int            `json:"seed_resize_from_h"`                   // Height for seed resize
+	SeedResizeFromW                   int            `json:"seed_resize_from_w"`                   // Width for seed resize
+	SamplerName                       string         `json:"sampler_name"`                         // Sampler name
+	BatchSize                         int            `json:"batch_size"`                           // Batch size
+	NIter                             int            `json:"n_iter"`                               // Number of iterations
+	Steps                             int            `json:"steps"`                                // Number of steps
+	CfgScale                          float64        `json:"cfg_scale"`                            // CFG scale
+	Width                             int            `json:"width"`                                // Image width
+	Height                            int            `json:"height"`                               // Image height
+	RestoreFaces                      bool           `json:"restore_faces"`                        // Face restoration
+	Tiling                            bool           `json:"tiling"`                               // Tiling
+	DoNotSaveSamples                  bool           `json:"do_not_save_samples"`                  // Don't save samples
+	DoNotSaveGrid                     bool           `json:"do_not_save_grid"`                     // Don't save grid
+	NegativePrompt                    string         `json:"negative_prompt"`                      // Negative prompt
+	Eta                               float64        `json:"eta"`                                  // Eta parameter
+	SChurn                            float64        `json:"s_churn"`                              // S-churn parameter
+	STmax                             float64        `json:"s_tmax"`                               // S-tmax parameter
+	STmin                             float64        `json:"s_tmin"`                               // S-tmin parameter
+	SNoise                            float64        `json:"s_noise"`                              // S-noise parameter
+	OverrideSettings                  map[string]any `json:"override_settings"`                    // Settings override
+	OverrideSettingsRestoreAfterwards bool           `json:"override_settings_restore_afterwards"` // Restore settings after
+	ScriptArgs                        []any          `json:"script_args,omitempty"`                // Script arguments
+	SamplerIndex                      string         `json:"sampler_index"`                        // Sampler index
+	IncludeInitImages                 bool           `json:"include_init_images"`                  // Include source images
+	ScriptName                        string         `json:"script_name,omitempty"`                // Script name
+	SendImages                        bool           `json:"send_images"`                          // Send images
+	SaveImages                        bool           `json:"save_images"`                          // Save images
+	AlwaysonScripts                   map[string]any `json:"alwayson_scripts,omitempty"`           // Always active scripts
+}
+
+// Img2ImgResponse structure for img2img API response
+type Img2ImgResponse struct {
+	Images     []string       `json:"images"`     // Generated images in base64
+	Parameters map[string]any `json:"parameters"` // Generation parameters
+	Info       string         `json:"info"`       // Generation info
+}
+
+// makeImg2ImgRequest executes request to img2img API
+func (b *Automatic1111Backend) makeImg2ImgRequest(ctx context.Context, req Img2ImgRequest) (image string, err error) {
+	started := time.Now()
+	defer func() {
+		observeRequest(ctx, operationImg2Img, started, err)
+	}()
+
+	jsonData, err := json.Marshal(req)
+	if err != nil {
+		b.logger.GetZerologLogger().Error().
+			Err(err).
+			Msg("Failed to marshal Stable Diffusion request")
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := b.img2imgURL()
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		b.logger.GetZerologLogger().Error().
+			Err(err).
+			Str("url", url).
+			Msg("Failed to create Stable Diffusion request")
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	b.logger.GetZerologLogger().Info().
+		Str("url", url).
+		Int("request_size", len(jsonData)).
+		Str("prompt", req.Prompt).
+		Str("negative_prompt", req.NegativePrompt).
+		Float64("denoising_strength", req.DenoisingStrength).
+		Int("steps", req.Steps).
+		Float64("cfg_scale", req.CfgScale).
+		Msg("Making Stable Diffusion API request")
+
+	resp, err := b.httpClient.Do(httpReq)
+	if err != nil {
+		b.logger.GetZerologLogger().Error().
+			Err(err).
+			Str("url", url).
+			Msg("Failed to make Stable Diffusion request")
+		return "", fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		b.logger.GetZerologLogger().Error().
+			Int("status_code", resp.StatusCode).
+			Str("response_body", string(body)).
+			Str("url", url).
+			Msg("Stable Diffusion API request failed")
+		return "", &apiStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var apiResponse Img2ImgResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+		b.logger.GetZerologLogger().Error().
+			Err(err).
+			Msg("Failed to decode Stable Diffusion response")
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	if len(apiResponse.Images) == 0 {
+		b.logger.GetZerologLogger().Error().
+			Msg("No images returned from Stable Diffusion API")
+		return "", errNoImages
+	}
+
+	b.logger.GetZerologLogger().Info().
+		Int("images_count", len(apiResponse.Images)).
+		Str("info", apiResponse.Info).
+		Int("image_size", len(apiResponse.Images[0])).
+		Msg("Stable Diffusion API request completed successfully")
+
+	return apiResponse.Images[0], nil
+}
+
+// ExtraSingleImageRequest structure for extras API request upscaling single image
+type ExtraSingleImageRequest struct {
+	Image           string  `json:"image"`               // Source image in base64
+	ResizeMode      int     `json:"resize_mode"`         // 0 scales by factor, 1 resizes to given size
+	UpscalingResize float64 `json:"upscaling_resize"`    // Resize factor
+	Upscaler1       string  `json:"upscaler_1"`          // Upscaler name
+	Upscaler2       string  `json:"upscaler_2"`          // Second upscaler blended in, None to skip
+	ShowExtrasImage bool    `json:"show_extras_results"` // Return result image
+}
+
+// ExtraSingleImageResponse structure for extras API response
+type ExtraSingleImageResponse struct {
+	Image    string `json:"image"`     // Upscaled image in base64
+	HTMLInfo string `json:"html_info"` // Processing info
+}
+
+// Upscale enlarges image with upscaler of extras API
+func (b *Automatic1111Backend) Upscale(ctx context.Context, params UpscaleParams) (image string, err error) {
+	started := time.Now()
+	defer func() {
+		observeRequest(ctx, operationUpscale, started, err)
+	}()
+
+	upscaler := params.Upscaler
+	if upscaler == "" {
+		upscaler = b.upscaler
+	}
+	if upscaler == "" {
+		upscaler = defaultAutomatic1111Upscaler
+	}
+
+	jsonData, err := json.Marshal(ExtraSingleImageRequest{
+		Image:           params.ImageBase64,
+		ResizeMode:      0,
+		UpscalingResize: params.Scale,
+		Upscaler1:       upscaler,
+		Upscaler2:       "None",
+		ShowExtrasImage: true,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := b.apiURL("extra-single-image")
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(httpReq)
+	if err != nil {
+		b.logger.GetZerologLogger().Error().
+			Err(err).
+			Str("url", url).
+			Msg("Failed to make Stable Diffusion upscale request")
+		return "", fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		b.logger.GetZerologLogger().Error().
+			Int("status_code", resp.StatusCode).
+			Str("response_body", string(body)).
+			Str("url", url).
+			Msg("Stable Diffusion upscale request failed")
+		return "", &apiStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var apiResponse ExtraSingleImageResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if apiResponse.Image == "" {
+		return "", errNoImages
+	}
+
+	b.logger.GetZerologLogger().Info().
+		Str("upscaler", upscaler).
+		Float64("scale", params.Scale).
+		Int("image_size", len(apiResponse.Image)).
+		Msg("Stable Diffusion upscale request completed successfully")
+
+	return apiResponse.Image, nil
+}
+
+// RembgRequest structure for API request of rembg extension
+type RembgRequest struct {
+	InputImage     string `json:"input_image"`   // Source image in base64
+	Model          string `json:"model"`         // Background removal model
+	ReturnMask     bool   `json:"return_mask"`   // Return mask instead of image without background
+	AlphaMatting   bool   `json:"alpha_matting"` // Refine edges of mask
+	AlphaMattingFG int    `json:"alpha_matting_foreground_threshold"`
+	AlphaMattingBG int    `json:"alpha_matting_background_threshold"`
+	AlphaMattingES int    `json:"alpha_matting_erode_size"`
+}
+
+// RembgResponse structure for API response of rembg extension
+type RembgResponse struct {
+	Image string `json:"image"` // Mask in base64
+}
+
+// Segment builds subject mask with rembg extension of web UI
+func (b *Automatic1111Backend) Segment(ctx context.Context, params SegmentParams) (mask string, err error) {
+	started := time.Now()
+	defer func() {
+		observeRequest(ctx, operationSegment, started, err)
+	}()
+
+	model := params.Model
+	if model == "" {
+		model = b.segmentationModel
+	}
+	if model == "" {
+		model = defaultSegmentationModel
+	}
+
+	jsonData, err := json.Marshal(RembgRequest{
+		InputImage:     params.ImageBase64,
+		Model:          model,
+		ReturnMask:     true,
+		AlphaMatting:   true,
+		AlphaMattingFG: 240,
+		AlphaMattingBG: 10,
+		AlphaMattingES: 10,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	url := b.rootURL() + "/rembg"
+
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := b.httpClient.Do(httpReq)
+	if err != nil {
+		b.logger.GetZerologLogger().Error().
+			Err(err).
+			Str("url", url).
+			Msg("Failed to make Stable Diffusion segmentation request")
+		return "", fmt.Errorf("failed to make request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		b.logger.GetZerologLogger().Error().
+			Int("status_code", resp.StatusCode).
+			Str("response_body", string(body)).
+			Str("url", url).
+			Msg("Stable Diffusion segmentation request failed")
+		return "", &apiStatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var apiResponse RembgResponse
+	if err := json.NewDecoder(resp.Body).Decode(&apiResponse); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if apiResponse.Image == "" {
+		return "", errNoImages
+	}
+
+	b.logger.GetZerologLogger().Info().
+		Str("model", model).
+		Int("mask_size", len(apiResponse.Image)).
+		Msg("Stable Diffusion segmentation request completed successfully")
+
+	return apiResponse.Image, nil
+}
+
+// CheckHealth checks AUTOMATIC1111 API health
+func (b *Automatic1111Backend) CheckHealth(ctx context.Context) (err error) {
+	started := time.Now()
+	defer func() {
+		observeRequest(ctx, operationHealth, started, err)
+	}()
+
+	url := b.apiURL("samplers")
+
+	httpReq, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		b.logger.GetZerologLogger().Error().
+			Err(err).
+			Str("url", url).
+			Msg("Failed to create Stable Diffusion health check request")
+		return fmt.Errorf("failed to create health check request: %w", err)
+	}
+
+	resp, err := b.httpClient.Do(httpReq)
+	if err != nil {
+		b.logger.GetZerologLogger().Error().
+			Err(err).
+			Str("url", url).
+			Msg("Stable Diffusion health check failed")
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		b.logger.GetZerologLogger().Error().
+			Int("status_code", resp.StatusCode).
+			Str("url", url).
+			Msg("Stable Diffusion health check failed with non-OK status")
+		return fmt.Errorf("health check failed: %w", &apiStatusError{StatusCode: resp.StatusCode})
+	}
+
+	b.logger.GetZerologLogger().Info().Msg("Stable Diffusion API health check passed")
+	return nil
+}
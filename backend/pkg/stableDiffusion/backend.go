@@ -0,0 +1,78 @@
+package stableDiffusion
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/skr1ms/mosaic/config"
+	"github.com/skr1ms/mosaic/pkg/middleware"
+)
+
+// Supported AI backends
+const (
+	BackendAutomatic1111 = "automatic1111"
+	BackendComfyUI       = "comfyui"
+)
+
+// GenerationParams is image-to-image request independent of AI server API
+type GenerationParams struct {
+	ImageBase64       string  // Source image in base64
+	Prompt            string  // Text prompt
+	NegativePrompt    string  // Negative prompt
+	Steps             int     // Number of sampling steps
+	CfgScale          float64 // CFG scale
+	DenoisingStrength float64 // Denoising strength (0.0-1.0)
+	Sampler           string  // Sampler name as shown in AUTOMATIC1111
+	Width             int     // Result width
+	Height            int     // Result height
+	Seed              int64   // Generation seed, -1 for random
+}
+
+// UpscaleParams is upscaling request independent of AI server API
+type UpscaleParams struct {
+	ImageBase64 string  // Source image in base64
+	Scale       float64 // Resize factor of both sides
+	Upscaler    string  // Upscaler model, backend default when empty
+}
+
+// SegmentParams is subject segmentation request independent of AI server API
+type SegmentParams struct {
+	ImageBase64 string // Source image in base64
+	Model       string // Segmentation model, backend default when empty
+}
+
+// AIBackend runs image-to-image generation on AI server with specific API
+type AIBackend interface {
+	// Name returns backend name used in logs
+	Name() string
+	// Img2Img returns generated image in base64
+	Img2Img(ctx context.Context, params GenerationParams) (string, error)
+	// Upscale returns source image enlarged by upscaler model in base64
+	Upscale(ctx context.Context, params UpscaleParams) (string, error)
+	// Segment returns mask of photo subject in base64, subject is white and background is black
+	Segment(ctx context.Context, params SegmentParams) (string, error)
+	// CheckHealth checks that AI server is reachable
+	CheckHealth(ctx context.Context) error
+}
+
+// NewBackend creates adapter for AI server API selected in config
+func NewBackend(cfg config.StableDiffusionConfig, httpClient *http.Client, logger *middleware.Logger) (AIBackend, error) {
+	switch cfg.Backend {
+	case "", BackendAutomatic1111:
+		backend := NewAutomatic1111Backend(cfg.BaseURL, httpClient, logger)
+		backend.upscaler = cfg.Upscaler
+		backend.segmentationModel = cfg.SegmentationModel
+		return backend, nil
+	case BackendComfyUI:
+		backend, err := NewComfyUIBackend(cfg.BaseURL, cfg.WorkflowPath, cfg.Checkpoint, httpClient, logger)
+		if err != nil {
+			return nil, err
+		}
+		backend.upscaler = cfg.Upscaler
+		backend.segmentationModel = cfg.SegmentationModel
+		return backend, nil
+	default:
+		return nil, fmt.Errorf("unknown AI backend: %s", cfg.Backend)
+	}
+}
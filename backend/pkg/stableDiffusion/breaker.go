@@ -0,0 +1,276 @@
+package stableDiffusion
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/skr1ms/mosaic/pkg/middleware"
+)
+
+// ErrCircuitOpen is returned without calling AI server while it is considered unavailable
+var ErrCircuitOpen = errors.New("stable diffusion circuit breaker is open")
+
+// BreakerState is state of circuit breaker
+type BreakerState string
+
+const (
+	BreakerClosed   BreakerState = "closed"    // Calls go to AI server
+	BreakerOpen     BreakerState = "open"      // Calls fail immediately until cooldown passes
+	BreakerHalfOpen BreakerState = "half_open" // Single probe call decides whether to close or open again
+)
+
+// BreakerConfig configures when circuit breaker opens
+type BreakerConfig struct {
+	Window    int           // Number of recent calls error rate is computed over
+	MinCalls  int           // Minimal number of calls in window before breaker may open
+	ErrorRate float64       // Share of failed calls in window that opens breaker
+	Cooldown  time.Duration // Time breaker stays open before probing AI server again
+}
+
+// DefaultBreakerConfig opens breaker when half of last 10 calls failed, at least 4 calls are needed
+func DefaultBreakerConfig() BreakerConfig {
+	return BreakerConfig{
+		Window:    10,
+		MinCalls:  4,
+		ErrorRate: 0.5,
+		Cooldown:  30 * time.Second,
+	}
+}
+
+// BreakerStatus is current state of circuit breaker
+type BreakerStatus struct {
+	State     BreakerState `json:"state"`
+	Calls     int          `json:"calls"`     // Calls in current window
+	Failures  int          `json:"failures"`  // Failed calls in current window
+	OpenedAt  *time.Time   `json:"opened_at"` // Time breaker opened last time
+	RetryAt   *time.Time   `json:"retry_at"`  // Time of next probe while breaker is open
+	LastError string       `json:"last_error,omitempty"`
+}
+
+// BreakerBackend guards AI backend with circuit breaker. Only errors meaning AI server is unavailable
+// count as failures: connection errors, timeouts, 5xx responses and failed health checks.
+// Out of memory errors and cancelled requests do not affect breaker.
+type BreakerBackend struct {
+	backend AIBackend
+	cfg     BreakerConfig
+	logger  *middleware.Logger
+	now     func() time.Time
+
+	mu        sync.Mutex
+	state     BreakerState
+	results   []bool // Outcomes of recent calls, true for failure
+	openedAt  time.Time
+	probing   bool
+	lastError string
+}
+
+var _ AIBackend = (*BreakerBackend)(nil)
+
+// NewBreakerBackend wraps backend with circuit breaker
+func NewBreakerBackend(backend AIBackend, cfg BreakerConfig, logger *middleware.Logger) *BreakerBackend {
+	b := &BreakerBackend{
+		backend: backend,
+		cfg:     cfg,
+		logger:  logger,
+		now:     time.Now,
+		state:   BreakerClosed,
+	}
+	CircuitState.Set(circuitStateValue(BreakerClosed))
+	return b
+}
+
+func (b *BreakerBackend) Name() string {
+	return b.backend.Name()
+}
+
+// Img2Img runs generation unless breaker is open
+func (b *BreakerBackend) Img2Img(ctx context.Context, params GenerationParams) (string, error) {
+	if err := b.allow(); err != nil {
+		return "", err
+	}
+
+	image, err := b.backend.Img2Img(ctx, params)
+	b.record(ctx, err)
+	return image, err
+}
+
+// Upscale runs upscaling unless breaker is open
+func (b *BreakerBackend) Upscale(ctx context.Context, params UpscaleParams) (string, error) {
+	if err := b.allow(); err != nil {
+		return "", err
+	}
+
+	image, err := b.backend.Upscale(ctx, params)
+	b.record(ctx, err)
+	return image, err
+}
+
+// Segment runs subject segmentation unless breaker is open
+func (b *BreakerBackend) Segment(ctx context.Context, params SegmentParams) (string, error) {
+	if err := b.allow(); err != nil {
+		return "", err
+	}
+
+	mask, err := b.backend.Segment(ctx, params)
+	b.record(ctx, err)
+	return mask, err
+}
+
+// CheckHealth probes AI server. While breaker is open it fails immediately until cooldown passes,
+// then successful health check closes breaker and failed one keeps it open for another cooldown.
+func (b *BreakerBackend) CheckHealth(ctx context.Context) error {
+	b.mu.Lock()
+	if b.state == BreakerOpen && b.now().Before(b.openedAt.Add(b.cfg.Cooldown)) {
+		b.mu.Unlock()
+		return ErrCircuitOpen
+	}
+	b.mu.Unlock()
+
+	err := b.backend.CheckHealth(ctx)
+	if err != nil {
+		b.record(ctx, err)
+		return err
+	}
+
+	b.mu.Lock()
+	if b.state != BreakerClosed {
+		b.setState(BreakerClosed)
+	}
+	b.mu.Unlock()
+	return nil
+}
+
+// Status returns current state of breaker
+func (b *BreakerBackend) Status() BreakerStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	status := BreakerStatus{
+		State:     b.state,
+		Calls:     len(b.results),
+		LastError: b.lastError,
+	}
+	for _, failed := range b.results {
+		if failed {
+			status.Failures++
+		}
+	}
+	if !b.openedAt.IsZero() {
+		openedAt := b.openedAt
+		status.OpenedAt = &openedAt
+	}
+	if b.state == BreakerOpen {
+		retryAt := b.openedAt.Add(b.cfg.Cooldown)
+		status.RetryAt = &retryAt
+	}
+	return status
+}
+
+// allow decides whether call may go to AI server, after cooldown single probe call is let through
+func (b *BreakerBackend) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerOpen:
+		if b.now().Before(b.openedAt.Add(b.cfg.Cooldown)) {
+			return ErrCircuitOpen
+		}
+		b.setState(BreakerHalfOpen)
+		b.probing = true
+	case BreakerHalfOpen:
+		if b.probing {
+			return ErrCircuitOpen
+		}
+		b.probing = true
+	}
+	return nil
+}
+
+// record updates breaker with outcome of call
+func (b *BreakerBackend) record(ctx context.Context, err error) {
+	failed := err != nil && IsOutage(ctx, err)
+	if err != nil && !failed {
+		// Server answered, but request itself failed, probe slot is released
+		b.mu.Lock()
+		b.probing = false
+		b.mu.Unlock()
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if failed {
+		b.lastError = err.Error()
+	}
+
+	switch b.state {
+	case BreakerHalfOpen:
+		if failed {
+			b.open()
+		} else {
+			b.setState(BreakerClosed)
+		}
+		return
+	case BreakerOpen:
+		if failed {
+			b.openedAt = b.now()
+		}
+		return
+	}
+
+	b.results = append(b.results, failed)
+	if len(b.results) > b.cfg.Window {
+		b.results = b.results[len(b.results)-b.cfg.Window:]
+	}
+
+	failures := 0
+	for _, f := range b.results {
+		if f {
+			failures++
+		}
+	}
+	if len(b.results) >= b.cfg.MinCalls && float64(failures)/float64(len(b.results)) >= b.cfg.ErrorRate {
+		b.open()
+	}
+}
+
+func (b *BreakerBackend) open() {
+	b.openedAt = b.now()
+	b.setState(BreakerOpen)
+}
+
+// setState changes state, window of results starts anew in every state
+func (b *BreakerBackend) setState(state BreakerState) {
+	if b.state == state {
+		return
+	}
+
+	b.logger.GetZerologLogger().Warn().
+		Str("backend", b.backend.Name()).
+		Str("from", string(b.state)).
+		Str("to", string(state)).
+		Str("last_error", b.lastError).
+		Msg("Stable Diffusion circuit breaker state changed")
+
+	b.state = state
+	b.results = nil
+	CircuitState.Set(circuitStateValue(state))
+}
+
+// IsOutage reports whether error means AI server is unavailable, including open circuit
+func IsOutage(ctx context.Context, err error) bool {
+	if errors.Is(err, ErrCircuitOpen) {
+		return true
+	}
+	switch reason := errorReason(ctx, err); reason {
+	case "timeout", "connection", "http_5xx", "invalid_response":
+		return true
+	default:
+		return false
+	}
+}
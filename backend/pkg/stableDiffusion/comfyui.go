@@ -0,0 +1,543 @@
+package stableDiffusion
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"math"
+	"math/rand/v2"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/skr1ms/mosaic/pkg/middleware"
+)
+
+// comfyUIPollInterval is how often finished prompt is looked up in ComfyUI history
+const comfyUIPollInterval = time.Second
+
+// defaultComfyUIWorkflow is img2img workflow in ComfyUI API format. String values consisting of
+// single placeholder are replaced with value of its type, placeholders inside text are substituted.
+const defaultComfyUIWorkflow = `{
+	"1": {"class_type": "CheckpointLoaderSimple", "inputs": {"ckpt_name": "{{checkpoint}}"}},
+	"2": {"class_type": "LoadImage", "inputs": {"image": "{{image}}"}},
+	"3": {"class_type": "ImageScale", "inputs": {"image": ["2", 0], "upscale_method": "lanczos", "width": "{{width}}", "height": "{{height}}", "crop": "center"}},
+	"4": {"class_type": "VAEEncode", "inputs": {"pixels": ["3", 0], "vae": ["1", 2]}},
+	"5": {"class_type": "CLIPTextEncode", "inputs": {"text": "{{prompt}}", "clip": ["1", 1]}},
+	"6": {"class_type": "CLIPTextEncode", "inputs": {"text": "{{negative_prompt}}", "clip": ["1", 1]}},
+	"7": {"class_type": "KSampler", "inputs": {"model": ["1", 0], "positive": ["5", 0], "negative": ["6", 0], "latent_image": ["4", 0], "seed": "{{seed}}", "steps": "{{steps}}", "cfg": "{{cfg}}", "sampler_name": "{{sampler}}", "scheduler": "{{scheduler}}", "denoise": "{{denoise}}"}},
+	"8": {"class_type": "VAEDecode", "inputs": {"samples": ["7", 0], "vae": ["1", 2]}},
+	"9": {"class_type": "SaveImage", "inputs": {"images": ["8", 0], "filename_prefix": "mosaic"}}
+}`
+
+// comfyUIUpscaleWorkflow enlarges image with upscaler model, then scales it to requested size
+// since models have fixed factor
+const comfyUIUpscaleWorkflow = `{
+	"1": {"class_type": "LoadImage", "inputs": {"image": "{{image}}"}},
+	"2": {"class_type": "UpscaleModelLoader", "inputs": {"model_name": "{{upscaler}}"}},
+	"3": {"class_type": "ImageUpscaleWithModel", "inputs": {"upscale_model": ["2", 0], "image": ["1", 0]}},
+	"4": {"class_type": "ImageScale", "inputs": {"image": ["3", 0], "upscale_method": "lanczos", "width": "{{width}}", "height": "{{height}}", "crop": "disabled"}},
+	"5": {"class_type": "SaveImage", "inputs": {"images": ["4", 0], "filename_prefix": "mosaic_upscaled"}}
+}`
+
+// defaultComfyUIUpscaler is upscaler model used when none configured
+const defaultComfyUIUpscaler = "RealESRGAN_x4plus.pth"
+
+// comfyUISegmentWorkflow builds subject mask with rembg node of WAS node suite
+const comfyUISegmentWorkflow = `{
+	"1": {"class_type": "LoadImage", "inputs": {"image": "{{image}}"}},
+	"2": {"class_type": "Image Rembg (Remove Background)", "inputs": {"images": ["1", 0], "transparency": false, "model": "{{model}}", "post_processing": true, "only_mask": true, "alpha_matting": true, "alpha_matting_foreground_threshold": 240, "alpha_matting_background_threshold": 10, "alpha_matting_erode_size": 10, "background_color": "none"}},
+	"3": {"class_type": "SaveImage", "inputs": {"images": ["2", 0], "filename_prefix": "mosaic_mask"}}
+}`
+
+// ComfyUIBackend runs img2img workflow on ComfyUI server
+type ComfyUIBackend struct {
+	baseURL           string
+	workflow          map[string]any
+	checkpoint        string
+	upscaler          string
+	segmentationModel string
+	httpClient        *http.Client
+	logger            *middleware.Logger
+}
+
+// comfyUIImage is reference to image stored by ComfyUI
+type comfyUIImage struct {
+	Filename  string `json:"filename"`
+	Subfolder string `json:"subfolder"`
+	Type      string `json:"type"`
+}
+
+// comfyUIHistoryEntry is result of executed prompt
+type comfyUIHistoryEntry struct {
+	Status struct {
+		StatusStr string `json:"status_str"`
+		Completed bool   `json:"completed"`
+		Messages  []any  `json:"messages"`
+	} `json:"status"`
+	Outputs map[string]struct {
+		Images []comfyUIImage `json:"images"`
+	} `json:"outputs"`
+}
+
+// NewComfyUIBackend creates backend for ComfyUI server at baseURL. Workflow is read from workflowPath,
+// built-in img2img workflow using checkpoint is used when path is empty.
+func NewComfyUIBackend(baseURL, workflowPath, checkpoint string, httpClient *http.Client, logger *middleware.Logger) (*ComfyUIBackend, error) {
+	data := []byte(defaultComfyUIWorkflow)
+	if workflowPath != "" {
+		var err error
+		if data, err = os.ReadFile(workflowPath); err != nil {
+			return nil, fmt.Errorf("failed to read ComfyUI workflow: %w", err)
+		}
+	}
+
+	var workflow map[string]any
+	if err := json.Unmarshal(data, &workflow); err != nil {
+		return nil, fmt.Errorf("failed to parse ComfyUI workflow: %w", err)
+	}
+
+	return &ComfyUIBackend{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		workflow:   workflow,
+		checkpoint: checkpoint,
+		httpClient: httpClient,
+		logger:     logger,
+	}, nil
+}
+
+func (b *ComfyUIBackend) Name() string {
+	return BackendComfyUI
+}
+
+// Img2Img uploads source image, queues workflow and waits for its first output image
+func (b *ComfyUIBackend) Img2Img(ctx context.Context, params GenerationParams) (image string, err error) {
+	started := time.Now()
+	defer func() {
+		observeRequest(ctx, operationImg2Img, started, err)
+	}()
+
+	imageData, err := base64.StdEncoding.DecodeString(params.ImageBase64)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode source image: %w", err)
+	}
+
+	imageName, err := b.uploadImage(ctx, imageData)
+	if err != nil {
+		return "", err
+	}
+
+	seed := params.Seed
+	if seed < 0 {
+		seed = rand.Int64N(1 << 32)
+	}
+	sampler, scheduler := comfyUISampler(params.Sampler)
+	workflow := fillWorkflow(b.workflow, map[string]any{
+		"checkpoint":      b.checkpoint,
+		"image":           imageName,
+		"prompt":          params.Prompt,
+		"negative_prompt": params.NegativePrompt,
+		"seed":            seed,
+		"steps":           params.Steps,
+		"cfg":             params.CfgScale,
+		"sampler":         sampler,
+		"scheduler":       scheduler,
+		"denoise":         params.DenoisingStrength,
+		"width":           params.Width,
+		"height":          params.Height,
+	})
+
+	promptID, err := b.queuePrompt(ctx, workflow)
+	if err != nil {
+		return "", err
+	}
+
+	b.logger.GetZerologLogger().Info().
+		Str("prompt_id", promptID).
+		Str("image", imageName).
+		Int("steps", params.Steps).
+		Float64("denoising_strength", params.DenoisingStrength).
+		Msg("ComfyUI prompt queued")
+
+	output, err := b.waitForOutput(ctx, promptID)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := b.download(ctx, output)
+	if err != nil {
+		return "", err
+	}
+
+	b.logger.GetZerologLogger().Info().
+		Str("prompt_id", promptID).
+		Int("image_size", len(data)).
+		Msg("ComfyUI prompt completed successfully")
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Upscale runs upscaler model workflow on source image
+func (b *ComfyUIBackend) Upscale(ctx context.Context, params UpscaleParams) (result string, err error) {
+	started := time.Now()
+	defer func() {
+		observeRequest(ctx, operationUpscale, started, err)
+	}()
+
+	imageData, err := base64.StdEncoding.DecodeString(params.ImageBase64)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode source image: %w", err)
+	}
+	source, _, err := image.DecodeConfig(bytes.NewReader(imageData))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode source image: %w", err)
+	}
+
+	upscaler := params.Upscaler
+	if upscaler == "" {
+		upscaler = b.upscaler
+	}
+	if upscaler == "" {
+		upscaler = defaultComfyUIUpscaler
+	}
+
+	imageName, err := b.uploadImage(ctx, imageData)
+	if err != nil {
+		return "", err
+	}
+
+	var workflow map[string]any
+	if err := json.Unmarshal([]byte(comfyUIUpscaleWorkflow), &workflow); err != nil {
+		return "", fmt.Errorf("failed to parse ComfyUI workflow: %w", err)
+	}
+	workflow = fillWorkflow(workflow, map[string]any{
+		"image":    imageName,
+		"upscaler": upscaler,
+		"width":    int(math.Round(float64(source.Width) * params.Scale)),
+		"height":   int(math.Round(float64(source.Height) * params.Scale)),
+	})
+
+	promptID, err := b.queuePrompt(ctx, workflow)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := b.waitForOutput(ctx, promptID)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := b.download(ctx, output)
+	if err != nil {
+		return "", err
+	}
+
+	b.logger.GetZerologLogger().Info().
+		Str("prompt_id", promptID).
+		Str("upscaler", upscaler).
+		Float64("scale", params.Scale).
+		Int("image_size", len(data)).
+		Msg("ComfyUI upscale completed successfully")
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Segment runs background removal workflow returning subject mask
+func (b *ComfyUIBackend) Segment(ctx context.Context, params SegmentParams) (mask string, err error) {
+	started := time.Now()
+	defer func() {
+		observeRequest(ctx, operationSegment, started, err)
+	}()
+
+	imageData, err := base64.StdEncoding.DecodeString(params.ImageBase64)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode source image: %w", err)
+	}
+
+	model := params.Model
+	if model == "" {
+		model = b.segmentationModel
+	}
+	if model == "" {
+		model = defaultSegmentationModel
+	}
+
+	imageName, err := b.uploadImage(ctx, imageData)
+	if err != nil {
+		return "", err
+	}
+
+	var workflow map[string]any
+	if err := json.Unmarshal([]byte(comfyUISegmentWorkflow), &workflow); err != nil {
+		return "", fmt.Errorf("failed to parse ComfyUI workflow: %w", err)
+	}
+	workflow = fillWorkflow(workflow, map[string]any{
+		"image": imageName,
+		"model": model,
+	})
+
+	promptID, err := b.queuePrompt(ctx, workflow)
+	if err != nil {
+		return "", err
+	}
+
+	output, err := b.waitForOutput(ctx, promptID)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := b.download(ctx, output)
+	if err != nil {
+		return "", err
+	}
+
+	b.logger.GetZerologLogger().Info().
+		Str("prompt_id", promptID).
+		Str("model", model).
+		Int("mask_size", len(data)).
+		Msg("ComfyUI segmentation completed successfully")
+
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// CheckHealth checks ComfyUI server health
+func (b *ComfyUIBackend) CheckHealth(ctx context.Context) (err error) {
+	started := time.Now()
+	defer func() {
+		observeRequest(ctx, operationHealth, started, err)
+	}()
+
+	resp, err := b.do(ctx, http.MethodGet, "/system_stats", nil, "")
+	if err != nil {
+		b.logger.GetZerologLogger().Error().Err(err).Str("url", b.baseURL).Msg("ComfyUI health check failed")
+		return fmt.Errorf("health check failed: %w", err)
+	}
+	resp.Body.Close()
+
+	b.logger.GetZerologLogger().Info().Msg("ComfyUI health check passed")
+	return nil
+}
+
+// uploadImage stores source image in ComfyUI input directory and returns its name for LoadImage node
+func (b *ComfyUIBackend) uploadImage(ctx context.Context, data []byte) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", fmt.Sprintf("mosaic_%s.png", uuid.New().String()))
+	if err != nil {
+		return "", fmt.Errorf("failed to create upload form: %w", err)
+	}
+	if _, err := part.Write(data); err != nil {
+		return "", fmt.Errorf("failed to create upload form: %w", err)
+	}
+	writer.WriteField("overwrite", "true")
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to create upload form: %w", err)
+	}
+
+	resp, err := b.do(ctx, http.MethodPost, "/upload/image", &body, writer.FormDataContentType())
+	if err != nil {
+		b.logger.GetZerologLogger().Error().Err(err).Msg("Failed to upload image to ComfyUI")
+		return "", fmt.Errorf("failed to upload image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var uploaded struct {
+		Name      string `json:"name"`
+		Subfolder string `json:"subfolder"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&uploaded); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if uploaded.Subfolder != "" {
+		return uploaded.Subfolder + "/" + uploaded.Name, nil
+	}
+	return uploaded.Name, nil
+}
+
+// queuePrompt queues workflow for execution and returns prompt ID
+func (b *ComfyUIBackend) queuePrompt(ctx context.Context, workflow map[string]any) (string, error) {
+	payload, err := json.Marshal(map[string]any{
+		"prompt":    workflow,
+		"client_id": "mosaic",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal request: %w", err)
+	}
+
+	resp, err := b.do(ctx, http.MethodPost, "/prompt", bytes.NewReader(payload), "application/json")
+	if err != nil {
+		b.logger.GetZerologLogger().Error().Err(err).Msg("Failed to queue ComfyUI prompt")
+		return "", fmt.Errorf("failed to queue prompt: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var queued struct {
+		PromptID string `json:"prompt_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&queued); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	if queued.PromptID == "" {
+		return "", fmt.Errorf("no prompt ID returned from API")
+	}
+	return queued.PromptID, nil
+}
+
+// waitForOutput polls history until prompt is executed and returns its first output image
+func (b *ComfyUIBackend) waitForOutput(ctx context.Context, promptID string) (*comfyUIImage, error) {
+	ticker := time.NewTicker(comfyUIPollInterval)
+	defer ticker.Stop()
+
+	for {
+		resp, err := b.do(ctx, http.MethodGet, "/history/"+url.PathEscape(promptID), nil, "")
+		if err != nil {
+			return nil, fmt.Errorf("failed to get prompt history: %w", err)
+		}
+		var history map[string]comfyUIHistoryEntry
+		err = json.NewDecoder(resp.Body).Decode(&history)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode response: %w", err)
+		}
+
+		if entry, ok := history[promptID]; ok {
+			if entry.Status.StatusStr == "error" {
+				messages, _ := json.Marshal(entry.Status.Messages)
+				b.logger.GetZerologLogger().Error().Str("prompt_id", promptID).RawJSON("messages", messages).Msg("ComfyUI prompt execution failed")
+				return nil, fmt.Errorf("prompt execution failed: %s", messages)
+			}
+
+			nodes := make([]string, 0, len(entry.Outputs))
+			for node := range entry.Outputs {
+				nodes = append(nodes, node)
+			}
+			sort.Strings(nodes)
+			for _, node := range nodes {
+				if images := entry.Outputs[node].Images; len(images) > 0 {
+					return &images[0], nil
+				}
+			}
+			if entry.Status.Completed {
+				return nil, errNoImages
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// download returns content of image stored by ComfyUI
+func (b *ComfyUIBackend) download(ctx context.Context, img *comfyUIImage) ([]byte, error) {
+	query := url.Values{}
+	query.Set("filename", img.Filename)
+	query.Set("subfolder", img.Subfolder)
+	query.Set("type", img.Type)
+
+	resp, err := b.do(ctx, http.MethodGet, "/view?"+query.Encode(), nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", err)
+	}
+	return data, nil
+}
+
+// do executes request to ComfyUI API, non-OK responses are returned as apiStatusError
+func (b *ComfyUIBackend) do(ctx context.Context, method, path string, body io.Reader, contentType string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, b.baseURL+path, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := b.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		data, _ := io.ReadAll(resp.Body)
+		return nil, &apiStatusError{StatusCode: resp.StatusCode, Body: string(data)}
+	}
+	return resp, nil
+}
+
+// comfyUISamplers maps AUTOMATIC1111 sampler names to ComfyUI sampler and scheduler
+var comfyUISamplers = map[string][2]string{
+	"Euler":               {"euler", "normal"},
+	"Euler a":             {"euler_ancestral", "normal"},
+	"Heun":                {"heun", "normal"},
+	"LMS":                 {"lms", "normal"},
+	"DDIM":                {"ddim", "ddim_uniform"},
+	"UniPC":               {"uni_pc", "normal"},
+	"DPM++ 2M":            {"dpmpp_2m", "normal"},
+	"DPM++ 2M Karras":     {"dpmpp_2m", "karras"},
+	"DPM++ SDE Karras":    {"dpmpp_sde", "karras"},
+	"DPM++ 2M SDE Karras": {"dpmpp_2m_sde", "karras"},
+}
+
+// comfyUISampler returns ComfyUI sampler and scheduler for sampler name, names unknown to
+// AUTOMATIC1111 are taken as ComfyUI sampler names
+func comfyUISampler(name string) (string, string) {
+	if name == "" {
+		name = DefaultSampler
+	}
+	if sampler, ok := comfyUISamplers[name]; ok {
+		return sampler[0], sampler[1]
+	}
+	return name, "normal"
+}
+
+// fillWorkflow returns copy of workflow with placeholders replaced by values
+func fillWorkflow(workflow map[string]any, values map[string]any) map[string]any {
+	return fillValue(workflow, values).(map[string]any)
+}
+
+func fillValue(value any, values map[string]any) any {
+	switch v := value.(type) {
+	case map[string]any:
+		filled := make(map[string]any, len(v))
+		for key, item := range v {
+			filled[key] = fillValue(item, values)
+		}
+		return filled
+	case []any:
+		filled := make([]any, len(v))
+		for i, item := range v {
+			filled[i] = fillValue(item, values)
+		}
+		return filled
+	case string:
+		if name, ok := strings.CutPrefix(v, "{{"); ok {
+			if name, ok = strings.CutSuffix(name, "}}"); ok {
+				if replacement, exists := values[name]; exists {
+					return replacement
+				}
+			}
+		}
+		for name, replacement := range values {
+			v = strings.ReplaceAll(v, "{{"+name+"}}", fmt.Sprint(replacement))
+		}
+		return v
+	default:
+		return value
+	}
+}
@@ -0,0 +1,416 @@
+package stableDiffusion
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"image"
+	_ "image/jpeg"
+	"image/png"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/disintegration/imaging"
+)
+
+// FakeServer is deterministic stand-in for AI server that serves both AUTOMATIC1111 and ComfyUI APIs
+// without GPU. Instead of diffusion it resizes source image and applies simple filter chosen by prompt,
+// so the same request always gives the same image. Upscaling is plain resize and subject mask is
+// ellipse in the middle of photo.
+type FakeServer struct {
+	// MaxSide makes requests for larger images fail with CUDA out of memory error, zero disables the limit
+	MaxSide int
+
+	mux      *http.ServeMux
+	mu       sync.Mutex
+	inputs   map[string][]byte
+	outputs  map[string][]byte
+	history  map[string]comfyUIHistoryEntry
+	prompts  int
+	requests int
+}
+
+// NewFakeServer creates fake AI server, it can be served by http.Server or httptest.Server
+func NewFakeServer() *FakeServer {
+	s := &FakeServer{
+		mux:     http.NewServeMux(),
+		inputs:  make(map[string][]byte),
+		outputs: make(map[string][]byte),
+		history: make(map[string]comfyUIHistoryEntry),
+	}
+
+	// AUTOMATIC1111 API
+	s.mux.HandleFunc("POST /sdapi/v1/img2img", s.handleImg2Img)
+	s.mux.HandleFunc("POST /sdapi/v1/extra-single-image", s.handleExtraSingleImage)
+	s.mux.HandleFunc("GET /sdapi/v1/samplers", s.handleSamplers)
+	s.mux.HandleFunc("POST /rembg", s.handleRembg)
+
+	// ComfyUI API
+	s.mux.HandleFunc("POST /upload/image", s.handleUpload)
+	s.mux.HandleFunc("POST /prompt", s.handlePrompt)
+	s.mux.HandleFunc("GET /history/{id}", s.handleHistory)
+	s.mux.HandleFunc("GET /view", s.handleView)
+	s.mux.HandleFunc("GET /system_stats", s.handleSystemStats)
+
+	return s
+}
+
+func (s *FakeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Requests returns number of generation requests received, including failed ones
+func (s *FakeServer) Requests() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.requests
+}
+
+func (s *FakeServer) handleImg2Img(w http.ResponseWriter, r *http.Request) {
+	var req Img2ImgRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.InitImages) == 0 {
+		writeFakeJSON(w, http.StatusUnprocessableEntity, map[string]any{"detail": "init_images are required"})
+		return
+	}
+
+	source := req.InitImages[0]
+	if idx := strings.Index(source, ","); strings.HasPrefix(source, "data:") && idx >= 0 {
+		source = source[idx+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(source)
+	if err != nil {
+		writeFakeJSON(w, http.StatusUnprocessableEntity, map[string]any{"detail": "init image is not valid base64"})
+		return
+	}
+
+	result, err := s.generate(data, req.Prompt, req.Width, req.Height)
+	if err != nil {
+		writeFakeJSON(w, http.StatusInternalServerError, map[string]any{"error": "RuntimeError", "detail": err.Error()})
+		return
+	}
+
+	seed := req.Seed
+	if seed < 0 {
+		seed = fakeSeed(data, req.Prompt)
+	}
+	info, _ := json.Marshal(map[string]any{"seed": seed, "prompt": req.Prompt, "fake": true})
+	writeFakeJSON(w, http.StatusOK, Img2ImgResponse{
+		Images:     []string{base64.StdEncoding.EncodeToString(result)},
+		Parameters: map[string]any{},
+		Info:       string(info),
+	})
+}
+
+func (s *FakeServer) handleExtraSingleImage(w http.ResponseWriter, r *http.Request) {
+	var req ExtraSingleImageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Image == "" {
+		writeFakeJSON(w, http.StatusUnprocessableEntity, map[string]any{"detail": "image is required"})
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(req.Image)
+	if err != nil {
+		writeFakeJSON(w, http.StatusUnprocessableEntity, map[string]any{"detail": "image is not valid base64"})
+		return
+	}
+	source, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		writeFakeJSON(w, http.StatusUnprocessableEntity, map[string]any{"detail": "image cannot be decoded"})
+		return
+	}
+
+	width := int(float64(source.Width)*req.UpscalingResize + 0.5)
+	height := int(float64(source.Height)*req.UpscalingResize + 0.5)
+	result, err := s.upscale(data, width, height)
+	if err != nil {
+		writeFakeJSON(w, http.StatusInternalServerError, map[string]any{"error": "RuntimeError", "detail": err.Error()})
+		return
+	}
+
+	writeFakeJSON(w, http.StatusOK, ExtraSingleImageResponse{
+		Image:    base64.StdEncoding.EncodeToString(result),
+		HTMLInfo: "fake upscale",
+	})
+}
+
+func (s *FakeServer) handleRembg(w http.ResponseWriter, r *http.Request) {
+	var req RembgRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.InputImage == "" {
+		writeFakeJSON(w, http.StatusUnprocessableEntity, map[string]any{"detail": "input_image is required"})
+		return
+	}
+
+	data, err := base64.StdEncoding.DecodeString(req.InputImage)
+	if err != nil {
+		writeFakeJSON(w, http.StatusUnprocessableEntity, map[string]any{"detail": "input_image is not valid base64"})
+		return
+	}
+
+	result, err := s.segment(data)
+	if err != nil {
+		writeFakeJSON(w, http.StatusInternalServerError, map[string]any{"error": "RuntimeError", "detail": err.Error()})
+		return
+	}
+
+	writeFakeJSON(w, http.StatusOK, RembgResponse{Image: base64.StdEncoding.EncodeToString(result)})
+}
+
+func (s *FakeServer) handleSamplers(w http.ResponseWriter, r *http.Request) {
+	writeFakeJSON(w, http.StatusOK, []map[string]any{
+		{"name": "DPM++ 2M Karras", "aliases": []string{"k_dpmpp_2m_ka"}, "options": map[string]any{}},
+	})
+}
+
+func (s *FakeServer) handleUpload(w http.ResponseWriter, r *http.Request) {
+	file, header, err := r.FormFile("image")
+	if err != nil {
+		writeFakeJSON(w, http.StatusBadRequest, map[string]any{"error": "image is required"})
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		writeFakeJSON(w, http.StatusBadRequest, map[string]any{"error": err.Error()})
+		return
+	}
+
+	s.mu.Lock()
+	s.inputs[header.Filename] = data
+	s.mu.Unlock()
+
+	writeFakeJSON(w, http.StatusOK, map[string]any{"name": header.Filename, "subfolder": "", "type": "input"})
+}
+
+// handlePrompt executes workflow immediately, it looks only at LoadImage, ImageScale, KSampler,
+// CLIPTextEncode, UpscaleModelLoader, rembg and SaveImage nodes
+func (s *FakeServer) handlePrompt(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Prompt map[string]struct {
+			ClassType string         `json:"class_type"`
+			Inputs    map[string]any `json:"inputs"`
+		} `json:"prompt"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Prompt) == 0 {
+		writeFakeJSON(w, http.StatusBadRequest, map[string]any{"error": map[string]any{"type": "invalid_prompt", "message": "prompt is required"}})
+		return
+	}
+
+	var imageName, prompt, outputNode string
+	var width, height int
+	var upscale, segment bool
+	for id, node := range req.Prompt {
+		switch node.ClassType {
+		case "UpscaleModelLoader":
+			upscale = true
+		case "Image Rembg (Remove Background)":
+			segment = true
+		case "LoadImage":
+			imageName, _ = node.Inputs["image"].(string)
+		case "ImageScale":
+			w, _ := node.Inputs["width"].(float64)
+			h, _ := node.Inputs["height"].(float64)
+			width, height = int(w), int(h)
+		case "KSampler":
+			if ref, ok := node.Inputs["positive"].([]any); ok && len(ref) > 0 {
+				if textNode, ok := req.Prompt[fmt.Sprint(ref[0])]; ok {
+					prompt, _ = textNode.Inputs["text"].(string)
+				}
+			}
+		case "SaveImage":
+			outputNode = id
+		}
+	}
+
+	s.mu.Lock()
+	data, exists := s.inputs[imageName]
+	s.mu.Unlock()
+
+	if !exists || outputNode == "" {
+		writeFakeJSON(w, http.StatusBadRequest, map[string]any{"error": map[string]any{"type": "invalid_prompt", "message": "workflow needs uploaded LoadImage input and SaveImage output"}})
+		return
+	}
+
+	s.mu.Lock()
+	s.prompts++
+	number := s.prompts
+	s.mu.Unlock()
+	promptID := fmt.Sprintf("fake-%d", number)
+
+	var entry comfyUIHistoryEntry
+	entry.Status.Completed = true
+	var result []byte
+	var err error
+	switch {
+	case segment:
+		result, err = s.segment(data)
+	case upscale:
+		result, err = s.upscale(data, width, height)
+	default:
+		result, err = s.generate(data, prompt, width, height)
+	}
+	if err != nil {
+		entry.Status.StatusStr = "error"
+		entry.Status.Messages = []any{[]any{"execution_error", map[string]any{"exception_message": err.Error()}}}
+	} else {
+		filename := fmt.Sprintf("mosaic_%s.png", promptID)
+		entry.Status.StatusStr = "success"
+		entry.Outputs = map[string]struct {
+			Images []comfyUIImage `json:"images"`
+		}{
+			outputNode: {Images: []comfyUIImage{{Filename: filename, Type: "output"}}},
+		}
+		s.mu.Lock()
+		s.outputs[filename] = result
+		s.mu.Unlock()
+	}
+
+	s.mu.Lock()
+	s.history[promptID] = entry
+	s.mu.Unlock()
+
+	writeFakeJSON(w, http.StatusOK, map[string]any{"prompt_id": promptID, "number": number, "node_errors": map[string]any{}})
+}
+
+func (s *FakeServer) handleHistory(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+
+	s.mu.Lock()
+	entry, exists := s.history[id]
+	s.mu.Unlock()
+
+	if !exists {
+		writeFakeJSON(w, http.StatusOK, map[string]any{})
+		return
+	}
+	writeFakeJSON(w, http.StatusOK, map[string]any{id: entry})
+}
+
+func (s *FakeServer) handleView(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	data, exists := s.outputs[r.URL.Query().Get("filename")]
+	s.mu.Unlock()
+
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(data)
+}
+
+func (s *FakeServer) handleSystemStats(w http.ResponseWriter, r *http.Request) {
+	writeFakeJSON(w, http.StatusOK, map[string]any{
+		"system":  map[string]any{"os": "fake", "comfyui_version": "fake"},
+		"devices": []any{},
+	})
+}
+
+// generate resizes image to requested size and applies filter matching prompt
+func (s *FakeServer) generate(data []byte, prompt string, width, height int) ([]byte, error) {
+	s.mu.Lock()
+	s.requests++
+	s.mu.Unlock()
+
+	if s.MaxSide > 0 && max(width, height) > s.MaxSide {
+		return nil, fmt.Errorf("CUDA out of memory. Tried to allocate %dx%d image", width, height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode init image: %w", err)
+	}
+
+	var result image.Image = img
+	if width > 0 && height > 0 {
+		result = imaging.Fill(img, width, height, imaging.Center, imaging.Lanczos)
+	}
+
+	prompt = strings.ToLower(prompt)
+	switch {
+	case strings.Contains(prompt, "grayscale"):
+		result = imaging.Grayscale(result)
+	case strings.Contains(prompt, "pop art"):
+		result = imaging.AdjustContrast(imaging.AdjustSaturation(result, 60), 30)
+	case strings.Contains(prompt, "skin tones"):
+		result = imaging.AdjustGamma(imaging.AdjustSaturation(result, -10), 1.1)
+	default:
+		result = imaging.AdjustSaturation(result, 20)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, result); err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// upscale resizes image to requested size without filter
+func (s *FakeServer) upscale(data []byte, width, height int) ([]byte, error) {
+	s.mu.Lock()
+	s.requests++
+	s.mu.Unlock()
+
+	if s.MaxSide > 0 && max(width, height) > s.MaxSide {
+		return nil, fmt.Errorf("CUDA out of memory. Tried to allocate %dx%d image", width, height)
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, imaging.Resize(img, width, height, imaging.Lanczos)); err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// segment returns mask with ellipse covering middle of image as subject
+func (s *FakeServer) segment(data []byte) ([]byte, error) {
+	s.mu.Lock()
+	s.requests++
+	s.mu.Unlock()
+
+	source, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	mask := image.NewGray(image.Rect(0, 0, source.Width, source.Height))
+	rx, ry := float64(source.Width)*0.3, float64(source.Height)*0.3
+	cx, cy := float64(source.Width)/2, float64(source.Height)/2
+	for y := 0; y < source.Height; y++ {
+		for x := 0; x < source.Width; x++ {
+			dx, dy := (float64(x)+0.5-cx)/rx, (float64(y)+0.5-cy)/ry
+			if dx*dx+dy*dy <= 1 {
+				mask.Pix[y*mask.Stride+x] = 255
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, mask); err != nil {
+		return nil, fmt.Errorf("failed to encode mask: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// fakeSeed derives seed reported for random seed requests from request content
+func fakeSeed(data []byte, prompt string) int64 {
+	h := fnv.New32a()
+	h.Write(data)
+	h.Write([]byte(prompt))
+	return int64(h.Sum32())
+}
+
+func writeFakeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
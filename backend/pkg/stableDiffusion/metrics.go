@@ -0,0 +1,116 @@
+package stableDiffusion
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// API operations reported in metrics
+const (
+	operationImg2Img = "img2img"
+	operationUpscale = "upscale"
+	operationSegment = "segment"
+	operationHealth  = "health"
+)
+
+var (
+	RequestDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "mosaic_stable_diffusion_request_duration_seconds",
+			Help:    "Duration of Stable Diffusion API requests",
+			Buckets: []float64{0.1, 0.5, 1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+		},
+		[]string{"operation", "status"},
+	)
+
+	ErrorsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "mosaic_stable_diffusion_errors_total",
+			Help: "Total number of failed Stable Diffusion API requests",
+		},
+		[]string{"operation", "reason"},
+	)
+
+	CircuitState = promauto.NewGauge(
+		prometheus.GaugeOpts{
+			Name: "mosaic_stable_diffusion_circuit_state",
+			Help: "State of Stable Diffusion circuit breaker: 0 closed, 1 half-open, 2 open",
+		},
+	)
+)
+
+// circuitStateValue converts breaker state to gauge value
+func circuitStateValue(state BreakerState) float64 {
+	switch state {
+	case BreakerHalfOpen:
+		return 1
+	case BreakerOpen:
+		return 2
+	default:
+		return 0
+	}
+}
+
+// errNoImages is returned when API answers without generated image
+var errNoImages = errors.New("no images returned from API")
+
+// apiStatusError is returned when API answers with non-OK status
+type apiStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *apiStatusError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("API request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// observeRequest records duration of finished API request and reason of its failure
+func observeRequest(ctx context.Context, operation string, started time.Time, err error) {
+	status := "success"
+	if err != nil {
+		status = "error"
+		ErrorsTotal.WithLabelValues(operation, errorReason(ctx, err)).Inc()
+	}
+	RequestDuration.WithLabelValues(operation, status).Observe(time.Since(started).Seconds())
+}
+
+// errorReason reduces error to small set of metric label values
+func errorReason(ctx context.Context, err error) string {
+	var (
+		statusErr    *apiStatusError
+		netErr       net.Error
+		urlErr       *url.Error
+		syntaxErr    *json.SyntaxError
+		unmarshalErr *json.UnmarshalTypeError
+	)
+	switch {
+	case errors.Is(ctx.Err(), context.Canceled):
+		return "cancelled"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "timeout"
+	case errors.As(err, &urlErr):
+		return "connection"
+	case errors.As(err, &statusErr):
+		if strings.Contains(strings.ToLower(statusErr.Body), "out of memory") {
+			return "out_of_memory"
+		}
+		return fmt.Sprintf("http_%dxx", statusErr.StatusCode/100)
+	case errors.Is(err, errNoImages), errors.As(err, &syntaxErr), errors.As(err, &unmarshalErr), errors.Is(err, io.ErrUnexpectedEOF):
+		return "invalid_response"
+	default:
+		return "other"
+	}
+}
@@ -0,0 +1,153 @@
+package stableDiffusion
+
+import (
+	"context"
+	"strings"
+)
+
+// Default generation parameters of built-in presets
+const (
+	DefaultSteps             = 25
+	DefaultCfgScale          = 7.5
+	DefaultDenoisingStrength = 0.6
+	DefaultSampler           = "DPM++ 2M Karras"
+
+	// retrySteps limits steps of generations retried with smaller size after out of memory error
+	retrySteps = 20
+	// lightDenoisingStrength is used when AI enhancement is not requested to preserve original
+	lightDenoisingStrength = 0.3
+)
+
+// PromptPreset is set of generation parameters used for processing style and lighting
+type PromptPreset struct {
+	Style             ProcessingStyle `json:"style"`
+	Lighting          LightingType    `json:"lighting"` // Empty when preset is used with any lighting
+	Prompt            string          `json:"prompt"`
+	NegativePrompt    string          `json:"negative_prompt"`
+	Steps             int             `json:"steps"`
+	CfgScale          float64         `json:"cfg_scale"`
+	DenoisingStrength float64         `json:"denoising_strength"`
+	Sampler           string          `json:"sampler"` // Sampler name as shown in AUTOMATIC1111, empty for default
+}
+
+// PresetStore provides presets managed by admins
+type PresetStore interface {
+	// FindPreset returns preset for style and lighting, nil when built-in preset should be used
+	FindPreset(ctx context.Context, style ProcessingStyle, lighting LightingType) (*PromptPreset, error)
+}
+
+var stylePrompts = map[ProcessingStyle]string{
+	StyleGrayscale: "grayscale, monochrome, black and white, elegant",
+	StyleSkinTones: "natural skin tones, warm colors, portrait, realistic",
+	StylePopArt:    "pop art style, vibrant colors, high contrast, bold, artistic",
+	StyleMaxColors: "maximum colors, vibrant, colorful, rich palette, dynamic",
+}
+
+var lightingPrompts = map[LightingType]string{
+	LightingSun:   "bright sunlight, golden hour, warm lighting, natural",
+	LightingMoon:  "moonlight, cool lighting, night scene, atmospheric",
+	LightingVenus: "soft diffused light, ethereal lighting, dreamy",
+}
+
+const baseNegativePrompt = "blurry, low quality, pixelated, artifacts, distorted, watermark, signature, text, ugly, deformed, bad anatomy, disfigured, poorly drawn face, mutated, extra limb, ugly, poorly drawn hands, missing limb, floating limbs, disconnected limbs, malformed hands, blur, out of focus, long neck, long body, mutated hands and fingers, out of frame, blender, doll, cropped, low-res, close-up, poorly-drawn face, out of frame double, two heads, blurred, ugly, disfigured, too many limbs, deformed, repetitive, black and white, grainy, extra limbs, bad anatomy, high pass filter, airbrush, portrait, zoomed, soft light, smooth skin, closeup, deformed, extra limbs, extra fingers, mutated hands, bad anatomy, bad proportions, blind, extra eyes, ugly eyes, dead eyes, blur, vignette, out of shot, out of focus, gaussian, closeup, monochrome, grainy, noisy, text, watermarked, logo, oversaturation, over contrast, over shadow"
+
+var styleNegativePrompts = map[ProcessingStyle]string{
+	StyleGrayscale: "colorful, bright colors, saturation",
+	StyleSkinTones: "unnatural skin, green skin, blue skin, purple skin, red skin",
+	StylePopArt:    "muted colors, dull, grayscale, monochrome",
+	StyleMaxColors: "monochrome, grayscale, black and white",
+}
+
+// Styles returns all processing styles
+func Styles() []ProcessingStyle {
+	return []ProcessingStyle{StyleGrayscale, StyleSkinTones, StylePopArt, StyleMaxColors}
+}
+
+// DefaultPreset returns built-in preset for style and lighting, it is used while admins have not set their own
+func DefaultPreset(style ProcessingStyle, lighting LightingType) PromptPreset {
+	return defaultPreset(style, lighting, true)
+}
+
+func defaultPreset(style ProcessingStyle, lighting LightingType, useAI bool) PromptPreset {
+	prompt := []string{"high quality, detailed, professional"}
+	if text, ok := stylePrompts[style]; ok {
+		prompt = append(prompt, text)
+	}
+	if text, ok := lightingPrompts[lighting]; ok {
+		prompt = append(prompt, text)
+	}
+	if useAI {
+		prompt = append(prompt, "enhanced, professional quality, masterpiece, refined")
+	}
+
+	negativePrompt := baseNegativePrompt
+	if text, ok := styleNegativePrompts[style]; ok {
+		negativePrompt += ", " + text
+	}
+
+	denoisingStrength := DefaultDenoisingStrength
+	if !useAI {
+		denoisingStrength = lightDenoisingStrength
+	}
+
+	return PromptPreset{
+		Style:             style,
+		Lighting:          lighting,
+		Prompt:            strings.Join(prompt, ", "),
+		NegativePrompt:    negativePrompt,
+		Steps:             DefaultSteps,
+		CfgScale:          DefaultCfgScale,
+		DenoisingStrength: denoisingStrength,
+		Sampler:           DefaultSampler,
+	}
+}
+
+// SetPresetStore makes client take presets from store, built-in presets are used for styles store has no preset for
+func (c *StableDiffusionClient) SetPresetStore(store PresetStore) {
+	c.presets = store
+}
+
+// resolvePreset returns preset for request, store errors fall back to built-in preset so processing goes on
+func (c *StableDiffusionClient) resolvePreset(ctx context.Context, req ProcessImageRequest) PromptPreset {
+	if c.presets != nil {
+		preset, err := c.presets.FindPreset(ctx, req.Style, req.Lighting)
+		if err != nil {
+			c.logger.GetZerologLogger().Error().
+				Err(err).
+				Str("style", string(req.Style)).
+				Str("lighting", string(req.Lighting)).
+				Msg("Failed to load prompt preset, using built-in one")
+		} else if preset != nil {
+			return *preset
+		}
+	}
+	return defaultPreset(req.Style, req.Lighting, req.UseAI)
+}
+
+// buildPrompt adds to preset prompt lighting, when preset is not bound to it, and image adjustments
+func buildPrompt(preset PromptPreset, req ProcessImageRequest) string {
+	prompt := preset.Prompt
+	if preset.Lighting == "" {
+		if text, ok := lightingPrompts[req.Lighting]; ok {
+			prompt += ", " + text
+		}
+	}
+
+	switch req.Contrast {
+	case ContrastHigh:
+		prompt += ", high contrast"
+	case ContrastLow:
+		prompt += ", soft contrast"
+	}
+	if req.Brightness > 0 {
+		prompt += ", bright"
+	} else if req.Brightness < 0 {
+		prompt += ", dim lighting"
+	}
+	if req.Saturation > 0 {
+		prompt += ", vibrant colors"
+	} else if req.Saturation < 0 {
+		prompt += ", muted colors"
+	}
+	return prompt
+}
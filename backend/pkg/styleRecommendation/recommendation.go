@@ -0,0 +1,217 @@
+package styleRecommendation
+
+import (
+	"image"
+	"math"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// Styles of coupons that can be recommended
+const (
+	StyleGrayscale = "grayscale"
+	StyleSkinTones = "skin_tones"
+	StylePopArt    = "pop_art"
+	StyleMaxColors = "max_colors"
+)
+
+// Styles in order used to break ties between equal scores
+var Styles = []string{StyleMaxColors, StyleSkinTones, StylePopArt, StyleGrayscale}
+
+const (
+	analysisSide   = 256 // Image is downscaled to fit this side before analysis
+	hueBuckets     = 12  // 30 degrees each
+	dominantHues   = 3
+	minSaturation  = 0.2  // Pixels with less saturation are taken as achromatic
+	minValue       = 0.15 // Pixels darker than this are taken as achromatic
+	colorfulMax    = 100.0
+	contrastMax    = 80.0
+	skinRatioFull  = 0.25 // Skin share of image at which photo is taken as full portrait
+	minDominantHue = 0.05
+)
+
+// Hue is one of dominant hues of image
+type Hue struct {
+	Degrees int     `json:"degrees"` // Center of hue range, 0 is red, 120 is green, 240 is blue
+	Share   float64 `json:"share"`   // Share of chromatic pixels in range
+}
+
+// Analysis is statistics of image used for recommendation. Values are normalized to [0, 1].
+type Analysis struct {
+	Colorfulness   float64 `json:"colorfulness"`
+	Contrast       float64 `json:"contrast"`
+	SkinRatio      float64 `json:"skin_ratio"`
+	ChromaticShare float64 `json:"chromatic_share"`
+	HueDiversity   float64 `json:"hue_diversity"`
+	DominantHues   []Hue   `json:"dominant_hues"`
+}
+
+// Score is how well style suits image, ranked from best
+type Score struct {
+	Style string  `json:"style"`
+	Score float64 `json:"score"`
+	Rank  int     `json:"rank"`
+}
+
+// Recommend analyzes image and returns all styles ranked from best to worst
+func Recommend(img image.Image) (Analysis, []Score) {
+	analysis := Analyze(img)
+	return analysis, Rank(analysis)
+}
+
+// Rank scores styles against analysis of image
+func Rank(a Analysis) []Score {
+	skin := math.Min(1, a.SkinRatio/skinRatioFull)
+
+	scores := map[string]float64{
+		StyleGrayscale: 0.6*(1-a.Colorfulness) + 0.25*a.Contrast + 0.15*(1-a.ChromaticShare),
+		StyleSkinTones: 0.75*skin + 0.25*(1-a.HueDiversity),
+		StylePopArt:    (0.45*a.Colorfulness + 0.3*a.Contrast + 0.25*(1-a.HueDiversity)) * (1 - 0.5*skin),
+		StyleMaxColors: 0.5*a.HueDiversity + 0.3*a.Colorfulness + 0.2*a.ChromaticShare,
+	}
+
+	ranked := make([]Score, 0, len(Styles))
+	for _, style := range Styles {
+		ranked = append(ranked, Score{Style: style, Score: round(scores[style])})
+	}
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].Score > ranked[j].Score
+	})
+	for i := range ranked {
+		ranked[i].Rank = i + 1
+	}
+
+	return ranked
+}
+
+// Analyze computes colorfulness, contrast, skin tone share and dominant hues of image
+func Analyze(img image.Image) Analysis {
+	small := imaging.Fit(img, analysisSide, analysisSide, imaging.Box)
+	bounds := small.Bounds()
+	total := float64(bounds.Dx() * bounds.Dy())
+	if total == 0 {
+		return Analysis{}
+	}
+
+	var (
+		sumRG, sumYB, sumRG2, sumYB2 float64
+		sumLuma, sumLuma2            float64
+		skin, chromatic              float64
+		hues                         [hueBuckets]float64
+	)
+
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := small.NRGBAAt(x, y)
+			r, g, b := float64(c.R), float64(c.G), float64(c.B)
+
+			rg := r - g
+			yb := 0.5*(r+g) - b
+			sumRG += rg
+			sumYB += yb
+			sumRG2 += rg * rg
+			sumYB2 += yb * yb
+
+			luma := 0.299*r + 0.587*g + 0.114*b
+			sumLuma += luma
+			sumLuma2 += luma * luma
+
+			if isSkin(r, g, b) {
+				skin++
+			}
+
+			hue, saturation, value := hsv(r, g, b)
+			if saturation >= minSaturation && value >= minValue {
+				chromatic++
+				hues[int(hue/(360/hueBuckets))%hueBuckets]++
+			}
+		}
+	}
+
+	meanRG, meanYB := sumRG/total, sumYB/total
+	stdRG := math.Sqrt(math.Max(0, sumRG2/total-meanRG*meanRG))
+	stdYB := math.Sqrt(math.Max(0, sumYB2/total-meanYB*meanYB))
+	// Hasler and Süsstrunk colorfulness metric
+	colorfulness := math.Hypot(stdRG, stdYB) + 0.3*math.Hypot(meanRG, meanYB)
+
+	meanLuma := sumLuma / total
+	stdLuma := math.Sqrt(math.Max(0, sumLuma2/total-meanLuma*meanLuma))
+
+	analysis := Analysis{
+		Colorfulness:   round(math.Min(1, colorfulness/colorfulMax)),
+		Contrast:       round(math.Min(1, stdLuma/contrastMax)),
+		SkinRatio:      round(skin / total),
+		ChromaticShare: round(chromatic / total),
+		DominantHues:   []Hue{},
+	}
+
+	if chromatic > 0 {
+		var entropy float64
+		for i, count := range hues {
+			share := count / chromatic
+			if share > 0 {
+				entropy -= share * math.Log(share)
+			}
+			if share >= minDominantHue {
+				analysis.DominantHues = append(analysis.DominantHues, Hue{Degrees: i*360/hueBuckets + 180/hueBuckets, Share: round(share)})
+			}
+		}
+		analysis.HueDiversity = round(entropy / math.Log(hueBuckets))
+
+		sort.SliceStable(analysis.DominantHues, func(i, j int) bool {
+			return analysis.DominantHues[i].Share > analysis.DominantHues[j].Share
+		})
+		if len(analysis.DominantHues) > dominantHues {
+			analysis.DominantHues = analysis.DominantHues[:dominantHues]
+		}
+	}
+
+	return analysis
+}
+
+// isSkin combines RGB rule of Kovač et al. with Cb/Cr range of skin tones
+func isSkin(r, g, b float64) bool {
+	maxC := math.Max(r, math.Max(g, b))
+	minC := math.Min(r, math.Min(g, b))
+	if r <= 95 || g <= 40 || b <= 20 || maxC-minC <= 15 || math.Abs(r-g) <= 15 || r <= g || r <= b {
+		return false
+	}
+
+	cb := 128 - 0.168736*r - 0.331264*g + 0.5*b
+	cr := 128 + 0.5*r - 0.418688*g - 0.081312*b
+	return cb >= 77 && cb <= 127 && cr >= 133 && cr <= 173
+}
+
+// hsv returns hue in degrees, saturation and value in [0, 1]
+func hsv(r, g, b float64) (float64, float64, float64) {
+	maxC := math.Max(r, math.Max(g, b))
+	minC := math.Min(r, math.Min(g, b))
+	delta := maxC - minC
+
+	value := maxC / 255
+	if maxC == 0 || delta == 0 {
+		return 0, 0, value
+	}
+	saturation := delta / maxC
+
+	var hue float64
+	switch maxC {
+	case r:
+		hue = math.Mod((g-b)/delta, 6)
+	case g:
+		hue = (b-r)/delta + 2
+	default:
+		hue = (r-g)/delta + 4
+	}
+	hue *= 60
+	if hue < 0 {
+		hue += 360
+	}
+
+	return hue, saturation, value
+}
+
+func round(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
@@ -0,0 +1,282 @@
+package textOverlay
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strings"
+	"sync"
+
+	"github.com/disintegration/imaging"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+	"golang.org/x/image/vector"
+)
+
+// Font is one of fonts bundled into the binary, all of them cover Latin and Cyrillic
+type Font string
+
+const (
+	FontRegular Font = "regular"
+	FontBold    Font = "bold"
+	FontItalic  Font = "italic"
+	FontMono    Font = "mono"
+)
+
+// Position is anchor of text block on the image
+type Position string
+
+const (
+	PositionTop         Position = "top"
+	PositionCenter      Position = "center"
+	PositionBottom      Position = "bottom"
+	PositionTopLeft     Position = "top_left"
+	PositionTopRight    Position = "top_right"
+	PositionBottomLeft  Position = "bottom_left"
+	PositionBottomRight Position = "bottom_right"
+)
+
+const (
+	DefaultSizePercent = 8.0  // Default text line height in percent of image height
+	marginPercent      = 4.0  // Distance from image border in percent of smaller side
+	lineSpacing        = 1.15 // Line height relative to font size
+)
+
+var fontData = map[Font][]byte{
+	FontRegular: goregular.TTF,
+	FontBold:    gobold.TTF,
+	FontItalic:  goitalic.TTF,
+	FontMono:    gomono.TTF,
+}
+
+var (
+	parseOnce   sync.Once
+	parsedFonts map[Font]*sfnt.Font
+	parseErr    error
+)
+
+// Text describes single text block, multiple lines are separated with "\n"
+type Text struct {
+	Text        string
+	Font        Font
+	Position    Position
+	SizePercent float64 // Line height in percent of image height
+	Color       color.Color
+}
+
+// Fonts returns names of bundled fonts
+func Fonts() []Font {
+	return []Font{FontRegular, FontBold, FontItalic, FontMono}
+}
+
+// Positions returns supported text positions
+func Positions() []Position {
+	return []Position{PositionTop, PositionCenter, PositionBottom, PositionTopLeft, PositionTopRight, PositionBottomLeft, PositionBottomRight}
+}
+
+// Draw renders texts onto copy of image
+func Draw(img image.Image, texts ...Text) (*image.NRGBA, error) {
+	dst := imaging.Clone(img)
+	for _, text := range texts {
+		if err := drawText(dst, text); err != nil {
+			return nil, err
+		}
+	}
+	return dst, nil
+}
+
+func loadFont(name Font) (*sfnt.Font, error) {
+	parseOnce.Do(func() {
+		parsedFonts = make(map[Font]*sfnt.Font, len(fontData))
+		for fontName, data := range fontData {
+			f, err := sfnt.Parse(data)
+			if err != nil {
+				parseErr = fmt.Errorf("failed to parse bundled font %s: %w", fontName, err)
+				return
+			}
+			parsedFonts[fontName] = f
+		}
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+
+	if name == "" {
+		name = FontRegular
+	}
+	f, ok := parsedFonts[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown font: %s", name)
+	}
+	return f, nil
+}
+
+func drawText(dst *image.NRGBA, text Text) error {
+	lines := strings.Split(strings.TrimSpace(text.Text), "\n")
+	if len(lines) == 0 || lines[0] == "" {
+		return nil
+	}
+
+	f, err := loadFont(text.Font)
+	if err != nil {
+		return err
+	}
+
+	bounds := dst.Bounds()
+	sizePercent := text.SizePercent
+	if sizePercent <= 0 {
+		sizePercent = DefaultSizePercent
+	}
+	margin := float64(min(bounds.Dx(), bounds.Dy())) * marginPercent / 100
+	size := float64(bounds.Dy()) * sizePercent / 100
+
+	var buf sfnt.Buffer
+	widths := make([]float64, len(lines))
+	maxWidth := 0.0
+	for i, line := range lines {
+		if widths[i], err = measureLine(f, &buf, line, size); err != nil {
+			return err
+		}
+		maxWidth = math.Max(maxWidth, widths[i])
+	}
+
+	// Shrink text that does not fit between margins
+	if available := float64(bounds.Dx()) - 2*margin; maxWidth > available && maxWidth > 0 {
+		ratio := available / maxWidth
+		size *= ratio
+		maxWidth *= ratio
+		for i := range widths {
+			widths[i] *= ratio
+		}
+	}
+
+	metrics, err := f.Metrics(&buf, fixed.Int26_6(size*64), font.HintingNone)
+	if err != nil {
+		return fmt.Errorf("failed to get font metrics: %w", err)
+	}
+	ascent := float64(metrics.Ascent) / 64
+	descent := float64(metrics.Descent) / 64
+	lineHeight := size * lineSpacing
+	blockHeight := lineHeight*float64(len(lines)-1) + ascent + descent
+
+	var top float64
+	switch text.Position {
+	case PositionTop, PositionTopLeft, PositionTopRight:
+		top = margin
+	case PositionCenter:
+		top = (float64(bounds.Dy()) - blockHeight) / 2
+	default:
+		top = float64(bounds.Dy()) - margin - blockHeight
+	}
+
+	r := vector.NewRasterizer(bounds.Dx(), bounds.Dy())
+	for i, line := range lines {
+		var left float64
+		switch text.Position {
+		case PositionTopLeft, PositionBottomLeft:
+			left = margin
+		case PositionTopRight, PositionBottomRight:
+			left = float64(bounds.Dx()) - margin - widths[i]
+		default:
+			left = (float64(bounds.Dx()) - widths[i]) / 2
+		}
+		baseline := top + ascent + lineHeight*float64(i)
+		if err := rasterizeLine(r, f, &buf, line, size, left, baseline); err != nil {
+			return err
+		}
+	}
+
+	col := text.Color
+	if col == nil {
+		col = color.Black
+	}
+	mask := image.NewAlpha(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+	r.Draw(mask, mask.Bounds(), image.Opaque, image.Point{})
+	draw.DrawMask(dst, bounds, image.NewUniform(col), image.Point{}, mask, image.Point{}, draw.Over)
+
+	return nil
+}
+
+func measureLine(f *sfnt.Font, buf *sfnt.Buffer, line string, size float64) (float64, error) {
+	ppem := fixed.Int26_6(size * 64)
+	var width fixed.Int26_6
+	prev := sfnt.GlyphIndex(0)
+	for _, ch := range line {
+		idx, err := f.GlyphIndex(buf, ch)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get glyph for %q: %w", ch, err)
+		}
+		if prev != 0 && idx != 0 {
+			if kern, err := f.Kern(buf, prev, idx, ppem, font.HintingNone); err == nil {
+				width += kern
+			}
+		}
+		advance, err := f.GlyphAdvance(buf, idx, ppem, font.HintingNone)
+		if err != nil {
+			return 0, fmt.Errorf("failed to get glyph advance for %q: %w", ch, err)
+		}
+		width += advance
+		prev = idx
+	}
+	return float64(width) / 64, nil
+}
+
+func rasterizeLine(r *vector.Rasterizer, f *sfnt.Font, buf *sfnt.Buffer, line string, size, x, baseline float64) error {
+	ppem := fixed.Int26_6(size * 64)
+	dot := float32(x)
+	originY := float32(baseline)
+	prev := sfnt.GlyphIndex(0)
+
+	for _, ch := range line {
+		idx, err := f.GlyphIndex(buf, ch)
+		if err != nil {
+			return fmt.Errorf("failed to get glyph for %q: %w", ch, err)
+		}
+		if prev != 0 && idx != 0 {
+			if kern, err := f.Kern(buf, prev, idx, ppem, font.HintingNone); err == nil {
+				dot += float32(kern) / 64
+			}
+		}
+
+		segments, err := f.LoadGlyph(buf, idx, ppem, nil)
+		if err != nil {
+			return fmt.Errorf("failed to load glyph for %q: %w", ch, err)
+		}
+		// Segment coordinates are 26.6 fixed point numbers relative to glyph origin
+		for _, seg := range segments {
+			switch seg.Op {
+			case sfnt.SegmentOpMoveTo:
+				r.MoveTo(dot+float32(seg.Args[0].X)/64, originY+float32(seg.Args[0].Y)/64)
+			case sfnt.SegmentOpLineTo:
+				r.LineTo(dot+float32(seg.Args[0].X)/64, originY+float32(seg.Args[0].Y)/64)
+			case sfnt.SegmentOpQuadTo:
+				r.QuadTo(
+					dot+float32(seg.Args[0].X)/64, originY+float32(seg.Args[0].Y)/64,
+					dot+float32(seg.Args[1].X)/64, originY+float32(seg.Args[1].Y)/64,
+				)
+			case sfnt.SegmentOpCubeTo:
+				r.CubeTo(
+					dot+float32(seg.Args[0].X)/64, originY+float32(seg.Args[0].Y)/64,
+					dot+float32(seg.Args[1].X)/64, originY+float32(seg.Args[1].Y)/64,
+					dot+float32(seg.Args[2].X)/64, originY+float32(seg.Args[2].Y)/64,
+				)
+			}
+		}
+		r.ClosePath()
+
+		advance, err := f.GlyphAdvance(buf, idx, ppem, font.HintingNone)
+		if err != nil {
+			return fmt.Errorf("failed to get glyph advance for %q: %w", ch, err)
+		}
+		dot += float32(advance) / 64
+		prev = idx
+	}
+	return nil
+}
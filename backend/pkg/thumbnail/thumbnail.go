@@ -0,0 +1,159 @@
+package thumbnail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/disintegration/imaging"
+)
+
+const (
+	FormatJPEG = "jpeg"
+	FormatWebP = "webp"
+
+	DefaultQuality      = 85
+	DefaultCwebpCommand = "cwebp"
+
+	MinSize = 16
+	MaxSize = 2048
+)
+
+// DefaultSizes are longest sides of thumbnails in pixels
+var DefaultSizes = []string{"160", "320", "640"}
+
+// Encoded is image encoded to bytes
+type Encoded struct {
+	Data        []byte
+	Format      string
+	ContentType string
+	Extension   string
+}
+
+// Encoder encodes images to JPEG or WebP. WebP is produced by cwebp utility,
+// encoder falls back to JPEG when utility is not installed.
+type Encoder struct {
+	Format       string
+	Quality      int
+	CwebpCommand string
+}
+
+// NewEncoder creates encoder, unknown format is treated as JPEG
+func NewEncoder(format string, quality int) *Encoder {
+	format = strings.ToLower(strings.TrimSpace(format))
+	if format != FormatWebP {
+		format = FormatJPEG
+	}
+	return &Encoder{
+		Format:       format,
+		Quality:      normalizeQuality(quality),
+		CwebpCommand: DefaultCwebpCommand,
+	}
+}
+
+// ParseSizes converts sizes like "320" to pixels, duplicates are dropped
+func ParseSizes(values []string) ([]int, error) {
+	sizes := make([]int, 0, len(values))
+	seen := make(map[int]bool, len(values))
+	for _, v := range values {
+		size, err := strconv.Atoi(strings.TrimSpace(v))
+		if err != nil {
+			return nil, fmt.Errorf("invalid thumbnail size %q", v)
+		}
+		if size < MinSize || size > MaxSize {
+			return nil, fmt.Errorf("thumbnail size %d is out of range %d-%d", size, MinSize, MaxSize)
+		}
+		if seen[size] {
+			continue
+		}
+		seen[size] = true
+		sizes = append(sizes, size)
+	}
+	return sizes, nil
+}
+
+// Fit scales image down so its longest side is not greater than size, smaller images are returned as is
+func Fit(img image.Image, size int) image.Image {
+	b := img.Bounds()
+	if b.Dx() <= size && b.Dy() <= size {
+		return img
+	}
+	return imaging.Fit(img, size, size, imaging.Lanczos)
+}
+
+// Encode encodes image in format of encoder
+func (e *Encoder) Encode(ctx context.Context, img image.Image) (*Encoded, error) {
+	if e.Format == FormatWebP {
+		if _, err := exec.LookPath(e.CwebpCommand); err == nil {
+			return e.encodeWebP(ctx, img)
+		}
+	}
+	return EncodeJPEG(img, e.Quality)
+}
+
+// EncodeJPEG encodes image as JPEG with given quality
+func EncodeJPEG(img image.Image, quality int) (*Encoded, error) {
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: normalizeQuality(quality)}); err != nil {
+		return nil, fmt.Errorf("failed to encode jpeg: %w", err)
+	}
+	return &Encoded{
+		Data:        buf.Bytes(),
+		Format:      FormatJPEG,
+		ContentType: "image/jpeg",
+		Extension:   ".jpg",
+	}, nil
+}
+
+// encodeWebP passes image to cwebp through temporary files
+func (e *Encoder) encodeWebP(ctx context.Context, img image.Image) (*Encoded, error) {
+	input, err := os.CreateTemp("", "thumbnail-*.png")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(input.Name())
+
+	if err := png.Encode(input, img); err != nil {
+		input.Close()
+		return nil, fmt.Errorf("failed to encode png: %w", err)
+	}
+	if err := input.Close(); err != nil {
+		return nil, fmt.Errorf("failed to write temporary file: %w", err)
+	}
+
+	output := strings.TrimSuffix(input.Name(), ".png") + ".webp"
+	defer os.Remove(output)
+
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, e.CwebpCommand, "-quiet", "-q", strconv.Itoa(e.Quality), input.Name(), "-o", output)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("cwebp failed: %w: %s", err, stderr.String())
+	}
+
+	data, err := os.ReadFile(output)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webp: %w", err)
+	}
+
+	return &Encoded{
+		Data:        data,
+		Format:      FormatWebP,
+		ContentType: "image/webp",
+		Extension:   ".webp",
+	}, nil
+}
+
+func normalizeQuality(quality int) int {
+	if quality <= 0 || quality > 100 {
+		return DefaultQuality
+	}
+	return quality
+}
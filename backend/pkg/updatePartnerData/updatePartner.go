@@ -49,4 +49,7 @@ func UpdatePartnerData(partner *partner.Partner, req *partner.UpdatePartnerReque
 	if req.BrandColors != nil {
 		partner.BrandColors = *req.BrandColors
 	}
+	if req.QueueWeight != nil && *req.QueueWeight > 0 {
+		partner.QueueWeight = *req.QueueWeight
+	}
 }